@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+)
+
+// runCheck implements "server check": it reports hadiths without vectors,
+// hadiths with several, vectors of deleted hadiths and vectors of another
+// embedding model, and exits 1 when there are any. With -fix it repairs
+// them.
+func runCheck(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "delete orphaned points and reindex missing, duplicated and outdated hadiths")
+	cfg := loadConfig(fs, args)
+	ctx := tenant.Unscoped(context.Background())
+	svc, closeIngest := newIngest(ctx, cfg, "check", false)
+
+	r, err := svc.Check(ctx)
+	if err != nil {
+		closeIngest()
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("hadiths: %d, points: %d\n", r.Hadiths, r.Points)
+	fmt.Printf("missing: %d %v\n", len(r.Missing), sample(r.Missing))
+	fmt.Printf("duplicated: %d %v\n", len(r.Duplicated), sample(r.Duplicated))
+	fmt.Printf("orphaned: %d %v\n", len(r.Orphaned), sample(r.Orphaned))
+	fmt.Printf("outdated: %d %v\n", len(r.Outdated), sample(r.Outdated))
+
+	code := 0
+	switch {
+	case r.OK():
+		fmt.Println("ok")
+	case *fix:
+		lock, err := svc.LockIndex(ctx)
+		if err != nil {
+			closeIngest()
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		res, err := svc.Repair(ctx, r)
+		lock.Release()
+		fmt.Printf("deleted points of %d orphans, reindexed %d hadiths, embedded %d\n", len(r.Orphaned), res.Hadiths, res.Embedded)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+		}
+	default:
+		code = 1
+	}
+	closeIngest()
+	os.Exit(code)
+}
+
+// sample returns up to 20 ids for display.
+func sample(ids []int64) []int64 {
+	return ids[:min(len(ids), 20)]
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/config"
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+	"github.com/buugaaga/test-cursor/backend/internal/natsjs"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+)
+
+const (
+	// consumeFetchWait is how long one fetch waits for messages.
+	consumeFetchWait = 30 * time.Second
+	consumeMaxDelay  = 10 * time.Minute
+)
+
+// runConsume implements "server consume": it reads upload request bodies,
+// as POST /v1/admin/hadiths/upload takes them, from a NATS JetStream
+// subject and ingests them until interrupted. A message is acknowledged
+// once its hadiths are stored; failed ones are delivered again, and a
+// redelivered message that was stored already is only acknowledged.
+func runConsume(args []string) {
+	fs := flag.NewFlagSet("consume", flag.ExitOnError)
+	tenantID := fs.Int64("tenant", tenant.Default, "ingest into this tenant id")
+	cfg := loadConfig(fs, args)
+	ctx, stop := signal.NotifyContext(tenant.With(context.Background(), *tenantID), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	svc, closeIngest := newIngest(ctx, cfg, "consume", false)
+	defer closeIngest()
+	// Hadiths stored while the index was down are indexed from the outbox.
+	go svc.RunOutbox(ctx, cfg.Ingest.OutboxInterval)
+
+	nc := natsjs.Config{
+		URL:        cfg.Consume.NATSURL,
+		Token:      cfg.Consume.NATSToken,
+		Stream:     cfg.Consume.Stream,
+		Consumer:   cfg.Consume.Consumer,
+		Subject:    cfg.Consume.Subject,
+		AckWait:    cfg.Consume.AckWait,
+		MaxDeliver: cfg.Consume.MaxDeliver,
+	}
+	retry := time.Second
+	for ctx.Err() == nil {
+		err := consume(ctx, cfg, nc, svc)
+		if ctx.Err() != nil {
+			break
+		}
+		slog.Error("consume: connection failed, reconnecting", "error", err, "in", retry)
+		select {
+		case <-ctx.Done():
+		case <-time.After(retry):
+		}
+		retry = min(retry*2, time.Minute)
+	}
+	slog.Info("consume: stopped")
+}
+
+// consume reads from one connection until it fails or ctx is done.
+func consume(ctx context.Context, cfg *config.Config, nc natsjs.Config, svc *ingest.Service) error {
+	conn, err := natsjs.Dial(ctx, nc)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	slog.Info("consume: reading", "stream", nc.Stream, "consumer", nc.Consumer, "subject", nc.Subject)
+	for {
+		msgs, err := conn.Fetch(ctx, cfg.Consume.Batch, consumeFetchWait)
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if err := consumeMessage(ctx, cfg, conn, svc, m); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// consumeMessage ingests m and acknowledges it, has it delivered again
+// later or, when it can never be ingested, drops it. Only errors talking
+// to NATS are returned.
+func consumeMessage(ctx context.Context, cfg *config.Config, conn *natsjs.Conn, svc *ingest.Service, m *natsjs.Msg) error {
+	log := slog.With("message", m.ID(), "delivered", m.Delivered())
+	var req ingest.HadithUploadRequest
+	if err := json.Unmarshal(m.Data, &req); err != nil {
+		log.Error("consume: dropping malformed message", "error", err)
+		return conn.Term(m)
+	}
+	if id := m.ID(); id != "" {
+		req.MessageID = "nats:" + id
+	}
+
+	uploadCtx, cancel := context.WithTimeout(ctx, cfg.HTTP.UploadTimeout)
+	resp, err := svc.Ingest(uploadCtx, &req)
+	cancel()
+	var apiErr *apierr.APIError
+	switch {
+	case err == nil:
+		log.Info("consume: ingested", "collection", req.Collection.Code, "inserted", resp.Inserted, "embedded", resp.Embedded, "skipped", resp.Skipped, "errors", len(resp.Errors))
+		return conn.Ack(m)
+	case errors.Is(err, ingest.ErrAlreadyIngested):
+		log.Info("consume: already ingested", "collection", req.Collection.Code)
+		return conn.Ack(m)
+	case errors.As(err, &apiErr) && apiErr.Status < http.StatusInternalServerError && apiErr.Status != http.StatusTooManyRequests:
+		log.Error("consume: dropping invalid upload", "collection", req.Collection.Code, "error", err)
+		return conn.Term(m)
+	case ctx.Err() != nil:
+		// Left unacknowledged, the message is delivered again after the ack
+		// wait.
+		return nil
+	}
+	delay := min(10*time.Second<<min(m.Delivered()-1, 10), consumeMaxDelay)
+	log.Warn("consume: ingest failed, will retry", "collection", req.Collection.Code, "error", err, "in", delay)
+	return conn.Nak(m, delay)
+}
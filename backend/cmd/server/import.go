@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+)
+
+// maxImportBatch is the most hadiths one upload may carry; larger files are
+// stored in several transactions of this size.
+const maxImportBatch = 2000
+
+// runImport implements "server import": each file holds an upload request
+// body, {"collection": {...}, "hadiths": [...]}, and is stored and indexed
+// as POST /v1/admin/hadiths/upload would.
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: server import [flags] file.json... (- reads stdin)")
+		fs.PrintDefaults()
+	}
+	tenantID := fs.Int64("tenant", tenant.Default, "import into this tenant id")
+	cfg := loadConfig(fs, args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	ctx := tenant.With(context.Background(), *tenantID)
+	svc, closeIngest := newIngest(ctx, cfg, "import", false)
+
+	code := 0
+	for _, name := range fs.Args() {
+		resp, err := importFile(ctx, svc, name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			code = 1
+			continue
+		}
+		fmt.Printf("%s: inserted %d, embedded %d, skipped %d\n", name, resp.Inserted, resp.Embedded, resp.Skipped)
+		for _, e := range resp.Errors {
+			fmt.Printf("%s: hadith %d (%s): %s\n", name, e.Index+1, e.Number, e.Reason.Message)
+		}
+		for _, m := range resp.LangMismatches {
+			fmt.Printf("%s: hadith %s: %s looks like %s\n", name, m.Number, m.Field, m.Detected)
+		}
+	}
+	closeIngest()
+	os.Exit(code)
+}
+
+func importFile(ctx context.Context, svc *ingest.Service, name string) (ingest.UploadResponse, error) {
+	var b []byte
+	var err error
+	if name == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return ingest.UploadResponse{}, err
+	}
+	var req ingest.HadithUploadRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		return ingest.UploadResponse{}, err
+	}
+	if len(req.Hadiths) == 0 {
+		return ingest.UploadResponse{}, fmt.Errorf("no hadiths")
+	}
+
+	var total ingest.UploadResponse
+	for i := 0; i < len(req.Hadiths); i += maxImportBatch {
+		batch := req
+		batch.Hadiths = req.Hadiths[i:min(i+maxImportBatch, len(req.Hadiths))]
+		resp, err := svc.Ingest(ctx, &batch)
+		total.Inserted += resp.Inserted
+		total.Embedded += resp.Embedded
+		total.Skipped += resp.Skipped
+		total.LangMismatches = append(total.LangMismatches, resp.LangMismatches...)
+		for _, e := range resp.Errors {
+			e.Index += i
+			total.Errors = append(total.Errors, e)
+		}
+		if err != nil {
+			return total, fmt.Errorf("hadiths %d-%d: %w", i+1, i+len(batch.Hadiths), err)
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,104 @@
+// Command server runs the REST, gRPC and MCP APIs, and the operator
+// commands that share their configuration.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/config"
+	"github.com/buugaaga/test-cursor/backend/internal/logging"
+)
+
+const usage = `usage: server [command] [flags]
+
+commands:
+  serve          run the HTTP, gRPC and MCP servers (the default)
+  migrate        apply or roll back database migrations
+  import         store and index hadiths from JSON files
+  consume        ingest uploads published on a NATS JetStream subject
+  reindex        embed hadiths again and replace their vectors
+  check          compare Postgres with the vector index
+  config print   print the effective configuration
+
+Every command takes -config and the setting flags; see "server <command> -h".
+`
+
+var commands = map[string]func(args []string){
+	"serve":   serve,
+	"migrate": runMigrate,
+	"import":  runImport,
+	"consume": runConsume,
+	"reindex": runReindex,
+	"check":   runCheck,
+	"config":  runConfig,
+}
+
+func main() {
+	// Without a command the flags are the server's, as before there were
+	// subcommands.
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	run, ok := commands[name]
+	if !ok {
+		if name != "help" {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		}
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	run(args)
+}
+
+// loadConfig loads the configuration for a command from args and sets up
+// logging from it, exiting on invalid settings.
+func loadConfig(fs *flag.FlagSet, args []string) *config.Config {
+	return loadSettings(fs, args).Config
+}
+
+// loadSettings is loadConfig keeping where each setting came from, which
+// serve needs to reload them.
+func loadSettings(fs *flag.FlagSet, args []string) *config.Loaded {
+	loaded, err := config.Load(context.Background(), fs, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	cfg := loaded.Config
+	logging.Setup(cfg.Log.Level, cfg.Log.Format)
+	if loaded.File != "" {
+		slog.Info("loaded config file", "path", loaded.File)
+	}
+
+	logging.SlowThresholds.Postgres = cfg.Slow.Query
+	logging.SlowThresholds.Qdrant = cfg.Slow.Qdrant
+	logging.SlowThresholds.Embedder = cfg.Slow.Embedder
+	logging.SlowThresholds.Request = cfg.Slow.Request
+	return loaded
+}
+
+// runConfig implements "server config print": it loads the configuration as
+// the server would and prints it with secrets redacted.
+func runConfig(args []string) {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: server config print [-format yaml|env] [flags]")
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("config print", flag.ExitOnError)
+	format := fs.String("format", "yaml", "output format: yaml or env")
+	loaded, err := config.Load(context.Background(), fs, args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := loaded.Print(os.Stdout, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/pressly/goose/v3"
+)
+
+const migrateUsage = `usage: server migrate [flags] <command> [version]
+
+commands:
+  up [version]     apply pending migrations, or those up to version
+  down [version]   roll back the last migration, or those above version
+  status           list the migrations and when each was applied
+  version          print the current schema version
+`
+
+// runMigrate implements "server migrate": it connects with the server's
+// configuration and runs one goose command against the embedded migrations.
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), migrateUsage)
+		fs.PrintDefaults()
+	}
+	cfg := loadConfig(fs, args)
+	ctx := context.Background()
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	command := fs.Arg(0)
+	version := int64(-1)
+	if fs.NArg() == 2 {
+		var err error
+		if version, err = strconv.ParseInt(fs.Arg(1), 10, 64); err != nil || version < 0 {
+			fmt.Fprintf(os.Stderr, "invalid version %q\n", fs.Arg(1))
+			os.Exit(2)
+		}
+	}
+
+	pg, err := postgres.OpenAs(ctx, cfg.Postgres.DSN, "migrate")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "postgres:", err)
+		os.Exit(1)
+	}
+	m, err := postgres.NewMigrator(pg)
+	if err == nil {
+		err = migrate(ctx, m, command, version)
+	}
+	pg.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func migrate(ctx context.Context, m *goose.Provider, command string, version int64) error {
+	var results []*goose.MigrationResult
+	var err error
+	switch command {
+	case "up":
+		if version < 0 {
+			results, err = m.Up(ctx)
+		} else {
+			results, err = m.UpTo(ctx, version)
+		}
+	case "down":
+		if version < 0 {
+			var r *goose.MigrationResult
+			if r, err = m.Down(ctx); r != nil {
+				results = append(results, r)
+			}
+		} else {
+			results, err = m.DownTo(ctx, version)
+		}
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tMIGRATION\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.State == goose.StateApplied {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Source.Version, s.Source.Path, applied)
+		}
+		return w.Flush()
+	case "version":
+		current, latest, err := m.GetVersions(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d (latest %d)\n", current, latest)
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q, want up, down, status or version", command)
+	}
+	for _, r := range results {
+		fmt.Println(r)
+	}
+	if len(results) == 0 && err == nil {
+		fmt.Println("no migrations to run")
+	}
+	return err
+}
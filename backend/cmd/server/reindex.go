@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+)
+
+// runReindex implements "server reindex": it embeds the hadiths of one or
+// every collection again. After changing the embedding model it must
+// reindex every collection of every tenant, which replaces the index's
+// model.
+func runReindex(args []string) {
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	collection := fs.String("collection", "", "reindex only this collection code")
+	tenantID := fs.Int64("tenant", tenant.All, "reindex only this tenant id; -collection defaults it to the default tenant")
+	dryRun := fs.Bool("dry-run", false, "only estimate the tokens and cost of embedding")
+	cfg := loadConfig(fs, args)
+	if *collection != "" && *tenantID == tenant.All {
+		*tenantID = tenant.Default
+	}
+	ctx := tenant.With(context.Background(), *tenantID)
+	svc, closeIngest := newIngest(ctx, cfg, "reindex", true)
+	if *dryRun {
+		res, err := svc.EstimateReindex(ctx, *collection)
+		closeIngest()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("would embed %d texts of %d hadiths: %d characters, about %d tokens, $%.4f\n",
+			res.Usage.Texts, res.Hadiths, res.Usage.Chars, res.Usage.Tokens, res.Usage.Cost)
+		return
+	}
+	lock, err := svc.LockIndex(ctx)
+	if err != nil {
+		closeIngest()
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	res, err := svc.Reindex(ctx, *collection)
+	lock.Release()
+	closeIngest()
+	fmt.Printf("reindexed %d hadiths, embedded %d: %d characters, about %d tokens, $%.4f\n",
+		res.Hadiths, res.Embedded, res.Usage.Chars, res.Usage.Tokens, res.Usage.Cost)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/buugaaga/test-cursor/backend/internal/httpapi"
+	"github.com/buugaaga/test-cursor/backend/internal/logging"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/vector"
+)
+
+// serve runs the HTTP, gRPC and MCP servers until the HTTP server stops.
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	mcpStdio := fs.Bool("mcp-stdio", false, "serve the MCP tools over stdin/stdout instead of starting the HTTP and gRPC servers")
+	devEmbedder := fs.Bool("dev-embedder", false, "embed with deterministic hash-based vectors served in-process instead of the embedding service")
+	devEmbedderAddr := fs.String("dev-embedder-addr", "127.0.0.1:8000", "address the -dev-embedder API listens on, for other commands to use as EMBEDDER_URL")
+	loaded := loadSettings(fs, args)
+	cfg := loaded.Config
+	ctx := context.Background()
+	if *devEmbedder {
+		startDevEmbedder(cfg, *devEmbedderAddr)
+	}
+
+	replica := httpapi.NewReplicaID()
+	pg := openPostgres(ctx, cfg, replica)
+	defer pg.Close()
+	embedder := newEmbedder(cfg)
+	model := embeddingModel(ctx, vector.PrimaryIndex, embedder)
+	unlock := lockStartup(ctx, pg)
+	checkIndexModel(ctx, pg, model)
+	vectors, qClient := openVectors(ctx, cfg, pg, model)
+	shadow := openShadow(ctx, cfg, pg, qClient)
+	unlock()
+	read := openReadPostgres(ctx, cfg, replica)
+	if read != nil {
+		defer read.Close()
+	}
+
+	err := httpapi.Run(ctx, httpapi.Config{
+		Settings:       cfg,
+		Loaded:         loaded,
+		ReplicaID:      replica,
+		Postgres:       pg,
+		ReadPostgres:   read,
+		Qdrant:         qClient,
+		QdrantHTTPURL:  qdrantHTTPURL(cfg),
+		Store:          postgres.NewStore(pg),
+		Vectors:        vectors,
+		Embedder:       embedder,
+		EmbeddingModel: model,
+		Shadow:         shadow,
+		Cache:          newResultCache(cfg),
+		MCPStdio:       *mcpStdio,
+	})
+	if err != nil {
+		logging.Fatal("server error", "error", err)
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/cache"
+	"github.com/buugaaga/test-cursor/backend/internal/config"
+	"github.com/buugaaga/test-cursor/backend/internal/embed"
+	"github.com/buugaaga/test-cursor/backend/internal/httpapi"
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+	"github.com/buugaaga/test-cursor/backend/internal/logging"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/vector"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// The helpers below build the infrastructure every command shares and exit
+// when it is unavailable.
+
+// openPostgres connects as app and brings the schema up to date or checks
+// it, as configured.
+func openPostgres(ctx context.Context, cfg *config.Config, app string) *pgxpool.Pool {
+	pg, err := postgres.OpenPool(ctx, cfg.Postgres.DSN, app, postgres.PoolConfig{
+		MaxConns:        cfg.Postgres.MaxConns,
+		MinConns:        cfg.Postgres.MinConns,
+		MaxConnLifetime: cfg.Postgres.MaxConnLifetime,
+		MaxConnIdleTime: cfg.Postgres.MaxConnIdleTime,
+	})
+	if err != nil {
+		logging.Fatal("postgres init", "error", err)
+	}
+	unlock := lockStartup(ctx, pg)
+	defer unlock()
+	if err := postgres.EnsureSchema(ctx, pg, cfg.Postgres.AutoMigrate); err != nil {
+		logging.Fatal("schema", "error", err)
+	}
+	return pg
+}
+
+// openReadPostgres connects to the read replica, or returns nil when none
+// is configured. Its schema is the primary's.
+func openReadPostgres(ctx context.Context, cfg *config.Config, app string) *pgxpool.Pool {
+	if cfg.Postgres.ReadDSN == "" {
+		return nil
+	}
+	pg, err := postgres.OpenPool(ctx, cfg.Postgres.ReadDSN, app+":read", postgres.PoolConfig{
+		MaxConns:        cfg.Postgres.ReadMaxConns,
+		MinConns:        cfg.Postgres.ReadMinConns,
+		MaxConnLifetime: cfg.Postgres.MaxConnLifetime,
+		MaxConnIdleTime: cfg.Postgres.MaxConnIdleTime,
+	})
+	if err != nil {
+		logging.Fatal("postgres read replica init", "error", err)
+	}
+	return pg
+}
+
+// lockStartup waits for the startup lock, so that replicas starting
+// together migrate and create the collections one at a time, and returns
+// its release.
+func lockStartup(ctx context.Context, pg *pgxpool.Pool) func() {
+	lock, err := postgres.AcquireLock(ctx, pg, "startup")
+	if err != nil {
+		logging.Fatal("startup lock", "error", err)
+	}
+	return lock.Release
+}
+
+// embeddingModel asks the embedder which model it serves and how long its
+// vectors are.
+func embeddingModel(ctx context.Context, index string, embedder *embed.Client) embed.Model {
+	model, err := embedder.Model(ctx)
+	if err != nil {
+		logging.Fatal("embedding model", "index", index, "error", err)
+	}
+	slog.Info("embedding model", "index", index, "model", model.Name, "dimensions", model.Dimensions)
+	return model
+}
+
+// collectionConfig builds the vector collection settings. The vector size
+// is the model's unless configured, in which case the two must agree.
+func collectionConfig(cfg *config.Config, model embed.Model) vector.CollectionConfig {
+	collectionCfg, err := vector.NewCollectionConfig(cfg.Qdrant)
+	if err != nil {
+		logging.Fatal("collection config", "error", err)
+	}
+	switch collectionCfg.Size {
+	case 0:
+		collectionCfg.Size = uint64(model.Dimensions)
+	case uint64(model.Dimensions):
+	default:
+		logging.Fatal("QDRANT_VECTOR_SIZE does not match the embedding model", "vector_size", collectionCfg.Size, "model", model.Name, "dimensions", model.Dimensions)
+	}
+	return collectionCfg
+}
+
+// checkIndexModel records model for an index that has none, and exits when
+// the index holds vectors of another model; `server reindex` switches it.
+func checkIndexModel(ctx context.Context, pg *pgxpool.Pool, model embed.Model) {
+	if err := vector.EnsureModel(ctx, pg, vector.PrimaryIndex, model.Name, model.Dimensions); err != nil {
+		logging.Fatal("embedding model check; run `server reindex` to rebuild the index", "error", err)
+	}
+}
+
+// openQdrant connects and creates or checks the collection and its payload
+// indexes.
+func openQdrant(ctx context.Context, cfg *config.Config, collectionCfg vector.CollectionConfig) *qdrant.Client {
+	qClient, err := vector.Open(cfg.Qdrant.Host, cfg.Qdrant.GRPCPort, cfg.Qdrant.UseTLS, cfg.Qdrant.APIKey)
+	if err != nil {
+		logging.Fatal("qdrant init", "error", err)
+	}
+	if err := vector.EnsureCollection(ctx, qClient, vector.Collection, collectionCfg); err != nil {
+		logging.Fatal("ensure collection", "error", err)
+	}
+	if err := vector.EnsurePayloadIndexes(ctx, qClient, vector.Collection); err != nil {
+		logging.Fatal("ensure payload indexes", "error", err)
+	}
+	return qClient
+}
+
+// openVectors opens the configured vector backend. The Qdrant client is
+// nil with pgvector, which keeps the points in pg.
+func openVectors(ctx context.Context, cfg *config.Config, pg *pgxpool.Pool, model embed.Model) (vector.Index, *qdrant.Client) {
+	collectionCfg := collectionConfig(cfg, model)
+	if cfg.Vector.Backend == "qdrant" {
+		qClient := openQdrant(ctx, cfg, collectionCfg)
+		return vector.NewIndex(qClient, vector.NewQdrantOptions(cfg.Qdrant)), qClient
+	}
+	return openPGVector(ctx, pg, vector.PGVectorTable, collectionCfg), nil
+}
+
+func openPGVector(ctx context.Context, pg *pgxpool.Pool, table string, collectionCfg vector.CollectionConfig) vector.Index {
+	if err := vector.EnsurePGVector(ctx, pg, table, collectionCfg); err != nil {
+		logging.Fatal("ensure pgvector", "table", table, "error", err)
+	}
+	index, err := vector.NewPGVectorIndex(pg, table, collectionCfg.Distance)
+	if err != nil {
+		logging.Fatal("pgvector init", "error", err)
+	}
+	return index
+}
+
+// openShadow builds the shadow embedder and its index on the primary's
+// backend, or returns nil when SHADOW_EMBEDDER_PROVIDER is unset. The
+// shadow index is disposable: when it holds another model's vectors it is
+// dropped and created again, empty, for the shadow.backfill job to fill.
+func openShadow(ctx context.Context, cfg *config.Config, pg *pgxpool.Pool, qClient *qdrant.Client) *ingest.Shadow {
+	if cfg.Shadow.Provider == "" {
+		return nil
+	}
+	ec := embedderConfig(cfg)
+	ec.Provider, ec.URL, ec.Model, ec.Dimensions = cfg.Shadow.Provider, cfg.Shadow.URL, cfg.Shadow.Model, cfg.Shadow.Dimensions
+	ec.APIKey, ec.APIKeyHeader = cfg.Shadow.APIKey, ""
+	ec.CAFile, ec.CertFile, ec.KeyFile, ec.ServerName = "", "", "", ""
+	ec.MaxBatch, ec.MaxBatchChars, ec.Meter = 0, 0, nil
+	embedder, err := embed.New(ec)
+	if err != nil {
+		logging.Fatal("shadow embedder init", "error", err)
+	}
+	model := embeddingModel(ctx, vector.ShadowIndex, embedder)
+	collectionCfg, err := vector.NewCollectionConfig(cfg.Qdrant)
+	if err != nil {
+		logging.Fatal("collection config", "error", err)
+	}
+	collectionCfg.Size = uint64(model.Dimensions)
+
+	var mismatch *vector.ModelMismatchError
+	err = vector.EnsureModel(ctx, pg, vector.ShadowIndex, model.Name, model.Dimensions)
+	changed := errors.As(err, &mismatch)
+	if err != nil && !changed {
+		logging.Fatal("shadow embedding model check", "error", err)
+	}
+
+	var index vector.Index
+	if qClient != nil {
+		if changed {
+			if err := qClient.DeleteCollection(ctx, vector.ShadowCollection); err != nil {
+				logging.Fatal("drop shadow collection", "error", err)
+			}
+		}
+		if err := vector.EnsureCollection(ctx, qClient, vector.ShadowCollection, collectionCfg); err != nil {
+			logging.Fatal("ensure shadow collection", "error", err)
+		}
+		if err := vector.EnsurePayloadIndexes(ctx, qClient, vector.ShadowCollection); err != nil {
+			logging.Fatal("ensure shadow payload indexes", "error", err)
+		}
+		index = vector.NewCollectionIndex(qClient, vector.ShadowCollection, vector.NewQdrantOptions(cfg.Qdrant))
+	} else {
+		if changed {
+			if err := vector.DropPGVector(ctx, pg, vector.PGVectorShadowTable); err != nil {
+				logging.Fatal("drop shadow table", "error", err)
+			}
+		}
+		index = openPGVector(ctx, pg, vector.PGVectorShadowTable, collectionCfg)
+	}
+	if changed {
+		if err := vector.SetModel(ctx, pg, vector.ShadowIndex, model.Name, model.Dimensions); err != nil {
+			logging.Fatal("record shadow embedding model", "error", err)
+		}
+		slog.Warn("shadow embedding model changed, shadow index dropped", "from", mismatch.Indexed, "to", model.Name)
+	}
+	if n, err := index.Count(ctx, nil); err == nil && n == 0 {
+		slog.Warn("shadow index is empty; run the shadow.backfill job to fill it", "model", model.Name)
+	}
+	return &ingest.Shadow{Embedder: embedder, Vectors: index, Model: model}
+}
+
+func qdrantHTTPURL(cfg *config.Config) string {
+	scheme := "http"
+	if cfg.Qdrant.UseTLS {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, cfg.Qdrant.Host, cfg.Qdrant.HTTPPort)
+}
+
+// startDevEmbedder serves embed.DevHandler on addr and points the embedder
+// settings at it. Its vectors are the collection's size, so the index
+// accepts them.
+func startDevEmbedder(cfg *config.Config, addr string) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		logging.Fatal("dev embedder listen", "error", err)
+	}
+	dim := int(cfg.Qdrant.VectorSize)
+	if dim == 0 {
+		dim = 768
+	}
+	srv := &http.Server{Handler: embed.DevHandler(dim), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil {
+			logging.Fatal("dev embedder", "error", err)
+		}
+	}()
+	cfg.Embedder.Provider, cfg.Embedder.URL, cfg.Embedder.Model = "http", "http://"+ln.Addr().String(), ""
+	cfg.Embedder.APIKey, cfg.Embedder.APIKeyHeader = "", ""
+	cfg.Embedder.CAFile, cfg.Embedder.CertFile, cfg.Embedder.KeyFile, cfg.Embedder.ServerName = "", "", "", ""
+	slog.Warn("using the dev embedder; search results only match equal texts", "url", cfg.Embedder.URL, "dimensions", dim)
+}
+
+func newEmbedder(cfg *config.Config) *embed.Client {
+	embedder, err := embed.New(embedderConfig(cfg))
+	if err != nil {
+		logging.Fatal("embedder init", "error", err)
+	}
+	return embedder
+}
+
+func embedderConfig(cfg *config.Config) embed.Config {
+	return embed.Config{
+		Provider:         cfg.Embedder.Provider,
+		URL:              cfg.Embedder.URL,
+		Model:            cfg.Embedder.Model,
+		Dimensions:       cfg.Embedder.Dimensions,
+		MaxBatch:         cfg.Embedder.MaxBatch,
+		MaxBatchChars:    cfg.Embedder.MaxBatchChars,
+		APIKey:           cfg.Embedder.APIKey,
+		APIKeyHeader:     cfg.Embedder.APIKeyHeader,
+		CAFile:           cfg.Embedder.CAFile,
+		CertFile:         cfg.Embedder.CertFile,
+		KeyFile:          cfg.Embedder.KeyFile,
+		ServerName:       cfg.Embedder.ServerName,
+		Timeout:          cfg.Embedder.Timeout,
+		MaxRetries:       cfg.Embedder.MaxRetries,
+		RetryBackoff:     cfg.Embedder.RetryBackoff,
+		BreakerThreshold: cfg.Embedder.BreakerThreshold,
+		BreakerCooldown:  cfg.Embedder.BreakerCooldown,
+		MaxConcurrency:   cfg.Embedder.MaxConcurrency,
+		Meter:            httpapi.MeterEmbedding,
+	}
+}
+
+func newResultCache(cfg *config.Config) *cache.ResultCache {
+	cacheTTLs, err := cache.ParseTTLs(cfg.Cache.TTLs)
+	if err != nil {
+		logging.Fatal("invalid CACHE_TTLS", "error", err)
+	}
+	var cacheBackend cache.Cache
+	switch cfg.Cache.Backend {
+	case "memory":
+		cacheBackend = cache.NewMemory(cfg.Cache.MaxEntries)
+	case "redis":
+		rc, err := cache.NewRedis(cfg.Cache.RedisURL, cfg.Cache.KeyPrefix)
+		if err != nil {
+			logging.Fatal("invalid REDIS_URL", "error", err)
+		}
+		cacheBackend = rc
+	}
+	return cache.NewResultCache(cacheBackend, cacheTTLs)
+}
+
+// newIngest builds the ingest service for operator command. Content
+// changes drop the shared (Redis) result cache; webhooks are only sent by
+// the server. Unless switchModel, the index must hold vectors of the
+// embedder's model.
+func newIngest(ctx context.Context, cfg *config.Config, command string, switchModel bool) (*ingest.Service, func()) {
+	pg := openPostgres(ctx, cfg, command)
+	embedder := newEmbedder(cfg)
+	model := embeddingModel(ctx, vector.PrimaryIndex, embedder)
+	unlock := lockStartup(ctx, pg)
+	if !switchModel {
+		checkIndexModel(ctx, pg, model)
+	}
+	vectors, qClient := openVectors(ctx, cfg, pg, model)
+	shadow := openShadow(ctx, cfg, pg, qClient)
+	unlock()
+	svc := ingest.New(ingest.Config{
+		Postgres:    pg,
+		Store:       postgres.NewStore(pg),
+		Vectors:     vectors,
+		Embedder:    embedder,
+		Model:       model,
+		Shadow:      shadow,
+		Notifier:    cliNotifier{newResultCache(cfg)},
+		Concurrency: cfg.Ingest.Concurrency,
+		TokenPrice:  embed.Price(cfg.Embedder.TokenPrice, cfg.Embedder.Provider, cfg.Embedder.Model),
+	})
+	return svc, func() {
+		if qClient != nil {
+			qClient.Close()
+		}
+		pg.Close()
+	}
+}
+
+type cliNotifier struct {
+	cache *cache.ResultCache
+}
+
+func (cliNotifier) CollectionUpdated(context.Context, string, string)        {}
+func (cliNotifier) HadithsCreated(context.Context, string, []ingest.Created) {}
+func (cliNotifier) HadithsUpdated(context.Context, []int64)                  {}
+func (cliNotifier) HadithsDeleted(context.Context, []int64)                  {}
+
+func (n cliNotifier) ContentChanged(ctx context.Context) {
+	n.cache.InvalidateContent(ctx)
+}
@@ -0,0 +1,76 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+)
+
+// A restore replaces the content in place: the bookmarks of hadiths the
+// backup has survive it, their Quran references are those of the backup,
+// and hadiths added after the backup are gone.
+func TestRestoreKeepsBookmarks(t *testing.T) {
+	if status, _ := env.upload(t, "it-backup", []ingest.HadithUploadItem{{Number: "1", TextEn: "Backup test: kept by the restore, see Quran 2:255."}}); status != http.StatusOK {
+		t.Fatalf("upload: status %d", status)
+	}
+	id := env.hadithIDs(t, "it-backup")["1"]
+
+	var auth struct {
+		Token string `json:"token"`
+	}
+	status := env.do(t, http.MethodPost, "/v1/auth/register", map[string]string{"email": "backup@example.com", "password": "backup-password"}, &auth)
+	if status != http.StatusCreated {
+		t.Fatalf("register: status %d", status)
+	}
+	bearer := "Bearer " + auth.Token
+	if status := env.do(t, http.MethodPost, "/v1/me/bookmarks", map[string]int64{"hadith_id": id}, nil, "Authorization", bearer); status != http.StatusCreated {
+		t.Fatalf("bookmark: status %d", status)
+	}
+
+	var backup struct {
+		ID string `json:"id"`
+	}
+	if status := env.do(t, http.MethodPost, "/v1/admin/backups", nil, &backup, "X-API-Key", adminKey); status != http.StatusOK {
+		t.Fatalf("backup: status %d", status)
+	}
+	if _, err := env.postgres.Exec(context.Background(), `DELETE FROM hadith_ayah_refs WHERE hadith_id = $1`, id); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := env.upload(t, "it-backup", []ingest.HadithUploadItem{{Number: "2", TextEn: "Backup test: added after the backup."}}); status != http.StatusOK {
+		t.Fatalf("upload after backup: status %d", status)
+	}
+
+	if status := env.do(t, http.MethodPost, "/v1/admin/backups/"+backup.ID+"/restore", nil, nil, "X-API-Key", adminKey); status != http.StatusOK {
+		t.Fatalf("restore: status %d", status)
+	}
+
+	ids := env.hadithIDs(t, "it-backup")
+	if ids["1"] != id {
+		t.Fatalf("after restore: hadith 1 is %d, want %d", ids["1"], id)
+	}
+	if _, ok := ids["2"]; ok {
+		t.Error("hadith added after the backup survived the restore")
+	}
+	var refs int
+	if err := env.postgres.QueryRow(context.Background(), `SELECT count(*) FROM hadith_ayah_refs WHERE hadith_id = $1 AND surah = 2 AND ayah = 255`, id).Scan(&refs); err != nil {
+		t.Fatal(err)
+	}
+	if refs != 1 {
+		t.Error("after restore: the backup's ayah reference was not restored")
+	}
+	var bookmarks struct {
+		Bookmarks []struct {
+			HadithID int64 `json:"hadith_id"`
+		} `json:"bookmarks"`
+	}
+	if status := env.do(t, http.MethodGet, "/v1/me/bookmarks", nil, &bookmarks, "Authorization", bearer); status != http.StatusOK {
+		t.Fatalf("list bookmarks: status %d", status)
+	}
+	if len(bookmarks.Bookmarks) != 1 || bookmarks.Bookmarks[0].HadithID != id {
+		t.Errorf("after restore: bookmarks %+v, want one of hadith %d", bookmarks.Bookmarks, id)
+	}
+}
@@ -0,0 +1,62 @@
+//go:build integration
+
+package integration
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+)
+
+func TestRandomHadithFilters(t *testing.T) {
+	status, _ := env.upload(t, "it-filters", []ingest.HadithUploadItem{
+		{Number: "1", TextEn: "Filter test: sound narration one.", Grade: "sahih"},
+		{Number: "2", TextEn: "Filter test: sound narration two.", Grade: "sahih"},
+		{Number: "3", TextEn: "Filter test: weak narration.", Grade: "daif"},
+	})
+	if status != http.StatusOK {
+		t.Fatalf("upload: status %d", status)
+	}
+
+	for range 10 {
+		var h struct {
+			CollectionCode string  `json:"collection_code"`
+			Grade          *string `json:"grade"`
+		}
+		if status := env.do(t, http.MethodGet, "/v1/hadiths/random?collection=it-filters&grade=sahih", nil, &h); status != http.StatusOK {
+			t.Fatalf("random: status %d", status)
+		}
+		if h.CollectionCode != "it-filters" || h.Grade == nil || *h.Grade != "sahih" {
+			t.Fatalf("random returned collection %q grade %v, want it-filters sahih", h.CollectionCode, h.Grade)
+		}
+	}
+
+	if status := env.do(t, http.MethodGet, "/v1/hadiths/random?collection=it-filters&grade=hasan", nil, nil); status != http.StatusNotFound {
+		t.Errorf("random with no match: status %d, want 404", status)
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	status := env.do(t, http.MethodPost, "/v1/admin/tenants", map[string]string{"code": "it-acme", "name": "Acme"}, nil, "X-API-Key", adminKey)
+	if status != http.StatusCreated && status != http.StatusOK {
+		t.Fatalf("create tenant: status %d", status)
+	}
+	const text = "Tenant test: a hadith only Acme has."
+	status, _ = env.upload(t, "it-tenant", []ingest.HadithUploadItem{{Number: "1", TextEn: text}}, "X-Tenant", "it-acme")
+	if status != http.StatusOK {
+		t.Fatalf("upload: status %d", status)
+	}
+	id := env.hadithIDs(t, "it-tenant", "X-Tenant", "it-acme")["1"]
+
+	if hits := env.search(t, text, 5, "X-Tenant", "it-acme"); len(hits) == 0 || hits[0] != id {
+		t.Errorf("acme search: hits %v, want hadith %d first", hits, id)
+	}
+	if hits := env.search(t, text, 5); slices.Contains(hits, id) {
+		t.Errorf("default tenant search returned acme's hadith %d", id)
+	}
+	if status := env.do(t, http.MethodGet, "/v1/collections/it-tenant/hadiths", nil, nil); status != http.StatusNotFound {
+		t.Errorf("default tenant listing acme's collection: status %d, want 404", status)
+	}
+}
@@ -0,0 +1,360 @@
+//go:build integration
+
+// Package integration runs the server against real Postgres, Qdrant and
+// MinIO containers and a stub embedder, and exercises it over HTTP:
+//
+//	go test -tags integration ./integration/
+//
+// Docker must be available; the containers are started once for the
+// package and removed when it finishes.
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/cache"
+	"github.com/buugaaga/test-cursor/backend/internal/config"
+	"github.com/buugaaga/test-cursor/backend/internal/embed"
+	"github.com/buugaaga/test-cursor/backend/internal/embed/embedtest"
+	"github.com/buugaaga/test-cursor/backend/internal/httpapi"
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/vector"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/testcontainers/testcontainers-go"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	postgresImage = "postgres:16-alpine"
+	qdrantImage   = "qdrant/qdrant:v1.15.1"
+	minioImage    = "minio/minio:RELEASE.2025-07-23T15-54-02Z"
+	minioKey      = "integration"
+	minioSecret   = "integration-secret"
+	backupBucket  = "backups"
+	vectorSize    = 16
+	adminKey      = "integration-admin-key"
+)
+
+// env is the running server and the backends it uses.
+var env *testEnv
+
+type testEnv struct {
+	baseURL  string
+	postgres *pgxpool.Pool
+	vectors  vector.Index
+	embedder *stubEmbedder
+}
+
+// stubEmbedder embeds like embedtest.Embedder, so equal texts get equal
+// vectors, and fails every call while failing is set.
+type stubEmbedder struct {
+	embedtest.Embedder
+	failing atomic.Bool
+}
+
+func (e *stubEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if e.failing.Load() {
+		return nil, errors.New("stub embedder unavailable")
+	}
+	return e.Embedder.Embed(ctx, texts)
+}
+
+func TestMain(m *testing.M) {
+	code, err := run(m)
+	if err != nil {
+		log.Print(err)
+		code = 1
+	}
+	os.Exit(code)
+}
+
+func run(m *testing.M) (int, error) {
+	ctx := context.Background()
+
+	pgC, err := tcpostgres.Run(ctx, postgresImage,
+		tcpostgres.WithDatabase("islamdb"),
+		tcpostgres.WithUsername("app"),
+		tcpostgres.WithPassword("app"),
+		tcpostgres.BasicWaitStrategies(),
+	)
+	defer testcontainers.TerminateContainer(pgC)
+	if err != nil {
+		return 0, fmt.Errorf("start postgres: %w", err)
+	}
+	dsn, err := pgC.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		return 0, err
+	}
+
+	qC, err := testcontainers.Run(ctx, qdrantImage,
+		testcontainers.WithExposedPorts("6333/tcp", "6334/tcp"),
+		testcontainers.WithWaitStrategy(wait.ForHTTP("/readyz").WithPort("6333/tcp").WithStartupTimeout(time.Minute)),
+	)
+	defer testcontainers.TerminateContainer(qC)
+	if err != nil {
+		return 0, fmt.Errorf("start qdrant: %w", err)
+	}
+	qHost, err := qC.Host(ctx)
+	if err != nil {
+		return 0, err
+	}
+	qPort, err := qC.MappedPort(ctx, "6334/tcp")
+	if err != nil {
+		return 0, err
+	}
+	qHTTPPort, err := qC.MappedPort(ctx, "6333/tcp")
+	if err != nil {
+		return 0, err
+	}
+
+	mC, s3Endpoint, err := startMinIO(ctx)
+	defer testcontainers.TerminateContainer(mC)
+	if err != nil {
+		return 0, fmt.Errorf("start minio: %w", err)
+	}
+
+	httpPort, grpcPort := freePort(), freePort()
+	loaded, err := config.Load(ctx, flag.NewFlagSet("integration", flag.ContinueOnError), []string{
+		"-postgres.dsn", dsn,
+		"-qdrant.host", qHost,
+		"-qdrant.grpc_port", strconv.Itoa(qPort.Int()),
+		"-qdrant.vector_size", strconv.Itoa(vectorSize),
+		"-http.port", httpPort,
+		"-http.grpc_port", grpcPort,
+		"-http.rate_limits", "",
+		"-auth.admin_api_key", adminKey,
+		"-auth.jwt_secret", "integration-jwt-secret",
+		"-cache.backend", "off",
+		"-ingest.outbox_interval", "200ms",
+		"-jobs.poll_interval", "100ms",
+		"-backups.s3_endpoint", s3Endpoint,
+		"-backups.s3_access_key", minioKey,
+		"-backups.s3_secret_key", minioSecret,
+		"-backups.s3_bucket", backupBucket,
+		"-backups.s3_use_ssl", "false",
+		"-backups.pg_dump", "",
+	})
+	if err != nil {
+		return 0, err
+	}
+	cfg := loaded.Config
+
+	pg, err := postgres.Open(ctx, cfg.Postgres.DSN)
+	if err != nil {
+		return 0, err
+	}
+	defer pg.Close()
+	if err := postgres.EnsureSchema(ctx, pg, true); err != nil {
+		return 0, err
+	}
+
+	model := embed.Model{Name: "integration-stub", Dimensions: vectorSize}
+	if err := vector.EnsureModel(ctx, pg, vector.PrimaryIndex, model.Name, model.Dimensions); err != nil {
+		return 0, err
+	}
+	collectionCfg, err := vector.NewCollectionConfig(cfg.Qdrant)
+	if err != nil {
+		return 0, err
+	}
+	qClient, err := vector.Open(cfg.Qdrant.Host, cfg.Qdrant.GRPCPort, false, "")
+	if err != nil {
+		return 0, err
+	}
+	if err := vector.EnsureCollection(ctx, qClient, vector.Collection, collectionCfg); err != nil {
+		return 0, err
+	}
+	if err := vector.EnsurePayloadIndexes(ctx, qClient, vector.Collection); err != nil {
+		return 0, err
+	}
+	vectors := vector.NewIndex(qClient, vector.NewQdrantOptions(cfg.Qdrant))
+	embedder := &stubEmbedder{Embedder: embedtest.Embedder{Dim: vectorSize}}
+
+	go func() {
+		err := httpapi.Run(ctx, httpapi.Config{
+			Settings:       cfg,
+			Postgres:       pg,
+			Qdrant:         qClient,
+			QdrantHTTPURL:  fmt.Sprintf("http://%s:%d", qHost, qHTTPPort.Int()),
+			Store:          postgres.NewStore(pg),
+			Vectors:        vectors,
+			Embedder:       embedder,
+			EmbeddingModel: model,
+			Cache:          cache.NewResultCache(nil, nil),
+		})
+		log.Fatalf("server stopped: %v", err)
+	}()
+
+	env = &testEnv{
+		baseURL:  "http://127.0.0.1:" + httpPort,
+		postgres: pg,
+		vectors:  vectors,
+		embedder: embedder,
+	}
+	if err := env.waitHealthy(30 * time.Second); err != nil {
+		return 0, err
+	}
+	return m.Run(), nil
+}
+
+// startMinIO starts a MinIO container with an empty backup bucket and
+// returns it and its endpoint.
+func startMinIO(ctx context.Context) (testcontainers.Container, string, error) {
+	mC, err := testcontainers.Run(ctx, minioImage,
+		testcontainers.WithCmd("server", "/data"),
+		testcontainers.WithEnv(map[string]string{"MINIO_ROOT_USER": minioKey, "MINIO_ROOT_PASSWORD": minioSecret}),
+		testcontainers.WithExposedPorts("9000/tcp"),
+		testcontainers.WithWaitStrategy(wait.ForHTTP("/minio/health/ready").WithPort("9000/tcp").WithStartupTimeout(time.Minute)),
+	)
+	if err != nil {
+		return mC, "", err
+	}
+	endpoint, err := mC.PortEndpoint(ctx, "9000/tcp", "")
+	if err != nil {
+		return mC, "", err
+	}
+	client, err := minio.New(endpoint, &minio.Options{Creds: credentials.NewStaticV4(minioKey, minioSecret, "")})
+	if err != nil {
+		return mC, "", err
+	}
+	return mC, endpoint, client.MakeBucket(ctx, backupBucket, minio.MakeBucketOptions{})
+}
+
+func freePort() string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer l.Close()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+func (e *testEnv) waitHealthy(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(e.baseURL + "/healthz")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return errors.New("server did not become healthy")
+}
+
+// do sends body as JSON with the given header name/value pairs and decodes
+// a successful response into out. It returns the status code.
+func (e *testEnv) do(t *testing.T, method, path string, body, out any, headers ...string) int {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, e.baseURL+path, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// upload stores hadiths in collection code as an admin and returns the
+// status and response.
+func (e *testEnv) upload(t *testing.T, code string, hadiths []ingest.HadithUploadItem, headers ...string) (int, ingest.UploadResponse) {
+	t.Helper()
+	var resp ingest.UploadResponse
+	status := e.do(t, http.MethodPost, "/v1/admin/hadiths/upload", ingest.HadithUploadRequest{
+		Collection: ingest.HadithUploadCollection{Code: code, Title: "Integration " + code},
+		Hadiths:    hadiths,
+	}, &resp, append([]string{"X-API-Key", adminKey}, headers...)...)
+	return status, resp
+}
+
+// hadithIDs maps the numbers of collection code to hadith ids.
+func (e *testEnv) hadithIDs(t *testing.T, code string, headers ...string) map[string]int64 {
+	t.Helper()
+	var list struct {
+		Hadiths []struct {
+			ID     int64  `json:"id"`
+			Number string `json:"number"`
+		} `json:"hadiths"`
+	}
+	if status := e.do(t, http.MethodGet, "/v1/collections/"+code+"/hadiths?limit=100", nil, &list, headers...); status != http.StatusOK {
+		t.Fatalf("list %s: status %d", code, status)
+	}
+	ids := map[string]int64{}
+	for _, h := range list.Hadiths {
+		ids[h.Number] = h.ID
+	}
+	return ids
+}
+
+type searchHit struct {
+	ID      string         `json:"id"`
+	Score   float32        `json:"score"`
+	Payload map[string]any `json:"payload"`
+}
+
+// search returns the hadith ids of the hits for query, best first.
+func (e *testEnv) search(t *testing.T, query string, limit int, headers ...string) []int64 {
+	t.Helper()
+	var resp struct {
+		Results []searchHit `json:"results"`
+	}
+	if status := e.do(t, http.MethodPost, "/v1/search", map[string]any{"query": query, "limit": limit}, &resp, headers...); status != http.StatusOK {
+		t.Fatalf("search %q: status %d", query, status)
+	}
+	ids := make([]int64, 0, len(resp.Results))
+	for _, h := range resp.Results {
+		id, _ := h.Payload["origin_id"].(float64)
+		ids = append(ids, int64(id))
+	}
+	return ids
+}
+
+// eventually retries cond until it holds or timeout passes.
+func eventually(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within " + timeout.String())
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
@@ -0,0 +1,91 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// An upload made while the embedder is down stores the hadiths and leaves
+// their indexing to the outbox, which catches up once it is back.
+func TestIndexingRecoversAfterEmbedderOutage(t *testing.T) {
+	const text = "Recovery test: indexed after the embedder came back."
+	env.embedder.failing.Store(true)
+	status, resp := env.upload(t, "it-outage", []ingest.HadithUploadItem{{Number: "1", TextEn: text}})
+	env.embedder.failing.Store(false)
+	if status != http.StatusOK {
+		t.Fatalf("upload: status %d", status)
+	}
+	if resp.Embedded != 0 || len(resp.Errors) == 0 {
+		t.Fatalf("upload: embedded %d with errors %+v, want 0 and a failed row", resp.Embedded, resp.Errors)
+	}
+	for _, e := range resp.Errors {
+		if !e.Stored {
+			t.Errorf("upload: hadith %q was not stored: %+v", e.Number, e.Reason)
+		}
+	}
+
+	id, ok := env.hadithIDs(t, "it-outage")["1"]
+	if !ok {
+		t.Fatal("hadith was not stored")
+	}
+	if hits := env.search(t, text, 5); slices.Contains(hits, id) {
+		t.Fatalf("hadith %d was indexed during the outage", id)
+	}
+
+	// Skip the retry backoff instead of waiting it out.
+	if _, err := env.postgres.Exec(context.Background(), `UPDATE index_outbox SET available_at = now()`); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, 15*time.Second, func() bool {
+		hits := env.search(t, text, 5)
+		return len(hits) > 0 && hits[0] == id
+	})
+}
+
+// The index.backfill job finds hadiths whose points were lost and indexes
+// them again.
+func TestBackfillRestoresLostPoints(t *testing.T) {
+	const text = "Recovery test: points lost and backfilled."
+	if status, _ := env.upload(t, "it-backfill", []ingest.HadithUploadItem{{Number: "1", TextEn: text}}); status != http.StatusOK {
+		t.Fatalf("upload: status %d", status)
+	}
+	id := env.hadithIDs(t, "it-backfill")["1"]
+
+	err := env.vectors.Delete(context.Background(), &qdrant.Filter{
+		Must: []*qdrant.Condition{qdrant.NewMatchInt("origin_id", id)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits := env.search(t, text, 5); slices.Contains(hits, id) {
+		t.Fatalf("hadith %d still found after its points were deleted", id)
+	}
+
+	var job struct {
+		ID    int64  `json:"id"`
+		State string `json:"state"`
+	}
+	status := env.do(t, http.MethodPost, "/v1/admin/jobs", map[string]string{"kind": "index.backfill"}, &job, "X-API-Key", adminKey)
+	if status != http.StatusAccepted {
+		t.Fatalf("queue backfill: status %d", status)
+	}
+	eventually(t, 30*time.Second, func() bool {
+		env.do(t, http.MethodGet, "/v1/admin/jobs/"+strconv.FormatInt(job.ID, 10), nil, &job, "X-API-Key", adminKey)
+		if job.State == "failed" || job.State == "cancelled" {
+			t.Fatalf("backfill job %s", job.State)
+		}
+		return job.State == "completed"
+	})
+	if hits := env.search(t, text, 5); len(hits) == 0 || hits[0] != id {
+		t.Errorf("after backfill: hits %v, want hadith %d first", hits, id)
+	}
+}
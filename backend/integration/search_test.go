@@ -0,0 +1,50 @@
+//go:build integration
+
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+)
+
+func TestUploadThenSearch(t *testing.T) {
+	texts := map[string]string{
+		"1": "Actions are judged by intentions.",
+		"2": "None of you truly believes until he loves for his brother what he loves for himself.",
+		"3": "Whoever believes in Allah and the Last Day should speak good or keep silent.",
+	}
+	var items []ingest.HadithUploadItem
+	for number, text := range texts {
+		items = append(items, ingest.HadithUploadItem{Number: number, TextEn: text, Grade: "sahih"})
+	}
+	status, resp := env.upload(t, "it-roundtrip", items)
+	if status != http.StatusOK {
+		t.Fatalf("upload: status %d", status)
+	}
+	if resp.Inserted != 3 || resp.Embedded != 3 {
+		t.Fatalf("upload: inserted %d, embedded %d, want 3 and 3", resp.Inserted, resp.Embedded)
+	}
+
+	ids := env.hadithIDs(t, "it-roundtrip")
+	for number, text := range texts {
+		hits := env.search(t, text, 3)
+		if len(hits) == 0 || hits[0] != ids[number] {
+			t.Errorf("search %q: hits %v, want hadith %d first", text, hits, ids[number])
+		}
+	}
+}
+
+func TestSearchLimit(t *testing.T) {
+	var items []ingest.HadithUploadItem
+	for _, number := range []string{"1", "2", "3", "4", "5"} {
+		items = append(items, ingest.HadithUploadItem{Number: number, TextEn: "Limit test hadith " + number})
+	}
+	if status, _ := env.upload(t, "it-limit", items); status != http.StatusOK {
+		t.Fatalf("upload: status %d", status)
+	}
+	if hits := env.search(t, "Limit test hadith 1", 2); len(hits) != 2 {
+		t.Errorf("limit 2: got %d hits", len(hits))
+	}
+}
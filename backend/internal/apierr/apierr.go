@@ -0,0 +1,120 @@
+// Package apierr defines the errors returned to API clients. Services return
+// them so that the HTTP, gRPC and MCP front ends can render them as is.
+package apierr
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/buugaaga/test-cursor/backend/internal/i18n"
+)
+
+// ErrorCode is the machine-readable part of an error response. Clients should
+// branch on it rather than on the message.
+type ErrorCode string
+
+const (
+	CodeInvalidRequest    ErrorCode = "invalid_request"
+	CodeInvalidArgument   ErrorCode = "invalid_argument"
+	CodeUnauthorized      ErrorCode = "unauthorized"
+	CodeForbidden         ErrorCode = "forbidden"
+	CodeNotFound          ErrorCode = "not_found"
+	CodeAlreadyExists     ErrorCode = "already_exists"
+	CodeConflict          ErrorCode = "conflict"
+	CodeMethodNotAllowed  ErrorCode = "method_not_allowed"
+	CodeRateLimited       ErrorCode = "rate_limited"
+	CodePayloadTooLarge   ErrorCode = "payload_too_large"
+	CodeNotAcceptable     ErrorCode = "not_acceptable"
+	CodeDatabaseFailed    ErrorCode = "database_failed"
+	CodeEmbedderFailed    ErrorCode = "embedder_failed"
+	CodeVectorStoreFailed ErrorCode = "vector_store_failed"
+	CodeStorageFailed     ErrorCode = "storage_failed"
+	CodeNotConfigured     ErrorCode = "not_configured"
+	CodeOverloaded        ErrorCode = "overloaded"
+	CodeMaintenance       ErrorCode = "maintenance"
+	CodeInternal          ErrorCode = "internal"
+)
+
+// APIError is returned by handlers and rendered by the HTTP error handler as
+// {"code": ..., "message": ..., "details": ...}.
+type APIError struct {
+	Status  int       `json:"-"`
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	Details any       `json:"details,omitempty"`
+	// RetryAfter, in seconds, is sent as the Retry-After header when set.
+	RetryAfter int `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+func (e *APIError) WithDetails(details any) *APIError {
+	cp := *e
+	cp.Details = details
+	return &cp
+}
+
+func (e *APIError) WithRetryAfter(seconds int) *APIError {
+	cp := *e
+	cp.RetryAfter = seconds
+	return &cp
+}
+
+// Localize returns e with its message in lang. Messages missing from the
+// catalog stay in English, except that server-side failures fall back to a
+// translation of their code.
+func (e *APIError) Localize(lang string) *APIError {
+	if lang == i18n.Default {
+		return e
+	}
+	if d, ok := e.Details.(map[string]any); ok {
+		if fields, ok := d["fields"].([]fieldError); ok && len(fields) > 0 {
+			return validationError(lang, slices.Clone(fields)).WithRetryAfter(e.RetryAfter)
+		}
+	}
+	cp := *e
+	if msg, ok := i18n.Lookup(lang, e.Message); ok {
+		cp.Message = msg
+	} else if msg, ok := i18n.Lookup(lang, "error."+string(e.Code)); ok && e.Status >= http.StatusInternalServerError {
+		cp.Message = msg
+	}
+	return &cp
+}
+
+func New(status int, code ErrorCode, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+func InvalidRequest() *APIError {
+	return New(http.StatusBadRequest, CodeInvalidRequest, "malformed request body")
+}
+
+func InvalidArgument(message string) *APIError {
+	return New(http.StatusBadRequest, CodeInvalidArgument, message)
+}
+
+func NotFound(message string) *APIError {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Unauthorized(message string) *APIError {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *APIError {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+func Database(message string) *APIError {
+	return New(http.StatusInternalServerError, CodeDatabaseFailed, message)
+}
+
+func Embedder(message string) *APIError {
+	return New(http.StatusBadGateway, CodeEmbedderFailed, message)
+}
+
+func VectorStore(message string) *APIError {
+	return New(http.StatusBadGateway, CodeVectorStoreFailed, message)
+}
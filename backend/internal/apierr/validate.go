@@ -0,0 +1,108 @@
+package apierr
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/i18n"
+	"github.com/go-playground/validator/v10"
+)
+
+// Request structs declare their constraints in `validate` tags; failures
+// become a 400 invalid_argument whose details list every offending field by
+// its JSON path, e.g. {"field": "hadiths[3].number", "rule": "required"}.
+
+type fieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	// param and kind are kept to render Message in other languages.
+	param string
+	kind  reflect.Kind
+}
+
+var validate = func() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}()
+
+// Validate checks the `validate` tags of v; it is shared by the HTTP, gRPC
+// and MCP front ends.
+func Validate(v any) error {
+	err := validate.Struct(v)
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err
+	}
+	fields := make([]fieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, fieldError{Field: fieldPath(fe.Namespace()), Rule: fe.Tag(), param: fe.Param(), kind: fe.Kind()})
+	}
+	return validationError(i18n.Default, fields)
+}
+
+// validationError renders fields in lang.
+func validationError(lang string, fields []fieldError) *APIError {
+	for i := range fields {
+		fields[i].Message = fields[i].message(lang)
+	}
+	msg := fields[0].Field + " " + fields[0].Message
+	if len(fields) > 1 {
+		msg = i18n.T(lang, "validation.more", msg, len(fields)-1)
+	}
+	return InvalidArgument(msg).WithDetails(map[string]any{"fields": fields})
+}
+
+// fieldPath drops the root struct name from "HadithUploadRequest.hadiths[3].number".
+func fieldPath(ns string) string {
+	_, path, ok := strings.Cut(ns, ".")
+	if !ok {
+		return ns
+	}
+	return path
+}
+
+func (f fieldError) message(lang string) string {
+	kind := ""
+	switch f.kind {
+	case reflect.Slice, reflect.Map:
+		kind = ".list"
+	case reflect.String:
+		kind = ".string"
+	}
+	switch f.Rule {
+	case "required", "email", "http_url":
+		return i18n.T(lang, "validation."+f.Rule)
+	case "min", "max":
+		return i18n.T(lang, "validation."+f.Rule+kind, f.param)
+	case "gte":
+		return i18n.T(lang, "validation.min", f.param)
+	case "lte":
+		return i18n.T(lang, "validation.max", f.param)
+	case "oneof":
+		return i18n.T(lang, "validation.oneof", strings.ReplaceAll(f.param, " ", ", "))
+	case "datetime":
+		return i18n.T(lang, "validation.datetime", f.param)
+	default:
+		return i18n.T(lang, "validation.failed", f.Rule)
+	}
+}
+
+// InvalidField is the error Validate returns for one field failing rule,
+// e.g. InvalidField("limit", "lte", "50") for a limit above 50.
+func InvalidField(field, rule, param string) *APIError {
+	return validationError(i18n.Default, []fieldError{{Field: field, Rule: rule, param: param}})
+}
+
+func PayloadTooLarge() *APIError {
+	return New(http.StatusRequestEntityTooLarge, CodePayloadTooLarge, "request body too large")
+}
@@ -0,0 +1,223 @@
+// Package cache caches results of hot read paths in memory or in Redis.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/metrics"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache stores encoded results of hot read paths. Failures are logged and
+// treated as misses so a cache outage only costs latency.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+	// Invalidate drops every entry whose key starts with prefix.
+	Invalidate(ctx context.Context, prefix string)
+}
+
+// Everything derived from hadiths and collections lives under contentPrefix
+// and is dropped by InvalidateContent.
+const contentPrefix = "content:"
+
+// ParseTTLs parses CACHE_TTLS, e.g. "daily=1h,collections=5m,search=10m".
+// Routes left out are not cached.
+func ParseTTLs(s string) (map[string]time.Duration, error) {
+	ttls := map[string]time.Duration{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		route, spec, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q: want route=ttl", part)
+		}
+		d, err := time.ParseDuration(spec)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%q: invalid ttl", part)
+		}
+		ttls[strings.TrimSpace(route)] = d
+	}
+	return ttls, nil
+}
+
+// ResultCache is the Cache plus per-route TTLs as used by handlers.
+type ResultCache struct {
+	backend Cache
+	ttls    map[string]time.Duration
+}
+
+// NewResultCache returns a cache over backend; a nil backend caches nothing.
+func NewResultCache(backend Cache, ttls map[string]time.Duration) *ResultCache {
+	return &ResultCache{backend: backend, ttls: ttls}
+}
+
+func (rc *ResultCache) Backend() Cache {
+	return rc.backend
+}
+
+// Cached returns the value stored for route and key, or calls load and stores
+// its result for the route's TTL. Entries are kept per tenant of ctx.
+func Cached[T any](ctx context.Context, rc *ResultCache, route, key string, load func() (T, error)) (T, error) {
+	ttl := rc.ttls[route]
+	if rc.backend == nil || ttl <= 0 {
+		return load()
+	}
+	fullKey := contentPrefix + route + ":" + strconv.FormatInt(tenant.From(ctx), 10) + ":" + key
+	if b, ok := rc.backend.Get(ctx, fullKey); ok {
+		var v T
+		if err := json.Unmarshal(b, &v); err == nil {
+			metrics.CacheRequests.WithLabelValues(route, "hit").Inc()
+			return v, nil
+		}
+	}
+	metrics.CacheRequests.WithLabelValues(route, "miss").Inc()
+	v, err := load()
+	if err != nil {
+		return v, err
+	}
+	if b, err := json.Marshal(v); err == nil {
+		rc.backend.Set(ctx, fullKey, b, ttl)
+	}
+	return v, nil
+}
+
+// InvalidateContent drops everything derived from hadiths and collections.
+func (rc *ResultCache) InvalidateContent(ctx context.Context) {
+	if rc.backend != nil {
+		rc.backend.Invalidate(ctx, contentPrefix)
+	}
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// Memory is a per-process cache bounded to maxEntries.
+type Memory struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+func NewMemory(maxEntries int) *Memory {
+	return &Memory{maxEntries: maxEntries, entries: map[string]memoryEntry{}}
+}
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(m.entries, key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if _, ok := m.entries[key]; !ok && len(m.entries) >= m.maxEntries {
+		for k, e := range m.entries {
+			if now.After(e.expires) {
+				delete(m.entries, k)
+			}
+		}
+		// Still full: evict arbitrary entries (map order is random).
+		for k := range m.entries {
+			if len(m.entries) < m.maxEntries {
+				break
+			}
+			delete(m.entries, k)
+		}
+	}
+	m.entries[key] = memoryEntry{value: value, expires: now.Add(ttl)}
+}
+
+func (m *Memory) Invalidate(_ context.Context, prefix string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k := range m.entries {
+		if strings.HasPrefix(k, prefix) {
+			delete(m.entries, k)
+		}
+	}
+}
+
+// Redis shares cached results between replicas. Keys are namespaced by
+// prefix so the Redis database can be shared with other applications.
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedis(url, prefix string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &Redis{client: redis.NewClient(opts), prefix: prefix}, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool) {
+	b, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			slog.WarnContext(ctx, "cache: redis get failed", "error", err)
+		}
+		return nil, false
+	}
+	return b, true
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	if err := r.client.Set(ctx, r.prefix+key, value, ttl).Err(); err != nil {
+		slog.WarnContext(ctx, "cache: redis set failed", "error", err)
+	}
+}
+
+func (r *Redis) Invalidate(ctx context.Context, prefix string) {
+	iter := r.client.Scan(ctx, 0, r.prefix+prefix+"*", 500).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == 500 {
+			r.unlink(ctx, keys)
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		slog.ErrorContext(ctx, "cache: redis scan failed", "error", err)
+	}
+	r.unlink(ctx, keys)
+}
+
+func (r *Redis) unlink(ctx context.Context, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	if err := r.client.Unlink(ctx, keys...).Err(); err != nil {
+		slog.ErrorContext(ctx, "cache: redis unlink failed", "error", err)
+	}
+}
+
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
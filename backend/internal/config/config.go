@@ -0,0 +1,28 @@
+// Package config loads the server settings from a config file, the
+// environment and flags.
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// Getenv returns the variable key, or fallback when it is unset or empty.
+func Getenv(key string, fallback string) string {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	return v
+}
+
+// SplitList splits a comma-separated list, dropping empty items.
+func SplitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
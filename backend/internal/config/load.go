@@ -0,0 +1,330 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buugaaga/test-cursor/backend/internal/hijri"
+	"github.com/buugaaga/test-cursor/backend/internal/logging"
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+// Sources a setting's value can come from, lowest precedence first.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Setting is one leaf of Config with where its effective value came from.
+type Setting struct {
+	// Key is the dotted file key and flag name, e.g. "qdrant.host".
+	Key    string
+	Env    string
+	Secret bool
+	// Live settings take effect on reload; the others need a restart.
+	Live   bool
+	Value  string
+	Source string
+
+	field reflect.Value
+	def   string
+}
+
+// Loaded is the result of Load.
+type Loaded struct {
+	Config   *Config
+	Settings []*Setting
+	// File is the config file read, if any.
+	File string
+
+	// flags are the values set on the command line, kept for Reload.
+	flags map[string]string
+}
+
+// Load builds the configuration, each source overriding the previous one:
+//
+//   - the defaults in the struct tags of Config
+//   - the YAML (.yaml, .yml) or TOML (.toml) file named by -config or
+//     CONFIG_FILE
+//   - the environment; for secret settings also K_FILE and Vault, see Secrets
+//   - flags in args, registered on fs next to the caller's own
+//
+// Every setting is parsed and validated; the error lists all invalid ones.
+func Load(ctx context.Context, fs *flag.FlagSet, args []string) (*Loaded, error) {
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file (env CONFIG_FILE)")
+	flags := map[string]*string{}
+	for _, s := range collect(reflect.ValueOf(&Config{}).Elem(), "") {
+		flags[s.Key] = fs.String(s.Key, "", "env "+s.Env)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	set := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		if p, ok := flags[f.Name]; ok {
+			set[f.Name] = *p
+		}
+	})
+	return load(ctx, *file, set)
+}
+
+// load builds the configuration from the defaults, file, environment and
+// the flag values set.
+func load(ctx context.Context, file string, set map[string]string) (*Loaded, error) {
+	cfg := &Config{}
+	settings := collect(reflect.ValueOf(cfg).Elem(), "")
+	byKey := map[string]*Setting{}
+	for _, s := range settings {
+		byKey[s.Key] = s
+	}
+
+	for _, s := range settings {
+		s.Value, s.Source = s.def, SourceDefault
+	}
+	if file != "" {
+		values, err := readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range values {
+			s, ok := byKey[k]
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown setting %q", file, k)
+			}
+			s.Value, s.Source = v, SourceFile
+		}
+	}
+
+	secrets, err := LoadSecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range settings {
+		v := os.Getenv(s.Env)
+		if s.Secret {
+			if v, err = envOrFile(s.Env); err != nil {
+				return nil, err
+			}
+			if v == "" {
+				v = secrets.vault[s.Env]
+			}
+		}
+		if v != "" {
+			s.Value, s.Source = v, SourceEnv
+		}
+	}
+	for k, v := range set {
+		byKey[k].Value, byKey[k].Source = v, SourceFlag
+	}
+
+	var errs []error
+	for _, s := range settings {
+		if err := setValue(s.field, s.Value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s (%s) from %s: %w", s.Key, s.Env, s.Source, err))
+		}
+	}
+	if len(errs) == 0 {
+		errs = append(errs, validate(cfg, byKey)...)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &Loaded{Config: cfg, Settings: settings, File: file, flags: set}, nil
+}
+
+// collect walks the sections of Config and returns a Setting per field.
+func collect(v reflect.Value, prefix string) []*Setting {
+	var out []*Setting
+	t := v.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		key := f.Tag.Get("key")
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if f.Type.Kind() == reflect.Struct {
+			out = append(out, collect(v.Field(i), key)...)
+			continue
+		}
+		out = append(out, &Setting{
+			Key:    key,
+			Env:    f.Tag.Get("env"),
+			Secret: f.Tag.Get("secret") == "true",
+			Live:   f.Tag.Get("reload") == "true",
+			field:  v.Field(i),
+			def:    f.Tag.Get("default"),
+		})
+	}
+	return out
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setValue parses s into the field. Lists are comma-separated; an empty
+// value leaves optional (pointer) fields nil.
+func setValue(field reflect.Value, s string) error {
+	if field.Kind() == reflect.Pointer {
+		if s == "" {
+			field.SetZero()
+			return nil
+		}
+		p := reflect.New(field.Type().Elem())
+		if err := setValue(p.Elem(), s); err != nil {
+			return err
+		}
+		field.Set(p)
+		return nil
+	}
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("want true or false")
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("want an integer")
+		}
+		field.SetInt(int64(n))
+	case reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("want a non-negative integer")
+		}
+		field.SetUint(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("want a number")
+		}
+		field.SetFloat(n)
+	case reflect.Slice:
+		field.Set(reflect.ValueOf(SplitList(s)))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// readFile flattens a config file into dotted keys with string values.
+func readFile(name string) (map[string]string, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(name)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &doc)
+	case ".toml":
+		err = toml.Unmarshal(b, &doc)
+	default:
+		return nil, fmt.Errorf("%s: unknown config format %q, want .yaml, .yml or .toml", name, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	out := map[string]string{}
+	if err := flatten(doc, "", out); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return out, nil
+}
+
+func flatten(m map[string]any, prefix string, out map[string]string) error {
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch v := v.(type) {
+		case map[string]any:
+			if err := flatten(v, key, out); err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			out[key] = strings.Join(items, ",")
+		case nil:
+			out[key] = ""
+		case map[any]any, []map[string]any:
+			return fmt.Errorf("%s: unsupported value", key)
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
+
+// validate checks the validate tags and the settings tags cannot express.
+func validate(cfg *Config, byKey map[string]*Setting) []error {
+	var errs []error
+	fail := func(key, msg string) {
+		s := byKey[key]
+		errs = append(errs, fmt.Errorf("invalid %s (%s) from %s: %s", key, s.Env, s.Source, msg))
+	}
+
+	if err := validator.New().Struct(cfg); err != nil {
+		var verrs validator.ValidationErrors
+		if !errors.As(err, &verrs) {
+			return []error{err}
+		}
+		for _, fe := range verrs {
+			fail(settingKey(fe.StructNamespace()), fmt.Sprintf("fails %s=%s", fe.Tag(), fe.Param()))
+		}
+	}
+	if _, ok := logging.Levels[strings.ToLower(cfg.Log.Level)]; !ok {
+		fail("log.level", "want debug, info, warn, error or off")
+	}
+	if _, err := time.LoadLocation(cfg.Daily.Timezone); err != nil {
+		fail("daily.timezone", err.Error())
+	}
+	if _, err := hijri.ParseSeasons(cfg.Daily.HijriTopics); err != nil {
+		fail("daily.hijri_topics", err.Error())
+	}
+	return errs
+}
+
+// settingKey maps a validator namespace such as "Config.Qdrant.GRPCPort" to
+// the setting key "qdrant.grpc_port".
+func settingKey(namespace string) string {
+	parts := strings.Split(namespace, ".")[1:]
+	t := reflect.TypeOf(Config{})
+	keys := make([]string, 0, len(parts))
+	for _, p := range parts {
+		f, ok := t.FieldByName(p)
+		if !ok {
+			break
+		}
+		keys = append(keys, f.Tag.Get("key"))
+		t = f.Type
+	}
+	return strings.Join(keys, ".")
+}
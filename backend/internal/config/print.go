@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "********"
+
+// Print writes the effective configuration with secrets redacted, as
+// nested YAML ("yaml") or as environment variables annotated with the
+// source of each value ("env").
+func (l *Loaded) Print(w io.Writer, format string) error {
+	switch format {
+	case "yaml":
+		root := &yaml.Node{Kind: yaml.MappingNode}
+		sections := map[string]*yaml.Node{}
+		for _, s := range l.Settings {
+			section, name, _ := strings.Cut(s.Key, ".")
+			m, ok := sections[section]
+			if !ok {
+				m = &yaml.Node{Kind: yaml.MappingNode}
+				sections[section] = m
+				root.Content = append(root.Content, scalar(section), m)
+			}
+			m.Content = append(m.Content, scalar(name), s.node())
+		}
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(root); err != nil {
+			return err
+		}
+		return enc.Close()
+	case "env":
+		for _, s := range l.Settings {
+			if _, err := fmt.Fprintf(w, "%s=%s # %s\n", s.Env, s.display(), s.Source); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q, want yaml or env", format)
+	}
+}
+
+func (s *Setting) display() string {
+	if s.Secret && s.Value != "" {
+		return redacted
+	}
+	return s.Value
+}
+
+// node renders numbers and booleans as plain scalars and everything else as
+// a string.
+func (s *Setting) node() *yaml.Node {
+	t := s.field.Type()
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Uint64, reflect.Float64:
+		if s.Value != "" {
+			return &yaml.Node{Kind: yaml.ScalarNode, Value: s.Value}
+		}
+	}
+	return scalar(s.display())
+}
+
+func scalar(v string) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+	if v == "" {
+		n.Style = yaml.DoubleQuotedStyle
+	}
+	return n
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Change is a setting whose value differs between two loads. Old and New
+// are redacted for secrets.
+type Change struct {
+	Key    string
+	Env    string
+	Live   bool
+	Old    string
+	New    string
+	Source string
+}
+
+// Reload loads the configuration again from the file, environment and
+// secrets it was loaded from; flags keep the values they were given.
+func (l *Loaded) Reload(ctx context.Context) (*Loaded, error) {
+	return load(ctx, l.File, l.flags)
+}
+
+// Changes lists the settings whose value differs in next.
+func (l *Loaded) Changes(next *Loaded) []Change {
+	var out []Change
+	for i, s := range l.Settings {
+		n := next.Settings[i]
+		if s.Value == n.Value {
+			continue
+		}
+		out = append(out, Change{Key: s.Key, Env: s.Env, Live: s.Live, Old: s.display(), New: n.display(), Source: n.Source})
+	}
+	return out
+}
+
+// Watch reloads the configuration when the config file changes, checked
+// every interval, or when the process receives SIGHUP, and calls apply
+// with each load that changed any setting. A load that fails, or that apply
+// rejects, is logged and the previous configuration stays in effect. Watch
+// returns when ctx is done.
+func (l *Loaded) Watch(ctx context.Context, interval time.Duration, apply func(next *Loaded, changes []Change) error) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	current, stamp := l, fileStamp(l.File)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("config: reload requested")
+		case <-t.C:
+			s := fileStamp(l.File)
+			if s == stamp {
+				continue
+			}
+			stamp = s
+			slog.Info("config: file changed", "path", l.File)
+		}
+		next, err := current.Reload(ctx)
+		if err != nil {
+			slog.Error("config: reload failed, keeping the current configuration", "error", err)
+			continue
+		}
+		changes := current.Changes(next)
+		if len(changes) == 0 {
+			continue
+		}
+		if err := apply(next, changes); err != nil {
+			slog.Error("config: reload rejected, keeping the current configuration", "error", err)
+			continue
+		}
+		current = next
+	}
+}
+
+// fileStamp identifies a version of the file by its size and modification
+// time; it is empty when there is no file.
+func fileStamp(name string) string {
+	if name == "" {
+		return ""
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d/%s", fi.Size(), fi.ModTime())
+}
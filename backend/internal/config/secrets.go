@@ -0,0 +1,91 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Secrets resolves sensitive settings. For a setting K the value is taken
+// from the first of:
+//
+//   - the K environment variable
+//   - the file named by K_FILE (Docker/Kubernetes secrets), trailing newline trimmed
+//   - field K of the Vault secret at VAULT_SECRET_PATH, when VAULT_ADDR is set
+type Secrets struct {
+	vault map[string]string
+}
+
+// LoadSecrets reads the Vault secret once at startup. It authenticates with
+// VAULT_TOKEN (or VAULT_TOKEN_FILE) and understands both KV v1 and KV v2
+// responses; for KV v2 the path includes "data/", e.g. secret/data/islam-app.
+func LoadSecrets(ctx context.Context) (*Secrets, error) {
+	s := &Secrets{vault: map[string]string{}}
+	addr := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return s, nil
+	}
+	token, err := envOrFile("VAULT_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	path := strings.Trim(Getenv("VAULT_SECRET_PATH", "secret/data/islam-app"), "/")
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr+"/v1/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault: read %s: status %d", path, resp.StatusCode)
+	}
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("vault: decode %s: %w", path, err)
+	}
+	data := body.Data
+	if inner, ok := data["data"].(map[string]any); ok && data["metadata"] != nil {
+		data = inner
+	}
+	for k, v := range data {
+		if str, ok := v.(string); ok {
+			s.vault[k] = str
+		} else {
+			s.vault[k] = fmt.Sprint(v)
+		}
+	}
+	slog.Info("loaded secrets from vault", "count", len(s.vault), "path", path)
+	return s, nil
+}
+
+func envOrFile(key string) (string, error) {
+	if v := os.Getenv(key); v != "" {
+		return v, nil
+	}
+	name := os.Getenv(key + "_FILE")
+	if name == "" {
+		return "", nil
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("read %s_FILE: %w", key, err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
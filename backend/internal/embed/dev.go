@@ -0,0 +1,54 @@
+package embed
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+)
+
+// DevModel is the model the development embedder reports.
+const DevModel = "dev-hash"
+
+// DevHandler serves the bundled embedder's API (POST /embed and GET
+// /healthz) with HashVector vectors of dim, so the stack runs without the
+// embedding service. Similar texts do not get similar vectors; only equal
+// ones match.
+func DevHandler(dim int) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /embed", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Texts []string `json:"texts"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+		out := make([][]float32, 0, len(req.Texts))
+		for _, t := range req.Texts {
+			out = append(out, HashVector(t, dim))
+		}
+		writeJSON(w, map[string]any{"embeddings": out})
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"status": "ok", "model": DevModel, "dimensions": dim})
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// HashVector derives a deterministic vector of dim from text.
+func HashVector(text string, dim int) []float32 {
+	h := fnv.New64a()
+	h.Write([]byte(text))
+	seed := h.Sum64()
+	vec := make([]float32, dim)
+	for i := range vec {
+		seed = seed*6364136223846793005 + 1442695040888963407
+		vec[i] = float32(seed>>40)/float32(1<<24) - 0.5
+	}
+	return vec
+}
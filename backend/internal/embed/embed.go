@@ -0,0 +1,509 @@
+// Package embed is the client of the embedding service: the bundled one or
+// an OpenAI-compatible, Cohere or Ollama API.
+package embed
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/metrics"
+)
+
+type Config struct {
+	// Provider is the API spoken at URL: http (the bundled service), openai,
+	// cohere or ollama. URL defaults to the provider's usual address.
+	Provider string
+	URL      string
+	// Model is required by all providers but http.
+	Model string
+	// Dimensions asks OpenAI models that support it for vectors of this
+	// size; 0 keeps the model's.
+	Dimensions int
+	// MaxBatch caps the texts per request below the provider's own limit;
+	// 0 means the provider's limit.
+	MaxBatch int
+	// MaxBatchChars caps the characters per request, summed over its texts;
+	// 0 means the provider's default. A single longer text is sent alone.
+	MaxBatchChars int
+	// APIKey is sent as "Authorization: Bearer <key>", or verbatim in
+	// APIKeyHeader when that is set.
+	APIKey       string
+	APIKeyHeader string
+	// CAFile verifies the embedder's certificate; CertFile and KeyFile are the
+	// client certificate presented for mutual TLS.
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+
+	// Timeout bounds each attempt. Failed attempts (connection errors, 429
+	// and 5xx) are retried up to MaxRetries times, waiting RetryBackoff,
+	// then twice as long, and so on.
+	Timeout      time.Duration
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// After BreakerThreshold consecutive failures calls fail fast for
+	// BreakerCooldown; 0 disables the breaker.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// MaxConcurrency caps in-flight calls across all callers; 0 means no cap.
+	MaxConcurrency int
+	// Meter, when set, is told about every Embed call, e.g. for per-client
+	// usage accounting.
+	Meter func(ctx context.Context, texts []string)
+}
+
+const maxEmbedderBackoff = 10 * time.Second
+
+// batchCharsRecovery is how long a lowered character budget holds before
+// it doubles back toward the configured one.
+const batchCharsRecovery = time.Minute
+
+// DefaultBatchChars is the character budget BatchChars reports for
+// embedders other than Client.
+const DefaultBatchChars = 200_000
+
+// ErrCircuitOpen is returned without calling the service while the circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("embedder circuit open")
+
+// Embedder turns texts into vectors. Client is the production
+// implementation; embedtest.Embedder is a fake for tests.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Ping(ctx context.Context) error
+}
+
+// Client calls the configured embedding API.
+type Client struct {
+	provider     provider
+	providerName string
+	model        string
+	maxBatch     int
+	// batchChars is the character budget of a request. It starts at
+	// maxBatchChars, the configured one, is lowered when the embedder
+	// rejects a request as too large, and grows back after loweredAt.
+	batchChars    atomic.Int64
+	maxBatchChars int64
+	loweredAt     atomic.Int64
+
+	apiKey       string
+	apiKeyHeader string
+	client       *http.Client
+	timeout      time.Duration
+	maxRetries   int
+	retryBackoff time.Duration
+	breaker      *circuitBreaker
+	slots        chan struct{}
+	meter        func(ctx context.Context, texts []string)
+}
+
+func New(cfg Config) (*Client, error) {
+	p, err := newProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := clientTLSConfig(cfg.CAFile, cfg.CertFile, cfg.KeyFile, cfg.ServerName)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.MaxIdleConnsPerHost = 32
+	e := &Client{
+		provider:     p,
+		providerName: or(cfg.Provider, "http"),
+		model:        cfg.Model,
+		maxBatch:     p.maxBatch(),
+		apiKey:       cfg.APIKey,
+		apiKeyHeader: cfg.APIKeyHeader,
+		client:       &http.Client{Transport: transport},
+		timeout:      cfg.Timeout,
+		maxRetries:   cfg.MaxRetries,
+		retryBackoff: cfg.RetryBackoff,
+		breaker:      &circuitBreaker{threshold: cfg.BreakerThreshold, cooldown: cfg.BreakerCooldown},
+		meter:        cfg.Meter,
+	}
+	if cfg.MaxBatch > 0 {
+		e.maxBatch = min(e.maxBatch, cfg.MaxBatch)
+	}
+	e.maxBatchChars = int64(cmp.Or(cfg.MaxBatchChars, p.maxBatchChars()))
+	e.batchChars.Store(e.maxBatchChars)
+	if cfg.MaxConcurrency > 0 {
+		e.slots = make(chan struct{}, cfg.MaxConcurrency)
+	}
+	return e, nil
+}
+
+type queryKey struct{}
+
+// AsQuery marks texts embedded with ctx as search queries rather than
+// documents, for providers whose models embed them differently.
+func AsQuery(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryKey{}, true)
+}
+
+func isQuery(ctx context.Context) bool {
+	q, _ := ctx.Value(queryKey{}).(bool)
+	return q
+}
+
+// clientTLSConfig returns nil when nothing is configured, leaving the
+// transport's defaults in place.
+func clientTLSConfig(caFile, certFile, keyFile, serverName string) (*tls.Config, error) {
+	if caFile == "" && certFile == "" && keyFile == "" && serverName == "" {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: serverName}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", caFile)
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, errors.New("client certificate needs both cert and key files")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// Ping checks that the embedding API is reachable and accepts the
+// credentials.
+func (e *Client) Ping(ctx context.Context) error {
+	req, err := e.provider.pingRequest(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := e.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("embedder status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Model identifies the embedding model and the size of its vectors. Name
+// is prefixed with the provider, as in "openai:text-embedding-3-small".
+type Model struct {
+	Name       string `json:"name"`
+	Dimensions int    `json:"dimensions"`
+}
+
+// Model asks the provider which model it serves and how long its vectors
+// are, embedding a probe text when the provider cannot tell the size.
+func (e *Client) Model(ctx context.Context) (Model, error) {
+	name := e.model
+	var dims int
+	if d, ok := e.provider.(describer); ok {
+		n, size, err := d.describe(ctx, e.do)
+		if err != nil {
+			return Model{}, err
+		}
+		name, dims = or(n, name), size
+	}
+	if name == "" {
+		return Model{}, errors.New("embedder did not report its model; set EMBEDDER_MODEL")
+	}
+	if dims == 0 {
+		vecs, err := e.Embed(ctx, []string{"dimension probe"})
+		if err != nil {
+			return Model{}, err
+		}
+		dims = len(vecs[0])
+	}
+	return Model{Name: e.providerName + ":" + name, Dimensions: dims}, nil
+}
+
+// Embed embeds texts in requests of at most the provider's batch size and
+// the character budget.
+func (e *Client) Embed(ctx context.Context, texts []string) (embeddings [][]float32, err error) {
+	if e.meter != nil {
+		e.meter(ctx, texts)
+	}
+	defer func(start time.Time) { metrics.ObserveEmbedder(ctx, start, err, texts) }(time.Now())
+	embeddings = make([][]float32, 0, len(texts))
+	for len(texts) > 0 {
+		n := e.batchLen(texts)
+		vecs, err := e.embedSplit(ctx, texts[:n])
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, vecs...)
+		texts = texts[n:]
+	}
+	return embeddings, nil
+}
+
+// BatchChars is the configured character budget of e's requests, or
+// DefaultBatchChars when e is not a Client. Callers batching texts for e
+// use it, and Client splits a batch further while its budget is lowered.
+func BatchChars(e Embedder) int {
+	if c, ok := e.(*Client); ok {
+		return int(c.maxBatchChars)
+	}
+	return DefaultBatchChars
+}
+
+// budget is the character budget of the next request. A budget lowered
+// after a 413 doubles every batchCharsRecovery without another rejection,
+// up to the configured one, so a single oversized batch does not shrink
+// requests for good.
+func (e *Client) budget() int {
+	budget := e.batchChars.Load()
+	lowered := e.loweredAt.Load()
+	if budget < e.maxBatchChars && time.Since(time.Unix(0, lowered)) >= batchCharsRecovery &&
+		e.loweredAt.CompareAndSwap(lowered, time.Now().UnixNano()) {
+		e.batchChars.CompareAndSwap(budget, min(budget*2, e.maxBatchChars))
+	}
+	return int(e.batchChars.Load())
+}
+
+// batchLen is how many of texts, at least one, fit in a request.
+func (e *Client) batchLen(texts []string) int {
+	budget := e.budget()
+	chars := 0
+	for i, t := range texts {
+		chars += utf8.RuneCountInString(t)
+		if i == e.maxBatch || i > 0 && chars > budget {
+			return i
+		}
+	}
+	return len(texts)
+}
+
+// embedSplit embeds texts, halving the batch while the embedder rejects it
+// as too large (413). The character budget is lowered to what was last
+// rejected, halved, so that later batches are sent small enough until it
+// recovers.
+func (e *Client) embedSplit(ctx context.Context, texts []string) ([][]float32, error) {
+	vecs, err := e.embedBatch(ctx, texts)
+	var status embedderStatusError
+	if err == nil || len(texts) < 2 || !errors.As(err, &status) || status != http.StatusRequestEntityTooLarge {
+		return vecs, err
+	}
+	chars := 0
+	for _, t := range texts {
+		chars += utf8.RuneCountInString(t)
+	}
+	for {
+		budget := e.batchChars.Load()
+		if int64(chars/2) >= budget {
+			break
+		}
+		// The time goes first, so that budget does not raise it at once.
+		e.loweredAt.Store(time.Now().UnixNano())
+		if e.batchChars.CompareAndSwap(budget, int64(max(chars/2, 1))) {
+			break
+		}
+	}
+	slog.Warn("embedder: batch too large, splitting", "texts", len(texts), "chars", chars, "budget", e.batchChars.Load())
+	metrics.EmbedderBatchSplits.Inc()
+	half := len(texts) / 2
+	first, err := e.embedSplit(ctx, texts[:half])
+	if err != nil {
+		return nil, err
+	}
+	rest, err := e.embedSplit(ctx, texts[half:])
+	if err != nil {
+		return nil, err
+	}
+	return append(first, rest...), nil
+}
+
+func (e *Client) embedBatch(ctx context.Context, texts []string) (embeddings [][]float32, err error) {
+	for attempt := 0; ; attempt++ {
+		if !e.breaker.allow() {
+			return nil, ErrCircuitOpen
+		}
+		embeddings, err = e.embed(ctx, texts)
+		retry := retryableEmbedderError(err)
+		switch {
+		case err == nil || !retry:
+			e.breaker.success()
+		case ctx.Err() != nil:
+			e.breaker.release()
+			return nil, err
+		default:
+			e.breaker.failure()
+		}
+		if !retry || attempt >= e.maxRetries {
+			return embeddings, err
+		}
+		metrics.EmbedderRetries.Inc()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(e.backoff(attempt)):
+		}
+	}
+}
+
+func (e *Client) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if e.slots != nil {
+		select {
+		case e.slots <- struct{}{}:
+			defer func() { <-e.slots }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+	req, err := e.provider.embedRequest(ctx, texts, isQuery(ctx))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, embedderStatusError(resp.StatusCode)
+	}
+	return e.provider.decodeEmbeddings(resp, len(texts))
+}
+
+// do sends req with the API key.
+func (e *Client) do(req *http.Request) (*http.Response, error) {
+	switch {
+	case e.apiKey == "":
+	case e.apiKeyHeader != "":
+		req.Header.Set(e.apiKeyHeader, e.apiKey)
+	default:
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+	return e.client.Do(req)
+}
+
+// backoff doubles per attempt up to maxEmbedderBackoff, with up to 50%
+// jitter so that retries from concurrent requests spread out.
+func (e *Client) backoff(attempt int) time.Duration {
+	d := min(e.retryBackoff<<attempt, maxEmbedderBackoff)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// APIError maps an Embed error for clients: fail-fast refusals are 503 so
+// that clients back off, anything else is a bad gateway.
+func APIError(err error) *apierr.APIError {
+	if errors.Is(err, ErrCircuitOpen) {
+		return apierr.New(http.StatusServiceUnavailable, apierr.CodeEmbedderFailed, "embedder unavailable")
+	}
+	return apierr.Embedder("embedder failed")
+}
+
+type embedderStatusError int
+
+func (s embedderStatusError) Error() string {
+	return fmt.Sprintf("embedder status %d", int(s))
+}
+
+// retryableEmbedderError reports transport failures (including per-attempt
+// timeouts) and 429/5xx responses. Other statuses mean the request itself
+// was rejected and will not succeed on a retry.
+func retryableEmbedderError(err error) bool {
+	var status embedderStatusError
+	if errors.As(err, &status) {
+		return status == http.StatusTooManyRequests || status >= 500
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// circuitBreaker opens after threshold consecutive failures. Once cooldown
+// has passed a single probe call is let through; its outcome closes the
+// breaker or opens it again.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (b *circuitBreaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *circuitBreaker) success() {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures, b.probing = 0, false
+	metrics.EmbedderCircuitOpen.Set(0)
+}
+
+func (b *circuitBreaker) failure() {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+		metrics.EmbedderCircuitOpen.Set(1)
+	}
+}
+
+// release ends a call that was abandoned by its caller without counting it
+// either way.
+func (b *circuitBreaker) release() {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
@@ -0,0 +1,65 @@
+// Package embedtest provides a fake embed.Embedder for handler and service
+// tests that run without the embedding service.
+package embedtest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/buugaaga/test-cursor/backend/internal/embed"
+)
+
+var _ embed.Embedder = (*Embedder)(nil)
+
+// Embedder returns deterministic vectors derived from each text, so equal
+// texts embed equally. EmbedFunc and PingFunc, when set, replace the default
+// behaviour, e.g. to inject errors.
+type Embedder struct {
+	// Dim is the vector size; zero means 8.
+	Dim       int
+	EmbedFunc func(ctx context.Context, texts []string) ([][]float32, error)
+	PingFunc  func(ctx context.Context) error
+
+	mu    sync.Mutex
+	calls [][]string
+}
+
+func (e *Embedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.mu.Lock()
+	e.calls = append(e.calls, append([]string(nil), texts...))
+	e.mu.Unlock()
+	if e.EmbedFunc != nil {
+		return e.EmbedFunc(ctx, texts)
+	}
+	out := make([][]float32, 0, len(texts))
+	for _, t := range texts {
+		out = append(out, Vector(t, e.dim()))
+	}
+	return out, nil
+}
+
+func (e *Embedder) Ping(ctx context.Context) error {
+	if e.PingFunc != nil {
+		return e.PingFunc(ctx)
+	}
+	return nil
+}
+
+// Calls returns the texts of every Embed call so far.
+func (e *Embedder) Calls() [][]string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([][]string(nil), e.calls...)
+}
+
+func (e *Embedder) dim() int {
+	if e.Dim > 0 {
+		return e.Dim
+	}
+	return 8
+}
+
+// Vector is the vector the fake returns for text.
+func Vector(text string, dim int) []float32 {
+	return embed.HashVector(text, dim)
+}
@@ -0,0 +1,287 @@
+package embed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// provider is the wire protocol of an embedding API. The Client adds
+// authentication, timeouts, retries and batching around it.
+type provider interface {
+	// maxBatch is the most texts one request may carry.
+	maxBatch() int
+	// maxBatchChars is the most characters, summed over its texts, one
+	// request should carry.
+	maxBatchChars() int
+	embedRequest(ctx context.Context, texts []string, query bool) (*http.Request, error)
+	decodeEmbeddings(resp *http.Response, n int) ([][]float32, error)
+	pingRequest(ctx context.Context) (*http.Request, error)
+}
+
+// describer is implemented by providers that can tell the model or its
+// vector size without embedding a probe text. Zero results are unknown.
+type describer interface {
+	describe(ctx context.Context, do func(*http.Request) (*http.Response, error)) (model string, dims int, err error)
+}
+
+// Providers are the values of Config.Provider.
+var Providers = []string{"http", "openai", "cohere", "ollama"}
+
+func newProvider(cfg Config) (provider, error) {
+	url := strings.TrimSuffix(cfg.URL, "/")
+	if cfg.Provider != "http" && cfg.Provider != "" && cfg.Model == "" {
+		return nil, fmt.Errorf("embedding provider %s needs a model", cfg.Provider)
+	}
+	switch cfg.Provider {
+	case "http", "":
+		return &httpProvider{url: or(url, "http://localhost:8000")}, nil
+	case "openai":
+		return &openAIProvider{url: or(url, "https://api.openai.com/v1"), model: cfg.Model, dims: cfg.Dimensions}, nil
+	case "cohere":
+		return &cohereProvider{url: or(url, "https://api.cohere.com"), model: cfg.Model}, nil
+	case "ollama":
+		return &ollamaProvider{url: or(url, "http://localhost:11434"), model: cfg.Model}, nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q, want one of %s", cfg.Provider, strings.Join(Providers, ", "))
+	}
+}
+
+func or(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func postJSON(ctx context.Context, url string, body any) (*http.Request, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+func decodeJSON(resp *http.Response, v any) error {
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// getJSON sends req and decodes a 200 response into v.
+func getJSON(req *http.Request, do func(*http.Request) (*http.Response, error), v any) error {
+	resp, err := do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return embedderStatusError(resp.StatusCode)
+	}
+	return decodeJSON(resp, v)
+}
+
+func checkCount(got [][]float32, n int) ([][]float32, error) {
+	if len(got) != n {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d texts", len(got), n)
+	}
+	return got, nil
+}
+
+// httpProvider is the bundled embedder service: POST /embed with
+// {"texts": [...]} returning {"embeddings": [...]}, and GET /healthz.
+type httpProvider struct {
+	url string
+}
+
+func (p *httpProvider) maxBatch() int      { return 256 }
+func (p *httpProvider) maxBatchChars() int { return 200_000 }
+
+func (p *httpProvider) embedRequest(ctx context.Context, texts []string, query bool) (*http.Request, error) {
+	return postJSON(ctx, p.url+"/embed", map[string]any{"texts": texts})
+}
+
+func (p *httpProvider) decodeEmbeddings(resp *http.Response, n int) ([][]float32, error) {
+	var r struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := decodeJSON(resp, &r); err != nil {
+		return nil, err
+	}
+	return checkCount(r.Embeddings, n)
+}
+
+func (p *httpProvider) pingRequest(ctx context.Context) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, http.MethodGet, p.url+"/healthz", nil)
+}
+
+// describe reads the model and its dimensions from /healthz.
+func (p *httpProvider) describe(ctx context.Context, do func(*http.Request) (*http.Response, error)) (string, int, error) {
+	req, err := p.pingRequest(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	var r struct {
+		Model      string `json:"model"`
+		Dimensions int    `json:"dimensions"`
+	}
+	if err := getJSON(req, do, &r); err != nil {
+		return "", 0, err
+	}
+	return r.Model, r.Dimensions, nil
+}
+
+// openAIProvider speaks the OpenAI embeddings API, which many hosted and
+// self-hosted servers also implement.
+type openAIProvider struct {
+	url   string
+	model string
+	// dims asks models that support it for shorter vectors; 0 keeps the
+	// model's size.
+	dims int
+}
+
+// openAIDimensions are the default sizes of OpenAI's own models.
+var openAIDimensions = map[string]int{
+	"text-embedding-3-small": 1536,
+	"text-embedding-3-large": 3072,
+	"text-embedding-ada-002": 1536,
+}
+
+func (p *openAIProvider) maxBatch() int { return 2048 }
+
+// maxBatchChars keeps under OpenAI's 300k tokens per request even for
+// Arabic, which can take a token per character.
+func (p *openAIProvider) maxBatchChars() int { return 250_000 }
+
+func (p *openAIProvider) embedRequest(ctx context.Context, texts []string, query bool) (*http.Request, error) {
+	body := map[string]any{"model": p.model, "input": texts, "encoding_format": "float"}
+	if p.dims > 0 {
+		body["dimensions"] = p.dims
+	}
+	return postJSON(ctx, p.url+"/embeddings", body)
+}
+
+type openAIEmbedding struct {
+	Index     int       `json:"index"`
+	Embedding []float32 `json:"embedding"`
+}
+
+func (p *openAIProvider) decodeEmbeddings(resp *http.Response, n int) ([][]float32, error) {
+	var r struct {
+		Data []openAIEmbedding `json:"data"`
+	}
+	if err := decodeJSON(resp, &r); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(r.Data, func(a, b openAIEmbedding) int { return a.Index - b.Index })
+	out := make([][]float32, 0, len(r.Data))
+	for _, d := range r.Data {
+		out = append(out, d.Embedding)
+	}
+	return checkCount(out, n)
+}
+
+func (p *openAIProvider) pingRequest(ctx context.Context) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, http.MethodGet, p.url+"/models", nil)
+}
+
+func (p *openAIProvider) describe(ctx context.Context, do func(*http.Request) (*http.Response, error)) (string, int, error) {
+	if p.dims > 0 {
+		return p.model, p.dims, nil
+	}
+	return p.model, openAIDimensions[p.model], nil
+}
+
+// cohereProvider speaks Cohere's v2 embed API. Queries and documents are
+// embedded with different input types, as its models expect.
+type cohereProvider struct {
+	url   string
+	model string
+}
+
+func (p *cohereProvider) maxBatch() int      { return 96 }
+func (p *cohereProvider) maxBatchChars() int { return 200_000 }
+
+func (p *cohereProvider) embedRequest(ctx context.Context, texts []string, query bool) (*http.Request, error) {
+	inputType := "search_document"
+	if query {
+		inputType = "search_query"
+	}
+	return postJSON(ctx, p.url+"/v2/embed", map[string]any{
+		"model":           p.model,
+		"texts":           texts,
+		"input_type":      inputType,
+		"embedding_types": []string{"float"},
+	})
+}
+
+func (p *cohereProvider) decodeEmbeddings(resp *http.Response, n int) ([][]float32, error) {
+	var r struct {
+		Embeddings struct {
+			Float [][]float32 `json:"float"`
+		} `json:"embeddings"`
+	}
+	if err := decodeJSON(resp, &r); err != nil {
+		return nil, err
+	}
+	return checkCount(r.Embeddings.Float, n)
+}
+
+func (p *cohereProvider) pingRequest(ctx context.Context) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, http.MethodGet, p.url+"/v1/models/"+p.model, nil)
+}
+
+// ollamaProvider speaks Ollama's /api/embed.
+type ollamaProvider struct {
+	url   string
+	model string
+}
+
+func (p *ollamaProvider) maxBatch() int      { return 512 }
+func (p *ollamaProvider) maxBatchChars() int { return 500_000 }
+
+func (p *ollamaProvider) embedRequest(ctx context.Context, texts []string, query bool) (*http.Request, error) {
+	return postJSON(ctx, p.url+"/api/embed", map[string]any{"model": p.model, "input": texts})
+}
+
+func (p *ollamaProvider) decodeEmbeddings(resp *http.Response, n int) ([][]float32, error) {
+	var r struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := decodeJSON(resp, &r); err != nil {
+		return nil, err
+	}
+	return checkCount(r.Embeddings, n)
+}
+
+func (p *ollamaProvider) pingRequest(ctx context.Context) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, http.MethodGet, p.url+"/api/tags", nil)
+}
+
+// describe reads the model's embedding_length from /api/show.
+func (p *ollamaProvider) describe(ctx context.Context, do func(*http.Request) (*http.Response, error)) (string, int, error) {
+	req, err := postJSON(ctx, p.url+"/api/show", map[string]any{"model": p.model})
+	if err != nil {
+		return "", 0, err
+	}
+	var r struct {
+		ModelInfo map[string]any `json:"model_info"`
+	}
+	if err := getJSON(req, do, &r); err != nil {
+		return "", 0, err
+	}
+	for k, v := range r.ModelInfo {
+		if n, ok := v.(float64); ok && strings.HasSuffix(k, ".embedding_length") {
+			return p.model, int(n), nil
+		}
+	}
+	return p.model, 0, nil
+}
@@ -0,0 +1,134 @@
+package embed
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Usage is how much text was, or would be, sent to the embedder.
+type Usage struct {
+	Texts int `json:"texts"`
+	Chars int `json:"chars"`
+	// Tokens is estimated by CountTokens, not reported by the provider.
+	Tokens int `json:"tokens"`
+	// Cost is Tokens at the configured price, in US dollars.
+	Cost float64 `json:"cost_usd"`
+}
+
+// Add counts texts, priced at price dollars per million tokens.
+func (u *Usage) Add(price float64, texts ...string) {
+	tokens := 0
+	for _, t := range texts {
+		u.Chars += utf8.RuneCountInString(t)
+		tokens += CountTokens(t)
+	}
+	u.Texts += len(texts)
+	u.Tokens += tokens
+	u.Cost += float64(tokens) * price / 1e6
+}
+
+// Merge adds the counts of v to u.
+func (u *Usage) Merge(v Usage) {
+	u.Texts += v.Texts
+	u.Chars += v.Chars
+	u.Tokens += v.Tokens
+	u.Cost += v.Cost
+}
+
+// CountTokens estimates how many tokens the subword tokenizers of
+// embedding models (BPE as in cl100k, or SentencePiece as in XLM-R) split
+// text into. Text is pre-tokenized as they do, into runs of letters of one
+// script, runs of digits and single punctuation marks, and each run is
+// counted at its script's usual number of characters per token: about four
+// for Latin, two and a half for Cyrillic and two for Arabic, whose
+// diacritics often become tokens of their own. It errs on the high side.
+func CountTokens(text string) int {
+	tokens := 0
+	var run []rune
+	runScript := ""
+	flush := func() {
+		if len(run) > 0 {
+			tokens += runTokens(runScript, len(run))
+			run = run[:0]
+		}
+	}
+	for _, r := range strings.TrimSpace(text) {
+		script := scriptOf(r)
+		switch {
+		case script == "space":
+			flush()
+		case script == "punct":
+			flush()
+			tokens++
+		case script == "mark" && len(run) > 0:
+			// Combining marks stay in the run of the letter they follow.
+			run = append(run, r)
+		default:
+			if script != runScript {
+				flush()
+			}
+			runScript = script
+			run = append(run, r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+func scriptOf(r rune) string {
+	switch {
+	case unicode.IsSpace(r):
+		return "space"
+	case unicode.IsDigit(r):
+		return "digit"
+	case unicode.IsMark(r):
+		return "mark"
+	case unicode.Is(unicode.Arabic, r) && unicode.IsLetter(r):
+		return "arabic"
+	case unicode.Is(unicode.Cyrillic, r):
+		return "cyrillic"
+	case unicode.Is(unicode.Latin, r):
+		return "latin"
+	case unicode.IsLetter(r):
+		return "other"
+	}
+	return "punct"
+}
+
+func runTokens(script string, n int) int {
+	switch script {
+	case "latin":
+		return (n + 3) / 4
+	case "cyrillic":
+		return (2*n + 4) / 5
+	case "arabic", "mark":
+		return (n + 1) / 2
+	case "digit":
+		// cl100k splits numbers into groups of up to three digits.
+		return (n + 2) / 3
+	}
+	return n
+}
+
+// listPrices are the providers' prices, in US dollars per million tokens,
+// of their embedding models.
+var listPrices = map[string]float64{
+	"openai:text-embedding-3-small":        0.02,
+	"openai:text-embedding-3-large":        0.13,
+	"openai:text-embedding-ada-002":        0.10,
+	"cohere:embed-english-v3.0":            0.10,
+	"cohere:embed-multilingual-v3.0":       0.10,
+	"cohere:embed-english-light-v3.0":      0.10,
+	"cohere:embed-multilingual-light-v3.0": 0.10,
+}
+
+// Price is the price in US dollars per million tokens of provider's model:
+// configured, when set, or else the list price, which is 0 for unknown
+// models and for those, as of the bundled service and Ollama, run locally.
+func Price(configured *float64, provider, model string) float64 {
+	if configured != nil {
+		return *configured
+	}
+	return listPrices[provider+":"+model]
+}
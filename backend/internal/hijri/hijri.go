@@ -0,0 +1,127 @@
+// Package hijri converts between the Gregorian and the Islamic (Hijri)
+// calendars. It uses the tabular calendar, whose months alternate between 30
+// and 29 days in a 30-year cycle of 11 leap years; sighting-based calendars
+// can differ from it by a day or two, which Calendar.Adjustment corrects.
+package hijri
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Method is the epoch the tabular calendar counts from.
+type Method string
+
+const (
+	// Civil counts from Friday 16 July 622 and is what most software uses.
+	Civil Method = "civil"
+	// Astronomical counts from Thursday 15 July 622, a day earlier.
+	Astronomical Method = "astronomical"
+)
+
+// Date is a day of the Hijri calendar.
+type Date struct {
+	Year  int `json:"year"`
+	Month int `json:"month"`
+	Day   int `json:"day"`
+}
+
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// Parse reads a date written as YYYY-MM-DD.
+func Parse(s string) (Date, error) {
+	var d Date
+	if _, err := fmt.Sscanf(s, "%d-%d-%d", &d.Year, &d.Month, &d.Day); err != nil || strings.Count(s, "-") != 2 {
+		return Date{}, fmt.Errorf("hijri: invalid date %q", s)
+	}
+	if !d.Valid() {
+		return Date{}, fmt.Errorf("hijri: invalid date %q", s)
+	}
+	return d, nil
+}
+
+// Valid reports whether d exists in the tabular calendar.
+func (d Date) Valid() bool {
+	return d.Year >= 1 && d.Month >= 1 && d.Month <= 12 && d.Day >= 1 && d.Day <= MonthLength(d.Year, d.Month)
+}
+
+// IsLeap reports whether year has 355 days rather than 354.
+func IsLeap(year int) bool {
+	return (14+11*year)%30 < 11
+}
+
+// MonthLength is the number of days in month of year: 30 in odd months, 29
+// in even ones, except for the 30-day Dhu al-Hijjah of leap years.
+func MonthLength(year, month int) int {
+	if month%2 == 1 || (month == 12 && IsLeap(year)) {
+		return 30
+	}
+	return 29
+}
+
+// Calendar converts dates by Method, shifted by Adjustment days.
+type Calendar struct {
+	Method     Method
+	Adjustment int
+}
+
+func (c Calendar) offset() int {
+	if c.Method == Astronomical {
+		return c.Adjustment + 1
+	}
+	return c.Adjustment
+}
+
+// FromTime returns the Hijri date of the calendar day of t, in t's location.
+func (c Calendar) FromTime(t time.Time) Date {
+	return fromJDN(julianDayNumber(t.Year(), int(t.Month()), t.Day()) + c.offset())
+}
+
+// ToTime returns midnight, in loc, of the Gregorian day d falls on.
+func (c Calendar) ToTime(d Date, loc *time.Location) (time.Time, error) {
+	if !d.Valid() {
+		return time.Time{}, fmt.Errorf("hijri: invalid date %s", d)
+	}
+	year, month, day := fromJulianDayNumber(toJDN(d) - c.offset())
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, loc), nil
+}
+
+// fromJDN converts a Julian day number to the civil tabular calendar.
+func fromJDN(jdn int) Date {
+	l := jdn - 1948440 + 10632
+	n := (l - 1) / 10631
+	l = l - 10631*n + 354
+	j := ((10985-l)/5316)*((50*l)/17719) + (l/5670)*((43*l)/15238)
+	l = l - ((30-j)/15)*((17719*j)/50) - (j/16)*((15238*j)/43) + 29
+	month := (24 * l) / 709
+	day := l - (709*month)/24
+	year := 30*n + j - 30
+	return Date{Year: year, Month: month, Day: day}
+}
+
+func toJDN(d Date) int {
+	return (11*d.Year+3)/30 + 354*d.Year + 30*d.Month - (d.Month-1)/2 + d.Day + 1948440 - 385
+}
+
+func julianDayNumber(year, month, day int) int {
+	a := (14 - month) / 12
+	y := year + 4800 - a
+	m := month + 12*a - 3
+	return day + (153*m+2)/5 + 365*y + y/4 - y/100 + y/400 - 32045
+}
+
+func fromJulianDayNumber(jdn int) (year, month, day int) {
+	a := jdn + 32044
+	b := (4*a + 3) / 146097
+	c := a - 146097*b/4
+	d := (4*c + 3) / 1461
+	e := c - 1461*d/4
+	m := (5*e + 2) / 153
+	day = e - (153*m+2)/5 + 1
+	month = m + 3 - 12*(m/10)
+	year = 100*b + d - 4800 + m/10
+	return year, month, day
+}
@@ -0,0 +1,50 @@
+package hijri
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Seasons maps Hijri months, or single days of a month, to a value: "9" is
+// all of Ramadan, "12-10" the day of Eid al-Adha.
+type Seasons map[string]string
+
+// ParseSeasons reads a comma-separated list such as "9=fasting,12-10=sacrifice".
+func ParseSeasons(s string) (Seasons, error) {
+	out := Seasons{}
+	for item := range strings.SplitSeq(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		value = strings.TrimSpace(value)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("hijri: %q: want month=value or month-day=value", item)
+		}
+		monthStr, dayStr, hasDay := strings.Cut(strings.TrimSpace(key), "-")
+		month, err := strconv.Atoi(monthStr)
+		if err != nil || month < 1 || month > 12 {
+			return nil, fmt.Errorf("hijri: %q: invalid month", item)
+		}
+		key = strconv.Itoa(month)
+		if hasDay {
+			day, err := strconv.Atoi(dayStr)
+			if err != nil || day < 1 || day > 30 {
+				return nil, fmt.Errorf("hijri: %q: invalid day", item)
+			}
+			key += "-" + strconv.Itoa(day)
+		}
+		out[key] = value
+	}
+	return out, nil
+}
+
+// Of returns the value for the day of d, else for its month, else "".
+func (s Seasons) Of(d Date) string {
+	if v, ok := s[fmt.Sprintf("%d-%d", d.Month, d.Day)]; ok {
+		return v
+	}
+	return s[strconv.Itoa(d.Month)]
+}
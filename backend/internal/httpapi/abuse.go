@@ -0,0 +1,395 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/bits"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/config"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+// abuseGroup is the route group of the abuse counters in
+// rate_limit_counters, which the rate limiter leaves alone.
+const abuseGroup = "abuse"
+
+// challengeTTL is how long a proof of work challenge, once solved, lets its
+// IP over the limit.
+const challengeTTL = 10 * time.Minute
+
+// abuseSettings are the ABUSE_* settings, with the limit parsed.
+type abuseSettings struct {
+	config.Abuse
+	limit *rateLimit
+}
+
+func parseAbuseSettings(s config.Abuse) (abuseSettings, error) {
+	out := abuseSettings{Abuse: s}
+	if s.SearchLimit != "" {
+		limit, err := parseRateLimit(s.SearchLimit)
+		if err != nil {
+			return out, fmt.Errorf("invalid ABUSE_SEARCH_LIMIT: %w", err)
+		}
+		out.limit = &limit
+	}
+	return out, nil
+}
+
+// AbuseGuard limits the public search API per IP over a sliding window,
+// estimated from the counts of the current and the previous fixed window.
+// IPs over the limit may solve a challenge to get through; those far over
+// it are banned from the public API for a while. Bans are kept in Postgres
+// and, for every request to check, in memory, refreshed from Postgres so
+// that replicas share them.
+type AbuseGuard struct {
+	db       *pgxpool.Pool
+	key      []byte
+	client   *http.Client
+	settings atomic.Pointer[abuseSettings]
+
+	mu   sync.RWMutex
+	bans map[string]time.Time
+}
+
+func newAbuseGuard(db *pgxpool.Pool, key []byte, s abuseSettings) *AbuseGuard {
+	g := &AbuseGuard{db: db, key: key, client: &http.Client{Timeout: 5 * time.Second}, bans: map[string]time.Time{}}
+	g.set(s)
+	return g
+}
+
+func (g *AbuseGuard) set(s abuseSettings) {
+	g.settings.Store(&s)
+}
+
+// abuseProtected reports whether path belongs to the public search API.
+func abuseProtected(path string) bool {
+	return routeGroup(path) == "search" || path == "/v1/search/suggestions"
+}
+
+func (g *AbuseGuard) bannedUntil(ip string) (time.Time, bool) {
+	g.mu.RLock()
+	until, ok := g.bans[ip]
+	g.mu.RUnlock()
+	return until, ok && time.Now().Before(until)
+}
+
+// refresh loads the bans in effect every 10 seconds, until ctx is done.
+func (g *AbuseGuard) refresh(ctx context.Context) {
+	t := time.NewTicker(10 * time.Second)
+	defer t.Stop()
+	for {
+		bans := map[string]time.Time{}
+		rows, err := g.db.Query(ctx, `SELECT ip, banned_until FROM ip_bans WHERE banned_until > now()`)
+		if err == nil {
+			var ip string
+			var until time.Time
+			_, err = pgx.ForEachRow(rows, []any{&ip, &until}, func() error {
+				bans[ip] = until
+				return nil
+			})
+		}
+		if err != nil && ctx.Err() == nil {
+			slog.Error("abuse: loading bans failed", "error", err)
+		} else if err == nil {
+			g.mu.Lock()
+			g.bans = bans
+			g.mu.Unlock()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// hit counts a request of ip and returns the counts of the current and the
+// previous window.
+func (g *AbuseGuard) hit(ctx context.Context, ip string, windowStart time.Time, window time.Duration) (current, previous int, err error) {
+	err = g.db.QueryRow(ctx, `
+WITH hit AS (
+  INSERT INTO rate_limit_counters (client, route_group, window_start, count)
+  VALUES ($1, $2, $3, 1)
+  ON CONFLICT (client, route_group, window_start) DO UPDATE SET count = rate_limit_counters.count + 1
+  RETURNING count
+)
+SELECT hit.count, COALESCE((SELECT count FROM rate_limit_counters WHERE client = $1 AND route_group = $2 AND window_start = $4), 0)
+FROM hit`, "ip:"+ip, abuseGroup, windowStart, windowStart.Add(-window)).Scan(&current, &previous)
+	return current, previous, err
+}
+
+func (g *AbuseGuard) ban(ctx context.Context, ip, reason string, until time.Time) error {
+	_, err := g.db.Exec(ctx, `
+INSERT INTO ip_bans (ip, reason, banned_until) VALUES ($1, $2, $3)
+ON CONFLICT (ip) DO UPDATE SET reason = EXCLUDED.reason, banned_until = EXCLUDED.banned_until,
+  banned_at = now(), bans = ip_bans.bans + 1, lifted_by = NULL`, ip, reason, until)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	g.bans[ip] = until
+	g.mu.Unlock()
+	return nil
+}
+
+func bannedError(c echo.Context, until time.Time) error {
+	retryAfter := int(time.Until(until).Seconds()) + 1
+	c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	return apierr.New(http.StatusForbidden, apierr.CodeForbidden, "temporarily banned").
+		WithDetails(map[string]any{"banned_until": until.UTC(), "retry_after": retryAfter})
+}
+
+// middleware turns banned IPs away from all but probes and the admin API,
+// and checks the public search API against the limit. Only keys that
+// authenticate are exempt; unknown ones are treated like no key. Like the
+// rate limiter it fails open.
+func (g *AbuseGuard) middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			group := routeGroup(c.Path())
+			if group == "" || group == "admin" || currentAPIKey(c) != nil {
+				return next(c)
+			}
+			ip := c.RealIP()
+			if until, ok := g.bannedUntil(ip); ok {
+				return bannedError(c, until)
+			}
+			s := g.settings.Load()
+			if s.limit == nil || !abuseProtected(c.Path()) {
+				return next(c)
+			}
+
+			ctx := c.Request().Context()
+			now, window := time.Now(), s.limit.Window
+			windowStart := now.Truncate(window)
+			hitCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			current, previous, err := g.hit(hitCtx, ip, windowStart, window)
+			cancel()
+			if err != nil {
+				slog.ErrorContext(ctx, "abuse check failed", "error", err)
+				return next(c)
+			}
+			elapsed := float64(now.Sub(windowStart)) / float64(window)
+			count := float64(previous)*(1-elapsed) + float64(current)
+			if count <= float64(s.limit.Limit) {
+				return next(c)
+			}
+
+			if s.BanAfter > 0 && count > float64(s.limit.Limit*s.BanAfter) {
+				until := now.Add(s.BanFor)
+				reason := fmt.Sprintf("%.0f searches in %s, limit %d", count, window, s.limit.Limit)
+				if err := g.ban(context.WithoutCancel(ctx), ip, reason, until); err != nil {
+					slog.ErrorContext(ctx, "abuse: ban failed", "ip", ip, "error", err)
+				} else {
+					slog.WarnContext(ctx, "abuse: ip banned", "ip", ip, "reason", reason, "until", until)
+				}
+				return bannedError(c, until)
+			}
+			if g.passed(c, s, ip) {
+				return next(c)
+			}
+
+			retryAfter := int(windowStart.Add(window).Sub(now).Seconds()) + 1
+			c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(retryAfter))
+			details := map[string]any{"limit": s.limit.Limit, "window": window.String(), "retry_after": retryAfter}
+			switch s.Challenge {
+			case "pow":
+				details["challenge"] = map[string]any{"type": "pow", "token": g.challenge(ip, s.PoWDifficulty, now.Add(challengeTTL)), "difficulty": s.PoWDifficulty}
+			case "captcha":
+				details["challenge"] = map[string]any{"type": "captcha"}
+			}
+			return apierr.New(http.StatusTooManyRequests, apierr.CodeRateLimited, "rate limit exceeded").WithDetails(details)
+		}
+	}
+}
+
+// passed reports whether the request solved the configured challenge:
+// X-Challenge with X-Challenge-Solution for pow, X-Captcha-Token for
+// captcha.
+func (g *AbuseGuard) passed(c echo.Context, s *abuseSettings, ip string) bool {
+	h := c.Request().Header
+	switch s.Challenge {
+	case "pow":
+		token, solution := h.Get("X-Challenge"), h.Get("X-Challenge-Solution")
+		return token != "" && solution != "" && g.solved(ip, token, solution)
+	case "captcha":
+		token := h.Get("X-Captcha-Token")
+		if token == "" {
+			return false
+		}
+		ok, err := g.verifyCaptcha(c.Request().Context(), s, ip, token)
+		if err != nil {
+			slog.ErrorContext(c.Request().Context(), "abuse: captcha verification failed", "error", err)
+		}
+		return ok
+	}
+	return false
+}
+
+func (g *AbuseGuard) sign(ip, payload string) string {
+	mac := hmac.New(sha256.New, g.key)
+	mac.Write([]byte(ip + "|" + payload))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// challenge is a proof of work token for ip: expiry.difficulty.nonce.
+// signature. It is solved by a string s such that the SHA-256 of
+// token + ":" + s starts with difficulty zero bits.
+func (g *AbuseGuard) challenge(ip string, difficulty int, expires time.Time) string {
+	payload := fmt.Sprintf("%d.%d.%s", expires.Unix(), difficulty, strings.ToLower(rand.Text()))
+	return payload + "." + g.sign(ip, payload)
+}
+
+func (g *AbuseGuard) solved(ip, token, solution string) bool {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 || !hmac.Equal([]byte(token[i+1:]), []byte(g.sign(ip, token[:i]))) {
+		return false
+	}
+	parts := strings.Split(token[:i], ".")
+	if len(parts) != 3 {
+		return false
+	}
+	expires, err1 := strconv.ParseInt(parts[0], 10, 64)
+	difficulty, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || time.Now().Unix() > expires {
+		return false
+	}
+	return leadingZeroBits(sha256.Sum256([]byte(token+":"+solution))) >= difficulty
+}
+
+func leadingZeroBits(sum [sha256.Size]byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// verifyCaptcha asks the siteverify endpoint whether token is a solved
+// captcha.
+func (g *AbuseGuard) verifyCaptcha(ctx context.Context, s *abuseSettings, ip, token string) (bool, error) {
+	form := url.Values{"secret": {s.CaptchaSecret}, "response": {token}, "remoteip": {ip}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.CaptchaVerifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("siteverify: status %d", resp.StatusCode)
+	}
+	var out struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return false, err
+	}
+	return out.Success, nil
+}
+
+// prune drops abuse counters of windows that ended long ago.
+func (g *AbuseGuard) prune(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			window := time.Hour
+			if s := g.settings.Load(); s.limit != nil {
+				window = max(window, s.limit.Window)
+			}
+			_, err := g.db.Exec(ctx, `DELETE FROM rate_limit_counters WHERE route_group = $1 AND window_start < $2`, abuseGroup, time.Now().Add(-2*window))
+			if err != nil {
+				slog.Error("abuse prune failed", "error", err)
+			}
+		}
+	}
+}
+
+// registerAbuseRoutes lets admins see the IPs banned for abuse and lift
+// their bans.
+func registerAbuseRoutes(admin *echo.Group, g *AbuseGuard) {
+	admin.GET("/abuse/bans", func(c echo.Context) error {
+		limit, err := parseLimit(c.QueryParam("limit"), 100, 1000)
+		if err != nil {
+			return apierr.InvalidArgument("invalid limit")
+		}
+		all := c.QueryParam("all") == "true"
+		rows, err := g.db.Query(c.Request().Context(), `
+SELECT ip, reason, bans, banned_at, banned_until, lifted_by FROM ip_bans
+WHERE $1 OR banned_until > now()
+ORDER BY banned_at DESC
+LIMIT $2`, all, limit)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		bans, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (ipBan, error) {
+			var b ipBan
+			err := row.Scan(&b.IP, &b.Reason, &b.Bans, &b.BannedAt, &b.BannedUntil, &b.LiftedBy)
+			b.Active = b.BannedUntil.After(time.Now())
+			return b, err
+		})
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, ipBanListResponse{Bans: bans})
+	})
+
+	// Lifting a ban also forgets the IP's recent searches, so that it is
+	// not banned again at once.
+	admin.DELETE("/abuse/bans/:ip", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		addr, err := netip.ParseAddr(c.Param("ip"))
+		if err != nil {
+			return apierr.InvalidArgument("invalid ip")
+		}
+		ip := addr.String()
+		var by *string
+		if p := currentPrincipal(c); p != nil {
+			by = &p.Name
+		}
+		auditNote(c, "abuse.unban", nil, "ip:"+ip)
+		err = g.db.QueryRow(ctx, `
+UPDATE ip_bans SET banned_until = now(), lifted_by = $2 WHERE ip = $1 AND banned_until > now() RETURNING ip`, ip, by).Scan(&ip)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("ban not found")
+		}
+		if err != nil {
+			return apierr.Database("db update ban failed")
+		}
+		if _, err := g.db.Exec(ctx, `DELETE FROM rate_limit_counters WHERE client = $1 AND route_group = $2`, "ip:"+ip, abuseGroup); err != nil {
+			return apierr.Database("db update ban failed")
+		}
+		g.mu.Lock()
+		delete(g.bans, ip)
+		g.mu.Unlock()
+		return c.NoContent(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,146 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+// searchEvent is a search as search analytics keep it. Experiment and
+// Variant are set for searches bucketed into a ranking experiment.
+type searchEvent struct {
+	Tenant     int64
+	Experiment *int64
+	Variant    *string
+	Client     string
+	Query      string
+	// Results are the ids of the results returned, in order.
+	Results []string
+}
+
+// SearchEvents records searches and the clicks and feedback on their
+// results, for click-through analysis and ranking experiments.
+type SearchEvents struct {
+	db *pgxpool.Pool
+	// retention is how long searches outside experiments are kept.
+	retention time.Duration
+}
+
+func newSearchEvents(db *pgxpool.Pool, retention time.Duration) *SearchEvents {
+	return &SearchEvents{db: db, retention: retention}
+}
+
+// record stores ev in the background and returns its id, the query id
+// clicks are reported against.
+func (s *SearchEvents) record(ctx context.Context, ev searchEvent) string {
+	id := strings.ToLower(rand.Text())
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		_, err := s.db.Exec(ctx, `
+INSERT INTO search_events (id, tenant_id, experiment_id, variant, client_id, query, hits, results)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			id, ev.Tenant, ev.Experiment, ev.Variant, ev.Client, ev.Query, len(ev.Results), ev.Results)
+		if err != nil {
+			slog.WarnContext(ctx, "search events: record failed", "error", err)
+		}
+	}()
+	return id
+}
+
+// prune deletes searches past the retention every hour. Searches made in
+// experiments go with their experiment. It runs on the leader only.
+func (s *SearchEvents) prune(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		tag, err := s.db.Exec(ctx, `
+DELETE FROM search_events WHERE experiment_id IS NULL AND created_at < now() - $1::interval`, s.retention)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			slog.Error("search events: prune failed", "error", err)
+		case err == nil && tag.RowsAffected() > 0:
+			slog.Info("search events: pruned", "searches", tag.RowsAffected())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// searchResultIDs are the ids of results in order.
+func searchResultIDs(results []searchResult) []string {
+	ids := make([]string, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+// registerEventRoutes lets clients report what they did with the results of
+// a search, by the query_id it returned.
+func registerEventRoutes(e *echo.Echo, deps *AppDependencies) {
+	// A click's position is where the result was in the search; it is looked
+	// up when the client leaves it out.
+	e.POST("/v1/events/click", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		var req searchClickRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		var results []string
+		err := deps.Postgres.QueryRow(ctx, `SELECT results FROM search_events WHERE id = $1`, req.QueryID).Scan(&results)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("search not found")
+		}
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		position := slices.Index(results, req.ResultID)
+		if position < 0 {
+			return apierr.InvalidArgument("result is not in the search")
+		}
+		if req.Position != nil {
+			position = *req.Position
+		}
+		_, err = deps.Postgres.Exec(ctx, `
+INSERT INTO search_interactions (search_id, kind, result_id, position) VALUES ($1, 'click', $2, $3)`, req.QueryID, req.ResultID, position)
+		if err != nil {
+			return apierr.Database("db insert search interaction failed")
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	// Feedback replaces the feedback given before on the same search.
+	e.POST("/v1/events/feedback", func(c echo.Context) error {
+		var req searchFeedbackRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		kind := "unhelpful"
+		if *req.Helpful {
+			kind = "helpful"
+		}
+		_, err := deps.Postgres.Exec(c.Request().Context(), `
+WITH previous AS (DELETE FROM search_interactions WHERE search_id = $1 AND kind IN ('helpful', 'unhelpful'))
+INSERT INTO search_interactions (search_id, kind) VALUES ($1, $2)`, req.QueryID, kind)
+		if isForeignKeyViolation(err) {
+			return apierr.NotFound("search not found")
+		}
+		if err != nil {
+			return apierr.Database("db insert search interaction failed")
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,1028 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/hijri"
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+	"github.com/buugaaga/test-cursor/backend/internal/jobs"
+	"github.com/buugaaga/test-cursor/backend/internal/quran"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+)
+
+// Request and response bodies of the HTTP API. /openapi.json is generated
+// from these types, so keep json tags and field types accurate.
+
+type searchRequest struct {
+	Query string `json:"query" validate:"required,max=1000"`
+	// Limit defaults to the caller's default limit; above its maximum the
+	// search is refused.
+	Limit int `json:"limit"`
+}
+
+type searchResult struct {
+	ID      string         `json:"id"`
+	Score   float32        `json:"score"`
+	Payload map[string]any `json:"payload"`
+}
+
+type Hadith = store.Hadith
+
+type Collection = store.Collection
+
+type batchGetRequest struct {
+	IDs []int64 `json:"ids" validate:"required,min=1,max=200"`
+}
+
+// batchRequest is a list of sub-requests for POST /v1/batch. Path includes
+// the query string, e.g. /v1/collections/bukhari/hadiths?cursor=...; Body
+// is the JSON body of POSTs.
+type batchRequest struct {
+	Requests []batchItem `json:"requests" validate:"required,min=1,max=20,dive"`
+}
+
+type batchItem struct {
+	ID     string          `json:"id" validate:"max=64"`
+	Method string          `json:"method" validate:"required,oneof=GET POST"`
+	Path   string          `json:"path" validate:"required,max=2048"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// batchResult is what a sub-request answered, in the order of the
+// requests; Body is its JSON body, or error.
+type batchResult struct {
+	ID      string            `json:"id,omitempty"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+type collectionStats struct {
+	Code    string `json:"code"`
+	Title   string `json:"title"`
+	Hadiths int64  `json:"hadiths"`
+	TextAr  int64  `json:"text_ar"`
+	TextRu  int64  `json:"text_ru"`
+	TextEn  int64  `json:"text_en"`
+}
+
+type corpusStats struct {
+	Collections    []collectionStats `json:"collections"`
+	Hadiths        int64             `json:"hadiths"`
+	Languages      map[string]int64  `json:"languages"`
+	Grades         map[string]int64  `json:"grades"`
+	IndexedVectors uint64            `json:"indexed_vectors"`
+	ComputedAt     time.Time         `json:"computed_at"`
+}
+
+type backupManifest struct {
+	ID               string    `json:"id"`
+	CreatedAt        time.Time `json:"created_at"`
+	Collections      int       `json:"collections"`
+	Hadiths          int       `json:"hadiths"`
+	QdrantCollection string    `json:"qdrant_collection"`
+	QdrantSnapshot   string    `json:"qdrant_snapshot"`
+	// PostgresDump is the pg_dump archive of the whole database, for
+	// disaster recovery with pg_restore; restores only use the content.
+	PostgresDump string `json:"postgres_dump,omitempty"`
+	// EmbeddingModel produced the snapshot's vectors; empty in backups
+	// taken before it was recorded.
+	EmbeddingModel string `json:"embedding_model,omitempty"`
+	// AyahRefs is the file of the hadiths' ayah references; backups taken
+	// before they were backed up have none, and restoring them keeps the
+	// references of the hadiths they have.
+	AyahRefs string `json:"ayah_refs,omitempty"`
+}
+
+type dependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type readinessResponse struct {
+	Status    string                      `json:"status"`
+	CheckedAt time.Time                   `json:"checked_at"`
+	Checks    map[string]dependencyStatus `json:"checks"`
+}
+
+type logSettings struct {
+	Level             string    `json:"level"`
+	SampleRate        float64   `json:"sample_rate"`
+	DebugRequestIDs   []string  `json:"debug_request_ids"`
+	DebugAPIKeyHashes []string  `json:"debug_api_key_hashes"`
+	Now               time.Time `json:"now"`
+}
+
+type maintenanceState struct {
+	Enabled bool       `json:"enabled"`
+	Message string     `json:"message"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+type maintenanceUpdate struct {
+	Enabled *bool   `json:"enabled" validate:"required"`
+	Message *string `json:"message" validate:"omitnil,max=500"`
+}
+
+type logSettingsUpdate struct {
+	Level           *string  `json:"level"`
+	SampleRate      *float64 `json:"sample_rate"`
+	DebugRequestIDs []string `json:"debug_request_ids"`
+	DebugAPIKeys    []string `json:"debug_api_keys"`
+	ClearDebug      bool     `json:"clear_debug"`
+	TTLSeconds      int      `json:"ttl_seconds"`
+}
+
+type searchResponse struct {
+	Results []searchResult `json:"results"`
+	// Degraded marks keyword matches served while semantic search is down.
+	Degraded bool `json:"degraded,omitempty"`
+	// QueryID identifies the search when clicks and feedback on its
+	// results are reported.
+	QueryID string `json:"query_id,omitempty"`
+	// Variant is the ranking experiment variant the search ran in.
+	Variant string `json:"variant,omitempty"`
+}
+
+type collectionListResponse struct {
+	Collections []Collection `json:"collections"`
+}
+
+type bookListResponse struct {
+	Books []store.Book `json:"books"`
+}
+
+type hadithListResponse struct {
+	Hadiths    []Hadith `json:"hadiths"`
+	NextCursor *string  `json:"next_cursor"`
+}
+
+type batchGetResponse struct {
+	Hadiths []Hadith `json:"hadiths"`
+	Missing []int64  `json:"missing"`
+}
+
+type dailyHadithResponse struct {
+	Date       string `json:"date"`
+	HijriDate  string `json:"hijri_date"`
+	HijriMonth string `json:"hijri_month"`
+	Calendar   string `json:"calendar"`
+	Hadith     Hadith `json:"hadith"`
+}
+
+type hijriConversion struct {
+	Date       string     `json:"date"`
+	Hijri      hijri.Date `json:"hijri"`
+	HijriDate  string     `json:"hijri_date"`
+	HijriMonth string     `json:"hijri_month"`
+	Method     string     `json:"method"`
+	Adjustment int        `json:"adjustment"`
+}
+
+type backupListResponse struct {
+	Backups []backupManifest `json:"backups"`
+}
+
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+type webhook struct {
+	ID             int64      `json:"id"`
+	URL            string     `json:"url"`
+	Events         []string   `json:"events"`
+	Active         bool       `json:"active"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastDeliveryAt *time.Time `json:"last_delivery_at"`
+	LastError      *string    `json:"last_error"`
+}
+
+type webhookCreateRequest struct {
+	URL    string   `json:"url" validate:"required,http_url"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=hadith.created hadith.updated hadith.deleted collection.updated reindex.started reindex.completed reindex.failed"`
+	Secret string   `json:"secret" validate:"omitempty,min=16,max=256"`
+}
+
+type webhookCreateResponse struct {
+	Webhook webhook `json:"webhook"`
+	Secret  string  `json:"secret"`
+}
+
+type webhookListResponse struct {
+	Webhooks []webhook `json:"webhooks"`
+}
+
+// hadithPatchRequest changes the metadata of a hadith; omitted fields are
+// kept and empty ones cleared.
+type hadithPatchRequest struct {
+	Grade  *string   `json:"grade" validate:"omitnil,max=64"`
+	Title  *string   `json:"title" validate:"omitnil,max=300"`
+	Topics *[]string `json:"topics" validate:"omitnil,max=50,dive,required,max=100"`
+}
+
+type bulkPreviewRequest struct {
+	Filter ingest.BulkFilter `json:"filter"`
+}
+
+type bulkPreviewResponse struct {
+	Matched int64 `json:"matched"`
+}
+
+// bulkDeleteRequest and bulkUpdateRequest carry the count a preview of the
+// filter returned; they are refused when it no longer matches.
+type bulkDeleteRequest struct {
+	Filter   ingest.BulkFilter `json:"filter"`
+	Expected *int64            `json:"expected" validate:"required,min=0"`
+}
+
+type bulkUpdateRequest struct {
+	Filter   ingest.BulkFilter  `json:"filter"`
+	Set      hadithPatchRequest `json:"set"`
+	Expected *int64             `json:"expected" validate:"required,min=0"`
+}
+
+type jobCreateRequest struct {
+	Kind string `json:"kind" validate:"required,oneof=reindex index.backfill shadow.backfill duplicates.detect ayahs.detect"`
+	// Collection limits a reindex to one collection.
+	Collection string `json:"collection" validate:"omitempty,max=64"`
+	// DryRun has a reindex only estimate what it would embed.
+	DryRun bool `json:"dry_run"`
+	// Threshold is the cosine similarity above which duplicates.detect
+	// groups hadiths.
+	Threshold float32 `json:"threshold" validate:"omitempty,gt=0,lte=1"`
+}
+
+type jobListResponse struct {
+	Jobs       []jobs.Job `json:"jobs"`
+	NextCursor *string    `json:"next_cursor"`
+}
+
+type clusterResponse struct {
+	// Replica is the replica that answered.
+	Replica  string           `json:"replica"`
+	Replicas []clusterReplica `json:"replicas"`
+	// Locks maps each held lock to its holder: a replica id or an operator
+	// command such as reindex.
+	Locks map[string]string `json:"locks"`
+}
+
+type clusterReplica struct {
+	ID        string    `json:"id"`
+	Hostname  string    `json:"hostname"`
+	StartedAt time.Time `json:"started_at"`
+	SeenAt    time.Time `json:"seen_at"`
+	// Leads lists the locks the replica holds, Jobs the running jobs it
+	// claimed.
+	Leads []string `json:"leads"`
+	Jobs  []int64  `json:"jobs"`
+}
+
+type apiKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	Tier       string     `json:"tier"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+type apiKeyCreateRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=reader editor admin"`
+	// Tier picks the key's search limits from SEARCH_TIER_LIMITS.
+	Tier string `json:"tier" validate:"max=64"`
+}
+
+type apiKeyCreateResponse struct {
+	Key    apiKey `json:"key"`
+	Secret string `json:"secret"`
+}
+
+type apiKeyListResponse struct {
+	Keys []apiKey `json:"keys"`
+}
+
+type tenantInfo struct {
+	ID        int64     `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type tenantCreateRequest struct {
+	Code string `json:"code" validate:"required,max=64,hostname_rfc1123"`
+	Name string `json:"name" validate:"required,max=200"`
+}
+
+type tenantListResponse struct {
+	Tenants []tenantInfo `json:"tenants"`
+}
+
+type user struct {
+	ID          int64   `json:"id"`
+	Email       string  `json:"email"`
+	DisplayName *string `json:"display_name"`
+	Role        string  `json:"role"`
+	// SearchHistory is whether the user's searches are kept.
+	SearchHistory bool      `json:"search_history"`
+	CreatedAt     time.Time `json:"created_at"`
+	TenantID      int64     `json:"-"`
+}
+
+type registerRequest struct {
+	Email       string `json:"email" validate:"required,email,max=254"`
+	Password    string `json:"password" validate:"min=8,max=72"`
+	DisplayName string `json:"display_name" validate:"max=100"`
+}
+
+type profileUpdate struct {
+	DisplayName   *string `json:"display_name" validate:"omitnil,max=100"`
+	SearchHistory *bool   `json:"search_history"`
+}
+
+type searchHistoryEntry struct {
+	ID         int64     `json:"id"`
+	Query      string    `json:"query"`
+	Count      int       `json:"count"`
+	SearchedAt time.Time `json:"searched_at"`
+}
+
+type searchHistoryResponse struct {
+	// Enabled is whether new searches are kept; see PATCH /v1/me.
+	Enabled  bool                 `json:"enabled"`
+	Searches []searchHistoryEntry `json:"searches"`
+}
+
+type searchSuggestion struct {
+	Text string `json:"text"`
+	// Source is history, for the user's own searches, or topic.
+	Source string `json:"source"`
+}
+
+type searchSuggestionsResponse struct {
+	Suggestions []searchSuggestion `json:"suggestions"`
+}
+
+// bookmark marks a hadith (HadithID, with the hadith when listed) or an
+// ayah (Surah and Ayah).
+type bookmark struct {
+	ID        int64     `json:"id"`
+	Kind      string    `json:"kind"`
+	HadithID  *int64    `json:"hadith_id,omitempty"`
+	Surah     *int      `json:"surah,omitempty"`
+	Ayah      *int      `json:"ayah,omitempty"`
+	FolderID  *int64    `json:"folder_id"`
+	Note      *string   `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+	Hadith    *Hadith   `json:"hadith,omitempty"`
+}
+
+type bookmarkCreateRequest struct {
+	HadithID int64  `json:"hadith_id" validate:"required_without=Surah,excluded_with=Surah"`
+	Surah    int    `json:"surah" validate:"required_without=HadithID,omitempty,min=1,max=114"`
+	Ayah     int    `json:"ayah" validate:"required_with=Surah,excluded_with=HadithID,omitempty,min=1,max=286"`
+	FolderID int64  `json:"folder_id"`
+	Note     string `json:"note" validate:"max=1000"`
+}
+
+// bookmarkUpdate moves a bookmark to another folder (0 for none) or
+// changes its note.
+type bookmarkUpdate struct {
+	FolderID *int64  `json:"folder_id" validate:"omitnil,min=0"`
+	Note     *string `json:"note" validate:"omitnil,max=1000"`
+}
+
+type bookmarkListResponse struct {
+	Bookmarks  []bookmark `json:"bookmarks"`
+	NextCursor *string    `json:"next_cursor"`
+}
+
+type bookmarkFolder struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Bookmarks int       `json:"bookmarks"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type bookmarkFolderRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type bookmarkFolderListResponse struct {
+	Folders []bookmarkFolder `json:"folders"`
+}
+
+// hadithNote is a user's private note on a hadith.
+type hadithNote struct {
+	ID         int64           `json:"id"`
+	HadithID   int64           `json:"hadith_id"`
+	Body       string          `json:"body"`
+	Highlights []noteHighlight `json:"highlights"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	Hadith     *Hadith         `json:"hadith,omitempty"`
+}
+
+// noteHighlight marks characters (Unicode code points) Start up to End of
+// one of the hadith's texts.
+type noteHighlight struct {
+	Field string `json:"field" validate:"oneof=text_ar text_ru text_en"`
+	Start int    `json:"start" validate:"min=0"`
+	End   int    `json:"end" validate:"gtfield=Start"`
+	Color string `json:"color,omitempty" validate:"omitempty,hexcolor"`
+}
+
+type noteCreateRequest struct {
+	HadithID   int64           `json:"hadith_id" validate:"required"`
+	Body       string          `json:"body" validate:"required_without=Highlights,max=10000"`
+	Highlights []noteHighlight `json:"highlights" validate:"max=50,dive"`
+}
+
+type noteUpdate struct {
+	Body       *string          `json:"body" validate:"omitnil,max=10000"`
+	Highlights *[]noteHighlight `json:"highlights" validate:"omitnil,max=50,dive"`
+}
+
+// noteExport is a line of GET /v1/me/notes/export.
+type noteExport struct {
+	hadithNote
+	CollectionCode string `json:"collection_code"`
+	Number         string `json:"number"`
+}
+
+type noteListResponse struct {
+	Notes      []hadithNote `json:"notes"`
+	NextCursor *string      `json:"next_cursor"`
+}
+
+// readingList is an ordered list of hadiths and ayahs; Items is only filled
+// in when one list is fetched.
+type readingList struct {
+	ID          int64   `json:"id"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	ItemCount   int     `json:"item_count"`
+	ShareSlug   *string `json:"share_slug"`
+	ShareURL    *string `json:"share_url,omitempty"`
+	// Moderation is whether others may see the list while shared.
+	Moderation string            `json:"moderation"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	Items      []readingListItem `json:"items,omitempty"`
+}
+
+type readingListItem struct {
+	Kind     string  `json:"kind"`
+	HadithID *int64  `json:"hadith_id,omitempty"`
+	Surah    *int    `json:"surah,omitempty"`
+	Ayah     *int    `json:"ayah,omitempty"`
+	Note     string  `json:"note"`
+	Hadith   *Hadith `json:"hadith,omitempty"`
+}
+
+type readingListItemRequest struct {
+	HadithID int64  `json:"hadith_id" validate:"required_without=Surah,excluded_with=Surah"`
+	Surah    int    `json:"surah" validate:"required_without=HadithID,omitempty,min=1,max=114"`
+	Ayah     int    `json:"ayah" validate:"required_with=Surah,excluded_with=HadithID,omitempty,min=1,max=286"`
+	Note     string `json:"note" validate:"max=2000"`
+}
+
+type readingListCreateRequest struct {
+	Title       string                   `json:"title" validate:"required,max=200"`
+	Description string                   `json:"description" validate:"max=5000"`
+	Items       []readingListItemRequest `json:"items" validate:"max=500,dive"`
+}
+
+type readingListUpdate struct {
+	Title       *string `json:"title" validate:"omitnil,min=1,max=200"`
+	Description *string `json:"description" validate:"omitnil,max=5000"`
+}
+
+// readingListItemsRequest replaces a list's items, in order.
+type readingListItemsRequest struct {
+	Items []readingListItemRequest `json:"items" validate:"max=500,dive"`
+}
+
+type readingListListResponse struct {
+	Lists []readingList `json:"lists"`
+}
+
+// sharedReadingList is a list as read through its share slug.
+type sharedReadingList struct {
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Author      *string           `json:"author"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	Items       []readingListItem `json:"items"`
+}
+
+type moderationItem struct {
+	ID        int64      `json:"id"`
+	Kind      string     `json:"kind"`
+	RefID     int64      `json:"ref_id"`
+	UserID    *int64     `json:"user_id"`
+	Content   string     `json:"content"`
+	Status    string     `json:"status"`
+	Reasons   []string   `json:"reasons"`
+	CreatedAt time.Time  `json:"created_at"`
+	DecidedAt *time.Time `json:"decided_at"`
+}
+
+type moderationListResponse struct {
+	Items      []moderationItem `json:"items"`
+	NextCursor *string          `json:"next_cursor"`
+}
+
+type moderationDecisionRequest struct {
+	Decision string `json:"decision" validate:"required,oneof=approve reject"`
+	Reason   string `json:"reason" validate:"max=1000"`
+}
+
+type moderationDecision struct {
+	ID        int64     `json:"id"`
+	ItemID    int64     `json:"item_id"`
+	Kind      string    `json:"kind"`
+	RefID     int64     `json:"ref_id"`
+	Decision  string    `json:"decision"`
+	Moderator *string   `json:"moderator"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type moderationDecisionListResponse struct {
+	Decisions  []moderationDecision `json:"decisions"`
+	NextCursor *string              `json:"next_cursor"`
+}
+
+type ipBan struct {
+	IP          string    `json:"ip"`
+	Reason      string    `json:"reason"`
+	Bans        int       `json:"bans"`
+	Active      bool      `json:"active"`
+	BannedAt    time.Time `json:"banned_at"`
+	BannedUntil time.Time `json:"banned_until"`
+	LiftedBy    *string   `json:"lifted_by"`
+}
+
+type ipBanListResponse struct {
+	Bans []ipBan `json:"bans"`
+}
+
+type loginRequest struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type authResponse struct {
+	User      user      `json:"user"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type userListResponse struct {
+	Users []user `json:"users"`
+}
+
+type roleUpdateRequest struct {
+	Role string `json:"role" validate:"required,oneof=reader editor admin"`
+}
+
+type auditActor struct {
+	Kind string `json:"kind"`
+	ID   *int64 `json:"id"`
+	Name string `json:"name"`
+}
+
+type auditEntry struct {
+	ID        int64          `json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	Actor     auditActor     `json:"actor"`
+	Action    string         `json:"action"`
+	Resources []string       `json:"resources"`
+	Summary   map[string]any `json:"summary"`
+	Status    int            `json:"status"`
+	RequestID *string        `json:"request_id"`
+	RemoteIP  *string        `json:"remote_ip"`
+}
+
+type auditListResponse struct {
+	Entries    []auditEntry `json:"entries"`
+	NextCursor *string      `json:"next_cursor"`
+}
+
+type export struct {
+	ID                string     `json:"id"`
+	Status            string     `json:"status"`
+	CollectionCode    *string    `json:"collection_code"`
+	Format            string     `json:"format"`
+	Hadiths           *int       `json:"hadiths"`
+	SizeBytes         *int64     `json:"size_bytes"`
+	Error             *string    `json:"error"`
+	CreatedBy         *string    `json:"created_by"`
+	CreatedAt         time.Time  `json:"created_at"`
+	CompletedAt       *time.Time `json:"completed_at"`
+	DownloadURL       *string    `json:"download_url,omitempty"`
+	DownloadExpiresAt *time.Time `json:"download_expires_at,omitempty"`
+	TenantID          int64      `json:"-"`
+}
+
+type exportCreateRequest struct {
+	Collection string `json:"collection" validate:"max=64"`
+	Format     string `json:"format" validate:"omitempty,oneof=ndjson csv"`
+}
+
+type exportListResponse struct {
+	Exports []export `json:"exports"`
+}
+
+type usageDay struct {
+	Date          string `json:"date"`
+	APIKeyID      int64  `json:"api_key_id"`
+	APIKeyName    string `json:"api_key_name"`
+	Requests      int64  `json:"requests"`
+	Searches      int64  `json:"searches"`
+	EmbeddedTexts int64  `json:"embedded_texts"`
+	EmbeddedChars int64  `json:"embedded_chars"`
+}
+
+type usageTotal struct {
+	APIKeyID      int64  `json:"api_key_id"`
+	APIKeyName    string `json:"api_key_name"`
+	Requests      int64  `json:"requests"`
+	Searches      int64  `json:"searches"`
+	EmbeddedTexts int64  `json:"embedded_texts"`
+	EmbeddedChars int64  `json:"embedded_chars"`
+}
+
+type usageReport struct {
+	From   string       `json:"from"`
+	To     string       `json:"to"`
+	Days   []usageDay   `json:"days"`
+	Totals []usageTotal `json:"totals"`
+}
+
+// subscription is a daily hadith delivery: Target is an email address, an
+// FCM registration token or a webhook URL, depending on Channel.
+type subscription struct {
+	ID         int64   `json:"id"`
+	Channel    string  `json:"channel"`
+	Target     string  `json:"target"`
+	Collection *string `json:"collection"`
+	Language   string  `json:"language"`
+	// SendAt is the local time of day, HH:MM, in Timezone.
+	SendAt     string    `json:"send_at"`
+	Timezone   string    `json:"timezone"`
+	Active     bool      `json:"active"`
+	LastSentOn *string   `json:"last_sent_on"`
+	LastError  *string   `json:"last_error"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type subscriptionCreateRequest struct {
+	Channel string `json:"channel" validate:"required,oneof=email push webhook"`
+	// Target defaults to the account email for the email channel.
+	Target     string `json:"target" validate:"max=4096"`
+	Collection string `json:"collection" validate:"max=64"`
+	Language   string `json:"language" validate:"omitempty,oneof=ar ru en"`
+	SendAt     string `json:"send_at" validate:"omitempty,datetime=15:04"`
+	Timezone   string `json:"timezone" validate:"max=64"`
+}
+
+type subscriptionUpdate struct {
+	// Collection is the collection to pick from; empty for any.
+	Collection *string `json:"collection" validate:"omitnil,max=64"`
+	Language   *string `json:"language" validate:"omitnil,oneof=ar ru en"`
+	SendAt     *string `json:"send_at" validate:"omitnil,datetime=15:04"`
+	Timezone   *string `json:"timezone" validate:"omitnil,min=1,max=64"`
+	Active     *bool   `json:"active"`
+}
+
+// subscriptionCreateResponse carries the webhook signing secret, which is
+// only ever shown here.
+type subscriptionCreateResponse struct {
+	Subscription subscription `json:"subscription"`
+	Secret       *string      `json:"secret,omitempty"`
+}
+
+type subscriptionListResponse struct {
+	Subscriptions []subscription `json:"subscriptions"`
+	// Channels are the channels this server delivers through.
+	Channels []string `json:"channels"`
+}
+
+// shareLinkRequest shares one hadith or the results of a search.
+type shareLinkRequest struct {
+	HadithID int64  `json:"hadith_id" validate:"required_without=Query,excluded_with=Query"`
+	Query    string `json:"query" validate:"required_without=HadithID,max=1000"`
+}
+
+type shareLink struct {
+	Slug string `json:"slug"`
+	URL  string `json:"url"`
+	// Kind is hadith or search.
+	Kind      string    `json:"kind"`
+	HadithID  *int64    `json:"hadith_id,omitempty"`
+	Query     *string   `json:"query,omitempty"`
+	Hits      int64     `json:"hits"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// scholar is an author, compiler or grader of hadiths. Books is only filled
+// when a single scholar is fetched.
+type scholar struct {
+	Slug      string       `json:"slug"`
+	Name      string       `json:"name"`
+	NameAr    string       `json:"name_ar,omitempty"`
+	BornAH    *int         `json:"born_ah,omitempty"`
+	DiedAH    *int         `json:"died_ah,omitempty"`
+	Bio       string       `json:"bio,omitempty"`
+	Books     []sourceBook `json:"books,omitempty"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+type scholarRequest struct {
+	Name   string `json:"name" validate:"required,max=200"`
+	NameAr string `json:"name_ar" validate:"max=200"`
+	BornAH *int   `json:"born_ah" validate:"omitnil,min=1,max=1600"`
+	DiedAH *int   `json:"died_ah" validate:"omitnil,min=1,max=1600"`
+	Bio    string `json:"bio" validate:"max=10000"`
+}
+
+type scholarListResponse struct {
+	Scholars []scholar `json:"scholars"`
+}
+
+// scholarRef and sourceBookRef name a scholar or a book inside another
+// resource.
+type scholarRef struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+type sourceBookRef struct {
+	Slug    string `json:"slug"`
+	Title   string `json:"title"`
+	Edition string `json:"edition,omitempty"`
+}
+
+type sourceBook struct {
+	Slug          string      `json:"slug"`
+	Title         string      `json:"title"`
+	TitleAr       string      `json:"title_ar,omitempty"`
+	Author        *scholarRef `json:"author,omitempty"`
+	Edition       string      `json:"edition,omitempty"`
+	Publisher     string      `json:"publisher,omitempty"`
+	PublishedYear *int        `json:"published_year,omitempty"`
+	Volumes       *int        `json:"volumes,omitempty"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+type sourceBookRequest struct {
+	Title   string `json:"title" validate:"required,max=300"`
+	TitleAr string `json:"title_ar" validate:"max=300"`
+	// Author is the slug of a scholar.
+	Author        string `json:"author" validate:"max=64"`
+	Edition       string `json:"edition" validate:"max=300"`
+	Publisher     string `json:"publisher" validate:"max=300"`
+	PublishedYear *int   `json:"published_year" validate:"omitnil,min=1,max=3000"`
+	Volumes       *int   `json:"volumes" validate:"omitnil,min=1,max=1000"`
+}
+
+type sourceBookListResponse struct {
+	Books []sourceBook `json:"books"`
+}
+
+// hadithSource is where a hadith appears in a book. Citation renders it in
+// the language of the request.
+type hadithSource struct {
+	Book     sourceBookRef `json:"book"`
+	Volume   *int          `json:"volume,omitempty"`
+	Page     *int          `json:"page,omitempty"`
+	Number   string        `json:"number,omitempty"`
+	Citation string        `json:"citation"`
+}
+
+// hadithGrading is the grade a scholar gave a hadith and, when known, where.
+type hadithGrading struct {
+	Scholar  scholarRef     `json:"scholar"`
+	Grade    string         `json:"grade"`
+	Book     *sourceBookRef `json:"book,omitempty"`
+	Volume   *int           `json:"volume,omitempty"`
+	Page     *int           `json:"page,omitempty"`
+	Citation string         `json:"citation,omitempty"`
+}
+
+type hadithCitations struct {
+	HadithID int64           `json:"hadith_id"`
+	Sources  []hadithSource  `json:"sources"`
+	Gradings []hadithGrading `json:"gradings"`
+}
+
+type hadithSourceRequest struct {
+	Book   string `json:"book" validate:"required,max=64"`
+	Volume *int   `json:"volume" validate:"omitnil,min=1"`
+	Page   *int   `json:"page" validate:"omitnil,min=1"`
+	Number string `json:"number" validate:"max=32"`
+}
+
+type hadithGradingRequest struct {
+	Scholar string `json:"scholar" validate:"required,max=64"`
+	Grade   string `json:"grade" validate:"required,max=64"`
+	Book    string `json:"book" validate:"max=64"`
+	Volume  *int   `json:"volume" validate:"omitnil,min=1"`
+	Page    *int   `json:"page" validate:"omitnil,min=1"`
+}
+
+// hadithCitationsRequest replaces the sources, in order, and the gradings of
+// a hadith; books and scholars are named by slug.
+type hadithCitationsRequest struct {
+	Sources  []hadithSourceRequest  `json:"sources" validate:"max=50,dive"`
+	Gradings []hadithGradingRequest `json:"gradings" validate:"max=50,dive"`
+}
+
+type topic struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// topicRequest describes a topic; the description is embedded with its name
+// to suggest it.
+type topicRequest struct {
+	Description string `json:"description" validate:"max=1000"`
+}
+
+type hadithAyah struct {
+	Surah int `json:"surah"`
+	Ayah  int `json:"ayah"`
+	// Source is manual for refs set by editors and detected for citations
+	// found in the texts.
+	Source string `json:"source"`
+}
+
+type hadithAyahs struct {
+	HadithID int64        `json:"hadith_id"`
+	Ayahs    []hadithAyah `json:"ayahs"`
+}
+
+// hadithAyahsRequest replaces the ayah refs of a hadith.
+type hadithAyahsRequest struct {
+	Ayahs []quran.Ref `json:"ayahs" validate:"max=50"`
+}
+
+type duplicateMember struct {
+	HadithID       int64   `json:"hadith_id"`
+	CollectionCode string  `json:"collection_code"`
+	Number         string  `json:"number"`
+	Score          float32 `json:"score"`
+}
+
+// duplicateGroup is a candidate set of duplicate narrations. Score is the
+// highest similarity between two of its members.
+type duplicateGroup struct {
+	ID              int64             `json:"id"`
+	Status          string            `json:"status"`
+	Score           float32           `json:"score"`
+	CrossCollection bool              `json:"cross_collection"`
+	CanonicalID     *int64            `json:"canonical_id,omitempty"`
+	JobID           *int64            `json:"job_id,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	ResolvedAt      *time.Time        `json:"resolved_at,omitempty"`
+	Members         []duplicateMember `json:"members"`
+}
+
+type duplicateGroupListResponse struct {
+	Groups     []duplicateGroup `json:"groups"`
+	NextCursor *string          `json:"next_cursor"`
+}
+
+// duplicateResolveRequest links the hadiths of a group as parallel
+// narrations, merges them under CanonicalID, one of them, or dismisses the
+// group.
+type duplicateResolveRequest struct {
+	Action      string `json:"action" validate:"required,oneof=link merge dismiss"`
+	CanonicalID int64  `json:"canonical_id" validate:"required_if=Action merge"`
+}
+
+type hadithParallel struct {
+	ID             int64  `json:"id"`
+	CollectionCode string `json:"collection_code"`
+	Number         string `json:"number"`
+	// Relation is "linked" or "merged".
+	Relation string `json:"relation"`
+}
+
+// hadithParallels are the narrations reviewed as duplicates of a hadith;
+// CanonicalID is set when they were merged.
+type hadithParallels struct {
+	HadithID    int64            `json:"hadith_id"`
+	CanonicalID *int64           `json:"canonical_id,omitempty"`
+	Parallels   []hadithParallel `json:"parallels"`
+}
+
+// featuredItem is a hadith or a collection featured by editors; Hadith or
+// Collection is set according to Kind.
+type featuredItem struct {
+	ID         int64             `json:"id"`
+	Kind       string            `json:"kind"`
+	Headline   string            `json:"headline,omitempty"`
+	Position   int               `json:"position"`
+	StartsOn   *string           `json:"starts_on,omitempty"`
+	EndsOn     *string           `json:"ends_on,omitempty"`
+	HijriMonth *int              `json:"hijri_month,omitempty"`
+	Hadith     *Hadith           `json:"hadith,omitempty"`
+	Collection *store.Collection `json:"collection,omitempty"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// featuredRequest features either a hadith or a collection, optionally
+// from StartsOn to EndsOn and only in HijriMonth.
+type featuredRequest struct {
+	HadithID   int64  `json:"hadith_id" validate:"required_without=Collection,excluded_with=Collection"`
+	Collection string `json:"collection" validate:"required_without=HadithID,max=64"`
+	Headline   string `json:"headline" validate:"max=200"`
+	Position   int    `json:"position" validate:"min=0,max=1000"`
+	StartsOn   string `json:"starts_on" validate:"omitempty,datetime=2006-01-02"`
+	EndsOn     string `json:"ends_on" validate:"omitempty,datetime=2006-01-02"`
+	HijriMonth *int   `json:"hijri_month" validate:"omitnil,min=1,max=12"`
+}
+
+type featuredListResponse struct {
+	Items []featuredItem `json:"items"`
+}
+
+// featuredResponse is what is featured on Date, in DAILY_TIMEZONE.
+type featuredResponse struct {
+	Date      string         `json:"date"`
+	HijriDate string         `json:"hijri_date"`
+	Items     []featuredItem `json:"items"`
+}
+
+// experimentVariant is one arm of a ranking experiment. Boosts are rules in
+// SEARCH_BOOSTS syntax that replace the configured ones; "" turns boosting
+// off and leaving it out keeps the configured rules.
+type experimentVariant struct {
+	Name     string  `json:"name" validate:"required,max=50"`
+	Weight   int     `json:"weight" validate:"omitempty,gte=1,lte=1000"`
+	Boosts   *string `json:"boosts,omitempty" validate:"omitnil,max=1000"`
+	MinScore float32 `json:"min_score,omitempty" validate:"gte=0,lte=1"`
+}
+
+type experiment struct {
+	ID        int64               `json:"id"`
+	Name      string              `json:"name"`
+	Variants  []experimentVariant `json:"variants"`
+	Active    bool                `json:"active"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+type experimentRequest struct {
+	Name     string              `json:"name" validate:"required,max=100"`
+	Variants []experimentVariant `json:"variants" validate:"required,min=2,max=5,dive"`
+}
+
+type experimentListResponse struct {
+	Experiments []experiment `json:"experiments"`
+}
+
+// variantMetrics are the recorded searches of a variant and what clients
+// reported about them. ClickThroughRate is the share of searches with at
+// least one click.
+type variantMetrics struct {
+	Variant          string  `json:"variant"`
+	Searches         int64   `json:"searches"`
+	ZeroResults      int64   `json:"zero_results"`
+	Clicks           int64   `json:"clicks"`
+	ClickThroughRate float64 `json:"click_through_rate"`
+	Helpful          int64   `json:"helpful"`
+	Unhelpful        int64   `json:"unhelpful"`
+}
+
+type experimentReport struct {
+	Experiment experiment       `json:"experiment"`
+	Variants   []variantMetrics `json:"variants"`
+}
+
+type searchClickRequest struct {
+	QueryID  string `json:"query_id" validate:"required,max=64"`
+	ResultID string `json:"result_id" validate:"required,max=100"`
+	// Position is the 0-based rank of the result as the user saw it; it
+	// defaults to its rank in the search.
+	Position *int `json:"position" validate:"omitnil,gte=0,lte=1000"`
+}
+
+type searchFeedbackRequest struct {
+	QueryID string `json:"query_id" validate:"required,max=64"`
+	Helpful *bool  `json:"helpful" validate:"required"`
+}
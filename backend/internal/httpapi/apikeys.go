@@ -0,0 +1,242 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+const apiKeyPrefix = "iak_"
+
+// apiKeyIdentity is an authenticated key; its scopes are role names and the
+// highest one applies, and its tier sets search limits. ID is 0 for the
+// bootstrap key from ADMIN_API_KEY.
+type apiKeyIdentity struct {
+	ID     int64
+	Name   string
+	Scopes []string
+	Tier   string
+}
+
+// APIKeyStore authenticates keys against their SHA-256 hashes in api_keys;
+// the plaintext is only ever shown once, when the key is created. Keys
+// belong to the tenant of the context they are created and used in.
+type APIKeyStore struct {
+	db        *pgxpool.Pool
+	bootstrap string
+
+	mu       sync.Mutex
+	verified map[string]cachedAPIKey
+}
+
+// cachedAPIKey is a verify result; identity is nil for refused keys.
+type cachedAPIKey struct {
+	identity *apiKeyIdentity
+	expires  time.Time
+}
+
+func newAPIKeyStore(db *pgxpool.Pool, bootstrap string) *APIKeyStore {
+	return &APIKeyStore{db: db, bootstrap: bootstrap, verified: map[string]cachedAPIKey{}}
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func newAPIKey() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+}
+
+var errAPIKeyInvalid = errors.New("invalid api key")
+
+func (s *APIKeyStore) Authenticate(ctx context.Context, key string) (*apiKeyIdentity, error) {
+	if s.bootstrap != "" && subtle.ConstantTimeCompare([]byte(key), []byte(s.bootstrap)) == 1 {
+		return &apiKeyIdentity{Name: "bootstrap", Scopes: []string{roleAdmin}}, nil
+	}
+	id := &apiKeyIdentity{}
+	err := s.db.QueryRow(ctx, `
+UPDATE api_keys SET last_used_at = now()
+WHERE key_hash = $1 AND revoked_at IS NULL AND tenant_id = $2
+RETURNING id, name, scopes, tier`, hashAPIKey(key), tenant.From(ctx)).Scan(&id.ID, &id.Name, &id.Scopes, &id.Tier)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// verify is Authenticate for per-request checks such as rate limiting,
+// cached for a minute. It returns nil for unknown and revoked keys, and when
+// the lookup fails, so such callers are treated as anonymous.
+func (s *APIKeyStore) verify(ctx context.Context, key string) *apiKeyIdentity {
+	cacheKey := fmt.Sprintf("%d:%s", tenant.From(ctx), hashAPIKey(key))
+	s.mu.Lock()
+	cached, ok := s.verified[cacheKey]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.identity
+	}
+	id, err := s.Authenticate(ctx, key)
+	if err != nil && !errors.Is(err, errAPIKeyInvalid) {
+		slog.ErrorContext(ctx, "api key lookup failed", "error", err)
+		return nil
+	}
+	s.mu.Lock()
+	if len(s.verified) > 10000 {
+		clear(s.verified)
+	}
+	s.verified[cacheKey] = cachedAPIKey{identity: id, expires: time.Now().Add(time.Minute)}
+	s.mu.Unlock()
+	return id
+}
+
+// middleware stores the identity of a valid presented key under "api_key".
+// It refuses nothing: invalid keys are left to requireRole, and until then
+// count as no key at all. It runs after TenantDirectory.middleware, as keys
+// belong to a tenant.
+func (s *APIKeyStore) middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if key := apiKeyFromRequest(c.Request()); key != "" {
+				if id := s.verify(c.Request().Context(), key); id != nil {
+					c.Set("api_key", id)
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// apiKeyFromRequest is the key presented as "X-API-Key: <key>" or as
+// "Authorization: Bearer iak_…"; other bearer tokens are user tokens.
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if bearer := bearerToken(r); strings.HasPrefix(bearer, apiKeyPrefix) {
+		return bearer
+	}
+	return ""
+}
+
+// currentAPIKey is the verified key of the request, or nil.
+func currentAPIKey(c echo.Context) *apiKeyIdentity {
+	id, _ := c.Get("api_key").(*apiKeyIdentity)
+	return id
+}
+
+func (s *APIKeyStore) Create(ctx context.Context, name string, scopes []string, tier string) (apiKey, string, error) {
+	key := newAPIKey()
+	k, err := scanAPIKey(s.db.QueryRow(ctx, `
+INSERT INTO api_keys (name, prefix, key_hash, scopes, tier, tenant_id)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING `+apiKeyColumns, name, key[:len(apiKeyPrefix)+6], hashAPIKey(key), scopes, tier, tenant.From(ctx)))
+	return k, key, err
+}
+
+func (s *APIKeyStore) List(ctx context.Context) ([]apiKey, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE tenant_id = $1 ORDER BY id`, tenant.From(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []apiKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke revokes key id and drops it from the verify cache, so this replica
+// refuses it at once; other replicas may accept it until their cached
+// entry expires, within a minute.
+func (s *APIKeyStore) Revoke(ctx context.Context, id int64) (apiKey, error) {
+	k, err := scanAPIKey(s.db.QueryRow(ctx, `
+UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now())
+WHERE id = $1 AND tenant_id = $2
+RETURNING `+apiKeyColumns, id, tenant.From(ctx)))
+	if err != nil {
+		return k, err
+	}
+	s.mu.Lock()
+	maps.DeleteFunc(s.verified, func(_ string, cached cachedAPIKey) bool {
+		return cached.identity != nil && cached.identity.ID == id
+	})
+	s.mu.Unlock()
+	return k, nil
+}
+
+const apiKeyColumns = `id, name, prefix, scopes, tier, created_at, last_used_at, revoked_at`
+
+func scanAPIKey(row pgx.Row) (apiKey, error) {
+	var k apiKey
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.Scopes, &k.Tier, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt)
+	return k, err
+}
+
+func registerAPIKeyRoutes(admin *echo.Group, deps *AppDependencies) {
+	admin.GET("/keys", func(c echo.Context) error {
+		keys, err := deps.APIKeys.List(c.Request().Context())
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, apiKeyListResponse{Keys: keys})
+	})
+
+	admin.POST("/keys", func(c echo.Context) error {
+		var req apiKeyCreateRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		auditNote(c, "api_key.create", map[string]any{"name": req.Name, "scopes": req.Scopes, "tier": req.Tier})
+		k, key, err := deps.APIKeys.Create(c.Request().Context(), req.Name, req.Scopes, req.Tier)
+		if err != nil {
+			return apierr.Database("db insert api key failed")
+		}
+		auditResource(c, "api_key:"+strconv.FormatInt(k.ID, 10))
+		return c.JSON(http.StatusCreated, apiKeyCreateResponse{Key: k, Secret: key})
+	})
+
+	admin.DELETE("/keys/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		auditNote(c, "api_key.revoke", nil, "api_key:"+c.Param("id"))
+		k, err := deps.APIKeys.Revoke(c.Request().Context(), id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("api key not found")
+		}
+		if err != nil {
+			return apierr.Database("db update api key failed")
+		}
+		return c.JSON(http.StatusOK, k)
+	})
+}
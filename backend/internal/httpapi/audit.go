@@ -0,0 +1,283 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+// AuditLog is the append-only record of admin mutations kept in audit_log.
+// Entries are written after the action has run, whether it succeeded or not;
+// failing to write one is logged but does not fail the request it describes.
+// Listings are read from read, which may be a replica.
+type AuditLog struct {
+	db   *pgxpool.Pool
+	read *pgxpool.Pool
+}
+
+func newAuditLog(db, read *pgxpool.Pool) *AuditLog {
+	return &AuditLog{db: db, read: read}
+}
+
+type auditRecord struct {
+	Actor     *principal
+	Action    string
+	Resources []string
+	Summary   map[string]any
+	Status    int
+	RequestID string
+	RemoteIP  string
+}
+
+func (a *AuditLog) Record(ctx context.Context, r auditRecord) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	actor := r.Actor
+	if actor == nil {
+		actor = &principal{Kind: "unknown"}
+	}
+	var actorID *int64
+	if actor.ID != 0 {
+		actorID = &actor.ID
+	}
+	if r.Resources == nil {
+		r.Resources = []string{}
+	}
+	if r.Summary == nil {
+		r.Summary = map[string]any{}
+	}
+	_, err := a.db.Exec(ctx, `
+INSERT INTO audit_log (actor_kind, actor_id, actor_name, action, resources, summary, status, request_id, remote_ip, tenant_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		actor.Kind, actorID, actor.Name, r.Action, r.Resources, r.Summary, r.Status, postgres.NullString(r.RequestID), postgres.NullString(r.RemoteIP), tenant.From(ctx))
+	if err != nil {
+		slog.ErrorContext(ctx, "audit: record failed", "action", r.Action, "actor_kind", actor.Kind, "actor", actor.Name, "error", err)
+	}
+}
+
+// auditAnnotation is what a handler tells the audit middleware about the
+// action it performed; it is stored in the echo context under "audit".
+type auditAnnotation struct {
+	action    string
+	resources []string
+	summary   map[string]any
+}
+
+// auditNote names the action a handler performs, the resources it touches
+// ("kind:id") and a summary of the request. Secrets must stay out of the
+// summary.
+func auditNote(c echo.Context, action string, summary map[string]any, resources ...string) {
+	c.Set("audit", &auditAnnotation{action: action, resources: resources, summary: summary})
+}
+
+// auditResource adds resources that are only known once the action is done,
+// such as the id of a created row.
+func auditResource(c echo.Context, resources ...string) {
+	if n, ok := c.Get("audit").(*auditAnnotation); ok {
+		n.resources = append(n.resources, resources...)
+	}
+}
+
+func errorStatus(err error) int {
+	var apiErr *apierr.APIError
+	var httpErr *echo.HTTPError
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.As(err, &apiErr):
+		return apiErr.Status
+	case errors.As(err, &httpErr):
+		return httpErr.Code
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// middleware records every non-read request to the routes it guards. Routes
+// whose handler did not call auditNote are recorded as "<method> <route>".
+func (a *AuditLog) middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return next(c)
+			}
+			err := next(c)
+
+			rec := auditRecord{
+				Actor:     currentPrincipal(c),
+				Action:    strings.ToLower(r.Method) + " " + c.Path(),
+				Summary:   map[string]any{"method": r.Method, "path": r.URL.Path},
+				Status:    c.Response().Status,
+				RequestID: requestID(c),
+				RemoteIP:  c.RealIP(),
+			}
+			if n, ok := c.Get("audit").(*auditAnnotation); ok {
+				rec.Action = n.action
+				rec.Resources = n.resources
+				maps.Copy(rec.Summary, n.summary)
+			}
+			if err != nil {
+				rec.Status = errorStatus(err)
+				var apiErr *apierr.APIError
+				if errors.As(err, &apiErr) {
+					rec.Summary["error"] = apiErr.Message
+				}
+			}
+			a.Record(r.Context(), rec)
+			return err
+		}
+	}
+}
+
+func uploadAuditSummary(req *ingest.HadithUploadRequest, resp ingest.UploadResponse) map[string]any {
+	return map[string]any{
+		"collection": req.Collection.Code,
+		"hadiths":    len(req.Hadiths),
+		"inserted":   resp.Inserted,
+		"embedded":   resp.Embedded,
+		"skipped":    resp.Skipped,
+		"errors":     len(resp.Errors),
+		"mismatches": len(resp.LangMismatches),
+	}
+}
+
+type auditCursor struct {
+	ID int64 `json:"id"`
+}
+
+type auditFilter struct {
+	Actor    string
+	Action   string
+	Resource string
+	Since    *time.Time
+	Until    *time.Time
+	Limit    int
+	Cursor   *auditCursor
+}
+
+const auditColumns = `id, created_at, actor_kind, actor_id, actor_name, action, resources, summary, status, request_id, remote_ip`
+
+// Query returns the entries of the tenant of ctx newest first, plus the
+// cursor of the next page.
+func (a *AuditLog) Query(ctx context.Context, f auditFilter) ([]auditEntry, *auditCursor, error) {
+	var where []string
+	args := []any{}
+	add := func(cond string, v any) {
+		args = append(args, v)
+		where = append(where, strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(len(args))))
+	}
+	add("tenant_id = ?", tenant.From(ctx))
+	if f.Actor != "" {
+		add("actor_name = ?", f.Actor)
+	}
+	if f.Action != "" {
+		add("action = ?", f.Action)
+	}
+	if f.Resource != "" {
+		add("? = ANY(resources)", f.Resource)
+	}
+	if f.Since != nil {
+		add("created_at >= ?", *f.Since)
+	}
+	if f.Until != nil {
+		add("created_at < ?", *f.Until)
+	}
+	if f.Cursor != nil {
+		add("id < ?", f.Cursor.ID)
+	}
+	sql := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(where) > 0 {
+		sql += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	args = append(args, f.Limit+1)
+	sql += ` ORDER BY id DESC LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := a.read.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	entries := []auditEntry{}
+	for rows.Next() {
+		var e auditEntry
+		err := rows.Scan(&e.ID, &e.CreatedAt, &e.Actor.Kind, &e.Actor.ID, &e.Actor.Name, &e.Action,
+			&e.Resources, &e.Summary, &e.Status, &e.RequestID, &e.RemoteIP)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	var next *auditCursor
+	if len(entries) > f.Limit {
+		entries = entries[:f.Limit]
+		next = &auditCursor{ID: entries[len(entries)-1].ID}
+	}
+	return entries, next, nil
+}
+
+func parseAuditTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func registerAuditRoutes(admin *echo.Group, deps *AppDependencies) {
+	admin.GET("/audit", func(c echo.Context) error {
+		limit, err := parseLimit(c.QueryParam("limit"), 50, 500)
+		if err != nil {
+			return apierr.InvalidArgument("invalid limit")
+		}
+		f := auditFilter{
+			Actor:    c.QueryParam("actor"),
+			Action:   c.QueryParam("action"),
+			Resource: c.QueryParam("resource"),
+			Limit:    limit,
+		}
+		if f.Since, err = parseAuditTime(c.QueryParam("since")); err != nil {
+			return apierr.InvalidArgument("invalid since, want RFC 3339")
+		}
+		if f.Until, err = parseAuditTime(c.QueryParam("until")); err != nil {
+			return apierr.InvalidArgument("invalid until, want RFC 3339")
+		}
+		if s := c.QueryParam("cursor"); s != "" {
+			f.Cursor = &auditCursor{}
+			if err := decodeCursor(s, f.Cursor); err != nil {
+				return apierr.InvalidArgument("invalid cursor")
+			}
+		}
+
+		entries, next, err := deps.Audit.Query(c.Request().Context(), f)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		resp := auditListResponse{Entries: entries}
+		if next != nil {
+			cursor := encodeCursor(next)
+			resp.NextCursor = &cursor
+		}
+		return c.JSON(http.StatusOK, resp)
+	})
+}
@@ -0,0 +1,146 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+	"github.com/buugaaga/test-cursor/backend/internal/jobs"
+	"github.com/buugaaga/test-cursor/backend/internal/quran"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const jobAyahsDetect = "ayahs.detect"
+
+func registerAyahJobKind(deps *AppDependencies) {
+	deps.Jobs.Register(jobs.Kind{
+		Name:        jobAyahsDetect,
+		MaxAttempts: 3,
+		Backoff:     time.Minute,
+		Timeout:     6 * time.Hour,
+		Lock:        ingest.IndexLock,
+		Work: func(ctx context.Context, j *jobs.Job) (any, error) {
+			return deps.Ingest.DetectAyahs(ctx)
+		},
+	})
+}
+
+func hadithAyahsOf(ctx context.Context, deps *AppDependencies, id int64) (hadithAyahs, error) {
+	out := hadithAyahs{HadithID: id, Ayahs: []hadithAyah{}}
+	rows, err := deps.Postgres.Query(ctx, `SELECT surah, ayah, source FROM hadith_ayah_refs WHERE hadith_id = $1 ORDER BY surah, ayah`, id)
+	if err != nil {
+		return out, err
+	}
+	var a hadithAyah
+	_, err = pgx.ForEachRow(rows, []any{&a.Surah, &a.Ayah, &a.Source}, func() error {
+		out.Ayahs = append(out.Ayahs, a)
+		return nil
+	})
+	return out, err
+}
+
+// registerAyahRoutes links hadiths and the ayahs of the Quran they cite,
+// both ways. Editors set the refs of a hadith; uploads and the ayahs.detect
+// job find the others in the texts.
+func registerAyahRoutes(editor *echo.Group, public *publicAPI, deps *AppDependencies) {
+	public.GET("/v1/hadiths/:id/ayahs", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		if _, err := noteHadith(ctx, deps, id); err != nil {
+			return err
+		}
+		out, err := hadithAyahsOf(ctx, deps, id)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, out)
+	})
+
+	public.GET("/v1/quran/:surah/:ayah/hadiths", func(c echo.Context) error {
+		surah, err1 := strconv.Atoi(c.Param("surah"))
+		ayah, err2 := strconv.Atoi(c.Param("ayah"))
+		ref := quran.Ref{Surah: surah, Ayah: ayah}
+		if err1 != nil || err2 != nil || !ref.Valid() {
+			return apierr.InvalidArgument("invalid ayah")
+		}
+		limit, err := parseLimit(c.QueryParam("limit"), 20, 100)
+		if err != nil {
+			return apierr.InvalidArgument("invalid limit")
+		}
+		var after int64
+		if s := c.QueryParam("cursor"); s != "" {
+			if err := decodeCursor(s, &after); err != nil {
+				return apierr.InvalidArgument("invalid cursor")
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+		defer cancel()
+		version, err := deps.Store.CollectionsVersion(ctx, "")
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		if notModified(c, weakETag("ayah-hadiths", ref.String(), version, c.QueryParams().Encode(), c.Request().Header.Get(echo.HeaderAccept))) {
+			return c.NoContent(http.StatusNotModified)
+		}
+
+		rows, err := deps.Postgres.Query(ctx, `
+SELECT `+postgres.HadithColumns+`
+FROM hadith_ayah_refs r
+JOIN hadiths h ON h.id = r.hadith_id JOIN hadith_collections c ON c.id = h.collection_id
+WHERE r.surah = $1 AND r.ayah = $2 AND h.id > $3 AND `+postgres.Published("h")+` AND `+postgres.TenantWhere("h", 4)+`
+ORDER BY h.id
+LIMIT $5`, ref.Surah, ref.Ayah, after, tenant.From(ctx), limit+1)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		hadiths, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Hadith, error) {
+			return postgres.ScanHadith(row)
+		})
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		resp := hadithListResponse{Hadiths: hadiths}
+		if len(hadiths) > limit {
+			resp.Hadiths = hadiths[:limit]
+			cursor := encodeCursor(hadiths[limit-1].ID)
+			resp.NextCursor = &cursor
+		}
+		return respond(c, http.StatusOK, resp)
+	})
+
+	editor.PUT("/hadiths/:id/ayahs", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		var req hadithAyahsRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		for _, r := range req.Ayahs {
+			if !r.Valid() {
+				return apierr.InvalidArgument("invalid ayah").WithDetails(map[string]any{"ayah": r.String()})
+			}
+		}
+		auditNote(c, "hadith.ayahs", map[string]any{"ayahs": len(req.Ayahs)}, "hadith:"+c.Param("id"))
+		if _, err := deps.Ingest.SetAyahs(ctx, id, req.Ayahs); err != nil {
+			return err
+		}
+		out, err := hadithAyahsOf(ctx, deps, id)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, out)
+	})
+}
@@ -0,0 +1,713 @@
+package httpapi
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/buugaaga/test-cursor/backend/internal/vector"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// A backup is a directory under the configured prefix:
+//
+//	<prefix>/<id>/tenants.ndjson
+//	<prefix>/<id>/collections.ndjson
+//	<prefix>/<id>/hadiths.ndjson
+//	<prefix>/<id>/ayah_refs.ndjson
+//	<prefix>/<id>/postgres.dump
+//	<prefix>/<id>/qdrant/<collection>.snapshot
+//	<prefix>/<id>/manifest.json
+//
+// The manifest is written last, so a backup without one is incomplete and
+// cannot be restored. Backups cover every tenant, so only the default
+// tenant's admins take and restore them.
+
+type BackupStore struct {
+	S3     *minio.Client
+	Bucket string
+	Prefix string
+	// PGDump, when set, is run against DSN to add postgres.dump.
+	PGDump string
+	DSN    string
+}
+
+type backupCollection struct {
+	ID    int64  `json:"id"`
+	Code  string `json:"code"`
+	Title string `json:"title"`
+	// TenantID is 0 in backups taken before tenants, restored as the
+	// default tenant's.
+	TenantID int64 `json:"tenant_id,omitempty"`
+}
+
+type backupHadith struct {
+	ID           int64      `json:"id"`
+	CollectionID int64      `json:"collection_id"`
+	Number       string     `json:"number"`
+	Title        *string    `json:"title,omitempty"`
+	TextAr       *string    `json:"text_ar,omitempty"`
+	TextRu       *string    `json:"text_ru,omitempty"`
+	TextEn       *string    `json:"text_en,omitempty"`
+	TextTranslit *string    `json:"text_translit,omitempty"`
+	Grade        *string    `json:"grade,omitempty"`
+	Topics       []string   `json:"topics,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+	PublishAt    *time.Time `json:"publish_at,omitempty"`
+	TenantID     int64      `json:"tenant_id,omitempty"`
+	Book         *int       `json:"book,omitempty"`
+	BookTitle    *string    `json:"book_title,omitempty"`
+	Chapter      *int       `json:"chapter,omitempty"`
+	ChapterTitle *string    `json:"chapter_title,omitempty"`
+	Sequence     *int       `json:"sequence,omitempty"`
+	// DetectedLangs and LangMismatch are empty in backups taken before they
+	// were backed up, as for hadiths uploaded before detection.
+	DetectedLangs map[string]string `json:"detected_langs,omitempty"`
+	LangMismatch  bool              `json:"lang_mismatch,omitempty"`
+}
+
+type backupAyahRef struct {
+	HadithID  int64     `json:"hadith_id"`
+	Surah     int       `json:"surah"`
+	Ayah      int       `json:"ayah"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// backupHadithColumns are the columns of backupHadith, in field order.
+const backupHadithColumns = "id, collection_id, number, title, text_ar, text_ru, text_en, text_translit, grade, topics, created_at, updated_at, publish_at, tenant_id, book, book_title, chapter, chapter_title, sequence, detected_langs, lang_mismatch"
+
+func initBackupStore(endpoint, accessKey, secretKey, bucket, prefix string, useSSL bool) (*BackupStore, error) {
+	if endpoint == "" {
+		return nil, nil
+	}
+	if bucket == "" {
+		return nil, errors.New("S3_BUCKET is required when S3_ENDPOINT is set")
+	}
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &BackupStore{S3: client, Bucket: bucket, Prefix: strings.Trim(prefix, "/")}, nil
+}
+
+func (b *BackupStore) key(id string, name string) string {
+	return path.Join(b.Prefix, id, name)
+}
+
+func (b *BackupStore) putJSONLines(ctx context.Context, key string, write func(enc *json.Encoder) error) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(write(json.NewEncoder(pw)))
+	}()
+	_, err := b.S3.PutObject(ctx, b.Bucket, key, pr, -1, minio.PutObjectOptions{ContentType: "application/x-ndjson"})
+	pr.CloseWithError(err)
+	return err
+}
+
+func (b *BackupStore) List(ctx context.Context) ([]backupManifest, error) {
+	manifests := []backupManifest{}
+	for obj := range b.S3.ListObjects(ctx, b.Bucket, minio.ListObjectsOptions{Prefix: b.Prefix + "/", Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		if path.Base(obj.Key) != "manifest.json" {
+			continue
+		}
+		m, err := b.manifest(ctx, path.Base(path.Dir(obj.Key)))
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+	sort.Slice(manifests, func(i, j int) bool { return manifests[i].CreatedAt.After(manifests[j].CreatedAt) })
+	return manifests, nil
+}
+
+func (b *BackupStore) manifest(ctx context.Context, id string) (backupManifest, error) {
+	var m backupManifest
+	obj, err := b.S3.GetObject(ctx, b.Bucket, b.key(id, "manifest.json"), minio.GetObjectOptions{})
+	if err != nil {
+		return m, err
+	}
+	defer obj.Close()
+	err = json.NewDecoder(obj).Decode(&m)
+	return m, err
+}
+
+func createBackup(ctx context.Context, deps *AppDependencies) (backupManifest, error) {
+	b := deps.Backups
+	m := backupManifest{
+		ID:        time.Now().UTC().Format("20060102T150405Z"),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	err := b.putJSONLines(ctx, b.key(m.ID, "tenants.ndjson"), func(enc *json.Encoder) error {
+		rows, err := deps.Postgres.Query(ctx, `SELECT `+tenantColumns+` FROM tenants ORDER BY id`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			t, err := scanTenant(rows)
+			if err != nil {
+				return err
+			}
+			if err := enc.Encode(t); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return m, fmt.Errorf("export tenants: %w", err)
+	}
+
+	err = b.putJSONLines(ctx, b.key(m.ID, "collections.ndjson"), func(enc *json.Encoder) error {
+		rows, err := deps.Postgres.Query(ctx, `SELECT id, code, title, tenant_id FROM hadith_collections ORDER BY id`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var c backupCollection
+			if err := rows.Scan(&c.ID, &c.Code, &c.Title, &c.TenantID); err != nil {
+				return err
+			}
+			if err := enc.Encode(c); err != nil {
+				return err
+			}
+			m.Collections++
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return m, fmt.Errorf("export collections: %w", err)
+	}
+
+	err = b.putJSONLines(ctx, b.key(m.ID, "hadiths.ndjson"), func(enc *json.Encoder) error {
+		rows, err := deps.Postgres.Query(ctx, `SELECT `+backupHadithColumns+` FROM hadiths ORDER BY id`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var h backupHadith
+			if err := rows.Scan(&h.ID, &h.CollectionID, &h.Number, &h.Title, &h.TextAr, &h.TextRu, &h.TextEn, &h.TextTranslit, &h.Grade, &h.Topics, &h.CreatedAt, &h.UpdatedAt, &h.PublishAt, &h.TenantID, &h.Book, &h.BookTitle, &h.Chapter, &h.ChapterTitle, &h.Sequence, &h.DetectedLangs, &h.LangMismatch); err != nil {
+				return err
+			}
+			if err := enc.Encode(h); err != nil {
+				return err
+			}
+			m.Hadiths++
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return m, fmt.Errorf("export hadiths: %w", err)
+	}
+
+	m.AyahRefs = "ayah_refs.ndjson"
+	err = b.putJSONLines(ctx, b.key(m.ID, m.AyahRefs), func(enc *json.Encoder) error {
+		rows, err := deps.Postgres.Query(ctx, `SELECT hadith_id, surah, ayah, source, created_at FROM hadith_ayah_refs ORDER BY hadith_id, surah, ayah`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var r backupAyahRef
+			if err := rows.Scan(&r.HadithID, &r.Surah, &r.Ayah, &r.Source, &r.CreatedAt); err != nil {
+				return err
+			}
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return m, fmt.Errorf("export ayah refs: %w", err)
+	}
+
+	if b.PGDump != "" {
+		m.PostgresDump = "postgres.dump"
+		if err := b.dumpPostgres(ctx, b.key(m.ID, m.PostgresDump)); err != nil {
+			return m, fmt.Errorf("pg_dump: %w", err)
+		}
+	}
+
+	// With pgvector there is no snapshot; restores rebuild the vectors.
+	if deps.Qdrant != nil {
+		m.QdrantCollection = vector.Collection
+		m.EmbeddingModel = deps.EmbeddingModel.Name
+		snap, err := deps.Qdrant.CreateSnapshot(ctx, m.QdrantCollection)
+		if err != nil {
+			return m, fmt.Errorf("qdrant snapshot: %w", err)
+		}
+		m.QdrantSnapshot = snap.GetName()
+		err = copyQdrantSnapshotToS3(ctx, deps, m.QdrantCollection, m.QdrantSnapshot, b.key(m.ID, "qdrant/"+m.QdrantCollection+".snapshot"))
+		if delErr := deps.Qdrant.DeleteSnapshot(ctx, m.QdrantCollection, m.QdrantSnapshot); delErr != nil && err == nil {
+			err = delErr
+		}
+		if err != nil {
+			return m, fmt.Errorf("store qdrant snapshot: %w", err)
+		}
+	}
+
+	body, _ := json.Marshal(m)
+	_, err = b.S3.PutObject(ctx, b.Bucket, b.key(m.ID, "manifest.json"), strings.NewReader(string(body)), int64(len(body)),
+		minio.PutObjectOptions{ContentType: "application/json"})
+	if err != nil {
+		return m, fmt.Errorf("write manifest: %w", err)
+	}
+	return m, nil
+}
+
+// dumpPostgres streams a custom-format pg_dump of the database to key.
+func (b *BackupStore) dumpPostgres(ctx context.Context, key string) error {
+	cmd := exec.CommandContext(ctx, b.PGDump, "--format=custom", "--no-owner", "--no-privileges", "--dbname="+b.DSN)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	_, err = b.S3.PutObject(ctx, b.Bucket, key, out, -1, minio.PutObjectOptions{ContentType: "application/octet-stream"})
+	if err != nil {
+		cmd.Process.Kill()
+	}
+	if werr := cmd.Wait(); werr != nil && err == nil {
+		err = fmt.Errorf("%w: %s", werr, strings.TrimSpace(stderr.String()))
+	}
+	return err
+}
+
+// writeArchive writes the files of backup id to w as a tar archive,
+// manifest.json first.
+func (b *BackupStore) writeArchive(ctx context.Context, id string, w io.Writer) error {
+	dir := b.key(id, "") + "/"
+	var objects []minio.ObjectInfo
+	for obj := range b.S3.ListObjects(ctx, b.Bucket, minio.ListObjectsOptions{Prefix: dir, Recursive: true}) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		objects = append(objects, obj)
+	}
+	sort.SliceStable(objects, func(i, j int) bool { return objects[i].Key == dir+"manifest.json" })
+
+	tw := tar.NewWriter(w)
+	for _, obj := range objects {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    path.Join(id, strings.TrimPrefix(obj.Key, dir)),
+			Mode:    0o644,
+			Size:    obj.Size,
+			ModTime: obj.LastModified,
+		})
+		if err != nil {
+			return err
+		}
+		body, err := b.S3.GetObject(ctx, b.Bucket, obj.Key, minio.GetObjectOptions{})
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, body)
+		body.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// setQdrantAPIKey authenticates calls to Qdrant's REST API, which the
+// snapshot transfers use instead of gRPC.
+func setQdrantAPIKey(req *http.Request, deps *AppDependencies) {
+	if deps.QdrantAPIKey != "" {
+		req.Header.Set("api-key", deps.QdrantAPIKey)
+	}
+}
+
+func copyQdrantSnapshotToS3(ctx context.Context, deps *AppDependencies, collection, snapshot, key string) error {
+	u := fmt.Sprintf("%s/collections/%s/snapshots/%s", deps.QdrantHTTPURL, url.PathEscape(collection), url.PathEscape(snapshot))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	setQdrantAPIKey(req, deps)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("qdrant snapshot download status %d", resp.StatusCode)
+	}
+	_, err = deps.Backups.S3.PutObject(ctx, deps.Backups.Bucket, key, resp.Body, resp.ContentLength,
+		minio.PutObjectOptions{ContentType: "application/octet-stream"})
+	return err
+}
+
+// restoreBackup replaces the hadiths with backup id's and reports whether
+// their vectors came from its Qdrant snapshot, or are being rebuilt.
+func restoreBackup(ctx context.Context, deps *AppDependencies, id string) (backupManifest, bool, error) {
+	ctx = tenant.Unscoped(ctx)
+	b := deps.Backups
+	m, err := b.manifest(ctx, id)
+	if err != nil {
+		return m, false, fmt.Errorf("read manifest: %w", err)
+	}
+
+	tx, err := deps.Postgres.Begin(ctx)
+	if err != nil {
+		return m, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	// The content is staged and then replaced in place rather than
+	// truncated, which the tables referring to hadiths would refuse: the
+	// bookmarks, notes, reading lists and other rows of hadiths the backup
+	// has are kept, and only those of hadiths it has not go with them.
+	if _, err := tx.Exec(ctx, `
+CREATE TEMP TABLE restore_collections ON COMMIT DROP AS
+  SELECT id, code, title, tenant_id FROM hadith_collections WITH NO DATA;
+CREATE TEMP TABLE restore_hadiths ON COMMIT DROP AS
+  SELECT `+backupHadithColumns+`, translit_norm FROM hadiths WITH NO DATA`); err != nil {
+		return m, false, err
+	}
+
+	// Tenants created since the backup are kept; those deleted since are
+	// created again. Backups from before tenants have no tenants.ndjson.
+	err = b.readJSONLines(ctx, b.key(id, "tenants.ndjson"), func(dec *json.Decoder) error {
+		var t tenantInfo
+		if err := dec.Decode(&t); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `INSERT INTO tenants (id, code, name, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`, t.ID, t.Code, t.Name, t.CreatedAt)
+		return err
+	})
+	if err != nil {
+		return m, false, fmt.Errorf("restore tenants: %w", err)
+	}
+
+	var collections [][]any
+	err = b.readJSONLines(ctx, b.key(id, "collections.ndjson"), func(dec *json.Decoder) error {
+		var c backupCollection
+		if err := dec.Decode(&c); err != nil {
+			return err
+		}
+		collections = append(collections, []any{c.ID, c.Code, c.Title, cmp.Or(c.TenantID, tenant.Default)})
+		return nil
+	})
+	if err != nil {
+		return m, false, fmt.Errorf("read collections: %w", err)
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"restore_collections"}, []string{"id", "code", "title", "tenant_id"}, pgx.CopyFromRows(collections)); err != nil {
+		return m, false, fmt.Errorf("restore collections: %w", err)
+	}
+
+	// Backups taken before created_at/updated_at existed restore as if added
+	// when the backup was taken, not now, so they stay out of the feeds of
+	// recent hadiths.
+	takenAt := m.CreatedAt
+	var hadiths [][]any
+	err = b.readJSONLines(ctx, b.key(id, "hadiths.ndjson"), func(dec *json.Decoder) error {
+		h := backupHadith{CreatedAt: &takenAt, UpdatedAt: &takenAt, DetectedLangs: map[string]string{}}
+		if err := dec.Decode(&h); err != nil {
+			return err
+		}
+		// translit_norm is not backed up: it is derived again, with the
+		// spellings of the running version.
+		var norm *string
+		if h.TextTranslit != nil {
+			n := store.NormalizeTranslit(*h.TextTranslit)
+			norm = &n
+		}
+		hadiths = append(hadiths, []any{h.ID, h.CollectionID, h.Number, h.Title, h.TextAr, h.TextRu, h.TextEn, h.TextTranslit, h.Grade, h.Topics, h.CreatedAt, h.UpdatedAt, h.PublishAt, cmp.Or(h.TenantID, tenant.Default), h.Book, h.BookTitle, h.Chapter, h.ChapterTitle, h.Sequence, h.DetectedLangs, h.LangMismatch, norm})
+		return nil
+	})
+	if err != nil {
+		return m, false, fmt.Errorf("read hadiths: %w", err)
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"restore_hadiths"},
+		append(strings.Split(backupHadithColumns, ", "), "translit_norm"),
+		pgx.CopyFromRows(hadiths)); err != nil {
+		return m, false, fmt.Errorf("restore hadiths: %w", err)
+	}
+	if err := replaceContent(ctx, tx); err != nil {
+		return m, false, fmt.Errorf("restore hadiths: %w", err)
+	}
+
+	if m.AyahRefs != "" {
+		var refs [][]any
+		err = b.readJSONLines(ctx, b.key(id, m.AyahRefs), func(dec *json.Decoder) error {
+			var r backupAyahRef
+			if err := dec.Decode(&r); err != nil {
+				return err
+			}
+			refs = append(refs, []any{r.HadithID, r.Surah, r.Ayah, r.Source, r.CreatedAt})
+			return nil
+		})
+		if err != nil {
+			return m, false, fmt.Errorf("read ayah refs: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM hadith_ayah_refs`); err != nil {
+			return m, false, fmt.Errorf("restore ayah refs: %w", err)
+		}
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"hadith_ayah_refs"}, []string{"hadith_id", "surah", "ayah", "source", "created_at"}, pgx.CopyFromRows(refs)); err != nil {
+			return m, false, fmt.Errorf("restore ayah refs: %w", err)
+		}
+	}
+
+	if err := resetSequences(ctx, tx); err != nil {
+		return m, false, err
+	}
+
+	if !restoresSnapshot(deps, m) {
+		if err := reindexRestored(ctx, deps, tx); err != nil {
+			return m, false, err
+		}
+		return m, false, tx.Commit(ctx)
+	}
+	// Postgres commits first, so a failed commit cannot leave Qdrant with
+	// the backup's vectors for the old hadiths; a snapshot that then fails
+	// to load is replaced by a reindex.
+	if err := tx.Commit(ctx); err != nil {
+		return m, false, err
+	}
+	if err := uploadQdrantSnapshotFromS3(ctx, deps, m.QdrantCollection, b.key(id, "qdrant/"+m.QdrantCollection+".snapshot")); err != nil {
+		slog.ErrorContext(ctx, "restore qdrant snapshot failed, reindexing", "backup_id", id, "error", err)
+		tx, err := deps.Postgres.Begin(ctx)
+		if err != nil {
+			return m, false, err
+		}
+		defer tx.Rollback(ctx)
+		if err := reindexRestored(ctx, deps, tx); err != nil {
+			return m, false, err
+		}
+		return m, false, tx.Commit(ctx)
+	}
+	return m, true, nil
+}
+
+// replaceContent makes hadith_collections and hadiths those staged in
+// restore_collections and restore_hadiths. A collection whose code or tenant
+// differs from the backup's is deleted and created again, as the codes of a
+// tenant are unique.
+func replaceContent(ctx context.Context, tx pgx.Tx) error {
+	var set []string
+	for _, col := range strings.Split(backupHadithColumns, ", ")[1:] {
+		set = append(set, col+" = EXCLUDED."+col)
+	}
+	_, err := tx.Exec(ctx, `
+DELETE FROM hadiths h WHERE NOT EXISTS (SELECT 1 FROM restore_hadiths r WHERE r.id = h.id);
+DELETE FROM hadith_collections c
+WHERE NOT EXISTS (SELECT 1 FROM restore_collections r WHERE r.id = c.id AND r.code = c.code AND r.tenant_id = c.tenant_id);
+INSERT INTO hadith_collections (id, code, title, tenant_id)
+SELECT id, code, title, tenant_id FROM restore_collections
+ON CONFLICT (id) DO UPDATE SET title = EXCLUDED.title;
+INSERT INTO hadiths (`+backupHadithColumns+`, translit_norm)
+SELECT `+backupHadithColumns+`, translit_norm FROM restore_hadiths
+ON CONFLICT (id) DO UPDATE SET `+strings.Join(set, ", ")+`, translit_norm = EXCLUDED.translit_norm`)
+	return err
+}
+
+// reindexRestored has the restored hadiths' vectors rebuilt by the index
+// outbox once tx commits.
+func reindexRestored(ctx context.Context, deps *AppDependencies, tx pgx.Tx) error {
+	if err := ingest.QueueReindexAll(ctx, tx); err != nil {
+		return fmt.Errorf("queue reindex: %w", err)
+	}
+	if err := deps.Vectors.Delete(ctx, &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatch("origin_type", "hadith")}}); err != nil {
+		return fmt.Errorf("clear vectors: %w", err)
+	}
+	return nil
+}
+
+// restoresSnapshot reports whether restoring m restores a Qdrant snapshot.
+// Otherwise, when the backup has none, its vectors are another embedding
+// model's or vectors are kept by pgvector, the index is rebuilt from the
+// restored hadiths.
+func restoresSnapshot(deps *AppDependencies, m backupManifest) bool {
+	sameModel := m.EmbeddingModel == "" || m.EmbeddingModel == deps.EmbeddingModel.Name
+	return deps.Qdrant != nil && m.QdrantSnapshot != "" && sameModel
+}
+
+func resetSequences(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `
+SELECT setval(pg_get_serial_sequence('hadith_collections', 'id'), COALESCE((SELECT MAX(id) FROM hadith_collections), 0) + 1, false);
+SELECT setval(pg_get_serial_sequence('hadiths', 'id'), COALESCE((SELECT MAX(id) FROM hadiths), 0) + 1, false);
+SELECT setval(pg_get_serial_sequence('tenants', 'id'), COALESCE((SELECT MAX(id) FROM tenants), 0) + 1, false);
+`)
+	return err
+}
+
+func (b *BackupStore) readJSONLines(ctx context.Context, key string, read func(dec *json.Decoder) error) error {
+	obj, err := b.S3.GetObject(ctx, b.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	dec := json.NewDecoder(obj)
+	for dec.More() {
+		if err := read(dec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func uploadQdrantSnapshotFromS3(ctx context.Context, deps *AppDependencies, collection, key string) error {
+	obj, err := deps.Backups.S3.GetObject(ctx, deps.Backups.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		part, err := mw.CreateFormFile("snapshot", path.Base(key))
+		if err == nil {
+			_, err = io.Copy(part, obj)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	u := fmt.Sprintf("%s/collections/%s/snapshots/upload?priority=snapshot&wait=true", deps.QdrantHTTPURL, url.PathEscape(collection))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, pr)
+	if err != nil {
+		pr.CloseWithError(err)
+		return err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	setQdrantAPIKey(req, deps)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		pr.CloseWithError(err)
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("qdrant snapshot upload status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func registerBackupRoutes(admin *echo.Group, deps *AppDependencies) {
+	backupsConfigured := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if deps.Backups == nil {
+				return apierr.New(http.StatusServiceUnavailable, apierr.CodeNotConfigured, "backups are not configured")
+			}
+			return next(c)
+		}
+	}
+
+	g := admin.Group("/backups", backupsConfigured, requireDefaultTenant)
+
+	g.GET("", func(c echo.Context) error {
+		manifests, err := deps.Backups.List(c.Request().Context())
+		if err != nil {
+			return apierr.New(http.StatusBadGateway, apierr.CodeStorageFailed, "list backups failed")
+		}
+		return c.JSON(http.StatusOK, backupListResponse{Backups: manifests})
+	})
+
+	g.POST("", func(c echo.Context) error {
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Minute)
+		defer cancel()
+
+		m, err := createBackup(ctx, deps)
+		auditNote(c, "backup.create", map[string]any{"hadiths": m.Hadiths}, "backup:"+m.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "backup failed", "backup_id", m.ID, "error", err)
+			return apierr.New(http.StatusBadGateway, apierr.CodeStorageFailed, "backup failed")
+		}
+		return c.JSON(http.StatusOK, m)
+	})
+
+	g.GET("/:id/archive", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id := c.Param("id")
+		if _, err := deps.Backups.manifest(ctx, id); err != nil {
+			if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+				return apierr.NotFound("backup not found")
+			}
+			return apierr.New(http.StatusBadGateway, apierr.CodeStorageFailed, "read backup failed")
+		}
+		auditNote(c, "backup.download", nil, "backup:"+id)
+		res := c.Response()
+		res.Header().Set(echo.HeaderContentType, "application/x-tar")
+		res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="backup-%s.tar"`, id))
+		res.WriteHeader(http.StatusOK)
+		// Headers are sent; a failure can only cut the archive short.
+		if err := deps.Backups.writeArchive(ctx, id, res); err != nil {
+			slog.ErrorContext(ctx, "backup download failed", "backup_id", id, "error", err)
+		}
+		return nil
+	})
+
+	g.POST("/:id/restore", func(c echo.Context) error {
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Minute)
+		defer cancel()
+
+		auditNote(c, "backup.restore", nil, "backup:"+c.Param("id"))
+		lock, err := postgres.TryLock(ctx, deps.Postgres, ingest.IndexLock)
+		if err != nil {
+			return apierr.Database("db lock failed")
+		}
+		if lock == nil {
+			return apierr.New(http.StatusConflict, apierr.CodeConflict, "a reindex, backfill or restore is running")
+		}
+		defer lock.Release()
+		m, snapshot, err := restoreBackup(ctx, deps, c.Param("id"))
+		contentChanged(context.WithoutCancel(ctx), deps)
+		if err != nil {
+			slog.ErrorContext(ctx, "restore failed", "backup_id", c.Param("id"), "error", err)
+			return apierr.New(http.StatusBadGateway, apierr.CodeStorageFailed, "restore failed")
+		}
+		if snapshot {
+			deps.Webhooks.Publish(c.Request().Context(), eventReindexCompleted, map[string]any{
+				"source":     "backup_restore",
+				"backup_id":  m.ID,
+				"collection": m.QdrantCollection,
+				"hadiths":    m.Hadiths,
+			})
+			auditResource(c, "qdrant_collection:"+m.QdrantCollection)
+		}
+		return c.JSON(http.StatusOK, m)
+	})
+}
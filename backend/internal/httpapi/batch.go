@@ -0,0 +1,125 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/i18n"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/sync/errgroup"
+)
+
+// batchConcurrency is how many sub-requests of a batch run at once.
+const batchConcurrency = 4
+
+// batchPOSTs are the POST routes a batch may call; they only read.
+var batchPOSTs = map[string]bool{"/v1/search": true, "/v1/hadiths/batch-get": true}
+
+// batchExcluded are path prefixes a batch may not GET: routes that stream,
+// redirect or change state.
+var batchExcluded = []string{"/v1/batch", "/v1/ws", "/v1/auth/", "/v1/exports/", "/v1/subscriptions/"}
+
+// batchHeaders are the response headers passed on for a sub-request.
+var batchHeaders = []string{"ETag", echo.HeaderRetryAfter, echo.HeaderLocation}
+
+func batchAllowed(method, path string) bool {
+	if method == http.MethodPost {
+		return batchPOSTs[path]
+	}
+	for _, p := range batchExcluded {
+		if path == strings.TrimSuffix(p, "/") || strings.HasPrefix(path, p) {
+			return false
+		}
+	}
+	return strings.HasPrefix(path, "/v1/")
+}
+
+// batchRecorder buffers a sub-request's response.
+type batchRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *batchRecorder) Header() http.Header         { return r.header }
+func (r *batchRecorder) Write(b []byte) (int, error) { return r.body.Write(b) }
+func (r *batchRecorder) WriteHeader(status int)      { r.status = status }
+
+// result turns the response into a batchResult; bodies that are not JSON,
+// such as Atom feeds, become strings.
+func (r *batchRecorder) result(id string) batchResult {
+	res := batchResult{ID: id, Status: r.status}
+	if res.Status == 0 {
+		res.Status = http.StatusOK
+	}
+	for _, h := range batchHeaders {
+		if v := r.header.Get(h); v != "" {
+			if res.Headers == nil {
+				res.Headers = map[string]string{}
+			}
+			res.Headers[h] = v
+		}
+	}
+	switch body := r.body.Bytes(); {
+	case len(body) == 0:
+	case json.Valid(body):
+		res.Body = json.RawMessage(body)
+	default:
+		res.Body, _ = json.Marshal(string(body))
+	}
+	return res
+}
+
+// registerBatchRoute serves POST /v1/batch: independent sub-requests run
+// through the router, middleware included, with the batch's credentials,
+// tenant and language, and each reports its own status and body, so that
+// one failing does not fail the others.
+func registerBatchRoute(e *echo.Echo) {
+	e.POST("/v1/batch", func(c echo.Context) error {
+		var req batchRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		parent := c.Request()
+		resp := batchResponse{Results: make([]batchResult, len(req.Requests))}
+		var g errgroup.Group
+		g.SetLimit(batchConcurrency)
+		for i, item := range req.Requests {
+			u, err := url.ParseRequestURI(item.Path)
+			if err != nil || !batchAllowed(item.Method, u.Path) {
+				apiErr := apierr.InvalidArgument("request is not allowed in a batch")
+				if lang := i18n.From(parent.Context()); lang != i18n.Default {
+					apiErr = apiErr.Localize(lang)
+				}
+				body, _ := json.Marshal(apiErr)
+				resp.Results[i] = batchResult{ID: item.ID, Status: apiErr.Status, Body: body}
+				continue
+			}
+			g.Go(func() error {
+				sub, err := http.NewRequestWithContext(parent.Context(), item.Method, u.RequestURI(), bytes.NewReader(item.Body))
+				if err != nil {
+					return err
+				}
+				sub.Header = parent.Header.Clone()
+				for _, h := range []string{echo.HeaderContentLength, echo.HeaderAcceptEncoding, echo.HeaderContentEncoding, "If-None-Match", echo.HeaderIfModifiedSince} {
+					sub.Header.Del(h)
+				}
+				sub.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+				sub.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+				sub.RemoteAddr = parent.RemoteAddr
+				rec := &batchRecorder{header: http.Header{}}
+				e.ServeHTTP(rec, sub)
+				resp.Results[i] = rec.result(item.ID)
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, resp)
+	})
+}
@@ -0,0 +1,28 @@
+package httpapi
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// bodyLimits caps request bodies per route: uploads get the largest cap,
+// search the smallest, everything else the default. Limits use
+// middleware.BodyLimit syntax ("64K", "32M").
+func bodyLimits(search, upload, def string) echo.MiddlewareFunc {
+	searchLimit := middleware.BodyLimit(search)
+	uploadLimit := middleware.BodyLimit(upload)
+	defaultLimit := middleware.BodyLimit(def)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		search, upload, def := searchLimit(next), uploadLimit(next), defaultLimit(next)
+		return func(c echo.Context) error {
+			switch path := c.Path(); {
+			case path == "/v1/admin/hadiths/upload":
+				return upload(c)
+			case routeGroup(path) == "search":
+				return search(c)
+			default:
+				return def(c)
+			}
+		}
+	}
+}
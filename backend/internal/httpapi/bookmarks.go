@@ -0,0 +1,316 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+)
+
+const bookmarkColumns = `id, hadith_id, surah, ayah, folder_id, note, created_at`
+
+func scanBookmark(row pgx.Row) (bookmark, error) {
+	var b bookmark
+	err := row.Scan(&b.ID, &b.HadithID, &b.Surah, &b.Ayah, &b.FolderID, &b.Note, &b.CreatedAt)
+	b.Kind = "ayah"
+	if b.HadithID != nil {
+		b.Kind = "hadith"
+	}
+	return b, err
+}
+
+// hadithsByID looks up the hadiths of ids that exist.
+func hadithsByID(ctx context.Context, deps *AppDependencies, ids []int64) (map[int64]*Hadith, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	hadiths, _, err := deps.Store.Hadiths(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*Hadith, len(hadiths))
+	for i := range hadiths {
+		byID[hadiths[i].ID] = &hadiths[i]
+	}
+	return byID, nil
+}
+
+// attachHadiths fills in the hadith of each hadith bookmark.
+func attachHadiths(ctx context.Context, deps *AppDependencies, bookmarks []bookmark) error {
+	var ids []int64
+	for _, b := range bookmarks {
+		if b.HadithID != nil {
+			ids = append(ids, *b.HadithID)
+		}
+	}
+	byID, err := hadithsByID(ctx, deps, ids)
+	if err != nil {
+		return err
+	}
+	for i, b := range bookmarks {
+		if b.HadithID != nil {
+			bookmarks[i].Hadith = byID[*b.HadithID]
+		}
+	}
+	return nil
+}
+
+// ownFolder reports whether folder id belongs to user.
+func ownFolder(ctx context.Context, deps *AppDependencies, user, id int64) (bool, error) {
+	var ok bool
+	err := deps.Postgres.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM bookmark_folders WHERE id = $1 AND user_id = $2)`, id, user).Scan(&ok)
+	return ok, err
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
+
+// registerBookmarkRoutes serves the authenticated user's bookmarks and
+// bookmark folders under /v1/me.
+func registerBookmarkRoutes(me *echo.Group, deps *AppDependencies) {
+	me.GET("/bookmarks", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		limit, err := parseLimit(c.QueryParam("limit"), 50, 200)
+		if err != nil {
+			return apierr.InvalidArgument("invalid limit")
+		}
+		kind := c.QueryParam("kind")
+		if kind != "" && kind != "hadith" && kind != "ayah" {
+			return apierr.InvalidArgument("invalid kind, want hadith or ayah")
+		}
+		// folder_id=0 lists the bookmarks outside any folder.
+		folder := int64(-1)
+		if s := c.QueryParam("folder_id"); s != "" {
+			if folder, err = strconv.ParseInt(s, 10, 64); err != nil || folder < 0 {
+				return apierr.InvalidArgument("invalid folder_id")
+			}
+		}
+		var before int64
+		if s := c.QueryParam("cursor"); s != "" {
+			var cur auditCursor
+			if err := decodeCursor(s, &cur); err != nil {
+				return apierr.InvalidArgument("invalid cursor")
+			}
+			before = cur.ID
+		}
+
+		rows, err := deps.Postgres.Query(ctx, `
+SELECT `+bookmarkColumns+` FROM bookmarks
+WHERE user_id = $1
+  AND ($2 = '' OR ($2 = 'hadith') = (hadith_id IS NOT NULL))
+  AND ($3 < 0 OR COALESCE(folder_id, 0) = $3)
+  AND ($4 = 0 OR id < $4)
+ORDER BY id DESC
+LIMIT $5`, currentUser(c).UserID(), kind, folder, before, limit+1)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		list, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (bookmark, error) { return scanBookmark(row) })
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		resp := bookmarkListResponse{Bookmarks: list}
+		if len(list) > limit {
+			resp.Bookmarks = list[:limit]
+			cursor := encodeCursor(auditCursor{ID: list[limit-1].ID})
+			resp.NextCursor = &cursor
+		}
+		if err := attachHadiths(ctx, deps, resp.Bookmarks); err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, resp)
+	})
+
+	me.POST("/bookmarks", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		var req bookmarkCreateRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		user := currentUser(c).UserID()
+		var h *Hadith
+		if req.HadithID != 0 {
+			found, err := deps.Store.Hadith(ctx, req.HadithID)
+			if errors.Is(err, store.ErrNotFound) {
+				return apierr.NotFound("hadith not found")
+			}
+			if err != nil {
+				return apierr.Database("db query failed")
+			}
+			h = &found
+		}
+		if req.FolderID != 0 {
+			ok, err := ownFolder(ctx, deps, user, req.FolderID)
+			if err != nil {
+				return apierr.Database("db query failed")
+			}
+			if !ok {
+				return apierr.NotFound("folder not found")
+			}
+		}
+		b, err := scanBookmark(deps.Postgres.QueryRow(ctx, `
+INSERT INTO bookmarks (user_id, hadith_id, surah, ayah, folder_id, note)
+VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), NULLIF($4, 0), NULLIF($5, 0), NULLIF($6, ''))
+RETURNING `+bookmarkColumns, user, req.HadithID, req.Surah, req.Ayah, req.FolderID, strings.TrimSpace(req.Note)))
+		if isUniqueViolation(err) {
+			return apierr.New(http.StatusConflict, apierr.CodeAlreadyExists, "already bookmarked")
+		}
+		if err != nil {
+			return apierr.Database("db insert bookmark failed")
+		}
+		b.Hadith = h
+		return c.JSON(http.StatusCreated, b)
+	})
+
+	me.PATCH("/bookmarks/:id", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		var req bookmarkUpdate
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		user := currentUser(c).UserID()
+		if req.FolderID != nil && *req.FolderID != 0 {
+			ok, err := ownFolder(ctx, deps, user, *req.FolderID)
+			if err != nil {
+				return apierr.Database("db query failed")
+			}
+			if !ok {
+				return apierr.NotFound("folder not found")
+			}
+		}
+		var note *string
+		if req.Note != nil {
+			s := strings.TrimSpace(*req.Note)
+			note = &s
+		}
+		b, err := scanBookmark(deps.Postgres.QueryRow(ctx, `
+UPDATE bookmarks SET
+  folder_id = CASE WHEN $3::bigint IS NULL THEN folder_id ELSE NULLIF($3, 0) END,
+  note = CASE WHEN $4::text IS NULL THEN note ELSE NULLIF($4, '') END
+WHERE id = $1 AND user_id = $2
+RETURNING `+bookmarkColumns, id, user, req.FolderID, note))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("bookmark not found")
+		}
+		if err != nil {
+			return apierr.Database("db update bookmark failed")
+		}
+		updated := []bookmark{b}
+		if err := attachHadiths(ctx, deps, updated); err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, updated[0])
+	})
+
+	me.DELETE("/bookmarks/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		tag, err := deps.Postgres.Exec(c.Request().Context(), `DELETE FROM bookmarks WHERE id = $1 AND user_id = $2`, id, currentUser(c).UserID())
+		if err != nil {
+			return apierr.Database("db delete bookmark failed")
+		}
+		if tag.RowsAffected() == 0 {
+			return apierr.NotFound("bookmark not found")
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	me.GET("/bookmark-folders", func(c echo.Context) error {
+		rows, err := deps.Postgres.Query(c.Request().Context(), `
+SELECT f.id, f.name, count(b.id), f.created_at
+FROM bookmark_folders f LEFT JOIN bookmarks b ON b.folder_id = f.id
+WHERE f.user_id = $1
+GROUP BY f.id
+ORDER BY f.name`, currentUser(c).UserID())
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		folders, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (bookmarkFolder, error) {
+			var f bookmarkFolder
+			err := row.Scan(&f.ID, &f.Name, &f.Bookmarks, &f.CreatedAt)
+			return f, err
+		})
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, bookmarkFolderListResponse{Folders: folders})
+	})
+
+	me.POST("/bookmark-folders", func(c echo.Context) error {
+		var req bookmarkFolderRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		f := bookmarkFolder{Name: strings.TrimSpace(req.Name)}
+		err := deps.Postgres.QueryRow(c.Request().Context(), `
+INSERT INTO bookmark_folders (user_id, name) VALUES ($1, $2) RETURNING id, created_at`, currentUser(c).UserID(), f.Name).Scan(&f.ID, &f.CreatedAt)
+		if isUniqueViolation(err) {
+			return apierr.New(http.StatusConflict, apierr.CodeAlreadyExists, "folder already exists")
+		}
+		if err != nil {
+			return apierr.Database("db insert folder failed")
+		}
+		return c.JSON(http.StatusCreated, f)
+	})
+
+	me.PATCH("/bookmark-folders/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		var req bookmarkFolderRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		f := bookmarkFolder{ID: id}
+		err = deps.Postgres.QueryRow(c.Request().Context(), `
+UPDATE bookmark_folders f SET name = $3 WHERE id = $1 AND user_id = $2
+RETURNING name, (SELECT count(*) FROM bookmarks b WHERE b.folder_id = f.id), created_at`, id, currentUser(c).UserID(), strings.TrimSpace(req.Name)).
+			Scan(&f.Name, &f.Bookmarks, &f.CreatedAt)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return apierr.NotFound("folder not found")
+		case isUniqueViolation(err):
+			return apierr.New(http.StatusConflict, apierr.CodeAlreadyExists, "folder already exists")
+		case err != nil:
+			return apierr.Database("db update folder failed")
+		}
+		return c.JSON(http.StatusOK, f)
+	})
+
+	// Deleting a folder keeps its bookmarks, outside any folder.
+	me.DELETE("/bookmark-folders/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		tag, err := deps.Postgres.Exec(c.Request().Context(), `DELETE FROM bookmark_folders WHERE id = $1 AND user_id = $2`, id, currentUser(c).UserID())
+		if err != nil {
+			return apierr.Database("db delete folder failed")
+		}
+		if tag.RowsAffected() == 0 {
+			return apierr.NotFound("folder not found")
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,154 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/labstack/echo/v4"
+)
+
+// registerBookRoutes serves the books and chapters of collections, as
+// ingested with their hadiths, to browse them as printed.
+func registerBookRoutes(public *publicAPI, deps *AppDependencies) {
+	// collectionETag sets the ETag of the collection's version and reports
+	// whether the client's copy is current.
+	collectionETag := func(c echo.Context, ctx context.Context, kind string) (bool, error) {
+		version, err := deps.Store.CollectionsVersion(ctx, c.Param("code"))
+		if err != nil {
+			return false, apierr.Database("db query failed")
+		}
+		etag := weakETag(kind, c.Param("code"), c.Param("book"), version, c.QueryParams().Encode(), c.Request().Header.Get(echo.HeaderAccept))
+		return notModified(c, etag), nil
+	}
+	collectionFound := func(ctx context.Context, code string) error {
+		exists, err := deps.Store.CollectionExists(ctx, code)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		if !exists {
+			return apierr.NotFound("collection not found")
+		}
+		return nil
+	}
+	parseBook := func(c echo.Context) (int, error) {
+		book, err := strconv.Atoi(c.Param("book"))
+		if err != nil || book < 1 {
+			return 0, apierr.InvalidArgument("invalid book")
+		}
+		return book, nil
+	}
+
+	public.GET("/v1/collections/:code/books", func(c echo.Context) error {
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+		defer cancel()
+		unchanged, err := collectionETag(c, ctx, "collection-books")
+		if err != nil {
+			return err
+		}
+		if unchanged {
+			return c.NoContent(http.StatusNotModified)
+		}
+		books, err := deps.Store.Books(ctx, c.Param("code"))
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		if len(books) == 0 {
+			if err := collectionFound(ctx, c.Param("code")); err != nil {
+				return err
+			}
+		}
+		return respond(c, http.StatusOK, bookListResponse{Books: books})
+	})
+
+	public.GET("/v1/collections/:code/books/:book", func(c echo.Context) error {
+		number, err := parseBook(c)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+		defer cancel()
+		unchanged, err := collectionETag(c, ctx, "collection-book")
+		if err != nil {
+			return err
+		}
+		if unchanged {
+			return c.NoContent(http.StatusNotModified)
+		}
+		books, err := deps.Store.Books(ctx, c.Param("code"))
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		for _, book := range books {
+			if book.Number != number {
+				continue
+			}
+			if book.Chapters, err = deps.Store.Chapters(ctx, c.Param("code"), number); err != nil {
+				return apierr.Database("db query failed")
+			}
+			return respond(c, http.StatusOK, book)
+		}
+		if err := collectionFound(ctx, c.Param("code")); err != nil {
+			return err
+		}
+		return apierr.NotFound("book not found")
+	})
+
+	public.GET("/v1/collections/:code/books/:book/hadiths", func(c echo.Context) error {
+		book, err := parseBook(c)
+		if err != nil {
+			return err
+		}
+		var chapter int
+		if s := c.QueryParam("chapter"); s != "" {
+			if chapter, err = strconv.Atoi(s); err != nil || chapter < 1 {
+				return apierr.InvalidArgument("invalid chapter")
+			}
+		}
+		limit, err := parseLimit(c.QueryParam("limit"), 20, 100)
+		if err != nil {
+			return apierr.InvalidArgument("invalid limit")
+		}
+		var cursor *store.BookCursor
+		if s := c.QueryParam("cursor"); s != "" {
+			cursor = &store.BookCursor{}
+			if err := decodeCursor(s, cursor); err != nil {
+				return apierr.InvalidArgument("invalid cursor")
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+		defer cancel()
+		unchanged, err := collectionETag(c, ctx, "book-hadiths")
+		if err != nil {
+			return err
+		}
+		if unchanged {
+			return c.NoContent(http.StatusNotModified)
+		}
+
+		hadiths, next, err := deps.Store.BookHadiths(ctx, c.Param("code"), book, chapter, limit, cursor)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		if len(hadiths) == 0 && cursor == nil {
+			if err := collectionFound(ctx, c.Param("code")); err != nil {
+				return err
+			}
+			if chapter != 0 {
+				return apierr.NotFound("chapter not found")
+			}
+			return apierr.NotFound("book not found")
+		}
+
+		resp := hadithListResponse{Hadiths: hadiths}
+		if next != nil {
+			cursor := encodeCursor(next)
+			resp.NextCursor = &cursor
+		}
+		return respond(c, http.StatusOK, resp)
+	})
+}
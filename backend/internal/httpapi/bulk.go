@@ -0,0 +1,115 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+	"github.com/buugaaga/test-cursor/backend/internal/jobs"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	jobHadithsBulkDelete = "hadiths.bulk_delete"
+	jobHadithsBulkUpdate = "hadiths.bulk_update"
+)
+
+type bulkJobArgs struct {
+	Filter ingest.BulkFilter   `json:"filter"`
+	Set    *ingest.HadithPatch `json:"set,omitempty"`
+}
+
+func registerBulkJobKinds(deps *AppDependencies) {
+	work := func(ctx context.Context, j *jobs.Job) (any, error) {
+		var args bulkJobArgs
+		if err := json.Unmarshal(j.Args, &args); err != nil {
+			return nil, jobs.Permanent(err)
+		}
+		if args.Set != nil {
+			return deps.Ingest.BulkUpdate(ctx, args.Filter, *args.Set)
+		}
+		return deps.Ingest.BulkDelete(ctx, args.Filter)
+	}
+	for _, kind := range []string{jobHadithsBulkDelete, jobHadithsBulkUpdate} {
+		deps.Jobs.Register(jobs.Kind{
+			Name:        kind,
+			MaxAttempts: 3,
+			Backoff:     time.Minute,
+			Timeout:     6 * time.Hour,
+			Lock:        ingest.IndexLock,
+			Work:        work,
+		})
+	}
+}
+
+// checkExpected refuses a bulk change whose preview is out of date.
+func checkExpected(ctx context.Context, deps *AppDependencies, f ingest.BulkFilter, expected int64) error {
+	n, err := deps.Ingest.CountMatching(ctx, f)
+	if err != nil {
+		return err
+	}
+	if n != expected {
+		return apierr.New(http.StatusConflict, apierr.CodeConflict, fmt.Sprintf("filter matches %d hadiths, not %d", n, expected))
+	}
+	return nil
+}
+
+// registerBulkRoutes lets editors preview how many hadiths a filter matches
+// and then delete or update them in a job.
+func registerBulkRoutes(editor *echo.Group, deps *AppDependencies) {
+	g := editor.Group("/hadiths/bulk")
+
+	g.POST("/preview", func(c echo.Context) error {
+		var req bulkPreviewRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		n, err := deps.Ingest.CountMatching(c.Request().Context(), req.Filter)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, bulkPreviewResponse{Matched: n})
+	})
+
+	enqueue := func(c echo.Context, kind string, args bulkJobArgs) error {
+		j, err := deps.Jobs.Enqueue(c.Request().Context(), kind, args)
+		if err != nil {
+			return apierr.Database("db insert job failed")
+		}
+		auditResource(c, "job:"+strconv.FormatInt(j.ID, 10))
+		return c.JSON(http.StatusAccepted, j)
+	}
+
+	g.POST("/delete", func(c echo.Context) error {
+		var req bulkDeleteRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		auditNote(c, "hadiths.bulk_delete", map[string]any{"filter": req.Filter, "expected": *req.Expected})
+		if err := checkExpected(c.Request().Context(), deps, req.Filter, *req.Expected); err != nil {
+			return err
+		}
+		return enqueue(c, jobHadithsBulkDelete, bulkJobArgs{Filter: req.Filter})
+	})
+
+	g.POST("/update", func(c echo.Context) error {
+		var req bulkUpdateRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		if req.Set == (hadithPatchRequest{}) {
+			return apierr.InvalidArgument("set changes nothing")
+		}
+		auditNote(c, "hadiths.bulk_update", map[string]any{"filter": req.Filter, "set": req.Set, "expected": *req.Expected})
+		if err := checkExpected(c.Request().Context(), deps, req.Filter, *req.Expected); err != nil {
+			return err
+		}
+		set := ingest.HadithPatch{Grade: req.Set.Grade, Title: req.Set.Title, Topics: req.Set.Topics}
+		return enqueue(c, jobHadithsBulkUpdate, bulkJobArgs{Filter: req.Filter, Set: &set})
+	})
+}
@@ -0,0 +1,53 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/hijri"
+	"github.com/buugaaga/test-cursor/backend/internal/i18n"
+	"github.com/labstack/echo/v4"
+)
+
+// hijriMonthName is the name of the month of d in the language of the
+// request, which caches must then tell apart.
+func hijriMonthName(c echo.Context, d hijri.Date) string {
+	c.Response().Header().Add(echo.HeaderVary, "Accept-Language")
+	return i18n.T(i18n.From(c.Request().Context()), "hijri.month."+strconv.Itoa(d.Month))
+}
+
+// registerCalendarRoutes serves GET /v1/calendar/hijri, which converts a
+// Gregorian date (?date=) or a Hijri one (?hijri_date=) with the configured
+// method; without either it gives today's in DAILY_TIMEZONE.
+func registerCalendarRoutes(public *publicAPI, deps *AppDependencies) {
+	public.GET("/v1/calendar/hijri", func(c echo.Context) error {
+		day := time.Now().In(deps.DailyLocation)
+		switch {
+		case c.QueryParam("date") != "":
+			d, err := time.ParseInLocation("2006-01-02", c.QueryParam("date"), deps.DailyLocation)
+			if err != nil {
+				return apierr.InvalidArgument("invalid date")
+			}
+			day = d
+		case c.QueryParam("hijri_date") != "":
+			hd, err := hijri.Parse(c.QueryParam("hijri_date"))
+			if err != nil {
+				return apierr.InvalidArgument("invalid hijri_date")
+			}
+			if day, err = deps.Hijri.ToTime(hd, deps.DailyLocation); err != nil {
+				return apierr.InvalidArgument("invalid hijri_date")
+			}
+		}
+		hd := deps.Hijri.FromTime(day)
+		return c.JSON(http.StatusOK, hijriConversion{
+			Date:       day.Format("2006-01-02"),
+			Hijri:      hd,
+			HijriDate:  hd.String(),
+			HijriMonth: hijriMonthName(c, hd),
+			Method:     string(deps.Hijri.Method),
+			Adjustment: deps.Hijri.Adjustment,
+		})
+	})
+}
@@ -0,0 +1,142 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+	"github.com/buugaaga/test-cursor/backend/internal/jobs"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	replicaHeartbeat = 15 * time.Second
+	// replicaExpiry is how long a replica that stopped heartbeating is
+	// listed before it is taken for gone.
+	replicaExpiry = 2 * time.Minute
+)
+
+// clusterLocks are the advisory locks that single out one holder across
+// replicas and operator commands.
+var clusterLocks = []string{"startup", ingest.IndexLock, "jobs.maintain", "telegram.poll", "ratelimit.prune", "notify.schedule"}
+
+// NewReplicaID names a server process: its host name and a random suffix,
+// so that restarts and replicas on one host are told apart.
+func NewReplicaID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "server"
+	}
+	b := make([]byte, 4)
+	rand.Read(b)
+	return host + "-" + hex.EncodeToString(b)
+}
+
+// ReplicaRegistry keeps this replica's row in the replicas table fresh and
+// removes the rows of replicas that stopped heartbeating.
+type ReplicaRegistry struct {
+	db *pgxpool.Pool
+	id string
+}
+
+func newReplicaRegistry(db *pgxpool.Pool, id string) *ReplicaRegistry {
+	return &ReplicaRegistry{db: db, id: id}
+}
+
+func (r *ReplicaRegistry) heartbeat(ctx context.Context) error {
+	host, _ := os.Hostname()
+	_, err := r.db.Exec(ctx, `
+INSERT INTO replicas (id, hostname) VALUES ($1, $2)
+ON CONFLICT (id) DO UPDATE SET seen_at = now()`, r.id, host)
+	return err
+}
+
+// run heartbeats until ctx is done, then deregisters. Each round also
+// fails the exports whose job is gone.
+func (r *ReplicaRegistry) run(ctx context.Context, deps *AppDependencies) {
+	ticker := time.NewTicker(replicaHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, err := r.db.Exec(ctx, `DELETE FROM replicas WHERE id = $1`, r.id); err != nil {
+				slog.Warn("replica deregistration failed", "replica", r.id, "error", err)
+			}
+			return
+		case <-ticker.C:
+		}
+		if err := r.heartbeat(ctx); err != nil {
+			slog.Warn("replica heartbeat failed", "replica", r.id, "error", err)
+			continue
+		}
+		if _, err := r.db.Exec(ctx, `DELETE FROM replicas WHERE seen_at < now() - $1::interval`, replicaExpiry); err != nil {
+			slog.Warn("replica prune failed", "error", err)
+		}
+		if err := failInterruptedExports(ctx, deps); err != nil {
+			slog.Warn("exports: fail interrupted", "error", err)
+		}
+	}
+}
+
+func (r *ReplicaRegistry) list(ctx context.Context) ([]clusterReplica, error) {
+	rows, err := r.db.Query(ctx, `SELECT id, hostname, started_at, seen_at FROM replicas ORDER BY started_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (clusterReplica, error) {
+		var rep clusterReplica
+		err := row.Scan(&rep.ID, &rep.Hostname, &rep.StartedAt, &rep.SeenAt)
+		rep.Leads, rep.Jobs = []string{}, []int64{}
+		return rep, err
+	})
+}
+
+func registerClusterRoutes(admin *echo.Group, deps *AppDependencies) {
+	admin.GET("/cluster", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		replicas, err := deps.Replicas.list(ctx)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		holders, err := postgres.LockHolders(ctx, deps.Postgres, clusterLocks)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		running, err := deps.Jobs.List(tenant.Unscoped(ctx), jobs.Filter{State: jobs.StateRunning, Limit: 500})
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+
+		resp := clusterResponse{Replica: deps.Replicas.id, Replicas: replicas, Locks: holders}
+		byID := make(map[string]*clusterReplica, len(replicas))
+		for i := range resp.Replicas {
+			byID[resp.Replicas[i].ID] = &resp.Replicas[i]
+		}
+		for _, name := range clusterLocks {
+			if rep := byID[holders[name]]; rep != nil {
+				rep.Leads = append(rep.Leads, name)
+			}
+		}
+		for _, j := range running {
+			if j.Worker == nil {
+				continue
+			}
+			if rep := byID[*j.Worker]; rep != nil {
+				rep.Jobs = append(rep.Jobs, j.ID)
+			}
+		}
+		return c.JSON(http.StatusOK, resp)
+	})
+}
@@ -0,0 +1,169 @@
+package httpapi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/buugaaga/test-cursor/backend/internal/config"
+	"github.com/klauspost/compress/zstd"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// uncompressedRoutes stream, upgrade the connection, serve byte ranges or
+// compress on their own. Server-sent events are not compressed either.
+var uncompressedRoutes = map[string]bool{
+	"/v1/ws":                   true,
+	"/mcp":                     true,
+	"/v1/exports/:id/download": true,
+	"/metrics":                 true,
+}
+
+func skipCompression(c echo.Context) bool {
+	return uncompressedRoutes[c.Path()] || c.Request().Method == http.MethodHead || acceptsEventStream(c)
+}
+
+// parseCompression parses COMPRESSION: "off" or a list of gzip and zstd.
+func parseCompression(s string) (gzip, zstd bool, err error) {
+	if s == "off" {
+		return false, false, nil
+	}
+	for _, enc := range config.SplitList(s) {
+		switch enc {
+		case "gzip":
+			gzip = true
+		case "zstd":
+			zstd = true
+		default:
+			return false, false, fmt.Errorf("unknown encoding %q", enc)
+		}
+	}
+	return gzip, zstd, nil
+}
+
+// compression encodes responses with zstd when enabled and accepted by the
+// client, otherwise with gzip when enabled.
+func compression(useGzip, useZstd bool, level, minLength int) echo.MiddlewareFunc {
+	gz := middleware.GzipWithConfig(middleware.GzipConfig{Skipper: skipCompression, Level: level, MinLength: minLength})
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		gzNext := gz(next)
+		return func(c echo.Context) error {
+			if useZstd && !skipCompression(c) && acceptsEncoding(c.Request().Header.Get(echo.HeaderAcceptEncoding), "zstd") {
+				return zstdResponse(c, next)
+			}
+			if useGzip {
+				return gzNext(c)
+			}
+			return next(c)
+		}
+	}
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header lists enc with a
+// non-zero quality.
+func acceptsEncoding(header, enc string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), enc) {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
+
+var zstdEncoders = sync.Pool{New: func() any {
+	enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedDefault))
+	return enc
+}}
+
+func zstdResponse(c echo.Context, next echo.HandlerFunc) error {
+	res := c.Response()
+	res.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+	w := &zstdWriter{ResponseWriter: res.Writer}
+	res.Writer = w
+	defer func() {
+		w.close()
+		res.Writer = w.ResponseWriter
+	}()
+	return next(c)
+}
+
+// zstdWriter starts encoding on the first body write, so bodiless responses
+// such as 204 and 304 go out untouched.
+type zstdWriter struct {
+	http.ResponseWriter
+	enc        *zstd.Encoder
+	code       int
+	headerSent bool
+}
+
+func (w *zstdWriter) WriteHeader(code int) {
+	if !w.headerSent {
+		w.code = code
+	}
+}
+
+func (w *zstdWriter) sendHeader() {
+	w.headerSent = true
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+}
+
+func (w *zstdWriter) Write(b []byte) (int, error) {
+	if !w.headerSent {
+		h := w.Header()
+		if h.Get(echo.HeaderContentType) == "" {
+			h.Set(echo.HeaderContentType, http.DetectContentType(b))
+		}
+		h.Del(echo.HeaderContentLength)
+		h.Set(echo.HeaderContentEncoding, "zstd")
+		w.sendHeader()
+		w.enc = zstdEncoders.Get().(*zstd.Encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+	return w.enc.Write(b)
+}
+
+func (w *zstdWriter) Flush() {
+	if w.enc != nil {
+		w.enc.Flush()
+	} else if !w.headerSent {
+		w.sendHeader()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *zstdWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response does not support hijacking")
+}
+
+func (w *zstdWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *zstdWriter) close() {
+	if w.enc != nil {
+		w.enc.Close()
+		w.enc.Reset(nil)
+		zstdEncoders.Put(w.enc)
+		w.enc = nil
+		return
+	}
+	if !w.headerSent && w.code != 0 {
+		w.sendHeader()
+	}
+}
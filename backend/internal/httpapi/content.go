@@ -0,0 +1,50 @@
+package httpapi
+
+import (
+	"context"
+
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+)
+
+// contentNotifier turns ingestion events into webhooks and cache
+// invalidation.
+type contentNotifier struct {
+	deps *AppDependencies
+}
+
+func (n contentNotifier) CollectionUpdated(ctx context.Context, code, title string) {
+	n.deps.Webhooks.Publish(ctx, eventCollectionUpdated, map[string]any{
+		"code":  code,
+		"title": title,
+	})
+}
+
+func (n contentNotifier) HadithsCreated(ctx context.Context, collectionCode string, hadiths []ingest.Created) {
+	created := make([]map[string]any, 0, len(hadiths))
+	for _, h := range hadiths {
+		created = append(created, map[string]any{"id": h.ID, "number": h.Number})
+	}
+	n.deps.Webhooks.Publish(ctx, eventHadithCreated, map[string]any{
+		"collection_code": collectionCode,
+		"hadiths":         created,
+	})
+}
+
+func (n contentNotifier) HadithsUpdated(ctx context.Context, ids []int64) {
+	n.deps.Webhooks.Publish(ctx, eventHadithUpdated, map[string]any{"ids": ids})
+}
+
+func (n contentNotifier) HadithsDeleted(ctx context.Context, ids []int64) {
+	n.deps.Webhooks.Publish(ctx, eventHadithDeleted, map[string]any{"ids": ids})
+}
+
+func (n contentNotifier) ContentChanged(ctx context.Context) {
+	contentChanged(ctx, n.deps)
+}
+
+// contentChanged is the invalidation hook for every mutation of hadiths or
+// collections.
+func contentChanged(ctx context.Context, deps *AppDependencies) {
+	deps.Stats.Invalidate()
+	deps.Cache.InvalidateContent(ctx)
+}
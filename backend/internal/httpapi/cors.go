@@ -0,0 +1,21 @@
+package httpapi
+
+import (
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// publicCORS applies cfg to every route except /v1/admin/*, which stays
+// same-origin only.
+func publicCORS(cfg middleware.CORSConfig) echo.MiddlewareFunc {
+	cfg.Skipper = func(c echo.Context) bool {
+		return strings.HasPrefix(c.Request().URL.Path, "/v1/admin")
+	}
+	cfg.ExposeHeaders = []string{
+		"ETag", echo.HeaderXRequestID, echo.HeaderRetryAfter,
+		"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset",
+	}
+	return middleware.CORSWithConfig(cfg)
+}
@@ -0,0 +1,142 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/cache"
+	"github.com/buugaaga/test-cursor/backend/internal/hijri"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/labstack/echo/v4"
+)
+
+func hadithFilterFromQuery(c echo.Context) store.HadithFilter {
+	return store.HadithFilter{Collection: c.QueryParam("collection"), Grade: c.QueryParam("grade"), Topic: c.QueryParam("topic")}
+}
+
+func randomHadith(ctx context.Context, deps *AppDependencies, f store.HadithFilter) (Hadith, error) {
+	total, err := deps.Store.CountHadiths(ctx, f)
+	if err != nil {
+		return Hadith{}, err
+	}
+	if total == 0 {
+		return Hadith{}, store.ErrNotFound
+	}
+	return deps.Store.NthHadith(ctx, f, rand.Int64N(total))
+}
+
+// dailyHadith picks a hadith deterministically from the calendar day, so
+// every client sees the same hadith for the same day and filter. On Hijri
+// days with a topic in DAILY_HIJRI_TOPICS it is picked among the hadiths of
+// that topic, if the filter leaves any.
+func dailyHadith(ctx context.Context, deps *AppDependencies, f store.HadithFilter, day time.Time, calendar string) (Hadith, error) {
+	key := dayKey(deps, day, calendar)
+	if topic := deps.DailyTopics.Of(deps.Hijri.FromTime(day)); topic != "" && f.Topic == "" {
+		seasonal := f
+		seasonal.Topic = topic
+		h, err := pickHadith(ctx, deps, seasonal, key)
+		if !errors.Is(err, store.ErrNotFound) {
+			return h, err
+		}
+	}
+	return pickHadith(ctx, deps, f, key)
+}
+
+func pickHadith(ctx context.Context, deps *AppDependencies, f store.HadithFilter, dayKey string) (Hadith, error) {
+	total, err := deps.Store.CountHadiths(ctx, f)
+	if err != nil {
+		return Hadith{}, err
+	}
+	if total == 0 {
+		return Hadith{}, store.ErrNotFound
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s|%s|%s", dayKey, f.Collection, f.Grade)
+	if f.Topic != "" {
+		fmt.Fprintf(h, "|%s", f.Topic)
+	}
+	return deps.Store.NthHadith(ctx, f, int64(h.Sum64()%uint64(total)))
+}
+
+// cachedDailyHadith is dailyHadith through the cache shared by every
+// replica.
+func cachedDailyHadith(ctx context.Context, deps *AppDependencies, f store.HadithFilter, day time.Time, calendar string) (Hadith, error) {
+	key := dayKey(deps, day, calendar) + "|" + f.Collection + "|" + f.Grade + "|" + f.Topic
+	return cache.Cached(ctx, deps.Cache, "daily", key, func() (Hadith, error) {
+		return dailyHadith(ctx, deps, f, day, calendar)
+	})
+}
+
+func dayKey(deps *AppDependencies, day time.Time, calendar string) string {
+	if calendar == "hijri" {
+		return "hijri:" + deps.Hijri.FromTime(day).String()
+	}
+	return "gregorian:" + day.Format("2006-01-02")
+}
+
+func registerDailyRoutes(e *echo.Echo, public *publicAPI, deps *AppDependencies) {
+	e.GET("/v1/hadiths/random", func(c echo.Context) error {
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+		defer cancel()
+
+		h, err := randomHadith(ctx, deps, hadithFilterFromQuery(c))
+		if errors.Is(err, store.ErrNotFound) {
+			return apierr.NotFound("no matching hadiths")
+		}
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return respond(c, http.StatusOK, h)
+	})
+
+	public.GET("/v1/hadiths/daily", func(c echo.Context) error {
+		calendar := c.QueryParam("calendar")
+		if calendar == "" {
+			calendar = deps.DailyCalendar
+		}
+		if calendar != "gregorian" && calendar != "hijri" {
+			return apierr.InvalidArgument("invalid calendar")
+		}
+		day := time.Now().In(deps.DailyLocation)
+		if s := c.QueryParam("date"); s != "" {
+			d, err := time.ParseInLocation("2006-01-02", s, deps.DailyLocation)
+			if err != nil {
+				return apierr.InvalidArgument("invalid date")
+			}
+			day = d
+		} else if s := c.QueryParam("hijri_date"); s != "" {
+			hd, err := hijri.Parse(s)
+			if err != nil {
+				return apierr.InvalidArgument("invalid hijri_date")
+			}
+			if day, err = deps.Hijri.ToTime(hd, deps.DailyLocation); err != nil {
+				return apierr.InvalidArgument("invalid hijri_date")
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+		defer cancel()
+
+		h, err := cachedDailyHadith(ctx, deps, hadithFilterFromQuery(c), day, calendar)
+		if errors.Is(err, store.ErrNotFound) {
+			return apierr.NotFound("no matching hadiths")
+		}
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		hd := deps.Hijri.FromTime(day)
+		return respond(c, http.StatusOK, dailyHadithResponse{
+			Date:       day.Format("2006-01-02"),
+			HijriDate:  hd.String(),
+			HijriMonth: hijriMonthName(c, hd),
+			Calendar:   calendar,
+			Hadith:     h,
+		})
+	})
+}
@@ -0,0 +1,386 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/jobs"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/buugaaga/test-cursor/backend/internal/vector"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+const jobDuplicatesDetect = "duplicates.detect"
+
+const (
+	// duplicateThreshold is the default cosine similarity above which two
+	// hadiths are taken for the same narration.
+	duplicateThreshold = 0.95
+	// duplicateNeighbors is how many nearest hadiths are compared with each.
+	duplicateNeighbors = 10
+)
+
+type duplicatesJobArgs struct {
+	Threshold float32 `json:"threshold,omitempty"`
+}
+
+type duplicatesResult struct {
+	Threshold float32 `json:"threshold"`
+	Hadiths   int     `json:"hadiths"`
+	Groups    int     `json:"groups"`
+	// Reviewed counts the groups left out because editors already resolved
+	// a group holding all their hadiths.
+	Reviewed int `json:"reviewed"`
+}
+
+// duplicateSets is a union-find over hadith ids, with each hadith's best
+// similarity to another.
+type duplicateSets struct {
+	parent map[int64]int64
+	score  map[int64]float32
+}
+
+func (s *duplicateSets) find(id int64) int64 {
+	for s.parent[id] != id {
+		s.parent[id] = s.parent[s.parent[id]]
+		id = s.parent[id]
+	}
+	return id
+}
+
+func (s *duplicateSets) join(a, b int64, score float32) {
+	for _, id := range []int64{a, b} {
+		if _, ok := s.parent[id]; !ok {
+			s.parent[id] = id
+		}
+		s.score[id] = max(s.score[id], score)
+	}
+	s.parent[s.find(a)] = s.find(b)
+}
+
+// groups returns the sets of two or more hadiths, each sorted.
+func (s *duplicateSets) groups() [][]int64 {
+	byRoot := map[int64][]int64{}
+	for id := range s.parent {
+		root := s.find(id)
+		byRoot[root] = append(byRoot[root], id)
+	}
+	var out [][]int64
+	for _, root := range slices.Sorted(maps.Keys(byRoot)) {
+		if g := byRoot[root]; len(g) > 1 {
+			slices.Sort(g)
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// detectDuplicates compares every hadith point of the tenant of ctx with its
+// nearest neighbours in any collection and replaces the pending duplicate
+// groups with the sets of hadiths closer than threshold.
+func detectDuplicates(ctx context.Context, deps *AppDependencies, jobID int64, threshold float32) (duplicatesResult, error) {
+	res := duplicatesResult{Threshold: threshold}
+	collections := map[int64]string{}
+	err := deps.Vectors.Scroll(ctx, vector.ForTenant(ctx, &qdrant.Filter{
+		Must: []*qdrant.Condition{qdrant.NewMatch("origin_type", "hadith")},
+	}), func(page []*qdrant.RetrievedPoint) error {
+		for _, p := range page {
+			collections[p.GetPayload()["origin_id"].GetIntegerValue()] = p.GetPayload()["collection_code"].GetStringValue()
+		}
+		return nil
+	})
+	if err != nil {
+		return res, err
+	}
+	res.Hadiths = len(collections)
+
+	sets := &duplicateSets{parent: map[int64]int64{}, score: map[int64]float32{}}
+	for _, id := range slices.Sorted(maps.Keys(collections)) {
+		hits, err := deps.Search.Similar(ctx, id, duplicateNeighbors)
+		if err != nil {
+			return res, err
+		}
+		for _, h := range hits {
+			if h.Score < threshold {
+				break
+			}
+			sets.join(id, h.Payload["origin_id"].GetIntegerValue(), h.Score)
+		}
+	}
+
+	tx, err := deps.Postgres.Begin(ctx)
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback(ctx)
+	tenantID := tenant.From(ctx)
+	if _, err := tx.Exec(ctx, `DELETE FROM duplicate_groups WHERE tenant_id = $1 AND status = 'pending'`, tenantID); err != nil {
+		return res, err
+	}
+	rows, err := tx.Query(ctx, `
+SELECT m.hadith_id, m.group_id FROM duplicate_group_members m JOIN duplicate_groups g ON g.id = m.group_id
+WHERE g.tenant_id = $1`, tenantID)
+	if err != nil {
+		return res, err
+	}
+	reviewed := map[int64][]int64{}
+	var hadithID, groupID int64
+	_, err = pgx.ForEachRow(rows, []any{&hadithID, &groupID}, func() error {
+		reviewed[hadithID] = append(reviewed[hadithID], groupID)
+		return nil
+	})
+	if err != nil {
+		return res, err
+	}
+
+	for _, g := range sets.groups() {
+		// A group inside one that was reviewed is not asked about again.
+		seen := reviewed[g[0]]
+		for _, id := range g[1:] {
+			seen = slices.DeleteFunc(slices.Clone(seen), func(r int64) bool { return !slices.Contains(reviewed[id], r) })
+		}
+		if len(seen) > 0 {
+			res.Reviewed++
+			continue
+		}
+		var score float32
+		cross := false
+		for _, id := range g {
+			score = max(score, sets.score[id])
+			cross = cross || collections[id] != collections[g[0]]
+		}
+		var id int64
+		if err := tx.QueryRow(ctx, `
+INSERT INTO duplicate_groups (tenant_id, job_id, score, cross_collection) VALUES ($1, $2, $3, $4) RETURNING id`,
+			tenantID, jobID, score, cross).Scan(&id); err != nil {
+			return res, err
+		}
+		scores := make([]float32, len(g))
+		for i, h := range g {
+			scores[i] = sets.score[h]
+		}
+		// Points of hadiths deleted since they were indexed are skipped.
+		if _, err := tx.Exec(ctx, `
+INSERT INTO duplicate_group_members (group_id, hadith_id, score)
+SELECT $1, m.hadith_id, m.score FROM unnest($2::int[], $3::real[]) AS m(hadith_id, score)
+WHERE EXISTS (SELECT 1 FROM hadiths h WHERE h.id = m.hadith_id)`, id, g, scores); err != nil {
+			return res, err
+		}
+		res.Groups++
+	}
+	return res, tx.Commit(ctx)
+}
+
+const duplicateGroupColumns = `g.id, g.status, g.score, g.cross_collection, g.canonical_id, g.job_id, g.created_at, g.resolved_at`
+
+func scanDuplicateGroup(row pgx.Row) (duplicateGroup, error) {
+	g := duplicateGroup{Members: []duplicateMember{}}
+	err := row.Scan(&g.ID, &g.Status, &g.Score, &g.CrossCollection, &g.CanonicalID, &g.JobID, &g.CreatedAt, &g.ResolvedAt)
+	return g, err
+}
+
+// withDuplicateMembers fills in the members of groups, most similar first.
+func withDuplicateMembers(ctx context.Context, deps *AppDependencies, groups []duplicateGroup) error {
+	byID := map[int64]*duplicateGroup{}
+	ids := make([]int64, 0, len(groups))
+	for i := range groups {
+		byID[groups[i].ID] = &groups[i]
+		ids = append(ids, groups[i].ID)
+	}
+	rows, err := deps.Postgres.Query(ctx, `
+SELECT m.group_id, m.hadith_id, c.code, h.number, m.score
+FROM duplicate_group_members m JOIN hadiths h ON h.id = m.hadith_id JOIN hadith_collections c ON c.id = h.collection_id
+WHERE m.group_id = ANY($1)
+ORDER BY m.score DESC, m.hadith_id`, ids)
+	if err != nil {
+		return err
+	}
+	var (
+		groupID int64
+		m       duplicateMember
+	)
+	_, err = pgx.ForEachRow(rows, []any{&groupID, &m.HadithID, &m.CollectionCode, &m.Number, &m.Score}, func() error {
+		byID[groupID].Members = append(byID[groupID].Members, m)
+		return nil
+	})
+	return err
+}
+
+func duplicateGroupOf(ctx context.Context, deps *AppDependencies, id int64) (duplicateGroup, error) {
+	g, err := scanDuplicateGroup(deps.Postgres.QueryRow(ctx, `
+SELECT `+duplicateGroupColumns+` FROM duplicate_groups g WHERE g.id = $1 AND g.tenant_id = $2`, id, tenant.From(ctx)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return g, apierr.NotFound("duplicate group not found")
+	}
+	if err != nil {
+		return g, apierr.Database("db query failed")
+	}
+	groups := []duplicateGroup{g}
+	if err := withDuplicateMembers(ctx, deps, groups); err != nil {
+		return g, apierr.Database("db query failed")
+	}
+	return groups[0], nil
+}
+
+func registerDuplicatesJobKind(deps *AppDependencies) {
+	deps.Jobs.Register(jobs.Kind{
+		Name:        jobDuplicatesDetect,
+		MaxAttempts: 3,
+		Backoff:     time.Minute,
+		Timeout:     6 * time.Hour,
+		Lock:        jobDuplicatesDetect,
+		Work: func(ctx context.Context, j *jobs.Job) (any, error) {
+			var args duplicatesJobArgs
+			if err := json.Unmarshal(j.Args, &args); err != nil {
+				return nil, jobs.Permanent(err)
+			}
+			if args.Threshold == 0 {
+				args.Threshold = duplicateThreshold
+			}
+			return detectDuplicates(ctx, deps, j.ID, args.Threshold)
+		},
+	})
+}
+
+// registerDuplicateRoutes lets editors review the groups found by the
+// duplicates.detect job, and shows anyone the narrations linked to a hadith.
+func registerDuplicateRoutes(editor *echo.Group, public *publicAPI, deps *AppDependencies) {
+	g := editor.Group("/duplicates")
+
+	g.GET("", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		limit, err := parseLimit(c.QueryParam("limit"), 50, 200)
+		if err != nil {
+			return apierr.InvalidArgument("invalid limit")
+		}
+		status := c.QueryParam("status")
+		switch status {
+		case "":
+			status = "pending"
+		case "pending", "linked", "merged", "dismissed":
+		default:
+			return apierr.InvalidArgument("invalid status")
+		}
+		var cur auditCursor
+		if s := c.QueryParam("cursor"); s != "" {
+			if err := decodeCursor(s, &cur); err != nil {
+				return apierr.InvalidArgument("invalid cursor")
+			}
+		}
+		rows, err := deps.Postgres.Query(ctx, `
+SELECT `+duplicateGroupColumns+` FROM duplicate_groups g
+WHERE g.tenant_id = $1 AND g.status = $2 AND ($3 = 0 OR g.id < $3)
+ORDER BY g.id DESC
+LIMIT $4`, tenant.From(ctx), status, cur.ID, limit+1)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		groups, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (duplicateGroup, error) { return scanDuplicateGroup(row) })
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		resp := duplicateGroupListResponse{Groups: groups}
+		if len(groups) > limit {
+			resp.Groups = groups[:limit]
+			cursor := encodeCursor(auditCursor{ID: groups[limit-1].ID})
+			resp.NextCursor = &cursor
+		}
+		if err := withDuplicateMembers(ctx, deps, resp.Groups); err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, resp)
+	})
+
+	g.GET("/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		group, err := duplicateGroupOf(c.Request().Context(), deps, id)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, group)
+	})
+
+	g.POST("/:id/resolve", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		var req duplicateResolveRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		auditNote(c, "duplicates.resolve", map[string]any{"action": req.Action, "canonical_id": req.CanonicalID}, "duplicate_group:"+c.Param("id"))
+		group, err := duplicateGroupOf(ctx, deps, id)
+		if err != nil {
+			return err
+		}
+		var canonical *int64
+		if req.Action == "merge" {
+			if !slices.ContainsFunc(group.Members, func(m duplicateMember) bool { return m.HadithID == req.CanonicalID }) {
+				return apierr.InvalidArgument("canonical_id is not in the group")
+			}
+			canonical = &req.CanonicalID
+		}
+		status := map[string]string{"link": "linked", "merge": "merged", "dismiss": "dismissed"}[req.Action]
+		if _, err := deps.Postgres.Exec(ctx, `
+UPDATE duplicate_groups SET status = $2, canonical_id = $3, resolved_at = now() WHERE id = $1`, id, status, canonical); err != nil {
+			return apierr.Database("db update duplicate group failed")
+		}
+		group, err = duplicateGroupOf(ctx, deps, id)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, group)
+	})
+
+	public.GET("/v1/hadiths/:id/parallels", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		if _, err := noteHadith(ctx, deps, id); err != nil {
+			return err
+		}
+		out := hadithParallels{HadithID: id, Parallels: []hadithParallel{}}
+		rows, err := deps.Postgres.Query(ctx, `
+SELECT DISTINCT ON (o.hadith_id) o.hadith_id, c.code, h.number, g.status, g.canonical_id
+FROM duplicate_group_members m
+JOIN duplicate_groups g ON g.id = m.group_id AND g.status IN ('linked', 'merged')
+JOIN duplicate_group_members o ON o.group_id = g.id AND o.hadith_id <> m.hadith_id
+JOIN hadiths h ON h.id = o.hadith_id JOIN hadith_collections c ON c.id = h.collection_id
+WHERE m.hadith_id = $1
+ORDER BY o.hadith_id, g.resolved_at DESC`, id)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		var (
+			p         hadithParallel
+			canonical *int64
+		)
+		_, err = pgx.ForEachRow(rows, []any{&p.ID, &p.CollectionCode, &p.Number, &p.Relation, &canonical}, func() error {
+			out.Parallels = append(out.Parallels, p)
+			if canonical != nil {
+				out.CanonicalID = canonical
+			}
+			return nil
+		})
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, out)
+	})
+}
@@ -0,0 +1,71 @@
+package httpapi
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/i18n"
+	"github.com/labstack/echo/v4"
+)
+
+var statusCodes = map[int]apierr.ErrorCode{
+	http.StatusBadRequest:            apierr.CodeInvalidRequest,
+	http.StatusUnauthorized:          apierr.CodeUnauthorized,
+	http.StatusForbidden:             apierr.CodeForbidden,
+	http.StatusNotFound:              apierr.CodeNotFound,
+	http.StatusMethodNotAllowed:      apierr.CodeMethodNotAllowed,
+	http.StatusTooManyRequests:       apierr.CodeRateLimited,
+	http.StatusRequestEntityTooLarge: apierr.CodePayloadTooLarge,
+}
+
+// httpErrorHandler renders every error that reaches echo — handler errors as
+// well as router and middleware errors — in the apierr.APIError format.
+func httpErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	apiErr := responseError(c, err)
+	if apiErr.RetryAfter > 0 {
+		c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(apiErr.RetryAfter))
+	}
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(apiErr.Status)
+	} else {
+		err = c.JSON(apiErr.Status, apiErr)
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "write error response", "error", err)
+	}
+}
+
+// responseError is err as an APIError in the request's language.
+func responseError(c echo.Context, err error) *apierr.APIError {
+	var apiErr *apierr.APIError
+	var httpErr *echo.HTTPError
+	switch {
+	case errors.As(err, &apiErr):
+	case errors.As(err, &httpErr):
+		code, ok := statusCodes[httpErr.Code]
+		if !ok {
+			code = apierr.CodeInternal
+		}
+		msg, ok := httpErr.Message.(string)
+		if !ok {
+			msg = http.StatusText(httpErr.Code)
+		}
+		apiErr = apierr.New(httpErr.Code, code, msg)
+	default:
+		slog.ErrorContext(c.Request().Context(), "unhandled error", "error", err)
+		apiErr = apierr.New(http.StatusInternalServerError, apierr.CodeInternal, "internal error")
+	}
+
+	if lang := i18n.From(c.Request().Context()); lang != i18n.Default {
+		apiErr = apiErr.Localize(lang)
+		c.Response().Header().Set("Content-Language", lang)
+	}
+	return apiErr
+}
@@ -0,0 +1,43 @@
+package httpapi
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// weakETag hashes the parts that determine a representation. ETags are weak
+// because the same version is served as JSON, XML or protobuf.
+func weakETag(parts ...any) string {
+	h := fnv.New64a()
+	for _, p := range parts {
+		fmt.Fprintf(h, "%v|", p)
+	}
+	return fmt.Sprintf(`W/"%x"`, h.Sum64())
+}
+
+// notModified sets the ETag header and reports whether the request's
+// If-None-Match already names it, in which case the handler should reply 304.
+func notModified(c echo.Context, etag string) bool {
+	c.Response().Header().Set(echo.HeaderVary, echo.HeaderAccept)
+	c.Response().Header().Set("ETag", etag)
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-cache")
+	inm := c.Request().Header.Get("If-None-Match")
+	if inm == "" {
+		return false
+	}
+	bare := strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(inm, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == bare {
+			return true
+		}
+	}
+	return false
+}
+
+func hadithETag(c echo.Context, h Hadith) string {
+	return weakETag("hadith", h.ID, h.UpdatedAt.UnixNano(), c.QueryParam("fields"), c.Request().Header.Get(echo.HeaderAccept))
+}
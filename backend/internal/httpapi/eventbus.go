@@ -0,0 +1,76 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"github.com/buugaaga/test-cursor/backend/internal/config"
+	"github.com/buugaaga/test-cursor/backend/internal/jobs"
+	"github.com/buugaaga/test-cursor/backend/internal/natsjs"
+)
+
+const jobEventPublish = "event.publish"
+
+type eventJobArgs struct {
+	Subject string          `json:"subject"`
+	EventID string          `json:"event_id"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// EventBus publishes every webhook event, whether or not a hook subscribes
+// to it, on NATS as <prefix>.<tenant id>.<event>, with the webhook body.
+// Like deliveries, publishing is an event.publish job, so events survive
+// restarts and NATS outages; they may arrive more than once and out of
+// order.
+type EventBus struct {
+	cfg  config.Events
+	jobs *jobs.Client
+	mu   sync.Mutex
+	conn *natsjs.Conn
+}
+
+// newEventBus returns nil when no NATS URL is configured.
+func newEventBus(cfg config.Events, queue *jobs.Client) *EventBus {
+	if cfg.NATSURL == "" {
+		return nil
+	}
+	return &EventBus{cfg: cfg, jobs: queue}
+}
+
+func (b *EventBus) enqueue(ctx context.Context, tenantID int64, ev webhookEvent, body []byte) {
+	args := eventJobArgs{
+		Subject: b.cfg.SubjectPrefix + "." + strconv.FormatInt(tenantID, 10) + "." + ev.Event,
+		EventID: ev.ID,
+		Body:    body,
+	}
+	if _, err := b.jobs.Enqueue(ctx, jobEventPublish, args); err != nil {
+		slog.Error("events: enqueue publish failed", "event", ev.Event, "error", err)
+	}
+}
+
+// publishJob runs an event.publish job over a connection shared by the
+// replica's workers, which is opened again after a failure.
+func (b *EventBus) publishJob(ctx context.Context, j *jobs.Job) (any, error) {
+	var args eventJobArgs
+	if err := json.Unmarshal(j.Args, &args); err != nil {
+		return nil, jobs.Permanent(err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn == nil {
+		conn, err := natsjs.Connect(ctx, natsjs.Config{URL: b.cfg.NATSURL, Token: b.cfg.NATSToken})
+		if err != nil {
+			return nil, err
+		}
+		b.conn = conn
+	}
+	if err := b.conn.Publish(ctx, args.Subject, args.EventID, args.Body); err != nil {
+		b.conn.Close()
+		b.conn = nil
+		return nil, err
+	}
+	return nil, nil
+}
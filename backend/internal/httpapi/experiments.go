@@ -0,0 +1,340 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/search"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+// experimentCacheTTL is how long a replica keeps a tenant's running
+// experiment; starting or stopping one on another replica takes that long
+// to reach it.
+const experimentCacheTTL = 15 * time.Second
+
+const experimentColumns = `x.id, x.name, x.variants, x.active, x.created_at, x.updated_at`
+
+func scanExperiment(row pgx.Row) (experiment, error) {
+	var e experiment
+	err := row.Scan(&e.ID, &e.Name, &e.Variants, &e.Active, &e.CreatedAt, &e.UpdatedAt)
+	return e, err
+}
+
+// Experiments buckets searches into the variants of their tenant's running
+// ranking experiment and records them.
+type Experiments struct {
+	db      *pgxpool.Pool
+	mu      sync.Mutex
+	running map[int64]cachedExperiment
+}
+
+type cachedExperiment struct {
+	e       *experiment
+	expires time.Time
+}
+
+func newExperiments(db *pgxpool.Pool) *Experiments {
+	return &Experiments{db: db, running: map[int64]cachedExperiment{}}
+}
+
+// active returns the running experiment of tenantID, or nil when there is
+// none.
+func (x *Experiments) active(ctx context.Context, tenantID int64) (*experiment, error) {
+	x.mu.Lock()
+	cached, ok := x.running[tenantID]
+	x.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.e, nil
+	}
+	e, err := scanExperiment(x.db.QueryRow(ctx, `SELECT `+experimentColumns+` FROM experiments x WHERE x.tenant_id = $1 AND x.active`, tenantID))
+	var running *experiment
+	switch {
+	case err == nil:
+		running = &e
+	case !errors.Is(err, pgx.ErrNoRows):
+		return nil, err
+	}
+	x.mu.Lock()
+	x.running[tenantID] = cachedExperiment{e: running, expires: time.Now().Add(experimentCacheTTL)}
+	x.mu.Unlock()
+	return running, nil
+}
+
+// forget drops the cached experiment of tenantID after it changed here.
+func (x *Experiments) forget(tenantID int64) {
+	x.mu.Lock()
+	delete(x.running, tenantID)
+	x.mu.Unlock()
+}
+
+// searchClient identifies the caller of a search for bucketing: by the
+// X-Client-ID apps send, or else as rate limits do.
+func searchClient(c echo.Context) string {
+	if id := strings.TrimSpace(c.Request().Header.Get("X-Client-ID")); id != "" && len(id) <= 128 {
+		return "client:" + id
+	}
+	return rateLimitClient(c)
+}
+
+// bucket picks the variant of e for client by weight. A client keeps its
+// variant for the whole experiment.
+func bucket(e *experiment, client string) experimentVariant {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d:%s", e.ID, client)
+	total := 0
+	for _, v := range e.Variants {
+		total += v.Weight
+	}
+	n := int(h.Sum64() % uint64(total))
+	for _, v := range e.Variants {
+		if n < v.Weight {
+			return v
+		}
+		n -= v.Weight
+	}
+	return e.Variants[len(e.Variants)-1]
+}
+
+// ranking is how searches in v are ranked. Boosts were checked when the
+// experiment was created.
+func (v experimentVariant) ranking() *search.Ranking {
+	r := &search.Ranking{MinScore: v.MinScore}
+	if v.Boosts != nil {
+		r.Boosts, _ = search.ParseBoosts(*v.Boosts)
+		if r.Boosts == nil {
+			r.Boosts = []search.Boost{}
+		}
+	}
+	return r
+}
+
+// assign returns the running experiment of the search request c and the
+// variant of its client, or nil when the tenant runs none. Searches are
+// served without an experiment when it cannot be looked up.
+func (x *Experiments) assign(c echo.Context) (*experiment, *experimentVariant) {
+	ctx := c.Request().Context()
+	tenantID := tenant.From(ctx)
+	if tenantID == tenant.All {
+		return nil, nil
+	}
+	e, err := x.active(ctx, tenantID)
+	if err != nil {
+		slog.WarnContext(ctx, "experiments: lookup failed", "error", err)
+		return nil, nil
+	}
+	if e == nil {
+		return nil, nil
+	}
+	v := bucket(e, searchClient(c))
+	return e, &v
+}
+
+// checkExperiment rejects variants that share a name or whose boost rules
+// do not parse, and weighs variants without a weight 1.
+func checkExperiment(req experimentRequest) error {
+	seen := map[string]bool{}
+	for i, v := range req.Variants {
+		if v.Weight == 0 {
+			req.Variants[i].Weight = 1
+		}
+		if seen[v.Name] {
+			return apierr.InvalidArgument("variant names must be unique")
+		}
+		seen[v.Name] = true
+		if v.Boosts != nil {
+			if _, err := search.ParseBoosts(*v.Boosts); err != nil {
+				return apierr.InvalidArgument(fmt.Sprintf("variant %s: invalid boosts: %v", v.Name, err))
+			}
+		}
+	}
+	return nil
+}
+
+func experimentOf(ctx context.Context, deps *AppDependencies, id int64) (experiment, error) {
+	e, err := scanExperiment(deps.Postgres.QueryRow(ctx, `
+SELECT `+experimentColumns+` FROM experiments x WHERE x.id = $1 AND `+postgres.TenantWhere("x", 2), id, tenant.From(ctx)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return experiment{}, apierr.NotFound("experiment not found")
+	}
+	if err != nil {
+		return experiment{}, apierr.Database("db query failed")
+	}
+	return e, nil
+}
+
+// experimentMetrics reports every variant of e, in its order, including
+// those without searches yet.
+func experimentMetrics(ctx context.Context, deps *AppDependencies, e experiment) ([]variantMetrics, error) {
+	rows, err := deps.ReadPostgres.Query(ctx, `
+SELECT s.variant, count(*), count(*) FILTER (WHERE s.hits = 0), COALESCE(sum(i.clicks), 0),
+  count(*) FILTER (WHERE i.clicks > 0), count(*) FILTER (WHERE i.helpful), count(*) FILTER (WHERE i.unhelpful)
+FROM search_events s
+CROSS JOIN LATERAL (
+  SELECT count(*) FILTER (WHERE kind = 'click') AS clicks,
+    COALESCE(bool_or(kind = 'helpful'), false) AS helpful,
+    COALESCE(bool_or(kind = 'unhelpful'), false) AS unhelpful
+  FROM search_interactions WHERE search_id = s.id
+) i
+WHERE s.experiment_id = $1
+GROUP BY s.variant`, e.ID)
+	if err != nil {
+		return nil, err
+	}
+	byVariant := map[string]variantMetrics{}
+	var (
+		m       variantMetrics
+		clicked int64
+	)
+	_, err = pgx.ForEachRow(rows, []any{&m.Variant, &m.Searches, &m.ZeroResults, &m.Clicks, &clicked, &m.Helpful, &m.Unhelpful}, func() error {
+		if m.Searches > 0 {
+			m.ClickThroughRate = float64(clicked) / float64(m.Searches)
+		}
+		byVariant[m.Variant] = m
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]variantMetrics, 0, len(e.Variants))
+	for _, v := range e.Variants {
+		m := byVariant[v.Name]
+		m.Variant = v.Name
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// registerExperimentRoutes lets admins run ranking experiments under
+// /v1/admin/experiments.
+func registerExperimentRoutes(admin *echo.Group, deps *AppDependencies) {
+	g := admin.Group("/experiments")
+
+	g.GET("", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		rows, err := deps.Postgres.Query(ctx, `
+SELECT `+experimentColumns+` FROM experiments x WHERE `+postgres.TenantWhere("x", 1)+` ORDER BY x.id DESC`, tenant.From(ctx))
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		list, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (experiment, error) { return scanExperiment(row) })
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, experimentListResponse{Experiments: list})
+	})
+
+	g.POST("", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		var req experimentRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		if tenant.From(ctx) == tenant.All {
+			return apierr.InvalidArgument("experiments need a tenant")
+		}
+		if err := checkExperiment(req); err != nil {
+			return err
+		}
+		auditNote(c, "experiment.create", map[string]any{"name": req.Name, "variants": len(req.Variants)})
+		x, err := scanExperiment(deps.Postgres.QueryRow(ctx, `
+INSERT INTO experiments AS x (tenant_id, name, variants) VALUES ($1, $2, $3)
+RETURNING `+experimentColumns, tenant.From(ctx), req.Name, req.Variants))
+		if isUniqueViolation(err) {
+			return apierr.New(http.StatusConflict, apierr.CodeAlreadyExists, "experiment already exists")
+		}
+		if err != nil {
+			return apierr.Database("db insert experiment failed")
+		}
+		auditResource(c, "experiment:"+strconv.FormatInt(x.ID, 10))
+		return c.JSON(http.StatusCreated, x)
+	})
+
+	g.GET("/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		x, err := experimentOf(c.Request().Context(), deps, id)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, x)
+	})
+
+	g.GET("/:id/report", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		x, err := experimentOf(ctx, deps, id)
+		if err != nil {
+			return err
+		}
+		variants, err := experimentMetrics(ctx, deps, x)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, experimentReport{Experiment: x, Variants: variants})
+	})
+
+	// A tenant runs one experiment at a time; starting another while one
+	// runs is a conflict.
+	setActive := func(c echo.Context, active bool) error {
+		ctx := c.Request().Context()
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		auditNote(c, "experiment.update", map[string]any{"active": active}, "experiment:"+c.Param("id"))
+		x, err := scanExperiment(deps.Postgres.QueryRow(ctx, `
+UPDATE experiments x SET active = $2, updated_at = now()
+WHERE x.id = $1 AND `+postgres.TenantWhere("x", 3)+`
+RETURNING `+experimentColumns, id, active, tenant.From(ctx)))
+		if isUniqueViolation(err) {
+			return apierr.New(http.StatusConflict, apierr.CodeConflict, "another experiment is running")
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("experiment not found")
+		}
+		if err != nil {
+			return apierr.Database("db update experiment failed")
+		}
+		deps.Experiments.forget(tenant.From(ctx))
+		return c.JSON(http.StatusOK, x)
+	}
+	g.POST("/:id/start", func(c echo.Context) error { return setActive(c, true) })
+	g.POST("/:id/stop", func(c echo.Context) error { return setActive(c, false) })
+
+	g.DELETE("/:id", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		auditNote(c, "experiment.delete", nil, "experiment:"+c.Param("id"))
+		tag, err := deps.Postgres.Exec(ctx, `DELETE FROM experiments x WHERE x.id = $1 AND `+postgres.TenantWhere("x", 2), id, tenant.From(ctx))
+		if err != nil {
+			return apierr.Database("db delete experiment failed")
+		}
+		if tag.RowsAffected() == 0 {
+			return apierr.NotFound("experiment not found")
+		}
+		deps.Experiments.forget(tenant.From(ctx))
+		return c.NoContent(http.StatusNoContent)
+	})
+}
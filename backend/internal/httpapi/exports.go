@@ -0,0 +1,415 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/jobs"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/minio/minio-go/v7"
+)
+
+// Exports are generated by export.run jobs and stored either in the backup
+// bucket (under exports/) or, without S3, in a local directory. They are
+// downloaded through /v1/exports/:id/download with an HMAC-signed expiring
+// link instead of credentials, so links can be shared with collaborators;
+// downloads support Range requests and can be resumed.
+
+const (
+	exportDefaultTTL = 24 * time.Hour
+	exportMaxTTL     = 7 * 24 * time.Hour
+)
+
+type ExportStore struct {
+	s3         *BackupStore
+	dir        string
+	signingKey []byte
+	baseURL    string
+}
+
+func newExportStore(s3 *BackupStore, dir string, signingKey []byte, baseURL string) *ExportStore {
+	return &ExportStore{s3: s3, dir: dir, signingKey: signingKey, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+func (s *ExportStore) objectKey(id, format string) string {
+	return "exports/" + id + "." + format
+}
+
+func (s *ExportStore) put(ctx context.Context, key string, write func(w io.Writer) error) (int64, error) {
+	if s.s3 != nil {
+		pr, pw := io.Pipe()
+		go func() { pw.CloseWithError(write(pw)) }()
+		info, err := s.s3.S3.PutObject(ctx, s.s3.Bucket, key, pr, -1, minio.PutObjectOptions{})
+		pr.CloseWithError(err)
+		return info.Size, err
+	}
+	name := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return 0, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), ".export-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+	if err := write(f); err != nil {
+		f.Close()
+		return 0, err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+	return size, os.Rename(f.Name(), name)
+}
+
+func (s *ExportStore) open(ctx context.Context, key string) (io.ReadSeekCloser, time.Time, error) {
+	if s.s3 != nil {
+		obj, err := s.s3.S3.GetObject(ctx, s.s3.Bucket, key, minio.GetObjectOptions{})
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		info, err := obj.Stat()
+		if err != nil {
+			obj.Close()
+			return nil, time.Time{}, err
+		}
+		return obj, info.LastModified, nil
+	}
+	f, err := os.Open(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, time.Time{}, err
+	}
+	return f, info.ModTime(), nil
+}
+
+func (s *ExportStore) signature(id string, expires int64) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(mac, "%s.%d", id, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signedURL returns the download link of export id, valid until expires.
+// Without a configured base URL the link is relative to the request host.
+func (s *ExportStore) signedURL(c echo.Context, id string, expires time.Time) string {
+	base := s.baseURL
+	if base == "" {
+		base = c.Scheme() + "://" + c.Request().Host
+	}
+	q := url.Values{
+		"expires": {strconv.FormatInt(expires.Unix(), 10)},
+		"sig":     {s.signature(id, expires.Unix())},
+	}
+	return base + "/v1/exports/" + url.PathEscape(id) + "/download?" + q.Encode()
+}
+
+func (s *ExportStore) verify(id, expires, sig string) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(s.signature(id, exp)))
+}
+
+const exportColumns = `id, status, collection_code, format, hadiths, size_bytes, error, created_by, created_at, completed_at, tenant_id`
+
+func scanExport(row pgx.Row) (export, error) {
+	var x export
+	err := row.Scan(&x.ID, &x.Status, &x.CollectionCode, &x.Format, &x.Hadiths, &x.SizeBytes, &x.Error, &x.CreatedBy, &x.CreatedAt, &x.CompletedAt, &x.TenantID)
+	return x, err
+}
+
+func getExport(ctx context.Context, deps *AppDependencies, id string) (export, error) {
+	return scanExport(deps.Postgres.QueryRow(ctx, `SELECT `+exportColumns+` FROM exports x WHERE id = $1 AND `+postgres.TenantWhere("x", 2), id, tenant.From(ctx)))
+}
+
+// failInterruptedExports marks exports whose job is gone, e.g. cancelled
+// by an admin, without the export being finished. Fresh exports are left
+// alone: their job is enqueued right after the insert.
+func failInterruptedExports(ctx context.Context, deps *AppDependencies) error {
+	_, err := deps.Postgres.Exec(ctx, `
+UPDATE exports x SET status = 'failed', error = 'export job ended without finishing', completed_at = now()
+WHERE status IN ('pending', 'running') AND created_at < now() - interval '1 minute'
+  AND NOT EXISTS (
+    SELECT 1 FROM jobs j
+    WHERE j.kind = $1 AND j.args->>'id' = x.id AND j.state IN ('available', 'running'))`, jobExportRun)
+	return err
+}
+
+type exportJobArgs struct {
+	ID string `json:"id"`
+}
+
+// registerExportJobKind runs exports on the job queue, so one whose
+// replica dies is picked up again by another. Failed attempts put the
+// export back to pending; only the last one fails it.
+func registerExportJobKind(deps *AppDependencies) {
+	deps.Jobs.Register(jobs.Kind{
+		Name:        jobExportRun,
+		MaxAttempts: 3,
+		Backoff:     time.Minute,
+		Timeout:     time.Hour,
+		Work: func(ctx context.Context, j *jobs.Job) (any, error) {
+			var args exportJobArgs
+			if err := json.Unmarshal(j.Args, &args); err != nil {
+				return nil, jobs.Permanent(err)
+			}
+			x, err := getExport(ctx, deps, args.ID)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, jobs.Permanent(fmt.Errorf("export %s not found", args.ID))
+			}
+			if err != nil {
+				return nil, err
+			}
+			if x.Status != "pending" && x.Status != "running" {
+				return nil, nil
+			}
+			if _, err := deps.Postgres.Exec(ctx, `UPDATE exports SET status = 'running', replica = $2 WHERE id = $1`, x.ID, deps.Replicas.id); err != nil {
+				return nil, err
+			}
+			if err := runExport(ctx, deps, x); err != nil {
+				slog.ErrorContext(ctx, "export failed", "export_id", x.ID, "attempt", j.Attempt, "error", err)
+				status := "pending"
+				if j.Attempt >= j.MaxAttempts {
+					status = "failed"
+				}
+				if _, uerr := deps.Postgres.Exec(ctx, `
+UPDATE exports SET status = $2, error = $3, completed_at = CASE WHEN $2 = 'failed' THEN now() END WHERE id = $1`, x.ID, status, err.Error()); uerr != nil {
+					slog.ErrorContext(ctx, "export: record status failed", "export_id", x.ID, "error", uerr)
+				}
+				return nil, err
+			}
+			return nil, nil
+		},
+	})
+}
+
+// runExport writes export x to storage and records it completed.
+func runExport(ctx context.Context, deps *AppDependencies, x export) error {
+	var count int
+	size, err := deps.Exports.put(ctx, deps.Exports.objectKey(x.ID, x.Format), func(w io.Writer) error {
+		var err error
+		count, err = writeExport(ctx, deps, w, x.Format, x.CollectionCode)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = deps.Postgres.Exec(ctx, `
+UPDATE exports SET status = 'completed', hadiths = $2, size_bytes = $3, error = NULL, completed_at = now() WHERE id = $1`, x.ID, count, size)
+	return err
+}
+
+func writeExport(ctx context.Context, deps *AppDependencies, w io.Writer, format string, collection *string) (int, error) {
+	rows, err := deps.Postgres.Query(ctx, `
+SELECT `+postgres.HadithColumns+`
+FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+WHERE ($1::text IS NULL OR c.code = $1) AND `+postgres.Published("h")+` AND `+postgres.TenantWhere("h", 2)+`
+ORDER BY h.id`, collection, tenant.From(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var cw *csv.Writer
+	enc := json.NewEncoder(w)
+	if format == "csv" {
+		cw = csv.NewWriter(w)
+		cw.Write([]string{"id", "collection_code", "number", "text_ar", "text_ru", "text_en", "text_translit", "grade", "topics", "updated_at"})
+	}
+	deref := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	count := 0
+	for rows.Next() {
+		h, err := postgres.ScanHadith(rows)
+		if err != nil {
+			return count, err
+		}
+		if cw != nil {
+			err = cw.Write([]string{
+				strconv.FormatInt(h.ID, 10), h.CollectionCode, h.Number,
+				deref(h.TextAr), deref(h.TextRu), deref(h.TextEn), deref(h.TextTranslit), deref(h.Grade),
+				strings.Join(h.Topics, ";"), h.UpdatedAt.UTC().Format(time.RFC3339),
+			})
+		} else {
+			err = enc.Encode(h)
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	if cw != nil {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return count, err
+		}
+	}
+	return count, rows.Err()
+}
+
+// withDownloadURL adds a signed link to completed exports.
+func withDownloadURL(c echo.Context, deps *AppDependencies, x export, ttl time.Duration) export {
+	if x.Status == "completed" {
+		exp := time.Now().Add(ttl).Truncate(time.Second)
+		u := deps.Exports.signedURL(c, x.ID, exp)
+		x.DownloadURL, x.DownloadExpiresAt = &u, &exp
+	}
+	return x
+}
+
+func parseExportTTL(s string) (time.Duration, error) {
+	if s == "" {
+		return exportDefaultTTL, nil
+	}
+	ttl, err := time.ParseDuration(s)
+	if err != nil || ttl <= 0 || ttl > exportMaxTTL {
+		return 0, fmt.Errorf("ttl must be a duration up to %s", exportMaxTTL)
+	}
+	return ttl, nil
+}
+
+func registerExportRoutes(e *echo.Echo, admin *echo.Group, deps *AppDependencies) {
+	admin.POST("/exports", func(c echo.Context) error {
+		var req exportCreateRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		if req.Format == "" {
+			req.Format = "ndjson"
+		}
+		auditNote(c, "export.create", map[string]any{"collection": req.Collection, "format": req.Format})
+		ctx := c.Request().Context()
+		if deps.Exports.s3 == nil {
+			// Any replica may run the job and serve the download, so a
+			// local directory only works with a single replica.
+			replicas, err := deps.Replicas.list(ctx)
+			if err != nil {
+				return apierr.Database("db query failed")
+			}
+			if len(replicas) > 1 {
+				return apierr.New(http.StatusServiceUnavailable, apierr.CodeNotConfigured, "exports need S3 storage when more than one replica runs")
+			}
+		}
+		var createdBy string
+		if p := currentPrincipal(c); p != nil {
+			createdBy = p.Name
+		}
+		x, err := scanExport(deps.Postgres.QueryRow(ctx, `
+INSERT INTO exports (id, status, collection_code, format, created_by, tenant_id)
+VALUES ($1, 'pending', $2, $3, $4, $5)
+RETURNING `+exportColumns, uuid.NewString(), postgres.NullString(req.Collection), req.Format, postgres.NullString(createdBy), tenant.From(ctx)))
+		if err != nil {
+			return apierr.Database("db insert export failed")
+		}
+		auditResource(c, "export:"+x.ID)
+		if _, err := deps.Jobs.Enqueue(ctx, jobExportRun, exportJobArgs{ID: x.ID}); err != nil {
+			if _, err := deps.Postgres.Exec(ctx, `UPDATE exports SET status = 'failed', error = 'enqueue failed', completed_at = now() WHERE id = $1`, x.ID); err != nil {
+				slog.ErrorContext(ctx, "export: record status failed", "export_id", x.ID, "error", err)
+			}
+			return apierr.Database("db insert job failed")
+		}
+		c.Response().Header().Set(echo.HeaderLocation, "/v1/admin/exports/"+x.ID)
+		return c.JSON(http.StatusAccepted, x)
+	})
+
+	admin.GET("/exports", func(c echo.Context) error {
+		rows, err := deps.Postgres.Query(c.Request().Context(), `SELECT `+exportColumns+` FROM exports x WHERE `+postgres.TenantWhere("x", 1)+` ORDER BY created_at DESC LIMIT 100`, tenant.From(c.Request().Context()))
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		defer rows.Close()
+		exports := []export{}
+		for rows.Next() {
+			x, err := scanExport(rows)
+			if err != nil {
+				return apierr.Database("db scan failed")
+			}
+			exports = append(exports, withDownloadURL(c, deps, x, exportDefaultTTL))
+		}
+		if rows.Err() != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, exportListResponse{Exports: exports})
+	})
+
+	// ?ttl= picks the lifetime of the returned link, e.g. 1h or 72h.
+	admin.GET("/exports/:id", func(c echo.Context) error {
+		ttl, err := parseExportTTL(c.QueryParam("ttl"))
+		if err != nil {
+			return apierr.InvalidArgument(err.Error())
+		}
+		x, err := getExport(c.Request().Context(), deps, c.Param("id"))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("export not found")
+		}
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, withDownloadURL(c, deps, x, ttl))
+	})
+
+	e.GET("/v1/exports/:id/download", func(c echo.Context) error {
+		id := c.Param("id")
+		if !deps.Exports.verify(id, c.QueryParam("expires"), c.QueryParam("sig")) {
+			return apierr.Forbidden("invalid or expired download link")
+		}
+		// The signature grants access, whichever tenant the export belongs to.
+		x, err := getExport(tenant.Unscoped(c.Request().Context()), deps, id)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && x.Status != "completed") {
+			return apierr.NotFound("export not found")
+		}
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		f, modTime, err := deps.Exports.open(c.Request().Context(), deps.Exports.objectKey(x.ID, x.Format))
+		if err != nil {
+			return apierr.New(http.StatusBadGateway, apierr.CodeStorageFailed, "export unavailable")
+		}
+		defer f.Close()
+
+		contentType := "application/x-ndjson"
+		if x.Format == "csv" {
+			contentType = "text/csv; charset=utf-8"
+		}
+		h := c.Response().Header()
+		h.Set(echo.HeaderContentType, contentType)
+		h.Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="hadiths-%s.%s"`, x.ID, x.Format))
+		h.Set("ETag", `"`+x.ID+`"`)
+		http.ServeContent(c.Response(), c.Request(), "", modTime, f)
+		return nil
+	})
+}
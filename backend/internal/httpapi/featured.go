@@ -0,0 +1,225 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const featuredColumns = `f.id, f.hadith_id, fc.id, fc.code, fc.title, (SELECT count(*) FROM hadiths x WHERE x.collection_id = fc.id AND x.publish_at IS NULL),
+  f.headline, f.position, to_char(f.starts_on, 'YYYY-MM-DD'), to_char(f.ends_on, 'YYYY-MM-DD'), f.hijri_month, f.updated_at`
+
+const featuredFrom = `featured_items f LEFT JOIN hadith_collections fc ON fc.id = f.collection_id`
+
+// scanFeatured leaves only the id of a featured hadith; withFeaturedHadiths
+// fills in the rest.
+func scanFeatured(row pgx.Row) (featuredItem, error) {
+	var (
+		f               featuredItem
+		hadithID, colID *int64
+		code, title     *string
+		count           int64
+	)
+	err := row.Scan(&f.ID, &hadithID, &colID, &code, &title, &count, &f.Headline, &f.Position, &f.StartsOn, &f.EndsOn, &f.HijriMonth, &f.UpdatedAt)
+	if hadithID != nil {
+		f.Kind = "hadith"
+		f.Hadith = &Hadith{ID: *hadithID}
+	} else if colID != nil {
+		f.Kind = "collection"
+		f.Collection = &store.Collection{ID: *colID, Code: *code, Title: *title, HadithCount: count}
+	}
+	return f, err
+}
+
+func withFeaturedHadiths(ctx context.Context, deps *AppDependencies, items []featuredItem) error {
+	var ids []int64
+	for _, f := range items {
+		if f.Hadith != nil {
+			ids = append(ids, f.Hadith.ID)
+		}
+	}
+	byID, err := hadithsByID(ctx, deps, ids)
+	if err != nil {
+		return err
+	}
+	for i, f := range items {
+		if f.Hadith != nil {
+			items[i].Hadith = byID[f.Hadith.ID]
+		}
+	}
+	return nil
+}
+
+func queryFeatured(ctx context.Context, deps *AppDependencies, where string, args ...any) ([]featuredItem, error) {
+	rows, err := deps.Postgres.Query(ctx, `
+SELECT `+featuredColumns+` FROM `+featuredFrom+`
+WHERE `+where+`
+ORDER BY f.position, f.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	items, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (featuredItem, error) { return scanFeatured(row) })
+	if err != nil {
+		return nil, err
+	}
+	return items, withFeaturedHadiths(ctx, deps, items)
+}
+
+// featuredTarget resolves what req features to a hadith id or a collection
+// id of the request's tenant.
+func featuredTarget(ctx context.Context, deps *AppDependencies, req featuredRequest) (hadithID, collectionID any, err error) {
+	if req.StartsOn != "" && req.EndsOn != "" && req.EndsOn < req.StartsOn {
+		return nil, nil, apierr.InvalidArgument("ends_on is before starts_on")
+	}
+	if req.HadithID != 0 {
+		if _, err := noteHadith(ctx, deps, req.HadithID); err != nil {
+			return nil, nil, err
+		}
+		return req.HadithID, nil, nil
+	}
+	col, err := deps.Store.Collection(ctx, req.Collection)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil, apierr.NotFound("collection not found")
+	}
+	if err != nil {
+		return nil, nil, apierr.Database("db query failed")
+	}
+	return nil, col.ID, nil
+}
+
+func featuredItemOf(ctx context.Context, deps *AppDependencies, id int64) (featuredItem, error) {
+	items, err := queryFeatured(ctx, deps, `f.id = $1 AND `+postgres.TenantWhere("f", 2), id, tenant.From(ctx))
+	if err != nil {
+		return featuredItem{}, apierr.Database("db query failed")
+	}
+	if len(items) == 0 {
+		return featuredItem{}, apierr.NotFound("featured item not found")
+	}
+	return items[0], nil
+}
+
+// registerFeaturedRoutes lets editors feature hadiths and collections, for
+// good or for a season, and serves what is featured today at GET
+// /v1/featured.
+func registerFeaturedRoutes(editor *echo.Group, public *publicAPI, deps *AppDependencies) {
+	public.GET("/v1/featured", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		day := time.Now().In(deps.DailyLocation)
+		if s := c.QueryParam("date"); s != "" {
+			d, err := time.ParseInLocation("2006-01-02", s, deps.DailyLocation)
+			if err != nil {
+				return apierr.InvalidArgument("invalid date")
+			}
+			day = d
+		}
+		hd := deps.Hijri.FromTime(day)
+		items, err := queryFeatured(ctx, deps, postgres.TenantWhere("f", 1)+`
+  AND (f.starts_on IS NULL OR f.starts_on <= $2::date) AND (f.ends_on IS NULL OR f.ends_on >= $2::date)
+  AND (f.hijri_month IS NULL OR f.hijri_month = $3)`, tenant.From(ctx), day.Format("2006-01-02"), hd.Month)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return respond(c, http.StatusOK, featuredResponse{Date: day.Format("2006-01-02"), HijriDate: hd.String(), Items: items})
+	})
+
+	g := editor.Group("/featured")
+
+	g.GET("", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		items, err := queryFeatured(ctx, deps, postgres.TenantWhere("f", 1), tenant.From(ctx))
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, featuredListResponse{Items: items})
+	})
+
+	g.POST("", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		var req featuredRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		if tenant.From(ctx) == tenant.All {
+			return apierr.InvalidArgument("editing needs a tenant")
+		}
+		hadithID, collectionID, err := featuredTarget(ctx, deps, req)
+		if err != nil {
+			return err
+		}
+		auditNote(c, "featured.create", map[string]any{"hadith_id": req.HadithID, "collection": req.Collection, "hijri_month": req.HijriMonth})
+		var id int64
+		err = deps.Postgres.QueryRow(ctx, `
+INSERT INTO featured_items (tenant_id, hadith_id, collection_id, headline, position, starts_on, ends_on, hijri_month)
+VALUES ($1, $2, $3, $4, $5, $6::date, $7::date, $8)
+RETURNING id`, tenant.From(ctx), hadithID, collectionID, req.Headline, req.Position,
+			postgres.NullString(req.StartsOn), postgres.NullString(req.EndsOn), req.HijriMonth).Scan(&id)
+		if err != nil {
+			return apierr.Database("db insert featured item failed")
+		}
+		auditResource(c, "featured:"+strconv.FormatInt(id, 10))
+		f, err := featuredItemOf(ctx, deps, id)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusCreated, f)
+	})
+
+	g.PUT("/:id", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		var req featuredRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		hadithID, collectionID, err := featuredTarget(ctx, deps, req)
+		if err != nil {
+			return err
+		}
+		auditNote(c, "featured.update", map[string]any{"hadith_id": req.HadithID, "collection": req.Collection, "hijri_month": req.HijriMonth}, "featured:"+c.Param("id"))
+		tag, err := deps.Postgres.Exec(ctx, `
+UPDATE featured_items f SET hadith_id = $2, collection_id = $3, headline = $4, position = $5,
+  starts_on = $6::date, ends_on = $7::date, hijri_month = $8, updated_at = now()
+WHERE f.id = $1 AND `+postgres.TenantWhere("f", 9), id, hadithID, collectionID, req.Headline, req.Position,
+			postgres.NullString(req.StartsOn), postgres.NullString(req.EndsOn), req.HijriMonth, tenant.From(ctx))
+		if err != nil {
+			return apierr.Database("db update featured item failed")
+		}
+		if tag.RowsAffected() == 0 {
+			return apierr.NotFound("featured item not found")
+		}
+		f, err := featuredItemOf(ctx, deps, id)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, f)
+	})
+
+	g.DELETE("/:id", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		auditNote(c, "featured.delete", nil, "featured:"+c.Param("id"))
+		tag, err := deps.Postgres.Exec(ctx, `DELETE FROM featured_items f WHERE f.id = $1 AND `+postgres.TenantWhere("f", 2), id, tenant.From(ctx))
+		if err != nil {
+			return apierr.Database("db delete featured item failed")
+		}
+		if tag.RowsAffected() == 0 {
+			return apierr.NotFound("featured item not found")
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+}
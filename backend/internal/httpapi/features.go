@@ -0,0 +1,50 @@
+package httpapi
+
+import (
+	"slices"
+	"sync/atomic"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/labstack/echo/v4"
+)
+
+// Optional parts of the API that FEATURES_DISABLED can switch off.
+const (
+	featureGraphQL      = "graphql"
+	featureMCP          = "mcp"
+	featureWebSocket    = "websocket"
+	featureFeeds        = "feeds"
+	featureRegistration = "registration"
+	featureShare        = "share"
+)
+
+// FeatureFlags tracks the disabled features; they can change while serving.
+type FeatureFlags struct {
+	disabled atomic.Pointer[[]string]
+}
+
+func newFeatureFlags(disabled []string) *FeatureFlags {
+	f := &FeatureFlags{}
+	f.setDisabled(disabled)
+	return f
+}
+
+func (f *FeatureFlags) setDisabled(disabled []string) {
+	f.disabled.Store(&disabled)
+}
+
+func (f *FeatureFlags) Enabled(name string) bool {
+	return !slices.Contains(*f.disabled.Load(), name)
+}
+
+// require answers 404 while feature name is disabled.
+func (f *FeatureFlags) require(name string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !f.Enabled(name) {
+				return apierr.NotFound(name + " is disabled")
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/labstack/echo/v4"
+)
+
+const feedEntries = 50
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Lang string `xml:"xml:lang,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+}
+
+type feedHadith struct {
+	Hadith
+	CreatedAt time.Time
+}
+
+// recentHadiths lists the most recently ingested hadiths of the tenant of
+// ctx, optionally limited to one collection.
+func recentHadiths(ctx context.Context, deps *AppDependencies, code string, limit int) ([]feedHadith, error) {
+	rows, err := deps.Postgres.Query(ctx, `
+SELECT `+postgres.HadithColumns+`, h.created_at
+FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+WHERE ($1 = '' OR c.code = $1) AND `+postgres.Published("h")+` AND `+postgres.TenantWhere("h", 3)+`
+ORDER BY h.created_at DESC, h.id DESC
+LIMIT $2`, code, limit, tenant.From(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []feedHadith{}
+	for rows.Next() {
+		var createdAt time.Time
+		h, err := postgres.ScanHadith(rows, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, feedHadith{Hadith: h, CreatedAt: createdAt})
+	}
+	return out, rows.Err()
+}
+
+func buildAtomFeed(baseURL, selfPath, id, title string, hadiths []feedHadith) atomFeed {
+	updated := time.Now().UTC()
+	if len(hadiths) > 0 {
+		updated = hadiths[0].CreatedAt.UTC()
+	}
+	feed := atomFeed{
+		ID:      id,
+		Title:   title,
+		Updated: updated.Format(time.RFC3339),
+		Links:   []atomLink{{Href: baseURL + selfPath, Rel: "self", Type: "application/atom+xml"}},
+		Author:  atomAuthor{Name: "Islam App"},
+		Entries: make([]atomEntry, 0, len(hadiths)),
+	}
+	for _, h := range hadiths {
+		entry := atomEntry{
+			ID:      fmt.Sprintf("urn:islamapp:hadith:%d", h.ID),
+			Title:   "Hadith " + hadithRef(h.Hadith),
+			Updated: h.CreatedAt.UTC().Format(time.RFC3339),
+			Links:   []atomLink{{Href: fmt.Sprintf("%s/v1/hadiths/%d", baseURL, h.ID), Rel: "alternate", Type: "application/json"}},
+		}
+		for _, t := range h.Topics {
+			entry.Categories = append(entry.Categories, atomCategory{Term: t})
+		}
+		if text, lang := hadithText(h.Hadith); text != "" {
+			entry.Summary = &atomText{Type: "text", Lang: lang, Body: truncateRunes(text, 280)}
+			entry.Content = &atomText{Type: "text", Lang: lang, Body: text}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return feed
+}
+
+func writeAtom(c echo.Context, feed atomFeed) error {
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return err
+	}
+	return c.Blob(http.StatusOK, "application/atom+xml; charset=utf-8", append([]byte(xml.Header), body...))
+}
+
+func registerFeedRoutes(public *publicAPI, deps *AppDependencies) {
+	public.GET("/v1/feeds/hadiths.atom", func(c echo.Context) error {
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+		defer cancel()
+
+		hadiths, err := recentHadiths(ctx, deps, "", feedEntries)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		base := c.Scheme() + "://" + c.Request().Host
+		return writeAtom(c, buildAtomFeed(base, c.Request().URL.Path, "urn:islamapp:feed:hadiths", "Recently added hadiths", hadiths))
+	}, deps.Features.require(featureFeeds))
+
+	// Echo has no "param plus suffix" routes, so the extension is split off here.
+	public.GET("/v1/feeds/collections/:file", func(c echo.Context) error {
+		code, ok := strings.CutSuffix(c.Param("file"), ".atom")
+		if !ok || code == "" {
+			return apierr.NotFound("feed not found")
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+		defer cancel()
+
+		collection, err := deps.Store.Collection(ctx, code)
+		if errors.Is(err, store.ErrNotFound) {
+			return apierr.NotFound("collection not found")
+		}
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		hadiths, err := recentHadiths(ctx, deps, code, feedEntries)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		base := c.Scheme() + "://" + c.Request().Host
+		return writeAtom(c, buildAtomFeed(base, c.Request().URL.Path, "urn:islamapp:feed:collection:"+code,
+			collection.Title+": recently added hadiths", hadiths))
+	}, deps.Features.require(featureFeeds))
+}
@@ -0,0 +1,122 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/labstack/echo/v4"
+)
+
+// hadithFields are the json names selectable with ?fields= on hadith
+// responses; id is always included.
+var hadithFields = func() map[string]bool {
+	fields := map[string]bool{}
+	t := reflect.TypeOf(Hadith{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		fields[name] = true
+	}
+	return fields
+}()
+
+// parseFields reads ?fields=a,b for the response v. Hadith responses accept
+// Hadith json names; on search the names refer to payload keys and are not
+// checked. Other responses have no fields to select and ignore it.
+func parseFields(c echo.Context, v any) ([]string, error) {
+	raw := c.QueryParam("fields")
+	if raw == "" {
+		return nil, nil
+	}
+	var check bool
+	switch v.(type) {
+	case Hadith, hadithListResponse, batchGetResponse, dailyHadithResponse:
+		check = true
+	case searchResponse:
+	default:
+		return nil, nil
+	}
+	var fields []string
+	for _, f := range strings.Split(raw, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		if check && !hadithFields[f] {
+			return nil, apierr.InvalidArgument("unknown field").WithDetails(map[string]string{"field": f})
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// projectFields returns the JSON form of v reduced to the selected fields:
+// hadith objects keep id plus fields, search results keep id and score plus
+// the selected payload keys. Other values are returned unchanged.
+func projectFields(v any, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return v, nil
+	}
+	keep := map[string]bool{}
+	for _, f := range fields {
+		keep[f] = true
+	}
+
+	var selector func(doc map[string]any)
+	switch v.(type) {
+	case Hadith:
+		selector = func(doc map[string]any) { selectKeys(doc, keep, "id") }
+	case hadithListResponse, batchGetResponse:
+		selector = func(doc map[string]any) {
+			eachObject(doc["hadiths"], func(h map[string]any) { selectKeys(h, keep, "id") })
+		}
+	case dailyHadithResponse:
+		selector = func(doc map[string]any) {
+			if h, ok := doc["hadith"].(map[string]any); ok {
+				selectKeys(h, keep, "id")
+			}
+		}
+	case searchResponse:
+		selector = func(doc map[string]any) {
+			eachObject(doc["results"], func(r map[string]any) {
+				if p, ok := r["payload"].(map[string]any); ok {
+					selectKeys(p, keep)
+				}
+			})
+		}
+	default:
+		return v, nil
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	selector(doc)
+	return doc, nil
+}
+
+func selectKeys(obj map[string]any, keep map[string]bool, always ...string) {
+	for k := range obj {
+		if !keep[k] && !slices.Contains(always, k) {
+			delete(obj, k)
+		}
+	}
+}
+
+func eachObject(list any, fn func(map[string]any)) {
+	items, _ := list.([]any)
+	for _, item := range items {
+		if obj, ok := item.(map[string]any); ok {
+			fn(obj)
+		}
+	}
+}
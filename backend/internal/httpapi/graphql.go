@@ -0,0 +1,382 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/i18n"
+	"github.com/buugaaga/test-cursor/backend/internal/search"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/labstack/echo/v4"
+)
+
+const graphqlSchema = `
+schema {
+  query: Query
+}
+
+type Query {
+  collections: [Collection!]!
+  collection(code: String!): Collection
+  hadith(id: ID!): Hadith
+  hadithByNumber(collection: String!, number: String!): Hadith
+  topics(limit: Int = 50): [Topic!]!
+  topic(name: String!): Topic
+  search(query: String!, limit: Int): [SearchHit!]!
+}
+
+type Collection {
+  id: ID!
+  code: String!
+  title: String!
+  hadithCount: Int!
+  hadiths(first: Int = 20, after: String): HadithConnection!
+}
+
+type HadithConnection {
+  nodes: [Hadith!]!
+  nextCursor: String
+}
+
+type Hadith {
+  id: ID!
+  number: String!
+  title: String
+  collection: Collection!
+  textAr: String
+  textRu: String
+  textEn: String
+  textTranslit: String
+  grade: String
+  topics: [Topic!]!
+  "Semantically closest hadiths from any collection."
+  parallels(limit: Int = 5): [SearchHit!]!
+}
+
+type Topic {
+  name: String!
+  hadithCount: Int!
+  hadiths(first: Int = 20): [Hadith!]!
+}
+
+type SearchHit {
+  id: ID!
+  score: Float!
+  title: String
+  snippet: String
+  hadith: Hadith
+}
+`
+
+const graphqlMaxPage = 100
+
+func clampFirst(n int32) int {
+	if n <= 0 || n > graphqlMaxPage {
+		return graphqlMaxPage
+	}
+	return int(n)
+}
+
+type gqlRoot struct {
+	deps *AppDependencies
+}
+
+func (r *gqlRoot) Collections(ctx context.Context) ([]*gqlCollection, error) {
+	collections, err := r.deps.Store.Collections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*gqlCollection, 0, len(collections))
+	for _, c := range collections {
+		out = append(out, &gqlCollection{deps: r.deps, c: c})
+	}
+	return out, nil
+}
+
+func (r *gqlRoot) Collection(ctx context.Context, args struct{ Code string }) (*gqlCollection, error) {
+	c, err := r.deps.Store.Collection(ctx, args.Code)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &gqlCollection{deps: r.deps, c: c}, nil
+}
+
+func (r *gqlRoot) Hadith(ctx context.Context, args struct{ ID graphql.ID }) (*gqlHadith, error) {
+	id, err := strconv.ParseInt(string(args.ID), 10, 64)
+	if err != nil {
+		return nil, apierr.InvalidArgument("invalid id")
+	}
+	h, err := r.deps.Store.Hadith(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &gqlHadith{deps: r.deps, h: h}, nil
+}
+
+func (r *gqlRoot) HadithByNumber(ctx context.Context, args struct{ Collection, Number string }) (*gqlHadith, error) {
+	h, err := r.deps.Store.HadithByNumber(ctx, args.Collection, args.Number)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &gqlHadith{deps: r.deps, h: h}, nil
+}
+
+func (r *gqlRoot) Topics(ctx context.Context, args struct{ Limit int32 }) ([]*gqlTopic, error) {
+	rows, err := r.deps.ReadPostgres.Query(ctx, `
+SELECT t, COUNT(*)
+FROM hadiths h, unnest(h.topics) AS t
+WHERE `+postgres.Published("h")+` AND `+postgres.TenantWhere("h", 2)+`
+GROUP BY t
+ORDER BY 2 DESC, t
+LIMIT $1`, clampFirst(args.Limit), tenant.From(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	topics := []*gqlTopic{}
+	for rows.Next() {
+		t := &gqlTopic{deps: r.deps}
+		if err := rows.Scan(&t.name, &t.count); err != nil {
+			return nil, err
+		}
+		topics = append(topics, t)
+	}
+	return topics, rows.Err()
+}
+
+func (r *gqlRoot) Topic(ctx context.Context, args struct{ Name string }) (*gqlTopic, error) {
+	t := &gqlTopic{deps: r.deps, name: args.Name}
+	err := r.deps.Postgres.QueryRow(ctx, `SELECT COUNT(*) FROM hadiths h WHERE $1 = ANY(h.topics) AND `+postgres.Published("h")+` AND `+postgres.TenantWhere("h", 2), args.Name, tenant.From(ctx)).Scan(&t.count)
+	if err != nil {
+		return nil, err
+	}
+	if t.count == 0 {
+		return nil, nil
+	}
+	return t, nil
+}
+
+func (r *gqlRoot) Search(ctx context.Context, args struct {
+	Query string
+	Limit *int32
+}) ([]*gqlSearchHit, error) {
+	if args.Query == "" {
+		return nil, apierr.InvalidArgument("empty query")
+	}
+	var limit int
+	if args.Limit != nil {
+		limit = int(*args.Limit)
+	}
+	limit, err := r.deps.Search.Limit(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	res, err := semanticSearch(ctx, r.deps, args.Query, limit)
+	if err != nil {
+		return nil, err
+	}
+	return newGQLSearchHits(r.deps, res.Hits), nil
+}
+
+type gqlCollection struct {
+	deps *AppDependencies
+	c    Collection
+}
+
+func (r *gqlCollection) ID() graphql.ID     { return graphql.ID(strconv.FormatInt(r.c.ID, 10)) }
+func (r *gqlCollection) Code() string       { return r.c.Code }
+func (r *gqlCollection) Title() string      { return r.c.Title }
+func (r *gqlCollection) HadithCount() int32 { return int32(r.c.HadithCount) }
+
+func (r *gqlCollection) Hadiths(ctx context.Context, args struct {
+	First int32
+	After *string
+}) (*gqlHadithConnection, error) {
+	var cursor *store.HadithCursor
+	if args.After != nil {
+		cursor = &store.HadithCursor{}
+		if err := decodeCursor(*args.After, cursor); err != nil {
+			return nil, apierr.InvalidArgument("invalid cursor")
+		}
+	}
+	hadiths, next, err := r.deps.Store.CollectionHadiths(ctx, r.c.Code, clampFirst(args.First), false, cursor)
+	if err != nil {
+		return nil, err
+	}
+	conn := &gqlHadithConnection{nodes: newGQLHadiths(r.deps, hadiths)}
+	if next != nil {
+		s := encodeCursor(next)
+		conn.nextCursor = &s
+	}
+	return conn, nil
+}
+
+type gqlHadithConnection struct {
+	nodes      []*gqlHadith
+	nextCursor *string
+}
+
+func (r *gqlHadithConnection) Nodes() []*gqlHadith { return r.nodes }
+func (r *gqlHadithConnection) NextCursor() *string { return r.nextCursor }
+
+type gqlHadith struct {
+	deps *AppDependencies
+	h    Hadith
+}
+
+func newGQLHadiths(deps *AppDependencies, hadiths []Hadith) []*gqlHadith {
+	out := make([]*gqlHadith, 0, len(hadiths))
+	for _, h := range hadiths {
+		out = append(out, &gqlHadith{deps: deps, h: h})
+	}
+	return out
+}
+
+func (r *gqlHadith) ID() graphql.ID        { return graphql.ID(strconv.FormatInt(r.h.ID, 10)) }
+func (r *gqlHadith) Number() string        { return r.h.Number }
+func (r *gqlHadith) Title() *string        { return r.h.Title }
+func (r *gqlHadith) TextAr() *string       { return r.h.TextAr }
+func (r *gqlHadith) TextRu() *string       { return r.h.TextRu }
+func (r *gqlHadith) TextEn() *string       { return r.h.TextEn }
+func (r *gqlHadith) TextTranslit() *string { return r.h.TextTranslit }
+func (r *gqlHadith) Grade() *string        { return r.h.Grade }
+
+func (r *gqlHadith) Collection(ctx context.Context) (*gqlCollection, error) {
+	c, err := r.deps.Store.Collection(ctx, r.h.CollectionCode)
+	if err != nil {
+		return nil, err
+	}
+	return &gqlCollection{deps: r.deps, c: c}, nil
+}
+
+func (r *gqlHadith) Topics() []*gqlTopic {
+	topics := make([]*gqlTopic, 0, len(r.h.Topics))
+	for _, name := range r.h.Topics {
+		topics = append(topics, &gqlTopic{deps: r.deps, name: name, count: -1})
+	}
+	return topics
+}
+
+func (r *gqlHadith) Parallels(ctx context.Context, args struct{ Limit int32 }) ([]*gqlSearchHit, error) {
+	limit := int(args.Limit)
+	if limit <= 0 || limit > 50 {
+		limit = 5
+	}
+	hits, err := r.deps.Search.Similar(ctx, r.h.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return newGQLSearchHits(r.deps, hits), nil
+}
+
+type gqlTopic struct {
+	deps  *AppDependencies
+	name  string
+	count int64
+}
+
+func (r *gqlTopic) Name() string { return r.name }
+
+func (r *gqlTopic) HadithCount(ctx context.Context) (int32, error) {
+	if r.count < 0 {
+		err := r.deps.Postgres.QueryRow(ctx, `SELECT COUNT(*) FROM hadiths h WHERE $1 = ANY(h.topics) AND `+postgres.Published("h")+` AND `+postgres.TenantWhere("h", 2), r.name, tenant.From(ctx)).Scan(&r.count)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return int32(r.count), nil
+}
+
+func (r *gqlTopic) Hadiths(ctx context.Context, args struct{ First int32 }) ([]*gqlHadith, error) {
+	rows, err := r.deps.Postgres.Query(ctx, `
+SELECT `+postgres.HadithColumns+`
+FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+WHERE $1 = ANY(h.topics) AND `+postgres.Published("h")+` AND `+postgres.TenantWhere("h", 3)+`
+ORDER BY h.id
+LIMIT $2`, r.name, clampFirst(args.First), tenant.From(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hadiths := []Hadith{}
+	for rows.Next() {
+		h, err := postgres.ScanHadith(rows)
+		if err != nil {
+			return nil, err
+		}
+		hadiths = append(hadiths, h)
+	}
+	return newGQLHadiths(r.deps, hadiths), rows.Err()
+}
+
+type gqlSearchHit struct {
+	deps *AppDependencies
+	hit  search.Hit
+}
+
+func newGQLSearchHits(deps *AppDependencies, hits []search.Hit) []*gqlSearchHit {
+	out := make([]*gqlSearchHit, 0, len(hits))
+	for _, h := range hits {
+		out = append(out, &gqlSearchHit{deps: deps, hit: h})
+	}
+	return out
+}
+
+func (r *gqlSearchHit) ID() graphql.ID { return graphql.ID(r.hit.ID) }
+func (r *gqlSearchHit) Score() float64 { return float64(r.hit.Score) }
+
+func (r *gqlSearchHit) payloadString(key string) *string {
+	v, ok := r.hit.Payload[key]
+	if !ok {
+		return nil
+	}
+	s := v.GetStringValue()
+	return &s
+}
+
+func (r *gqlSearchHit) Title(ctx context.Context) *string {
+	if _, ok := r.hit.Payload["title"]; !ok {
+		return nil
+	}
+	title := hitTitle(i18n.From(ctx), r.hit.Payload)
+	return &title
+}
+
+func (r *gqlSearchHit) Snippet() *string { return r.payloadString("snippet") }
+
+func (r *gqlSearchHit) Hadith(ctx context.Context) (*gqlHadith, error) {
+	if r.hit.Payload["origin_type"].GetStringValue() != "hadith" {
+		return nil, nil
+	}
+	h, err := r.deps.Store.Hadith(ctx, r.hit.Payload["origin_id"].GetIntegerValue())
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &gqlHadith{deps: r.deps, h: h}, nil
+}
+
+func registerGraphQLRoute(e *echo.Echo, deps *AppDependencies) {
+	schema := graphql.MustParseSchema(graphqlSchema, &gqlRoot{deps: deps},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(8),
+	)
+	e.POST("/graphql", echo.WrapHandler(&relay.Handler{Schema: schema}), deps.Features.require(featureGraphQL))
+}
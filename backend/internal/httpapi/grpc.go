@@ -0,0 +1,208 @@
+package httpapi
+
+//go:generate protoc -I ../../proto --go_out=../../proto --go_opt=paths=source_relative --go-grpc_out=../../proto --go-grpc_opt=paths=source_relative islamapp/v1/islamapp.proto
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/i18n"
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+	"github.com/buugaaga/test-cursor/backend/internal/search"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	islamappv1 "github.com/buugaaga/test-cursor/backend/proto/islamapp/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type grpcServer struct {
+	islamappv1.UnimplementedIslamAppServiceServer
+	deps *AppDependencies
+}
+
+func (s *grpcServer) Search(ctx context.Context, req *islamappv1.SearchRequest) (*islamappv1.SearchResponse, error) {
+	if req.GetQuery() == "" {
+		return nil, toGRPCError(ctx, apierr.InvalidArgument("empty query"))
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.deps.Timeouts.Search)
+	defer cancel()
+
+	limit, err := s.deps.Search.Limit(ctx, int(req.GetLimit()))
+	if err != nil {
+		return nil, toGRPCError(ctx, err)
+	}
+	res, err := semanticSearch(ctx, s.deps, req.GetQuery(), limit)
+	if err != nil {
+		return nil, toGRPCError(ctx, err)
+	}
+	if res.Degraded {
+		grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(headerSearchDegraded), "true"))
+	}
+	resp := &islamappv1.SearchResponse{Results: make([]*islamappv1.SearchResult, 0, len(res.Hits))}
+	for _, h := range res.Hits {
+		fields := make(map[string]any, len(h.Payload))
+		for k, v := range h.Payload {
+			fields[k] = payloadValue(v)
+		}
+		if _, ok := h.Payload["title"]; ok {
+			fields["title"] = hitTitle(i18n.From(ctx), h.Payload)
+		}
+		payload, err := structpb.NewStruct(fields)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "encode payload")
+		}
+		resp.Results = append(resp.Results, &islamappv1.SearchResult{Id: h.ID, Score: h.Score, Payload: payload})
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) UploadHadiths(ctx context.Context, req *islamappv1.UploadHadithsRequest) (*islamappv1.UploadHadithsResponse, error) {
+	if err := s.deps.Maintenance.err(); err != nil {
+		return nil, toGRPCError(ctx, err)
+	}
+	upload := ingest.HadithUploadRequest{
+		Collection: ingest.HadithUploadCollection{Code: req.GetCollection().GetCode(), Title: req.GetCollection().GetTitle()},
+		Hadiths:    make([]ingest.HadithUploadItem, 0, len(req.GetHadiths())),
+	}
+	for _, h := range req.GetHadiths() {
+		upload.Hadiths = append(upload.Hadiths, ingest.HadithUploadItem{
+			Number: h.GetNumber(),
+			TextAr: h.GetTextAr(),
+			TextRu: h.GetTextRu(),
+			TextEn: h.GetTextEn(),
+			Grade:  h.GetGrade(),
+			Topics: h.GetTopics(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.deps.Timeouts.Upload)
+	defer cancel()
+
+	resp, err := s.deps.Ingest.Ingest(ctx, &upload)
+	summary := uploadAuditSummary(&upload, resp)
+	summary["method"] = islamappv1.IslamAppService_UploadHadiths_FullMethodName
+	rec := auditRecord{
+		Action:    "hadiths.upload",
+		Resources: []string{"collection:" + upload.Collection.Code},
+		Summary:   summary,
+		Status:    errorStatus(err),
+	}
+	rec.Actor, _ = ctx.Value(principalKey{}).(*principal)
+	rec.RemoteIP = grpcPeerIP(ctx)
+	var apiErr *apierr.APIError
+	if errors.As(err, &apiErr) {
+		summary["error"] = apiErr.Message
+	}
+	s.deps.Audit.Record(ctx, rec)
+	if err != nil {
+		return nil, toGRPCError(ctx, err)
+	}
+	return &islamappv1.UploadHadithsResponse{Inserted: int32(resp.Inserted), Embedded: int32(resp.Embedded)}, nil
+}
+
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusConflict:              codes.AlreadyExists,
+	http.StatusRequestEntityTooLarge: codes.ResourceExhausted,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusBadGateway:            codes.Unavailable,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+	http.StatusInternalServerError:   codes.Internal,
+}
+
+func toGRPCError(ctx context.Context, err error) error {
+	var apiErr *apierr.APIError
+	if !errors.As(err, &apiErr) {
+		return status.Error(codes.Internal, "internal error")
+	}
+	code, ok := grpcCodes[apiErr.Status]
+	if !ok {
+		code = codes.Unknown
+	}
+	return status.Error(code, apiErr.Localize(i18n.From(ctx)).Message)
+}
+
+// grpcMethodRoles mirror the role requirements of the equivalent HTTP routes.
+// Credentials go in the "x-api-key" or "authorization: Bearer" metadata, and
+// the tenant code, as with the X-Tenant header, in "x-tenant"; messages are
+// in the language of "accept-language".
+var grpcMethodRoles = map[string]string{
+	islamappv1.IslamAppService_UploadHadiths_FullMethodName: roleEditor,
+}
+
+func grpcAuthInterceptor(deps *AppDependencies) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var apiKey, bearer, tenantCode string
+		md, _ := metadata.FromIncomingContext(ctx)
+		if v := md.Get("x-api-key"); len(v) > 0 {
+			apiKey = v[0]
+		}
+		if v := md.Get("authorization"); len(v) > 0 {
+			bearer, _ = strings.CutPrefix(v[0], "Bearer ")
+		}
+		if v := md.Get("x-tenant"); len(v) > 0 {
+			tenantCode = strings.TrimSpace(v[0])
+		}
+		if v := md.Get("accept-language"); len(v) > 0 {
+			ctx = i18n.With(ctx, i18n.Match(v[0]))
+		}
+		key := apiKey
+		if key == "" && strings.HasPrefix(bearer, apiKeyPrefix) {
+			key = bearer
+		}
+		var claims *userClaims
+		if key == "" && bearer != "" {
+			claims, _ = deps.Users.parse(bearer)
+		}
+		tenantID, err := deps.Tenants.resolve(ctx, tenantCode, key, claims)
+		if err != nil {
+			return nil, toGRPCError(ctx, err)
+		}
+		ctx = tenant.With(ctx, tenantID)
+		if key != "" {
+			// The key's tier sets its search limits, as in UsageTracker.middleware.
+			if id := deps.APIKeys.verify(ctx, key); id != nil {
+				ctx = search.WithTier(ctx, id.Tier)
+			}
+		}
+
+		role, ok := grpcMethodRoles[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+		if !ipAllowed(deps.AdminNetworks, grpcPeerIP(ctx)) {
+			return nil, toGRPCError(ctx, apierr.Forbidden("not allowed from this network"))
+		}
+		p, err := authorizeRole(ctx, deps, apiKey, bearer, role)
+		if err != nil {
+			return nil, toGRPCError(ctx, err)
+		}
+		return handler(context.WithValue(ctx, principalKey{}, p), req)
+	}
+}
+
+func startGRPCServer(addr string, deps *AppDependencies) (*grpc.Server, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := grpc.NewServer(grpc.UnaryInterceptor(grpcAuthInterceptor(deps)))
+	islamappv1.RegisterIslamAppServiceServer(srv, &grpcServer{deps: deps})
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			slog.Error("grpc server stopped", "error", err)
+		}
+	}()
+	return srv, nil
+}
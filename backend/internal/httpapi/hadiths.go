@@ -0,0 +1,207 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/cache"
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/labstack/echo/v4"
+)
+
+func hadithText(h Hadith) (text, lang string) {
+	deref := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	return ingest.PreferredText(map[string]string{"ru": deref(h.TextRu), "en": deref(h.TextEn), "ar": deref(h.TextAr)})
+}
+
+func hadithRef(h Hadith) string {
+	return fmt.Sprintf("%s %s", h.CollectionCode, h.Number)
+}
+
+func encodeCursor(v any) string {
+	b, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func parseLimit(s string, def, max int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid limit")
+	}
+	if n > max {
+		n = max
+	}
+	return n, nil
+}
+
+func registerHadithRoutes(e *echo.Echo, public *publicAPI, deps *AppDependencies) {
+	public.GET("/v1/hadiths/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+		defer cancel()
+
+		h, err := deps.Store.Hadith(ctx, id)
+		if errors.Is(err, store.ErrNotFound) {
+			return apierr.NotFound("hadith not found")
+		}
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		if notModified(c, hadithETag(c, h)) {
+			return c.NoContent(http.StatusNotModified)
+		}
+		return respond(c, http.StatusOK, h)
+	})
+
+	e.POST("/v1/hadiths/batch-get", func(c echo.Context) error {
+		var req batchGetRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+		defer cancel()
+
+		hadiths, missing, err := deps.Store.Hadiths(ctx, req.IDs)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return respond(c, http.StatusOK, batchGetResponse{Hadiths: hadiths, Missing: missing})
+	})
+
+	public.GET("/v1/collections", func(c echo.Context) error {
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+		defer cancel()
+
+		version, err := deps.Store.CollectionsVersion(ctx, "")
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		if notModified(c, weakETag("collections", version, c.Request().Header.Get(echo.HeaderAccept))) {
+			return c.NoContent(http.StatusNotModified)
+		}
+
+		collections, err := cache.Cached(ctx, deps.Cache, "collections", "all", func() ([]Collection, error) {
+			return deps.Store.Collections(ctx)
+		})
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return respond(c, http.StatusOK, collectionListResponse{Collections: collections})
+	})
+
+	public.GET("/v1/collections/:code/hadiths", func(c echo.Context) error {
+		limit, err := parseLimit(c.QueryParam("limit"), 20, 100)
+		if err != nil {
+			return apierr.InvalidArgument("invalid limit")
+		}
+		var desc bool
+		switch c.QueryParam("sort") {
+		case "", "number":
+		case "-number":
+			desc = true
+		default:
+			return apierr.InvalidArgument("invalid sort")
+		}
+		var cursor *store.HadithCursor
+		if s := c.QueryParam("cursor"); s != "" {
+			cursor = &store.HadithCursor{}
+			if err := decodeCursor(s, cursor); err != nil {
+				return apierr.InvalidArgument("invalid cursor")
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+		defer cancel()
+
+		code := c.Param("code")
+		version, err := deps.Store.CollectionsVersion(ctx, code)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		etag := weakETag("collection-hadiths", code, version, c.QueryParams().Encode(), c.Request().Header.Get(echo.HeaderAccept))
+		if notModified(c, etag) {
+			return c.NoContent(http.StatusNotModified)
+		}
+
+		hadiths, next, err := deps.Store.CollectionHadiths(ctx, code, limit, desc, cursor)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		if len(hadiths) == 0 && cursor == nil {
+			exists, err := deps.Store.CollectionExists(ctx, code)
+			if err != nil {
+				return apierr.Database("db query failed")
+			}
+			if !exists {
+				return apierr.NotFound("collection not found")
+			}
+		}
+
+		resp := hadithListResponse{Hadiths: hadiths}
+		if next != nil {
+			cursor := encodeCursor(next)
+			resp.NextCursor = &cursor
+		}
+		return respond(c, http.StatusOK, resp)
+	})
+
+	public.GET("/v1/collections/:code/hadiths/:number", func(c echo.Context) error {
+		code, number := c.Param("code"), c.Param("number")
+		if store.NormalizeNumber(number) == "" {
+			return apierr.InvalidArgument("invalid number")
+		}
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+		defer cancel()
+
+		h, err := deps.Store.HadithByNumber(ctx, code, number)
+		if err == nil {
+			if notModified(c, hadithETag(c, h)) {
+				return c.NoContent(http.StatusNotModified)
+			}
+			return respond(c, http.StatusOK, h)
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			return apierr.Database("db query failed")
+		}
+		candidates, err := deps.Store.CompositeNumbers(ctx, code, number)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		if len(candidates) == 1 {
+			if h, err := deps.Store.HadithByNumber(ctx, code, candidates[0]); err == nil {
+				if notModified(c, hadithETag(c, h)) {
+					return c.NoContent(http.StatusNotModified)
+				}
+				return respond(c, http.StatusOK, h)
+			}
+		}
+		return apierr.NotFound("hadith not found").WithDetails(map[string]any{"candidates": candidates})
+	})
+}
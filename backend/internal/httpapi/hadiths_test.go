@@ -0,0 +1,139 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/cache"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/store/storetest"
+	"github.com/labstack/echo/v4"
+)
+
+// newHadithServer serves the hadith and collection routes from an
+// in-memory store holding collection "bukhari" with hadiths 1 to n.
+func newHadithServer(t *testing.T, n int) (*echo.Echo, *storetest.Store) {
+	t.Helper()
+	s := &storetest.Store{}
+	s.AddCollection(store.Collection{Code: "bukhari", Title: "Sahih al-Bukhari"})
+	for i := 1; i <= n; i++ {
+		text := "Hadith text " + strconv.Itoa(i)
+		s.AddHadith(store.Hadith{CollectionCode: "bukhari", Number: strconv.Itoa(i), TextEn: &text, UpdatedAt: time.Unix(int64(i), 0)})
+	}
+	e := echo.New()
+	e.HTTPErrorHandler = httpErrorHandler
+	deps := &AppDependencies{Store: s, Cache: cache.NewResultCache(nil, nil)}
+	registerHadithRoutes(e, newPublicAPI(e, newRateLimiter(nil, nil), time.Minute, time.Minute), deps)
+	return e, s
+}
+
+func get(e *echo.Echo, path string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGetHadith(t *testing.T) {
+	e, _ := newHadithServer(t, 2)
+
+	rec := get(e, "/v1/hadiths/2")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var h Hadith
+	if err := json.Unmarshal(rec.Body.Bytes(), &h); err != nil {
+		t.Fatal(err)
+	}
+	if h.ID != 2 || h.Number != "2" || h.CollectionCode != "bukhari" {
+		t.Errorf("got %+v, want hadith 2 of bukhari", h)
+	}
+
+	etag := rec.Header().Get("ETag")
+	if rec := get(e, "/v1/hadiths/2", "If-None-Match", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match %s: status %d, want 304", etag, rec.Code)
+	}
+	if rec := get(e, "/v1/hadiths/3"); rec.Code != http.StatusNotFound {
+		t.Errorf("missing hadith: status %d, want 404", rec.Code)
+	}
+	if rec := get(e, "/v1/hadiths/x"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid id: status %d, want 400", rec.Code)
+	}
+}
+
+func TestListCollectionHadiths(t *testing.T) {
+	e, _ := newHadithServer(t, 5)
+
+	var numbers []string
+	path := "/v1/collections/bukhari/hadiths?limit=2"
+	for pages := 0; path != ""; pages++ {
+		if pages > 5 {
+			t.Fatal("cursor does not end")
+		}
+		rec := get(e, path)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", path, rec.Code, rec.Body)
+		}
+		var resp hadithListResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		for _, h := range resp.Hadiths {
+			numbers = append(numbers, h.Number)
+		}
+		path = ""
+		if resp.NextCursor != nil {
+			path = "/v1/collections/bukhari/hadiths?limit=2&cursor=" + *resp.NextCursor
+		}
+	}
+	if want := []string{"1", "2", "3", "4", "5"}; !slices.Equal(numbers, want) {
+		t.Errorf("pages list %v, want %v", numbers, want)
+	}
+
+	if rec := get(e, "/v1/collections/muslim/hadiths"); rec.Code != http.StatusNotFound {
+		t.Errorf("missing collection: status %d, want 404", rec.Code)
+	}
+	if rec := get(e, "/v1/collections/bukhari/hadiths?sort=title"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid sort: status %d, want 400", rec.Code)
+	}
+}
+
+func TestCollectionsETag(t *testing.T) {
+	e, s := newHadithServer(t, 1)
+
+	rec := get(e, "/v1/collections")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	if rec := get(e, "/v1/collections", "If-None-Match", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("unchanged: status %d, want 304", rec.Code)
+	}
+
+	s.AddCollection(store.Collection{Code: "muslim", Title: "Sahih Muslim"})
+	rec = get(e, "/v1/collections", "If-None-Match", etag)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("after a change: status %d, want 200", rec.Code)
+	}
+	var resp collectionListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Collections) != 2 {
+		t.Errorf("got %d collections, want 2", len(resp.Collections))
+	}
+	if rec.Header().Get("ETag") == etag {
+		t.Error("ETag unchanged after a collection was added")
+	}
+}
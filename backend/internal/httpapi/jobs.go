@@ -0,0 +1,252 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+	"github.com/buugaaga/test-cursor/backend/internal/jobs"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	jobReindex        = "reindex"
+	jobIndexBackfill  = "index.backfill"
+	jobWebhookDeliver = "webhook.deliver"
+	jobShadowBackfill = "shadow.backfill"
+	jobExportRun      = "export.run"
+)
+
+type reindexJobArgs struct {
+	Collection string `json:"collection,omitempty"`
+	DryRun     bool   `json:"dry_run,omitempty"`
+}
+
+type webhookJobArgs struct {
+	HookID  int64           `json:"hook_id"`
+	Event   string          `json:"event"`
+	EventID string          `json:"event_id"`
+	Body    json.RawMessage `json:"body"`
+}
+
+type backfillResult struct {
+	Missing    int                  `json:"missing"`
+	Duplicated int                  `json:"duplicated"`
+	Orphaned   int                  `json:"orphaned"`
+	Outdated   int                  `json:"outdated"`
+	Reindexed  ingest.ReindexResult `json:"reindexed"`
+}
+
+func registerJobKinds(deps *AppDependencies) {
+	deps.Jobs.Register(jobs.Kind{
+		Name:        jobReindex,
+		MaxAttempts: 3,
+		Backoff:     time.Minute,
+		Timeout:     6 * time.Hour,
+		Lock:        ingest.IndexLock,
+		Work: func(ctx context.Context, j *jobs.Job) (any, error) {
+			var args reindexJobArgs
+			if err := json.Unmarshal(j.Args, &args); err != nil {
+				return nil, jobs.Permanent(err)
+			}
+			if args.DryRun {
+				res, err := deps.Ingest.EstimateReindex(ctx, args.Collection)
+				if errors.Is(err, store.ErrNotFound) {
+					return nil, jobs.Permanent(err)
+				}
+				return res, err
+			}
+			deps.Webhooks.Publish(ctx, eventReindexStarted, map[string]any{
+				"source":     "job",
+				"job_id":     j.ID,
+				"collection": args.Collection,
+				"attempt":    j.Attempt,
+			})
+			res, err := deps.Ingest.Reindex(ctx, args.Collection)
+			if err != nil {
+				unknown := errors.Is(err, store.ErrNotFound)
+				deps.Webhooks.Publish(ctx, eventReindexFailed, map[string]any{
+					"source":     "job",
+					"job_id":     j.ID,
+					"collection": args.Collection,
+					"attempt":    j.Attempt,
+					"final":      unknown || j.Attempt >= j.MaxAttempts,
+					"error":      err.Error(),
+				})
+				if unknown {
+					return nil, jobs.Permanent(err)
+				}
+				return nil, err
+			}
+			deps.Webhooks.Publish(ctx, eventReindexCompleted, map[string]any{
+				"source":     "job",
+				"job_id":     j.ID,
+				"collection": args.Collection,
+				"hadiths":    res.Hadiths,
+			})
+			return res, nil
+		},
+	})
+
+	deps.Jobs.Register(jobs.Kind{
+		Name:        jobIndexBackfill,
+		MaxAttempts: 3,
+		Backoff:     time.Minute,
+		Timeout:     6 * time.Hour,
+		Lock:        ingest.IndexLock,
+		Work: func(ctx context.Context, j *jobs.Job) (any, error) {
+			report, err := deps.Ingest.Check(ctx)
+			if err != nil {
+				return nil, err
+			}
+			res := backfillResult{
+				Missing:    len(report.Missing),
+				Duplicated: len(report.Duplicated),
+				Orphaned:   len(report.Orphaned),
+				Outdated:   len(report.Outdated),
+			}
+			if report.OK() {
+				return res, nil
+			}
+			res.Reindexed, err = deps.Ingest.Repair(ctx, report)
+			return res, err
+		},
+	})
+
+	deps.Jobs.Register(jobs.Kind{
+		Name:        jobShadowBackfill,
+		MaxAttempts: 3,
+		Backoff:     time.Minute,
+		Timeout:     6 * time.Hour,
+		Lock:        ingest.IndexLock,
+		Work: func(ctx context.Context, j *jobs.Job) (any, error) {
+			res, err := deps.Ingest.BackfillShadow(ctx)
+			if errors.Is(err, ingest.ErrNoShadow) {
+				return nil, jobs.Permanent(err)
+			}
+			return res, err
+		},
+	})
+
+	deps.Jobs.Register(jobs.Kind{
+		Name:        jobWebhookDeliver,
+		MaxAttempts: webhookMaxAttempts,
+		Backoff:     webhookBaseBackoff,
+		Timeout:     30 * time.Second,
+		Work:        deps.Webhooks.deliverJob,
+	})
+
+	if deps.Webhooks.events != nil {
+		deps.Jobs.Register(jobs.Kind{
+			Name:        jobEventPublish,
+			MaxAttempts: 10,
+			Backoff:     webhookBaseBackoff,
+			Timeout:     30 * time.Second,
+			Work:        deps.Webhooks.events.publishJob,
+		})
+	}
+
+	deps.Jobs.Register(jobs.Kind{
+		Name:        jobNotifyDaily,
+		MaxAttempts: 5,
+		Backoff:     5 * time.Minute,
+		Timeout:     time.Minute,
+		Work:        deps.Subscriptions.deliverJob,
+	})
+
+	registerDuplicatesJobKind(deps)
+	registerAyahJobKind(deps)
+	registerBulkJobKinds(deps)
+	registerExportJobKind(deps)
+}
+
+func registerJobRoutes(admin *echo.Group, deps *AppDependencies) {
+	g := admin.Group("/jobs")
+
+	g.GET("", func(c echo.Context) error {
+		limit, err := parseLimit(c.QueryParam("limit"), 50, 500)
+		if err != nil {
+			return apierr.InvalidArgument("invalid limit")
+		}
+		f := jobs.Filter{State: c.QueryParam("state"), Kind: c.QueryParam("kind"), Limit: limit + 1}
+		if s := c.QueryParam("cursor"); s != "" {
+			var cur auditCursor
+			if err := decodeCursor(s, &cur); err != nil {
+				return apierr.InvalidArgument("invalid cursor")
+			}
+			f.BeforeID = cur.ID
+		}
+		list, err := deps.Jobs.List(c.Request().Context(), f)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		resp := jobListResponse{Jobs: list}
+		if len(list) > limit {
+			resp.Jobs = list[:limit]
+			cursor := encodeCursor(auditCursor{ID: list[limit-1].ID})
+			resp.NextCursor = &cursor
+		}
+		return c.JSON(http.StatusOK, resp)
+	})
+
+	g.GET("/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		j, err := deps.Jobs.Get(c.Request().Context(), id)
+		if errors.Is(err, jobs.ErrNotFound) {
+			return apierr.NotFound("job not found")
+		}
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, j)
+	})
+
+	g.POST("", func(c echo.Context) error {
+		var req jobCreateRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		var args any = struct{}{}
+		switch req.Kind {
+		case jobReindex:
+			args = reindexJobArgs{Collection: req.Collection, DryRun: req.DryRun}
+		case jobDuplicatesDetect:
+			args = duplicatesJobArgs{Threshold: req.Threshold}
+		}
+		auditNote(c, "job.create", map[string]any{"kind": req.Kind, "collection": req.Collection, "dry_run": req.DryRun})
+		j, err := deps.Jobs.Enqueue(c.Request().Context(), req.Kind, args)
+		if err != nil {
+			return apierr.Database("db insert job failed")
+		}
+		auditResource(c, "job:"+strconv.FormatInt(j.ID, 10))
+		return c.JSON(http.StatusAccepted, j)
+	})
+
+	g.POST("/:id/cancel", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		auditNote(c, "job.cancel", nil, "job:"+c.Param("id"))
+		j, err := deps.Jobs.Cancel(c.Request().Context(), id)
+		switch {
+		case errors.Is(err, jobs.ErrNotFound):
+			return apierr.NotFound("job not found")
+		case errors.Is(err, jobs.ErrFinished):
+			return apierr.New(http.StatusConflict, apierr.CodeConflict, fmt.Sprintf("job already %s", j.State))
+		case err != nil:
+			return apierr.Database("db update job failed")
+		}
+		return c.JSON(http.StatusOK, j)
+	})
+}
@@ -0,0 +1,267 @@
+package httpapi
+
+import (
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/logging"
+	"github.com/labstack/echo/v4"
+)
+
+// LogControl holds the logging knobs that can be changed at runtime through
+// /v1/admin/logging without restarting the server.
+type LogControl struct {
+	// debug ignores logging.Level so debug targets are logged at any level.
+	debug *slog.Logger
+
+	mu              sync.RWMutex
+	sampleRate      float64
+	debugRequestIDs map[string]time.Time
+	debugAPIKeys    map[string]time.Time // by hashAPIKey; keys are not kept
+}
+
+func newLogControl(format string, sampleRate float64) *LogControl {
+	return &LogControl{
+		debug:           slog.New(logging.NewHandler(os.Stderr, format, slog.LevelDebug)),
+		sampleRate:      sampleRate,
+		debugRequestIDs: map[string]time.Time{},
+		debugAPIKeys:    map[string]time.Time{},
+	}
+}
+
+func (lc *LogControl) levelName() string {
+	lvl := logging.Level.Level()
+	for name, l := range logging.Levels {
+		if l == lvl {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+func (lc *LogControl) settings() logSettings {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	now := time.Now()
+	return logSettings{
+		Level:             lc.levelName(),
+		SampleRate:        lc.sampleRate,
+		DebugRequestIDs:   activeKeys(lc.debugRequestIDs, now),
+		DebugAPIKeyHashes: activeKeys(lc.debugAPIKeys, now),
+		Now:               now.UTC(),
+	}
+}
+
+func activeKeys(m map[string]time.Time, now time.Time) []string {
+	keys := []string{}
+	for k, exp := range m {
+		if now.Before(exp) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// debugTarget reports whether the request was singled out for debug logging
+// by request id or API key.
+func (lc *LogControl) debugTarget(c echo.Context) bool {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	if len(lc.debugRequestIDs) == 0 && len(lc.debugAPIKeys) == 0 {
+		return false
+	}
+	now := time.Now()
+	if id := requestID(c); id != "" {
+		if exp, ok := lc.debugRequestIDs[id]; ok && now.Before(exp) {
+			return true
+		}
+	}
+	if key := apiKeyFromRequest(c.Request()); key != "" {
+		if exp, ok := lc.debugAPIKeys[hashAPIKey(key)]; ok && now.Before(exp) {
+			return true
+		}
+	}
+	return false
+}
+
+func requestID(c echo.Context) string {
+	if id := c.Request().Header.Get(echo.HeaderXRequestID); id != "" {
+		return id
+	}
+	return c.Response().Header().Get(echo.HeaderXRequestID)
+}
+
+// skipAccessLog is used as the access logger's Skipper: requests are logged
+// with probability sampleRate, debug targets always.
+func (lc *LogControl) skipAccessLog(c echo.Context) bool {
+	if lc.debugTarget(c) {
+		return false
+	}
+	lc.mu.RLock()
+	rate := lc.sampleRate
+	lc.mu.RUnlock()
+	if rate >= 1 {
+		return false
+	}
+	return rand.Float64() >= rate
+}
+
+// accessLogger logs one line per request. Handler errors are rendered here
+// so the logged status is the one the client receives.
+func (lc *LogControl) accessLogger() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if lc.skipAccessLog(c) {
+				return next(c)
+			}
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+			req, res := c.Request(), c.Response()
+			level := slog.LevelInfo
+			if res.Status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("route", c.Path()),
+				slog.String("uri", req.RequestURI),
+				slog.Int("status", res.Status),
+				slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.String("remote_ip", c.RealIP()),
+				slog.Int64("bytes_in", req.ContentLength),
+				slog.Int64("bytes_out", res.Size),
+				slog.String("user_agent", req.UserAgent()),
+			}
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+			slog.LogAttrs(req.Context(), level, "request", attrs...)
+			return nil
+		}
+	}
+}
+
+// debugLogger logs request headers and outcome for debug targets regardless
+// of the global level, so a single client can be traced in production.
+func (lc *LogControl) debugLogger() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !lc.debugTarget(c) {
+				return next(c)
+			}
+			start := time.Now()
+			err := next(c)
+			headers := map[string]string{}
+			for k := range c.Request().Header {
+				switch k {
+				case "Authorization", "Cookie", "X-Api-Key":
+					headers[k] = "[redacted]"
+				default:
+					headers[k] = c.Request().Header.Get(k)
+				}
+			}
+			status := c.Response().Status
+			if err != nil {
+				status = errorStatus(err)
+			}
+			attrs := []slog.Attr{
+				slog.String("method", c.Request().Method),
+				slog.String("route", c.Path()),
+				slog.String("uri", c.Request().RequestURI),
+				slog.Any("headers", headers),
+				slog.Int64("bytes_in", c.Request().ContentLength),
+				slog.Int("status", status),
+				slog.Int64("bytes_out", c.Response().Size),
+				slog.Int64("latency_ms", time.Since(start).Milliseconds()),
+			}
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+			lc.debug.LogAttrs(c.Request().Context(), slog.LevelDebug, "debug request", attrs...)
+			return err
+		}
+	}
+}
+
+func (lc *LogControl) apply(u logSettingsUpdate) error {
+	lvl, levelOK := slog.Level(0), false
+	if u.Level != nil {
+		if lvl, levelOK = logging.Levels[strings.ToLower(*u.Level)]; !levelOK {
+			return errors.New("invalid level")
+		}
+	}
+	if u.SampleRate != nil && (*u.SampleRate < 0 || *u.SampleRate > 1) {
+		return errors.New("sample_rate must be between 0 and 1")
+	}
+	if levelOK {
+		logging.Level.Set(lvl)
+	}
+	ttl := 15 * time.Minute
+	if u.TTLSeconds > 0 {
+		ttl = time.Duration(u.TTLSeconds) * time.Second
+	}
+	exp := time.Now().Add(ttl)
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if u.SampleRate != nil {
+		lc.sampleRate = *u.SampleRate
+	}
+	if u.ClearDebug {
+		lc.debugRequestIDs = map[string]time.Time{}
+		lc.debugAPIKeys = map[string]time.Time{}
+	}
+	for _, id := range u.DebugRequestIDs {
+		lc.debugRequestIDs[id] = exp
+	}
+	for _, key := range u.DebugAPIKeys {
+		lc.debugAPIKeys[hashAPIKey(key)] = exp
+	}
+	pruneExpired(lc.debugRequestIDs)
+	pruneExpired(lc.debugAPIKeys)
+	return nil
+}
+
+func pruneExpired(m map[string]time.Time) {
+	now := time.Now()
+	for k, exp := range m {
+		if !now.Before(exp) {
+			delete(m, k)
+		}
+	}
+}
+
+func registerLogControlRoutes(admin *echo.Group, lc *LogControl) {
+	admin.GET("/logging", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, lc.settings())
+	})
+
+	admin.PUT("/logging", func(c echo.Context) error {
+		var req logSettingsUpdate
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		auditNote(c, "logging.update", map[string]any{
+			"level":             req.Level,
+			"sample_rate":       req.SampleRate,
+			"debug_request_ids": req.DebugRequestIDs,
+			"debug_api_keys":    len(req.DebugAPIKeys),
+			"clear_debug":       req.ClearDebug,
+			"ttl_seconds":       req.TTLSeconds,
+		}, "logging")
+		if err := lc.apply(req); err != nil {
+			return apierr.InvalidArgument(err.Error())
+		}
+		return c.JSON(http.StatusOK, lc.settings())
+	})
+}
@@ -0,0 +1,30 @@
+package httpapi
+
+import (
+	"github.com/buugaaga/test-cursor/backend/internal/i18n"
+	"github.com/buugaaga/test-cursor/backend/internal/logging"
+	"github.com/labstack/echo/v4"
+)
+
+// requestContext stores the request id set by middleware.RequestID in the
+// request context, so records logged with it carry the id.
+func requestContext() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := i18n.With(c.Request().Context(), i18n.Match(c.Request().Header.Get("Accept-Language")))
+			if id := requestID(c); id != "" {
+				ctx = logging.WithRequestID(ctx, id)
+			}
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// slowLogExempt are routes that are slow by design: they hold a connection
+// open or stream a file.
+var slowLogExempt = map[string]bool{
+	"/v1/ws":                   true,
+	"/mcp":                     true,
+	"/v1/exports/:id/download": true,
+}
@@ -0,0 +1,96 @@
+package httpapi
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/labstack/echo/v4"
+)
+
+// readRoutes take POST without changing data, so they keep working in
+// maintenance mode; so does the toggle itself.
+var readRoutes = map[string]bool{
+	"/v1/search":            true,
+	"/v1/hadiths/batch-get": true,
+	"/graphql":              true,
+	"/mcp":                  true,
+	"/v1/auth/login":        true,
+	"/v1/admin/maintenance": true,
+}
+
+// Maintenance is the read-only mode set by MAINTENANCE_MODE or PUT
+// /v1/admin/maintenance, whichever changed last: while it is on, requests
+// that change data get 503 and reads and searches keep working.
+type Maintenance struct {
+	state atomic.Pointer[maintenanceState]
+}
+
+func newMaintenance(enabled bool, message string) *Maintenance {
+	m := &Maintenance{}
+	m.set(enabled, message)
+	return m
+}
+
+func (m *Maintenance) set(enabled bool, message string) maintenanceState {
+	st := maintenanceState{Enabled: enabled, Message: message}
+	if enabled {
+		if cur := m.state.Load(); cur != nil && cur.Enabled {
+			st.Since = cur.Since
+		} else {
+			now := time.Now().UTC()
+			st.Since = &now
+		}
+	}
+	m.state.Store(&st)
+	return st
+}
+
+// err is the error mutations get, nil while the mode is off.
+func (m *Maintenance) err() error {
+	st := m.state.Load()
+	if !st.Enabled {
+		return nil
+	}
+	return apierr.New(http.StatusServiceUnavailable, apierr.CodeMaintenance, st.Message)
+}
+
+// middleware rejects requests other than GET, HEAD, OPTIONS and readRoutes
+// while the mode is on.
+func (m *Maintenance) middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return next(c)
+			}
+			if readRoutes[c.Path()] {
+				return next(c)
+			}
+			if err := m.err(); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+func registerMaintenanceRoutes(admin *echo.Group, m *Maintenance) {
+	admin.GET("/maintenance", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, m.state.Load())
+	})
+
+	admin.PUT("/maintenance", func(c echo.Context) error {
+		var req maintenanceUpdate
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		message := m.state.Load().Message
+		if req.Message != nil {
+			message = *req.Message
+		}
+		auditNote(c, "maintenance.update", map[string]any{"enabled": req.Enabled, "message": message}, "maintenance")
+		return c.JSON(http.StatusOK, m.set(*req.Enabled, message))
+	})
+}
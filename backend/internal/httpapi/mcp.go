@@ -0,0 +1,134 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/i18n"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/labstack/echo/v4"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type mcpSearchInput struct {
+	Query string `json:"query" jsonschema:"free-text query in Arabic, Russian or English"`
+	Limit int    `json:"limit,omitempty" jsonschema:"maximum number of results; the default and cap are set per deployment"`
+}
+
+type mcpSearchHit struct {
+	Score          float32 `json:"score"`
+	HadithID       int64   `json:"hadith_id,omitempty"`
+	CollectionCode string  `json:"collection_code,omitempty"`
+	Number         string  `json:"number,omitempty"`
+	Title          string  `json:"title,omitempty"`
+	Snippet        string  `json:"snippet,omitempty"`
+}
+
+type mcpSearchOutput struct {
+	Results  []mcpSearchHit `json:"results"`
+	Degraded bool           `json:"degraded,omitempty" jsonschema:"set when the results are keyword matches because semantic search is unavailable"`
+}
+
+type mcpGetHadithInput struct {
+	ID int64 `json:"id" jsonschema:"hadith id as returned by search_hadiths"`
+}
+
+type mcpHadithByNumberInput struct {
+	Collection string `json:"collection" jsonschema:"collection code, e.g. bukhari"`
+	Number     string `json:"number" jsonschema:"hadith number as cited, e.g. 1234a"`
+}
+
+type mcpCollectionsOutput struct {
+	Collections []Collection `json:"collections"`
+}
+
+func mcpNotFound(err error, msg string) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return apierr.NotFound(msg)
+	}
+	return apierr.Database("db query failed")
+}
+
+// newMCPServer exposes the retrieval functions as MCP tools. Tool errors are
+// APIErrors and reach the client as error results carrying the message.
+func newMCPServer(deps *AppDependencies) *mcp.Server {
+	server := mcp.NewServer(&mcp.Implementation{Name: "islam-app", Version: "1.0.0"}, nil)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "search_hadiths",
+		Description: "Semantic search over the hadith corpus. Returns the closest hadiths with a text snippet.",
+	}, func(ctx context.Context, _ *mcp.CallToolRequest, in mcpSearchInput) (*mcp.CallToolResult, mcpSearchOutput, error) {
+		if strings.TrimSpace(in.Query) == "" {
+			return nil, mcpSearchOutput{}, apierr.InvalidArgument("empty query")
+		}
+		limit, err := deps.Search.Limit(ctx, in.Limit)
+		if err != nil {
+			return nil, mcpSearchOutput{}, err
+		}
+		res, err := semanticSearch(ctx, deps, in.Query, limit)
+		if err != nil {
+			return nil, mcpSearchOutput{}, err
+		}
+		out := mcpSearchOutput{Results: make([]mcpSearchHit, 0, len(res.Hits)), Degraded: res.Degraded}
+		for _, h := range res.Hits {
+			out.Results = append(out.Results, mcpSearchHit{
+				Score:          h.Score,
+				HadithID:       h.Payload["origin_id"].GetIntegerValue(),
+				CollectionCode: h.Payload["collection_code"].GetStringValue(),
+				Number:         h.Payload["number"].GetStringValue(),
+				Title:          hitTitle(i18n.From(ctx), h.Payload),
+				Snippet:        h.Payload["snippet"].GetStringValue(),
+			})
+		}
+		return nil, out, nil
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_hadith",
+		Description: "Full text (Arabic, Russian, English), grade and topics of a hadith by id.",
+	}, func(ctx context.Context, _ *mcp.CallToolRequest, in mcpGetHadithInput) (*mcp.CallToolResult, Hadith, error) {
+		h, err := deps.Store.Hadith(ctx, in.ID)
+		if err != nil {
+			return nil, Hadith{}, mcpNotFound(err, "hadith not found")
+		}
+		return nil, h, nil
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_hadith_by_number",
+		Description: "Look up a hadith by collection code and its cited number.",
+	}, func(ctx context.Context, _ *mcp.CallToolRequest, in mcpHadithByNumberInput) (*mcp.CallToolResult, Hadith, error) {
+		h, err := deps.Store.HadithByNumber(ctx, in.Collection, in.Number)
+		if err != nil {
+			return nil, Hadith{}, mcpNotFound(err, "hadith not found")
+		}
+		return nil, h, nil
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_collections",
+		Description: "List hadith collections with their codes and sizes.",
+	}, func(ctx context.Context, _ *mcp.CallToolRequest, _ struct{}) (*mcp.CallToolResult, mcpCollectionsOutput, error) {
+		collections, err := deps.Store.Collections(ctx)
+		if err != nil {
+			return nil, mcpCollectionsOutput{}, apierr.Database("db query failed")
+		}
+		return nil, mcpCollectionsOutput{Collections: collections}, nil
+	})
+
+	return server
+}
+
+func runMCPStdio(ctx context.Context, deps *AppDependencies) error {
+	return newMCPServer(deps).Run(ctx, &mcp.StdioTransport{})
+}
+
+func registerMCPRoute(e *echo.Echo, deps *AppDependencies) {
+	server := newMCPServer(deps)
+	handler := mcp.NewSSEHandler(func(*http.Request) *mcp.Server { return server }, nil)
+	e.GET("/mcp", echo.WrapHandler(handler), deps.Features.require(featureMCP))
+	e.POST("/mcp", echo.WrapHandler(handler), deps.Features.require(featureMCP))
+}
@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"maps"
+	"net/netip"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/logging"
+	"github.com/buugaaga/test-cursor/backend/internal/metrics"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func metricsMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			status := c.Response().Status
+			if err != nil {
+				status = errorStatus(err)
+			}
+			method := c.Request().Method
+			d := time.Since(start)
+			metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+			metrics.HTTPDuration.WithLabelValues(method, route).Observe(d.Seconds())
+			if !slowLogExempt[route] {
+				logging.Slow(c.Request().Context(), "request", logging.SlowThresholds.Request, d,
+					"method", method, "route", route, "status", status, "query_params", slices.Sorted(maps.Keys(c.QueryParams())))
+			}
+			return err
+		}
+	}
+}
+
+// registerMetricsRoute serves /metrics, optionally only to scrapers in nets.
+func registerMetricsRoute(e *echo.Echo, deps *AppDependencies, nets []netip.Prefix) {
+	prometheus.MustRegister(postgres.NewPoolCollector(deps.Postgres, "primary"))
+	if deps.ReadPostgres != deps.Postgres {
+		prometheus.MustRegister(postgres.NewPoolCollector(deps.ReadPostgres, "read"))
+	}
+	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()), requireNetwork(nets))
+}
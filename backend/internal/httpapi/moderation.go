@@ -0,0 +1,380 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/config"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// Moderation statuses of content and decisions on it.
+const (
+	moderationPending  = "pending"
+	moderationApproved = "approved"
+	moderationRejected = "rejected"
+)
+
+// moderationReadingList is the kind of moderation items for shared reading
+// lists, the only user text others see: notes stay private and feedback
+// has no text.
+const moderationReadingList = "reading_list"
+
+// Moderation checks text users publish against the MODERATION_* settings,
+// which reload while serving.
+type Moderation struct {
+	settings atomic.Pointer[config.Moderation]
+	client   *http.Client
+}
+
+func newModeration(s config.Moderation) *Moderation {
+	m := &Moderation{client: &http.Client{}}
+	m.set(s)
+	return m
+}
+
+func (m *Moderation) set(s config.Moderation) {
+	m.settings.Store(&s)
+}
+
+// check returns the status text gets and, unless approved, why.
+func (m *Moderation) check(ctx context.Context, text string) (string, []string) {
+	s := m.settings.Load()
+	if words := matchWords(text, s.Block); len(words) > 0 {
+		return moderationRejected, prefixed("block:", words)
+	}
+	reasons := prefixed("review:", matchWords(text, s.Review))
+	if s.ClassifierURL != "" {
+		categories, err := m.classify(ctx, s, text)
+		if err != nil {
+			reasons = append(reasons, "classifier:unavailable")
+		}
+		reasons = append(reasons, prefixed("classifier:", categories)...)
+	}
+	if len(reasons) == 0 && s.ReviewAll {
+		reasons = []string{"review_all"}
+	}
+	if len(reasons) > 0 {
+		return moderationPending, reasons
+	}
+	return moderationApproved, nil
+}
+
+// classify asks the classifier about text and returns the categories it
+// flagged it for, "flagged" when it names none.
+func (m *Moderation) classify(ctx context.Context, s *config.Moderation, text string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.ClassifierTimeout)
+	defer cancel()
+	body, _ := json.Marshal(map[string]string{"text": text})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.ClassifierURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("classifier: status %d", resp.StatusCode)
+	}
+	var out struct {
+		Flagged    bool     `json:"flagged"`
+		Categories []string `json:"categories"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	if !out.Flagged {
+		return nil, nil
+	}
+	if len(out.Categories) == 0 {
+		return []string{"flagged"}, nil
+	}
+	return out.Categories, nil
+}
+
+func prefixed(prefix string, list []string) []string {
+	out := make([]string, len(list))
+	for i, s := range list {
+		out[i] = prefix + s
+	}
+	return out
+}
+
+// matchWords returns the words found in text as whole words, ignoring
+// case; a word may be a phrase of several.
+func matchWords(text string, words []string) []string {
+	text = strings.ToLower(text)
+	var out []string
+	for _, w := range words {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if w == "" {
+			continue
+		}
+		for i := 0; ; {
+			k := strings.Index(text[i:], w)
+			if k < 0 {
+				break
+			}
+			start, end := i+k, i+k+len(w)
+			before, _ := utf8.DecodeLastRuneInString(text[:start])
+			after, _ := utf8.DecodeRuneInString(text[end:])
+			if !isWordRune(before) && !isWordRune(after) {
+				out = append(out, w)
+				break
+			}
+			i = start + 1
+		}
+	}
+	return out
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r))
+}
+
+// moderateReadingList checks list id, shared by user, and sets its
+// moderation status. Content a moderator or the pipeline already decided
+// keeps its decision; content held for review is queued in place of what
+// the list had queued before.
+func moderateReadingList(ctx context.Context, deps *AppDependencies, id, user int64) error {
+	var content string
+	var tenantID int64
+	err := deps.Postgres.QueryRow(ctx, `
+SELECT concat_ws(E'\n', l.title, NULLIF(l.description, ''),
+  (SELECT string_agg(i.note, E'\n' ORDER BY i.position) FROM reading_list_items i WHERE i.list_id = l.id AND i.note <> '')),
+  u.tenant_id
+FROM reading_lists l JOIN users u ON u.id = l.user_id
+WHERE l.id = $1`, id).Scan(&content, &tenantID)
+	if err != nil {
+		return err
+	}
+
+	var status string
+	err = deps.Postgres.QueryRow(ctx, `
+SELECT status FROM moderation_items
+WHERE kind = $1 AND ref_id = $2 AND content = $3 AND status <> 'pending'
+ORDER BY id DESC LIMIT 1`, moderationReadingList, id, content).Scan(&status)
+	decided := err == nil
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	var reasons []string
+	if !decided {
+		status, reasons = deps.Moderation.check(ctx, content)
+	}
+
+	tx, err := deps.Postgres.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `DELETE FROM moderation_items WHERE kind = $1 AND ref_id = $2 AND status = 'pending'`, moderationReadingList, id); err != nil {
+		return err
+	}
+	if !decided && status != moderationApproved {
+		var item int64
+		err := tx.QueryRow(ctx, `
+INSERT INTO moderation_items (tenant_id, kind, ref_id, user_id, content, status, reasons, decided_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, CASE WHEN $6 = 'pending' THEN NULL ELSE now() END)
+RETURNING id`, tenantID, moderationReadingList, id, user, content, status, reasons).Scan(&item)
+		if err != nil {
+			return err
+		}
+		if status == moderationRejected {
+			_, err := tx.Exec(ctx, `INSERT INTO moderation_decisions (item_id, decision, reason) VALUES ($1, $2, $3)`,
+				item, status, strings.Join(reasons, ", "))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := tx.Exec(ctx, `UPDATE reading_lists SET moderation = $2 WHERE id = $1`, id, status); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// withdrawModeration drops what a reading list had queued, as it is no
+// longer shared.
+func withdrawModeration(ctx context.Context, deps *AppDependencies, id int64) error {
+	_, err := deps.Postgres.Exec(ctx, `DELETE FROM moderation_items WHERE kind = $1 AND ref_id = $2 AND status = 'pending'`, moderationReadingList, id)
+	return err
+}
+
+const moderationItemColumns = `m.id, m.kind, m.ref_id, m.user_id, m.content, m.status, m.reasons, m.created_at, m.decided_at`
+
+func scanModerationItem(row pgx.Row) (moderationItem, error) {
+	var m moderationItem
+	err := row.Scan(&m.ID, &m.Kind, &m.RefID, &m.UserID, &m.Content, &m.Status, &m.Reasons, &m.CreatedAt, &m.DecidedAt)
+	return m, err
+}
+
+// registerModerationRoutes serves the review queue of moderated content
+// and the log of decisions on it. Only the latest item of a reading list
+// can be decided, as it is what the list shows.
+func registerModerationRoutes(editor *echo.Group, deps *AppDependencies) {
+	editor.GET("/moderation", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		status := c.QueryParam("status")
+		if status == "" {
+			status = moderationPending
+		}
+		if status != moderationPending && status != moderationApproved && status != moderationRejected {
+			return apierr.InvalidArgument("invalid status")
+		}
+		limit, err := parseLimit(c.QueryParam("limit"), 20, 100)
+		if err != nil {
+			return apierr.InvalidArgument("invalid limit")
+		}
+		var after int64
+		if s := c.QueryParam("cursor"); s != "" {
+			if err := decodeCursor(s, &after); err != nil {
+				return apierr.InvalidArgument("invalid cursor")
+			}
+		}
+		rows, err := deps.Postgres.Query(ctx, `
+SELECT `+moderationItemColumns+` FROM moderation_items m
+WHERE m.status = $1 AND m.id > $2 AND `+postgres.TenantWhere("m", 3)+`
+ORDER BY m.id
+LIMIT $4`, status, after, tenant.From(ctx), limit+1)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		items, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (moderationItem, error) { return scanModerationItem(row) })
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		resp := moderationListResponse{Items: items}
+		if len(items) > limit {
+			resp.Items = items[:limit]
+			cursor := encodeCursor(items[limit-1].ID)
+			resp.NextCursor = &cursor
+		}
+		return c.JSON(http.StatusOK, resp)
+	})
+
+	editor.POST("/moderation/:id/decision", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		var req moderationDecisionRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		status := moderationApproved
+		if req.Decision == "reject" {
+			status = moderationRejected
+		}
+		auditNote(c, "moderation.decide", map[string]any{"decision": status, "reason": req.Reason}, "moderation:"+strconv.FormatInt(id, 10))
+
+		tx, err := deps.Postgres.Begin(ctx)
+		if err != nil {
+			return apierr.Database("db update moderation failed")
+		}
+		defer tx.Rollback(ctx)
+		item, err := scanModerationItem(tx.QueryRow(ctx, `
+SELECT `+moderationItemColumns+` FROM moderation_items m
+WHERE m.id = $1 AND `+postgres.TenantWhere("m", 2)+`
+FOR UPDATE`, id, tenant.From(ctx)))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("moderation item not found")
+		}
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		var latest bool
+		if err := tx.QueryRow(ctx, `
+SELECT NOT EXISTS (SELECT 1 FROM moderation_items WHERE kind = $1 AND ref_id = $2 AND id > $3)`,
+			item.Kind, item.RefID, item.ID).Scan(&latest); err != nil {
+			return apierr.Database("db query failed")
+		}
+		if !latest {
+			return apierr.New(http.StatusConflict, apierr.CodeConflict, "the content has changed since")
+		}
+		item, err = scanModerationItem(tx.QueryRow(ctx, `
+UPDATE moderation_items m SET status = $2, decided_at = now() WHERE m.id = $1
+RETURNING `+moderationItemColumns, id, status))
+		if err != nil {
+			return apierr.Database("db update moderation failed")
+		}
+		var moderator *string
+		if p := currentPrincipal(c); p != nil {
+			moderator = &p.Name
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO moderation_decisions (item_id, decision, moderator, reason) VALUES ($1, $2, $3, $4)`,
+			id, status, moderator, strings.TrimSpace(req.Reason)); err != nil {
+			return apierr.Database("db update moderation failed")
+		}
+		if item.Kind == moderationReadingList {
+			if _, err := tx.Exec(ctx, `UPDATE reading_lists SET moderation = $2 WHERE id = $1`, item.RefID, status); err != nil {
+				return apierr.Database("db update moderation failed")
+			}
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return apierr.Database("db update moderation failed")
+		}
+		return c.JSON(http.StatusOK, item)
+	})
+
+	editor.GET("/moderation/decisions", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		limit, err := parseLimit(c.QueryParam("limit"), 50, 200)
+		if err != nil {
+			return apierr.InvalidArgument("invalid limit")
+		}
+		var before int64
+		if s := c.QueryParam("cursor"); s != "" {
+			if err := decodeCursor(s, &before); err != nil {
+				return apierr.InvalidArgument("invalid cursor")
+			}
+		}
+		var item int64
+		if s := c.QueryParam("item_id"); s != "" {
+			if item, err = strconv.ParseInt(s, 10, 64); err != nil {
+				return apierr.InvalidArgument("invalid item_id")
+			}
+		}
+		rows, err := deps.Postgres.Query(ctx, `
+SELECT d.id, d.item_id, m.kind, m.ref_id, d.decision, d.moderator, d.reason, d.created_at
+FROM moderation_decisions d JOIN moderation_items m ON m.id = d.item_id
+WHERE ($1 = 0 OR d.id < $1) AND ($2 = 0 OR d.item_id = $2) AND `+postgres.TenantWhere("m", 3)+`
+ORDER BY d.id DESC
+LIMIT $4`, before, item, tenant.From(ctx), limit+1)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		decisions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (moderationDecision, error) {
+			var d moderationDecision
+			err := row.Scan(&d.ID, &d.ItemID, &d.Kind, &d.RefID, &d.Decision, &d.Moderator, &d.Reason, &d.CreatedAt)
+			return d, err
+		})
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		resp := moderationDecisionListResponse{Decisions: decisions}
+		if len(decisions) > limit {
+			resp.Decisions = decisions[:limit]
+			cursor := encodeCursor(decisions[limit-1].ID)
+			resp.NextCursor = &cursor
+		}
+		return c.JSON(http.StatusOK, resp)
+	})
+}
@@ -0,0 +1,315 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	islamappv1 "github.com/buugaaga/test-cursor/backend/proto/islamapp/v1"
+	"github.com/labstack/echo/v4"
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	mimeXML      = "application/xml"
+	mimeProtobuf = "application/x-protobuf"
+)
+
+// protoEncoder is implemented by response types that have a protobuf
+// encoding in proto/islamapp/v1.
+type protoEncoder interface {
+	toProto() (proto.Message, error)
+}
+
+// asProtoEncoder is v as a protoEncoder. Hadith is defined in the store
+// package, so its encoding is attached here.
+func asProtoEncoder(v any) (protoEncoder, bool) {
+	if h, ok := v.(Hadith); ok {
+		return hadithEncoder(h), true
+	}
+	e, ok := v.(protoEncoder)
+	return e, ok
+}
+
+type hadithEncoder Hadith
+
+func (h hadithEncoder) toProto() (proto.Message, error) {
+	return hadithProto(Hadith(h)), nil
+}
+
+// respond writes v as JSON, XML or protobuf depending on the Accept header.
+// JSON is the default; protobuf is only offered for protoEncoder values and
+// always carries every field, ?fields= only trims JSON and XML.
+func respond(c echo.Context, status int, v any) error {
+	c.Response().Header().Set(echo.HeaderVary, echo.HeaderAccept)
+	fields, err := parseFields(c, v)
+	if err != nil {
+		return err
+	}
+	encoder, canProto := asProtoEncoder(v)
+	switch negotiate(c.Request().Header.Get(echo.HeaderAccept), canProto) {
+	case mimeXML:
+		projected, err := projectFields(v, fields)
+		if err != nil {
+			return err
+		}
+		body, err := marshalXML(projected)
+		if err != nil {
+			return err
+		}
+		return c.Blob(status, mimeXML+"; charset=utf-8", body)
+	case mimeProtobuf:
+		m, err := encoder.toProto()
+		if err != nil {
+			return err
+		}
+		body, err := proto.Marshal(m)
+		if err != nil {
+			return err
+		}
+		return c.Blob(status, mimeProtobuf, body)
+	case "":
+		return apierr.New(http.StatusNotAcceptable, apierr.CodeNotAcceptable, "no acceptable representation").
+			WithDetails(map[string][]string{"available": availableTypes(canProto)})
+	default:
+		projected, err := projectFields(v, fields)
+		if err != nil {
+			return err
+		}
+		return c.JSON(status, projected)
+	}
+}
+
+func availableTypes(canProto bool) []string {
+	types := []string{echo.MIMEApplicationJSON, mimeXML}
+	if canProto {
+		types = append(types, mimeProtobuf)
+	}
+	return types
+}
+
+// negotiate picks the client's most preferred available media type. An
+// empty Accept header or */* means JSON; "" means nothing matched.
+func negotiate(accept string, canProto bool) string {
+	if strings.TrimSpace(accept) == "" {
+		return echo.MIMEApplicationJSON
+	}
+	type ranged struct {
+		typ string
+		q   float64
+	}
+	var prefs []ranged
+	for _, part := range strings.Split(accept, ",") {
+		typ, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			prefs = append(prefs, ranged{typ, q})
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+	for _, p := range prefs {
+		switch p.typ {
+		case echo.MIMEApplicationJSON, "*/*", "application/*":
+			return echo.MIMEApplicationJSON
+		case mimeXML, "text/xml":
+			return mimeXML
+		case mimeProtobuf, "application/protobuf", "application/vnd.google.protobuf":
+			if canProto {
+				return mimeProtobuf
+			}
+		}
+	}
+	return ""
+}
+
+// marshalXML renders v through its JSON form, so json tags name the
+// elements: objects become nested elements, arrays repeat an <item> element
+// and nulls are omitted.
+func marshalXML(v any) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	if err := encodeXMLValue(enc, "response", doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeXMLValue(enc *xml.Encoder, name string, v any) error {
+	if v == nil {
+		return nil
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch val := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := encodeXMLValue(enc, xmlName(k), val[k]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range val {
+			if err := encodeXMLValue(enc, "item", item); err != nil {
+				return err
+			}
+		}
+	case string:
+		if err := enc.EncodeToken(xml.CharData(val)); err != nil {
+			return err
+		}
+	default:
+		if err := enc.EncodeToken(xml.CharData(toXMLScalar(val))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+func toXMLScalar(v any) string {
+	switch val := v.(type) {
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		return ""
+	}
+}
+
+// xmlName makes an arbitrary map key usable as an element name.
+func xmlName(k string) string {
+	var b strings.Builder
+	for i, r := range k {
+		switch {
+		case r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+		case i > 0 && (r == '-' || r == '.' || r >= '0' && r <= '9'):
+		default:
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+func hadithProto(h Hadith) *islamappv1.Hadith {
+	return &islamappv1.Hadith{
+		Id:             h.ID,
+		CollectionCode: h.CollectionCode,
+		Number:         h.Number,
+		TextAr:         h.TextAr,
+		TextRu:         h.TextRu,
+		TextEn:         h.TextEn,
+		Grade:          h.Grade,
+		Topics:         h.Topics,
+		UpdatedAt:      timestamppb.New(h.UpdatedAt),
+	}
+}
+
+func hadithsProto(hadiths []Hadith) []*islamappv1.Hadith {
+	out := make([]*islamappv1.Hadith, 0, len(hadiths))
+	for _, h := range hadiths {
+		out = append(out, hadithProto(h))
+	}
+	return out
+}
+
+func (r collectionListResponse) toProto() (proto.Message, error) {
+	m := &islamappv1.CollectionList{Collections: make([]*islamappv1.Collection, 0, len(r.Collections))}
+	for _, c := range r.Collections {
+		m.Collections = append(m.Collections, &islamappv1.Collection{Id: c.ID, Code: c.Code, Title: c.Title, HadithCount: c.HadithCount})
+	}
+	return m, nil
+}
+
+func (r hadithListResponse) toProto() (proto.Message, error) {
+	return &islamappv1.HadithList{Hadiths: hadithsProto(r.Hadiths), NextCursor: r.NextCursor}, nil
+}
+
+func (r batchGetResponse) toProto() (proto.Message, error) {
+	return &islamappv1.BatchGetHadithsResponse{Hadiths: hadithsProto(r.Hadiths), Missing: r.Missing}, nil
+}
+
+func (r dailyHadithResponse) toProto() (proto.Message, error) {
+	return &islamappv1.DailyHadith{Date: r.Date, HijriDate: r.HijriDate, Calendar: r.Calendar, Hadith: hadithProto(r.Hadith)}, nil
+}
+
+func (s *corpusStats) toProto() (proto.Message, error) {
+	m := &islamappv1.CorpusStats{
+		Collections:    make([]*islamappv1.CollectionStats, 0, len(s.Collections)),
+		Hadiths:        s.Hadiths,
+		Languages:      s.Languages,
+		Grades:         s.Grades,
+		IndexedVectors: s.IndexedVectors,
+		ComputedAt:     timestamppb.New(s.ComputedAt),
+	}
+	for _, c := range s.Collections {
+		m.Collections = append(m.Collections, &islamappv1.CollectionStats{
+			Code: c.Code, Title: c.Title, Hadiths: c.Hadiths, TextAr: c.TextAr, TextRu: c.TextRu, TextEn: c.TextEn,
+		})
+	}
+	return m, nil
+}
+
+func (r searchResponse) toProto() (proto.Message, error) {
+	m := &islamappv1.SearchResponse{Results: make([]*islamappv1.SearchResult, 0, len(r.Results))}
+	for _, res := range r.Results {
+		payload, err := searchPayloadStruct(res.Payload)
+		if err != nil {
+			return nil, err
+		}
+		m.Results = append(m.Results, &islamappv1.SearchResult{Id: res.ID, Score: res.Score, Payload: payload})
+	}
+	return m, nil
+}
+
+func searchPayloadStruct(payload map[string]any) (*structpb.Struct, error) {
+	fields := make(map[string]any, len(payload))
+	for k, v := range payload {
+		if qv, ok := v.(*qdrant.Value); ok {
+			v = payloadValue(qv)
+		}
+		fields[k] = v
+	}
+	return structpb.NewStruct(fields)
+}
@@ -0,0 +1,72 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/config"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc/peer"
+)
+
+// parseNetworks parses a comma-separated list of CIDR ranges; bare addresses
+// are taken as single hosts.
+func parseNetworks(s string) ([]netip.Prefix, error) {
+	var nets []netip.Prefix
+	for _, v := range config.SplitList(s) {
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(v)
+			if aerr != nil {
+				return nil, fmt.Errorf("invalid network %q", v)
+			}
+			p = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		nets = append(nets, p.Masked())
+	}
+	return nets, nil
+}
+
+// ipAllowed reports whether ip falls in one of nets; an empty list allows
+// every address.
+func ipAllowed(nets []netip.Prefix, ip string) bool {
+	if len(nets) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	return slices.ContainsFunc(nets, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
+
+// requireNetwork rejects clients outside nets before any credentials are
+// looked at. The client address is c.RealIP(), which only honours
+// X-Forwarded-For from proxies on private or loopback addresses.
+func requireNetwork(nets []netip.Prefix) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !ipAllowed(nets, c.RealIP()) {
+				return apierr.Forbidden("not allowed from this network")
+			}
+			return next(c)
+		}
+	}
+}
+
+func grpcPeerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,301 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const noteColumns = `id, hadith_id, body, highlights, created_at, updated_at`
+
+func scanNote(row pgx.Row) (hadithNote, error) {
+	var n hadithNote
+	err := row.Scan(&n.ID, &n.HadithID, &n.Body, &n.Highlights, &n.CreatedAt, &n.UpdatedAt)
+	return n, err
+}
+
+// checkHighlights rejects highlights of texts h does not have or past their
+// end.
+func checkHighlights(h Hadith, highlights []noteHighlight) error {
+	for _, hl := range highlights {
+		var text *string
+		switch hl.Field {
+		case "text_ar":
+			text = h.TextAr
+		case "text_ru":
+			text = h.TextRu
+		case "text_en":
+			text = h.TextEn
+		}
+		if text == nil {
+			return apierr.InvalidArgument(fmt.Sprintf("hadith has no %s to highlight", hl.Field))
+		}
+		if n := utf8.RuneCountInString(*text); hl.End > n {
+			return apierr.InvalidArgument(fmt.Sprintf("highlight %d-%d is past the end of %s (%d characters)", hl.Start, hl.End, hl.Field, n))
+		}
+	}
+	return nil
+}
+
+// noteHadith returns hadith id of the tenant of ctx.
+func noteHadith(ctx context.Context, deps *AppDependencies, id int64) (Hadith, error) {
+	h, err := deps.Store.Hadith(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return h, apierr.NotFound("hadith not found")
+	}
+	if err != nil {
+		return h, apierr.Database("db query failed")
+	}
+	return h, nil
+}
+
+// writeNotes writes all of user's notes, oldest first, as ndjson or csv.
+func writeNotes(ctx context.Context, deps *AppDependencies, w io.Writer, format string, user int64) error {
+	rows, err := deps.Postgres.Query(ctx, `
+SELECT n.id, n.hadith_id, n.body, n.highlights, n.created_at, n.updated_at, c.code, h.number
+FROM hadith_notes n JOIN hadiths h ON h.id = n.hadith_id JOIN hadith_collections c ON c.id = h.collection_id
+WHERE n.user_id = $1
+ORDER BY n.id`, user)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var cw *csv.Writer
+	enc := json.NewEncoder(w)
+	if format == "csv" {
+		cw = csv.NewWriter(w)
+		cw.Write([]string{"id", "hadith_id", "collection_code", "number", "body", "highlights", "created_at", "updated_at"})
+	}
+	for rows.Next() {
+		var n noteExport
+		if err := rows.Scan(&n.ID, &n.HadithID, &n.Body, &n.Highlights, &n.CreatedAt, &n.UpdatedAt, &n.CollectionCode, &n.Number); err != nil {
+			return err
+		}
+		if cw != nil {
+			highlights, _ := json.Marshal(n.Highlights)
+			err = cw.Write([]string{
+				strconv.FormatInt(n.ID, 10), strconv.FormatInt(n.HadithID, 10), n.CollectionCode, n.Number,
+				n.Body, string(highlights), n.CreatedAt.UTC().Format(time.RFC3339), n.UpdatedAt.UTC().Format(time.RFC3339),
+			})
+		} else {
+			err = enc.Encode(n)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if cw != nil {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// registerNoteRoutes serves the authenticated user's private notes on
+// hadiths under /v1/me/notes.
+func registerNoteRoutes(me *echo.Group, deps *AppDependencies) {
+	g := me.Group("/notes")
+
+	g.GET("", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		limit, err := parseLimit(c.QueryParam("limit"), 50, 200)
+		if err != nil {
+			return apierr.InvalidArgument("invalid limit")
+		}
+		var hadithID int64
+		if s := c.QueryParam("hadith_id"); s != "" {
+			if hadithID, err = strconv.ParseInt(s, 10, 64); err != nil || hadithID <= 0 {
+				return apierr.InvalidArgument("invalid hadith_id")
+			}
+		}
+		var before int64
+		if s := c.QueryParam("cursor"); s != "" {
+			var cur auditCursor
+			if err := decodeCursor(s, &cur); err != nil {
+				return apierr.InvalidArgument("invalid cursor")
+			}
+			before = cur.ID
+		}
+
+		rows, err := deps.Postgres.Query(ctx, `
+SELECT `+noteColumns+` FROM hadith_notes
+WHERE user_id = $1 AND ($2 = 0 OR hadith_id = $2) AND ($3 = 0 OR id < $3)
+ORDER BY id DESC
+LIMIT $4`, currentUser(c).UserID(), hadithID, before, limit+1)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		list, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (hadithNote, error) { return scanNote(row) })
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		resp := noteListResponse{Notes: list}
+		if len(list) > limit {
+			resp.Notes = list[:limit]
+			cursor := encodeCursor(auditCursor{ID: list[limit-1].ID})
+			resp.NextCursor = &cursor
+		}
+		ids := make([]int64, 0, len(resp.Notes))
+		for _, n := range resp.Notes {
+			ids = append(ids, n.HadithID)
+		}
+		byID, err := hadithsByID(ctx, deps, ids)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		for i, n := range resp.Notes {
+			resp.Notes[i].Hadith = byID[n.HadithID]
+		}
+		return c.JSON(http.StatusOK, resp)
+	})
+
+	g.GET("/export", func(c echo.Context) error {
+		format := c.QueryParam("format")
+		if format == "" {
+			format = "ndjson"
+		}
+		if format != "ndjson" && format != "csv" {
+			return apierr.InvalidArgument("invalid format, want ndjson or csv")
+		}
+		contentType := "application/x-ndjson"
+		if format == "csv" {
+			contentType = "text/csv; charset=utf-8"
+		}
+		ctx := c.Request().Context()
+		res := c.Response()
+		res.Header().Set(echo.HeaderContentType, contentType)
+		res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="notes.%s"`, format))
+		res.WriteHeader(http.StatusOK)
+		// Headers are sent; a failure can only cut the export short.
+		if err := writeNotes(ctx, deps, res, format, currentUser(c).UserID()); err != nil {
+			slog.ErrorContext(ctx, "notes export failed", "error", err)
+		}
+		return nil
+	})
+
+	g.GET("/:id", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		n, err := scanNote(deps.Postgres.QueryRow(ctx, `SELECT `+noteColumns+` FROM hadith_notes WHERE id = $1 AND user_id = $2`, id, currentUser(c).UserID()))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("note not found")
+		}
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		if h, err := deps.Store.Hadith(ctx, n.HadithID); err == nil {
+			n.Hadith = &h
+		}
+		return c.JSON(http.StatusOK, n)
+	})
+
+	g.POST("", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		var req noteCreateRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		h, err := noteHadith(ctx, deps, req.HadithID)
+		if err != nil {
+			return err
+		}
+		if err := checkHighlights(h, req.Highlights); err != nil {
+			return err
+		}
+		if req.Highlights == nil {
+			req.Highlights = []noteHighlight{}
+		}
+		n, err := scanNote(deps.Postgres.QueryRow(ctx, `
+INSERT INTO hadith_notes (user_id, hadith_id, body, highlights)
+VALUES ($1, $2, $3, $4)
+RETURNING `+noteColumns, currentUser(c).UserID(), req.HadithID, req.Body, req.Highlights))
+		if err != nil {
+			return apierr.Database("db insert note failed")
+		}
+		n.Hadith = &h
+		return c.JSON(http.StatusCreated, n)
+	})
+
+	g.PATCH("/:id", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		var req noteUpdate
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		user := currentUser(c).UserID()
+		var hadithID int64
+		err = deps.Postgres.QueryRow(ctx, `SELECT hadith_id FROM hadith_notes WHERE id = $1 AND user_id = $2`, id, user).Scan(&hadithID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("note not found")
+		}
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		h, err := noteHadith(ctx, deps, hadithID)
+		if err != nil {
+			return err
+		}
+		// highlights stays nil, keeping the current ones, unless given.
+		var highlights any
+		if req.Highlights != nil {
+			if err := checkHighlights(h, *req.Highlights); err != nil {
+				return err
+			}
+			if *req.Highlights == nil {
+				highlights = []noteHighlight{}
+			} else {
+				highlights = *req.Highlights
+			}
+		}
+		n, err := scanNote(deps.Postgres.QueryRow(ctx, `
+UPDATE hadith_notes SET body = COALESCE($3, body), highlights = COALESCE($4, highlights), updated_at = now()
+WHERE id = $1 AND user_id = $2
+RETURNING `+noteColumns, id, user, req.Body, highlights))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("note not found")
+		}
+		if err != nil {
+			return apierr.Database("db update note failed")
+		}
+		n.Hadith = &h
+		return c.JSON(http.StatusOK, n)
+	})
+
+	g.DELETE("/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		tag, err := deps.Postgres.Exec(c.Request().Context(), `DELETE FROM hadith_notes WHERE id = $1 AND user_id = $2`, id, currentUser(c).UserID())
+		if err != nil {
+			return apierr.Database("db delete note failed")
+		}
+		if tag.RowsAffected() == 0 {
+			return apierr.NotFound("note not found")
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+}
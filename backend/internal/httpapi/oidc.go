@@ -0,0 +1,398 @@
+package httpapi
+
+import (
+	"cmp"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/config"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/oauth2"
+)
+
+type oidcConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// RoleClaim is a dot-separated path to a string or string list in the ID
+	// token, e.g. "groups" or Keycloak's "realm_access.roles". Its values are
+	// mapped to local roles through RoleMap; the highest mapped role wins.
+	RoleClaim string
+	RoleMap   map[string]string
+	// PostLoginRedirect, when set, receives the local token in the URL
+	// fragment (#token=...&expires_at=...) instead of a JSON response.
+	PostLoginRedirect string
+}
+
+// OIDCProvider signs users in with an external OpenID Connect provider
+// (authorization code flow with PKCE) and issues them local access tokens.
+type OIDCProvider struct {
+	cfg     oidcConfig
+	oauth   oauth2.Config
+	jwksURL string
+	client  *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]any
+	fetchedAt time.Time
+}
+
+// parseRoleMap parses "provider-value=role,..." pairs.
+func parseRoleMap(s string) (map[string]string, error) {
+	m := map[string]string{}
+	for _, pair := range config.SplitList(s) {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || roleRank[v] == 0 {
+			return nil, fmt.Errorf("invalid role mapping %q", pair)
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+func newOIDCProvider(ctx context.Context, cfg oidcConfig) (*OIDCProvider, error) {
+	p := &OIDCProvider{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := p.getJSON(ctx, strings.TrimRight(cfg.Issuer, "/")+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if doc.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("discovery: issuer %q does not match %q", doc.Issuer, cfg.Issuer)
+	}
+	p.jwksURL = doc.JWKSURI
+	p.oauth = oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       cfg.Scopes,
+		Endpoint:     oauth2.Endpoint{AuthURL: doc.AuthorizationEndpoint, TokenURL: doc.TokenEndpoint},
+	}
+	return p, nil
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (any, error) {
+	b64 := base64.RawURLEncoding
+	switch k.Kty {
+	case "RSA":
+		n, err := b64.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := b64.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// key returns the provider's signing key kid, refetching the key set when
+// the kid is unknown (key rotation) at most once a minute.
+func (p *OIDCProvider) key(ctx context.Context, kid string) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if k, ok := p.keys[kid]; ok {
+		return k, nil
+	}
+	if time.Since(p.fetchedAt) < time.Minute {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.jwksURL, &set); err != nil {
+		return nil, err
+	}
+	p.fetchedAt = time.Now()
+	p.keys = map[string]any{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if pub, err := k.publicKey(); err == nil {
+			p.keys[k.Kid] = pub
+		}
+	}
+	if k, ok := p.keys[kid]; ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, raw, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(p.cfg.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims["nonce"] != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+	return claims, nil
+}
+
+// claimValues resolves a dot-separated path to a list of strings.
+func claimValues(claims map[string]any, path string) []string {
+	var v any = claims
+	for _, part := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[part]
+	}
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// mappedRole returns the local role granted by the token, or "" when role
+// mapping is not configured.
+func (p *OIDCProvider) mappedRole(claims jwt.MapClaims) string {
+	if p.cfg.RoleClaim == "" || len(p.cfg.RoleMap) == 0 {
+		return ""
+	}
+	var roles []string
+	for _, v := range claimValues(claims, p.cfg.RoleClaim) {
+		if r, ok := p.cfg.RoleMap[v]; ok {
+			roles = append(roles, r)
+		}
+	}
+	if r := highestRole(roles); r != "" {
+		return r
+	}
+	return roleReader
+}
+
+// oidcState travels in a short-lived signed cookie between the login
+// redirect and the callback.
+type oidcState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	// Tenant is the tenant the login started in; the provider's redirect
+	// carries no X-Tenant header.
+	Tenant int64 `json:"tid"`
+	jwt.RegisteredClaims
+}
+
+const oidcStateCookie = "oidc_state"
+
+// linkOIDCUser finds the local user of the tenant of ctx for the provider
+// subject. On first login a user with the same verified email is linked,
+// otherwise one is created. A mapped role, when configured, replaces the
+// stored one on every login.
+func linkOIDCUser(ctx context.Context, deps *AppDependencies, issuer string, claims jwt.MapClaims, role string) (user, error) {
+	tenantID := tenant.From(ctx)
+	sub, _ := claims["sub"].(string)
+	email, _ := claims["email"].(string)
+	verified, _ := claims["email_verified"].(bool)
+	name, _ := claims["name"].(string)
+	if sub == "" {
+		return user{}, apierr.Unauthorized("id token has no subject")
+	}
+
+	u, err := scanUser(deps.Postgres.QueryRow(ctx, `
+UPDATE users SET role = COALESCE($3, role)
+WHERE oidc_issuer = $1 AND oidc_subject = $2 AND tenant_id = $4
+RETURNING `+userColumns, issuer, sub, postgres.NullString(role), tenantID))
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return u, err
+	}
+	if email == "" || !verified {
+		return user{}, apierr.Forbidden("provider did not return a verified email")
+	}
+	u, err = scanUser(deps.Postgres.QueryRow(ctx, `
+UPDATE users SET oidc_issuer = $1, oidc_subject = $2, role = COALESCE($4, role)
+WHERE email = lower($3) AND oidc_subject IS NULL AND tenant_id = $5
+RETURNING `+userColumns, issuer, sub, email, postgres.NullString(role), tenantID))
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return u, err
+	}
+	if role == "" {
+		role = roleReader
+	}
+	return scanUser(deps.Postgres.QueryRow(ctx, `
+INSERT INTO users (email, display_name, role, oidc_issuer, oidc_subject, tenant_id)
+VALUES (lower($1), $2, $3, $4, $5, $6)
+RETURNING `+userColumns, email, postgres.NullString(name), role, issuer, sub, tenantID))
+}
+
+func registerOIDCRoutes(e *echo.Echo, deps *AppDependencies) {
+	p := deps.OIDC
+
+	e.GET("/v1/auth/oidc/login", func(c echo.Context) error {
+		tenantID := tenant.From(c.Request().Context())
+		if code := c.QueryParam("tenant"); code != "" {
+			id, err := deps.Tenants.byCode(c.Request().Context(), code)
+			if errors.Is(err, errTenantNotFound) {
+				return apierr.NotFound("unknown tenant " + code)
+			}
+			if err != nil {
+				return apierr.Database("tenant lookup failed")
+			}
+			tenantID = id
+		}
+		st := oidcState{
+			State:    oauth2.GenerateVerifier(),
+			Nonce:    oauth2.GenerateVerifier(),
+			Verifier: oauth2.GenerateVerifier(),
+			Tenant:   tenantID,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(10 * time.Minute)),
+			},
+		}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &st).SignedString(deps.Users.stateKey)
+		if err != nil {
+			return err
+		}
+		c.SetCookie(&http.Cookie{
+			Name:     oidcStateCookie,
+			Value:    signed,
+			Path:     "/v1/auth/oidc",
+			MaxAge:   600,
+			HttpOnly: true,
+			Secure:   c.Scheme() == "https",
+			SameSite: http.SameSiteLaxMode,
+		})
+		authURL := p.oauth.AuthCodeURL(st.State,
+			oauth2.S256ChallengeOption(st.Verifier),
+			oauth2.SetAuthURLParam("nonce", st.Nonce))
+		return c.Redirect(http.StatusFound, authURL)
+	})
+
+	e.GET("/v1/auth/oidc/callback", func(c echo.Context) error {
+		if msg := c.QueryParam("error"); msg != "" {
+			return apierr.Unauthorized("provider error: " + msg)
+		}
+		cookie, err := c.Cookie(oidcStateCookie)
+		if err != nil {
+			return apierr.InvalidArgument("missing login state, start at /v1/auth/oidc/login")
+		}
+		c.SetCookie(&http.Cookie{Name: oidcStateCookie, Path: "/v1/auth/oidc", MaxAge: -1})
+		var st oidcState
+		_, err = jwt.ParseWithClaims(cookie.Value, &st, func(*jwt.Token) (any, error) { return deps.Users.stateKey, nil },
+			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+		if err != nil || st.State != c.QueryParam("state") {
+			return apierr.InvalidArgument("invalid or expired login state")
+		}
+
+		ctx, cancel := context.WithTimeout(tenant.With(c.Request().Context(), cmp.Or(st.Tenant, tenant.Default)), 30*time.Second)
+		defer cancel()
+		tok, err := p.oauth.Exchange(context.WithValue(ctx, oauth2.HTTPClient, p.client), c.QueryParam("code"),
+			oauth2.VerifierOption(st.Verifier))
+		if err != nil {
+			return apierr.Unauthorized("code exchange failed")
+		}
+		rawIDToken, _ := tok.Extra("id_token").(string)
+		claims, err := p.verifyIDToken(ctx, rawIDToken, st.Nonce)
+		if err != nil {
+			return apierr.Unauthorized("invalid id token")
+		}
+
+		u, err := linkOIDCUser(ctx, deps, p.cfg.Issuer, claims, p.mappedRole(claims))
+		if err != nil {
+			var apiErr *apierr.APIError
+			if errors.As(err, &apiErr) {
+				return err
+			}
+			return apierr.Database("db upsert user failed")
+		}
+		token, exp, err := deps.Users.issue(u)
+		if err != nil {
+			return err
+		}
+		if p.cfg.PostLoginRedirect != "" {
+			fragment := url.Values{"token": {token}, "expires_at": {exp.UTC().Format(time.RFC3339)}}
+			return c.Redirect(http.StatusFound, p.cfg.PostLoginRedirect+"#"+fragment.Encode())
+		}
+		return c.JSON(http.StatusOK, authResponse{User: u, Token: token, ExpiresAt: exp})
+	})
+}
@@ -0,0 +1,640 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+	"github.com/buugaaga/test-cursor/backend/internal/jobs"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/labstack/echo/v4"
+)
+
+type apiParam struct {
+	Name        string
+	Description string
+}
+
+// apiOperation documents one route for /openapi.json. Request and Response
+// hold a zero value of the body type; path parameters are derived from the
+// route itself.
+type apiOperation struct {
+	Summary  string
+	Tag      string
+	Query    []apiParam
+	Request  any
+	Response any
+}
+
+var (
+	hadithFieldsParam = apiParam{Name: "fields", Description: "Comma-separated hadith fields to return, e.g. number,text_en (id is always included)"}
+	searchFieldsParam = apiParam{Name: "fields", Description: "Comma-separated payload keys to return, e.g. title,snippet"}
+)
+
+var apiOperations = map[string]apiOperation{
+	"GET /healthz": {Summary: "Liveness probe", Tag: "system"},
+	"GET /readyz": {
+		Summary: "Readiness probe: pings Postgres, Qdrant (unless vectors are in pgvector) and the embedder (503 when any is down)", Tag: "system",
+		Response: readinessResponse{},
+	},
+	"GET /metrics": {Summary: "Prometheus metrics", Tag: "system"},
+	"POST /v1/search": {
+		Summary: "Semantic search over indexed documents", Tag: "search",
+		Query:   []apiParam{searchFieldsParam},
+		Request: searchRequest{}, Response: searchResponse{},
+	},
+	"POST /v1/auth/register": {
+		Summary: "Create a user account and return an access token", Tag: "auth",
+		Request: registerRequest{}, Response: authResponse{},
+	},
+	"POST /v1/auth/login": {Summary: "Exchange email and password for an access token", Tag: "auth", Request: loginRequest{}, Response: authResponse{}},
+	"GET /v1/me":          {Summary: "The authenticated user", Tag: "auth", Response: user{}},
+	"PATCH /v1/me":        {Summary: "Change the authenticated user's profile and whether their searches are kept", Tag: "auth", Request: profileUpdate{}, Response: user{}},
+	"GET /v1/me/bookmarks": {
+		Summary: "The user's bookmarks, newest first; filter by kind (hadith, ayah) or folder_id (0 for none)", Tag: "bookmarks",
+		Response: bookmarkListResponse{},
+	},
+	"POST /v1/me/bookmarks": {
+		Summary: "Bookmark a hadith (hadith_id) or an ayah (surah and ayah); 409 when already bookmarked", Tag: "bookmarks",
+		Request: bookmarkCreateRequest{}, Response: bookmark{},
+	},
+	"PATCH /v1/me/bookmarks/:id": {
+		Summary: "Move a bookmark to a folder (0 for none) or change its note", Tag: "bookmarks",
+		Request: bookmarkUpdate{}, Response: bookmark{},
+	},
+	"DELETE /v1/me/bookmarks/:id": {Summary: "Delete a bookmark", Tag: "bookmarks"},
+	"GET /v1/me/notes": {
+		Summary: "The user's notes on hadiths, newest first; filter by hadith_id", Tag: "notes",
+		Response: noteListResponse{},
+	},
+	"POST /v1/me/notes": {
+		Summary: "Add a private note, with optional highlights (character ranges of the hadith's texts), to a hadith", Tag: "notes",
+		Request: noteCreateRequest{}, Response: hadithNote{},
+	},
+	"GET /v1/me/notes/export": {Summary: "Download all the user's notes as ndjson or csv (format)", Tag: "notes"},
+	"GET /v1/me/notes/:id":    {Summary: "A note", Tag: "notes", Response: hadithNote{}},
+	"PATCH /v1/me/notes/:id": {
+		Summary: "Change a note's body or replace its highlights", Tag: "notes",
+		Request: noteUpdate{}, Response: hadithNote{},
+	},
+	"DELETE /v1/me/notes/:id":  {Summary: "Delete a note", Tag: "notes"},
+	"GET /v1/me/reading-lists": {Summary: "The user's reading lists, newest first, without their items", Tag: "reading-lists", Response: readingListListResponse{}},
+	"POST /v1/me/reading-lists": {
+		Summary: "Create a reading list of hadiths and ayahs, in order", Tag: "reading-lists",
+		Request: readingListCreateRequest{}, Response: readingList{},
+	},
+	"GET /v1/me/reading-lists/:id": {Summary: "A reading list with its items", Tag: "reading-lists", Response: readingList{}},
+	"PATCH /v1/me/reading-lists/:id": {
+		Summary: "Change a reading list's title or description", Tag: "reading-lists",
+		Request: readingListUpdate{}, Response: readingList{},
+	},
+	"PUT /v1/me/reading-lists/:id/items": {
+		Summary: "Replace a reading list's items, in order", Tag: "reading-lists",
+		Request: readingListItemsRequest{}, Response: readingList{},
+	},
+	"DELETE /v1/me/reading-lists/:id": {Summary: "Delete a reading list", Tag: "reading-lists"},
+	"POST /v1/me/reading-lists/:id/share": {
+		Summary: "Share a reading list: gives it a slug anyone can read it by (share_url) once moderation approves it", Tag: "reading-lists",
+		Response: readingList{},
+	},
+	"DELETE /v1/me/reading-lists/:id/share": {Summary: "Stop sharing a reading list; its link stops working", Tag: "reading-lists"},
+	"GET /v1/reading-lists/:slug":           {Summary: "A shared reading list, read-only", Tag: "reading-lists", Response: sharedReadingList{}},
+	"GET /v1/me/search-history": {
+		Summary: "The user's recent searches, most recent first, and whether new ones are kept", Tag: "search",
+		Response: searchHistoryResponse{},
+	},
+	"DELETE /v1/me/search-history":     {Summary: "Forget all the user's searches", Tag: "search"},
+	"DELETE /v1/me/search-history/:id": {Summary: "Forget one of the user's searches", Tag: "search"},
+	"GET /v1/search/suggestions": {
+		Summary: "Completions of q: the signed-in user's own past searches first, then hadith topics", Tag: "search",
+		Response: searchSuggestionsResponse{},
+	},
+	"GET /v1/me/subscriptions": {
+		Summary: "The user's daily hadith subscriptions and the channels this server delivers through", Tag: "subscriptions",
+		Response: subscriptionListResponse{},
+	},
+	"POST /v1/me/subscriptions": {
+		Summary: "Subscribe an email, push token or webhook URL to the hadith of the day; webhooks get their signing secret once", Tag: "subscriptions",
+		Request: subscriptionCreateRequest{}, Response: subscriptionCreateResponse{},
+	},
+	"PATCH /v1/me/subscriptions/:id": {
+		Summary: "Change a subscription's collection, language, time or time zone, or pause and resume it", Tag: "subscriptions",
+		Request: subscriptionUpdate{}, Response: subscription{},
+	},
+	"DELETE /v1/me/subscriptions/:id":    {Summary: "Delete a subscription", Tag: "subscriptions"},
+	"GET /v1/subscriptions/unsubscribe":  {Summary: "Turn off the subscription of an unsubscribe link (token)", Tag: "subscriptions"},
+	"POST /v1/subscriptions/unsubscribe": {Summary: "One-click unsubscribe from mail clients (RFC 8058)", Tag: "subscriptions"},
+	"GET /v1/me/bookmark-folders":        {Summary: "The user's bookmark folders with their bookmark counts", Tag: "bookmarks", Response: bookmarkFolderListResponse{}},
+	"POST /v1/me/bookmark-folders":       {Summary: "Create a bookmark folder", Tag: "bookmarks", Request: bookmarkFolderRequest{}, Response: bookmarkFolder{}},
+	"PATCH /v1/me/bookmark-folders/:id":  {Summary: "Rename a bookmark folder", Tag: "bookmarks", Request: bookmarkFolderRequest{}, Response: bookmarkFolder{}},
+	"DELETE /v1/me/bookmark-folders/:id": {
+		Summary: "Delete a bookmark folder; its bookmarks are kept outside any folder", Tag: "bookmarks",
+	},
+	"GET /v1/auth/oidc/login": {Summary: "Redirect to the OIDC provider to sign in (when OIDC is configured)", Tag: "auth"},
+	"GET /v1/auth/oidc/callback": {
+		Summary: "OIDC redirect target: returns an access token, or redirects with it in the fragment", Tag: "auth",
+		Query:    []apiParam{{Name: "code"}, {Name: "state"}},
+		Response: authResponse{},
+	},
+	"GET /v1/collections": {Summary: "List collections", Tag: "hadiths", Response: collectionListResponse{}},
+	"GET /v1/collections/:code/hadiths": {
+		Summary: "List hadiths of a collection ordered by number", Tag: "hadiths",
+		Query: []apiParam{
+			{Name: "limit", Description: "Page size, 1-100 (default 20)"},
+			{Name: "sort", Description: "number or -number"},
+			{Name: "cursor", Description: "next_cursor of the previous page"},
+			hadithFieldsParam,
+		},
+		Response: hadithListResponse{},
+	},
+	"GET /v1/collections/:code/hadiths/:number": {
+		Summary: "Look up a hadith by its cited number (e.g. 1234a)", Tag: "hadiths",
+		Query: []apiParam{hadithFieldsParam}, Response: Hadith{},
+	},
+	"GET /v1/collections/:code/books": {
+		Summary: "List the books of a collection, with their chapter and hadith counts", Tag: "hadiths",
+		Response: bookListResponse{},
+	},
+	"GET /v1/collections/:code/books/:book": {
+		Summary: "Get a book of a collection with its chapters", Tag: "hadiths",
+		Response: store.Book{},
+	},
+	"GET /v1/collections/:code/books/:book/hadiths": {
+		Summary: "List hadiths of a book ordered as printed: by chapter, sequence, then number", Tag: "hadiths",
+		Query: []apiParam{
+			{Name: "chapter", Description: "Only this chapter"},
+			{Name: "limit", Description: "Page size, 1-100 (default 20)"},
+			{Name: "cursor", Description: "next_cursor of the previous page"},
+			hadithFieldsParam,
+		},
+		Response: hadithListResponse{},
+	},
+	"GET /v1/hadiths/:id": {Summary: "Get a hadith", Tag: "hadiths", Query: []apiParam{hadithFieldsParam}, Response: Hadith{}},
+	"POST /v1/batch": {
+		Summary: "Run up to 20 independent reads (GETs, searches, batch gets) in one round trip, each with its own status and body", Tag: "hadiths",
+		Request: batchRequest{}, Response: batchResponse{},
+	},
+	"POST /v1/hadiths/batch-get": {
+		Summary: "Get up to 200 hadiths by id", Tag: "hadiths",
+		Query:   []apiParam{hadithFieldsParam},
+		Request: batchGetRequest{}, Response: batchGetResponse{},
+	},
+	"GET /v1/hadiths/random": {
+		Summary: "Random hadith", Tag: "hadiths",
+		Query:    []apiParam{{Name: "collection"}, {Name: "grade"}, {Name: "topic"}, hadithFieldsParam},
+		Response: Hadith{},
+	},
+	"GET /v1/hadiths/daily": {
+		Summary: "Hadith of the day", Tag: "hadiths",
+		Query: []apiParam{
+			{Name: "collection"}, {Name: "grade"}, {Name: "topic"},
+			{Name: "calendar", Description: "gregorian or hijri"},
+			{Name: "date", Description: "YYYY-MM-DD, defaults to today"},
+			{Name: "hijri_date", Description: "YYYY-MM-DD in the Hijri calendar, instead of date"},
+			hadithFieldsParam,
+		},
+		Response: dailyHadithResponse{},
+	},
+	"GET /v1/calendar/hijri": {
+		Summary: "Convert a date between the Gregorian and Hijri calendars", Tag: "hadiths",
+		Query: []apiParam{
+			{Name: "date", Description: "Gregorian YYYY-MM-DD"},
+			{Name: "hijri_date", Description: "Hijri YYYY-MM-DD; without either, today"},
+		},
+		Response: hijriConversion{},
+	},
+	"GET /v1/featured": {
+		Summary: "Hadiths and collections featured today (or on ?date=YYYY-MM-DD), in editors' order", Tag: "hadiths",
+		Response: featuredResponse{},
+	},
+	"GET /v1/admin/featured": {Summary: "List featured items, including scheduled and past ones", Tag: "admin", Response: featuredListResponse{}},
+	"POST /v1/admin/featured": {
+		Summary: "Feature a hadith or a collection, optionally between dates and in one Hijri month", Tag: "admin",
+		Request: featuredRequest{}, Response: featuredItem{},
+	},
+	"PUT /v1/admin/featured/:id":    {Summary: "Change a featured item", Tag: "admin", Request: featuredRequest{}, Response: featuredItem{}},
+	"DELETE /v1/admin/featured/:id": {Summary: "Stop featuring an item", Tag: "admin"},
+	"GET /v1/admin/experiments":     {Summary: "List ranking experiments", Tag: "admin", Response: experimentListResponse{}},
+	"POST /v1/admin/experiments": {
+		Summary: "Define a ranking experiment: weighted variants with their own boost rules and minimum score", Tag: "admin",
+		Request: experimentRequest{}, Response: experiment{},
+	},
+	"GET /v1/admin/experiments/:id": {Summary: "Get a ranking experiment", Tag: "admin", Response: experiment{}},
+	"GET /v1/admin/experiments/:id/report": {
+		Summary: "Searches, zero-result searches, clicks, click-through rate and feedback per variant", Tag: "admin",
+		Response: experimentReport{},
+	},
+	"POST /v1/admin/experiments/:id/start": {Summary: "Run an experiment; one runs per tenant at a time", Tag: "admin", Response: experiment{}},
+	"POST /v1/admin/experiments/:id/stop":  {Summary: "Stop an experiment, keeping its report", Tag: "admin", Response: experiment{}},
+	"DELETE /v1/admin/experiments/:id":     {Summary: "Delete an experiment and its recorded searches", Tag: "admin"},
+	"POST /v1/events/click": {
+		Summary: "Report which result of a search, by the query_id it returned, the user opened", Tag: "search",
+		Request: searchClickRequest{},
+	},
+	"POST /v1/events/feedback": {
+		Summary: "Report whether a search helped, replacing earlier feedback on it", Tag: "search",
+		Request: searchFeedbackRequest{},
+	},
+	"GET /v1/scholars":       {Summary: "List scholars", Tag: "sources", Response: scholarListResponse{}},
+	"GET /v1/scholars/:slug": {Summary: "Get a scholar and the books they wrote", Tag: "sources", Response: scholar{}},
+	"GET /v1/sources":        {Summary: "List source books", Tag: "sources", Response: sourceBookListResponse{}},
+	"GET /v1/sources/:slug":  {Summary: "Get a source book", Tag: "sources", Response: sourceBook{}},
+	"GET /v1/hadiths/:id/citations": {
+		Summary: "Where a hadith appears and who graded it, with citations in the request's language", Tag: "sources",
+		Response: hadithCitations{},
+	},
+	"PUT /v1/admin/scholars/:slug":    {Summary: "Create or update a scholar", Tag: "sources", Request: scholarRequest{}, Response: scholar{}},
+	"DELETE /v1/admin/scholars/:slug": {Summary: "Delete a scholar and their gradings", Tag: "sources"},
+	"PUT /v1/admin/sources/:slug": {
+		Summary: "Create or update a source book", Tag: "sources", Request: sourceBookRequest{}, Response: sourceBook{},
+	},
+	"DELETE /v1/admin/sources/:slug": {Summary: "Delete a source book and the hadith sources in it", Tag: "sources"},
+	"GET /v1/admin/topics/suggest": {
+		Summary: "Suggest existing topics for a hadith (hadith_id, leaving out its topics) or a phrase (q), most similar first", Tag: "topics",
+		Query: []apiParam{
+			{Name: "q", Description: "Text to suggest topics for, up to 1000 characters"},
+			{Name: "hadith_id", Description: "Hadith to suggest topics for, instead of q"},
+			{Name: "limit", Description: "Suggestions, 1-20 (default 5)"},
+		},
+		Response: topicSuggestResponse{},
+	},
+	"PUT /v1/admin/topics/:name": {
+		Summary: "Describe a topic; the description is embedded with its name for suggestions", Tag: "topics",
+		Request: topicRequest{}, Response: topic{},
+	},
+	"GET /v1/admin/moderation": {
+		Summary: "The moderation queue: content held or rejected by the pipeline and what moderators decided, oldest first", Tag: "moderation",
+		Query: []apiParam{
+			{Name: "status", Description: "pending (default), approved or rejected"},
+			{Name: "limit", Description: "Items per page, 1-100 (default 20)"},
+			{Name: "cursor", Description: "next_cursor of the previous page"},
+		},
+		Response: moderationListResponse{},
+	},
+	"POST /v1/admin/moderation/:id/decision": {
+		Summary: "Approve or reject moderated content; only the latest item of its content can be decided", Tag: "moderation",
+		Request: moderationDecisionRequest{}, Response: moderationItem{},
+	},
+	"GET /v1/admin/moderation/decisions": {
+		Summary: "The log of moderation decisions, by the pipeline (no moderator) or moderators, newest first", Tag: "moderation",
+		Query: []apiParam{
+			{Name: "item_id", Description: "Only the decisions on this item"},
+			{Name: "limit", Description: "Decisions per page, 1-200 (default 50)"},
+			{Name: "cursor", Description: "next_cursor of the previous page"},
+		},
+		Response: moderationDecisionListResponse{},
+	},
+	"GET /v1/hadiths/:id/ayahs": {
+		Summary: "Ayahs of the Quran a hadith cites, set by editors (manual) or found in its texts (detected)", Tag: "quran",
+		Response: hadithAyahs{},
+	},
+	"GET /v1/quran/:surah/:ayah/hadiths": {
+		Summary: "List hadiths citing an ayah", Tag: "quran",
+		Query: []apiParam{
+			{Name: "limit", Description: "Page size, 1-100 (default 20)"},
+			{Name: "cursor", Description: "next_cursor of the previous page"},
+			hadithFieldsParam,
+		},
+		Response: hadithListResponse{},
+	},
+	"PUT /v1/admin/hadiths/:id/ayahs": {
+		Summary: "Replace the ayahs a hadith cites", Tag: "quran",
+		Request: hadithAyahsRequest{}, Response: hadithAyahs{},
+	},
+	"PUT /v1/admin/hadiths/:id/citations": {
+		Summary: "Replace the sources and gradings of a hadith", Tag: "sources",
+		Request: hadithCitationsRequest{}, Response: hadithCitations{},
+	},
+	"GET /v1/stats": {Summary: "Corpus coverage statistics", Tag: "hadiths", Response: corpusStats{}},
+	"POST /v1/share": {
+		Summary: "Make a short link to a hadith or to the results of a search", Tag: "share",
+		Request: shareLinkRequest{}, Response: shareLink{},
+	},
+	"GET /s/:slug": {
+		Summary: "Follow a short link: a redirect, or an HTML page with Open Graph tags for link previews", Tag: "share",
+	},
+	"GET /v1/feeds/hadiths.atom": {Summary: "Atom feed of the 50 most recently added hadiths", Tag: "feeds"},
+	"GET /v1/feeds/collections/:file": {
+		Summary: "Atom feed of a collection's recently added hadiths; file is {code}.atom", Tag: "feeds",
+	},
+	"GET /sitemap.xml": {Summary: "Sitemap index of the web frontend's hadith pages", Tag: "feeds"},
+	"GET /sitemaps/:file": {
+		Summary: "One page of the sitemap; file is hadiths-{n}.xml", Tag: "feeds",
+	},
+	"POST /v1/admin/hadiths/upload": {
+		Summary: "Upload and index a batch of hadiths; with Accept: text/event-stream, progress is streamed as server-sent events", Tag: "admin",
+		Query:   []apiParam{{Name: "dry_run", Description: "true to only validate and estimate the embedding usage"}},
+		Request: ingest.HadithUploadRequest{}, Response: ingest.UploadResponse{},
+	},
+	"POST /v1/admin/hadiths/bulk/preview": {
+		Summary: "Count the hadiths a bulk filter matches", Tag: "admin",
+		Request: bulkPreviewRequest{}, Response: bulkPreviewResponse{},
+	},
+	"POST /v1/admin/hadiths/bulk/delete": {
+		Summary: "Queue a job deleting the hadiths a filter matches and their vectors (409 when expected is not the current count)", Tag: "admin",
+		Request: bulkDeleteRequest{}, Response: jobs.Job{},
+	},
+	"POST /v1/admin/hadiths/bulk/update": {
+		Summary: "Queue a job setting the grade, title or topics of the hadiths a filter matches, without re-embedding", Tag: "admin",
+		Request: bulkUpdateRequest{}, Response: jobs.Job{},
+	},
+	"PATCH /v1/admin/hadiths/:id": {
+		Summary: "Edit a hadith's grade, title or topics and patch its index payload without re-embedding", Tag: "admin",
+		Request: hadithPatchRequest{}, Response: Hadith{},
+	},
+	"GET /v1/admin/backups":              {Summary: "List backups", Tag: "admin", Response: backupListResponse{}},
+	"POST /v1/admin/backups":             {Summary: "Back up Postgres and Qdrant to S3", Tag: "admin", Response: backupManifest{}},
+	"POST /v1/admin/backups/:id/restore": {Summary: "Restore a backup", Tag: "admin", Response: backupManifest{}},
+	"GET /v1/admin/backups/:id/archive":  {Summary: "Download a backup's files (manifest, content, pg_dump, Qdrant snapshot) as a tar archive", Tag: "admin"},
+	"GET /v1/admin/cluster":              {Summary: "Replicas sharing the database, the locks each holds and the jobs each runs", Tag: "admin", Response: clusterResponse{}},
+	"GET /v1/admin/maintenance":          {Summary: "Whether maintenance (read-only) mode is on", Tag: "admin", Response: maintenanceState{}},
+	"PUT /v1/admin/maintenance": {
+		Summary: "Turn maintenance (read-only) mode on or off; mutations then get 503", Tag: "admin",
+		Request: maintenanceUpdate{}, Response: maintenanceState{},
+	},
+	"GET /v1/admin/abuse/bans": {
+		Summary: "IPs banned for abusing the public search API, most recent first", Tag: "admin",
+		Query: []apiParam{
+			{Name: "all", Description: "true to include ended bans"},
+			{Name: "limit", Description: "Bans, 1-1000 (default 100)"},
+		},
+		Response: ipBanListResponse{},
+	},
+	"DELETE /v1/admin/abuse/bans/:ip": {Summary: "Lift the ban of an IP and forget its recent searches", Tag: "admin"},
+	"GET /v1/admin/logging":           {Summary: "Current logging settings", Tag: "admin", Response: logSettings{}},
+	"PUT /v1/admin/logging": {
+		Summary: "Change log level, sampling and debug targets", Tag: "admin",
+		Request: logSettingsUpdate{}, Response: logSettings{},
+	},
+	"GET /v1/admin/webhooks": {Summary: "List webhooks", Tag: "admin", Response: webhookListResponse{}},
+	"POST /v1/admin/webhooks": {
+		Summary: "Register a webhook; the signing secret is only returned here", Tag: "admin",
+		Request: webhookCreateRequest{}, Response: webhookCreateResponse{},
+	},
+	"DELETE /v1/admin/webhooks/:id": {Summary: "Delete a webhook", Tag: "admin"},
+	"GET /v1/admin/jobs": {
+		Summary: "Background jobs, newest first", Tag: "admin",
+		Query: []apiParam{
+			{Name: "state", Description: "available, running, completed, failed or cancelled"},
+			{Name: "kind", Description: "reindex, index.backfill, shadow.backfill or webhook.deliver"},
+			{Name: "limit", Description: "Page size, 1-500 (default 50)"},
+			{Name: "cursor", Description: "next_cursor of the previous page"},
+		},
+		Response: jobListResponse{},
+	},
+	"POST /v1/admin/jobs": {
+		Summary: "Queue a reindex (optionally of one collection), an index backfill, a shadow index backfill or a duplicate detection; poll the returned job", Tag: "admin",
+		Request: jobCreateRequest{}, Response: jobs.Job{},
+	},
+	"GET /v1/admin/duplicates": {
+		Summary: "List candidate duplicate groups, newest first", Tag: "admin",
+		Query: []apiParam{
+			{Name: "status", Description: "pending (default), linked, merged or dismissed"},
+			{Name: "limit", Description: "Page size (default 50, max 200)"},
+			{Name: "cursor", Description: "next_cursor of the previous page"},
+		},
+		Response: duplicateGroupListResponse{},
+	},
+	"GET /v1/admin/duplicates/:id": {Summary: "Get a candidate duplicate group", Tag: "admin", Response: duplicateGroup{}},
+	"POST /v1/admin/duplicates/:id/resolve": {
+		Summary: "Link, merge or dismiss a duplicate group", Tag: "admin",
+		Request: duplicateResolveRequest{}, Response: duplicateGroup{},
+	},
+	"GET /v1/hadiths/:id/parallels":  {Summary: "Narrations linked or merged with a hadith", Tag: "hadiths", Response: hadithParallels{}},
+	"GET /v1/admin/jobs/:id":         {Summary: "Job state, attempts, last error and result", Tag: "admin", Response: jobs.Job{}},
+	"POST /v1/admin/jobs/:id/cancel": {Summary: "Cancel a queued or running job", Tag: "admin", Response: jobs.Job{}},
+	"GET /v1/admin/keys":             {Summary: "List API keys", Tag: "admin", Response: apiKeyListResponse{}},
+	"POST /v1/admin/keys": {
+		Summary: "Create an API key; the key itself is only returned here", Tag: "admin",
+		Request: apiKeyCreateRequest{}, Response: apiKeyCreateResponse{},
+	},
+	"DELETE /v1/admin/keys/:id": {Summary: "Revoke an API key", Tag: "admin", Response: apiKey{}},
+	"GET /v1/admin/users":       {Summary: "List users", Tag: "admin", Response: userListResponse{}},
+	"GET /v1/admin/tenants":     {Summary: "List tenants (default tenant only)", Tag: "admin", Response: tenantListResponse{}},
+	"POST /v1/admin/tenants": {
+		Summary: "Create a tenant; name it in X-Tenant with the bootstrap key to create its first keys", Tag: "admin",
+		Request: tenantCreateRequest{}, Response: tenantInfo{},
+	},
+	"PUT /v1/admin/users/:id/role": {
+		Summary: "Set a user's role (reader, editor or admin)", Tag: "admin",
+		Request: roleUpdateRequest{}, Response: user{},
+	},
+	"POST /v1/admin/exports": {
+		Summary: "Start exporting hadiths (optionally one collection) as ndjson or csv; poll the returned export", Tag: "admin",
+		Request: exportCreateRequest{}, Response: export{},
+	},
+	"GET /v1/admin/exports": {Summary: "Recent exports, with download links for completed ones", Tag: "admin", Response: exportListResponse{}},
+	"GET /v1/admin/exports/:id": {
+		Summary: "Export status and, once completed, a signed download link", Tag: "admin",
+		Query:    []apiParam{{Name: "ttl", Description: "Link lifetime, e.g. 1h (default 24h, max 168h)"}},
+		Response: export{},
+	},
+	"GET /v1/exports/:id/download": {
+		Summary: "Download an export through a signed link; supports Range requests", Tag: "exports",
+		Query: []apiParam{{Name: "expires"}, {Name: "sig"}},
+	},
+	"GET /v1/admin/usage": {
+		Summary: "Daily usage per API key: requests, searches, embedded texts and characters", Tag: "admin",
+		Query: []apiParam{
+			{Name: "from", Description: "First day, YYYY-MM-DD (default 29 days ago)"},
+			{Name: "to", Description: "Last day, YYYY-MM-DD (default today, UTC)"},
+			{Name: "key_id", Description: "Only this API key"},
+		},
+		Response: usageReport{},
+	},
+	"GET /v1/admin/audit": {
+		Summary: "Audit log of admin mutations, newest first", Tag: "admin",
+		Query: []apiParam{
+			{Name: "actor", Description: "Actor name: API key name or user email"},
+			{Name: "action", Description: "e.g. hadiths.upload, api_key.create"},
+			{Name: "resource", Description: "Affected resource as kind:id, e.g. collection:bukhari"},
+			{Name: "since", Description: "RFC 3339 timestamp, inclusive"},
+			{Name: "until", Description: "RFC 3339 timestamp, exclusive"},
+			{Name: "limit", Description: "Page size, 1-500 (default 50)"},
+			{Name: "cursor", Description: "next_cursor of the previous page"},
+		},
+		Response: auditListResponse{},
+	},
+	"POST /graphql": {
+		Summary: "GraphQL endpoint over collections, hadiths, topics and search", Tag: "graphql",
+		Request: graphqlRequest{},
+	},
+	"GET /v1/ws": {
+		Summary: "WebSocket search-as-you-type: send {type:query,id,query,limit}, receive searching/results/error messages", Tag: "search",
+	},
+	"GET /mcp":          {Summary: "MCP server-sent events stream (Model Context Protocol)", Tag: "mcp"},
+	"POST /mcp":         {Summary: "MCP client messages for an SSE session", Tag: "mcp"},
+	"GET /openapi.json": {Summary: "This document", Tag: "system"},
+}
+
+type openAPIBuilder struct {
+	schemas map[string]any
+}
+
+func (b *openAPIBuilder) schema(t reflect.Type) map[string]any {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := b.schema(t.Elem())
+		s["nullable"] = true
+		return s
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		name := schemaName(t)
+		if _, ok := b.schemas[name]; !ok {
+			b.schemas[name] = nil
+			b.schemas[name] = b.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (b *openAPIBuilder) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = b.schema(f.Type)
+	}
+	return map[string]any{"type": "object", "properties": props}
+}
+
+func schemaName(t reflect.Type) string {
+	r := []rune(t.Name())
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+func buildOpenAPISpec(routes []*echo.Route) map[string]any {
+	b := &openAPIBuilder{schemas: map[string]any{}}
+	errorRef := b.schema(reflect.TypeOf(apierr.APIError{}))
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	paths := map[string]map[string]any{}
+	for _, r := range routes {
+		if r.Method == echo.RouteNotFound || strings.HasSuffix(r.Path, "/*") {
+			continue
+		}
+		doc := apiOperations[r.Method+" "+r.Path]
+
+		var params []any
+		segments := strings.Split(r.Path, "/")
+		for i, seg := range segments {
+			if strings.HasPrefix(seg, ":") {
+				params = append(params, map[string]any{
+					"name": seg[1:], "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+				})
+				segments[i] = "{" + seg[1:] + "}"
+			}
+		}
+		for _, q := range doc.Query {
+			params = append(params, map[string]any{
+				"name": q.Name, "in": "query", "description": q.Description, "schema": map[string]any{"type": "string"},
+			})
+		}
+
+		op := map[string]any{
+			"summary":   doc.Summary,
+			"responses": map[string]any{"default": map[string]any{"description": "Error", "content": jsonContent(errorRef)}},
+		}
+		if doc.Tag != "" {
+			op["tags"] = []string{doc.Tag}
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+		switch {
+		case strings.HasPrefix(r.Path, "/v1/admin/"):
+			op["security"] = []any{map[string]any{"apiKey": []string{}}, map[string]any{"bearer": []string{}}}
+		case r.Path == "/v1/me" || strings.HasPrefix(r.Path, "/v1/me/"):
+			op["security"] = []any{map[string]any{"bearer": []string{}}}
+		}
+		if doc.Request != nil {
+			op["requestBody"] = map[string]any{"required": true, "content": jsonContent(b.schema(reflect.TypeOf(doc.Request)))}
+		}
+		ok := map[string]any{"description": "OK"}
+		if doc.Response != nil {
+			ok["content"] = jsonContent(b.schema(reflect.TypeOf(doc.Response)))
+		}
+		op["responses"].(map[string]any)["200"] = ok
+
+		path := strings.Join(segments, "/")
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(r.Method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Islam App API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": b.schemas,
+			"securitySchemes": map[string]any{
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"bearer": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+func registerOpenAPIRoute(e *echo.Echo) {
+	var once sync.Once
+	var spec map[string]any
+	e.GET("/openapi.json", func(c echo.Context) error {
+		once.Do(func() { spec = buildOpenAPISpec(e.Routes()) })
+		return c.JSON(http.StatusOK, spec)
+	})
+}
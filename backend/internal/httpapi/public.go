@@ -0,0 +1,84 @@
+package httpapi
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/labstack/echo/v4"
+)
+
+// publicAPI is the public read API: GET routes anyone may call without
+// credentials. Their responses are cacheable by browsers and CDNs, their
+// anonymous callers have a rate limit of their own, and their server-side
+// failures are not explained.
+//
+// It is not an echo.Group, since group middleware is also run for unknown
+// paths and would turn every 405 under its prefix into a 404.
+type publicAPI struct {
+	e       *echo.Echo
+	limiter *RateLimiter
+	read    echo.MiddlewareFunc
+}
+
+func newPublicAPI(e *echo.Echo, limiter *RateLimiter, maxAge, sharedMaxAge time.Duration) *publicAPI {
+	return &publicAPI{e: e, limiter: limiter, read: publicRead(maxAge, sharedMaxAge)}
+}
+
+func (p *publicAPI) GET(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	p.limiter.public[path] = true
+	return p.e.GET(path, h, append([]echo.MiddlewareFunc{p.read}, m...)...)
+}
+
+// isAnonymous reports whether the request carries no credentials.
+func isAnonymous(c echo.Context) bool {
+	r := c.Request()
+	return r.Header.Get("X-API-Key") == "" && r.Header.Get(echo.HeaderAuthorization) == ""
+}
+
+// publicRead sets Cache-Control once the status is known: anonymous
+// successes may be cached anywhere, answers to credentials only by the
+// client, errors nowhere. Redirects keep what the handler chose.
+func publicRead(maxAge, sharedMaxAge time.Duration) echo.MiddlewareFunc {
+	public := fmt.Sprintf("public, max-age=%d, s-maxage=%d, stale-while-revalidate=%d",
+		int(maxAge.Seconds()), int(sharedMaxAge.Seconds()), int(sharedMaxAge.Seconds()))
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			res := c.Response()
+			anonymous := isAnonymous(c)
+			res.Before(func() {
+				h := res.Header()
+				switch {
+				case res.Status >= http.StatusBadRequest:
+					h.Set(echo.HeaderCacheControl, "no-store")
+				case res.Status >= http.StatusMultipleChoices && res.Status != http.StatusNotModified:
+				case !anonymous:
+					h.Set(echo.HeaderCacheControl, "private, no-cache")
+				default:
+					h.Set(echo.HeaderCacheControl, public)
+					h.Add(echo.HeaderVary, tenantHeader)
+				}
+			})
+			return hideErrorDetail(c, next(c))
+		}
+	}
+}
+
+// hideErrorDetail logs server-side failures and replaces them with a plain
+// internal error, so that public callers learn nothing about the database,
+// embedder or vector store behind them. Maintenance and overload are kept,
+// as clients act on them.
+func hideErrorDetail(c echo.Context, err error) error {
+	var apiErr *apierr.APIError
+	if err == nil || !errors.As(err, &apiErr) || apiErr.Status < http.StatusInternalServerError {
+		return err
+	}
+	if apiErr.Code == apierr.CodeMaintenance || apiErr.Code == apierr.CodeOverloaded {
+		return err
+	}
+	slog.ErrorContext(c.Request().Context(), "public read failed", "error", err)
+	return apierr.New(apiErr.Status, apierr.CodeInternal, "internal error").WithRetryAfter(apiErr.RetryAfter)
+}
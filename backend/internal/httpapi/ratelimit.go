@@ -0,0 +1,189 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+type rateLimit struct {
+	Limit  int
+	Window time.Duration
+}
+
+// parseRateLimits reads "group=limit/window,..." such as
+// "search=60/1m,admin=600/1h,public=120/1m,default=300/1m".
+func parseRateLimits(s string) (map[string]rateLimit, error) {
+	limits := map[string]rateLimit{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		group, spec, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q: want group=limit/window", part)
+		}
+		limit, err := parseRateLimit(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", part, err)
+		}
+		limits[strings.TrimSpace(group)] = limit
+	}
+	return limits, nil
+}
+
+// parseRateLimit reads "limit/window" such as "60/1m".
+func parseRateLimit(spec string) (rateLimit, error) {
+	n, window, ok := strings.Cut(strings.TrimSpace(spec), "/")
+	if !ok {
+		return rateLimit{}, errors.New("want limit/window")
+	}
+	limit, err := strconv.Atoi(n)
+	if err != nil || limit <= 0 {
+		return rateLimit{}, errors.New("invalid limit")
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d < time.Second {
+		return rateLimit{}, errors.New("invalid window")
+	}
+	return rateLimit{Limit: limit, Window: d}, nil
+}
+
+// RateLimiter counts requests per client, route group and fixed window in
+// Postgres, so quotas hold across restarts and replicas.
+type RateLimiter struct {
+	db     *pgxpool.Pool
+	limits atomic.Pointer[map[string]rateLimit]
+	// public holds the paths of the public read API, filled in before
+	// serving.
+	public map[string]bool
+}
+
+func newRateLimiter(db *pgxpool.Pool, limits map[string]rateLimit) *RateLimiter {
+	l := &RateLimiter{db: db, public: map[string]bool{}}
+	l.setLimits(limits)
+	return l
+}
+
+// setLimits replaces the limits; counts of the current windows are kept.
+func (l *RateLimiter) setLimits(limits map[string]rateLimit) {
+	l.limits.Store(&limits)
+}
+
+// routeGroup buckets routes by cost: vector search, admin and the rest.
+// Probes and docs belong to no group and are not rate limited.
+func routeGroup(path string) string {
+	switch {
+	case path == "/healthz" || path == "/readyz" || path == "/openapi.json" || path == "/metrics":
+		return ""
+	case strings.HasPrefix(path, "/v1/admin"):
+		return "admin"
+	case path == "/v1/search" || path == "/graphql" || path == "/v1/ws" || path == "/mcp":
+		return "search"
+	default:
+		return "default"
+	}
+}
+
+// group is routeGroup, except that anonymous callers of the public read API,
+// including those presenting unknown keys, count against the public group
+// when it has a limit.
+func (l *RateLimiter) group(c echo.Context, limits map[string]rateLimit) string {
+	group := routeGroup(c.Path())
+	if _, ok := limits["public"]; ok && group == "default" && l.public[c.Path()] && currentAPIKey(c) == nil && currentUser(c) == nil {
+		return "public"
+	}
+	return group
+}
+
+// rateLimitClient identifies the caller by verified API key, then user,
+// then IP. Unknown keys are ignored, so made-up ones buy no fresh quota.
+func rateLimitClient(c echo.Context) string {
+	if k := currentAPIKey(c); k != nil {
+		return "key:" + strconv.FormatInt(k.ID, 10)
+	}
+	if u := currentUser(c); u != nil {
+		return "user:" + u.Subject
+	}
+	return "ip:" + c.RealIP()
+}
+
+func (l *RateLimiter) hit(ctx context.Context, client, group string, windowStart time.Time) (int, error) {
+	var count int
+	err := l.db.QueryRow(ctx, `
+INSERT INTO rate_limit_counters (client, route_group, window_start, count)
+VALUES ($1, $2, $3, 1)
+ON CONFLICT (client, route_group, window_start) DO UPDATE SET count = rate_limit_counters.count + 1
+RETURNING count`, client, group, windowStart).Scan(&count)
+	return count, err
+}
+
+// middleware fails open: if the counter cannot be updated the request is
+// served and the error logged.
+func (l *RateLimiter) middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			limits := *l.limits.Load()
+			group := l.group(c, limits)
+			limit, ok := limits[group]
+			if !ok {
+				return next(c)
+			}
+			now := time.Now()
+			windowStart := now.Truncate(limit.Window)
+			reset := windowStart.Add(limit.Window)
+
+			ctx, cancel := context.WithTimeout(c.Request().Context(), 2*time.Second)
+			count, err := l.hit(ctx, rateLimitClient(c), group, windowStart)
+			cancel()
+			if err != nil {
+				slog.ErrorContext(c.Request().Context(), "rate limit check failed", "error", err)
+				return next(c)
+			}
+
+			h := c.Response().Header()
+			h.Set("X-RateLimit-Limit", strconv.Itoa(limit.Limit))
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(max(limit.Limit-count, 0)))
+			h.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			if count > limit.Limit {
+				retryAfter := int(reset.Sub(now).Seconds()) + 1
+				h.Set(echo.HeaderRetryAfter, strconv.Itoa(retryAfter))
+				return apierr.New(http.StatusTooManyRequests, apierr.CodeRateLimited, "rate limit exceeded").
+					WithDetails(map[string]any{"group": group, "limit": limit.Limit, "window": limit.Window.String(), "retry_after": retryAfter})
+			}
+			return next(c)
+		}
+	}
+}
+
+// prune drops counters of windows that ended long ago.
+func (l *RateLimiter) prune(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var longest time.Duration
+			for _, lim := range *l.limits.Load() {
+				longest = max(longest, lim.Window)
+			}
+			_, err := l.db.Exec(ctx, `DELETE FROM rate_limit_counters WHERE window_start < $1 AND route_group <> $2`, time.Now().Add(-2*longest), abuseGroup)
+			if err != nil {
+				slog.Error("rate limit prune failed", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/cache"
+	"github.com/labstack/echo/v4"
+)
+
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+	// degradable services can fail while search falls back to keywords,
+	// or while only reports suffer; the instance then reports degraded but
+	// stays ready.
+	degradable bool
+}
+
+// Readiness pings the backing services for /readyz. Results are cached for
+// ttl so frequent probes from several orchestrators don't add load.
+type Readiness struct {
+	checks  []readinessCheck
+	timeout time.Duration
+	ttl     time.Duration
+
+	mu   sync.Mutex
+	last readinessResponse
+}
+
+func newReadiness(deps *AppDependencies, timeout, ttl time.Duration, keywordFallback bool) *Readiness {
+	r := &Readiness{
+		timeout: timeout,
+		ttl:     ttl,
+		checks: []readinessCheck{
+			{name: "postgres", check: func(ctx context.Context) error { return deps.Postgres.Ping(ctx) }},
+		},
+	}
+	// Only reports and listings read from the replica, so the instance
+	// stays ready without it.
+	if deps.ReadPostgres != deps.Postgres {
+		r.checks = append(r.checks, readinessCheck{name: "postgres_read", check: func(ctx context.Context) error { return deps.ReadPostgres.Ping(ctx) }, degradable: true})
+	}
+	// With pgvector the vectors are in Postgres, which is already checked.
+	if deps.Qdrant != nil {
+		r.checks = append(r.checks, readinessCheck{name: "qdrant", check: deps.Vectors.Health, degradable: keywordFallback})
+	}
+	r.checks = append(r.checks, readinessCheck{name: "embedder", check: deps.Embedder.Ping, degradable: keywordFallback})
+	if rc, ok := deps.Cache.Backend().(*cache.Redis); ok {
+		r.checks = append(r.checks, readinessCheck{name: "redis", check: rc.Ping})
+	}
+	return r
+}
+
+func (r *Readiness) status(ctx context.Context) readinessResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.last.CheckedAt.IsZero() && time.Since(r.last.CheckedAt) < r.ttl {
+		return r.last
+	}
+
+	results := make([]dependencyStatus, len(r.checks))
+	var wg sync.WaitGroup
+	for i, chk := range r.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, r.timeout)
+			defer cancel()
+			start := time.Now()
+			err := chk.check(ctx)
+			results[i] = dependencyStatus{Status: "ok", LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				results[i].Status = "unavailable"
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	resp := readinessResponse{Status: "ok", CheckedAt: time.Now().UTC(), Checks: map[string]dependencyStatus{}}
+	for i, chk := range r.checks {
+		resp.Checks[chk.name] = results[i]
+		switch {
+		case results[i].Status == "ok" || resp.Status == "unavailable":
+		case chk.degradable:
+			resp.Status = "degraded"
+		default:
+			resp.Status = "unavailable"
+		}
+	}
+	r.last = resp
+	return resp
+}
+
+func registerHealthRoutes(e *echo.Echo, r *Readiness) {
+	e.GET("/healthz", func(c echo.Context) error { return c.String(http.StatusOK, "ok") })
+	e.GET("/readyz", func(c echo.Context) error {
+		// Checks outlive a client that hangs up so the cached result is complete.
+		resp := r.status(context.WithoutCancel(c.Request().Context()))
+		if resp.Status == "unavailable" {
+			return c.JSON(http.StatusServiceUnavailable, resp)
+		}
+		return c.JSON(http.StatusOK, resp)
+	})
+}
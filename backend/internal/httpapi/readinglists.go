@@ -0,0 +1,391 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const readingListColumns = `l.id, l.title, l.description, l.share_slug, l.moderation, l.created_at, l.updated_at,
+  (SELECT count(*) FROM reading_list_items i WHERE i.list_id = l.id)`
+
+func scanReadingList(row pgx.Row) (readingList, error) {
+	var l readingList
+	err := row.Scan(&l.ID, &l.Title, &l.Description, &l.ShareSlug, &l.Moderation, &l.CreatedAt, &l.UpdatedAt, &l.ItemCount)
+	return l, err
+}
+
+// heldWhileShared is the moderation status of a list being changed: a
+// shared list is hidden from others until moderateShared checks it again.
+const heldWhileShared = `CASE WHEN share_slug IS NULL THEN moderation ELSE 'pending' END`
+
+// moderateShared runs moderation on list id when shared.
+func moderateShared(c echo.Context, deps *AppDependencies, id int64, shared bool) error {
+	if !shared {
+		return nil
+	}
+	if err := moderateReadingList(c.Request().Context(), deps, id, currentUser(c).UserID()); err != nil {
+		return apierr.Database("db update moderation failed")
+	}
+	return nil
+}
+
+// withShareURL adds the public link of a shared list.
+func withShareURL(c echo.Context, deps *AppDependencies, l readingList) readingList {
+	if l.ShareSlug != nil {
+		u := publicBaseURL(c, deps) + "/v1/reading-lists/" + url.PathEscape(*l.ShareSlug)
+		l.ShareURL = &u
+	}
+	return l
+}
+
+// publicBaseURL is PUBLIC_BASE_URL, or the URL the request came to.
+func publicBaseURL(c echo.Context, deps *AppDependencies) string {
+	if deps.PublicBaseURL != "" {
+		return deps.PublicBaseURL
+	}
+	return c.Scheme() + "://" + c.Request().Host
+}
+
+// readingListItems returns the items of list id in order, with their
+// hadiths as seen from the tenant of ctx.
+func readingListItems(ctx context.Context, deps *AppDependencies, id int64) ([]readingListItem, error) {
+	rows, err := deps.Postgres.Query(ctx, `
+SELECT hadith_id, surah, ayah, note FROM reading_list_items WHERE list_id = $1 ORDER BY position`, id)
+	if err != nil {
+		return nil, err
+	}
+	items, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (readingListItem, error) {
+		var it readingListItem
+		err := row.Scan(&it.HadithID, &it.Surah, &it.Ayah, &it.Note)
+		it.Kind = "ayah"
+		if it.HadithID != nil {
+			it.Kind = "hadith"
+		}
+		return it, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for _, it := range items {
+		if it.HadithID != nil {
+			ids = append(ids, *it.HadithID)
+		}
+	}
+	byID, err := hadithsByID(ctx, deps, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i, it := range items {
+		if it.HadithID != nil {
+			items[i].Hadith = byID[*it.HadithID]
+		}
+	}
+	return items, nil
+}
+
+// checkReadingListItems rejects hadiths missing from the tenant of ctx.
+func checkReadingListItems(ctx context.Context, deps *AppDependencies, items []readingListItemRequest) error {
+	var ids []int64
+	for _, it := range items {
+		if it.HadithID != 0 {
+			ids = append(ids, it.HadithID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	_, missing, err := deps.Store.Hadiths(ctx, ids)
+	if err != nil {
+		return apierr.Database("db query failed")
+	}
+	if len(missing) > 0 {
+		return apierr.InvalidArgument(fmt.Sprintf("hadiths not found: %v", missing))
+	}
+	return nil
+}
+
+// replaceReadingListItems sets the items of list id, in order.
+func replaceReadingListItems(ctx context.Context, tx pgx.Tx, id int64, items []readingListItemRequest) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM reading_list_items WHERE list_id = $1`, id); err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	hadiths, surahs, ayahs, notes := make([]int64, len(items)), make([]int32, len(items)), make([]int32, len(items)), make([]string, len(items))
+	for i, it := range items {
+		hadiths[i], surahs[i], ayahs[i], notes[i] = it.HadithID, int32(it.Surah), int32(it.Ayah), strings.TrimSpace(it.Note)
+	}
+	_, err := tx.Exec(ctx, `
+INSERT INTO reading_list_items (list_id, position, hadith_id, surah, ayah, note)
+SELECT $1, t.ord, NULLIF(t.hadith_id, 0), NULLIF(t.surah, 0), NULLIF(t.ayah, 0), t.note
+FROM unnest($2::int[], $3::int[], $4::int[], $5::text[]) WITH ORDINALITY AS t(hadith_id, surah, ayah, note, ord)`,
+		id, hadiths, surahs, ayahs, notes)
+	return err
+}
+
+// registerReadingListRoutes serves the authenticated user's reading lists
+// under /v1/me/reading-lists and shared lists, to anyone, by slug.
+func registerReadingListRoutes(me *echo.Group, public *publicAPI, deps *AppDependencies) {
+	g := me.Group("/reading-lists")
+
+	// getOwn returns list id of the current user with its items.
+	getOwn := func(c echo.Context, id int64) (readingList, error) {
+		ctx := c.Request().Context()
+		l, err := scanReadingList(deps.Postgres.QueryRow(ctx, `
+SELECT `+readingListColumns+` FROM reading_lists l WHERE l.id = $1 AND l.user_id = $2`, id, currentUser(c).UserID()))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return l, apierr.NotFound("reading list not found")
+		}
+		if err != nil {
+			return l, apierr.Database("db query failed")
+		}
+		if l.Items, err = readingListItems(ctx, deps, l.ID); err != nil {
+			return l, apierr.Database("db query failed")
+		}
+		return withShareURL(c, deps, l), nil
+	}
+
+	g.GET("", func(c echo.Context) error {
+		rows, err := deps.Postgres.Query(c.Request().Context(), `
+SELECT `+readingListColumns+` FROM reading_lists l WHERE l.user_id = $1 ORDER BY l.id DESC`, currentUser(c).UserID())
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		lists, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (readingList, error) { return scanReadingList(row) })
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		for i := range lists {
+			lists[i] = withShareURL(c, deps, lists[i])
+		}
+		return c.JSON(http.StatusOK, readingListListResponse{Lists: lists})
+	})
+
+	g.GET("/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		l, err := getOwn(c, id)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, l)
+	})
+
+	g.POST("", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		var req readingListCreateRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		if err := checkReadingListItems(ctx, deps, req.Items); err != nil {
+			return err
+		}
+		tx, err := deps.Postgres.Begin(ctx)
+		if err != nil {
+			return apierr.Database("db insert reading list failed")
+		}
+		defer tx.Rollback(ctx)
+		var id int64
+		err = tx.QueryRow(ctx, `
+INSERT INTO reading_lists (user_id, title, description) VALUES ($1, $2, $3) RETURNING id`,
+			currentUser(c).UserID(), strings.TrimSpace(req.Title), strings.TrimSpace(req.Description)).Scan(&id)
+		if err == nil {
+			err = replaceReadingListItems(ctx, tx, id, req.Items)
+		}
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+		if err != nil {
+			return apierr.Database("db insert reading list failed")
+		}
+		l, err := getOwn(c, id)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusCreated, l)
+	})
+
+	g.PATCH("/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		var req readingListUpdate
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		if req.Title != nil {
+			s := strings.TrimSpace(*req.Title)
+			req.Title = &s
+		}
+		if req.Description != nil {
+			s := strings.TrimSpace(*req.Description)
+			req.Description = &s
+		}
+		var shared bool
+		err = deps.Postgres.QueryRow(c.Request().Context(), `
+UPDATE reading_lists SET title = COALESCE($3, title), description = COALESCE($4, description), updated_at = now(),
+  moderation = `+heldWhileShared+`
+WHERE id = $1 AND user_id = $2
+RETURNING share_slug IS NOT NULL`, id, currentUser(c).UserID(), req.Title, req.Description).Scan(&shared)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("reading list not found")
+		}
+		if err != nil {
+			return apierr.Database("db update reading list failed")
+		}
+		if err := moderateShared(c, deps, id, shared); err != nil {
+			return err
+		}
+		l, err := getOwn(c, id)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, l)
+	})
+
+	g.PUT("/:id/items", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		var req readingListItemsRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		if err := checkReadingListItems(ctx, deps, req.Items); err != nil {
+			return err
+		}
+		tx, err := deps.Postgres.Begin(ctx)
+		if err != nil {
+			return apierr.Database("db update reading list failed")
+		}
+		defer tx.Rollback(ctx)
+		var shared bool
+		err = tx.QueryRow(ctx, `
+UPDATE reading_lists SET updated_at = now(), moderation = `+heldWhileShared+`
+WHERE id = $1 AND user_id = $2
+RETURNING share_slug IS NOT NULL`, id, currentUser(c).UserID()).Scan(&shared)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("reading list not found")
+		}
+		if err != nil {
+			return apierr.Database("db update reading list failed")
+		}
+		if err := replaceReadingListItems(ctx, tx, id, req.Items); err != nil {
+			return apierr.Database("db update reading list failed")
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return apierr.Database("db update reading list failed")
+		}
+		if err := moderateShared(c, deps, id, shared); err != nil {
+			return err
+		}
+		l, err := getOwn(c, id)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, l)
+	})
+
+	g.DELETE("/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		tag, err := deps.Postgres.Exec(c.Request().Context(), `DELETE FROM reading_lists WHERE id = $1 AND user_id = $2`, id, currentUser(c).UserID())
+		if err != nil {
+			return apierr.Database("db delete reading list failed")
+		}
+		if tag.RowsAffected() == 0 {
+			return apierr.NotFound("reading list not found")
+		}
+		if err := withdrawModeration(c.Request().Context(), deps, id); err != nil {
+			return apierr.Database("db update moderation failed")
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	// Sharing keeps the slug a list already has; unsharing drops it, so
+	// sharing again gives a new link. Others see a shared list once it is
+	// approved by moderation.
+	g.POST("/:id/share", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		tag, err := deps.Postgres.Exec(c.Request().Context(), `
+UPDATE reading_lists SET share_slug = COALESCE(share_slug, $3), moderation = 'pending' WHERE id = $1 AND user_id = $2`,
+			id, currentUser(c).UserID(), strings.ToLower(rand.Text()))
+		if err != nil {
+			return apierr.Database("db update reading list failed")
+		}
+		if tag.RowsAffected() == 0 {
+			return apierr.NotFound("reading list not found")
+		}
+		if err := moderateShared(c, deps, id, true); err != nil {
+			return err
+		}
+		l, err := getOwn(c, id)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, l)
+	})
+
+	g.DELETE("/:id/share", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		tag, err := deps.Postgres.Exec(c.Request().Context(), `
+UPDATE reading_lists SET share_slug = NULL WHERE id = $1 AND user_id = $2`, id, currentUser(c).UserID())
+		if err != nil {
+			return apierr.Database("db update reading list failed")
+		}
+		if tag.RowsAffected() == 0 {
+			return apierr.NotFound("reading list not found")
+		}
+		if err := withdrawModeration(c.Request().Context(), deps, id); err != nil {
+			return apierr.Database("db update moderation failed")
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	// A shared list shows the hadiths of its owner's tenant, whoever reads it.
+	public.GET("/v1/reading-lists/:slug", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		var id, tenantID int64
+		var l sharedReadingList
+		err := deps.Postgres.QueryRow(ctx, `
+SELECT l.id, l.title, l.description, u.display_name, l.updated_at, u.tenant_id
+FROM reading_lists l JOIN users u ON u.id = l.user_id
+WHERE l.share_slug = $1 AND l.moderation = 'approved'`, c.Param("slug")).Scan(&id, &l.Title, &l.Description, &l.Author, &l.UpdatedAt, &tenantID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("reading list not found")
+		}
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		if l.Items, err = readingListItems(tenant.With(ctx, tenantID), deps, id); err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, l)
+	})
+}
@@ -0,0 +1,101 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/config"
+	"github.com/buugaaga/test-cursor/backend/internal/search"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+)
+
+// settingsReloader puts reloaded settings into effect while serving.
+type settingsReloader struct {
+	deps        *AppDependencies
+	logControl  *LogControl
+	rateLimiter *RateLimiter
+	abuse       *AbuseGuard
+}
+
+func searchSettings(s *config.Config) (search.Settings, error) {
+	boosts, err := search.ParseBoosts(s.Search.Boosts)
+	if err != nil {
+		return search.Settings{}, fmt.Errorf("invalid SEARCH_BOOSTS: %w", err)
+	}
+	tiers, err := search.ParseTierLimits(s.Search.TierLimits)
+	if err != nil {
+		return search.Settings{}, fmt.Errorf("invalid SEARCH_TIER_LIMITS: %w", err)
+	}
+	return search.Settings{DefaultLimit: s.Search.DefaultLimit, MaxLimit: s.Search.MaxLimit, Tiers: tiers, Boosts: boosts}, nil
+}
+
+// apply puts the live settings among changes into effect and records each
+// in the audit log. Changes to the other settings are only logged, as they
+// need a restart. Nothing is applied when a live value is invalid.
+func (r *settingsReloader) apply(next *config.Loaded, changes []config.Change) error {
+	s := next.Config
+	changed := func(prefix string) bool {
+		for _, c := range changes {
+			if c.Live && strings.HasPrefix(c.Key, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	rateLimits, err := parseRateLimits(s.HTTP.RateLimits)
+	if err != nil {
+		return fmt.Errorf("invalid RATE_LIMITS: %w", err)
+	}
+	st, err := searchSettings(s)
+	if err != nil {
+		return err
+	}
+	abuse, err := parseAbuseSettings(s.Abuse)
+	if err != nil {
+		return err
+	}
+	if changed("log.") {
+		if err := r.logControl.apply(logSettingsUpdate{Level: &s.Log.Level, SampleRate: &s.Log.SampleRate}); err != nil {
+			return fmt.Errorf("invalid log settings: %w", err)
+		}
+	}
+	if changed("http.rate_limits") {
+		r.rateLimiter.setLimits(rateLimits)
+	}
+	if changed("search.") {
+		r.deps.Search.SetSettings(st)
+	}
+	if changed("features.") {
+		r.deps.Features.setDisabled(s.Features.Disabled)
+	}
+	if changed("maintenance.") {
+		r.deps.Maintenance.set(s.Maintenance.Enabled, s.Maintenance.Message)
+	}
+	if changed("abuse.") {
+		r.abuse.set(abuse)
+	}
+	if changed("moderation.") {
+		r.deps.Moderation.set(s.Moderation)
+	}
+
+	ctx := tenant.With(context.Background(), tenant.Default)
+	for _, c := range changes {
+		if !c.Live {
+			slog.Warn("config: setting changed, restart to apply", "setting", c.Key)
+			continue
+		}
+		slog.Info("config: setting applied", "setting", c.Key, "old", c.Old, "new", c.New)
+		r.deps.Audit.Record(ctx, auditRecord{
+			Actor:     &principal{Kind: "system", Name: "config reload"},
+			Action:    "config.reload",
+			Resources: []string{"setting:" + c.Key},
+			Summary:   map[string]any{"old": c.Old, "new": c.New, "source": c.Source},
+			Status:    http.StatusOK,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// Roles are ordered: each one includes the permissions of those below it.
+// Readers search and read, editors also upload and edit content, admins also
+// manage keys, users, backups, webhooks and logging.
+const (
+	roleReader = "reader"
+	roleEditor = "editor"
+	roleAdmin  = "admin"
+)
+
+var roleRank = map[string]int{roleReader: 1, roleEditor: 2, roleAdmin: 3}
+
+func roleAllows(have, need string) bool {
+	return roleRank[have] >= roleRank[need]
+}
+
+func highestRole(roles []string) string {
+	best := ""
+	for _, r := range roles {
+		if roleRank[r] > roleRank[best] {
+			best = r
+		}
+	}
+	return best
+}
+
+// principal is the authenticated caller of a role-protected route, stored in
+// the echo context under "principal".
+type principal struct {
+	Kind string // "api_key" or "user"
+	ID   int64
+	Name string
+	Role string
+}
+
+// principalKey carries the principal in gRPC request contexts.
+type principalKey struct{}
+
+func currentPrincipal(c echo.Context) *principal {
+	p, _ := c.Get("principal").(*principal)
+	return p
+}
+
+// resolvePrincipal authenticates an API key or a user access token; bearer
+// may carry either. Users' roles are read from the database so demotions
+// apply before their tokens expire.
+func resolvePrincipal(ctx context.Context, deps *AppDependencies, apiKey, bearer string) (*principal, error) {
+	if apiKey == "" && strings.HasPrefix(bearer, apiKeyPrefix) {
+		apiKey, bearer = bearer, ""
+	}
+	switch {
+	case apiKey != "":
+		id, err := deps.APIKeys.Authenticate(ctx, apiKey)
+		if errors.Is(err, errAPIKeyInvalid) {
+			return nil, apierr.Unauthorized("invalid api key")
+		}
+		if err != nil {
+			return nil, apierr.Database("api key lookup failed")
+		}
+		return &principal{Kind: "api_key", ID: id.ID, Name: id.Name, Role: highestRole(id.Scopes)}, nil
+	case bearer != "":
+		claims, err := deps.Users.parse(bearer)
+		if err != nil {
+			return nil, apierr.Unauthorized("invalid or expired token")
+		}
+		u, err := getUser(ctx, deps, claims.UserID())
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apierr.Unauthorized("user no longer exists")
+		}
+		if err != nil {
+			return nil, apierr.Database("db query failed")
+		}
+		return &principal{Kind: "user", ID: u.ID, Name: u.Email, Role: u.Role}, nil
+	default:
+		return nil, apierr.Unauthorized("authentication required")
+	}
+}
+
+func authorizeRole(ctx context.Context, deps *AppDependencies, apiKey, bearer, role string) (*principal, error) {
+	p, err := resolvePrincipal(ctx, deps, apiKey, bearer)
+	if err != nil {
+		return nil, err
+	}
+	if !roleAllows(p.Role, role) {
+		return nil, apierr.Forbidden("requires role " + role)
+	}
+	return p, nil
+}
+
+func bearerToken(r *http.Request) string {
+	token, _ := strings.CutPrefix(r.Header.Get(echo.HeaderAuthorization), "Bearer ")
+	return strings.TrimSpace(token)
+}
+
+func requireRole(deps *AppDependencies, role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			p, err := authorizeRole(r.Context(), deps, r.Header.Get("X-API-Key"), bearerToken(r), role)
+			if err != nil {
+				var apiErr *apierr.APIError
+				if errors.As(err, &apiErr) && apiErr.Status == http.StatusUnauthorized {
+					c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="islam-app"`)
+				}
+				return err
+			}
+			c.Set("principal", p)
+			return next(c)
+		}
+	}
+}
+
+func registerUserAdminRoutes(admin *echo.Group, deps *AppDependencies) {
+	admin.GET("/users", func(c echo.Context) error {
+		rows, err := deps.Postgres.Query(c.Request().Context(), `SELECT `+userColumns+` FROM users WHERE tenant_id = $1 ORDER BY id`, tenant.From(c.Request().Context()))
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		defer rows.Close()
+		users := []user{}
+		for rows.Next() {
+			u, err := scanUser(rows)
+			if err != nil {
+				return apierr.Database("db scan failed")
+			}
+			users = append(users, u)
+		}
+		if rows.Err() != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, userListResponse{Users: users})
+	})
+
+	admin.PUT("/users/:id/role", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		var req roleUpdateRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		auditNote(c, "user.role_update", map[string]any{"role": req.Role}, "user:"+c.Param("id"))
+		u, err := scanUser(deps.Postgres.QueryRow(c.Request().Context(), `
+UPDATE users SET role = $2 WHERE id = $1 AND tenant_id = $3
+RETURNING `+userColumns, id, req.Role, tenant.From(c.Request().Context())))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("user not found")
+		}
+		if err != nil {
+			return apierr.Database("db update user failed")
+		}
+		return c.JSON(http.StatusOK, u)
+	})
+}
@@ -0,0 +1,557 @@
+package httpapi
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/cache"
+	"github.com/buugaaga/test-cursor/backend/internal/config"
+	"github.com/buugaaga/test-cursor/backend/internal/embed"
+	"github.com/buugaaga/test-cursor/backend/internal/hijri"
+	"github.com/buugaaga/test-cursor/backend/internal/i18n"
+	"github.com/buugaaga/test-cursor/backend/internal/ingest"
+	"github.com/buugaaga/test-cursor/backend/internal/jobs"
+	"github.com/buugaaga/test-cursor/backend/internal/logging"
+	"github.com/buugaaga/test-cursor/backend/internal/search"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/buugaaga/test-cursor/backend/internal/vector"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+type AppDependencies struct {
+	Postgres *pgxpool.Pool
+	// ReadPostgres is the read replica's pool, or Postgres when there is
+	// none. Only reports and listings that may lag behind writes use it.
+	ReadPostgres  *pgxpool.Pool
+	Qdrant        *qdrant.Client
+	QdrantHTTPURL string
+	QdrantAPIKey  string
+	// PublicBaseURL, when set, is where clients reach the API.
+	PublicBaseURL string
+	Store         store.Store
+	Vectors       vector.Index
+	Embedder      embed.Embedder
+	// EmbeddingModel is the embedder's model, recorded with the index.
+	EmbeddingModel embed.Model
+	Backups        *BackupStore
+	DailyCalendar  string
+	DailyLocation  *time.Location
+	// DailyTopics are the topics of the daily hadith on Hijri days.
+	DailyTopics hijri.Seasons
+	Hijri       hijri.Calendar
+	Stats       *StatsCache
+	Webhooks    *WebhookDispatcher
+	// Sitemaps is nil when SITEMAP_BASE_URL is unset.
+	Sitemaps      *Sitemaps
+	Topics        *TopicIndex
+	APIKeys       *APIKeyStore
+	Tenants       *TenantDirectory
+	Features      *FeatureFlags
+	Maintenance   *Maintenance
+	Moderation    *Moderation
+	Users         *UserAuth
+	Audit         *AuditLog
+	AdminNetworks []netip.Prefix
+	OIDC          *OIDCProvider
+	Exports       *ExportStore
+	Cache         *cache.ResultCache
+	Timeouts      requestTimeouts
+	Search        *search.Service
+	Ingest        *ingest.Service
+	Jobs          *jobs.Client
+	Replicas      *ReplicaRegistry
+	SearchHistory *SearchHistory
+	Experiments   *Experiments
+	SearchEvents  *SearchEvents
+	Subscriptions *Subscriptions
+	// ShareHadithURL and ShareSearchURL are the templates short links
+	// redirect to; empty to serve the preview page.
+	ShareHadithURL string
+	ShareSearchURL string
+	ShareSiteName  string
+}
+
+// Config is the infrastructure the servers are built on and the settings
+// Run configures everything else from.
+type Config struct {
+	Settings *config.Config
+	// ReplicaID names this process among the replicas sharing Postgres;
+	// NewReplicaID makes one when it is empty.
+	ReplicaID string
+	// Loaded, when set, is where Settings came from; it is watched and
+	// reloaded settings are put into effect.
+	Loaded   *config.Loaded
+	Postgres *pgxpool.Pool
+	// ReadPostgres, when set, is a replica for heavy read-only listings
+	// and reports.
+	ReadPostgres  *pgxpool.Pool
+	Qdrant        *qdrant.Client
+	QdrantHTTPURL string
+	// Store, Vectors and Embedder are what the handlers and services query;
+	// Postgres and Qdrant are used directly only for backups and the admin
+	// and account tables.
+	Store    store.Store
+	Vectors  vector.Index
+	Embedder embed.Embedder
+	// EmbeddingModel is what Embedder reported at startup.
+	EmbeddingModel embed.Model
+	// Shadow, when set, also indexes content and receives sampled searches.
+	Shadow *ingest.Shadow
+	Cache  *cache.ResultCache
+	// MCPStdio serves the MCP tools over stdin/stdout instead of starting the
+	// HTTP and gRPC servers.
+	MCPStdio bool
+}
+
+// Run builds the HTTP, gRPC and MCP servers on cfg and serves until the HTTP
+// server stops.
+func Run(ctx context.Context, cfg Config) error {
+	s := cfg.Settings
+	if cfg.ReplicaID == "" {
+		cfg.ReplicaID = NewReplicaID()
+	}
+
+	backups, err := initBackupStore(
+		s.Backups.Endpoint,
+		s.Backups.AccessKey,
+		s.Backups.SecretKey,
+		s.Backups.Bucket,
+		s.Backups.Prefix,
+		s.Backups.UseSSL,
+	)
+	if err != nil {
+		logging.Fatal("backup store init", "error", err)
+	}
+	if backups != nil {
+		backups.PGDump, backups.DSN = s.Backups.PGDump, s.Postgres.DSN
+	}
+
+	dailyLocation, err := time.LoadLocation(s.Daily.Timezone)
+	if err != nil {
+		logging.Fatal("invalid DAILY_TIMEZONE", "error", err)
+	}
+	dailyTopics, err := hijri.ParseSeasons(s.Daily.HijriTopics)
+	if err != nil {
+		logging.Fatal("invalid DAILY_HIJRI_TOPICS", "error", err)
+	}
+
+	jwtSecret := []byte(s.Auth.JWTSecret)
+	if len(jwtSecret) == 0 {
+		slog.Warn("JWT_SECRET is not set; using a random secret, issued tokens will not survive a restart")
+		jwtSecret = make([]byte, 32)
+		rand.Read(jwtSecret)
+	}
+	adminNetworks, err := parseNetworks(s.Auth.AdminNetworks)
+	if err != nil {
+		logging.Fatal("invalid ADMIN_ALLOWED_NETWORKS", "error", err)
+	}
+
+	rateLimits, err := parseRateLimits(s.HTTP.RateLimits)
+	if err != nil {
+		logging.Fatal("invalid RATE_LIMITS", "error", err)
+	}
+	searchCfg, err := searchSettings(s)
+	if err != nil {
+		logging.Fatal("search settings", "error", err)
+	}
+
+	exportSigningKey := []byte(s.Exports.SigningKey)
+	if len(exportSigningKey) == 0 {
+		exportSigningKey = derivedKey(jwtSecret, "export links")
+	}
+
+	timeouts := requestTimeouts{
+		Search: s.HTTP.SearchTimeout,
+		Upload: s.HTTP.UploadTimeout,
+	}
+
+	jobQueue := jobs.New(cfg.Postgres, jobs.Config{
+		Workers:      s.Jobs.Workers,
+		PollInterval: s.Jobs.PollInterval,
+		Retention:    s.Jobs.Retention,
+		Worker:       cfg.ReplicaID,
+	})
+	deps := &AppDependencies{
+		Postgres:       cfg.Postgres,
+		ReadPostgres:   cmp.Or(cfg.ReadPostgres, cfg.Postgres),
+		Qdrant:         cfg.Qdrant,
+		QdrantHTTPURL:  cfg.QdrantHTTPURL,
+		QdrantAPIKey:   s.Qdrant.APIKey,
+		PublicBaseURL:  strings.TrimRight(s.HTTP.PublicBaseURL, "/"),
+		Store:          cfg.Store,
+		Vectors:        cfg.Vectors,
+		Embedder:       cfg.Embedder,
+		EmbeddingModel: cfg.EmbeddingModel,
+		Backups:        backups,
+		DailyCalendar:  s.Daily.Calendar,
+		DailyLocation:  dailyLocation,
+		DailyTopics:    dailyTopics,
+		Hijri:          hijri.Calendar{Method: hijri.Method(s.Daily.HijriMethod), Adjustment: s.Daily.HijriAdjustment},
+		Stats:          newStatsCache(s.HTTP.StatsCacheTTL),
+		Webhooks:       newWebhookDispatcher(cfg.Postgres, jobQueue, newEventBus(s.Events, jobQueue)),
+		APIKeys:        newAPIKeyStore(cfg.Postgres, s.Auth.AdminAPIKey),
+		Tenants:        newTenantDirectory(cfg.Postgres, s.Auth.AdminAPIKey),
+		Features:       newFeatureFlags(s.Features.Disabled),
+		Maintenance:    newMaintenance(s.Maintenance.Enabled, s.Maintenance.Message),
+		Moderation:     newModeration(s.Moderation),
+		Users:          newUserAuth(cfg.Postgres, jwtSecret, s.Auth.JWTTTL),
+		Audit:          newAuditLog(cfg.Postgres, cmp.Or(cfg.ReadPostgres, cfg.Postgres)),
+		AdminNetworks:  adminNetworks,
+		Exports:        newExportStore(backups, s.Exports.Dir, exportSigningKey, s.HTTP.PublicBaseURL),
+		Cache:          cfg.Cache,
+		Timeouts:       timeouts,
+		Search:         search.New(cfg.Vectors, cfg.Embedder, cfg.Cache, searchCfg),
+		Jobs:           jobQueue,
+		Replicas:       newReplicaRegistry(cfg.Postgres, cfg.ReplicaID),
+		SearchHistory:  newSearchHistory(cfg.Postgres),
+		Experiments:    newExperiments(cfg.Postgres),
+		SearchEvents:   newSearchEvents(cfg.Postgres, s.Search.EventRetention),
+		ShareHadithURL: s.Share.HadithURL,
+		Sitemaps:       newSitemaps(s.Sitemap),
+		Topics:         newTopicIndex(),
+		ShareSearchURL: s.Share.SearchURL,
+		ShareSiteName:  s.Share.SiteName,
+	}
+	deps.Ingest = ingest.New(ingest.Config{
+		Postgres:    cfg.Postgres,
+		Store:       cfg.Store,
+		Vectors:     cfg.Vectors,
+		Embedder:    cfg.Embedder,
+		Model:       cfg.EmbeddingModel,
+		Shadow:      cfg.Shadow,
+		Notifier:    contentNotifier{deps},
+		Concurrency: s.Ingest.Concurrency,
+		Limiter:     ingest.NewLimiter(s.Ingest.MaxJobs, s.Ingest.MaxQueue, s.Ingest.QueueTimeout),
+		TokenPrice:  embed.Price(s.Embedder.TokenPrice, s.Embedder.Provider, s.Embedder.Model),
+	})
+	channels, err := notifyChannels(s.Notify)
+	if err != nil {
+		logging.Fatal("notify channels", "error", err)
+	}
+	deps.Subscriptions = newSubscriptions(deps, channels)
+	if s.Search.KeywordFallback {
+		deps.Search.SetFallback(cfg.Store)
+	}
+	if cfg.Shadow != nil {
+		deps.Search.SetShadow(&search.Shadow{Embedder: cfg.Shadow.Embedder, Index: cfg.Shadow.Vectors, SampleRate: s.Shadow.SearchSampleRate})
+	}
+	registerJobKinds(deps)
+
+	if s.OIDC.Issuer != "" {
+		roleMap, err := parseRoleMap(s.OIDC.RoleMap)
+		if err != nil {
+			logging.Fatal("invalid OIDC_ROLE_MAP", "error", err)
+		}
+		deps.OIDC, err = newOIDCProvider(ctx, oidcConfig{
+			Issuer:            s.OIDC.Issuer,
+			ClientID:          s.OIDC.ClientID,
+			ClientSecret:      s.OIDC.ClientSecret,
+			RedirectURL:       s.OIDC.RedirectURL,
+			Scopes:            s.OIDC.Scopes,
+			RoleClaim:         s.OIDC.RoleClaim,
+			RoleMap:           roleMap,
+			PostLoginRedirect: s.OIDC.PostLoginRedirect,
+		})
+		if err != nil {
+			logging.Fatal("oidc init", "error", err)
+		}
+	}
+
+	if cfg.MCPStdio {
+		return runMCPStdio(ctx, deps)
+	}
+
+	if err := deps.Replicas.heartbeat(ctx); err != nil {
+		logging.Fatal("replica registration", "error", err)
+	}
+	go deps.Replicas.run(ctx, deps)
+	if err := failInterruptedExports(ctx, deps); err != nil {
+		logging.Fatal("exports", "error", err)
+	}
+	deps.Jobs.Start(ctx)
+	go deps.Ingest.RunOutbox(ctx, s.Ingest.OutboxInterval)
+	go deps.Ingest.RunScheduler(ctx, s.Ingest.PublishInterval)
+
+	if s.Telegram.BotToken != "" {
+		bot := newTelegramBot(deps, s.Telegram.APIURL, s.Telegram.BotToken)
+		// Telegram allows one getUpdates poller per bot.
+		go postgres.RunAsLeader(ctx, cfg.Postgres, "telegram.poll", 15*time.Second, bot.Run)
+	}
+
+	// Deliveries are queued once per subscriber and day by a single replica.
+	go postgres.RunAsLeader(ctx, cfg.Postgres, "notify.schedule", time.Minute, deps.Subscriptions.schedule)
+	if s.Search.EventRetention > 0 {
+		go postgres.RunAsLeader(ctx, cfg.Postgres, "search_events.prune", time.Minute, deps.SearchEvents.prune)
+	}
+
+	e := echo.New()
+	e.HideBanner = true
+	e.HTTPErrorHandler = httpErrorHandler
+	// Only honour X-Forwarded-For set by proxies on loopback/private networks.
+	e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	logControl := newLogControl(s.Log.Format, s.Log.SampleRate)
+
+	e.Use(middleware.Recover())
+	e.Use(middleware.RequestID())
+	e.Use(requestContext())
+	e.Use(metricsMiddleware())
+	if len(s.CORS.AllowedOrigins) > 0 {
+		e.Use(publicCORS(middleware.CORSConfig{
+			AllowOrigins:     s.CORS.AllowedOrigins,
+			AllowMethods:     s.CORS.AllowedMethods,
+			AllowHeaders:     s.CORS.AllowedHeaders,
+			AllowCredentials: s.CORS.AllowCredentials,
+			MaxAge:           s.CORS.MaxAge,
+		}))
+	}
+	e.Use(logControl.accessLogger())
+	e.Use(logControl.debugLogger())
+	e.Use(deps.Users.middleware())
+	e.Use(deps.Tenants.middleware())
+	e.Use(deps.APIKeys.middleware())
+	abuseSettings, err := parseAbuseSettings(s.Abuse)
+	if err != nil {
+		logging.Fatal("invalid abuse settings", "error", err)
+	}
+	abuse := newAbuseGuard(cfg.Postgres, derivedKey(jwtSecret, "abuse challenges"), abuseSettings)
+	e.Use(abuse.middleware())
+	go abuse.refresh(ctx)
+	go postgres.RunAsLeader(ctx, cfg.Postgres, "abuse.prune", time.Minute, abuse.prune)
+	rateLimiter := newRateLimiter(cfg.Postgres, rateLimits)
+	e.Use(rateLimiter.middleware())
+	e.Use(deps.Maintenance.middleware())
+	go postgres.RunAsLeader(ctx, cfg.Postgres, "ratelimit.prune", time.Minute, rateLimiter.prune)
+	if cfg.Loaded != nil {
+		reloader := &settingsReloader{deps: deps, logControl: logControl, rateLimiter: rateLimiter, abuse: abuse}
+		go cfg.Loaded.Watch(ctx, s.Reload.Interval, reloader.apply)
+	}
+	usage := newUsageTracker(cfg.Postgres)
+	e.Use(usage.middleware())
+	go usage.run(ctx)
+	useGzip, useZstd, err := parseCompression(s.HTTP.Compression)
+	if err != nil {
+		logging.Fatal("invalid COMPRESSION", "error", err)
+	}
+	e.Use(compression(useGzip, useZstd, s.HTTP.CompressionLevel, s.HTTP.CompressionMinLength))
+	// Gzip request bodies are inflated before the body limits, which then
+	// apply to the decompressed size.
+	e.Use(middleware.Decompress())
+	e.Use(bodyLimits(
+		s.HTTP.BodyLimitSearch,
+		s.HTTP.BodyLimitUpload,
+		s.HTTP.BodyLimitDefault,
+	))
+	e.Use(handlerTimeout(s.HTTP.HandlerTimeout))
+
+	registerHealthRoutes(e, newReadiness(deps, s.HTTP.ReadyTimeout, s.HTTP.ReadyCacheTTL, s.Search.KeywordFallback))
+	metricsNetworks, err := parseNetworks(s.HTTP.MetricsNetworks)
+	if err != nil {
+		logging.Fatal("invalid METRICS_ALLOWED_NETWORKS", "error", err)
+	}
+	registerMetricsRoute(e, deps, metricsNetworks)
+
+	e.POST("/v1/search", func(c echo.Context) error {
+		var req searchRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		req.Limit, err = deps.Search.Limit(c.Request().Context(), req.Limit)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request().Context(), deps.Timeouts.Search)
+		defer cancel()
+
+		exp, variant := deps.Experiments.assign(c)
+		var ranking *search.Ranking
+		if variant != nil {
+			ranking = variant.ranking()
+		}
+		res, err := rankedSearch(ctx, deps, req.Query, req.Limit, ranking)
+		if err != nil {
+			return err
+		}
+		if res.Degraded {
+			c.Response().Header().Set(headerSearchDegraded, "true")
+		}
+		if u := currentUser(c); u != nil {
+			go deps.SearchHistory.record(context.WithoutCancel(ctx), u.UserID(), req.Query)
+		}
+
+		resp := searchResponse{Results: toSearchResults(i18n.From(ctx), res.Hits), Degraded: res.Degraded}
+		ev := searchEvent{Tenant: tenant.From(ctx), Client: searchClient(c), Query: req.Query, Results: searchResultIDs(resp.Results)}
+		// Keyword matches served while semantic search is down say nothing
+		// about the variant.
+		if variant != nil && !res.Degraded {
+			ev.Experiment, ev.Variant = &exp.ID, &variant.Name
+			resp.Variant = variant.Name
+		}
+		if ev.Tenant != tenant.All {
+			resp.QueryID = deps.SearchEvents.record(ctx, ev)
+		}
+		return respond(c, http.StatusOK, resp)
+	})
+
+	// Routes are split by caller: /v1/admin by role, /v1/me for signed-in
+	// users' own data and the public read API for anyone.
+	adminNetwork := requireNetwork(deps.AdminNetworks)
+	editor := e.Group("/v1/admin", adminNetwork, requireRole(deps, roleEditor), deps.Audit.middleware())
+	admin := e.Group("/v1/admin", adminNetwork, requireRole(deps, roleAdmin), deps.Audit.middleware())
+	me := e.Group("/v1/me", requireUser)
+	public := newPublicAPI(e, rateLimiter, s.HTTP.PublicCacheMaxAge, s.HTTP.PublicCacheSharedMaxAge)
+
+	editor.POST("/hadiths/upload", func(c echo.Context) error {
+		var req ingest.HadithUploadRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+
+		if c.QueryParam("dry_run") == "true" {
+			resp, err := deps.Ingest.Estimate(c.Request().Context(), &req)
+			if err != nil {
+				return err
+			}
+			resp.Localize(i18n.From(c.Request().Context()))
+			return c.JSON(http.StatusOK, resp)
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request().Context(), deps.Timeouts.Upload)
+		defer cancel()
+
+		// With Accept: text/event-stream, progress is streamed as it is
+		// made and the response or error is the last event.
+		var stream *eventStream
+		if acceptsEventStream(c) {
+			stream = &eventStream{c: c}
+			lang := i18n.From(ctx)
+			ctx = ingest.WithProgress(ctx, func(p ingest.Progress) {
+				if p.Error != nil {
+					p.Error = p.Error.Localize(lang)
+				}
+				if err := stream.send("progress", p); err != nil {
+					slog.DebugContext(ctx, "upload: write progress failed", "error", err)
+				}
+			})
+		}
+
+		resp, err := deps.Ingest.Ingest(ctx, &req)
+		auditNote(c, "hadiths.upload", uploadAuditSummary(&req, resp), "collection:"+req.Collection.Code)
+		resp.Localize(i18n.From(ctx))
+		if stream != nil && (err == nil || c.Response().Committed) {
+			if err != nil {
+				return stream.send("error", responseError(c, err))
+			}
+			return stream.send("result", resp)
+		}
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, resp)
+	})
+
+	// Metadata edits patch the points' payload instead of embedding again.
+	editor.PATCH("/hadiths/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		var req hadithPatchRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		auditNote(c, "hadith.patch", map[string]any{"grade": req.Grade, "title": req.Title, "topics": req.Topics}, "hadith:"+c.Param("id"))
+		h, err := deps.Ingest.Patch(c.Request().Context(), id, ingest.HadithPatch{Grade: req.Grade, Title: req.Title, Topics: req.Topics})
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, h)
+	})
+
+	registerAuthRoutes(e, me, deps, s.Auth.PasswordLogin)
+	registerBookmarkRoutes(me, deps)
+	registerNoteRoutes(me, deps)
+	registerReadingListRoutes(me, public, deps)
+	registerSearchHistoryRoutes(me, public, deps)
+	registerSubscriptionRoutes(e, me, deps)
+	registerShareRoutes(e, public, deps)
+	if deps.OIDC != nil {
+		registerOIDCRoutes(e, deps)
+	}
+	registerHadithRoutes(e, public, deps)
+	registerBookRoutes(public, deps)
+	registerAyahRoutes(editor, public, deps)
+	registerTopicRoutes(editor, deps)
+	registerModerationRoutes(editor, deps)
+	registerBatchRoute(e)
+	registerBulkRoutes(editor, deps)
+	registerScholarRoutes(editor, public, deps)
+	registerFeaturedRoutes(editor, public, deps)
+	registerDuplicateRoutes(editor, public, deps)
+	registerExperimentRoutes(admin, deps)
+	registerEventRoutes(e, deps)
+	registerDailyRoutes(e, public, deps)
+	registerCalendarRoutes(public, deps)
+	registerStatsRoutes(public, deps)
+	registerFeedRoutes(public, deps)
+	registerSitemapRoutes(public, deps)
+	registerBackupRoutes(admin, deps)
+	registerLogControlRoutes(admin, logControl)
+	registerMaintenanceRoutes(admin, deps.Maintenance)
+	registerAbuseRoutes(admin, abuse)
+	registerClusterRoutes(admin, deps)
+	registerWebhookRoutes(admin, deps)
+	registerJobRoutes(admin, deps)
+	registerAPIKeyRoutes(admin, deps)
+	registerUserAdminRoutes(admin, deps)
+	registerTenantRoutes(admin, deps)
+	registerAuditRoutes(admin, deps)
+	registerExportRoutes(e, admin, deps)
+	registerUsageRoutes(admin, deps)
+	registerGraphQLRoute(e, deps)
+	registerWebSocketRoute(e, deps, s.HTTP.WSDebounce)
+	registerMCPRoute(e, deps)
+	registerOpenAPIRoute(e)
+
+	grpcSrv, err := startGRPCServer(":"+s.HTTP.GRPCPort, deps)
+	if err != nil {
+		logging.Fatal("grpc listen", "error", err)
+	}
+	defer grpcSrv.GracefulStop()
+
+	srvCfg := serverConfig{
+		Port:             s.HTTP.Port,
+		CertFile:         s.TLS.CertFile,
+		KeyFile:          s.TLS.KeyFile,
+		AutocertDomains:  s.TLS.AutocertDomains,
+		AutocertEmail:    s.TLS.AutocertEmail,
+		AutocertCacheDir: s.TLS.AutocertCacheDir,
+		RedirectPort:     s.HTTP.RedirectPort,
+		HTTP2:            s.HTTP.HTTP2,
+		H2C:              s.HTTP.H2C,
+
+		ReadHeaderTimeout: s.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       s.HTTP.ReadTimeout,
+		WriteTimeout:      s.HTTP.WriteTimeout,
+		IdleTimeout:       s.HTTP.IdleTimeout,
+		MaxHeaderBytes:    s.HTTP.MaxHeaderBytes,
+	}
+	if err := serve(e, srvCfg); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,411 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/i18n"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// metadataSlug is the form of scholar and book slugs, e.g. "al-albani".
+var metadataSlug = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+const scholarColumns = `s.slug, s.name, s.name_ar, s.born_ah, s.died_ah, s.bio, s.updated_at`
+
+func scanScholar(row pgx.Row) (scholar, error) {
+	var s scholar
+	err := row.Scan(&s.Slug, &s.Name, &s.NameAr, &s.BornAH, &s.DiedAH, &s.Bio, &s.UpdatedAt)
+	return s, err
+}
+
+const sourceBookColumns = `b.slug, b.title, b.title_ar, a.slug, a.name, b.edition, b.publisher, b.published_year, b.volumes, b.updated_at`
+
+const sourceBookFrom = `source_books b LEFT JOIN scholars a ON a.id = b.author_id`
+
+func scanSourceBook(row pgx.Row) (sourceBook, error) {
+	var (
+		b                      sourceBook
+		authorSlug, authorName *string
+	)
+	err := row.Scan(&b.Slug, &b.Title, &b.TitleAr, &authorSlug, &authorName, &b.Edition, &b.Publisher, &b.PublishedYear, &b.Volumes, &b.UpdatedAt)
+	if authorSlug != nil {
+		b.Author = &scholarRef{Slug: *authorSlug, Name: *authorName}
+	}
+	return b, err
+}
+
+func collectSourceBooks(rows pgx.Rows, err error) ([]sourceBook, error) {
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (sourceBook, error) { return scanSourceBook(row) })
+}
+
+// citation renders a reference to a place in a book, e.g. "Sahih
+// al-Bukhari (Dar Tawq al-Najah), vol. 1, p. 6, no. 1".
+func citation(lang string, book sourceBookRef, volume, page *int, number string) string {
+	parts := []string{book.Title}
+	if book.Edition != "" {
+		parts[0] += " (" + book.Edition + ")"
+	}
+	if volume != nil {
+		parts = append(parts, i18n.T(lang, "citation.volume", *volume))
+	}
+	if page != nil {
+		parts = append(parts, i18n.T(lang, "citation.page", *page))
+	}
+	if number != "" {
+		parts = append(parts, i18n.T(lang, "citation.number", number))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// hadithCitationsOf returns the sources and gradings of hadith id, with
+// citations in lang.
+func hadithCitationsOf(ctx context.Context, deps *AppDependencies, id int64, lang string) (hadithCitations, error) {
+	out := hadithCitations{HadithID: id}
+	rows, err := deps.Postgres.Query(ctx, `
+SELECT b.slug, b.title, b.edition, hs.volume, hs.page, hs.number
+FROM hadith_sources hs JOIN source_books b ON b.id = hs.book_id
+WHERE hs.hadith_id = $1
+ORDER BY hs.position`, id)
+	if err != nil {
+		return out, err
+	}
+	out.Sources, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (hadithSource, error) {
+		var s hadithSource
+		err := row.Scan(&s.Book.Slug, &s.Book.Title, &s.Book.Edition, &s.Volume, &s.Page, &s.Number)
+		s.Citation = citation(lang, s.Book, s.Volume, s.Page, s.Number)
+		return s, err
+	})
+	if err != nil {
+		return out, err
+	}
+	rows, err = deps.Postgres.Query(ctx, `
+SELECT s.slug, s.name, g.grade, b.slug, b.title, b.edition, g.volume, g.page
+FROM hadith_gradings g JOIN scholars s ON s.id = g.scholar_id LEFT JOIN source_books b ON b.id = g.book_id
+WHERE g.hadith_id = $1
+ORDER BY s.died_ah NULLS LAST, s.name`, id)
+	if err != nil {
+		return out, err
+	}
+	out.Gradings, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (hadithGrading, error) {
+		var (
+			g                        hadithGrading
+			bookSlug, title, edition *string
+		)
+		err := row.Scan(&g.Scholar.Slug, &g.Scholar.Name, &g.Grade, &bookSlug, &title, &edition, &g.Volume, &g.Page)
+		if bookSlug != nil {
+			g.Book = &sourceBookRef{Slug: *bookSlug, Title: *title, Edition: *edition}
+			g.Citation = citation(lang, *g.Book, g.Volume, g.Page, "")
+		}
+		return g, err
+	})
+	return out, err
+}
+
+// slugIDs maps the given slugs of table to their ids in tenantID, failing
+// on the first one that does not exist.
+func slugIDs(ctx context.Context, tx pgx.Tx, tenantID int64, table, kind string, slugs []string) (map[string]int64, error) {
+	rows, err := tx.Query(ctx, `SELECT slug, id FROM `+table+` WHERE tenant_id = $1 AND slug = ANY($2)`, tenantID, slugs)
+	if err != nil {
+		return nil, apierr.Database("db query failed")
+	}
+	ids := map[string]int64{}
+	for rows.Next() {
+		var (
+			slug string
+			id   int64
+		)
+		if err := rows.Scan(&slug, &id); err != nil {
+			return nil, apierr.Database("db query failed")
+		}
+		ids[slug] = id
+	}
+	if rows.Err() != nil {
+		return nil, apierr.Database("db query failed")
+	}
+	for _, s := range slugs {
+		if _, ok := ids[s]; !ok && s != "" {
+			return nil, apierr.InvalidArgument("unknown " + kind + " " + s)
+		}
+	}
+	return ids, nil
+}
+
+// optionalID is the id of slug, or nil for "".
+func optionalID(ids map[string]int64, slug string) *int64 {
+	if slug == "" {
+		return nil
+	}
+	id := ids[slug]
+	return &id
+}
+
+// metadataSlugParam is the slug of a scholar or book being written, which
+// belongs to the request's tenant.
+func metadataSlugParam(c echo.Context) (string, error) {
+	if tenant.From(c.Request().Context()) == tenant.All {
+		return "", apierr.InvalidArgument("editing needs a tenant")
+	}
+	slug := c.Param("slug")
+	if !metadataSlug.MatchString(slug) {
+		return "", apierr.InvalidArgument("invalid slug")
+	}
+	return slug, nil
+}
+
+// registerScholarRoutes serves scholars, source books and the citations of
+// hadiths to anyone, and lets editors maintain them under /v1/admin.
+func registerScholarRoutes(editor *echo.Group, public *publicAPI, deps *AppDependencies) {
+	public.GET("/v1/scholars", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		rows, err := deps.Postgres.Query(ctx, `
+SELECT `+scholarColumns+` FROM scholars s
+WHERE `+postgres.TenantWhere("s", 1)+`
+ORDER BY s.died_ah NULLS LAST, s.name`, tenant.From(ctx))
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		scholars, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (scholar, error) { return scanScholar(row) })
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, scholarListResponse{Scholars: scholars})
+	})
+
+	public.GET("/v1/scholars/:slug", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		s, err := scanScholar(deps.Postgres.QueryRow(ctx, `
+SELECT `+scholarColumns+` FROM scholars s WHERE s.slug = $1 AND `+postgres.TenantWhere("s", 2),
+			c.Param("slug"), tenant.From(ctx)))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("scholar not found")
+		}
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		s.Books, err = collectSourceBooks(deps.Postgres.Query(ctx, `
+SELECT `+sourceBookColumns+` FROM `+sourceBookFrom+`
+WHERE a.slug = $1 AND `+postgres.TenantWhere("b", 2)+`
+ORDER BY b.title`, s.Slug, tenant.From(ctx)))
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, s)
+	})
+
+	public.GET("/v1/sources", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		books, err := collectSourceBooks(deps.Postgres.Query(ctx, `
+SELECT `+sourceBookColumns+` FROM `+sourceBookFrom+`
+WHERE `+postgres.TenantWhere("b", 1)+`
+ORDER BY b.title`, tenant.From(ctx)))
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, sourceBookListResponse{Books: books})
+	})
+
+	public.GET("/v1/sources/:slug", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		b, err := scanSourceBook(deps.Postgres.QueryRow(ctx, `
+SELECT `+sourceBookColumns+` FROM `+sourceBookFrom+`
+WHERE b.slug = $1 AND `+postgres.TenantWhere("b", 2), c.Param("slug"), tenant.From(ctx)))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("source not found")
+		}
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, b)
+	})
+
+	public.GET("/v1/hadiths/:id/citations", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		if _, err := noteHadith(ctx, deps, id); err != nil {
+			return err
+		}
+		// Citations are rendered in the request's language.
+		c.Response().Header().Add(echo.HeaderVary, "Accept-Language")
+		out, err := hadithCitationsOf(ctx, deps, id, i18n.From(ctx))
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, out)
+	})
+
+	editor.PUT("/scholars/:slug", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		slug, err := metadataSlugParam(c)
+		if err != nil {
+			return err
+		}
+		var req scholarRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		auditNote(c, "scholar.put", map[string]any{"name": req.Name}, "scholar:"+slug)
+		s, err := scanScholar(deps.Postgres.QueryRow(ctx, `
+INSERT INTO scholars AS s (tenant_id, slug, name, name_ar, born_ah, died_ah, bio)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (tenant_id, slug) DO UPDATE SET
+  name = EXCLUDED.name, name_ar = EXCLUDED.name_ar, born_ah = EXCLUDED.born_ah,
+  died_ah = EXCLUDED.died_ah, bio = EXCLUDED.bio, updated_at = now()
+RETURNING `+scholarColumns, tenant.From(ctx), slug, strings.TrimSpace(req.Name), strings.TrimSpace(req.NameAr), req.BornAH, req.DiedAH, req.Bio))
+		if err != nil {
+			return apierr.Database("db upsert scholar failed")
+		}
+		return c.JSON(http.StatusOK, s)
+	})
+
+	editor.DELETE("/scholars/:slug", func(c echo.Context) error {
+		auditNote(c, "scholar.delete", nil, "scholar:"+c.Param("slug"))
+		tag, err := deps.Postgres.Exec(c.Request().Context(), `DELETE FROM scholars WHERE tenant_id = $1 AND slug = $2`,
+			tenant.From(c.Request().Context()), c.Param("slug"))
+		if err != nil {
+			return apierr.Database("db delete scholar failed")
+		}
+		if tag.RowsAffected() == 0 {
+			return apierr.NotFound("scholar not found")
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	editor.PUT("/sources/:slug", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		slug, err := metadataSlugParam(c)
+		if err != nil {
+			return err
+		}
+		var req sourceBookRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		auditNote(c, "source.put", map[string]any{"title": req.Title, "author": req.Author}, "source:"+slug)
+		tx, err := deps.Postgres.Begin(ctx)
+		if err != nil {
+			return apierr.Database("db upsert source failed")
+		}
+		defer tx.Rollback(ctx)
+		authors, err := slugIDs(ctx, tx, tenant.From(ctx), "scholars", "scholar", []string{req.Author})
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+INSERT INTO source_books (tenant_id, slug, title, title_ar, author_id, edition, publisher, published_year, volumes)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (tenant_id, slug) DO UPDATE SET
+  title = EXCLUDED.title, title_ar = EXCLUDED.title_ar, author_id = EXCLUDED.author_id,
+  edition = EXCLUDED.edition, publisher = EXCLUDED.publisher, published_year = EXCLUDED.published_year,
+  volumes = EXCLUDED.volumes, updated_at = now()`,
+			tenant.From(ctx), slug, strings.TrimSpace(req.Title), strings.TrimSpace(req.TitleAr), optionalID(authors, req.Author),
+			strings.TrimSpace(req.Edition), strings.TrimSpace(req.Publisher), req.PublishedYear, req.Volumes)
+		if err != nil {
+			return apierr.Database("db upsert source failed")
+		}
+		b, err := scanSourceBook(tx.QueryRow(ctx, `
+SELECT `+sourceBookColumns+` FROM `+sourceBookFrom+` WHERE b.tenant_id = $1 AND b.slug = $2`, tenant.From(ctx), slug))
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+		if err != nil {
+			return apierr.Database("db upsert source failed")
+		}
+		return c.JSON(http.StatusOK, b)
+	})
+
+	editor.DELETE("/sources/:slug", func(c echo.Context) error {
+		auditNote(c, "source.delete", nil, "source:"+c.Param("slug"))
+		tag, err := deps.Postgres.Exec(c.Request().Context(), `DELETE FROM source_books WHERE tenant_id = $1 AND slug = $2`,
+			tenant.From(c.Request().Context()), c.Param("slug"))
+		if err != nil {
+			return apierr.Database("db delete source failed")
+		}
+		if tag.RowsAffected() == 0 {
+			return apierr.NotFound("source not found")
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	editor.PUT("/hadiths/:id/citations", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		var req hadithCitationsRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		h, err := noteHadith(ctx, deps, id)
+		if err != nil {
+			return err
+		}
+		auditNote(c, "hadith.citations", map[string]any{"sources": len(req.Sources), "gradings": len(req.Gradings)},
+			"hadith:"+strconv.FormatInt(id, 10))
+
+		var bookSlugs, scholarSlugs []string
+		for _, s := range req.Sources {
+			bookSlugs = append(bookSlugs, s.Book)
+		}
+		for _, g := range req.Gradings {
+			bookSlugs = append(bookSlugs, g.Book)
+			scholarSlugs = append(scholarSlugs, g.Scholar)
+		}
+		tx, err := deps.Postgres.Begin(ctx)
+		if err != nil {
+			return apierr.Database("db update citations failed")
+		}
+		defer tx.Rollback(ctx)
+		books, err := slugIDs(ctx, tx, h.TenantID, "source_books", "source", bookSlugs)
+		if err != nil {
+			return err
+		}
+		scholars, err := slugIDs(ctx, tx, h.TenantID, "scholars", "scholar", scholarSlugs)
+		if err != nil {
+			return err
+		}
+
+		batch := &pgx.Batch{}
+		batch.Queue(`DELETE FROM hadith_sources WHERE hadith_id = $1`, id)
+		batch.Queue(`DELETE FROM hadith_gradings WHERE hadith_id = $1`, id)
+		for i, s := range req.Sources {
+			batch.Queue(`
+INSERT INTO hadith_sources (hadith_id, book_id, position, volume, page, number) VALUES ($1, $2, $3, $4, $5, $6)`,
+				id, books[s.Book], i, s.Volume, s.Page, strings.TrimSpace(s.Number))
+		}
+		for _, g := range req.Gradings {
+			batch.Queue(`
+INSERT INTO hadith_gradings (hadith_id, scholar_id, grade, book_id, volume, page) VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (hadith_id, scholar_id) DO UPDATE SET grade = EXCLUDED.grade, book_id = EXCLUDED.book_id,
+  volume = EXCLUDED.volume, page = EXCLUDED.page`,
+				id, scholars[g.Scholar], strings.TrimSpace(g.Grade), optionalID(books, g.Book), g.Volume, g.Page)
+		}
+		err = tx.SendBatch(ctx, batch).Close()
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+		if err != nil {
+			return apierr.Database("db update citations failed")
+		}
+		out, err := hadithCitationsOf(ctx, deps, id, i18n.From(ctx))
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, out)
+	})
+}
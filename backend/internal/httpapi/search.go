@@ -0,0 +1,79 @@
+package httpapi
+
+import (
+	"context"
+
+	"github.com/buugaaga/test-cursor/backend/internal/i18n"
+	"github.com/buugaaga/test-cursor/backend/internal/search"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// headerSearchDegraded is set on responses with keyword matches served
+// while semantic search is down, for formats without the degraded field.
+const headerSearchDegraded = "X-Search-Degraded"
+
+// semanticSearch runs a search on behalf of a request, counting it against
+// the caller's API key usage.
+func semanticSearch(ctx context.Context, deps *AppDependencies, query string, limit int) (search.Results, error) {
+	return rankedSearch(ctx, deps, query, limit, nil)
+}
+
+// rankedSearch is semanticSearch with the ranking of an experiment variant.
+func rankedSearch(ctx context.Context, deps *AppDependencies, query string, limit int, r *search.Ranking) (search.Results, error) {
+	meterSearch(ctx)
+	return deps.Search.SemanticRanked(ctx, query, limit, r)
+}
+
+// hitTitle is the title of a hit in lang. Payloads store it in English.
+func hitTitle(lang string, payload map[string]*qdrant.Value) string {
+	if payload["origin_type"].GetStringValue() == "hadith" {
+		if title := payload["custom_title"].GetStringValue(); title != "" {
+			return title
+		}
+		return i18n.T(lang, "hadith.title", payload["number"].GetStringValue(), payload["collection_code"].GetStringValue())
+	}
+	return payload["title"].GetStringValue()
+}
+
+func toSearchResults(lang string, hits []search.Hit) []searchResult {
+	results := make([]searchResult, 0, len(hits))
+	for _, h := range hits {
+		resultPayload := map[string]any{}
+		for k, v := range h.Payload {
+			resultPayload[k] = v
+		}
+		if _, ok := h.Payload["title"]; ok {
+			resultPayload["title"] = hitTitle(lang, h.Payload)
+		}
+		results = append(results, searchResult{ID: h.ID, Score: h.Score, Payload: resultPayload})
+	}
+	return results
+}
+
+// payloadValue converts a Qdrant payload value to plain Go values.
+func payloadValue(v *qdrant.Value) any {
+	switch k := v.GetKind().(type) {
+	case *qdrant.Value_StringValue:
+		return k.StringValue
+	case *qdrant.Value_IntegerValue:
+		return k.IntegerValue
+	case *qdrant.Value_DoubleValue:
+		return k.DoubleValue
+	case *qdrant.Value_BoolValue:
+		return k.BoolValue
+	case *qdrant.Value_ListValue:
+		list := make([]any, 0, len(k.ListValue.GetValues()))
+		for _, item := range k.ListValue.GetValues() {
+			list = append(list, payloadValue(item))
+		}
+		return list
+	case *qdrant.Value_StructValue:
+		m := make(map[string]any, len(k.StructValue.GetFields()))
+		for name, item := range k.StructValue.GetFields() {
+			m[name] = payloadValue(item)
+		}
+		return m
+	default:
+		return nil
+	}
+}
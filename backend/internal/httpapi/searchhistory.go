@@ -0,0 +1,183 @@
+package httpapi
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+// searchHistoryKept is how many distinct queries are kept per user.
+const searchHistoryKept = 100
+
+// SearchHistory keeps the recent searches of signed-in users who have not
+// turned it off.
+type SearchHistory struct {
+	db *pgxpool.Pool
+}
+
+func newSearchHistory(db *pgxpool.Pool) *SearchHistory {
+	return &SearchHistory{db: db}
+}
+
+// record notes a search by user, unless they turned history off, and drops
+// their oldest searches past searchHistoryKept.
+func (h *SearchHistory) record(ctx context.Context, user int64, query string) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return
+	}
+	tag, err := h.db.Exec(ctx, `
+INSERT INTO search_history (user_id, query)
+SELECT id, $2 FROM users WHERE id = $1 AND search_history
+ON CONFLICT (user_id, lower(query)) DO UPDATE SET query = EXCLUDED.query, count = search_history.count + 1, searched_at = now()`, user, query)
+	if err == nil && tag.RowsAffected() > 0 {
+		_, err = h.db.Exec(ctx, `
+DELETE FROM search_history WHERE user_id = $1 AND id NOT IN (
+  SELECT id FROM search_history WHERE user_id = $1 ORDER BY searched_at DESC LIMIT $2)`, user, searchHistoryKept)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "search history: record failed", "user_id", user, "error", err)
+	}
+}
+
+func (h *SearchHistory) clear(ctx context.Context, user int64) error {
+	_, err := h.db.Exec(ctx, `DELETE FROM search_history WHERE user_id = $1`, user)
+	return err
+}
+
+// likePrefix matches strings starting with s in LIKE.
+func likePrefix(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s) + "%"
+}
+
+// suggest completes prefix from user's searches, most frequent first, and
+// then from hadith topics of the tenant of ctx. user is 0 for anonymous
+// requests.
+func (h *SearchHistory) suggest(ctx context.Context, user int64, prefix string, limit int) ([]searchSuggestion, error) {
+	out := []searchSuggestion{}
+	seen := map[string]bool{}
+	if user != 0 {
+		rows, err := h.db.Query(ctx, `
+SELECT query FROM search_history
+WHERE user_id = $1 AND lower(query) LIKE lower($2)
+ORDER BY count DESC, searched_at DESC
+LIMIT $3`, user, likePrefix(prefix), limit)
+		if err != nil {
+			return nil, err
+		}
+		queries, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range queries {
+			seen[strings.ToLower(q)] = true
+			out = append(out, searchSuggestion{Text: q, Source: "history"})
+		}
+	}
+	if prefix == "" || len(out) >= limit {
+		return out, nil
+	}
+	rows, err := h.db.Query(ctx, `
+SELECT DISTINCT t FROM hadiths h, unnest(h.topics) t
+WHERE t ILIKE $1 AND `+postgres.Published("h")+` AND `+postgres.TenantWhere("h", 2)+`
+ORDER BY t
+LIMIT $3`, likePrefix(prefix), tenant.From(ctx), limit)
+	if err != nil {
+		return nil, err
+	}
+	topics, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range topics {
+		if len(out) == limit {
+			break
+		}
+		if !seen[strings.ToLower(t)] {
+			out = append(out, searchSuggestion{Text: t, Source: "topic"})
+		}
+	}
+	return out, nil
+}
+
+func registerSearchHistoryRoutes(me *echo.Group, public *publicAPI, deps *AppDependencies) {
+	g := me.Group("/search-history")
+
+	g.GET("", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		limit, err := parseLimit(c.QueryParam("limit"), 20, searchHistoryKept)
+		if err != nil {
+			return apierr.InvalidArgument("invalid limit")
+		}
+		user := currentUser(c).UserID()
+		resp := searchHistoryResponse{}
+		if err := deps.Postgres.QueryRow(ctx, `SELECT search_history FROM users WHERE id = $1`, user).Scan(&resp.Enabled); err != nil {
+			return apierr.Database("db query failed")
+		}
+		rows, err := deps.Postgres.Query(ctx, `
+SELECT id, query, count, searched_at FROM search_history WHERE user_id = $1 ORDER BY searched_at DESC LIMIT $2`, user, limit)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		resp.Searches, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (searchHistoryEntry, error) {
+			var s searchHistoryEntry
+			err := row.Scan(&s.ID, &s.Query, &s.Count, &s.SearchedAt)
+			return s, err
+		})
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, resp)
+	})
+
+	g.DELETE("", func(c echo.Context) error {
+		if err := deps.SearchHistory.clear(c.Request().Context(), currentUser(c).UserID()); err != nil {
+			return apierr.Database("db delete search history failed")
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	g.DELETE("/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		tag, err := deps.Postgres.Exec(c.Request().Context(), `DELETE FROM search_history WHERE id = $1 AND user_id = $2`, id, currentUser(c).UserID())
+		if err != nil {
+			return apierr.Database("db delete search history failed")
+		}
+		if tag.RowsAffected() == 0 {
+			return apierr.NotFound("search not found")
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	public.GET("/v1/search/suggestions", func(c echo.Context) error {
+		limit, err := parseLimit(c.QueryParam("limit"), 10, 20)
+		if err != nil {
+			return apierr.InvalidArgument("invalid limit")
+		}
+		prefix := strings.TrimSpace(c.QueryParam("q"))
+		if len(prefix) > 200 {
+			return apierr.InvalidArgument("q is too long")
+		}
+		var user int64
+		if claims := currentUser(c); claims != nil {
+			user = claims.UserID()
+		}
+		suggestions, err := deps.SearchHistory.suggest(c.Request().Context(), user, prefix, limit)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, searchSuggestionsResponse{Suggestions: suggestions})
+	})
+}
@@ -0,0 +1,150 @@
+package httpapi
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// serverConfig selects how the HTTP API is served: plain HTTP (optionally
+// with cleartext HTTP/2 for proxies that speak h2c), HTTPS with a certificate
+// from disk, or HTTPS with certificates obtained from Let's Encrypt.
+type serverConfig struct {
+	Port             string
+	CertFile         string
+	KeyFile          string
+	AutocertDomains  []string
+	AutocertEmail    string
+	AutocertCacheDir string
+	// RedirectPort, when set with TLS, serves plain HTTP that redirects to
+	// HTTPS and answers ACME http-01 challenges.
+	RedirectPort string
+	HTTP2        bool
+	H2C          bool
+
+	// Zero ReadTimeout/WriteTimeout leave reads and writes unbounded, which
+	// long uploads, WebSockets and SSE streams need; handler timeouts bound
+	// the work instead.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+}
+
+func serve(e *echo.Echo, cfg serverConfig) error {
+	s := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Protocols:         new(http.Protocols),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	s.Protocols.SetHTTP1(true)
+
+	var redirect http.Handler
+	switch {
+	case cfg.CertFile != "":
+		if cfg.KeyFile == "" {
+			return errors.New("TLS_CERT_FILE needs TLS_KEY_FILE")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("load certificate: %w", err)
+		}
+		s.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{"h2", "http/1.1"},
+		}
+		redirect = httpsRedirect(cfg.Port)
+	case len(cfg.AutocertDomains) > 0:
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.AutocertDomains...),
+			Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+			Email:      cfg.AutocertEmail,
+		}
+		s.TLSConfig = m.TLSConfig()
+		redirect = m.HTTPHandler(httpsRedirect(cfg.Port))
+	}
+
+	if s.TLSConfig != nil {
+		s.TLSConfig.MinVersion = tls.VersionTLS12
+		s.Protocols.SetHTTP2(cfg.HTTP2)
+		if !cfg.HTTP2 {
+			s.TLSConfig.NextProtos = slices.DeleteFunc(s.TLSConfig.NextProtos, func(p string) bool { return p == "h2" })
+		}
+		if cfg.RedirectPort != "" {
+			rs := &http.Server{
+				Addr:              ":" + cfg.RedirectPort,
+				Handler:           redirect,
+				ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+				IdleTimeout:       cfg.IdleTimeout,
+				MaxHeaderBytes:    cfg.MaxHeaderBytes,
+			}
+			go func() {
+				if err := rs.ListenAndServe(); err != nil {
+					slog.Error("https redirect server stopped", "error", err)
+				}
+			}()
+		}
+	} else {
+		s.Protocols.SetUnencryptedHTTP2(cfg.H2C)
+	}
+	return e.StartServer(s)
+}
+
+func httpsRedirect(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// requestTimeouts bound the work done for a request where the default
+// handler timeout does not fit.
+type requestTimeouts struct {
+	Search time.Duration
+	Upload time.Duration
+}
+
+// longRunningRoutes are skipped by handlerTimeout: they stream, hold a
+// connection open or apply their own, longer timeout.
+var longRunningRoutes = map[string]bool{
+	"/v1/ws":                        true,
+	"/mcp":                          true,
+	"/v1/admin/hadiths/upload":      true,
+	"/v1/admin/backups":             true,
+	"/v1/admin/backups/:id/restore": true,
+	"/v1/admin/backups/:id/archive": true,
+	"/v1/exports/:id/download":      true,
+}
+
+// handlerTimeout cancels the request context after d so a stuck dependency
+// cannot hold a handler forever.
+func handlerTimeout(d time.Duration) echo.MiddlewareFunc {
+	if d <= 0 {
+		return func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+	}
+	return middleware.ContextTimeoutWithConfig(middleware.ContextTimeoutConfig{
+		Timeout: d,
+		Skipper: func(c echo.Context) bool { return longRunningRoutes[c.Path()] },
+	})
+}
@@ -0,0 +1,290 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// shareSearchHits is how many hadiths a search link keeps.
+	shareSearchHits = 5
+	// sharePreviewChars bounds og:description; unfurlers cut it further.
+	sharePreviewChars = 300
+)
+
+const shareLinkColumns = `slug, hadith_id, query, hits, created_at`
+
+// unfurlers are User-Agent substrings of the bots that fetch links posted in
+// messengers and social networks to build their previews.
+var unfurlers = []string{
+	"facebookexternalhit", "facebot", "twitterbot", "slackbot", "telegrambot", "whatsapp", "discordbot",
+	"linkedinbot", "skypeuripreview", "vkshare", "viber", "redditbot", "pinterest", "embedly", "iframely",
+	"mastodon", "applebot", "googlebot", "bingbot",
+}
+
+var sharePage = template.Must(template.New("share").Parse(`<!doctype html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<meta name="description" content="{{.Description}}">
+<meta property="og:type" content="article">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:url" content="{{.URL}}">
+{{- with .SiteName}}
+<meta property="og:site_name" content="{{.}}">
+{{- end}}
+<meta name="twitter:card" content="summary">
+<meta name="twitter:title" content="{{.Title}}">
+<meta name="twitter:description" content="{{.Description}}">
+{{- with .Target}}
+<link rel="canonical" href="{{.}}">
+{{- end}}
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{- range .Hadiths}}
+<article>
+<p dir="auto">{{.Text}}</p>
+<p><small>{{.Ref}}</small></p>
+</article>
+{{- end}}
+{{- with .Target}}
+<p><a href="{{.}}">Open</a></p>
+{{- end}}
+</body>
+</html>
+`))
+
+type sharePageData struct {
+	Lang, Title, Description, URL, SiteName, Target string
+	Hadiths                                         []sharePageHadith
+}
+
+type sharePageHadith struct {
+	Text, Ref string
+}
+
+func scanShareLink(row pgx.Row) (shareLink, error) {
+	var l shareLink
+	err := row.Scan(&l.Slug, &l.HadithID, &l.Query, &l.Hits, &l.CreatedAt)
+	l.Kind = "hadith"
+	if l.Query != nil {
+		l.Kind = "search"
+	}
+	return l, err
+}
+
+func isUnfurler(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	for _, bot := range unfurlers {
+		if strings.Contains(ua, bot) {
+			return true
+		}
+	}
+	return false
+}
+
+// shareTarget fills in the redirect template of a link, or returns "" when
+// there is none.
+func shareTarget(deps *AppDependencies, h *Hadith, query *string) string {
+	if query != nil {
+		if deps.ShareSearchURL == "" {
+			return ""
+		}
+		return strings.ReplaceAll(deps.ShareSearchURL, "{query}", url.QueryEscape(*query))
+	}
+	if deps.ShareHadithURL == "" || h == nil {
+		return ""
+	}
+	return strings.NewReplacer(
+		"{id}", strconv.FormatInt(h.ID, 10),
+		"{collection}", url.PathEscape(h.CollectionCode),
+		"{number}", url.PathEscape(h.Number),
+	).Replace(deps.ShareHadithURL)
+}
+
+// sharePageHadiths renders hadiths for the preview page, titled by their
+// collection's title where it can be found.
+func sharePageHadiths(ctx context.Context, deps *AppDependencies, hadiths []*Hadith) []sharePageHadith {
+	titles := map[string]string{}
+	out := make([]sharePageHadith, 0, len(hadiths))
+	for _, h := range hadiths {
+		title, ok := titles[h.CollectionCode]
+		if !ok {
+			title = h.CollectionCode
+			if col, err := deps.Store.Collection(ctx, h.CollectionCode); err == nil && col.Title != "" {
+				title = col.Title
+			}
+			titles[h.CollectionCode] = title
+		}
+		text, _ := hadithText(*h)
+		ref := title + " " + h.Number
+		if h.Grade != nil && *h.Grade != "" {
+			ref += " (" + *h.Grade + ")"
+		}
+		out = append(out, sharePageHadith{Text: text, Ref: ref})
+	}
+	return out
+}
+
+// registerShareRoutes serves POST /v1/share, which makes short links, and
+// the links themselves at /s/{slug}.
+func registerShareRoutes(e *echo.Echo, public *publicAPI, deps *AppDependencies) {
+	feature := deps.Features.require(featureShare)
+
+	e.POST("/v1/share", func(c echo.Context) error {
+		var req shareLinkRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		ctx := c.Request().Context()
+		var (
+			hadithID, query any
+			ids             = []int64{}
+		)
+		if req.HadithID != 0 {
+			if _, err := noteHadith(ctx, deps, req.HadithID); err != nil {
+				return err
+			}
+			hadithID = req.HadithID
+		} else {
+			query = req.Query
+			searchCtx, cancel := context.WithTimeout(ctx, deps.Timeouts.Search)
+			res, err := semanticSearch(searchCtx, deps, req.Query, shareSearchHits)
+			cancel()
+			if err != nil {
+				return err
+			}
+			for _, h := range res.Hits {
+				if h.Payload["origin_type"].GetStringValue() == "hadith" {
+					ids = append(ids, h.Payload["origin_id"].GetIntegerValue())
+				}
+			}
+		}
+
+		// A hadith has one link per tenant; slugs are 40 random bits, so a
+		// collision is retried rather than ruled out.
+		var (
+			l   shareLink
+			err error
+		)
+		for range 3 {
+			l, err = scanShareLink(deps.Postgres.QueryRow(ctx, `
+INSERT INTO short_links (slug, tenant_id, hadith_id, query, hadith_ids)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (tenant_id, hadith_id) WHERE hadith_id IS NOT NULL DO UPDATE SET hadith_id = EXCLUDED.hadith_id
+RETURNING `+shareLinkColumns, strings.ToLower(rand.Text()[:8]), tenant.From(ctx), hadithID, query, ids))
+			if !isUniqueViolation(err) {
+				break
+			}
+		}
+		if err != nil {
+			return apierr.Database("db insert short link failed")
+		}
+		l.URL = publicBaseURL(c, deps) + "/s/" + l.Slug
+		return c.JSON(http.StatusCreated, l)
+	}, feature)
+
+	// Link unfurlers, and everyone when no redirect is configured, get a
+	// page with Open Graph tags; ?preview=1 asks for it explicitly.
+	public.GET("/s/:slug", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		var (
+			tenantID int64
+			hadithID *int64
+			query    *string
+			ids      []int64
+			siteName string
+		)
+		err := deps.Postgres.QueryRow(ctx, `
+UPDATE short_links l SET hits = hits + 1
+FROM tenants t
+WHERE l.slug = $1 AND t.id = l.tenant_id
+RETURNING l.tenant_id, l.hadith_id, l.query, l.hadith_ids, t.name`, c.Param("slug")).Scan(&tenantID, &hadithID, &query, &ids, &siteName)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("link not found")
+		}
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		// A link shows the hadiths of the tenant it was made in.
+		ctx = tenant.With(ctx, tenantID)
+		if tenantID == tenant.Default {
+			siteName = deps.ShareSiteName
+		}
+
+		var hadiths []*Hadith
+		if hadithID != nil {
+			h, err := deps.Store.Hadith(ctx, *hadithID)
+			if errors.Is(err, store.ErrNotFound) {
+				return apierr.NotFound("hadith not found")
+			}
+			if err != nil {
+				return apierr.Database("db query failed")
+			}
+			hadiths = append(hadiths, &h)
+		} else {
+			byID, err := hadithsByID(ctx, deps, ids)
+			if err != nil {
+				return apierr.Database("db query failed")
+			}
+			for _, id := range ids {
+				if h := byID[id]; h != nil {
+					hadiths = append(hadiths, h)
+				}
+			}
+		}
+
+		var first *Hadith
+		if len(hadiths) > 0 {
+			first = hadiths[0]
+		}
+		target := shareTarget(deps, first, query)
+		if target != "" {
+			// Caches must not hand the preview page to people.
+			c.Response().Header().Add(echo.HeaderVary, "User-Agent")
+			if c.QueryParam("preview") == "" && !isUnfurler(c.Request().UserAgent()) {
+				return c.Redirect(http.StatusFound, target)
+			}
+		}
+
+		page := sharePageData{
+			URL:      publicBaseURL(c, deps) + "/s/" + c.Param("slug"),
+			SiteName: siteName,
+			Target:   target,
+			Hadiths:  sharePageHadiths(ctx, deps, hadiths),
+		}
+		if first != nil {
+			var text string
+			text, page.Lang = hadithText(*first)
+			page.Description = truncateRunes(text, sharePreviewChars)
+		}
+		if query != nil {
+			page.Title = "“" + truncateRunes(*query, 100) + "”"
+		} else if len(page.Hadiths) > 0 {
+			page.Title = page.Hadiths[0].Ref
+		}
+		var b strings.Builder
+		if err := sharePage.Execute(&b, page); err != nil {
+			slog.ErrorContext(ctx, "share page", "error", err)
+			return err
+		}
+		return c.HTML(http.StatusOK, b.String())
+	}, feature)
+}
@@ -0,0 +1,192 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/config"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/labstack/echo/v4"
+)
+
+const sitemapNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type sitemapIndex struct {
+	XMLName  xml.Name       `xml:"sitemapindex"`
+	NS       string         `xml:"xmlns,attr"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name       `xml:"urlset"`
+	NS      string         `xml:"xmlns,attr"`
+	URLs    []sitemapEntry `xml:"url"`
+}
+
+// sitemapSet is the rendered sitemaps of one tenant at one content version.
+type sitemapSet struct {
+	version string
+	index   []byte
+	pages   [][]byte
+}
+
+// Sitemaps renders the sitemaps of each tenant when first asked for them
+// and again once its content version, as for collection ETags, changes.
+type Sitemaps struct {
+	cfg config.Sitemap
+
+	mu   sync.Mutex
+	sets map[int64]*sitemapSet
+}
+
+// newSitemaps returns nil when sitemaps are not configured.
+func newSitemaps(cfg config.Sitemap) *Sitemaps {
+	if cfg.BaseURL == "" {
+		return nil
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return &Sitemaps{cfg: cfg, sets: map[int64]*sitemapSet{}}
+}
+
+func (s *Sitemaps) get(ctx context.Context, deps *AppDependencies) (*sitemapSet, error) {
+	version, err := deps.Store.CollectionsVersion(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := tenant.From(ctx)
+	if set := s.sets[id]; set != nil && set.version == version {
+		return set, nil
+	}
+	set, err := s.render(ctx, deps, version)
+	if err != nil {
+		return nil, err
+	}
+	s.sets[id] = set
+	return set, nil
+}
+
+func (s *Sitemaps) hadithURL(id int64, code, number string) string {
+	return s.cfg.BaseURL + strings.NewReplacer(
+		"{id}", strconv.FormatInt(id, 10),
+		"{collection}", url.PathEscape(code),
+		"{number}", url.PathEscape(number),
+	).Replace(s.cfg.HadithPath)
+}
+
+func (s *Sitemaps) render(ctx context.Context, deps *AppDependencies, version string) (*sitemapSet, error) {
+	rows, err := deps.Postgres.Query(ctx, `
+SELECT h.id, c.code, h.number, h.updated_at
+FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+WHERE `+postgres.Published("h")+` AND `+postgres.TenantWhere("h", 1)+`
+ORDER BY h.id`, tenant.From(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	set := &sitemapSet{version: version}
+	index := sitemapIndex{NS: sitemapNS, Sitemaps: []sitemapEntry{}}
+	page := sitemapURLSet{NS: sitemapNS}
+	var lastMod time.Time
+	flush := func() error {
+		body, err := xml.Marshal(page)
+		if err != nil {
+			return err
+		}
+		set.pages = append(set.pages, append([]byte(xml.Header), body...))
+		index.Sitemaps = append(index.Sitemaps, sitemapEntry{
+			Loc:     fmt.Sprintf("%s/sitemaps/hadiths-%d.xml", s.cfg.BaseURL, len(set.pages)),
+			LastMod: lastMod.UTC().Format(time.RFC3339),
+		})
+		page.URLs, lastMod = nil, time.Time{}
+		return nil
+	}
+	for rows.Next() {
+		var id int64
+		var code, number string
+		var updatedAt time.Time
+		if err := rows.Scan(&id, &code, &number, &updatedAt); err != nil {
+			return nil, err
+		}
+		page.URLs = append(page.URLs, sitemapEntry{Loc: s.hadithURL(id, code, number), LastMod: updatedAt.UTC().Format(time.RFC3339)})
+		if updatedAt.After(lastMod) {
+			lastMod = updatedAt
+		}
+		if len(page.URLs) == s.cfg.PageSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(page.URLs) > 0 {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+	body, err := xml.Marshal(index)
+	if err != nil {
+		return nil, err
+	}
+	set.index = append([]byte(xml.Header), body...)
+	return set, nil
+}
+
+func registerSitemapRoutes(public *publicAPI, deps *AppDependencies) {
+	serve := func(c echo.Context, page int) error {
+		if deps.Sitemaps == nil {
+			return apierr.NotFound("sitemap not found")
+		}
+		ctx, cancel := context.WithTimeout(c.Request().Context(), time.Minute)
+		defer cancel()
+		set, err := deps.Sitemaps.get(ctx, deps)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		body := set.index
+		if page > 0 {
+			if page > len(set.pages) {
+				return apierr.NotFound("sitemap not found")
+			}
+			body = set.pages[page-1]
+		}
+		if notModified(c, weakETag("sitemap", set.version, page)) {
+			return c.NoContent(http.StatusNotModified)
+		}
+		return c.Blob(http.StatusOK, "application/xml; charset=utf-8", body)
+	}
+
+	public.GET("/sitemap.xml", func(c echo.Context) error {
+		return serve(c, 0)
+	})
+
+	// Echo has no "param plus suffix" routes, so the page is parsed here.
+	public.GET("/sitemaps/:file", func(c echo.Context) error {
+		n, ok := strings.CutPrefix(c.Param("file"), "hadiths-")
+		if ok {
+			n, ok = strings.CutSuffix(n, ".xml")
+		}
+		page, err := strconv.Atoi(n)
+		if !ok || err != nil || page < 1 {
+			return apierr.NotFound("sitemap not found")
+		}
+		return serve(c, page)
+	})
+}
@@ -0,0 +1,42 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// acceptsEventStream reports whether the client asked for server-sent
+// events instead of a single JSON response.
+func acceptsEventStream(c echo.Context) bool {
+	return strings.Contains(c.Request().Header.Get(echo.HeaderAccept), "text/event-stream")
+}
+
+// eventStream writes server-sent events with JSON data. The response is
+// committed with the first event, so that errors before it are still
+// answered with their status.
+type eventStream struct {
+	c echo.Context
+}
+
+func (s *eventStream) send(event string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	res := s.c.Response()
+	if !res.Committed {
+		res.Header().Set(echo.HeaderContentType, "text/event-stream")
+		res.Header().Set(echo.HeaderCacheControl, "no-cache")
+		res.Header().Set("X-Accel-Buffering", "no")
+		res.WriteHeader(http.StatusOK)
+	}
+	if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event, body); err != nil {
+		return err
+	}
+	res.Flush()
+	return nil
+}
@@ -0,0 +1,122 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/buugaaga/test-cursor/backend/internal/vector"
+	"github.com/labstack/echo/v4"
+)
+
+// StatsCache keeps the last computed aggregates of each tenant for ttl; the
+// underlying queries scan the tenant's whole hadiths table.
+type StatsCache struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	stats map[int64]*corpusStats
+}
+
+func newStatsCache(ttl time.Duration) *StatsCache {
+	return &StatsCache{ttl: ttl, stats: map[int64]*corpusStats{}}
+}
+
+func (s *StatsCache) Get(ctx context.Context, deps *AppDependencies) (*corpusStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := tenant.From(ctx)
+	if st := s.stats[id]; st != nil && time.Since(st.ComputedAt) < s.ttl {
+		return st, nil
+	}
+	stats, err := computeStats(ctx, deps)
+	if err != nil {
+		return nil, err
+	}
+	s.stats[id] = stats
+	return stats, nil
+}
+
+func (s *StatsCache) Invalidate() {
+	s.mu.Lock()
+	clear(s.stats)
+	s.mu.Unlock()
+}
+
+func computeStats(ctx context.Context, deps *AppDependencies) (*corpusStats, error) {
+	stats := &corpusStats{
+		Collections: []collectionStats{},
+		Languages:   map[string]int64{"ar": 0, "ru": 0, "en": 0},
+		Grades:      map[string]int64{},
+		ComputedAt:  time.Now().UTC(),
+	}
+
+	rows, err := deps.ReadPostgres.Query(ctx, `
+SELECT c.code, c.title,
+       COUNT(h.id),
+       COUNT(h.id) FILTER (WHERE h.text_ar IS NOT NULL),
+       COUNT(h.id) FILTER (WHERE h.text_ru IS NOT NULL),
+       COUNT(h.id) FILTER (WHERE h.text_en IS NOT NULL)
+FROM hadith_collections c LEFT JOIN hadiths h ON h.collection_id = c.id AND `+postgres.Published("h")+`
+WHERE `+postgres.TenantWhere("c", 1)+`
+GROUP BY c.id
+ORDER BY c.code`, tenant.From(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cs collectionStats
+		if err := rows.Scan(&cs.Code, &cs.Title, &cs.Hadiths, &cs.TextAr, &cs.TextRu, &cs.TextEn); err != nil {
+			return nil, err
+		}
+		stats.Collections = append(stats.Collections, cs)
+		stats.Hadiths += cs.Hadiths
+		stats.Languages["ar"] += cs.TextAr
+		stats.Languages["ru"] += cs.TextRu
+		stats.Languages["en"] += cs.TextEn
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = deps.ReadPostgres.Query(ctx, `SELECT COALESCE(grade, 'unknown'), COUNT(*) FROM hadiths h WHERE `+postgres.Published("h")+` AND `+postgres.TenantWhere("h", 1)+` GROUP BY 1`, tenant.From(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var grade string
+		var n int64
+		if err := rows.Scan(&grade, &n); err != nil {
+			return nil, err
+		}
+		stats.Grades[grade] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stats.IndexedVectors, err = deps.Vectors.Count(ctx, vector.ForTenant(ctx, nil))
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func registerStatsRoutes(public *publicAPI, deps *AppDependencies) {
+	public.GET("/v1/stats", func(c echo.Context) error {
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+		defer cancel()
+
+		stats, err := deps.Stats.Get(ctx, deps)
+		if err != nil {
+			return apierr.Database("stats query failed")
+		}
+		return respond(c, http.StatusOK, stats)
+	})
+}
@@ -0,0 +1,450 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/config"
+	"github.com/buugaaga/test-cursor/backend/internal/i18n"
+	"github.com/buugaaga/test-cursor/backend/internal/jobs"
+	"github.com/buugaaga/test-cursor/backend/internal/metrics"
+	"github.com/buugaaga/test-cursor/backend/internal/notify"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	jobNotifyDaily = "notify.daily"
+	eventDaily     = "hadith.daily"
+)
+
+const subscriptionColumns = `id, channel, target, collection_code, language, to_char(send_at, 'HH24:MI'), timezone, active,
+  to_char(last_sent_on, 'YYYY-MM-DD'), last_error, created_at`
+
+type notifyJobArgs struct {
+	SubscriptionID int64 `json:"subscription_id"`
+	// Date is the subscriber's local date the hadith is picked for.
+	Date string `json:"date"`
+}
+
+func scanSubscription(row pgx.Row) (subscription, error) {
+	var s subscription
+	err := row.Scan(&s.ID, &s.Channel, &s.Target, &s.Collection, &s.Language, &s.SendAt, &s.Timezone, &s.Active,
+		&s.LastSentOn, &s.LastError, &s.CreatedAt)
+	return s, err
+}
+
+// Subscriptions delivers the hadith of the day to subscribers at their local
+// time through the configured channels, keyed by subscription channel.
+type Subscriptions struct {
+	deps     *AppDependencies
+	channels map[string]notify.Channel
+}
+
+// notifyChannels builds the channels cfg configures.
+func notifyChannels(cfg config.Notify) (map[string]notify.Channel, error) {
+	channels := map[string]notify.Channel{}
+	if cfg.SMTPAddr != "" {
+		channels["email"] = &notify.SMTP{Addr: cfg.SMTPAddr, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword, From: cfg.SMTPFrom}
+	}
+	if cfg.FCMCredentials != "" {
+		fcm, err := notify.NewFCM(cfg.FCMCredentials)
+		if err != nil {
+			return nil, err
+		}
+		channels["push"] = fcm
+	}
+	if cfg.Webhooks {
+		channels["webhook"] = &notify.Webhook{Client: &http.Client{Timeout: 10 * time.Second}}
+	}
+	return channels, nil
+}
+
+func newSubscriptions(deps *AppDependencies, channels map[string]notify.Channel) *Subscriptions {
+	return &Subscriptions{deps: deps, channels: channels}
+}
+
+// enabled lists the channels subscriptions can use.
+func (s *Subscriptions) enabled() []string {
+	out := []string{}
+	for name := range s.channels {
+		out = append(out, name)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// schedule queues a delivery for each active subscription whose send time
+// has come today, in its own time zone, and that has not had today's
+// hadith. It runs on the leader only.
+func (s *Subscriptions) schedule(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		if err := s.enqueueDue(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("notify: schedule failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Subscriptions) enqueueDue(ctx context.Context) error {
+	rows, err := s.deps.Postgres.Query(ctx, `
+SELECT s.id, u.tenant_id, to_char((now() AT TIME ZONE s.timezone)::date, 'YYYY-MM-DD')
+FROM subscriptions s JOIN users u ON u.id = s.user_id
+WHERE s.active
+  AND (now() AT TIME ZONE s.timezone)::time >= s.send_at
+  AND (s.last_sent_on IS NULL OR s.last_sent_on < (now() AT TIME ZONE s.timezone)::date)
+ORDER BY s.id
+LIMIT 1000`)
+	if err != nil {
+		return err
+	}
+	type due struct {
+		id, tenantID int64
+		date         string
+	}
+	list, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (due, error) {
+		var d due
+		err := row.Scan(&d.id, &d.tenantID, &d.date)
+		return d, err
+	})
+	if err != nil {
+		return err
+	}
+	for _, d := range list {
+		// A crash between the two steps sends the hadith twice rather than
+		// not at all.
+		if _, err := s.deps.Jobs.Enqueue(tenant.With(ctx, d.tenantID), jobNotifyDaily, notifyJobArgs{SubscriptionID: d.id, Date: d.date}); err != nil {
+			return err
+		}
+		if _, err := s.deps.Postgres.Exec(ctx, `UPDATE subscriptions SET last_sent_on = $2 WHERE id = $1`, d.id, d.date); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dailyMessage renders h in language, or in the text hadithText prefers.
+func dailyMessage(h Hadith, language string) (subject, text string) {
+	texts := map[string]*string{"ar": h.TextAr, "ru": h.TextRu, "en": h.TextEn}
+	if t := texts[language]; t != nil && *t != "" {
+		text = *t
+	} else {
+		text, _ = hadithText(h)
+	}
+	ref := hadithRef(h)
+	if h.Grade != nil && *h.Grade != "" {
+		ref += " (" + *h.Grade + ")"
+	}
+	return i18n.T(language, "daily.subject"), text + "\n\n— " + ref
+}
+
+func (s *Subscriptions) deliverJob(ctx context.Context, j *jobs.Job) (any, error) {
+	var args notifyJobArgs
+	if err := json.Unmarshal(j.Args, &args); err != nil {
+		return nil, jobs.Permanent(err)
+	}
+	day, err := time.Parse("2006-01-02", args.Date)
+	if err != nil {
+		return nil, jobs.Permanent(err)
+	}
+	var (
+		channel, target, language, token string
+		collection, secret               *string
+		active                           bool
+	)
+	err = s.deps.Postgres.QueryRow(ctx, `
+SELECT channel, target, collection_code, language, secret, unsubscribe_token, active FROM subscriptions WHERE id = $1`,
+		args.SubscriptionID).Scan(&channel, &target, &collection, &language, &secret, &token, &active)
+	if errors.Is(err, pgx.ErrNoRows) || err == nil && !active {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ch := s.channels[channel]
+	if ch == nil {
+		err := fmt.Errorf("channel %s is not enabled", channel)
+		s.record(ctx, args.SubscriptionID, err, false)
+		return nil, jobs.Permanent(err)
+	}
+
+	f := store.HadithFilter{}
+	if collection != nil {
+		f.Collection = *collection
+	}
+	h, err := cachedDailyHadith(ctx, s.deps, f, day, s.deps.DailyCalendar)
+	if errors.Is(err, store.ErrNotFound) {
+		err := errors.New("no hadiths to pick from")
+		s.record(ctx, args.SubscriptionID, err, false)
+		return nil, jobs.Permanent(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	id := fmt.Sprintf("subscription-%d-%s", args.SubscriptionID, args.Date)
+	subject, text := dailyMessage(h, language)
+	m := notify.Message{
+		ID:      id,
+		To:      target,
+		Subject: subject,
+		Text:    text,
+		Event:   eventDaily,
+		Data: webhookEvent{ID: id, Event: eventDaily, CreatedAt: time.Now().UTC(), Data: map[string]any{
+			"subscription_id": args.SubscriptionID,
+			"date":            args.Date,
+			"language":        language,
+			"hadith":          h,
+		}},
+		Meta: map[string]string{"hadith_id": strconv.FormatInt(h.ID, 10), "date": args.Date},
+	}
+	if secret != nil {
+		m.Secret = *secret
+	}
+	if s.deps.PublicBaseURL != "" {
+		m.UnsubscribeURL = s.deps.PublicBaseURL + "/v1/subscriptions/unsubscribe?token=" + url.QueryEscape(token)
+	}
+
+	err = ch.Send(ctx, m)
+	invalid := errors.Is(err, notify.ErrInvalidTarget)
+	switch {
+	case err == nil:
+		metrics.NotificationsSent.WithLabelValues(channel, "sent").Inc()
+	case invalid:
+		metrics.NotificationsSent.WithLabelValues(channel, "invalid_target").Inc()
+	default:
+		metrics.NotificationsSent.WithLabelValues(channel, "error").Inc()
+	}
+	s.record(ctx, args.SubscriptionID, err, invalid)
+	if invalid {
+		return nil, jobs.Permanent(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"hadith_id": h.ID}, nil
+}
+
+// record notes the outcome of a delivery, deactivating the subscription when
+// its target is gone.
+func (s *Subscriptions) record(ctx context.Context, id int64, deliveryErr error, deactivate bool) {
+	var msg *string
+	if deliveryErr != nil {
+		e := deliveryErr.Error()
+		msg = &e
+	}
+	_, err := s.deps.Postgres.Exec(context.WithoutCancel(ctx), `
+UPDATE subscriptions SET last_error = $2, active = active AND NOT $3 WHERE id = $1`, id, msg, deactivate)
+	if err != nil {
+		slog.WarnContext(ctx, "notify: record delivery failed", "subscription_id", id, "error", err)
+	}
+}
+
+// checkSubscriptionTimezone rejects zones Go and Postgres may not both know.
+func checkSubscriptionTimezone(tz string) error {
+	if tz == "Local" {
+		return apierr.InvalidArgument("invalid timezone")
+	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return apierr.InvalidArgument("invalid timezone")
+	}
+	return nil
+}
+
+func checkSubscriptionCollection(ctx context.Context, deps *AppDependencies, code string) error {
+	if code == "" {
+		return nil
+	}
+	ok, err := deps.Store.CollectionExists(ctx, code)
+	if err != nil {
+		return apierr.Database("db query failed")
+	}
+	if !ok {
+		return apierr.InvalidArgument("collection not found")
+	}
+	return nil
+}
+
+// unsubscribe deactivates the subscription of an unsubscribe link.
+func unsubscribe(c echo.Context, deps *AppDependencies) error {
+	token := c.QueryParam("token")
+	if token == "" {
+		return apierr.InvalidArgument("token is required")
+	}
+	tag, err := deps.Postgres.Exec(c.Request().Context(), `UPDATE subscriptions SET active = false WHERE unsubscribe_token = $1`, token)
+	if err != nil {
+		return apierr.Database("db update subscription failed")
+	}
+	if tag.RowsAffected() == 0 {
+		return apierr.NotFound("subscription not found")
+	}
+	return c.String(http.StatusOK, "You are unsubscribed from the hadith of the day.\n")
+}
+
+// registerSubscriptionRoutes serves the authenticated user's daily hadith
+// subscriptions under /v1/me/subscriptions and the unsubscribe links sent
+// with them.
+func registerSubscriptionRoutes(e *echo.Echo, me *echo.Group, deps *AppDependencies) {
+	e.GET("/v1/subscriptions/unsubscribe", func(c echo.Context) error { return unsubscribe(c, deps) })
+	// One-click unsubscribe from mail clients (RFC 8058).
+	e.POST("/v1/subscriptions/unsubscribe", func(c echo.Context) error { return unsubscribe(c, deps) })
+
+	g := me.Group("/subscriptions")
+
+	g.GET("", func(c echo.Context) error {
+		rows, err := deps.Postgres.Query(c.Request().Context(), `
+SELECT `+subscriptionColumns+` FROM subscriptions WHERE user_id = $1 ORDER BY id`, currentUser(c).UserID())
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		list, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (subscription, error) { return scanSubscription(row) })
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, subscriptionListResponse{Subscriptions: list, Channels: deps.Subscriptions.enabled()})
+	})
+
+	g.POST("", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		var req subscriptionCreateRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		if deps.Subscriptions.channels[req.Channel] == nil {
+			return apierr.InvalidArgument(fmt.Sprintf("channel %s is not enabled on this server", req.Channel))
+		}
+		user := currentUser(c).UserID()
+		req.Target = strings.TrimSpace(req.Target)
+		switch req.Channel {
+		case "email":
+			// Subscribing someone else's mailbox would make this a spam relay.
+			var email string
+			if err := deps.Postgres.QueryRow(ctx, `SELECT email FROM users WHERE id = $1`, user).Scan(&email); err != nil {
+				return apierr.Database("db query failed")
+			}
+			if req.Target == "" {
+				req.Target = email
+			}
+			if !strings.EqualFold(req.Target, email) {
+				return apierr.InvalidArgument("email subscriptions go to the account email")
+			}
+		case "webhook":
+			u, err := url.Parse(req.Target)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return apierr.InvalidArgument("target must be an absolute http(s) URL")
+			}
+		default:
+			if req.Target == "" {
+				return apierr.InvalidArgument("target is required")
+			}
+		}
+		if req.Timezone == "" {
+			req.Timezone = "UTC"
+		}
+		if err := checkSubscriptionTimezone(req.Timezone); err != nil {
+			return err
+		}
+		if err := checkSubscriptionCollection(ctx, deps, req.Collection); err != nil {
+			return err
+		}
+		if req.Language == "" {
+			req.Language = "en"
+		}
+		if req.SendAt == "" {
+			req.SendAt = "08:00"
+		}
+		var secret *string
+		if req.Channel == "webhook" {
+			s := newWebhookSecret()
+			secret = &s
+		}
+
+		sub, err := scanSubscription(deps.Postgres.QueryRow(ctx, `
+INSERT INTO subscriptions (user_id, channel, target, collection_code, language, send_at, timezone, secret, unsubscribe_token)
+VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6::time, $7, $8, $9)
+RETURNING `+subscriptionColumns, user, req.Channel, req.Target, req.Collection, req.Language, req.SendAt, req.Timezone,
+			secret, strings.ToLower(rand.Text())))
+		if isUniqueViolation(err) {
+			return apierr.InvalidArgument("already subscribed with this target")
+		}
+		if err != nil {
+			return apierr.Database("db insert subscription failed")
+		}
+		return c.JSON(http.StatusCreated, subscriptionCreateResponse{Subscription: sub, Secret: secret})
+	})
+
+	g.PATCH("/:id", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		var req subscriptionUpdate
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		if req.Timezone != nil {
+			if err := checkSubscriptionTimezone(*req.Timezone); err != nil {
+				return err
+			}
+		}
+		if req.Collection != nil {
+			if err := checkSubscriptionCollection(ctx, deps, *req.Collection); err != nil {
+				return err
+			}
+		}
+		// Turning a subscription back on clears the error that may have
+		// turned it off.
+		sub, err := scanSubscription(deps.Postgres.QueryRow(ctx, `
+UPDATE subscriptions SET
+  collection_code = CASE WHEN $3::text IS NULL THEN collection_code ELSE NULLIF($3, '') END,
+  language = COALESCE($4, language),
+  send_at = COALESCE($5::time, send_at),
+  timezone = COALESCE($6, timezone),
+  last_error = CASE WHEN $7::bool AND NOT active THEN NULL ELSE last_error END,
+  active = COALESCE($7, active)
+WHERE id = $1 AND user_id = $2
+RETURNING `+subscriptionColumns, id, currentUser(c).UserID(), req.Collection, req.Language, req.SendAt, req.Timezone, req.Active))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.NotFound("subscription not found")
+		}
+		if err != nil {
+			return apierr.Database("db update subscription failed")
+		}
+		return c.JSON(http.StatusOK, sub)
+	})
+
+	g.DELETE("/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		tag, err := deps.Postgres.Exec(c.Request().Context(), `DELETE FROM subscriptions WHERE id = $1 AND user_id = $2`, id, currentUser(c).UserID())
+		if err != nil {
+			return apierr.Database("db delete subscription failed")
+		}
+		if tag.RowsAffected() == 0 {
+			return apierr.NotFound("subscription not found")
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,268 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+)
+
+// TelegramBot answers /search and /daily in chats and inline queries
+// (@bot <query>) by long-polling the Bot API.
+type TelegramBot struct {
+	deps    *AppDependencies
+	baseURL string
+	client  *http.Client
+}
+
+const (
+	telegramPollTimeout  = 30
+	telegramMessageLimit = 4096
+	telegramSearchLimit  = 5
+	telegramInlineLimit  = 10
+)
+
+func newTelegramBot(deps *AppDependencies, apiURL, token string) *TelegramBot {
+	return &TelegramBot{
+		deps:    deps,
+		baseURL: strings.TrimRight(apiURL, "/") + "/bot" + token,
+		client:  &http.Client{Timeout: (telegramPollTimeout + 10) * time.Second},
+	}
+}
+
+type tgUpdate struct {
+	UpdateID    int64          `json:"update_id"`
+	Message     *tgMessage     `json:"message"`
+	InlineQuery *tgInlineQuery `json:"inline_query"`
+}
+
+type tgMessage struct {
+	MessageID int64  `json:"message_id"`
+	Chat      tgChat `json:"chat"`
+	Text      string `json:"text"`
+}
+
+type tgChat struct {
+	ID int64 `json:"id"`
+}
+
+type tgInlineQuery struct {
+	ID    string `json:"id"`
+	Query string `json:"query"`
+}
+
+type tgInlineArticle struct {
+	Type                string          `json:"type"`
+	ID                  string          `json:"id"`
+	Title               string          `json:"title"`
+	Description         string          `json:"description,omitempty"`
+	InputMessageContent tgInputTextBody `json:"input_message_content"`
+}
+
+type tgInputTextBody struct {
+	MessageText string `json:"message_text"`
+}
+
+type tgResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	Description string          `json:"description"`
+}
+
+func (b *TelegramBot) call(ctx context.Context, method string, params any, out any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.baseURL+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var tr tgResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return fmt.Errorf("%s: decode: %w", method, err)
+	}
+	if !tr.OK {
+		return fmt.Errorf("%s: %s", method, tr.Description)
+	}
+	if out != nil {
+		return json.Unmarshal(tr.Result, out)
+	}
+	return nil
+}
+
+func (b *TelegramBot) Run(ctx context.Context) {
+	var offset int64
+	for ctx.Err() == nil {
+		var updates []tgUpdate
+		err := b.call(ctx, "getUpdates", map[string]any{
+			"offset":          offset,
+			"timeout":         telegramPollTimeout,
+			"allowed_updates": []string{"message", "inline_query"},
+		}, &updates)
+		if err != nil {
+			slog.Error("telegram: get updates failed", "error", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			go b.handle(ctx, u)
+		}
+	}
+}
+
+func (b *TelegramBot) handle(ctx context.Context, u tgUpdate) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var err error
+	switch {
+	case u.InlineQuery != nil:
+		err = b.answerInline(ctx, u.InlineQuery)
+	case u.Message != nil:
+		err = b.answerMessage(ctx, u.Message)
+	}
+	if err != nil {
+		slog.Error("telegram: handle update failed", "update_id", u.UpdateID, "error", err)
+	}
+}
+
+// parseCommand splits "/search@MyBot text" into "search" and "text".
+func parseCommand(text string) (cmd, arg string) {
+	if !strings.HasPrefix(text, "/") {
+		return "", ""
+	}
+	cmd, arg, _ = strings.Cut(text[1:], " ")
+	cmd, _, _ = strings.Cut(cmd, "@")
+	return strings.ToLower(cmd), strings.TrimSpace(arg)
+}
+
+func (b *TelegramBot) answerMessage(ctx context.Context, m *tgMessage) error {
+	var reply string
+	switch cmd, arg := parseCommand(m.Text); cmd {
+	case "start", "help":
+		reply = "/search <text> — find hadiths by meaning\n/daily [collection] — hadith of the day\nInline: @<bot> <text> in any chat"
+	case "search":
+		if arg == "" {
+			reply = "Usage: /search <text>"
+			break
+		}
+		hadiths, err := b.search(ctx, arg, telegramSearchLimit)
+		if err != nil {
+			return err
+		}
+		if len(hadiths) == 0 {
+			reply = "Nothing found."
+			break
+		}
+		parts := make([]string, 0, len(hadiths))
+		for i, h := range hadiths {
+			parts = append(parts, fmt.Sprintf("%d. %s", i+1, formatHadith(h, 600)))
+		}
+		reply = strings.Join(parts, "\n\n")
+	case "daily":
+		h, err := b.daily(ctx, arg)
+		if errors.Is(err, store.ErrNotFound) {
+			reply = "No matching hadiths."
+			break
+		}
+		if err != nil {
+			return err
+		}
+		reply = formatHadith(h, telegramMessageLimit)
+	default:
+		return nil
+	}
+	return b.call(ctx, "sendMessage", map[string]any{
+		"chat_id":             m.Chat.ID,
+		"text":                truncateRunes(reply, telegramMessageLimit),
+		"reply_to_message_id": m.MessageID,
+	}, nil)
+}
+
+func (b *TelegramBot) answerInline(ctx context.Context, q *tgInlineQuery) error {
+	var hadiths []Hadith
+	if strings.TrimSpace(q.Query) == "" {
+		h, err := b.daily(ctx, "")
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		if err == nil {
+			hadiths = []Hadith{h}
+		}
+	} else {
+		var err error
+		if hadiths, err = b.search(ctx, q.Query, telegramInlineLimit); err != nil {
+			return err
+		}
+	}
+
+	results := make([]tgInlineArticle, 0, len(hadiths))
+	for _, h := range hadiths {
+		text, _ := hadithText(h)
+		results = append(results, tgInlineArticle{
+			Type:                "article",
+			ID:                  fmt.Sprint(h.ID),
+			Title:               hadithRef(h),
+			Description:         truncateRunes(text, 200),
+			InputMessageContent: tgInputTextBody{MessageText: formatHadith(h, telegramMessageLimit)},
+		})
+	}
+	return b.call(ctx, "answerInlineQuery", map[string]any{
+		"inline_query_id": q.ID,
+		"results":         results,
+		"cache_time":      60,
+	}, nil)
+}
+
+// search returns the full hadiths behind the top semantic hits.
+func (b *TelegramBot) search(ctx context.Context, query string, limit int) ([]Hadith, error) {
+	res, err := semanticSearch(ctx, b.deps, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		if h.Payload["origin_type"].GetStringValue() == "hadith" {
+			ids = append(ids, h.Payload["origin_id"].GetIntegerValue())
+		}
+	}
+	hadiths, _, err := b.deps.Store.Hadiths(ctx, ids)
+	return hadiths, err
+}
+
+func (b *TelegramBot) daily(ctx context.Context, collection string) (Hadith, error) {
+	day := time.Now().In(b.deps.DailyLocation)
+	return dailyHadith(ctx, b.deps, store.HadithFilter{Collection: collection}, day, b.deps.DailyCalendar)
+}
+
+func formatHadith(h Hadith, limit int) string {
+	text, _ := hadithText(h)
+	out := hadithRef(h)
+	if h.Grade != nil {
+		out += " (" + *h.Grade + ")"
+	}
+	return truncateRunes(out+"\n"+text, limit)
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
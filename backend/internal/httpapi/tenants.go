@@ -0,0 +1,206 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+// tenantHeader names the tenant of a request by its code.
+const tenantHeader = "X-Tenant"
+
+var errTenantNotFound = errors.New("tenant not found")
+
+// TenantDirectory resolves the tenant a request acts for. API keys and user
+// tokens belong to one tenant; the X-Tenant header names one for anonymous
+// requests and for the bootstrap key. Lookups are cached for a minute.
+type TenantDirectory struct {
+	db        *pgxpool.Pool
+	bootstrap string
+
+	mu     sync.Mutex
+	codes  map[string]cachedTenant
+	keyIDs map[string]cachedTenant
+}
+
+type cachedTenant struct {
+	id      int64
+	expires time.Time
+}
+
+func newTenantDirectory(db *pgxpool.Pool, bootstrap string) *TenantDirectory {
+	return &TenantDirectory{db: db, bootstrap: bootstrap, codes: map[string]cachedTenant{}, keyIDs: map[string]cachedTenant{}}
+}
+
+func (d *TenantDirectory) cached(m map[string]cachedTenant, key string, load func() (int64, error)) (int64, error) {
+	d.mu.Lock()
+	c, ok := m[key]
+	d.mu.Unlock()
+	if ok && time.Now().Before(c.expires) {
+		return c.id, nil
+	}
+	id, err := load()
+	if err != nil {
+		return 0, err
+	}
+	d.mu.Lock()
+	if len(m) > 10000 {
+		clear(m)
+	}
+	m[key] = cachedTenant{id: id, expires: time.Now().Add(time.Minute)}
+	d.mu.Unlock()
+	return id, nil
+}
+
+// byCode returns the id of the tenant with code, or errTenantNotFound.
+func (d *TenantDirectory) byCode(ctx context.Context, code string) (int64, error) {
+	id, err := d.cached(d.codes, code, func() (int64, error) {
+		var id int64
+		err := d.db.QueryRow(ctx, `SELECT id FROM tenants WHERE code = $1`, code).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return id, err
+	})
+	if err == nil && id == 0 {
+		return 0, errTenantNotFound
+	}
+	return id, err
+}
+
+// byAPIKey returns the tenant of an active key, or 0 for unknown keys,
+// which authentication rejects.
+func (d *TenantDirectory) byAPIKey(ctx context.Context, key string) (int64, error) {
+	return d.cached(d.keyIDs, hashAPIKey(key), func() (int64, error) {
+		var id int64
+		err := d.db.QueryRow(ctx, `SELECT tenant_id FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`, hashAPIKey(key)).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return id, err
+	})
+}
+
+// resolve picks the tenant from the credentials, then the X-Tenant code,
+// then tenant.Default. A header naming another tenant than the credentials'
+// is refused.
+func (d *TenantDirectory) resolve(ctx context.Context, header, apiKey string, claims *userClaims) (int64, error) {
+	var named int64
+	if header != "" {
+		id, err := d.byCode(ctx, header)
+		if errors.Is(err, errTenantNotFound) {
+			return 0, apierr.NotFound("unknown tenant " + header)
+		}
+		if err != nil {
+			return 0, apierr.Database("tenant lookup failed")
+		}
+		named = id
+	}
+
+	var owned int64
+	switch {
+	case apiKey != "" && d.bootstrap != "" && subtle.ConstantTimeCompare([]byte(apiKey), []byte(d.bootstrap)) == 1:
+		// The bootstrap key administers every tenant.
+	case apiKey != "":
+		id, err := d.byAPIKey(ctx, apiKey)
+		if err != nil {
+			return 0, apierr.Database("api key lookup failed")
+		}
+		owned = id
+	case claims != nil:
+		owned = claims.TenantID()
+	}
+
+	switch {
+	case owned != 0 && named != 0 && owned != named:
+		return 0, apierr.Forbidden("credentials belong to another tenant")
+	case owned != 0:
+		return owned, nil
+	case named != 0:
+		return named, nil
+	default:
+		return tenant.Default, nil
+	}
+}
+
+// middleware puts the tenant of the request in its context. It runs after
+// UserAuth.middleware, which has validated any user token.
+func (d *TenantDirectory) middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			id, err := d.resolve(r.Context(), strings.TrimSpace(r.Header.Get(tenantHeader)), apiKeyFromRequest(r), currentUser(c))
+			if err != nil {
+				return err
+			}
+			c.SetRequest(r.WithContext(tenant.With(r.Context(), id)))
+			return next(c)
+		}
+	}
+}
+
+// requireDefaultTenant limits a route to callers of the default tenant, the
+// one operating the deployment.
+func requireDefaultTenant(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if tenant.From(c.Request().Context()) != tenant.Default {
+			return apierr.Forbidden("requires the default tenant")
+		}
+		return next(c)
+	}
+}
+
+const tenantColumns = `id, code, name, created_at`
+
+func scanTenant(row pgx.Row) (tenantInfo, error) {
+	var t tenantInfo
+	err := row.Scan(&t.ID, &t.Code, &t.Name, &t.CreatedAt)
+	return t, err
+}
+
+func registerTenantRoutes(admin *echo.Group, deps *AppDependencies) {
+	g := admin.Group("/tenants", requireDefaultTenant)
+
+	g.GET("", func(c echo.Context) error {
+		rows, err := deps.Postgres.Query(c.Request().Context(), `SELECT `+tenantColumns+` FROM tenants ORDER BY id`)
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		tenants, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (tenantInfo, error) { return scanTenant(row) })
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, tenantListResponse{Tenants: tenants})
+	})
+
+	g.POST("", func(c echo.Context) error {
+		var req tenantCreateRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		auditNote(c, "tenant.create", map[string]any{"code": req.Code, "name": req.Name})
+		t, err := scanTenant(deps.Postgres.QueryRow(c.Request().Context(), `
+INSERT INTO tenants (code, name) VALUES ($1, $2)
+RETURNING `+tenantColumns, req.Code, req.Name))
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return apierr.New(http.StatusConflict, apierr.CodeAlreadyExists, "tenant code already taken")
+		}
+		if err != nil {
+			return apierr.Database("db insert tenant failed")
+		}
+		auditResource(c, "tenant:"+t.Code)
+		return c.JSON(http.StatusCreated, t)
+	})
+}
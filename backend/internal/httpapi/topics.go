@@ -0,0 +1,282 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// topicSet is the embedded topics of one tenant at one version.
+type topicSet struct {
+	version string
+	names   []string
+	vectors [][]float32
+}
+
+// TopicIndex suggests existing topics for a hadith or a phrase, by the
+// similarity of their embeddings. A topic is embedded from its name and
+// description when first needed and again when either or the model
+// changes; vectors are kept in the topics table and, per tenant, in memory
+// until hadiths, topics or the model change.
+type TopicIndex struct {
+	mu      sync.Mutex
+	tenants map[int64]*tenantTopics
+}
+
+// tenantTopics is one tenant's topicSet. Its lock is held while the set is
+// brought up to date, so one tenant's embedding holds up no other tenant,
+// and its concurrent requests embed once.
+type tenantTopics struct {
+	mu  sync.Mutex
+	set *topicSet
+}
+
+func newTopicIndex() *TopicIndex {
+	return &TopicIndex{tenants: map[int64]*tenantTopics{}}
+}
+
+// topicText is what a topic is embedded from.
+func topicText(name, description string) string {
+	if description == "" {
+		return name
+	}
+	return name + ": " + description
+}
+
+func (x *TopicIndex) get(ctx context.Context, deps *AppDependencies) (*topicSet, error) {
+	id := tenant.From(ctx)
+	var topics int64
+	var describedAt *time.Time
+	if err := deps.Postgres.QueryRow(ctx, `SELECT COUNT(*), MAX(updated_at) FROM topics WHERE tenant_id = $1`, id).Scan(&topics, &describedAt); err != nil {
+		return nil, apierr.Database("db query failed")
+	}
+	hadiths, err := deps.Store.CollectionsVersion(ctx, "")
+	if err != nil {
+		return nil, apierr.Database("db query failed")
+	}
+	version := fmt.Sprintf("%s.%s.%d.%v", deps.EmbeddingModel.Name, hadiths, topics, describedAt)
+
+	x.mu.Lock()
+	t := x.tenants[id]
+	if t == nil {
+		t = &tenantTopics{}
+		x.tenants[id] = t
+	}
+	x.mu.Unlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.set != nil && t.set.version == version {
+		return t.set, nil
+	}
+	set, err := x.sync(ctx, deps)
+	if err != nil {
+		return nil, err
+	}
+	set.version = version
+	t.set = set
+	return set, nil
+}
+
+// sync loads the tenant's topics, those of its hadiths and those described
+// by editors, and embeds the ones that are new or changed. Topics no hadith
+// has any more are only kept while described.
+func (x *TopicIndex) sync(ctx context.Context, deps *AppDependencies) (*topicSet, error) {
+	id, model := tenant.From(ctx), deps.EmbeddingModel.Name
+	rows, err := deps.Postgres.Query(ctx, `
+SELECT t.name, COALESCE(d.description, ''), COALESCE(d.embedded_text, ''), COALESCE(d.model, ''), d.embedding
+FROM (SELECT unnest(h.topics) AS name FROM hadiths h WHERE h.tenant_id = $1
+      UNION SELECT name FROM topics WHERE tenant_id = $1 AND description <> '') t
+LEFT JOIN topics d ON d.tenant_id = $1 AND d.name = t.name
+ORDER BY t.name`, id)
+	if err != nil {
+		return nil, apierr.Database("db query failed")
+	}
+	set := &topicSet{}
+	var (
+		stale                                      []int
+		texts                                      []string
+		name, description, embeddedText, embedWith string
+		vector                                     []float32
+	)
+	_, err = pgx.ForEachRow(rows, []any{&name, &description, &embeddedText, &embedWith, &vector}, func() error {
+		if text := topicText(name, description); text != embeddedText || embedWith != model || len(vector) == 0 {
+			stale = append(stale, len(set.names))
+			texts = append(texts, text)
+		}
+		set.names = append(set.names, name)
+		set.vectors = append(set.vectors, vector)
+		return nil
+	})
+	if err != nil {
+		return nil, apierr.Database("db query failed")
+	}
+	if len(stale) == 0 {
+		return set, nil
+	}
+
+	vectors, err := deps.Embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	batch := &pgx.Batch{}
+	for i, k := range stale {
+		set.vectors[k] = vectors[i]
+		batch.Queue(`
+INSERT INTO topics (tenant_id, name, embedded_text, embedding, model) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (tenant_id, name) DO UPDATE SET
+  embedded_text = EXCLUDED.embedded_text, embedding = EXCLUDED.embedding, model = EXCLUDED.model`,
+			id, set.names[k], texts[i], vectors[i], model)
+	}
+	if err := deps.Postgres.SendBatch(ctx, batch).Close(); err != nil {
+		return nil, apierr.Database("db update topics failed")
+	}
+	return set, nil
+}
+
+type topicSuggestion struct {
+	Topic string  `json:"topic"`
+	Score float32 `json:"score"`
+}
+
+type topicSuggestResponse struct {
+	Suggestions []topicSuggestion `json:"suggestions"`
+}
+
+// suggest ranks the topics by the cosine similarity of their vectors to
+// vec, leaving out those in skip.
+func (s *topicSet) suggest(vec []float32, limit int, skip []string) []topicSuggestion {
+	out := []topicSuggestion{}
+	for i, name := range s.names {
+		if !slices.Contains(skip, name) {
+			out = append(out, topicSuggestion{Topic: name, Score: cosine(vec, s.vectors[i])})
+		}
+	}
+	slices.SortStableFunc(out, func(a, b topicSuggestion) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	return out[:min(limit, len(out))]
+}
+
+func cosine(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return float32(dot / math.Sqrt(na*nb))
+}
+
+// registerTopicRoutes lets editors describe topics and get suggestions of
+// existing topics, so that hadiths are tagged consistently instead of with
+// near-duplicate new topics.
+func registerTopicRoutes(editor *echo.Group, deps *AppDependencies) {
+	editor.GET("/topics/suggest", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		if tenant.From(ctx) == tenant.All {
+			return apierr.InvalidArgument("editing needs a tenant")
+		}
+		limit, err := parseLimit(c.QueryParam("limit"), 5, 20)
+		if err != nil {
+			return apierr.InvalidArgument("invalid limit")
+		}
+		text := strings.TrimSpace(c.QueryParam("q"))
+		if utf8.RuneCountInString(text) > 1000 {
+			return apierr.InvalidField("q", "max", "1000")
+		}
+		var skip []string
+		if s := c.QueryParam("hadith_id"); s != "" {
+			if text != "" {
+				return apierr.InvalidArgument("give either q or hadith_id")
+			}
+			id, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return apierr.InvalidArgument("invalid hadith_id")
+			}
+			h, err := deps.Store.Hadith(ctx, id)
+			if errors.Is(err, store.ErrNotFound) {
+				return apierr.NotFound("hadith not found")
+			}
+			if err != nil {
+				return apierr.Database("db query failed")
+			}
+			text, _ = hadithText(h)
+			skip = h.Topics
+		}
+		if text == "" {
+			return apierr.InvalidArgument("q or hadith_id is required")
+		}
+
+		// The first suggestion of a tenant embeds all its topics.
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		set, err := deps.Topics.get(ctx, deps)
+		if err != nil {
+			return err
+		}
+		resp := topicSuggestResponse{Suggestions: []topicSuggestion{}}
+		if len(set.names) == 0 {
+			return c.JSON(http.StatusOK, resp)
+		}
+		vectors, err := deps.Embedder.Embed(ctx, []string{text})
+		if err != nil {
+			return err
+		}
+		resp.Suggestions = set.suggest(vectors[0], limit, skip)
+		return c.JSON(http.StatusOK, resp)
+	})
+
+	editor.PUT("/topics/:name", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		if tenant.From(ctx) == tenant.All {
+			return apierr.InvalidArgument("editing needs a tenant")
+		}
+		name, err := url.PathUnescape(c.Param("name"))
+		name = strings.TrimSpace(name)
+		if err != nil || name == "" || len(name) > 100 {
+			return apierr.InvalidArgument("invalid topic")
+		}
+		var req topicRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		auditNote(c, "topic.describe", map[string]any{"description": req.Description}, "topic:"+name)
+		var t topic
+		err = deps.Postgres.QueryRow(ctx, `
+INSERT INTO topics (tenant_id, name, description) VALUES ($1, $2, $3)
+ON CONFLICT (tenant_id, name) DO UPDATE SET description = EXCLUDED.description, updated_at = now()
+RETURNING name, description, updated_at`, tenant.From(ctx), name, strings.TrimSpace(req.Description)).Scan(&t.Name, &t.Description, &t.UpdatedAt)
+		if err != nil {
+			return apierr.Database("db update topic failed")
+		}
+		return c.JSON(http.StatusOK, t)
+	})
+}
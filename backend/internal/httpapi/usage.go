@@ -0,0 +1,254 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/search"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+// Usage of stored API keys is metered per UTC day: HTTP requests served,
+// semantic searches run and texts/characters sent to the embedder. Counts are
+// aggregated in memory and added to api_key_usage once a minute.
+
+type usageCounts struct {
+	Requests      int64
+	Searches      int64
+	EmbeddedTexts int64
+	EmbeddedChars int64
+}
+
+func (u *usageCounts) add(o usageCounts) {
+	u.Requests += o.Requests
+	u.Searches += o.Searches
+	u.EmbeddedTexts += o.EmbeddedTexts
+	u.EmbeddedChars += o.EmbeddedChars
+}
+
+type usageKey struct {
+	KeyID int64
+	Day   string
+}
+
+// usageMeter travels in the request context so that code far from the
+// handler (the embedder client, search) can report what it did.
+type usageMeter struct {
+	searches, texts, chars atomic.Int64
+}
+
+type usageMeterKey struct{}
+
+func meterSearch(ctx context.Context) {
+	if m, ok := ctx.Value(usageMeterKey{}).(*usageMeter); ok {
+		m.searches.Add(1)
+	}
+}
+
+// MeterEmbedding counts embedded texts against the API key of the request
+// carried by ctx; it is the embedder's Meter hook.
+func MeterEmbedding(ctx context.Context, texts []string) {
+	m, ok := ctx.Value(usageMeterKey{}).(*usageMeter)
+	if !ok {
+		return
+	}
+	var chars int
+	for _, t := range texts {
+		chars += utf8.RuneCountInString(t)
+	}
+	m.texts.Add(int64(len(texts)))
+	m.chars.Add(int64(chars))
+}
+
+type cachedKeyID struct {
+	id      int64
+	tier    string
+	expires time.Time
+}
+
+type UsageTracker struct {
+	db *pgxpool.Pool
+
+	mu      sync.Mutex
+	pending map[usageKey]*usageCounts
+	keyIDs  map[string]cachedKeyID
+}
+
+func newUsageTracker(db *pgxpool.Pool) *UsageTracker {
+	return &UsageTracker{db: db, pending: map[usageKey]*usageCounts{}, keyIDs: map[string]cachedKeyID{}}
+}
+
+// keyID maps a presented key to its api_keys id and tier, or 0 for
+// unknown, revoked and bootstrap keys. Lookups are cached for five minutes.
+func (t *UsageTracker) keyID(ctx context.Context, key string) (int64, string) {
+	hash := hashAPIKey(key)
+	t.mu.Lock()
+	cached, ok := t.keyIDs[hash]
+	t.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.id, cached.tier
+	}
+	var id int64
+	var tier string
+	err := t.db.QueryRow(ctx, `SELECT id, tier FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`, hash).Scan(&id, &tier)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		slog.ErrorContext(ctx, "usage: look up api key failed", "error", err)
+		return 0, ""
+	}
+	t.mu.Lock()
+	if len(t.keyIDs) > 10000 {
+		t.keyIDs = map[string]cachedKeyID{}
+	}
+	t.keyIDs[hash] = cachedKeyID{id: id, tier: tier, expires: time.Now().Add(5 * time.Minute)}
+	t.mu.Unlock()
+	return id, tier
+}
+
+func (t *UsageTracker) record(keyID int64, day string, c usageCounts) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	k := usageKey{KeyID: keyID, Day: day}
+	if t.pending[k] == nil {
+		t.pending[k] = &usageCounts{}
+	}
+	t.pending[k].add(c)
+}
+
+func (t *UsageTracker) middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := apiKeyFromRequest(c.Request())
+			if key == "" {
+				return next(c)
+			}
+			id, tier := t.keyID(c.Request().Context(), key)
+			if id == 0 {
+				return next(c)
+			}
+			// The key's tier sets its search limits.
+			ctx := search.WithTier(c.Request().Context(), tier)
+			m := &usageMeter{}
+			c.SetRequest(c.Request().WithContext(context.WithValue(ctx, usageMeterKey{}, m)))
+			err := next(c)
+			t.record(id, time.Now().UTC().Format(time.DateOnly), usageCounts{
+				Requests:      1,
+				Searches:      m.searches.Load(),
+				EmbeddedTexts: m.texts.Load(),
+				EmbeddedChars: m.chars.Load(),
+			})
+			return err
+		}
+	}
+}
+
+func (t *UsageTracker) flush(ctx context.Context) {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = map[usageKey]*usageCounts{}
+	t.mu.Unlock()
+
+	for k, c := range pending {
+		_, err := t.db.Exec(ctx, `
+INSERT INTO api_key_usage (api_key_id, day, requests, searches, embedded_texts, embedded_chars)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (api_key_id, day) DO UPDATE SET
+  requests = api_key_usage.requests + EXCLUDED.requests,
+  searches = api_key_usage.searches + EXCLUDED.searches,
+  embedded_texts = api_key_usage.embedded_texts + EXCLUDED.embedded_texts,
+  embedded_chars = api_key_usage.embedded_chars + EXCLUDED.embedded_chars`,
+			k.KeyID, k.Day, c.Requests, c.Searches, c.EmbeddedTexts, c.EmbeddedChars)
+		if err != nil {
+			slog.Error("usage: flush failed", "api_key_id", k.KeyID, "day", k.Day, "error", err)
+			t.record(k.KeyID, k.Day, *c)
+		}
+	}
+}
+
+func (t *UsageTracker) run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.flush(ctx)
+		}
+	}
+}
+
+func parseUsageDay(s string, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+func registerUsageRoutes(admin *echo.Group, deps *AppDependencies) {
+	admin.GET("/usage", func(c echo.Context) error {
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		from, err := parseUsageDay(c.QueryParam("from"), today.AddDate(0, 0, -29))
+		if err != nil {
+			return apierr.InvalidArgument("invalid from, want YYYY-MM-DD")
+		}
+		to, err := parseUsageDay(c.QueryParam("to"), today)
+		if err != nil {
+			return apierr.InvalidArgument("invalid to, want YYYY-MM-DD")
+		}
+		var keyID *int64
+		if s := c.QueryParam("key_id"); s != "" {
+			id, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return apierr.InvalidArgument("invalid key_id")
+			}
+			keyID = &id
+		}
+
+		rows, err := deps.ReadPostgres.Query(c.Request().Context(), `
+SELECT u.day, u.api_key_id, k.name, u.requests, u.searches, u.embedded_texts, u.embedded_chars
+FROM api_key_usage u JOIN api_keys k ON k.id = u.api_key_id
+WHERE u.day BETWEEN $1 AND $2 AND ($3::int IS NULL OR u.api_key_id = $3) AND k.tenant_id = $4
+ORDER BY u.day, u.api_key_id`, from, to, keyID, tenant.From(c.Request().Context()))
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		defer rows.Close()
+		resp := usageReport{From: from.Format(time.DateOnly), To: to.Format(time.DateOnly), Days: []usageDay{}, Totals: []usageTotal{}}
+		totals := map[int64]int{}
+		for rows.Next() {
+			var d usageDay
+			var day time.Time
+			if err := rows.Scan(&day, &d.APIKeyID, &d.APIKeyName, &d.Requests, &d.Searches, &d.EmbeddedTexts, &d.EmbeddedChars); err != nil {
+				return apierr.Database("db scan failed")
+			}
+			d.Date = day.Format(time.DateOnly)
+			resp.Days = append(resp.Days, d)
+			i, ok := totals[d.APIKeyID]
+			if !ok {
+				i = len(resp.Totals)
+				totals[d.APIKeyID] = i
+				resp.Totals = append(resp.Totals, usageTotal{APIKeyID: d.APIKeyID, APIKeyName: d.APIKeyName})
+			}
+			t := &resp.Totals[i]
+			t.Requests += d.Requests
+			t.Searches += d.Searches
+			t.EmbeddedTexts += d.EmbeddedTexts
+			t.EmbeddedChars += d.EmbeddedChars
+		}
+		if rows.Err() != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, resp)
+	})
+}
@@ -0,0 +1,258 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const jwtIssuer = "islam-app"
+
+// userClaims are carried in the HS256 access tokens issued by /v1/auth.
+type userClaims struct {
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+	Tenant int64  `json:"tid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func (c *userClaims) UserID() int64 {
+	id, _ := strconv.ParseInt(c.Subject, 10, 64)
+	return id
+}
+
+// TenantID is the user's tenant; tokens issued before tenants existed
+// belong to the default one.
+func (c *userClaims) TenantID() int64 {
+	if c.Tenant == 0 {
+		return tenant.Default
+	}
+	return c.Tenant
+}
+
+type UserAuth struct {
+	db     *pgxpool.Pool
+	secret []byte
+	// stateKey signs the OIDC login state, which must never pass for an
+	// access token.
+	stateKey []byte
+	ttl      time.Duration
+}
+
+func newUserAuth(db *pgxpool.Pool, secret []byte, ttl time.Duration) *UserAuth {
+	return &UserAuth{db: db, secret: secret, stateKey: derivedKey(secret, "oidc state"), ttl: ttl}
+}
+
+// derivedKey is the key for purpose derived from secret, so that tokens
+// signed for one use are refused by the checks of every other.
+func derivedKey(secret []byte, purpose string) []byte {
+	key, err := hkdf.Key(sha256.New, secret, nil, jwtIssuer+" "+purpose, 32)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func (a *UserAuth) issue(u user) (string, time.Time, error) {
+	now := time.Now()
+	exp := now.Add(a.ttl)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &userClaims{
+		Email:  u.Email,
+		Role:   u.Role,
+		Tenant: u.TenantID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    jwtIssuer,
+			Subject:   strconv.FormatInt(u.ID, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	})
+	signed, err := token.SignedString(a.secret)
+	return signed, exp, err
+}
+
+func (a *UserAuth) parse(token string) (*userClaims, error) {
+	claims := &userClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) { return a.secret, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(jwtIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// middleware authenticates "Authorization: Bearer <jwt>" when present and
+// stores the claims under "user". Requests without a token pass through;
+// bearer API keys are left to requireRole.
+func (a *UserAuth) middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			token = strings.TrimSpace(token)
+			if !ok || token == "" || strings.HasPrefix(token, apiKeyPrefix) {
+				return next(c)
+			}
+			claims, err := a.parse(token)
+			if err != nil {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="islam-app", error="invalid_token"`)
+				return apierr.Unauthorized("invalid or expired token")
+			}
+			c.Set("user", claims)
+			return next(c)
+		}
+	}
+}
+
+func currentUser(c echo.Context) *userClaims {
+	claims, _ := c.Get("user").(*userClaims)
+	return claims
+}
+
+func requireUser(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if currentUser(c) == nil {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="islam-app"`)
+			return apierr.Unauthorized("authentication required")
+		}
+		return next(c)
+	}
+}
+
+const userColumns = `id, email, display_name, role, search_history, created_at, tenant_id`
+
+func scanUser(row pgx.Row) (user, error) {
+	var u user
+	err := row.Scan(&u.ID, &u.Email, &u.DisplayName, &u.Role, &u.SearchHistory, &u.CreatedAt, &u.TenantID)
+	return u, err
+}
+
+// getUser returns user id of the tenant of ctx.
+func getUser(ctx context.Context, deps *AppDependencies, id int64) (user, error) {
+	return scanUser(deps.Postgres.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1 AND tenant_id = $2`, id, tenant.From(ctx)))
+}
+
+// registerAuthRoutes serves /v1/me and, unless passwordLogin is off (for
+// deployments that sign in only through OIDC), registration and login.
+func registerAuthRoutes(e *echo.Echo, me *echo.Group, deps *AppDependencies, passwordLogin bool) {
+	me.GET("", func(c echo.Context) error {
+		u, err := getUser(c.Request().Context(), deps, currentUser(c).UserID())
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.Unauthorized("user no longer exists")
+		}
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, u)
+	})
+
+	me.PATCH("", func(c echo.Context) error {
+		var req profileUpdate
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		var displayName *string
+		if req.DisplayName != nil {
+			s := strings.TrimSpace(*req.DisplayName)
+			displayName = &s
+		}
+		ctx := c.Request().Context()
+		u, err := scanUser(deps.Postgres.QueryRow(ctx, `
+UPDATE users SET
+  display_name = CASE WHEN $3::text IS NULL THEN display_name ELSE NULLIF($3, '') END,
+  search_history = COALESCE($4, search_history)
+WHERE id = $1 AND tenant_id = $2
+RETURNING `+userColumns, currentUser(c).UserID(), tenant.From(ctx), displayName, req.SearchHistory))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierr.Unauthorized("user no longer exists")
+		}
+		if err != nil {
+			return apierr.Database("db update user failed")
+		}
+		// Turning history off forgets what was kept.
+		if !u.SearchHistory {
+			if err := deps.SearchHistory.clear(ctx, u.ID); err != nil {
+				return apierr.Database("db delete search history failed")
+			}
+		}
+		return c.JSON(http.StatusOK, u)
+	})
+
+	if !passwordLogin {
+		return
+	}
+
+	e.POST("/v1/auth/register", func(c echo.Context) error {
+		var req registerRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		// bcrypt takes at most 72 bytes; the max=72 rule counts characters.
+		if len(req.Password) > 72 {
+			return apierr.InvalidField("password", "max", "72")
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			return apierr.InvalidField("password", "max", "72")
+		}
+		if err != nil {
+			return err
+		}
+		u, err := scanUser(deps.Postgres.QueryRow(c.Request().Context(), `
+INSERT INTO users (email, password_hash, display_name, tenant_id)
+VALUES (lower($1), $2, $3, $4)
+RETURNING `+userColumns, strings.TrimSpace(req.Email), string(hash), postgres.NullString(req.DisplayName), tenant.From(c.Request().Context())))
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return apierr.New(http.StatusConflict, apierr.CodeAlreadyExists, "email already registered")
+		}
+		if err != nil {
+			return apierr.Database("db insert user failed")
+		}
+		token, exp, err := deps.Users.issue(u)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusCreated, authResponse{User: u, Token: token, ExpiresAt: exp})
+	}, deps.Features.require(featureRegistration))
+
+	e.POST("/v1/auth/login", func(c echo.Context) error {
+		var req loginRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		var u user
+		var hash string
+		err := deps.Postgres.QueryRow(c.Request().Context(), `
+SELECT `+userColumns+`, COALESCE(password_hash, '') FROM users WHERE email = lower($1) AND tenant_id = $2`, strings.TrimSpace(req.Email), tenant.From(c.Request().Context())).
+			Scan(&u.ID, &u.Email, &u.DisplayName, &u.Role, &u.SearchHistory, &u.CreatedAt, &u.TenantID, &hash)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return apierr.Database("db query failed")
+		}
+		if err != nil || bcrypt.CompareHashAndPassword([]byte(hash), []byte(req.Password)) != nil {
+			return apierr.Unauthorized("invalid email or password")
+		}
+		token, exp, err := deps.Users.issue(u)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, authResponse{User: u, Token: token, ExpiresAt: exp})
+	})
+}
@@ -0,0 +1,19 @@
+package httpapi
+
+import (
+	"errors"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/labstack/echo/v4"
+)
+
+// bindAndValidate decodes the request body into v and checks its tags.
+func bindAndValidate(c echo.Context, v any) error {
+	if err := c.Bind(v); err != nil {
+		if errors.Is(err, echo.ErrStatusRequestEntityTooLarge) {
+			return apierr.PayloadTooLarge()
+		}
+		return apierr.InvalidRequest()
+	}
+	return apierr.Validate(v)
+}
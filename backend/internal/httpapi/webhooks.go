@@ -0,0 +1,241 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/jobs"
+	"github.com/buugaaga/test-cursor/backend/internal/notify"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	eventHadithCreated     = "hadith.created"
+	eventHadithUpdated     = "hadith.updated"
+	eventHadithDeleted     = "hadith.deleted"
+	eventCollectionUpdated = "collection.updated"
+	eventReindexStarted    = "reindex.started"
+	eventReindexCompleted  = "reindex.completed"
+	eventReindexFailed     = "reindex.failed"
+)
+
+const (
+	webhookMaxAttempts = 6
+	webhookBaseBackoff = 2 * time.Second
+)
+
+// Each delivery is POSTed as a webhookEvent with
+//
+//	X-Webhook-Event:     <event>
+//	X-Webhook-Delivery:  <event id>
+//	X-Webhook-Timestamp: <unix seconds>
+//	X-Webhook-Signature: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>" keyed by the hook secret>
+//
+// Non-2xx responses and transport errors are retried with exponential backoff.
+type webhookEvent struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// WebhookDispatcher queues a webhook.deliver job per subscribed hook, so
+// deliveries survive restarts and are retried by whichever replica is free.
+// Events also go to the EventBus, when there is one.
+type WebhookDispatcher struct {
+	db     *pgxpool.Pool
+	jobs   *jobs.Client
+	events *EventBus
+	client *http.Client
+}
+
+func newWebhookDispatcher(db *pgxpool.Pool, queue *jobs.Client, events *EventBus) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		db:     db,
+		jobs:   queue,
+		events: events,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Publish fans the event out to every active hook of the tenant of ctx
+// subscribed to it and to the event bus. It never blocks the caller;
+// delivery happens in the background.
+func (d *WebhookDispatcher) Publish(ctx context.Context, event string, data any) {
+	tenantID := tenant.From(ctx)
+	ev := webhookEvent{ID: uuid.NewString(), Event: event, CreatedAt: time.Now().UTC(), Data: data}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		slog.Error("webhooks: encode event failed", "event", event, "error", err)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(tenant.With(context.Background(), tenantID), 10*time.Second)
+		defer cancel()
+		if d.events != nil {
+			d.events.enqueue(ctx, tenantID, ev, body)
+		}
+		rows, err := d.db.Query(ctx, `SELECT id FROM webhooks w WHERE active AND $1 = ANY(events) AND `+postgres.TenantWhere("w", 2), event, tenantID)
+		if err != nil {
+			slog.Error("webhooks: load hooks failed", "event", event, "error", err)
+			return
+		}
+		hookIDs, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			slog.Error("webhooks: load hooks failed", "event", event, "error", err)
+			return
+		}
+		for _, id := range hookIDs {
+			args := webhookJobArgs{HookID: id, Event: event, EventID: ev.ID, Body: body}
+			if _, err := d.jobs.Enqueue(ctx, jobWebhookDeliver, args); err != nil {
+				slog.Error("webhooks: enqueue delivery failed", "event", event, "hook_id", id, "error", err)
+			}
+		}
+	}()
+}
+
+// deliverJob runs a webhook.deliver job. Hooks deleted or deactivated since
+// the event was published are skipped.
+func (d *WebhookDispatcher) deliverJob(ctx context.Context, j *jobs.Job) (any, error) {
+	var args webhookJobArgs
+	if err := json.Unmarshal(j.Args, &args); err != nil {
+		return nil, jobs.Permanent(err)
+	}
+	var url, secret string
+	var active bool
+	err := d.db.QueryRow(ctx, `SELECT url, secret, active FROM webhooks WHERE id = $1`, args.HookID).Scan(&url, &secret, &active)
+	if errors.Is(err, pgx.ErrNoRows) || err == nil && !active {
+		return map[string]any{"skipped": "webhook deleted or inactive"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = d.deliver(ctx, url, secret, args)
+	d.record(args.HookID, err)
+	return nil, err
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, url, secret string, w webhookJobArgs) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(w.Body))
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", w.Event)
+	req.Header.Set("X-Webhook-Delivery", w.EventID)
+	req.Header.Set("X-Webhook-Timestamp", ts)
+	req.Header.Set("X-Webhook-Signature", notify.Sign(secret, ts, w.Body))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (d *WebhookDispatcher) record(hookID int64, deliveryErr error) {
+	var lastError *string
+	if deliveryErr != nil {
+		s := deliveryErr.Error()
+		lastError = &s
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := d.db.Exec(ctx, `UPDATE webhooks SET last_delivery_at = now(), last_error = $2 WHERE id = $1`, hookID, lastError)
+	if err != nil {
+		slog.Error("webhooks: record delivery failed", "hook_id", hookID, "error", err)
+	}
+}
+
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+const webhookColumns = `id, url, events, active, created_at, last_delivery_at, last_error`
+
+func scanWebhook(row interface{ Scan(...any) error }) (webhook, error) {
+	var w webhook
+	err := row.Scan(&w.ID, &w.URL, &w.Events, &w.Active, &w.CreatedAt, &w.LastDeliveryAt, &w.LastError)
+	return w, err
+}
+
+func registerWebhookRoutes(admin *echo.Group, deps *AppDependencies) {
+	g := admin.Group("/webhooks")
+
+	g.GET("", func(c echo.Context) error {
+		rows, err := deps.Postgres.Query(c.Request().Context(), `SELECT `+webhookColumns+` FROM webhooks WHERE tenant_id = $1 ORDER BY id`, tenant.From(c.Request().Context()))
+		if err != nil {
+			return apierr.Database("db query failed")
+		}
+		defer rows.Close()
+		hooks := []webhook{}
+		for rows.Next() {
+			w, err := scanWebhook(rows)
+			if err != nil {
+				return apierr.Database("db scan failed")
+			}
+			hooks = append(hooks, w)
+		}
+		if rows.Err() != nil {
+			return apierr.Database("db query failed")
+		}
+		return c.JSON(http.StatusOK, webhookListResponse{Webhooks: hooks})
+	})
+
+	g.POST("", func(c echo.Context) error {
+		var req webhookCreateRequest
+		if err := bindAndValidate(c, &req); err != nil {
+			return err
+		}
+		auditNote(c, "webhook.create", map[string]any{"url": req.URL, "events": req.Events})
+		if req.Secret == "" {
+			req.Secret = newWebhookSecret()
+		}
+		w, err := scanWebhook(deps.Postgres.QueryRow(c.Request().Context(), `
+INSERT INTO webhooks (url, secret, events, tenant_id)
+VALUES ($1, $2, $3, $4)
+RETURNING `+webhookColumns, req.URL, req.Secret, req.Events, tenant.From(c.Request().Context())))
+		if err != nil {
+			return apierr.Database("db insert webhook failed")
+		}
+		auditResource(c, "webhook:"+strconv.FormatInt(w.ID, 10))
+		return c.JSON(http.StatusCreated, webhookCreateResponse{Webhook: w, Secret: req.Secret})
+	})
+
+	g.DELETE("/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return apierr.InvalidArgument("invalid id")
+		}
+		auditNote(c, "webhook.delete", nil, "webhook:"+c.Param("id"))
+		tag, err := deps.Postgres.Exec(c.Request().Context(), `DELETE FROM webhooks WHERE id = $1 AND tenant_id = $2`, id, tenant.From(c.Request().Context()))
+		if err != nil {
+			return apierr.Database("db delete webhook failed")
+		}
+		if tag.RowsAffected() == 0 {
+			return apierr.NotFound("webhook not found")
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+}
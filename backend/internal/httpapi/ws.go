@@ -0,0 +1,219 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/i18n"
+	"github.com/buugaaga/test-cursor/backend/internal/search"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+)
+
+// Search-as-you-type over a WebSocket. The client sends
+//
+//	{"type": "query", "id": "q7", "query": "prayer at ni", "limit": 10}
+//
+// for every keystroke. The server waits until the query has been stable for
+// the debounce interval, cancels any search still running for an older
+// query, and pushes
+//
+//	{"type": "searching", "id": "q7", "query": "..."}
+//	{"type": "results", "id": "q7", "query": "...", "results": [...]}
+//
+// or {"type": "error", "id": "q7", "code": "...", "message": "..."}.
+
+const (
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = 50 * time.Second
+	wsWriteWait  = 10 * time.Second
+	wsMaxMessage = 4096
+)
+
+type wsClientMessage struct {
+	Type  string `json:"type"`
+	ID    string `json:"id"`
+	Query string `json:"query"`
+	Limit int    `json:"limit"`
+}
+
+type wsServerMessage struct {
+	Type    string           `json:"type"`
+	ID      string           `json:"id,omitempty"`
+	Query   string           `json:"query,omitempty"`
+	Code    apierr.ErrorCode `json:"code,omitempty"`
+	Message string           `json:"message,omitempty"`
+}
+
+type wsResultsMessage struct {
+	Type     string         `json:"type"`
+	ID       string         `json:"id,omitempty"`
+	Query    string         `json:"query"`
+	Results  []searchResult `json:"results"`
+	Degraded bool           `json:"degraded,omitempty"`
+}
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+type wsSession struct {
+	deps     *AppDependencies
+	conn     *websocket.Conn
+	debounce time.Duration
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	current uint64
+}
+
+func (s *wsSession) send(msg any) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return s.conn.WriteJSON(msg)
+}
+
+// sendCurrent drops messages for queries that have been superseded.
+func (s *wsSession) sendCurrent(seq uint64, msg any) {
+	s.mu.Lock()
+	stale := seq != s.current
+	s.mu.Unlock()
+	if !stale {
+		s.send(msg)
+	}
+}
+
+func (s *wsSession) schedule(ctx context.Context, msg wsClientMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current++
+	seq := s.current
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(s.debounce, func() { s.run(ctx, seq, msg) })
+}
+
+func (s *wsSession) run(parent context.Context, seq uint64, msg wsClientMessage) {
+	s.mu.Lock()
+	if seq != s.current {
+		s.mu.Unlock()
+		return
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
+	ctx, cancel := context.WithTimeout(parent, 30*time.Second)
+	s.cancel = cancel
+	s.mu.Unlock()
+	defer cancel()
+
+	s.sendCurrent(seq, wsServerMessage{Type: "searching", ID: msg.ID, Query: msg.Query})
+	limit, err := s.deps.Search.Limit(ctx, msg.Limit)
+	var res search.Results
+	if err == nil {
+		res, err = semanticSearch(ctx, s.deps, msg.Query, limit)
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+	if err != nil {
+		var apiErr *apierr.APIError
+		if !errors.As(err, &apiErr) {
+			apiErr = apierr.New(http.StatusInternalServerError, apierr.CodeInternal, "internal error")
+		}
+		s.sendCurrent(seq, wsServerMessage{Type: "error", ID: msg.ID, Code: apiErr.Code, Message: apiErr.Localize(i18n.From(ctx)).Message})
+		return
+	}
+	s.sendCurrent(seq, wsResultsMessage{Type: "results", ID: msg.ID, Query: msg.Query, Results: toSearchResults(i18n.From(ctx), res.Hits), Degraded: res.Degraded})
+}
+
+func (s *wsSession) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current++
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+func (s *wsSession) pingLoop(done <-chan struct{}) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.writeMu.Lock()
+			err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			s.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func registerWebSocketRoute(e *echo.Echo, deps *AppDependencies, debounce time.Duration) {
+	e.GET("/v1/ws", func(c echo.Context) error {
+		conn, err := wsUpgrader.Upgrade(c.Response(), c.Request(), nil)
+		if err != nil {
+			return nil
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithCancel(c.Request().Context())
+		defer cancel()
+		s := &wsSession{deps: deps, conn: conn, debounce: debounce}
+		defer s.close()
+
+		done := make(chan struct{})
+		defer close(done)
+		go s.pingLoop(done)
+
+		conn.SetReadLimit(wsMaxMessage)
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+
+		for {
+			var msg wsClientMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				var closeErr *websocket.CloseError
+				if errors.As(err, &closeErr) || errors.Is(err, websocket.ErrReadLimit) {
+					return nil
+				}
+				if _, ok := err.(interface{ Timeout() bool }); ok {
+					return nil
+				}
+				if sendErr := s.send(wsServerMessage{Type: "error", Code: apierr.CodeInvalidRequest, Message: "malformed message"}); sendErr != nil {
+					return nil
+				}
+				continue
+			}
+			switch {
+			case msg.Type != "query":
+				s.send(wsServerMessage{Type: "error", ID: msg.ID, Code: apierr.CodeInvalidArgument, Message: "unknown message type"})
+			case msg.Query == "":
+				// An emptied search box cancels whatever is pending.
+				s.close()
+			default:
+				s.schedule(ctx, msg)
+			}
+		}
+	}, deps.Features.require(featureWebSocket))
+}
@@ -0,0 +1,30 @@
+package i18n
+
+import "unicode"
+
+// Detect guesses the language of text from the script most of its letters
+// are written in: Arabic, Cyrillic (Russian) or Latin (English). It returns
+// "" for text without letters.
+func Detect(text string) string {
+	var ar, ru, en int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Arabic, r):
+			ar++
+		case unicode.Is(unicode.Cyrillic, r):
+			ru++
+		case unicode.Is(unicode.Latin, r):
+			en++
+		}
+	}
+	switch {
+	case ar == 0 && ru == 0 && en == 0:
+		return ""
+	case ar >= ru && ar >= en:
+		return "ar"
+	case ru >= en:
+		return "ru"
+	default:
+		return "en"
+	}
+}
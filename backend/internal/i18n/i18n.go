@@ -0,0 +1,100 @@
+// Package i18n translates the messages and labels the API generates. A
+// request's language comes from its Accept-Language header; catalogs live in
+// locales/<lang>.json and map a message ID, or an English message, to its
+// translation.
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Default is the language of requests that ask for none we have, and of
+// messages missing from a catalog.
+const Default = "en"
+
+// Supported are the languages with a catalog.
+var Supported = []string{"en", "ru", "ar"}
+
+//go:embed locales/*.json
+var locales embed.FS
+
+var catalogs = func() map[string]map[string]string {
+	out := make(map[string]map[string]string, len(Supported))
+	for _, lang := range Supported {
+		data, err := locales.ReadFile("locales/" + lang + ".json")
+		if err != nil {
+			panic(err)
+		}
+		var c map[string]string
+		if err := json.Unmarshal(data, &c); err != nil {
+			panic(fmt.Sprintf("i18n: locales/%s.json: %v", lang, err))
+		}
+		out[lang] = c
+	}
+	return out
+}()
+
+// Lookup returns the translation of key in lang, if its catalog has one.
+func Lookup(lang, key string) (string, bool) {
+	s, ok := catalogs[lang][key]
+	return s, ok
+}
+
+// T translates key into lang, falling back to English and then to key
+// itself, and formats args into it.
+func T(lang, key string, args ...any) string {
+	s, ok := Lookup(lang, key)
+	if !ok {
+		if s, ok = Lookup(Default, key); !ok {
+			s = key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(s, args...)
+	}
+	return s
+}
+
+// Match picks the supported language an Accept-Language header prefers most,
+// or Default. "ru-RU" matches "ru"; "*" matches Default.
+func Match(header string) string {
+	best, bestQ := Default, 0.0
+	for part := range strings.SplitSeq(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if base == "*" {
+			base = Default
+		}
+		if _, ok := catalogs[base]; ok && q > bestQ {
+			best, bestQ = base, q
+		}
+	}
+	return best
+}
+
+type ctxKey struct{}
+
+// With returns a context whose messages are in lang.
+func With(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, lang)
+}
+
+// From returns the language of ctx, Default when none was set.
+func From(ctx context.Context) string {
+	if lang, ok := ctx.Value(ctxKey{}).(string); ok {
+		return lang
+	}
+	return Default
+}
@@ -0,0 +1,252 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/quran"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Ayah refs are manual when editors give them and detected when found in
+// the texts of a hadith; a hadith with manual refs is not detected again.
+const (
+	AyahManual   = "manual"
+	AyahDetected = "detected"
+)
+
+// ayahDetectBatch is how many hadiths DetectAyahs reads at a time.
+const ayahDetectBatch = 500
+
+// itemAyahs are the refs of an uploaded hadith and their source.
+func itemAyahs(h HadithUploadItem) ([]quran.Ref, string) {
+	if len(h.Ayahs) > 0 {
+		refs := slices.Clone(h.Ayahs)
+		slices.SortFunc(refs, quran.Compare)
+		return slices.Compact(refs), AyahManual
+	}
+	return quran.Detect(strings.Join([]string{h.Title, h.TextAr, h.TextRu, h.TextEn, h.TextTranslit}, "\n")), AyahDetected
+}
+
+// ayahPayload is the ayahs payload of a hadith's points: keywords such as
+// "2:255", which search filters can match.
+func ayahPayload(refs []quran.Ref) []any {
+	list := make([]any, len(refs))
+	for i, r := range refs {
+		list[i] = r.String()
+	}
+	return list
+}
+
+// ayahRefs are rows of hadith_ayah_refs, as columns.
+type ayahRefs struct {
+	hadiths []int64
+	surahs  []int32
+	ayahs   []int32
+	sources []string
+}
+
+func (a *ayahRefs) add(id int64, refs []quran.Ref, source string) {
+	for _, r := range refs {
+		a.hadiths = append(a.hadiths, id)
+		a.surahs = append(a.surahs, int32(r.Surah))
+		a.ayahs = append(a.ayahs, int32(r.Ayah))
+		a.sources = append(a.sources, source)
+	}
+}
+
+func (a *ayahRefs) insert(ctx context.Context, tx pgx.Tx) error {
+	if len(a.hadiths) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+INSERT INTO hadith_ayah_refs (hadith_id, surah, ayah, source)
+SELECT * FROM unnest($1::int[], $2::int[], $3::int[], $4::text[])
+ON CONFLICT DO NOTHING`, a.hadiths, a.surahs, a.ayahs, a.sources)
+	return err
+}
+
+// SetAyahs replaces the ayah refs of hadith id with refs, entered by an
+// editor, and updates the payload of its points like Patch. Without refs
+// the hadith has none until DetectAyahs finds some in its texts.
+func (s *Service) SetAyahs(ctx context.Context, id int64, refs []quran.Ref) (store.Hadith, error) {
+	tx, err := s.postgres.Begin(ctx)
+	if err != nil {
+		return store.Hadith{}, apierr.Database("db begin failed")
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `UPDATE hadiths h SET updated_at = now() WHERE h.id = $1 AND `+postgres.TenantWhere("h", 2)+` RETURNING h.id`, id, tenant.From(ctx)).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return store.Hadith{}, apierr.NotFound("hadith not found")
+	}
+	if err != nil {
+		return store.Hadith{}, apierr.Database("db update hadith failed")
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM hadith_ayah_refs WHERE hadith_id = $1`, id); err != nil {
+		return store.Hadith{}, apierr.Database("db update ayah refs failed")
+	}
+	var rows ayahRefs
+	rows.add(id, refs, AyahManual)
+	if err := rows.insert(ctx, tx); err != nil {
+		return store.Hadith{}, apierr.Database("db update ayah refs failed")
+	}
+	h, err := postgres.ScanHadith(tx.QueryRow(ctx, `
+SELECT `+postgres.HadithColumns+`
+FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+WHERE h.id = $1`, id))
+	if err != nil {
+		return store.Hadith{}, apierr.Database("db query failed")
+	}
+	intents, err := recordIntents(ctx, tx, []int64{id})
+	if err != nil {
+		return store.Hadith{}, apierr.Database("db record index intents failed")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return store.Hadith{}, apierr.Database("db commit failed")
+	}
+	s.notifier.HadithsUpdated(ctx, []int64{id})
+
+	if err := s.setAyahPayloads(ctx, []store.Hadith{h}, intents); err != nil {
+		return store.Hadith{}, apierr.VectorStore("qdrant set payload failed")
+	}
+	return h, nil
+}
+
+// setAyahPayloads updates the ayahs payload of the points of hadiths, then
+// settles their intents: when that fails they are left to RunOutbox, which
+// reindexes the hadiths.
+func (s *Service) setAyahPayloads(ctx context.Context, hadiths []store.Hadith, intents []int64) error {
+	for _, h := range hadiths {
+		set, unset := map[string]*qdrant.Value{}, []string{"ayahs"}
+		if len(h.Ayahs) > 0 {
+			set, unset = qdrant.NewValueMap(map[string]any{"ayahs": ayahPayload(h.Ayahs)}), nil
+		}
+		if err := s.setPayload(ctx, h.ID, set, unset); err != nil {
+			if rerr := s.releaseIntents(context.WithoutCancel(ctx), intents, err); rerr != nil {
+				slog.ErrorContext(ctx, "index outbox: release failed", "error", rerr)
+			}
+			return err
+		}
+	}
+	if err := s.completeIntents(context.WithoutCancel(ctx), intents); err != nil {
+		slog.ErrorContext(ctx, "index outbox: complete failed", "error", err)
+	}
+	return nil
+}
+
+// AyahDetectResult is the outcome of DetectAyahs.
+type AyahDetectResult struct {
+	Hadiths int `json:"hadiths"`
+	// Changed hadiths had their detected refs replaced.
+	Changed int `json:"changed"`
+	// Manual hadiths were skipped for having refs set by editors.
+	Manual int `json:"manual"`
+}
+
+// DetectAyahs finds the ayahs cited in the texts of every hadith of the
+// tenant of ctx, as uploads do, and replaces the detected refs of those
+// whose citations changed, for instance after the detection improved.
+func (s *Service) DetectAyahs(ctx context.Context) (AyahDetectResult, error) {
+	var res AyahDetectResult
+	var after int64
+	for {
+		rows, err := s.postgres.Query(ctx, `
+SELECT h.id, concat_ws(E'\n', h.title, h.text_ar, h.text_ru, h.text_en, h.text_translit),
+  ARRAY(SELECT r.surah || ':' || r.ayah FROM hadith_ayah_refs r WHERE r.hadith_id = h.id ORDER BY r.surah, r.ayah),
+  EXISTS (SELECT 1 FROM hadith_ayah_refs r WHERE r.hadith_id = h.id AND r.source = 'manual')
+FROM hadiths h
+WHERE h.id > $1 AND `+postgres.TenantWhere("h", 2)+`
+ORDER BY h.id
+LIMIT $3`, after, tenant.From(ctx), ayahDetectBatch)
+		if err != nil {
+			return res, err
+		}
+		var (
+			id      int64
+			text    string
+			current []string
+			manual  bool
+			changed []int64
+			refs    ayahRefs
+			n       int
+		)
+		_, err = pgx.ForEachRow(rows, []any{&id, &text, &current, &manual}, func() error {
+			n++
+			after = id
+			if manual {
+				res.Manual++
+				return nil
+			}
+			detected := quran.Detect(text)
+			keys := make([]string, len(detected))
+			for i, r := range detected {
+				keys[i] = r.String()
+			}
+			if !slices.Equal(keys, current) {
+				changed = append(changed, id)
+				refs.add(id, detected, AyahDetected)
+			}
+			return nil
+		})
+		if err != nil {
+			return res, err
+		}
+		res.Hadiths += n
+		if len(changed) > 0 {
+			if err := s.replaceDetected(ctx, changed, &refs); err != nil {
+				return res, err
+			}
+			res.Changed += len(changed)
+		}
+		if n < ayahDetectBatch {
+			return res, nil
+		}
+	}
+}
+
+// replaceDetected replaces the detected refs of the hadiths ids with refs.
+func (s *Service) replaceDetected(ctx context.Context, ids []int64, refs *ayahRefs) error {
+	tx, err := s.postgres.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `DELETE FROM hadith_ayah_refs WHERE hadith_id = ANY($1) AND source = 'detected'`, ids); err != nil {
+		return err
+	}
+	if err := refs.insert(ctx, tx); err != nil {
+		return err
+	}
+	rows, err := tx.Query(ctx, `
+UPDATE hadiths h SET updated_at = now()
+FROM hadith_collections c
+WHERE h.id = ANY($1) AND c.id = h.collection_id
+RETURNING `+postgres.HadithColumns, ids)
+	if err != nil {
+		return err
+	}
+	hadiths, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (store.Hadith, error) {
+		return postgres.ScanHadith(row)
+	})
+	if err != nil {
+		return err
+	}
+	intents, err := recordIntents(ctx, tx, ids)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	s.notifier.HadithsUpdated(ctx, ids)
+	return s.setAyahPayloads(ctx, hadiths, intents)
+}
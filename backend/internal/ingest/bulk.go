@@ -0,0 +1,214 @@
+package ingest
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/metrics"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+)
+
+// bulkBatchSize is how many hadiths a bulk change writes per transaction.
+const bulkBatchSize = 500
+
+// BulkFilter selects the hadiths of the tenant of ctx that a bulk change
+// applies to; empty fields match every hadith. NumberFrom and NumberTo
+// bound the leading integer of the number, inclusive, so 12a is in 1..12.
+type BulkFilter struct {
+	Collection string `json:"collection,omitempty" validate:"max=64"`
+	Grade      string `json:"grade,omitempty" validate:"max=64"`
+	Topic      string `json:"topic,omitempty" validate:"max=100"`
+	NumberFrom int64  `json:"number_from,omitempty" validate:"min=0"`
+	NumberTo   int64  `json:"number_to,omitempty" validate:"min=0"`
+}
+
+func (f BulkFilter) empty() bool {
+	return f == BulkFilter{}
+}
+
+// where renders f as a condition on hadiths h joined with
+// hadith_collections c, appending its parameters to args.
+func (f BulkFilter) where(ctx context.Context, args *[]any) string {
+	param := func(v any) string {
+		*args = append(*args, v)
+		return "$" + strconv.Itoa(len(*args))
+	}
+	conds := []string{"h.tenant_id = " + param(tenant.From(ctx))}
+	if f.Collection != "" {
+		conds = append(conds, "c.code = "+param(f.Collection))
+	}
+	if f.Grade != "" {
+		conds = append(conds, "h.grade = "+param(f.Grade))
+	}
+	if f.Topic != "" {
+		conds = append(conds, param(f.Topic)+" = ANY(h.topics)")
+	}
+	const leading = postgres.HadithNumberKey
+	if f.NumberFrom > 0 {
+		conds = append(conds, leading+" >= "+param(f.NumberFrom))
+	}
+	if f.NumberTo > 0 {
+		conds = append(conds, leading+" <= "+param(f.NumberTo))
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// checkBulk rejects filters that would change every hadith of the tenant
+// and unscoped contexts.
+func checkBulk(ctx context.Context, f BulkFilter) error {
+	if tenant.From(ctx) == tenant.All {
+		return apierr.InvalidArgument("bulk changes need a tenant")
+	}
+	if f.empty() {
+		return apierr.InvalidArgument("filter matches every hadith")
+	}
+	return nil
+}
+
+// CountMatching is how many hadiths f matches now.
+func (s *Service) CountMatching(ctx context.Context, f BulkFilter) (int64, error) {
+	if err := checkBulk(ctx, f); err != nil {
+		return 0, err
+	}
+	var args []any
+	where := f.where(ctx, &args)
+	var n int64
+	err := s.postgres.QueryRow(ctx, `
+SELECT count(*) FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id WHERE `+where, args...).Scan(&n)
+	if err != nil {
+		return 0, apierr.Database("db query failed")
+	}
+	return n, nil
+}
+
+// BulkResult is how many hadiths a bulk change deleted or updated.
+type BulkResult struct {
+	Hadiths int `json:"hadiths"`
+}
+
+// BulkDelete deletes the hadiths f matches and their points, in batches
+// that each commit on their own; after a failure, running it again
+// continues where it stopped.
+func (s *Service) BulkDelete(ctx context.Context, f BulkFilter) (BulkResult, error) {
+	var res BulkResult
+	if err := checkBulk(ctx, f); err != nil {
+		return res, err
+	}
+	defer s.notifier.ContentChanged(context.WithoutCancel(ctx))
+	for {
+		args := []any{bulkBatchSize}
+		where := f.where(ctx, &args)
+		ids, intents, err := s.bulkWrite(ctx, `
+DELETE FROM hadiths WHERE id IN (
+  SELECT h.id FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+  WHERE `+where+`
+  ORDER BY h.id
+  LIMIT $1
+)
+RETURNING id`, args)
+		if err != nil || len(ids) == 0 {
+			return res, err
+		}
+		s.notifier.HadithsDeleted(ctx, ids)
+		// The outbox intents delete the points of hadiths that are gone.
+		if _, err := s.applyIntents(ctx, ids); err != nil {
+			if rerr := s.releaseIntents(context.WithoutCancel(ctx), intents, err); rerr != nil {
+				slog.ErrorContext(ctx, "index outbox: release failed", "error", rerr)
+			}
+			return res, apierr.VectorStore("qdrant delete failed")
+		}
+		if err := s.completeIntents(context.WithoutCancel(ctx), intents); err != nil {
+			slog.ErrorContext(ctx, "index outbox: complete failed", "error", err)
+		}
+		res.Hadiths += len(ids)
+		slog.InfoContext(ctx, "bulk delete: batch done", "hadiths", res.Hadiths)
+	}
+}
+
+// BulkUpdate applies p to the hadiths f matches, patching the payload of
+// their points without embedding them again. Hadiths are visited once, in
+// id order, so a patch that takes them out of f does not stop it.
+func (s *Service) BulkUpdate(ctx context.Context, f BulkFilter, p HadithPatch) (BulkResult, error) {
+	var res BulkResult
+	if err := checkBulk(ctx, f); err != nil {
+		return res, err
+	}
+	defer s.notifier.ContentChanged(context.WithoutCancel(ctx))
+	set, unset := p.payload()
+	var after int64
+	for {
+		args := []any{bulkBatchSize, after,
+			p.Grade != nil, nullString(p.Grade), p.Title != nil, nullString(p.Title), p.Topics != nil, p.topics()}
+		where := f.where(ctx, &args)
+		ids, intents, err := s.bulkWrite(ctx, `
+UPDATE hadiths SET
+  grade = CASE WHEN $3 THEN $4 ELSE grade END,
+  title = CASE WHEN $5 THEN $6 ELSE title END,
+  topics = CASE WHEN $7 THEN $8::text[] ELSE topics END,
+  updated_at = now()
+WHERE id IN (
+  SELECT h.id FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+  WHERE h.id > $2 AND `+where+`
+  ORDER BY h.id
+  LIMIT $1
+)
+RETURNING id`, args)
+		if err != nil || len(ids) == 0 {
+			return res, err
+		}
+		s.notifier.HadithsUpdated(ctx, ids)
+		if err := s.vectors.SetPayload(ctx, hadithPoints(ids...), set, unset); err != nil {
+			if rerr := s.releaseIntents(context.WithoutCancel(ctx), intents, err); rerr != nil {
+				slog.ErrorContext(ctx, "index outbox: release failed", "error", rerr)
+			}
+			return res, apierr.VectorStore("qdrant set payload failed")
+		}
+		if s.shadow != nil {
+			if err := s.shadow.Vectors.SetPayload(ctx, hadithPoints(ids...), set, unset); err != nil {
+				metrics.ShadowIndexErrors.Inc()
+				slog.WarnContext(ctx, "shadow set payload failed", "hadiths", len(ids), "error", err)
+			}
+		}
+		if err := s.completeIntents(context.WithoutCancel(ctx), intents); err != nil {
+			slog.ErrorContext(ctx, "index outbox: complete failed", "error", err)
+		}
+		res.Hadiths += len(ids)
+		after = slices.Max(ids)
+		slog.InfoContext(ctx, "bulk update: batch done", "hadiths", res.Hadiths)
+	}
+}
+
+// bulkWrite runs one batch's statement, which returns the ids it changed,
+// and records leased outbox intents for them in the same transaction, so
+// that their points are fixed by RunOutbox if the caller fails to.
+func (s *Service) bulkWrite(ctx context.Context, sql string, args []any) (ids, intents []int64, err error) {
+	tx, err := s.postgres.Begin(ctx)
+	if err != nil {
+		return nil, nil, apierr.Database("db begin failed")
+	}
+	defer tx.Rollback(ctx)
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, nil, apierr.Database("db bulk write failed")
+	}
+	ids, err = pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, nil, apierr.Database("db bulk write failed")
+	}
+	if len(ids) == 0 {
+		return nil, nil, nil
+	}
+	if intents, err = recordIntents(ctx, tx, ids); err != nil {
+		return nil, nil, apierr.Database("db record index intents failed")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, apierr.Database("db commit failed")
+	}
+	return ids, intents, nil
+}
@@ -0,0 +1,678 @@
+// Package ingest stores uploaded hadiths in Postgres and indexes them in
+// Qdrant, retrying failed indexing through an outbox table.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/embed"
+	"github.com/buugaaga/test-cursor/backend/internal/i18n"
+	"github.com/buugaaga/test-cursor/backend/internal/metrics"
+	"github.com/buugaaga/test-cursor/backend/internal/quran"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/buugaaga/test-cursor/backend/internal/vector"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/qdrant/go-client/qdrant"
+	"golang.org/x/sync/errgroup"
+)
+
+// Notifier is told about stored content: the HTTP layer turns it into
+// webhooks and cache invalidation. CollectionUpdated and the Hadiths methods
+// are called once the rows are committed, whether or not indexing succeeds.
+type Notifier interface {
+	CollectionUpdated(ctx context.Context, code, title string)
+	HadithsCreated(ctx context.Context, collectionCode string, hadiths []Created)
+	HadithsUpdated(ctx context.Context, ids []int64)
+	HadithsDeleted(ctx context.Context, ids []int64)
+	ContentChanged(ctx context.Context)
+}
+
+// Created identifies a stored hadith.
+type Created struct {
+	ID     int64
+	Number string
+}
+
+type Config struct {
+	Postgres *pgxpool.Pool
+	// Store is read when reindexing and checking the index.
+	Store    store.Store
+	Vectors  vector.Index
+	Embedder embed.Embedder
+	// Model is the embedder's model. It is recorded on every point and must
+	// match the one recorded for the index.
+	Model embed.Model
+	// Shadow, when set, is written alongside Vectors.
+	Shadow   *Shadow
+	Notifier Notifier
+	// Concurrency is how many upload batches are embedded and upserted at
+	// once.
+	Concurrency int
+	// Limiter bounds concurrent uploads; nil means unlimited.
+	Limiter *Limiter
+	// TokenPrice is the embedder's price in US dollars per million tokens,
+	// for the cost in usage reports.
+	TokenPrice float64
+}
+
+type Service struct {
+	postgres    *pgxpool.Pool
+	store       store.Store
+	vectors     vector.Index
+	embedder    embed.Embedder
+	model       embed.Model
+	shadow      *Shadow
+	notifier    Notifier
+	concurrency int
+	limiter     *Limiter
+	tokenPrice  float64
+}
+
+func New(cfg Config) *Service {
+	return &Service{
+		postgres:    cfg.Postgres,
+		store:       cfg.Store,
+		vectors:     cfg.Vectors,
+		embedder:    cfg.Embedder,
+		model:       cfg.Model,
+		shadow:      cfg.Shadow,
+		notifier:    cfg.Notifier,
+		concurrency: cfg.Concurrency,
+		limiter:     cfg.Limiter,
+		tokenPrice:  cfg.TokenPrice,
+	}
+}
+
+type HadithUploadRequest struct {
+	Collection HadithUploadCollection `json:"collection"`
+	// Hadiths are validated one by one: invalid ones are skipped and
+	// reported in UploadResponse.Errors.
+	Hadiths []HadithUploadItem `json:"hadiths" validate:"required,min=1,max=2000"`
+	// PublishAt, when in the future, keeps the hadiths hidden from reads
+	// and search until RunScheduler publishes and indexes them.
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	// MessageID, when set, identifies the message the upload was read
+	// from: a message already ingested is refused with ErrAlreadyIngested,
+	// so that redelivered messages are stored once.
+	MessageID string `json:"-"`
+}
+
+// ErrAlreadyIngested is returned for an upload whose MessageID was
+// ingested before.
+var ErrAlreadyIngested = errors.New("message already ingested")
+
+type HadithUploadCollection struct {
+	Code  string `json:"code" validate:"required,max=64"`
+	Title string `json:"title" validate:"required,max=200"`
+}
+
+type HadithUploadItem struct {
+	Number string `json:"number" validate:"required,max=32"`
+	Title  string `json:"title" validate:"max=300"`
+	TextAr string `json:"text_ar"`
+	TextRu string `json:"text_ru"`
+	TextEn string `json:"text_en"`
+	// TextTranslit is the Arabic text in Latin script.
+	TextTranslit string   `json:"text_translit"`
+	Grade        string   `json:"grade" validate:"max=64"`
+	Topics       []string `json:"topics" validate:"max=50,dive,required,max=100"`
+	// Book, Chapter and Sequence place the hadith in the printed edition of
+	// the collection; a chapter needs a book.
+	Book         *int   `json:"book" validate:"omitnil,gte=1"`
+	BookTitle    string `json:"book_title" validate:"max=300"`
+	Chapter      *int   `json:"chapter" validate:"omitnil,gte=1"`
+	ChapterTitle string `json:"chapter_title" validate:"max=300"`
+	Sequence     *int   `json:"sequence" validate:"omitnil,gte=1"`
+	// Ayahs are the ayahs of the Quran the hadith cites. When none are
+	// given they are detected in its texts.
+	Ayahs []quran.Ref `json:"ayahs" validate:"max=50"`
+}
+
+type UploadResponse struct {
+	Inserted int `json:"inserted"`
+	Embedded int `json:"embedded"`
+	// Scheduled is how many hadiths wait for their publish_at.
+	Scheduled int `json:"scheduled,omitempty"`
+	// LangMismatches are the texts whose detected language is not the one
+	// of their field. They are stored as given.
+	LangMismatches []LangMismatch `json:"lang_mismatches,omitempty"`
+	// Usage is the text embedded or, for a dry run, that would be.
+	Usage *embed.Usage `json:"usage,omitempty"`
+	// DryRun is set when nothing was stored.
+	DryRun bool `json:"dry_run,omitempty"`
+	// Skipped is how many hadiths were invalid and not stored.
+	Skipped int `json:"skipped"`
+	// Errors are the hadiths that were skipped or failed to index, in
+	// request order.
+	Errors []RowError `json:"errors,omitempty"`
+}
+
+// RowError is a hadith of an upload that failed.
+type RowError struct {
+	// Index is the hadith's position in the request, from 0.
+	Index  int    `json:"index"`
+	Number string `json:"number,omitempty"`
+	// Stored hadiths failed to index; they are indexed later from the
+	// outbox.
+	Stored bool             `json:"stored"`
+	Reason *apierr.APIError `json:"reason"`
+}
+
+// Localize translates the reasons of r.Errors into lang.
+func (r *UploadResponse) Localize(lang string) {
+	for i, e := range r.Errors {
+		if e.Reason != nil {
+			r.Errors[i].Reason = e.Reason.Localize(lang)
+		}
+	}
+}
+
+// checkItems splits the hadiths of req into those that can be stored, by
+// their index, and the errors of those that cannot.
+func checkItems(req *HadithUploadRequest) ([]int, []RowError) {
+	valid := make([]int, 0, len(req.Hadiths))
+	var rowErrors []RowError
+	for i, h := range req.Hadiths {
+		if err := checkItem(h); err != nil {
+			rowErrors = append(rowErrors, RowError{Index: i, Number: h.Number, Reason: err})
+			continue
+		}
+		valid = append(valid, i)
+	}
+	return valid, rowErrors
+}
+
+// checkItem returns why h cannot be stored, or nil.
+func checkItem(h HadithUploadItem) *apierr.APIError {
+	if err := apierr.Validate(h); err != nil {
+		var apiErr *apierr.APIError
+		if !errors.As(err, &apiErr) {
+			apiErr = apierr.InvalidArgument(err.Error())
+		}
+		return apiErr
+	}
+	if h.Book == nil && (h.Chapter != nil || h.BookTitle != "") {
+		return apierr.InvalidArgument("chapter or book_title without a book")
+	}
+	if h.Chapter == nil && h.ChapterTitle != "" {
+		return apierr.InvalidArgument("chapter_title without a chapter")
+	}
+	for _, r := range h.Ayahs {
+		if !r.Valid() {
+			return apierr.InvalidArgument("invalid ayah").WithDetails(map[string]any{"ayah": r.String()})
+		}
+	}
+	// Postgres refuses text with NUL, which JSON can carry as \u0000.
+	for _, s := range append([]string{h.Number, h.Title, h.TextAr, h.TextRu, h.TextEn, h.TextTranslit, h.Grade, h.BookTitle, h.ChapterTitle}, h.Topics...) {
+		if strings.ContainsRune(s, 0) {
+			return apierr.InvalidArgument("text contains a NUL character")
+		}
+	}
+	return nil
+}
+
+// noValidHadiths is the error of an upload whose hadiths are all invalid.
+func noValidHadiths(rowErrors []RowError) error {
+	return apierr.InvalidArgument("no hadith of the upload is valid").WithDetails(map[string]any{"errors": rowErrors})
+}
+
+// LangMismatch is a text given in one field but written in another language,
+// e.g. English text in text_ru.
+type LangMismatch struct {
+	Number   string `json:"number"`
+	Field    string `json:"field"`
+	Detected string `json:"detected"`
+}
+
+// detectLangs detects the language of each text of h, keyed by field, and
+// lists the texts in another language than their field's.
+func detectLangs(h HadithUploadItem) (map[string]string, []LangMismatch) {
+	detected := map[string]string{}
+	var mismatches []LangMismatch
+	for _, t := range []struct{ field, lang, text string }{
+		{"text_ar", "ar", h.TextAr}, {"text_ru", "ru", h.TextRu}, {"text_en", "en", h.TextEn},
+	} {
+		lang := i18n.Detect(t.text)
+		if lang == "" {
+			continue
+		}
+		detected[t.field] = lang
+		if lang != t.lang {
+			mismatches = append(mismatches, LangMismatch{Number: h.Number, Field: t.field, Detected: lang})
+		}
+	}
+	return detected, mismatches
+}
+
+// Estimate validates an upload and reports what Ingest would embed,
+// without storing anything. Hadiths scheduled for later are embedded when
+// published, and are counted too.
+func (s *Service) Estimate(ctx context.Context, req *HadithUploadRequest) (UploadResponse, error) {
+	if err := apierr.Validate(req); err != nil {
+		return UploadResponse{}, err
+	}
+	valid, rowErrors := checkItems(req)
+	if len(valid) == 0 {
+		return UploadResponse{}, noValidHadiths(rowErrors)
+	}
+	var usage embed.Usage
+	var mismatches []LangMismatch
+	for _, i := range valid {
+		h := req.Hadiths[i]
+		_, m := detectLangs(h)
+		mismatches = append(mismatches, m...)
+		if d, ok := newDoc(0, tenant.From(ctx), req.Collection.Code, h.Number, h.TextAr, h.TextRu, h.TextEn, h.Grade); ok {
+			usage.Add(s.tokenPrice, d.Text)
+		}
+	}
+	return UploadResponse{LangMismatches: mismatches, Usage: &usage, DryRun: true, Skipped: len(rowErrors), Errors: rowErrors}, nil
+}
+
+// usage is the embedder usage of docs.
+func (s *Service) usage(docs []doc) embed.Usage {
+	var u embed.Usage
+	texts, _ := textsOf(docs)
+	u.Add(s.tokenPrice, texts...)
+	return u
+}
+
+// Ingest stores an upload in Postgres and indexes it in Qdrant. It is shared
+// by the HTTP and gRPC APIs; errors are APIErrors.
+func (s *Service) Ingest(ctx context.Context, req *HadithUploadRequest) (UploadResponse, error) {
+	if err := apierr.Validate(req); err != nil {
+		return UploadResponse{}, err
+	}
+	tenantID := tenant.From(ctx)
+	if tenantID == tenant.All {
+		return UploadResponse{}, apierr.InvalidArgument("upload needs a tenant")
+	}
+	// Invalid hadiths are skipped and reported; the others are stored.
+	valid, rowErrors := checkItems(req)
+	if len(valid) == 0 {
+		return UploadResponse{}, noValidHadiths(rowErrors)
+	}
+	skipped := len(rowErrors)
+	release, err := s.limiter.acquire(ctx)
+	if err != nil {
+		return UploadResponse{}, err
+	}
+	defer release()
+	defer s.notifier.ContentChanged(context.WithoutCancel(ctx))
+	var publishAt *time.Time
+	if req.PublishAt != nil && req.PublishAt.After(time.Now()) {
+		publishAt = req.PublishAt
+	}
+
+	// The collection and all hadiths are written in one transaction: ids are
+	// reserved from the sequence up front so the rows can go in with a single
+	// COPY instead of one INSERT ... RETURNING per hadith.
+	tx, err := s.postgres.Begin(ctx)
+	if err != nil {
+		return UploadResponse{}, apierr.Database("db begin failed")
+	}
+	defer tx.Rollback(ctx)
+
+	if req.MessageID != "" {
+		tag, err := tx.Exec(ctx, `INSERT INTO ingested_messages (id, tenant_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, req.MessageID, tenantID)
+		if err != nil {
+			return UploadResponse{}, apierr.Database("db record message failed")
+		}
+		if tag.RowsAffected() == 0 {
+			return UploadResponse{}, ErrAlreadyIngested
+		}
+	}
+
+	var collectionID int64
+	err = tx.QueryRow(ctx, `
+INSERT INTO hadith_collections(code, title, tenant_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (tenant_id, code) DO UPDATE SET
+  title = EXCLUDED.title,
+  updated_at = CASE WHEN hadith_collections.title = EXCLUDED.title THEN hadith_collections.updated_at ELSE now() END
+RETURNING id
+`, req.Collection.Code, req.Collection.Title, tenantID).Scan(&collectionID)
+	if err != nil {
+		return UploadResponse{}, apierr.Database("db upsert collection failed")
+	}
+
+	type row struct {
+		ID     int64
+		Index  int
+		Number string
+		Title  string
+		TextAr string
+		TextRu string
+		TextEn string
+		Grade  string
+		Topics []string
+		Ayahs  []quran.Ref
+	}
+	idRows, err := tx.Query(ctx, `SELECT nextval(pg_get_serial_sequence('hadiths', 'id')) FROM generate_series(1, $1)`, len(valid))
+	if err != nil {
+		return UploadResponse{}, apierr.Database("db reserve ids failed")
+	}
+	ids, err := pgx.CollectRows(idRows, pgx.RowTo[int64])
+	if err != nil {
+		return UploadResponse{}, apierr.Database("db reserve ids failed")
+	}
+
+	rows := make([]row, 0, len(valid))
+	copyRows := make([][]any, 0, len(valid))
+	var mismatches []LangMismatch
+	var refs ayahRefs
+	for i, index := range valid {
+		h := req.Hadiths[index]
+		ayahs, source := itemAyahs(h)
+		refs.add(ids[i], ayahs, source)
+		detected, m := detectLangs(h)
+		mismatches = append(mismatches, m...)
+		copyRows = append(copyRows, []any{ids[i], collectionID, h.Number, postgres.NullString(h.Title), postgres.NullString(h.TextAr), postgres.NullString(h.TextRu), postgres.NullString(h.TextEn),
+			postgres.NullString(h.TextTranslit), postgres.NullString(store.NormalizeTranslit(h.TextTranslit)), postgres.NullString(h.Grade), postgres.TextArray(h.Topics), tenantID,
+			detected, len(m) > 0, publishAt, h.Book, postgres.NullString(h.BookTitle), h.Chapter, postgres.NullString(h.ChapterTitle), h.Sequence})
+		rows = append(rows, row{
+			ID:     ids[i],
+			Index:  index,
+			Number: h.Number,
+			Title:  h.Title,
+			TextAr: h.TextAr,
+			TextRu: h.TextRu,
+			TextEn: h.TextEn,
+			Grade:  h.Grade,
+			Topics: h.Topics,
+			Ayahs:  ayahs,
+		})
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"hadiths"},
+		[]string{"id", "collection_id", "number", "title", "text_ar", "text_ru", "text_en", "text_translit", "translit_norm", "grade", "topics", "tenant_id", "detected_langs", "lang_mismatch", "publish_at", "book", "book_title", "chapter", "chapter_title", "sequence"},
+		pgx.CopyFromRows(copyRows))
+	if err != nil {
+		return UploadResponse{}, apierr.Database("db insert hadiths failed")
+	}
+	if err := refs.insert(ctx, tx); err != nil {
+		return UploadResponse{}, apierr.Database("db insert ayah refs failed")
+	}
+	// Scheduled hadiths get their intents when they are published.
+	var intents []int64
+	if publishAt == nil {
+		intents, err = recordIntents(ctx, tx, ids)
+		if err != nil {
+			return UploadResponse{}, apierr.Database("db record index intents failed")
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return UploadResponse{}, apierr.Database("db commit failed")
+	}
+
+	metrics.IngestedHadiths.Add(float64(len(rows)))
+	for _, m := range mismatches {
+		metrics.IngestLangMismatches.WithLabelValues(m.Field).Inc()
+	}
+	if len(mismatches) > 0 {
+		slog.WarnContext(ctx, "ingest: texts in another language than their field",
+			"collection", req.Collection.Code, "count", len(mismatches), "first", mismatches[0])
+	}
+	s.notifier.CollectionUpdated(ctx, req.Collection.Code, req.Collection.Title)
+	reportProgress(ctx, Progress{Stage: StageInserted, Inserted: len(rows)})
+	if publishAt != nil {
+		return UploadResponse{Inserted: len(rows), Scheduled: len(rows), LangMismatches: mismatches, Skipped: skipped, Errors: rowErrors}, nil
+	}
+	created := make([]Created, 0, len(rows))
+	for _, r := range rows {
+		created = append(created, Created{ID: r.ID, Number: r.Number})
+	}
+	s.notifier.HadithsCreated(ctx, req.Collection.Code, created)
+
+	docs := make([]doc, 0, len(rows))
+	indexOf := make(map[int64]int, len(rows))
+	for _, r := range rows {
+		indexOf[r.ID] = r.Index
+		if d, ok := newDoc(r.ID, tenantID, req.Collection.Code, r.Number, r.TextAr, r.TextRu, r.TextEn, r.Grade); ok {
+			d.Title, d.Topics, d.Ayahs = r.Title, r.Topics, r.Ayahs
+			docs = append(docs, d)
+		}
+	}
+	// The upload holds the lease on its intents while it indexes; those of
+	// batches that fail are left for RunOutbox, and the other batches go
+	// on.
+	var mu sync.Mutex
+	var failed []int64
+	var cause error
+	embedded, err := s.indexBatches(ctx, docs, false, func(batch []doc, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		var apiErr *apierr.APIError
+		errors.As(err, &apiErr)
+		for _, d := range batch {
+			failed = append(failed, d.ID)
+			rowErrors = append(rowErrors, RowError{Index: indexOf[d.ID], Number: d.Number, Stored: true, Reason: apiErr})
+		}
+		if cause == nil {
+			cause = err
+		}
+	})
+	if err != nil {
+		if rerr := s.releaseIntents(context.WithoutCancel(ctx), intents, err); rerr != nil {
+			slog.ErrorContext(ctx, "index outbox: release failed", "error", rerr)
+		}
+		return UploadResponse{}, err
+	}
+	if len(failed) > 0 {
+		slog.WarnContext(ctx, "ingest: batches failed to index, left for the outbox", "collection", req.Collection.Code, "hadiths", len(failed), "error", cause)
+		err = s.settleIntents(context.WithoutCancel(ctx), intents, failed, cause)
+	} else {
+		err = s.completeIntents(context.WithoutCancel(ctx), intents)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "index outbox: complete failed", "error", err)
+	}
+	slices.SortFunc(rowErrors, func(a, b RowError) int { return a.Index - b.Index })
+	usage := s.usage(docs)
+	return UploadResponse{Inserted: len(rows), Embedded: embedded, LangMismatches: mismatches, Usage: &usage, Skipped: skipped, Errors: rowErrors}, nil
+}
+
+// doc is a hadith as it is embedded and indexed.
+type doc struct {
+	ID         int64
+	Tenant     int64
+	Collection string
+	Text       string
+	// Lang is the detected language of Text; DeclaredLang is the language
+	// of its field when that differs.
+	Lang         string
+	DeclaredLang string
+	Number       string
+	Grade        string
+	Title        string
+	Topics       []string
+	Ayahs        []quran.Ref
+}
+
+// newDoc reports false for hadiths without any text, which are not indexed.
+func newDoc(id, tenantID int64, collection, number, textAr, textRu, textEn, grade string) (doc, bool) {
+	text, lang := PreferredText(map[string]string{
+		"ru": textRu,
+		"en": textEn,
+		"ar": textAr,
+	})
+	if text == "" {
+		return doc{}, false
+	}
+	d := doc{ID: id, Tenant: tenantID, Collection: collection, Text: text, Lang: lang, Number: number, Grade: grade}
+	if detected := i18n.Detect(text); detected != "" && detected != lang {
+		d.Lang, d.DeclaredLang = detected, lang
+	}
+	return d, true
+}
+
+// index embeds docs and upserts their points, returning how many were
+// indexed. With replace, each batch's existing points are deleted first.
+func (s *Service) index(ctx context.Context, docs []doc, replace bool) (int, error) {
+	return s.indexBatches(ctx, docs, replace, nil)
+}
+
+// indexBatches is index, but when failed is set a batch that fails is
+// passed to it, from any goroutine, and the other batches go on; only
+// the cancellation of ctx is returned.
+func (s *Service) indexBatches(ctx context.Context, docs []doc, replace bool, failed func(batch []doc, err error)) (int, error) {
+	// Batches are embedded and upserted by up to s.concurrency workers, so
+	// one batch's upsert overlaps the next batch's embedding. The embedder's own
+	// concurrency limit applies across all uploads.
+	var upserted atomic.Int64
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(s.concurrency, 1))
+	batches := batchByChars(docs, embed.BatchChars(s.embedder))
+	for i, batch := range batches {
+		p := Progress{Stage: StageEmbedded, Batch: i + 1, Batches: len(batches)}
+		g.Go(func() error {
+			if err := s.indexBatch(gctx, batch, replace); err != nil {
+				// Batches cancelled by this failure are not reported.
+				if gctx.Err() == nil {
+					p.Stage = StageBatchFailed
+					errors.As(err, &p.Error)
+					reportProgress(ctx, p)
+				}
+				if failed != nil && ctx.Err() == nil {
+					failed(batch, err)
+					return nil
+				}
+				return err
+			}
+			p.Embedded = int(upserted.Add(int64(len(batch))))
+			reportProgress(ctx, p)
+			return nil
+		})
+	}
+	err := g.Wait()
+	return int(upserted.Load()), err
+}
+
+// batchByChars splits docs into batches whose texts add up to at most
+// budget characters, the embedder's request budget, so that each batch is
+// one request. A longer text is a batch of its own.
+func batchByChars(docs []doc, budget int) [][]doc {
+	var batches [][]doc
+	start, chars := 0, 0
+	for i, d := range docs {
+		n := utf8.RuneCountInString(d.Text)
+		if i > start && chars+n > budget {
+			batches = append(batches, docs[start:i])
+			start, chars = i, 0
+		}
+		chars += n
+	}
+	if start < len(docs) {
+		batches = append(batches, docs[start:])
+	}
+	return batches
+}
+
+func (s *Service) indexBatch(ctx context.Context, batch []doc, replace bool) error {
+	texts, ids := textsOf(batch)
+	embeds, err := s.embedder.Embed(ctx, texts)
+	if err != nil {
+		return embed.APIError(err)
+	}
+	points := newPoints(batch, embeds, s.model.Name)
+	if replace {
+		if err := s.vectors.Delete(ctx, hadithPoints(ids...)); err != nil {
+			return apierr.VectorStore("qdrant delete failed")
+		}
+	}
+	if err := s.vectors.Upsert(ctx, points); err != nil {
+		return apierr.VectorStore("qdrant upsert failed")
+	}
+	metrics.EmbeddedHadiths.Add(float64(len(points)))
+	s.mirror(ctx, batch, replace)
+	return nil
+}
+
+func textsOf(docs []doc) ([]string, []int64) {
+	texts := make([]string, 0, len(docs))
+	ids := make([]int64, 0, len(docs))
+	for _, d := range docs {
+		texts = append(texts, d.Text)
+		ids = append(ids, d.ID)
+	}
+	return texts, ids
+}
+
+// newPoints builds the points of docs from their embeddings, made by model.
+func newPoints(docs []doc, embeds [][]float32, model string) []*qdrant.PointStruct {
+	points := make([]*qdrant.PointStruct, 0, len(embeds))
+	for k, vec := range embeds {
+		d := docs[k]
+
+		fields := map[string]any{
+			"origin_type":     "hadith",
+			"origin_id":       d.ID,
+			"tenant_id":       d.Tenant,
+			"collection_code": d.Collection,
+			"number":          d.Number,
+			"lang":            d.Lang,
+			"title":           i18n.T(i18n.Default, "hadith.title", d.Number, d.Collection),
+			"snippet":         snippet(d.Text, 280),
+			"embedding_model": model,
+		}
+		maps.Copy(fields, metadataPayload(d.Grade, d.Title, d.Topics))
+		if len(d.Ayahs) > 0 {
+			fields["ayahs"] = ayahPayload(d.Ayahs)
+		}
+		if d.DeclaredLang != "" {
+			fields["declared_lang"] = d.DeclaredLang
+		}
+		payload := qdrant.NewValueMap(fields)
+
+		points = append(points, &qdrant.PointStruct{
+			Id:      &qdrant.PointId{PointIdOptions: &qdrant.PointId_Uuid{Uuid: uuid.NewString()}},
+			Vectors: &qdrant.Vectors{VectorsOptions: &qdrant.Vectors_Vector{Vector: qdrant.NewVector(vec...)}},
+			Payload: payload,
+		})
+	}
+	return points
+}
+
+// hadithPoints matches the points of the given hadiths, or of all hadiths
+// when none are given.
+func hadithPoints(ids ...int64) *qdrant.Filter {
+	f := &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatch("origin_type", "hadith")}}
+	if len(ids) > 0 {
+		f.Must = append(f.Must, qdrant.NewMatchInts("origin_id", ids...))
+	}
+	return f
+}
+
+// PreferredText picks the text that is embedded and shown for a hadith from
+// its translations by language code: Russian, then English, then Arabic.
+func PreferredText(texts map[string]string) (text string, lang string) {
+	if v := texts["ru"]; v != "" {
+		return v, "ru"
+	}
+	if v := texts["en"]; v != "" {
+		return v, "en"
+	}
+	if v := texts["ar"]; v != "" {
+		return v, "ar"
+	}
+	return "", ""
+}
+
+func snippet(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
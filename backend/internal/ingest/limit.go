@@ -0,0 +1,85 @@
+package ingest
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/metrics"
+)
+
+// Limiter bounds how many uploads are ingested at once across the
+// HTTP and gRPC APIs. Uploads beyond maxJobs wait in a bounded queue; a full
+// queue is rejected with 429 and a wait longer than queueTimeout with 503,
+// both with Retry-After.
+type Limiter struct {
+	slots        chan struct{}
+	maxQueue     int64
+	queueTimeout time.Duration
+	waiting      atomic.Int64
+}
+
+// NewLimiter returns nil, meaning unlimited, when maxJobs is 0.
+func NewLimiter(maxJobs, maxQueue int, queueTimeout time.Duration) *Limiter {
+	if maxJobs <= 0 {
+		return nil
+	}
+	return &Limiter{
+		slots:        make(chan struct{}, maxJobs),
+		maxQueue:     int64(maxQueue),
+		queueTimeout: queueTimeout,
+	}
+}
+
+func (l *Limiter) retryAfter() int {
+	return max(int(math.Ceil(l.queueTimeout.Seconds())), 1)
+}
+
+// acquire waits for an ingestion slot. The returned release must be called
+// once the upload is done.
+func (l *Limiter) acquire(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	release = func() {
+		<-l.slots
+		metrics.IngestJobsRunning.Dec()
+	}
+	select {
+	case l.slots <- struct{}{}:
+		metrics.IngestJobsRunning.Inc()
+		return release, nil
+	default:
+	}
+
+	if l.waiting.Add(1) > l.maxQueue {
+		l.waiting.Add(-1)
+		metrics.IngestRejected.WithLabelValues("queue_full").Inc()
+		return nil, apierr.New(http.StatusTooManyRequests, apierr.CodeOverloaded, "too many uploads in progress").
+			WithRetryAfter(l.retryAfter())
+	}
+	metrics.IngestQueueDepth.Inc()
+	defer func() {
+		l.waiting.Add(-1)
+		metrics.IngestQueueDepth.Dec()
+	}()
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		metrics.IngestJobsRunning.Inc()
+		return release, nil
+	case <-timer.C:
+		metrics.IngestRejected.WithLabelValues("timeout").Inc()
+		return nil, apierr.New(http.StatusServiceUnavailable, apierr.CodeOverloaded, "timed out waiting for an ingestion slot").
+			WithRetryAfter(l.retryAfter())
+	case <-ctx.Done():
+		metrics.IngestRejected.WithLabelValues("cancelled").Inc()
+		return nil, apierr.New(http.StatusServiceUnavailable, apierr.CodeOverloaded, "timed out waiting for an ingestion slot").
+			WithRetryAfter(l.retryAfter())
+	}
+}
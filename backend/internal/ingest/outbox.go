@@ -0,0 +1,169 @@
+package ingest
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/metrics"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+)
+
+// Hadiths are indexed through the index_outbox table: uploads record an
+// intent per hadith in the transaction that stores it, so a failed or
+// interrupted Qdrant write is applied later by RunOutbox instead of being
+// lost.
+const (
+	// outboxLease is how long a claimed intent is hidden from other workers,
+	// covering the upload that wrote it while it indexes synchronously.
+	outboxLease     = 5 * time.Minute
+	outboxBatchSize = 256
+	outboxMaxDelay  = time.Hour
+)
+
+// recordIntents adds an outbox row per hadith, leased to the caller.
+func recordIntents(ctx context.Context, tx pgx.Tx, ids []int64) ([]int64, error) {
+	rows, err := tx.Query(ctx, `
+INSERT INTO index_outbox (hadith_id, available_at)
+SELECT unnest($1::bigint[]), now() + $2::interval
+RETURNING id`, ids, outboxLease)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[int64])
+}
+
+// QueueReindexAll records an intent for every hadith of the tenant of ctx,
+// so that RunOutbox rebuilds its part of the index from Postgres once tx
+// commits.
+func QueueReindexAll(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `INSERT INTO index_outbox (hadith_id) SELECT id FROM hadiths h WHERE `+postgres.TenantWhere("h", 1), tenant.From(ctx))
+	return err
+}
+
+// completeIntents deletes applied outbox rows.
+func (s *Service) completeIntents(ctx context.Context, outboxIDs []int64) error {
+	_, err := s.postgres.Exec(ctx, `DELETE FROM index_outbox WHERE id = ANY($1)`, outboxIDs)
+	return err
+}
+
+// releaseIntents makes outbox rows due again after a failed attempt, with a
+// delay that doubles per attempt.
+func (s *Service) releaseIntents(ctx context.Context, outboxIDs []int64, cause error) error {
+	_, err := s.postgres.Exec(ctx, `
+UPDATE index_outbox SET
+  attempts = attempts + 1,
+  available_at = now() + least(interval '10 seconds' * power(2, least(attempts, 12)), $3::interval),
+  last_error = $2
+WHERE id = ANY($1)`, outboxIDs, cause.Error(), outboxMaxDelay)
+	return err
+}
+
+// settleIntents completes the outbox rows of outboxIDs but those of the
+// failed hadiths, which are released as by releaseIntents.
+func (s *Service) settleIntents(ctx context.Context, outboxIDs, failed []int64, cause error) error {
+	_, err := s.postgres.Exec(ctx, `
+UPDATE index_outbox SET
+  attempts = attempts + 1,
+  available_at = now() + least(interval '10 seconds' * power(2, least(attempts, 12)), $3::interval),
+  last_error = $2
+WHERE id = ANY($1) AND hadith_id = ANY($4)`, outboxIDs, cause.Error(), outboxMaxDelay, failed)
+	if err != nil {
+		return err
+	}
+	_, err = s.postgres.Exec(ctx, `DELETE FROM index_outbox WHERE id = ANY($1) AND hadith_id <> ALL($2)`, outboxIDs, failed)
+	return err
+}
+
+// applyIntents makes the points of the given hadiths match Postgres: their
+// points are deleted and hadiths that still exist and have text are indexed
+// again.
+func (s *Service) applyIntents(ctx context.Context, hadithIDs []int64) (int, error) {
+	slices.Sort(hadithIDs)
+	hadithIDs = slices.Compact(hadithIDs)
+	hadiths, _, err := s.store.Hadiths(ctx, hadithIDs)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.vectors.Delete(ctx, hadithPoints(hadithIDs...)); err != nil {
+		return 0, err
+	}
+	s.mirrorDelete(ctx, hadithPoints(hadithIDs...))
+	return s.index(ctx, docsOf(hadiths), false)
+}
+
+// ProcessOutbox applies one batch of due intents and reports how many were
+// applied. Intents of all tenants are applied together.
+func (s *Service) ProcessOutbox(ctx context.Context) (int, error) {
+	ctx = tenant.Unscoped(ctx)
+	rows, err := s.postgres.Query(ctx, `
+UPDATE index_outbox SET available_at = now() + $2::interval
+WHERE id IN (
+  SELECT id FROM index_outbox
+  WHERE available_at <= now()
+  ORDER BY available_at, id
+  LIMIT $1
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id, hadith_id`, outboxBatchSize, outboxLease)
+	if err != nil {
+		return 0, err
+	}
+	var outboxIDs, hadithIDs []int64
+	var id, hadithID int64
+	_, err = pgx.ForEachRow(rows, []any{&id, &hadithID}, func() error {
+		outboxIDs = append(outboxIDs, id)
+		hadithIDs = append(hadithIDs, hadithID)
+		return nil
+	})
+	if err != nil || len(outboxIDs) == 0 {
+		return 0, err
+	}
+
+	if _, err := s.applyIntents(ctx, hadithIDs); err != nil {
+		metrics.IndexOutboxApplied.WithLabelValues("error").Add(float64(len(outboxIDs)))
+		if rerr := s.releaseIntents(context.WithoutCancel(ctx), outboxIDs, err); rerr != nil {
+			slog.Error("index outbox: release failed", "error", rerr)
+		}
+		return 0, err
+	}
+	metrics.IndexOutboxApplied.WithLabelValues("ok").Add(float64(len(outboxIDs)))
+	s.notifier.ContentChanged(context.WithoutCancel(ctx))
+	return len(outboxIDs), s.completeIntents(ctx, outboxIDs)
+}
+
+// RunOutbox applies due intents every interval until ctx is done. Replicas
+// can run it side by side; each intent is claimed by one of them.
+func (s *Service) RunOutbox(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		for {
+			n, err := s.ProcessOutbox(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Warn("index outbox: apply failed, will retry", "error", err)
+				}
+				break
+			}
+			if n > 0 {
+				slog.Info("index outbox: applied intents", "hadiths", n)
+			}
+			if n < outboxBatchSize {
+				break
+			}
+		}
+		var pending int64
+		if err := s.postgres.QueryRow(ctx, `SELECT count(*) FROM index_outbox`).Scan(&pending); err == nil {
+			metrics.IndexOutboxPending.Set(float64(pending))
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/metrics"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// metadataPayload is the part of a hadith point's payload that does not
+// depend on its text, so it can change without embedding it again.
+func metadataPayload(grade, title string, topics []string) map[string]any {
+	fields := map[string]any{}
+	if grade != "" {
+		fields["grade"] = grade
+	}
+	if title != "" {
+		fields["custom_title"] = title
+	}
+	if len(topics) > 0 {
+		list := make([]any, len(topics))
+		for i, t := range topics {
+			list[i] = t
+		}
+		fields["topics"] = list
+	}
+	return fields
+}
+
+// HadithPatch changes the metadata of a hadith; nil fields are kept and
+// empty ones cleared.
+type HadithPatch struct {
+	Grade  *string   `json:"grade,omitempty"`
+	Title  *string   `json:"title,omitempty"`
+	Topics *[]string `json:"topics,omitempty"`
+}
+
+func (p HadithPatch) topics() any {
+	if p.Topics == nil {
+		return nil
+	}
+	return postgres.TextArray(*p.Topics)
+}
+
+// payload is the change p makes to the payload of a hadith's points.
+func (p HadithPatch) payload() (set map[string]*qdrant.Value, unset []string) {
+	fields := metadataPayload(deref(p.Grade), deref(p.Title), deref(p.Topics))
+	set = qdrant.NewValueMap(fields)
+	for key, changed := range map[string]bool{"grade": p.Grade != nil, "custom_title": p.Title != nil, "topics": p.Topics != nil} {
+		if _, ok := fields[key]; changed && !ok {
+			unset = append(unset, key)
+		}
+	}
+	return set, unset
+}
+
+func nullString(s *string) any {
+	return postgres.NullString(deref(s))
+}
+
+// Patch updates the metadata of hadith id in Postgres and in the payload of
+// its points, without embedding it again. When the payload cannot be
+// patched the hadith is left to RunOutbox, which reindexes it. Errors are
+// APIErrors.
+func (s *Service) Patch(ctx context.Context, id int64, p HadithPatch) (store.Hadith, error) {
+	defer s.notifier.ContentChanged(context.WithoutCancel(ctx))
+	tx, err := s.postgres.Begin(ctx)
+	if err != nil {
+		return store.Hadith{}, apierr.Database("db begin failed")
+	}
+	defer tx.Rollback(ctx)
+
+	// Scheduled hadiths can be patched too; they have no points yet.
+	h, err := postgres.ScanHadith(tx.QueryRow(ctx, `
+UPDATE hadiths h SET
+  grade = CASE WHEN $2 THEN $3 ELSE h.grade END,
+  title = CASE WHEN $4 THEN $5 ELSE h.title END,
+  topics = CASE WHEN $6 THEN $7::text[] ELSE h.topics END,
+  updated_at = now()
+FROM hadith_collections c
+WHERE h.id = $1 AND c.id = h.collection_id AND `+postgres.TenantWhere("h", 8)+`
+RETURNING `+postgres.HadithColumns,
+		id, p.Grade != nil, nullString(p.Grade), p.Title != nil, nullString(p.Title), p.Topics != nil, p.topics(), tenant.From(ctx)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return store.Hadith{}, apierr.NotFound("hadith not found")
+	}
+	if err != nil {
+		return store.Hadith{}, apierr.Database("db update hadith failed")
+	}
+	intents, err := recordIntents(ctx, tx, []int64{id})
+	if err != nil {
+		return store.Hadith{}, apierr.Database("db record index intents failed")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return store.Hadith{}, apierr.Database("db commit failed")
+	}
+	s.notifier.HadithsUpdated(ctx, []int64{id})
+
+	set, unset := p.payload()
+	if err := s.setPayload(ctx, id, set, unset); err != nil {
+		if rerr := s.releaseIntents(context.WithoutCancel(ctx), intents, err); rerr != nil {
+			slog.ErrorContext(ctx, "index outbox: release failed", "error", rerr)
+		}
+		return store.Hadith{}, apierr.VectorStore("qdrant set payload failed")
+	}
+	if err := s.completeIntents(context.WithoutCancel(ctx), intents); err != nil {
+		slog.ErrorContext(ctx, "index outbox: complete failed", "error", err)
+	}
+	return h, nil
+}
+
+// setPayload changes the payload of the points of hadith id, in the shadow
+// index too, where failures are only logged.
+func (s *Service) setPayload(ctx context.Context, id int64, set map[string]*qdrant.Value, unset []string) error {
+	if err := s.vectors.SetPayload(ctx, hadithPoints(id), set, unset); err != nil {
+		return err
+	}
+	if s.shadow != nil {
+		if err := s.shadow.Vectors.SetPayload(ctx, hadithPoints(id), set, unset); err != nil {
+			metrics.ShadowIndexErrors.Inc()
+			slog.WarnContext(ctx, "shadow set payload failed", "hadith_id", id, "error", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package ingest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+)
+
+// Stages of an upload's Progress.
+const (
+	// StageInserted: the hadiths are stored.
+	StageInserted = "inserted"
+	// StageEmbedded: a batch is embedded and indexed.
+	StageEmbedded = "embedded"
+	// StageBatchFailed: a batch failed, and with it the upload.
+	StageBatchFailed = "batch_failed"
+)
+
+// Progress is a step of an upload or reindex.
+type Progress struct {
+	Stage    string `json:"stage"`
+	Inserted int    `json:"inserted,omitempty"`
+	// Batch counts from 1 of Batches; batches finish out of order.
+	Batch   int `json:"batch,omitempty"`
+	Batches int `json:"batches,omitempty"`
+	// Embedded is how many hadiths are indexed so far.
+	Embedded int              `json:"embedded,omitempty"`
+	Error    *apierr.APIError `json:"error,omitempty"`
+}
+
+type progressKey struct{}
+
+// WithProgress has Ingest and Reindex called with ctx report their steps
+// to fn, one call at a time.
+func WithProgress(ctx context.Context, fn func(Progress)) context.Context {
+	var mu sync.Mutex
+	return context.WithValue(ctx, progressKey{}, func(p Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		fn(p)
+	})
+}
+
+func reportProgress(ctx context.Context, p Progress) {
+	if fn, ok := ctx.Value(progressKey{}).(func(Progress)); ok {
+		fn(p)
+	}
+}
@@ -0,0 +1,281 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/buugaaga/test-cursor/backend/internal/embed"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/buugaaga/test-cursor/backend/internal/vector"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// IndexLock is the advisory lock held while the vector index is rebuilt,
+// repaired or restored, so that one replica or command does it at a time.
+const IndexLock = "index"
+
+// LockIndex waits for IndexLock, logging when another replica or command
+// holds it.
+func (s *Service) LockIndex(ctx context.Context) (*postgres.Lock, error) {
+	lock, err := postgres.TryLock(ctx, s.postgres, IndexLock)
+	if lock != nil || err != nil {
+		return lock, err
+	}
+	slog.InfoContext(ctx, "waiting for another reindex, backfill or restore to finish")
+	return postgres.AcquireLock(ctx, s.postgres, IndexLock)
+}
+
+type ReindexResult struct {
+	Hadiths  int `json:"hadiths"`
+	Embedded int `json:"embedded"`
+	// Usage is the text embedded or, for an estimate, that would be.
+	Usage embed.Usage `json:"usage"`
+}
+
+// Reindex embeds the hadiths of collection again, or of every collection
+// of the tenant of ctx when it is "", and replaces their points. Old points
+// are deleted batch by batch just before the new ones are upserted, so
+// searches only miss the batch in flight.
+//
+// When the index was built with another embedding model, only a full
+// reindex of every tenant is allowed: it deletes the old points first and
+// records the embedder's model, so that vectors of the two are never
+// searched together.
+func (s *Service) Reindex(ctx context.Context, collection string) (ReindexResult, error) {
+	if err := s.switchModel(ctx, collection); err != nil {
+		return ReindexResult{}, err
+	}
+	defer s.notifier.ContentChanged(context.WithoutCancel(ctx))
+	return s.reindex(ctx, collection, false)
+}
+
+// EstimateReindex reports what Reindex would embed, without embedding.
+func (s *Service) EstimateReindex(ctx context.Context, collection string) (ReindexResult, error) {
+	return s.reindex(ctx, collection, true)
+}
+
+func (s *Service) reindex(ctx context.Context, collection string, dryRun bool) (ReindexResult, error) {
+	var collections []store.Collection
+	if collection != "" {
+		c, err := s.store.Collection(ctx, collection)
+		if err != nil {
+			return ReindexResult{}, fmt.Errorf("collection %s: %w", collection, err)
+		}
+		collections = []store.Collection{c}
+	} else {
+		var err error
+		if collections, err = s.store.Collections(ctx); err != nil {
+			return ReindexResult{}, err
+		}
+	}
+
+	var total ReindexResult
+	for _, c := range collections {
+		res, err := s.reindexCollection(tenant.With(ctx, c.TenantID), c.Code, dryRun)
+		total.Hadiths += res.Hadiths
+		total.Embedded += res.Embedded
+		total.Usage.Merge(res.Usage)
+		if err != nil {
+			return total, err
+		}
+		if !dryRun {
+			slog.InfoContext(ctx, "reindexed collection", "tenant", c.TenantID, "collection", c.Code, "hadiths", res.Hadiths, "embedded", res.Embedded, "tokens", res.Usage.Tokens)
+		}
+	}
+	return total, nil
+}
+
+// CheckModel records the embedder's model for an index that has none, and
+// returns a *vector.ModelMismatchError when the index holds vectors of
+// another model.
+func (s *Service) CheckModel(ctx context.Context) error {
+	return vector.EnsureModel(ctx, s.postgres, vector.PrimaryIndex, s.model.Name, s.model.Dimensions)
+}
+
+func (s *Service) switchModel(ctx context.Context, collection string) error {
+	var mismatch *vector.ModelMismatchError
+	err := s.CheckModel(ctx)
+	if !errors.As(err, &mismatch) {
+		return err
+	}
+	if collection != "" || tenant.From(ctx) != tenant.All {
+		return fmt.Errorf("%w: reindex every collection of every tenant to switch models", err)
+	}
+	if err := s.vectors.Delete(ctx, hadithPoints()); err != nil {
+		return err
+	}
+	if err := vector.SetModel(ctx, s.postgres, vector.PrimaryIndex, s.model.Name, s.model.Dimensions); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "switched embedding model", "from", mismatch.Indexed, "to", s.model.Name)
+	return nil
+}
+
+func (s *Service) reindexCollection(ctx context.Context, code string, dryRun bool) (ReindexResult, error) {
+	var res ReindexResult
+	var cursor *store.HadithCursor
+	for {
+		page, next, err := s.store.CollectionHadiths(ctx, code, 500, false, cursor)
+		if err != nil {
+			return res, err
+		}
+		docs := docsOf(page)
+		res.Hadiths += len(page)
+		if !dryRun {
+			var n int
+			n, err = s.index(ctx, docs, true)
+			res.Embedded += n
+		}
+		if err == nil {
+			res.Usage.Merge(s.usage(docs))
+		}
+		if err != nil || next == nil {
+			return res, err
+		}
+		cursor = next
+	}
+}
+
+// ReindexHadiths embeds the given hadiths again and replaces their points.
+// Ids that do not exist are skipped.
+func (s *Service) ReindexHadiths(ctx context.Context, ids []int64) (ReindexResult, error) {
+	if len(ids) == 0 {
+		return ReindexResult{}, nil
+	}
+	defer s.notifier.ContentChanged(context.WithoutCancel(ctx))
+	hadiths, _, err := s.store.Hadiths(ctx, ids)
+	if err != nil {
+		return ReindexResult{}, err
+	}
+	docs := docsOf(hadiths)
+	n, err := s.index(ctx, docs, true)
+	res := ReindexResult{Hadiths: len(hadiths), Embedded: n}
+	if err == nil {
+		res.Usage = s.usage(docs)
+	}
+	return res, err
+}
+
+func docsOf(hadiths []store.Hadith) []doc {
+	docs := make([]doc, 0, len(hadiths))
+	for _, h := range hadiths {
+		if d, ok := newDoc(h.ID, h.TenantID, h.CollectionCode, h.Number, deref(h.TextAr), deref(h.TextRu), deref(h.TextEn), deref(h.Grade)); ok {
+			d.Title, d.Topics, d.Ayahs = deref(h.Title), h.Topics, h.Ayahs
+			docs = append(docs, d)
+		}
+	}
+	return docs
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
+
+// CheckReport compares the hadiths in Postgres with their points in the
+// vector index, for the tenant of the context it was made with or for every
+// tenant. Every hadith with text should have exactly one point.
+type CheckReport struct {
+	Hadiths int `json:"hadiths"`
+	Points  int `json:"points"`
+	// Missing hadiths have no point.
+	Missing []int64 `json:"missing"`
+	// Duplicated hadiths have more than one point.
+	Duplicated []int64 `json:"duplicated"`
+	// Orphaned are the origin ids of points whose hadith was deleted or has
+	// no text.
+	Orphaned []int64 `json:"orphaned"`
+	// Outdated hadiths have a point embedded by another model. Points from
+	// before models were recorded count as the current model's.
+	Outdated []int64 `json:"outdated"`
+}
+
+func (r CheckReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Duplicated) == 0 && len(r.Orphaned) == 0 && len(r.Outdated) == 0
+}
+
+// Check scans every collection and every hadith point and reports where
+// they disagree.
+func (s *Service) Check(ctx context.Context) (CheckReport, error) {
+	var r CheckReport
+	indexable := map[int64]bool{}
+	collections, err := s.store.Collections(ctx)
+	if err != nil {
+		return r, err
+	}
+	for _, c := range collections {
+		var cursor *store.HadithCursor
+		for {
+			page, next, err := s.store.CollectionHadiths(tenant.With(ctx, c.TenantID), c.Code, 500, false, cursor)
+			if err != nil {
+				return r, err
+			}
+			for _, d := range docsOf(page) {
+				indexable[d.ID] = true
+			}
+			if next == nil {
+				break
+			}
+			cursor = next
+		}
+	}
+	r.Hadiths = len(indexable)
+
+	points := map[int64]int{}
+	outdated := map[int64]bool{}
+	err = s.vectors.Scroll(ctx, vector.ForTenant(ctx, hadithPoints()), func(page []*qdrant.RetrievedPoint) error {
+		for _, p := range page {
+			id := p.GetPayload()["origin_id"].GetIntegerValue()
+			points[id]++
+			if m := p.GetPayload()["embedding_model"].GetStringValue(); m != "" && m != s.model.Name {
+				outdated[id] = true
+			}
+		}
+		r.Points += len(page)
+		return nil
+	})
+	if err != nil {
+		return r, err
+	}
+
+	for id := range indexable {
+		if points[id] == 0 {
+			r.Missing = append(r.Missing, id)
+		}
+	}
+	for id, n := range points {
+		switch {
+		case !indexable[id]:
+			r.Orphaned = append(r.Orphaned, id)
+		case n > 1:
+			r.Duplicated = append(r.Duplicated, id)
+		case outdated[id]:
+			r.Outdated = append(r.Outdated, id)
+		}
+	}
+	slices.Sort(r.Missing)
+	slices.Sort(r.Duplicated)
+	slices.Sort(r.Orphaned)
+	slices.Sort(r.Outdated)
+	return r, nil
+}
+
+// Repair deletes the orphaned points of r and reindexes its missing,
+// duplicated and outdated hadiths.
+func (s *Service) Repair(ctx context.Context, r CheckReport) (ReindexResult, error) {
+	for ids := range slices.Chunk(r.Orphaned, 1000) {
+		if err := s.vectors.Delete(ctx, hadithPoints(ids...)); err != nil {
+			return ReindexResult{}, err
+		}
+		s.mirrorDelete(ctx, hadithPoints(ids...))
+	}
+	return s.ReindexHadiths(ctx, slices.Concat(r.Missing, r.Duplicated, r.Outdated))
+}
@@ -0,0 +1,111 @@
+package ingest
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+)
+
+// publishBatchSize bounds how many scheduled hadiths one transaction
+// publishes.
+const publishBatchSize = 500
+
+// PublishDue publishes one batch of hadiths whose publish_at has passed:
+// it clears publish_at, announces them as created and indexes them. Their
+// intents are recorded with the publication, so a failed index is left to
+// RunOutbox. It reports how many were published.
+func (s *Service) PublishDue(ctx context.Context) (int, error) {
+	ctx = tenant.Unscoped(ctx)
+	tx, err := s.postgres.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+UPDATE hadiths h SET publish_at = NULL, updated_at = now()
+FROM hadith_collections c
+WHERE c.id = h.collection_id AND h.id IN (
+  SELECT id FROM hadiths
+  WHERE publish_at <= now()
+  ORDER BY publish_at, id
+  LIMIT $1
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING h.id, h.number, c.code, h.tenant_id`, publishBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	type collectionKey struct {
+		tenant int64
+		code   string
+	}
+	var (
+		ids     []int64
+		created = map[collectionKey][]Created{}
+		c       Created
+		key     collectionKey
+	)
+	_, err = pgx.ForEachRow(rows, []any{&c.ID, &c.Number, &key.code, &key.tenant}, func() error {
+		ids = append(ids, c.ID)
+		created[key] = append(created[key], c)
+		return nil
+	})
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	intents, err := recordIntents(ctx, tx, ids)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	for k, hadiths := range created {
+		s.notifier.HadithsCreated(tenant.With(ctx, k.tenant), k.code, hadiths)
+	}
+	s.notifier.ContentChanged(context.WithoutCancel(ctx))
+	if _, err := s.applyIntents(ctx, ids); err != nil {
+		if rerr := s.releaseIntents(context.WithoutCancel(ctx), intents, err); rerr != nil {
+			slog.Error("index outbox: release failed", "error", rerr)
+		}
+		return len(ids), err
+	}
+	if err := s.completeIntents(context.WithoutCancel(ctx), intents); err != nil {
+		slog.Error("index outbox: complete failed", "error", err)
+	}
+	return len(ids), nil
+}
+
+// RunScheduler publishes due hadiths every interval until ctx is done.
+// Replicas can run it side by side; each hadith is published by one of them.
+func (s *Service) RunScheduler(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		for {
+			n, err := s.PublishDue(ctx)
+			if n > 0 {
+				slog.Info("scheduler: published hadiths", "hadiths", n)
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Warn("scheduler: publish failed, will retry", "error", err)
+				}
+				break
+			}
+			if n < publishBatchSize {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/buugaaga/test-cursor/backend/internal/embed"
+	"github.com/buugaaga/test-cursor/backend/internal/metrics"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/buugaaga/test-cursor/backend/internal/vector"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ErrNoShadow is returned by BackfillShadow when no shadow is configured.
+var ErrNoShadow = errors.New("no shadow embedder configured")
+
+// Shadow is a second embedder and index that hadiths are indexed into as
+// well, so that a candidate embedding model can be evaluated on the same
+// content before it replaces the primary one. Shadow failures are logged
+// and counted but never fail an upload.
+type Shadow struct {
+	Embedder embed.Embedder
+	Vectors  vector.Index
+	Model    embed.Model
+}
+
+func (sh *Shadow) index(ctx context.Context, docs []doc, replace bool) error {
+	texts, ids := textsOf(docs)
+	embeds, err := sh.Embedder.Embed(ctx, texts)
+	if err != nil {
+		return err
+	}
+	if replace {
+		if err := sh.Vectors.Delete(ctx, hadithPoints(ids...)); err != nil {
+			return err
+		}
+	}
+	return sh.Vectors.Upsert(ctx, newPoints(docs, embeds, sh.Model.Name))
+}
+
+// mirror indexes docs into the shadow, if any.
+func (s *Service) mirror(ctx context.Context, docs []doc, replace bool) {
+	if s.shadow == nil {
+		return
+	}
+	if err := s.shadow.index(ctx, docs, replace); err != nil {
+		metrics.ShadowIndexErrors.Inc()
+		slog.WarnContext(ctx, "shadow index failed", "hadiths", len(docs), "error", err)
+		return
+	}
+	metrics.ShadowIndexed.Add(float64(len(docs)))
+}
+
+// mirrorDelete deletes the points matching filter from the shadow, if any.
+func (s *Service) mirrorDelete(ctx context.Context, filter *qdrant.Filter) {
+	if s.shadow == nil {
+		return
+	}
+	if err := s.shadow.Vectors.Delete(ctx, filter); err != nil {
+		metrics.ShadowIndexErrors.Inc()
+		slog.WarnContext(ctx, "shadow delete failed", "error", err)
+	}
+}
+
+// BackfillShadow replaces the shadow's points with the embeddings of every
+// hadith of the tenant of ctx, or of every tenant when ctx is unscoped,
+// e.g. after the shadow model changed.
+func (s *Service) BackfillShadow(ctx context.Context) (ReindexResult, error) {
+	var res ReindexResult
+	if s.shadow == nil {
+		return res, ErrNoShadow
+	}
+	if err := s.shadow.Vectors.Delete(ctx, vector.ForTenant(ctx, hadithPoints())); err != nil {
+		return res, err
+	}
+	collections, err := s.store.Collections(ctx)
+	if err != nil {
+		return res, err
+	}
+	budget := embed.BatchChars(s.shadow.Embedder)
+	for _, c := range collections {
+		var cursor *store.HadithCursor
+		for {
+			page, next, err := s.store.CollectionHadiths(tenant.With(ctx, c.TenantID), c.Code, 512, false, cursor)
+			if err != nil {
+				return res, err
+			}
+			for _, batch := range batchByChars(docsOf(page), budget) {
+				if err := s.shadow.index(ctx, batch, false); err != nil {
+					return res, err
+				}
+				res.Embedded += len(batch)
+				metrics.ShadowIndexed.Add(float64(len(batch)))
+			}
+			res.Hadiths += len(page)
+			if next == nil {
+				break
+			}
+			cursor = next
+		}
+		slog.InfoContext(ctx, "backfilled shadow index", "collection", c.Code, "hadiths", res.Hadiths)
+	}
+	return res, nil
+}
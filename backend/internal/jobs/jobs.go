@@ -0,0 +1,446 @@
+// Package jobs runs background work queued in the Postgres jobs table.
+// Workers on every replica claim jobs with FOR UPDATE SKIP LOCKED, so each
+// attempt runs on one worker; failed attempts are retried with exponential
+// backoff until the kind's MaxAttempts. Jobs belong to the tenant that
+// queued them and run in its context.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/metrics"
+	"github.com/buugaaga/test-cursor/backend/internal/store/postgres"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	StateAvailable = "available"
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	StateFailed    = "failed"
+	StateCancelled = "cancelled"
+)
+
+var (
+	ErrNotFound = errors.New("job not found")
+	// ErrFinished is returned when cancelling a job that already finished.
+	ErrFinished = errors.New("job already finished")
+)
+
+// A running job holds a lease that its worker renews; when the worker dies
+// the lease runs out and the job is retried.
+const (
+	leaseDuration     = time.Minute
+	heartbeatInterval = 20 * time.Second
+	maintainInterval  = time.Minute
+	lockRetry         = 30 * time.Second
+)
+
+type Job struct {
+	ID          int64           `json:"id"`
+	Kind        string          `json:"kind"`
+	Args        json.RawMessage `json:"args"`
+	State       string          `json:"state"`
+	Attempt     int             `json:"attempt"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+	LastError   *string         `json:"last_error,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	// Worker is the replica that claimed the latest attempt.
+	Worker   *string `json:"worker,omitempty"`
+	TenantID int64   `json:"-"`
+}
+
+// Kind is a type of job and its retry policy.
+type Kind struct {
+	Name string
+	// Work runs one attempt; its result is stored as JSON on success.
+	Work func(ctx context.Context, job *Job) (any, error)
+	// MaxAttempts defaults to 5.
+	MaxAttempts int
+	// Backoff is the delay before the second attempt, doubled for every
+	// later one up to an hour; it defaults to 10s.
+	Backoff time.Duration
+	// Timeout bounds one attempt; it defaults to an hour.
+	Timeout time.Duration
+	// Lock, when set, names an advisory lock held while the job runs: jobs
+	// of kinds sharing it, on any replica, run one at a time. A job whose
+	// lock is taken waits lockRetry without using up an attempt.
+	Lock string
+}
+
+func (k Kind) backoff(attempt int) time.Duration {
+	d := k.Backoff
+	for i := 1; i < attempt && d < time.Hour; i++ {
+		d *= 2
+	}
+	return min(d, time.Hour)
+}
+
+// Permanent marks an error that retrying cannot fix: the job fails at once.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+type permanentError struct{ error }
+
+func (e permanentError) Unwrap() error { return e.error }
+
+type Config struct {
+	// Workers is how many jobs this process runs at once; 0 only enqueues.
+	Workers      int
+	PollInterval time.Duration
+	// Retention is how long finished jobs are kept.
+	Retention time.Duration
+	// Worker identifies this replica on the jobs it claims.
+	Worker string
+}
+
+type Client struct {
+	db    *pgxpool.Pool
+	cfg   Config
+	kinds map[string]Kind
+	wake  chan struct{}
+
+	mu      sync.Mutex
+	running map[int64]context.CancelFunc
+}
+
+func New(db *pgxpool.Pool, cfg Config) *Client {
+	return &Client{
+		db:      db,
+		cfg:     cfg,
+		kinds:   map[string]Kind{},
+		wake:    make(chan struct{}, 1),
+		running: map[int64]context.CancelFunc{},
+	}
+}
+
+// Register adds a kind; it must be called before Start.
+func (c *Client) Register(k Kind) {
+	if k.MaxAttempts == 0 {
+		k.MaxAttempts = 5
+	}
+	if k.Backoff == 0 {
+		k.Backoff = 10 * time.Second
+	}
+	if k.Timeout == 0 {
+		k.Timeout = time.Hour
+	}
+	c.kinds[k.Name] = k
+}
+
+const jobColumns = `id, kind, args, state, attempt, max_attempts, run_at, created_at, started_at, finished_at, last_error, result, worker, tenant_id`
+
+func scanJob(row pgx.Row) (Job, error) {
+	var j Job
+	err := row.Scan(&j.ID, &j.Kind, &j.Args, &j.State, &j.Attempt, &j.MaxAttempts, &j.RunAt, &j.CreatedAt, &j.StartedAt, &j.FinishedAt, &j.LastError, &j.Result, &j.Worker, &j.TenantID)
+	return j, err
+}
+
+// Enqueue queues a job of a registered kind with args encoded as JSON, for
+// the tenant of ctx.
+func (c *Client) Enqueue(ctx context.Context, kind string, args any) (Job, error) {
+	k, ok := c.kinds[kind]
+	if !ok {
+		return Job{}, fmt.Errorf("unknown job kind %q", kind)
+	}
+	tenantID := tenant.From(ctx)
+	if tenantID == tenant.All {
+		return Job{}, errors.New("jobs are queued for a tenant")
+	}
+	b, err := json.Marshal(args)
+	if err != nil {
+		return Job{}, err
+	}
+	j, err := scanJob(c.db.QueryRow(ctx, `
+INSERT INTO jobs (kind, args, max_attempts, tenant_id) VALUES ($1, $2, $3, $4)
+RETURNING `+jobColumns, kind, b, k.MaxAttempts, tenantID))
+	if err != nil {
+		return Job{}, err
+	}
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+	return j, nil
+}
+
+func (c *Client) Get(ctx context.Context, id int64) (Job, error) {
+	j, err := scanJob(c.db.QueryRow(ctx, `SELECT `+jobColumns+` FROM jobs j WHERE id = $1 AND `+postgres.TenantWhere("j", 2), id, tenant.From(ctx)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Job{}, ErrNotFound
+	}
+	return j, err
+}
+
+// Filter selects jobs for List; empty fields match everything.
+type Filter struct {
+	State string
+	Kind  string
+	Limit int
+	// BeforeID continues a listing after the job with this id.
+	BeforeID int64
+}
+
+// List returns the jobs of the tenant of ctx newest first.
+func (c *Client) List(ctx context.Context, f Filter) ([]Job, error) {
+	rows, err := c.db.Query(ctx, `
+SELECT `+jobColumns+` FROM jobs j
+WHERE ($1 = '' OR state = $1) AND ($2 = '' OR kind = $2) AND ($3 = 0 OR id < $3) AND `+postgres.TenantWhere("j", 5)+`
+ORDER BY id DESC
+LIMIT $4`, f.State, f.Kind, f.BeforeID, f.Limit, tenant.From(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Job, error) { return scanJob(row) })
+}
+
+// Cancel stops a queued or running job. A running attempt is interrupted
+// through its context, on whichever replica runs it, within a heartbeat.
+func (c *Client) Cancel(ctx context.Context, id int64) (Job, error) {
+	j, err := scanJob(c.db.QueryRow(ctx, `
+UPDATE jobs j SET state = 'cancelled', finished_at = now(), lease_until = NULL
+WHERE id = $1 AND state IN ('available', 'running') AND `+postgres.TenantWhere("j", 2)+`
+RETURNING `+jobColumns, id, tenant.From(ctx)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		if j, err = c.Get(ctx, id); err != nil {
+			return Job{}, err
+		}
+		return j, ErrFinished
+	}
+	if err != nil {
+		return Job{}, err
+	}
+	c.mu.Lock()
+	if cancel, ok := c.running[id]; ok {
+		cancel()
+	}
+	c.mu.Unlock()
+	return j, nil
+}
+
+// Start runs the workers and the lease and retention maintenance until ctx
+// is done.
+func (c *Client) Start(ctx context.Context) {
+	if c.cfg.Workers <= 0 {
+		return
+	}
+	for range c.cfg.Workers {
+		go c.work(ctx)
+	}
+	go postgres.RunAsLeader(ctx, c.db, "jobs.maintain", maintainInterval, c.maintain)
+}
+
+func (c *Client) work(ctx context.Context) {
+	kinds := make([]string, 0, len(c.kinds))
+	for name := range c.kinds {
+		kinds = append(kinds, name)
+	}
+	for {
+		j, err := c.claim(ctx, kinds)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("jobs: claim failed", "error", err)
+		}
+		if err == nil && j != nil {
+			c.execute(ctx, j)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.wake:
+		case <-time.After(c.cfg.PollInterval):
+		}
+	}
+}
+
+// claim takes the next due job of the given kinds, or returns nil.
+func (c *Client) claim(ctx context.Context, kinds []string) (*Job, error) {
+	j, err := scanJob(c.db.QueryRow(ctx, `
+UPDATE jobs SET state = 'running', attempt = attempt + 1, started_at = now(), lease_until = now() + $2::interval, worker = NULLIF($3, '')
+WHERE id = (
+  SELECT id FROM jobs
+  WHERE state = 'available' AND run_at <= now() AND kind = ANY($1)
+  ORDER BY run_at, id
+  LIMIT 1
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING `+jobColumns, kinds, leaseDuration, c.cfg.Worker))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (c *Client) execute(ctx context.Context, j *Job) {
+	k := c.kinds[j.Kind]
+	if k.Lock != "" {
+		lock, err := postgres.TryLock(ctx, c.db, k.Lock)
+		if lock == nil {
+			c.postpone(ctx, j, k.Lock, err)
+			return
+		}
+		defer lock.Release()
+	}
+	start := time.Now()
+	runCtx, cancel := context.WithTimeout(tenant.With(ctx, j.TenantID), k.Timeout)
+	defer cancel()
+	c.mu.Lock()
+	c.running[j.ID] = cancel
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.running, j.ID)
+		c.mu.Unlock()
+	}()
+
+	stop := make(chan struct{})
+	go c.heartbeat(runCtx, j, cancel, stop)
+	result, err := run(runCtx, k, j)
+	close(stop)
+
+	// The outcome is recorded even when ctx is shutting down.
+	ctx, done := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer done()
+	outcome := c.finish(ctx, k, j, result, err)
+	metrics.ObserveJob(j.Kind, outcome, start)
+	log := slog.With("job_id", j.ID, "kind", j.Kind, "attempt", j.Attempt, "duration", time.Since(start))
+	switch outcome {
+	case StateCompleted:
+		log.Info("job completed")
+	case StateAvailable:
+		log.Warn("job failed, will retry", "error", err)
+	default:
+		log.Warn("job "+outcome, "error", err)
+	}
+}
+
+// postpone puts back a job whose lock is taken, as if it was not claimed.
+func (c *Client) postpone(ctx context.Context, j *Job, lock string, lockErr error) {
+	ctx, done := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer done()
+	_, err := c.db.Exec(ctx, `
+UPDATE jobs SET state = 'available', attempt = attempt - 1, started_at = NULL, lease_until = NULL, run_at = now() + $2::interval
+WHERE id = $1 AND attempt = $3 AND state = 'running'`, j.ID, lockRetry, j.Attempt)
+	if err != nil {
+		slog.Error("jobs: postpone failed", "job_id", j.ID, "error", err)
+		return
+	}
+	if lockErr != nil {
+		slog.Warn("jobs: lock failed, postponed", "job_id", j.ID, "kind", j.Kind, "lock", lock, "error", lockErr)
+		return
+	}
+	slog.Info("jobs: lock held elsewhere, postponed", "job_id", j.ID, "kind", j.Kind, "lock", lock)
+}
+
+func run(ctx context.Context, k Kind, j *Job) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = Permanent(fmt.Errorf("panic: %v", r))
+		}
+	}()
+	return k.Work(ctx, j)
+}
+
+// heartbeat renews the lease until stop is closed and cancels the attempt
+// when the job was cancelled or, after its lease ran out, claimed again.
+func (c *Client) heartbeat(ctx context.Context, j *Job, cancel context.CancelFunc, stop <-chan struct{}) {
+	t := time.NewTicker(heartbeatInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		tag, err := c.db.Exec(ctx, `UPDATE jobs SET lease_until = now() + $3::interval WHERE id = $1 AND attempt = $2 AND state = 'running'`, j.ID, j.Attempt, leaseDuration)
+		if err == nil && tag.RowsAffected() == 0 {
+			cancel()
+			return
+		}
+	}
+}
+
+// finish records the attempt and returns the job's new state.
+func (c *Client) finish(ctx context.Context, k Kind, j *Job, result any, runErr error) string {
+	var tag pgconn.CommandTag
+	var err error
+	state := StateCompleted
+	switch {
+	case runErr == nil:
+		var b []byte
+		if b, err = json.Marshal(result); err == nil {
+			tag, err = c.db.Exec(ctx, `
+UPDATE jobs SET state = 'completed', finished_at = now(), lease_until = NULL, last_error = NULL, result = $3
+WHERE id = $1 AND attempt = $2 AND state = 'running'`, j.ID, j.Attempt, b)
+		}
+	case errors.As(runErr, new(permanentError)) || j.Attempt >= j.MaxAttempts:
+		state = StateFailed
+		tag, err = c.db.Exec(ctx, `
+UPDATE jobs SET state = 'failed', finished_at = now(), lease_until = NULL, last_error = $3
+WHERE id = $1 AND attempt = $2 AND state = 'running'`, j.ID, j.Attempt, runErr.Error())
+	default:
+		state = StateAvailable
+		tag, err = c.db.Exec(ctx, `
+UPDATE jobs SET state = 'available', run_at = now() + $4::interval, lease_until = NULL, last_error = $3
+WHERE id = $1 AND attempt = $2 AND state = 'running'`, j.ID, j.Attempt, runErr.Error(), k.backoff(j.Attempt))
+	}
+	if err != nil {
+		slog.Error("jobs: record outcome failed", "job_id", j.ID, "error", err)
+		return state
+	}
+	if tag.RowsAffected() == 0 {
+		return StateCancelled
+	}
+	return state
+}
+
+// maintain retries jobs whose worker disappeared and deletes finished jobs
+// past the retention.
+func (c *Client) maintain(ctx context.Context) {
+	t := time.NewTicker(maintainInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		tag, err := c.db.Exec(ctx, `
+UPDATE jobs SET
+  state = CASE WHEN attempt >= max_attempts THEN 'failed' ELSE 'available' END,
+  finished_at = CASE WHEN attempt >= max_attempts THEN now() END,
+  run_at = now(), lease_until = NULL, last_error = 'worker lost: lease expired'
+WHERE state = 'running' AND lease_until < now()`)
+		if err != nil {
+			slog.Error("jobs: rescue failed", "error", err)
+		} else if n := tag.RowsAffected(); n > 0 {
+			slog.Warn("jobs: rescued jobs with expired leases", "jobs", n)
+		}
+		if c.cfg.Retention > 0 {
+			_, err = c.db.Exec(ctx, `
+DELETE FROM jobs WHERE state IN ('completed', 'failed', 'cancelled') AND finished_at < now() - $1::interval`, c.cfg.Retention)
+			if err != nil {
+				slog.Error("jobs: prune failed", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Package logging configures slog for the service: the output format,
+// a level that can be changed at runtime, request ids on every record and
+// the slow-operation log.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Level is shared by every handler so /v1/admin/logging can change it at
+// runtime.
+var Level = new(slog.LevelVar)
+
+// LevelOff is above every level the code logs at.
+const LevelOff = slog.Level(100)
+
+var Levels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+	"off":   LevelOff,
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose log records carry id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// contextHandler adds the request id carried by the context to each record,
+// so anything logged with a request's context can be correlated with its
+// access log line.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+func NewHandler(w io.Writer, format string, level slog.Leveler) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == "text" {
+		return contextHandler{slog.NewTextHandler(w, opts)}
+	}
+	return contextHandler{slog.NewJSONHandler(w, opts)}
+}
+
+// Setup installs the default slog logger, writing to stderr (stdout carries
+// the protocol in -mcp-stdio mode). The standard library log package writes
+// through it as well.
+func Setup(level, format string) {
+	if lvl, ok := Levels[strings.ToLower(level)]; ok {
+		Level.Set(lvl)
+	}
+	slog.SetDefault(slog.New(NewHandler(os.Stderr, format, Level)))
+}
+
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
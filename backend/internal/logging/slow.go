@@ -0,0 +1,22 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// SlowThresholds are set at startup from the SLOW_*_THRESHOLD variables.
+// Operations taking longer are logged at warn level; zero disables logging
+// for that kind.
+var SlowThresholds struct {
+	Postgres, Qdrant, Embedder, Request time.Duration
+}
+
+// Slow logs an operation of kind that took d when d reaches threshold.
+func Slow(ctx context.Context, kind string, threshold, d time.Duration, args ...any) {
+	if threshold <= 0 || d < threshold {
+		return
+	}
+	slog.WarnContext(ctx, "slow "+kind, append([]any{"duration", d.String(), "threshold", threshold.String()}, args...)...)
+}
@@ -0,0 +1,179 @@
+// Package metrics defines the Prometheus metrics of the service. They are
+// registered with the default registry, which /metrics serves.
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by route template, method and status.",
+	}, []string{"method", "route", "status"})
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route template and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	embedderDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "embedder_request_duration_seconds",
+		Help:    "Latency of calls to the embedding service.",
+		Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"outcome"})
+	embedderErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "embedder_errors_total",
+		Help: "Failed calls to the embedding service.",
+	})
+	EmbedderRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "embedder_retries_total",
+		Help: "Retried attempts to call the embedding service.",
+	})
+	EmbedderBatchSplits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "embedder_batch_splits_total",
+		Help: "Embedding requests split in two after the service rejected them as too large.",
+	})
+	EmbedderCircuitOpen = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "embedder_circuit_open",
+		Help: "1 while the embedder circuit breaker is open.",
+	})
+
+	qdrantDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "qdrant_operation_duration_seconds",
+		Help:    "Latency of Qdrant operations.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation", "outcome"})
+	QdrantRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "qdrant_retries_total",
+		Help: "Retried attempts of Qdrant operations, by operation.",
+	}, []string{"operation"})
+	QdrantUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "qdrant_up",
+		Help: "0 while calls to a Qdrant collection keep failing, else 1.",
+	}, []string{"collection"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Result cache lookups by route and result (hit, miss).",
+	}, []string{"route", "result"})
+
+	IngestedHadiths = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ingest_hadiths_total",
+		Help: "Hadiths stored by uploads.",
+	})
+	EmbeddedHadiths = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ingest_hadiths_embedded_total",
+		Help: "Hadiths embedded and indexed by uploads.",
+	})
+	IngestLangMismatches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ingest_lang_mismatches_total",
+		Help: "Uploaded texts whose detected language differs from their field, by field.",
+	}, []string{"field"})
+	IngestJobsRunning = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ingest_jobs_running",
+		Help: "Uploads currently being ingested.",
+	})
+	IngestQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ingest_queue_depth",
+		Help: "Uploads waiting for an ingestion slot.",
+	})
+	IngestRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ingest_rejected_total",
+		Help: "Uploads shed by reason (queue_full, timeout, cancelled).",
+	}, []string{"reason"})
+	IndexOutboxPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "index_outbox_pending",
+		Help: "Hadiths whose Qdrant points still have to be brought in line with Postgres.",
+	})
+	IndexOutboxApplied = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "index_outbox_applied_total",
+		Help: "Index intents applied by the outbox worker, by outcome (ok, error).",
+	}, []string{"outcome"})
+	SearchFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "search_fallbacks_total",
+		Help: "Semantic searches answered by Postgres keyword search because the embedder or vector store failed, by outcome (ok, error).",
+	}, []string{"outcome"})
+	ShadowIndexed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "shadow_index_hadiths_total",
+		Help: "Hadiths indexed into the shadow embedding index.",
+	})
+	ShadowIndexErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "shadow_index_errors_total",
+		Help: "Failed writes to the shadow embedding index.",
+	})
+	ShadowSearches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "shadow_searches_total",
+		Help: "Searches mirrored to the shadow index by outcome (compared, error, dropped).",
+	}, []string{"outcome"})
+	ShadowSearchOverlap = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "shadow_search_overlap_ratio",
+		Help:    "Share of the primary search's hadiths that the shadow search also returned.",
+		Buckets: prometheus.LinearBuckets(0, 0.1, 11),
+	})
+	ShadowSearchTopMatch = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "shadow_search_top_match_total",
+		Help: "Mirrored searches by whether both indexes ranked the same hadith first (true, false).",
+	}, []string{"match"})
+	ShadowSearchDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "shadow_search_duration_seconds",
+		Help:    "Query embedding and search time in the shadow index of mirrored searches.",
+		Buckets: prometheus.DefBuckets,
+	})
+	NotificationsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notifications_sent_total",
+		Help: "Daily hadith deliveries by channel and outcome (sent, invalid_target, error).",
+	}, []string{"channel", "outcome"})
+
+	jobsFinished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "jobs_attempts_total",
+		Help: "Background job attempts by kind and resulting state.",
+	}, []string{"kind", "state"})
+	jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "job_attempt_duration_seconds",
+		Help:    "Duration of background job attempts by kind.",
+		Buckets: []float64{.1, .5, 1, 5, 15, 60, 300, 900, 3600},
+	}, []string{"kind"})
+)
+
+// ObserveJob records a job attempt that started at start and left the job
+// in state.
+func ObserveJob(kind, state string, start time.Time) {
+	jobsFinished.WithLabelValues(kind, state).Inc()
+	jobDuration.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+}
+
+// Outcome is the outcome label of a call that returned err.
+func Outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// ObserveEmbedder records a call to the embedding service.
+func ObserveEmbedder(ctx context.Context, start time.Time, err error, texts []string) {
+	d := time.Since(start)
+	embedderDuration.WithLabelValues(Outcome(err)).Observe(d.Seconds())
+	chars := 0
+	for _, t := range texts {
+		chars += len(t)
+	}
+	logging.Slow(ctx, "embedder call", logging.SlowThresholds.Embedder, d, "texts", len(texts), "bytes", chars, "outcome", Outcome(err))
+	if err != nil {
+		embedderErrors.Inc()
+	}
+}
+
+// ObserveQdrant records a Qdrant call; args describe the request for the slow
+// log and must not contain payload values.
+func ObserveQdrant(ctx context.Context, op string, start time.Time, err error, args ...any) {
+	d := time.Since(start)
+	qdrantDuration.WithLabelValues(op, Outcome(err)).Observe(d.Seconds())
+	logging.Slow(ctx, "qdrant call", logging.SlowThresholds.Qdrant, d, append([]any{"operation", op, "outcome", Outcome(err)}, args...)...)
+}
@@ -0,0 +1,429 @@
+// Package natsjs is a minimal NATS JetStream client speaking the NATS text
+// protocol: enough to fetch messages from a durable consumer, acknowledge
+// or redeliver them, and to publish. It does not do TLS or clustering.
+package natsjs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// responseGrace is how much longer than a fetch's expiry the server is
+// waited for.
+const responseGrace = 5 * time.Second
+
+// Config names the server and, for Dial, the durable pull consumer to read
+// from.
+type Config struct {
+	// URL is nats://[user:password@]host:port.
+	URL   string
+	Token string
+	// Stream and Consumer name the durable consumer, which is created on
+	// Subject when it does not exist.
+	Stream   string
+	Consumer string
+	Subject  string
+	// AckWait is how long the server waits for an ack before redelivering
+	// a message, and MaxDeliver how many times it delivers it at most.
+	AckWait    time.Duration
+	MaxDeliver int
+}
+
+// Msg is a message fetched from the consumer.
+type Msg struct {
+	Header textproto.MIMEHeader
+	Data   []byte
+	reply  string
+}
+
+// ackTokens splits the $JS.ACK reply subject, in its short
+// ($JS.ACK.stream.consumer.delivered.sseq...) or domain-qualified form.
+func (m *Msg) ackTokens() []string {
+	t := strings.Split(m.reply, ".")
+	if len(t) >= 12 {
+		t = append(t[:2], t[4:]...)
+	}
+	if len(t) < 9 {
+		return nil
+	}
+	return t
+}
+
+// ID identifies the message: its Nats-Msg-Id header when the publisher
+// set one, else its stream and sequence as "stream:seq".
+func (m *Msg) ID() string {
+	if id := m.Header.Get("Nats-Msg-Id"); id != "" {
+		return id
+	}
+	if t := m.ackTokens(); t != nil {
+		return t[2] + ":" + t[5]
+	}
+	return ""
+}
+
+// Delivered is how many times the message has been delivered, this time
+// included.
+func (m *Msg) Delivered() int {
+	if t := m.ackTokens(); t != nil {
+		n, _ := strconv.Atoi(t[4])
+		return n
+	}
+	return 1
+}
+
+// Conn is a connection to one NATS server. It is not safe for concurrent
+// use.
+type Conn struct {
+	cfg   Config
+	nc    net.Conn
+	r     *bufio.Reader
+	w     *bufio.Writer
+	inbox string
+	next  int
+}
+
+// Dial connects to cfg.URL and makes sure the durable consumer exists.
+func Dial(ctx context.Context, cfg Config) (*Conn, error) {
+	c, err := Connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.subscribe(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := c.ensureConsumer(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Connect connects to cfg.URL for publishing.
+func Connect(ctx context.Context, cfg Config) (*Conn, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Scheme != "nats" || u.Host == "" {
+		return nil, fmt.Errorf("invalid NATS URL %q", cfg.URL)
+	}
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{cfg: cfg, nc: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc), inbox: "_INBOX." + rand.Text()}
+	if err := c.handshake(ctx, u); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Conn) Close() error {
+	return c.nc.Close()
+}
+
+func (c *Conn) handshake(ctx context.Context, u *url.URL) error {
+	defer c.watch(ctx, time.Now().Add(10*time.Second))()
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+		Headers     bool `json:"headers"`
+	}
+	if !strings.HasPrefix(line, "INFO ") || json.Unmarshal([]byte(line[5:]), &info) != nil {
+		return fmt.Errorf("unexpected greeting %q", line)
+	}
+	if info.TLSRequired {
+		return errors.New("the NATS server requires TLS, which is not supported")
+	}
+	if !info.Headers {
+		return errors.New("the NATS server does not support headers")
+	}
+	opts := map[string]any{
+		"verbose": false, "pedantic": false, "name": "hadith-backend", "lang": "go", "version": "1",
+		"protocol": 1, "headers": true, "no_responders": true,
+	}
+	if u.User != nil {
+		opts["user"] = u.User.Username()
+		opts["pass"], _ = u.User.Password()
+	}
+	if c.cfg.Token != "" {
+		opts["auth_token"] = c.cfg.Token
+	}
+	b, _ := json.Marshal(opts)
+	fmt.Fprintf(c.w, "CONNECT %s\r\n", b)
+	return c.flush()
+}
+
+// flush sends what is buffered and waits for the server to have processed
+// it, which it reports by answering a ping.
+func (c *Conn) flush() error {
+	c.w.WriteString("PING\r\n")
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			c.w.WriteString("PONG\r\n")
+			if err := c.w.Flush(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.TrimSpace(line[4:]))
+		}
+	}
+}
+
+// subscribe subscribes to the replies to publish.
+func (c *Conn) subscribe(ctx context.Context) error {
+	defer c.watch(ctx, time.Now().Add(10*time.Second))()
+	fmt.Fprintf(c.w, "SUB %s.* 1\r\n", c.inbox)
+	return c.flush()
+}
+
+// ensureConsumer creates the durable consumer, which the server accepts
+// when it exists with the same configuration.
+func (c *Conn) ensureConsumer(ctx context.Context) error {
+	req := map[string]any{
+		"stream_name": c.cfg.Stream,
+		"config": map[string]any{
+			"durable_name":   c.cfg.Consumer,
+			"ack_policy":     "explicit",
+			"filter_subject": c.cfg.Subject,
+			"ack_wait":       c.cfg.AckWait.Nanoseconds(),
+			"max_deliver":    c.cfg.MaxDeliver,
+		},
+	}
+	reply, err := c.publish("$JS.API.CONSUMER.DURABLE.CREATE."+c.cfg.Stream+"."+c.cfg.Consumer, req)
+	if err != nil {
+		return err
+	}
+	defer c.watch(ctx, time.Now().Add(10*time.Second))()
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			return err
+		}
+		if f.subject != reply {
+			continue
+		}
+		if f.status == "503" {
+			return errors.New("nats: no responders; is JetStream enabled?")
+		}
+		var resp struct {
+			Error *struct {
+				Description string `json:"description"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(f.msg.Data, &resp); err != nil {
+			return fmt.Errorf("nats: consumer create: %w", err)
+		}
+		if resp.Error != nil {
+			return fmt.Errorf("nats: consumer create: %s", resp.Error.Description)
+		}
+		return nil
+	}
+}
+
+// Fetch asks for up to batch messages and returns those delivered before
+// wait passes; none is not an error.
+func (c *Conn) Fetch(ctx context.Context, batch int, wait time.Duration) ([]*Msg, error) {
+	reply, err := c.publish("$JS.API.CONSUMER.MSG.NEXT."+c.cfg.Stream+"."+c.cfg.Consumer,
+		map[string]any{"batch": batch, "expires": wait.Nanoseconds()})
+	if err != nil {
+		return nil, err
+	}
+	defer c.watch(ctx, time.Now().Add(wait+responseGrace))()
+	var msgs []*Msg
+	for len(msgs) < batch {
+		f, err := c.readFrame()
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() && (len(msgs) > 0 || ctx.Err() != nil) {
+			return msgs, ctx.Err()
+		}
+		if err != nil {
+			return msgs, err
+		}
+		switch {
+		case strings.HasPrefix(f.msg.reply, "$JS.ACK."):
+			// Messages of an earlier fetch that timed out are taken too.
+			msgs = append(msgs, f.msg)
+		case f.subject != reply:
+		case f.status == "404" || f.status == "408":
+			return msgs, nil
+		case f.status == "409":
+			if len(msgs) > 0 {
+				return msgs, nil
+			}
+			return nil, fmt.Errorf("nats: fetch: %s", f.description)
+		}
+	}
+	return msgs, nil
+}
+
+// Ack tells the server m is processed.
+func (c *Conn) Ack(m *Msg) error {
+	return c.respond(m, "+ACK")
+}
+
+// Nak has the server redeliver m after delay.
+func (c *Conn) Nak(m *Msg, delay time.Duration) error {
+	return c.respond(m, fmt.Sprintf(`-NAK {"delay":%d}`, delay.Nanoseconds()))
+}
+
+// Term tells the server never to redeliver m.
+func (c *Conn) Term(m *Msg) error {
+	return c.respond(m, "+TERM")
+}
+
+// Publish publishes data on subject with id as its Nats-Msg-Id, by which a
+// stream that stores the subject drops duplicates, and returns once the
+// server has taken it. It is not told whether anyone received it. Publish
+// must not be used on a connection opened by Dial.
+func (c *Conn) Publish(ctx context.Context, subject, id string, data []byte) error {
+	defer c.watch(ctx, time.Now().Add(10*time.Second))()
+	hdr := "NATS/1.0\r\nNats-Msg-Id: " + id + "\r\n\r\n"
+	fmt.Fprintf(c.w, "HPUB %s %d %d\r\n%s%s\r\n", subject, len(hdr), len(hdr)+len(data), hdr, data)
+	return c.flush()
+}
+
+func (c *Conn) respond(m *Msg, body string) error {
+	fmt.Fprintf(c.w, "PUB %s %d\r\n%s\r\n", m.reply, len(body), body)
+	return c.w.Flush()
+}
+
+// publish sends v as JSON with a fresh reply subject, which it returns.
+func (c *Conn) publish(subject string, v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	c.next++
+	reply := c.inbox + "." + strconv.Itoa(c.next)
+	fmt.Fprintf(c.w, "PUB %s %s %d\r\n%s\r\n", subject, reply, len(b), b)
+	return reply, c.w.Flush()
+}
+
+// watch makes reads fail once deadline passes or ctx is done, until the
+// returned func is called.
+func (c *Conn) watch(ctx context.Context, deadline time.Time) func() {
+	c.nc.SetReadDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { c.nc.SetReadDeadline(time.Now()) })
+	return func() {
+		stop()
+		c.nc.SetReadDeadline(time.Time{})
+	}
+}
+
+func (c *Conn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// frame is a message received on the inbox; status is set for the
+// JetStream status messages that carry no data, e.g. 404 No Messages.
+type frame struct {
+	subject     string
+	status      string
+	description string
+	msg         *Msg
+}
+
+// readFrame reads the next MSG or HMSG, answering pings on the way.
+func (c *Conn) readFrame() (frame, error) {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return frame{}, err
+		}
+		op, args, _ := strings.Cut(line, " ")
+		switch op {
+		case "PING":
+			c.w.WriteString("PONG\r\n")
+			if err := c.w.Flush(); err != nil {
+				return frame{}, err
+			}
+			continue
+		case "-ERR":
+			return frame{}, fmt.Errorf("nats: %s", args)
+		case "MSG", "HMSG":
+		default:
+			continue
+		}
+		// MSG subject sid [reply] size; HMSG subject sid [reply] hsize size
+		f := strings.Fields(args)
+		n := 3
+		if op == "HMSG" {
+			n = 4
+		}
+		if len(f) != n && len(f) != n+1 {
+			return frame{}, fmt.Errorf("nats: malformed %s", line)
+		}
+		fr := frame{subject: f[0], msg: &Msg{Header: textproto.MIMEHeader{}}}
+		if len(f) == n+1 {
+			fr.msg.reply = f[2]
+		}
+		size, err := strconv.Atoi(f[len(f)-1])
+		if err != nil {
+			return frame{}, fmt.Errorf("nats: malformed %s", line)
+		}
+		hsize := 0
+		if op == "HMSG" {
+			if hsize, err = strconv.Atoi(f[len(f)-2]); err != nil || hsize > size {
+				return frame{}, fmt.Errorf("nats: malformed %s", line)
+			}
+		}
+		body := make([]byte, size+2)
+		if _, err := io.ReadFull(c.r, body); err != nil {
+			return frame{}, err
+		}
+		if hsize > 0 {
+			fr.status, fr.description, fr.msg.Header, err = parseHeader(body[:hsize])
+			if err != nil {
+				return frame{}, err
+			}
+		}
+		fr.msg.Data = body[hsize:size]
+		return fr, nil
+	}
+}
+
+// parseHeader reads "NATS/1.0[ status description]" and the header lines
+// after it.
+func parseHeader(b []byte) (status, description string, h textproto.MIMEHeader, err error) {
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(b)))
+	first, err := r.ReadLine()
+	if err != nil || !strings.HasPrefix(first, "NATS/1.0") {
+		return "", "", nil, fmt.Errorf("nats: malformed header %q", first)
+	}
+	status, description, _ = strings.Cut(strings.TrimSpace(strings.TrimPrefix(first, "NATS/1.0")), " ")
+	h, err = r.ReadMIMEHeader()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", "", nil, err
+	}
+	return status, description, h, nil
+}
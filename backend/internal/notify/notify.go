@@ -0,0 +1,52 @@
+// Package notify delivers messages to subscribers through pluggable
+// channels: email over SMTP, push through Firebase Cloud Messaging and
+// signed webhooks.
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// ErrInvalidTarget is returned, wrapped, when the recipient is gone or was
+// never valid: an unknown mailbox, an expired push token or a webhook that
+// answers 410 Gone. Retrying does not help.
+var ErrInvalidTarget = errors.New("invalid target")
+
+// Message is one notification. Channels use the fields that fit them.
+type Message struct {
+	// ID identifies the delivery, e.g. for receivers to drop duplicates.
+	ID string
+	// To is an email address, an FCM registration token or a URL.
+	To      string
+	Subject string
+	Text    string
+	// Event names the kind of message for webhooks.
+	Event string
+	// Data is the JSON body of webhooks.
+	Data any
+	// Meta is the data payload of push notifications.
+	Meta map[string]string
+	// Secret signs webhooks.
+	Secret string
+	// UnsubscribeURL, when set, is offered in emails.
+	UnsubscribeURL string
+}
+
+// Channel sends messages to one kind of target.
+type Channel interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// Sign is the signature of a webhook body sent at timestamp (unix seconds):
+// sha256=<hex HMAC-SHA256 of "<timestamp>.<body>" keyed by secret>.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Webhook POSTs Data as JSON to the URL in To, with the headers of the
+// server's webhooks:
+//
+//	X-Webhook-Event:     <Event>
+//	X-Webhook-Delivery:  <ID>
+//	X-Webhook-Timestamp: <unix seconds>
+//	X-Webhook-Signature: see Sign
+type Webhook struct {
+	Client *http.Client
+}
+
+func (w *Webhook) Send(ctx context.Context, m Message) error {
+	body, err := json.Marshal(m.Data)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.To, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTarget, err)
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", m.Event)
+	req.Header.Set("X-Webhook-Delivery", m.ID)
+	req.Header.Set("X-Webhook-Timestamp", ts)
+	req.Header.Set("X-Webhook-Signature", Sign(m.Secret, ts, body))
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusGone:
+		return fmt.Errorf("%w: status %d", ErrInvalidTarget, resp.StatusCode)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/i18n"
+	"github.com/buugaaga/test-cursor/backend/internal/metrics"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Keyword finds hadiths by the words of their texts; store.Store is one.
+type Keyword interface {
+	SearchText(ctx context.Context, query string, limit int) ([]store.TextMatch, error)
+}
+
+// SetFallback answers semantic searches from k while the embedder or the
+// vector store fails.
+func (s *Service) SetFallback(k Keyword) {
+	s.fallback = k
+}
+
+// degradable reports failures of the embedder or the vector store, which
+// keyword search does not depend on.
+func degradable(err error) bool {
+	var apiErr *apierr.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == apierr.CodeEmbedderFailed || apiErr.Code == apierr.CodeVectorStoreFailed
+}
+
+// keywordSearch serves query from the fallback, with payloads shaped like
+// the indexed points'. When it fails too, the semantic error is returned.
+func (s *Service) keywordSearch(ctx context.Context, query string, limit int, boosts []Boost, cause error) (Results, error) {
+	matches, err := s.fallback.SearchText(ctx, query, candidates(limit, boosts))
+	if err != nil {
+		metrics.SearchFallbacks.WithLabelValues("error").Inc()
+		slog.ErrorContext(ctx, "search: keyword fallback failed", "cause", cause, "error", err)
+		return Results{}, cause
+	}
+	metrics.SearchFallbacks.WithLabelValues("ok").Inc()
+	slog.WarnContext(ctx, "search: degraded to keyword search", "cause", cause)
+	hits := make([]Hit, 0, len(matches))
+	for _, m := range matches {
+		hits = append(hits, Hit{ID: strconv.FormatInt(m.ID, 10), Score: m.Rank, Payload: hadithPayload(m.Hadith)})
+	}
+	return Results{Hits: rank(hits, limit, boosts), Degraded: true}, nil
+}
+
+func hadithPayload(h store.Hadith) map[string]*qdrant.Value {
+	text, lang := hadithText(h)
+	declared := ""
+	if detected := i18n.Detect(text); detected != "" && detected != lang {
+		lang, declared = detected, lang
+	}
+	fields := map[string]any{
+		"origin_type":     "hadith",
+		"origin_id":       h.ID,
+		"tenant_id":       h.TenantID,
+		"collection_code": h.CollectionCode,
+		"number":          h.Number,
+		"lang":            lang,
+		"title":           i18n.T(i18n.Default, "hadith.title", h.Number, h.CollectionCode),
+		"snippet":         text[:min(len(text), 280)],
+	}
+	if h.Grade != nil && *h.Grade != "" {
+		fields["grade"] = *h.Grade
+	}
+	if len(h.Ayahs) > 0 {
+		ayahs := make([]any, len(h.Ayahs))
+		for i, r := range h.Ayahs {
+			ayahs[i] = r.String()
+		}
+		fields["ayahs"] = ayahs
+	}
+	if declared != "" {
+		fields["declared_lang"] = declared
+	}
+	return qdrant.NewValueMap(fields)
+}
+
+// hadithText picks the text shown for h in the order ingest.PreferredText
+// indexes them: Russian, then English, then Arabic.
+func hadithText(h store.Hadith) (string, string) {
+	for _, t := range []struct {
+		text *string
+		lang string
+	}{{h.TextRu, "ru"}, {h.TextEn, "en"}, {h.TextAr, "ar"}} {
+		if t.text != nil && *t.text != "" {
+			return *t.text, t.lang
+		}
+	}
+	return "", ""
+}
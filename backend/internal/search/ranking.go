@@ -0,0 +1,183 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// boostCandidates is how many more points than asked for are fetched when
+// boost rules may reorder them.
+const boostCandidates = 3
+
+// Settings are the search tunables that can change while serving.
+type Settings struct {
+	// DefaultLimit replaces a missing limit; a limit above MaxLimit is
+	// refused. They apply to callers whose tier is not in Tiers.
+	DefaultLimit int
+	MaxLimit     int
+	Tiers        map[string]Limits
+	Boosts       []Boost
+}
+
+// Limits are the default and maximum limit of a tier of callers.
+type Limits struct {
+	Default int
+	Max     int
+}
+
+// Ranking overrides the configured ranking of a search, as experiment
+// variants do.
+type Ranking struct {
+	// Boosts replace the configured boost rules when not nil; empty turns
+	// boosting off.
+	Boosts []Boost
+	// MinScore drops hits scoring less once boosted.
+	MinScore float32
+}
+
+// Boost multiplies the score of hits whose payload Field holds Value, or
+// a list containing it.
+type Boost struct {
+	Field  string
+	Value  string
+	Factor float32
+}
+
+// ParseTierLimits reads "tier=default/max,..." such as "free=5/20,partner=20/200".
+func ParseTierLimits(s string) (map[string]Limits, error) {
+	tiers := map[string]Limits{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		tier, limits, ok := strings.Cut(part, "=")
+		def, maxLimit, ok2 := strings.Cut(limits, "/")
+		if !ok || !ok2 || strings.TrimSpace(tier) == "" {
+			return nil, fmt.Errorf("%q: want tier=default/max", part)
+		}
+		d, err := strconv.Atoi(strings.TrimSpace(def))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%q: invalid default", part)
+		}
+		m, err := strconv.Atoi(strings.TrimSpace(maxLimit))
+		if err != nil || m < d {
+			return nil, fmt.Errorf("%q: invalid max", part)
+		}
+		tiers[strings.TrimSpace(tier)] = Limits{Default: d, Max: m}
+	}
+	return tiers, nil
+}
+
+// ParseBoosts reads "field:value=factor,..." such as
+// "grade:sahih=1.2,collection_code:bukhari=1.1".
+func ParseBoosts(s string) ([]Boost, error) {
+	var boosts []Boost
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		match, factor, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q: want field:value=factor", part)
+		}
+		field, value, ok := strings.Cut(match, ":")
+		if !ok || field == "" || value == "" {
+			return nil, fmt.Errorf("%q: want field:value=factor", part)
+		}
+		f, err := strconv.ParseFloat(factor, 32)
+		if err != nil || f <= 0 {
+			return nil, fmt.Errorf("%q: invalid factor", part)
+		}
+		boosts = append(boosts, Boost{Field: strings.TrimSpace(field), Value: strings.TrimSpace(value), Factor: float32(f)})
+	}
+	return boosts, nil
+}
+
+// SetSettings puts st into effect for the searches that follow.
+func (s *Service) SetSettings(st Settings) {
+	s.settings.Store(&st)
+}
+
+type tierKey struct{}
+
+// WithTier marks searches made with ctx as the API key tier's, for their
+// limits.
+func WithTier(ctx context.Context, tier string) context.Context {
+	return context.WithValue(ctx, tierKey{}, tier)
+}
+
+// Limit returns limit, or the default limit of the caller's tier for 0. A
+// limit below 1 or above the tier's maximum is an invalid_argument error
+// rather than being clamped.
+func (s *Service) Limit(ctx context.Context, limit int) (int, error) {
+	st := s.settings.Load()
+	l := Limits{Default: st.DefaultLimit, Max: st.MaxLimit}
+	if tier, ok := ctx.Value(tierKey{}).(string); ok {
+		if t, ok := st.Tiers[tier]; ok {
+			l = t
+		}
+	}
+	switch {
+	case limit == 0:
+		return l.Default, nil
+	case limit < 0:
+		return 0, apierr.InvalidField("limit", "gte", "1")
+	case limit > l.Max:
+		return 0, apierr.InvalidField("limit", "lte", strconv.Itoa(l.Max))
+	}
+	return limit, nil
+}
+
+// candidates is how many points to fetch for limit hits under boosts.
+func candidates(limit int, boosts []Boost) int {
+	if len(boosts) == 0 {
+		return limit
+	}
+	return limit * boostCandidates
+}
+
+// rank applies boosts to hits, reorders them by the boosted score and keeps
+// the best limit.
+func rank(hits []Hit, limit int, boosts []Boost) []Hit {
+	if len(boosts) > 0 {
+		for i := range hits {
+			for _, b := range boosts {
+				if payloadHas(hits[i].Payload[b.Field], b.Value) {
+					hits[i].Score *= b.Factor
+				}
+			}
+		}
+		slices.SortStableFunc(hits, func(a, b Hit) int {
+			switch {
+			case a.Score > b.Score:
+				return -1
+			case a.Score < b.Score:
+				return 1
+			}
+			return 0
+		})
+	}
+	return hits[:min(len(hits), limit)]
+}
+
+func payloadHas(v *qdrant.Value, want string) bool {
+	switch k := v.GetKind().(type) {
+	case *qdrant.Value_StringValue:
+		return strings.EqualFold(k.StringValue, want)
+	case *qdrant.Value_IntegerValue:
+		return strconv.FormatInt(k.IntegerValue, 10) == want
+	case *qdrant.Value_BoolValue:
+		return strconv.FormatBool(k.BoolValue) == want
+	case *qdrant.Value_ListValue:
+		return slices.ContainsFunc(k.ListValue.GetValues(), func(item *qdrant.Value) bool { return payloadHas(item, want) })
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+// Package search answers semantic queries from the Qdrant collection.
+package search
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/buugaaga/test-cursor/backend/internal/apierr"
+	"github.com/buugaaga/test-cursor/backend/internal/cache"
+	"github.com/buugaaga/test-cursor/backend/internal/embed"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/vector"
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/protobuf/proto"
+)
+
+// Service embeds queries with embedder and searches index, caching semantic
+// search results in cache.
+type Service struct {
+	index    vector.Index
+	embedder embed.Embedder
+	cache    *cache.ResultCache
+	settings atomic.Pointer[Settings]
+	shadow   *Shadow
+	fallback Keyword
+	// shadowSlots bounds the mirrored searches in flight.
+	shadowSlots chan struct{}
+}
+
+func New(index vector.Index, e embed.Embedder, c *cache.ResultCache, st Settings) *Service {
+	s := &Service{index: index, embedder: e, cache: c}
+	s.SetSettings(st)
+	return s
+}
+
+// Hit is a point returned by a search with its payload.
+type Hit struct {
+	ID      string
+	Score   float32
+	Payload map[string]*qdrant.Value
+}
+
+// Results are the hits of a semantic search. Degraded marks keyword matches
+// served because the embedder or vector store failed.
+type Results struct {
+	Hits     []Hit
+	Degraded bool
+}
+
+// Semantic embeds query and returns the closest points, reordered by the
+// boost rules. If that fails and a fallback is set, it returns the
+// fallback's keyword matches instead. Errors are APIErrors, ready to be
+// returned to clients.
+//
+// Transliterated Arabic terms are embedded in one spelling, so "solat" finds
+// what "salah" does.
+func (s *Service) Semantic(ctx context.Context, query string, limit int) (Results, error) {
+	return s.SemanticRanked(ctx, query, limit, nil)
+}
+
+// SemanticRanked is Semantic with the ranking of r instead of the
+// configured one, when r is not nil.
+func (s *Service) SemanticRanked(ctx context.Context, query string, limit int, r *Ranking) (Results, error) {
+	boosts := s.settings.Load().Boosts
+	if r != nil && r.Boosts != nil {
+		boosts = r.Boosts
+	}
+	fetch := candidates(limit, boosts)
+	normalized := store.NormalizeTranslit(query)
+	hits, err := cache.Cached(ctx, s.cache, "search", cacheKey(normalized, fetch), func() ([]cachedHit, error) {
+		embeds, err := s.embedder.Embed(embed.AsQuery(ctx), []string{normalized})
+		if err != nil {
+			return nil, embed.APIError(err)
+		}
+		if len(embeds) == 0 {
+			return nil, apierr.Embedder("no embedding returned")
+		}
+		hits, err := s.ByVector(ctx, embeds[0], fetch, nil)
+		if err != nil {
+			return nil, err
+		}
+		return encodeHits(hits)
+	})
+	if err != nil {
+		if s.fallback != nil && degradable(err) {
+			return s.keywordSearch(ctx, query, limit, boosts, err)
+		}
+		return Results{}, err
+	}
+	decoded, err := decodeHits(hits)
+	if err != nil {
+		return Results{}, err
+	}
+	decoded = rank(decoded, limit, boosts)
+	s.mirror(ctx, normalized, limit, boosts, decoded)
+	if r != nil && r.MinScore > 0 {
+		decoded = slices.DeleteFunc(decoded, func(h Hit) bool { return h.Score < r.MinScore })
+	}
+	return Results{Hits: decoded}, nil
+}
+
+// ByVector returns the points of the tenant of ctx closest to vec that match
+// filter.
+func (s *Service) ByVector(ctx context.Context, vec []float32, limit int, filter *qdrant.Filter) ([]Hit, error) {
+	points, err := s.index.Search(ctx, vec, limit, vector.ForTenant(ctx, filter))
+	if err != nil {
+		return nil, apierr.VectorStore("qdrant search failed")
+	}
+
+	hits := make([]Hit, 0, len(points))
+	for _, r := range points {
+		hits = append(hits, Hit{ID: vector.PointID(r.Id), Score: r.Score, Payload: r.Payload})
+	}
+	return hits, nil
+}
+
+// Similar finds hadiths whose vectors are closest to the indexed vector of
+// hadith id, excluding the hadith itself.
+func (s *Service) Similar(ctx context.Context, id int64, limit int) ([]Hit, error) {
+	vec, err := s.index.HadithVector(ctx, id)
+	if err != nil {
+		return nil, apierr.VectorStore("qdrant scroll failed")
+	}
+	if len(vec) == 0 {
+		return []Hit{}, nil
+	}
+	return s.ByVector(ctx, vec, limit, &qdrant.Filter{
+		Must:    []*qdrant.Condition{qdrant.NewMatch("origin_type", "hadith")},
+		MustNot: []*qdrant.Condition{qdrant.NewMatchInt("origin_id", id)},
+	})
+}
+
+// cacheKey hashes the query so arbitrary input makes a bounded key.
+func cacheKey(query string, limit int) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(query))))
+	return strconv.Itoa(limit) + ":" + hex.EncodeToString(sum[:])
+}
+
+// cachedHit is a Hit with its payload values protobuf-encoded, so they
+// come back from the cache with their exact Qdrant types.
+type cachedHit struct {
+	ID      string            `json:"id"`
+	Score   float32           `json:"score"`
+	Payload map[string][]byte `json:"payload"`
+}
+
+func encodeHits(hits []Hit) ([]cachedHit, error) {
+	out := make([]cachedHit, 0, len(hits))
+	for _, h := range hits {
+		ch := cachedHit{ID: h.ID, Score: h.Score, Payload: make(map[string][]byte, len(h.Payload))}
+		for k, v := range h.Payload {
+			b, err := proto.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			ch.Payload[k] = b
+		}
+		out = append(out, ch)
+	}
+	return out, nil
+}
+
+func decodeHits(cached []cachedHit) ([]Hit, error) {
+	out := make([]Hit, 0, len(cached))
+	for _, ch := range cached {
+		h := Hit{ID: ch.ID, Score: ch.Score, Payload: make(map[string]*qdrant.Value, len(ch.Payload))}
+		for k, b := range ch.Payload {
+			v := &qdrant.Value{}
+			if err := proto.Unmarshal(b, v); err != nil {
+				return nil, err
+			}
+			h.Payload[k] = v
+		}
+		out = append(out, h)
+	}
+	return out, nil
+}
@@ -0,0 +1,116 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/buugaaga/test-cursor/backend/internal/cache"
+	"github.com/buugaaga/test-cursor/backend/internal/embed/embedtest"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/store/storetest"
+	"github.com/buugaaga/test-cursor/backend/internal/vector/vectortest"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+const testDim = 16
+
+var testTexts = map[int64]string{
+	1: "Actions are judged by intentions.",
+	2: "Whoever believes in Allah and the Last Day should speak good or keep silent.",
+	3: "The strong man is the one who controls himself when angry.",
+}
+
+// newTestService indexes testTexts the way ingest does, embedding the
+// same normalized text a query is embedded from.
+func newTestService(t *testing.T) (*Service, *vectortest.Index, *embedtest.Embedder) {
+	t.Helper()
+	index := &vectortest.Index{}
+	embedder := &embedtest.Embedder{Dim: testDim}
+	var points []*qdrant.PointStruct
+	for id, text := range testTexts {
+		points = append(points, &qdrant.PointStruct{
+			Id:      qdrant.NewIDNum(uint64(id)),
+			Vectors: qdrant.NewVectorsDense(embedtest.Vector(store.NormalizeTranslit(text), testDim)),
+			Payload: qdrant.NewValueMap(map[string]any{"origin_type": "hadith", "origin_id": id}),
+		})
+	}
+	if err := index.Upsert(context.Background(), points); err != nil {
+		t.Fatal(err)
+	}
+	return New(index, embedder, cache.NewResultCache(nil, nil), Settings{DefaultLimit: 10, MaxLimit: 100}), index, embedder
+}
+
+func originIDs(hits []Hit) []int64 {
+	ids := make([]int64, 0, len(hits))
+	for _, h := range hits {
+		ids = append(ids, h.Payload["origin_id"].GetIntegerValue())
+	}
+	return ids
+}
+
+func TestSemanticFindsClosestHadith(t *testing.T) {
+	s, _, embedder := newTestService(t)
+	for id, text := range testTexts {
+		res, err := s.Semantic(context.Background(), text, 2)
+		if err != nil {
+			t.Fatalf("search %q: %v", text, err)
+		}
+		if ids := originIDs(res.Hits); len(ids) != 2 || ids[0] != id {
+			t.Errorf("search %q: hits %v, want 2 with hadith %d first", text, ids, id)
+		}
+		if res.Degraded {
+			t.Errorf("search %q: degraded", text)
+		}
+	}
+	if n := len(embedder.Calls()); n != len(testTexts) {
+		t.Errorf("embedded %d times, want %d", n, len(testTexts))
+	}
+}
+
+func TestSemanticFallsBackToKeywords(t *testing.T) {
+	s, index, _ := newTestService(t)
+	index.Err = errors.New("qdrant down")
+
+	if _, err := s.Semantic(context.Background(), "intentions", 5); err == nil {
+		t.Fatal("search without a fallback succeeded while the index was down")
+	}
+
+	kw := &storetest.Store{}
+	for id, text := range testTexts {
+		kw.AddHadith(store.Hadith{ID: id, CollectionCode: "bukhari", Number: "1", TextEn: &text})
+	}
+	s.SetFallback(kw)
+	res, err := s.Semantic(context.Background(), "judged by intentions", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Degraded {
+		t.Error("keyword results are not marked degraded")
+	}
+	if len(res.Hits) != 1 || res.Hits[0].ID != "1" {
+		t.Errorf("hits %+v, want hadith 1 only", res.Hits)
+	}
+}
+
+func TestSimilarExcludesHadith(t *testing.T) {
+	s, _, _ := newTestService(t)
+	hits, err := s.Similar(context.Background(), 1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := originIDs(hits)
+	if len(ids) != len(testTexts)-1 {
+		t.Fatalf("hits %v, want every other hadith", ids)
+	}
+	for _, id := range ids {
+		if id == 1 {
+			t.Errorf("hits %v include the hadith itself", ids)
+		}
+	}
+
+	hits, err = s.Similar(context.Background(), 99, 5)
+	if err != nil || len(hits) != 0 {
+		t.Errorf("unindexed hadith: hits %v, err %v, want none", hits, err)
+	}
+}
@@ -0,0 +1,129 @@
+package search
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/embed"
+	"github.com/buugaaga/test-cursor/backend/internal/metrics"
+	"github.com/buugaaga/test-cursor/backend/internal/vector"
+)
+
+const (
+	maxShadowSearches = 4
+	shadowTimeout     = 30 * time.Second
+)
+
+// Shadow is the embedder and index of a candidate embedding model. A sample
+// of semantic searches is repeated against it in the background and the
+// results are compared with the primary ones; clients only ever see the
+// primary results.
+type Shadow struct {
+	Embedder embed.Embedder
+	Index    vector.Index
+	// SampleRate is the share of searches mirrored, from 0 to 1.
+	SampleRate float64
+}
+
+// SetShadow starts mirroring searches to sh.
+func (s *Service) SetShadow(sh *Shadow) {
+	s.shadow = sh
+	s.shadowSlots = make(chan struct{}, maxShadowSearches)
+}
+
+// mirror repeats a search against the shadow and records how its results
+// compare with primary, both ranked by boosts. Searches beyond
+// maxShadowSearches in flight are dropped rather than queued.
+func (s *Service) mirror(ctx context.Context, query string, limit int, boosts []Boost, primary []Hit) {
+	if s.shadow == nil || rand.Float64() >= s.shadow.SampleRate {
+		return
+	}
+	select {
+	case s.shadowSlots <- struct{}{}:
+	default:
+		metrics.ShadowSearches.WithLabelValues("dropped").Inc()
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer func() { <-s.shadowSlots }()
+		ctx, cancel := context.WithTimeout(ctx, shadowTimeout)
+		defer cancel()
+
+		start := time.Now()
+		shadow, err := s.shadowSearch(ctx, query, limit, boosts)
+		metrics.ShadowSearchDuration.Observe(time.Since(start).Seconds())
+		if err != nil {
+			metrics.ShadowSearches.WithLabelValues("error").Inc()
+			slog.WarnContext(ctx, "shadow search failed", "error", err)
+			return
+		}
+		overlap, topMatch := compareHits(primary, shadow)
+		metrics.ShadowSearches.WithLabelValues("compared").Inc()
+		metrics.ShadowSearchOverlap.Observe(overlap)
+		metrics.ShadowSearchTopMatch.WithLabelValues(strconv.FormatBool(topMatch)).Inc()
+		slog.InfoContext(ctx, "shadow search compared",
+			"limit", limit,
+			"primary_hits", len(primary),
+			"shadow_hits", len(shadow),
+			"overlap", overlap,
+			"top_match", topMatch,
+			"primary_top_score", topScore(primary),
+			"shadow_top_score", topScore(shadow),
+			"shadow_duration", time.Since(start))
+	}()
+}
+
+func (s *Service) shadowSearch(ctx context.Context, query string, limit int, boosts []Boost) ([]Hit, error) {
+	embeds, err := s.shadow.Embedder.Embed(embed.AsQuery(ctx), []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(embeds) == 0 {
+		return nil, nil
+	}
+	points, err := s.shadow.Index.Search(ctx, embeds[0], candidates(limit, boosts), vector.ForTenant(ctx, nil))
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]Hit, 0, len(points))
+	for _, r := range points {
+		hits = append(hits, Hit{ID: vector.PointID(r.Id), Score: r.Score, Payload: r.Payload})
+	}
+	return rank(hits, limit, boosts), nil
+}
+
+// compareHits compares two result lists by hadith, since the two indexes
+// give the same hadith different point ids. overlap is the share of
+// primary's hadiths that shadow also returned.
+func compareHits(primary, shadow []Hit) (overlap float64, topMatch bool) {
+	if len(primary) == 0 {
+		return 1, len(shadow) == 0
+	}
+	found := make(map[int64]bool, len(shadow))
+	for _, h := range shadow {
+		found[hitOrigin(h)] = true
+	}
+	common := 0
+	for _, h := range primary {
+		if found[hitOrigin(h)] {
+			common++
+		}
+	}
+	topMatch = len(shadow) > 0 && hitOrigin(primary[0]) == hitOrigin(shadow[0])
+	return float64(common) / float64(len(primary)), topMatch
+}
+
+func hitOrigin(h Hit) int64 {
+	return h.Payload["origin_id"].GetIntegerValue()
+}
+
+func topScore(hits []Hit) float32 {
+	if len(hits) == 0 {
+		return 0
+	}
+	return hits[0].Score
+}
@@ -0,0 +1,394 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/quran"
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// HadithColumns selects a store.Hadith from hadiths h joined with
+// hadith_collections c, in ScanHadith order.
+const HadithColumns = `h.id, c.code, h.number, h.title, h.text_ar, h.text_ru, h.text_en, h.text_translit, h.grade, h.topics, h.updated_at, h.tenant_id, h.book, h.book_title, h.chapter, h.chapter_title, h.sequence,
+  ARRAY(SELECT r.surah || ':' || r.ayah FROM hadith_ayah_refs r WHERE r.hadith_id = h.id ORDER BY r.surah, r.ayah)`
+
+// HadithNumberKey is the leading integer of h.number, or 0. Ordering by it
+// and then by the full string sorts composite numbers such as "12", "12a",
+// "13" naturally. Integers too long for bigint count as the largest one.
+const HadithNumberKey = `COALESCE(CASE WHEN length(ltrim(substring(h.number from '^[0-9]+'), '0')) > 18 THEN 9223372036854775807
+  ELSE substring(h.number from '^[0-9]+')::bigint END, 0)`
+
+const hadithNumberNorm = `lower(regexp_replace(h.number, '\s', '', 'g'))`
+
+const hadithFilterWhere = `($1 = '' OR c.code = $1) AND ($2 = '' OR h.grade = $2) AND ($3 = 0 OR h.tenant_id = $3) AND ($4 = '' OR $4 = ANY(h.topics)) AND h.publish_at IS NULL`
+
+// TenantWhere is the condition that alias.tenant_id belongs to the tenant
+// passed as parameter $n; tenant.All matches every tenant.
+func TenantWhere(alias string, n int) string {
+	return fmt.Sprintf("($%d = 0 OR %s.tenant_id = $%d)", n, alias, n)
+}
+
+// Published is the condition that alias is live: hadiths scheduled for a
+// later publish_at are hidden from every read until they are published.
+func Published(alias string) string {
+	return alias + ".publish_at IS NULL"
+}
+
+func ScanHadith(row pgx.Row, extra ...any) (store.Hadith, error) {
+	var h store.Hadith
+	var ayahs []string
+	err := row.Scan(append([]any{&h.ID, &h.CollectionCode, &h.Number, &h.Title, &h.TextAr, &h.TextRu, &h.TextEn, &h.TextTranslit, &h.Grade, &h.Topics, &h.UpdatedAt, &h.TenantID, &h.Book, &h.BookTitle, &h.Chapter, &h.ChapterTitle, &h.Sequence, &ayahs}, extra...)...)
+	for _, a := range ayahs {
+		if r, err := quran.Parse(a); err == nil {
+			h.Ayahs = append(h.Ayahs, r)
+		}
+	}
+	return h, err
+}
+
+var _ store.Store = (*Store)(nil)
+
+// Store is the store.Store over the pool.
+type Store struct {
+	db *pgxpool.Pool
+}
+
+func NewStore(db *pgxpool.Pool) *Store {
+	return &Store{db: db}
+}
+
+func notFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	return err
+}
+
+func (s *Store) Hadith(ctx context.Context, id int64) (store.Hadith, error) {
+	h, err := ScanHadith(s.db.QueryRow(ctx, `
+SELECT `+HadithColumns+`
+FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+WHERE h.id = $1 AND `+Published("h")+` AND `+TenantWhere("h", 2), id, tenant.From(ctx)))
+	return h, notFound(err)
+}
+
+func (s *Store) Hadiths(ctx context.Context, ids []int64) ([]store.Hadith, []int64, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT `+HadithColumns+`
+FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+WHERE h.id = ANY($1) AND `+Published("h")+` AND `+TenantWhere("h", 2), ids, tenant.From(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	byID := make(map[int64]store.Hadith, len(ids))
+	for rows.Next() {
+		h, err := ScanHadith(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		byID[h.ID] = h
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	hadiths := make([]store.Hadith, 0, len(byID))
+	missing := []int64{}
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if h, ok := byID[id]; ok {
+			hadiths = append(hadiths, h)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return hadiths, missing, nil
+}
+
+func (s *Store) Collections(ctx context.Context) ([]store.Collection, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT c.id, c.code, c.title, COUNT(h.id), c.tenant_id
+FROM hadith_collections c LEFT JOIN hadiths h ON h.collection_id = c.id AND `+Published("h")+`
+WHERE `+TenantWhere("c", 1)+`
+GROUP BY c.id
+ORDER BY c.code, c.tenant_id`, tenant.From(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	collections := []store.Collection{}
+	for rows.Next() {
+		var col store.Collection
+		if err := rows.Scan(&col.ID, &col.Code, &col.Title, &col.HadithCount, &col.TenantID); err != nil {
+			return nil, err
+		}
+		collections = append(collections, col)
+	}
+	return collections, rows.Err()
+}
+
+func (s *Store) Collection(ctx context.Context, code string) (store.Collection, error) {
+	var col store.Collection
+	err := s.db.QueryRow(ctx, `
+SELECT c.id, c.code, c.title, (SELECT COUNT(*) FROM hadiths h WHERE h.collection_id = c.id AND `+Published("h")+`), c.tenant_id
+FROM hadith_collections c
+WHERE c.code = $1 AND `+TenantWhere("c", 2)+`
+ORDER BY c.tenant_id
+LIMIT 1`, code, tenant.From(ctx)).Scan(&col.ID, &col.Code, &col.Title, &col.HadithCount, &col.TenantID)
+	return col, notFound(err)
+}
+
+func (s *Store) CollectionHadiths(ctx context.Context, code string, limit int, desc bool, cursor *store.HadithCursor) ([]store.Hadith, *store.HadithCursor, error) {
+	cmp, order := ">", "ASC"
+	if desc {
+		cmp, order = "<", "DESC"
+	}
+	args := []any{code, limit + 1, tenant.From(ctx)}
+	where := ""
+	if cursor != nil {
+		where = fmt.Sprintf(`AND (%s, h.number, h.id) %s ($4, $5, $6)`, HadithNumberKey, cmp)
+		args = append(args, cursor.NumberKey, cursor.Number, cursor.ID)
+	}
+	rows, err := s.db.Query(ctx, fmt.Sprintf(`
+SELECT %s, %s
+FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+WHERE c.code = $1 AND %s AND %s %s
+ORDER BY %s %s, h.number %s, h.id %s
+LIMIT $2`, HadithColumns, HadithNumberKey, Published("h"), TenantWhere("c", 3), where, HadithNumberKey, order, order, order), args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	hadiths := []store.Hadith{}
+	var next *store.HadithCursor
+	var lastKey int64
+	for rows.Next() {
+		var key int64
+		h, err := ScanHadith(rows, &key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(hadiths) == limit {
+			last := hadiths[len(hadiths)-1]
+			next = &store.HadithCursor{NumberKey: lastKey, Number: last.Number, ID: last.ID}
+			break
+		}
+		hadiths = append(hadiths, h)
+		lastKey = key
+	}
+	return hadiths, next, rows.Err()
+}
+
+func (s *Store) Books(ctx context.Context, code string) ([]store.Book, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT h.book, (array_agg(h.book_title ORDER BY h.id) FILTER (WHERE h.book_title IS NOT NULL))[1], COUNT(DISTINCT h.chapter), COUNT(*)
+FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+WHERE c.code = $1 AND h.book IS NOT NULL AND `+Published("h")+` AND `+TenantWhere("c", 2)+`
+GROUP BY h.book
+ORDER BY h.book`, code, tenant.From(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	books := []store.Book{}
+	for rows.Next() {
+		var b store.Book
+		if err := rows.Scan(&b.Number, &b.Title, &b.ChapterCount, &b.HadithCount); err != nil {
+			return nil, err
+		}
+		books = append(books, b)
+	}
+	return books, rows.Err()
+}
+
+func (s *Store) Chapters(ctx context.Context, code string, book int) ([]store.Chapter, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT h.chapter, (array_agg(h.chapter_title ORDER BY h.id) FILTER (WHERE h.chapter_title IS NOT NULL))[1], COUNT(*)
+FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+WHERE c.code = $1 AND h.book = $2 AND h.chapter IS NOT NULL AND `+Published("h")+` AND `+TenantWhere("c", 3)+`
+GROUP BY h.chapter
+ORDER BY h.chapter`, code, book, tenant.From(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	chapters := []store.Chapter{}
+	for rows.Next() {
+		var ch store.Chapter
+		if err := rows.Scan(&ch.Number, &ch.Title, &ch.HadithCount); err != nil {
+			return nil, err
+		}
+		chapters = append(chapters, ch)
+	}
+	return chapters, rows.Err()
+}
+
+// bookOrder orders the hadiths of a book as printed; hadiths without a
+// chapter or sequence come first.
+const bookOrder = `COALESCE(h.chapter, 0), COALESCE(h.sequence, 0), ` + HadithNumberKey + `, h.number, h.id`
+
+func (s *Store) BookHadiths(ctx context.Context, code string, book, chapter, limit int, cursor *store.BookCursor) ([]store.Hadith, *store.BookCursor, error) {
+	args := []any{code, book, chapter, limit + 1, tenant.From(ctx)}
+	where := ""
+	if cursor != nil {
+		where = `AND (` + bookOrder + `) > ($6, $7, $8, $9, $10)`
+		args = append(args, cursor.Chapter, cursor.Sequence, cursor.NumberKey, cursor.Number, cursor.ID)
+	}
+	rows, err := s.db.Query(ctx, `
+SELECT `+HadithColumns+`, `+HadithNumberKey+`
+FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+WHERE c.code = $1 AND h.book = $2 AND ($3 = 0 OR h.chapter = $3) AND `+Published("h")+` AND `+TenantWhere("c", 5)+` `+where+`
+ORDER BY `+bookOrder+`
+LIMIT $4`, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	hadiths := []store.Hadith{}
+	var next *store.BookCursor
+	var lastKey int64
+	for rows.Next() {
+		var key int64
+		h, err := ScanHadith(rows, &key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(hadiths) == limit {
+			last := hadiths[len(hadiths)-1]
+			next = &store.BookCursor{
+				Chapter:      orZero(last.Chapter),
+				Sequence:     orZero(last.Sequence),
+				HadithCursor: store.HadithCursor{NumberKey: lastKey, Number: last.Number, ID: last.ID},
+			}
+			break
+		}
+		hadiths = append(hadiths, h)
+		lastKey = key
+	}
+	return hadiths, next, rows.Err()
+}
+
+func orZero(n *int) int {
+	if n == nil {
+		return 0
+	}
+	return *n
+}
+
+func (s *Store) HadithByNumber(ctx context.Context, code, number string) (store.Hadith, error) {
+	h, err := ScanHadith(s.db.QueryRow(ctx, `
+SELECT `+HadithColumns+`
+FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+WHERE c.code = $1 AND `+hadithNumberNorm+` = $2 AND `+Published("h")+` AND `+TenantWhere("c", 3)+`
+ORDER BY h.id
+LIMIT 1`, code, store.NormalizeNumber(number), tenant.From(ctx)))
+	return h, notFound(err)
+}
+
+func (s *Store) CompositeNumbers(ctx context.Context, code, number string) ([]string, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT h.number
+FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+WHERE c.code = $1 AND `+hadithNumberNorm+` ~ ('^' || $2 || '[a-z]+$') AND `+Published("h")+` AND `+TenantWhere("c", 3)+`
+ORDER BY h.number`, code, regexp.QuoteMeta(store.NormalizeNumber(number)), tenant.From(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	candidates := []string{}
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, n)
+	}
+	return candidates, rows.Err()
+}
+
+func (s *Store) CollectionExists(ctx context.Context, code string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM hadith_collections c WHERE c.code = $1 AND `+TenantWhere("c", 2)+`)`, code, tenant.From(ctx)).Scan(&exists)
+	return exists, err
+}
+
+func (s *Store) CollectionsVersion(ctx context.Context, code string) (string, error) {
+	var collections, hadiths int64
+	var collectionsAt, hadithsAt *time.Time
+	err := s.db.QueryRow(ctx, `
+SELECT
+  (SELECT COUNT(*) FROM hadith_collections c WHERE ($1 = '' OR c.code = $1) AND `+TenantWhere("c", 2)+`),
+  (SELECT MAX(c.updated_at) FROM hadith_collections c WHERE ($1 = '' OR c.code = $1) AND `+TenantWhere("c", 2)+`),
+  COUNT(h.id),
+  MAX(h.updated_at)
+FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+WHERE ($1 = '' OR c.code = $1) AND `+Published("h")+` AND `+TenantWhere("c", 2), code, tenant.From(ctx)).Scan(&collections, &collectionsAt, &hadiths, &hadithsAt)
+	if err != nil {
+		return "", err
+	}
+	unix := func(t *time.Time) int64 {
+		if t == nil {
+			return 0
+		}
+		return t.UnixNano()
+	}
+	return fmt.Sprintf("%d.%d.%d.%d", collections, unix(collectionsAt), hadiths, unix(hadithsAt)), nil
+}
+
+func (s *Store) CountHadiths(ctx context.Context, f store.HadithFilter) (int64, error) {
+	var n int64
+	err := s.db.QueryRow(ctx, `
+SELECT COUNT(*)
+FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+WHERE `+hadithFilterWhere, f.Collection, f.Grade, tenant.From(ctx), f.Topic).Scan(&n)
+	return n, err
+}
+
+func (s *Store) NthHadith(ctx context.Context, f store.HadithFilter, n int64) (store.Hadith, error) {
+	h, err := ScanHadith(s.db.QueryRow(ctx, `
+SELECT `+HadithColumns+`
+FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id
+WHERE `+hadithFilterWhere+`
+ORDER BY h.id
+OFFSET $5 LIMIT 1`, f.Collection, f.Grade, tenant.From(ctx), f.Topic, n))
+	return h, notFound(err)
+}
+
+func (s *Store) SearchText(ctx context.Context, query string, limit int) ([]store.TextMatch, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT `+HadithColumns+`, greatest(ts_rank(h.text_search, q), ts_rank(h.translit_search, q)) AS rank
+FROM hadiths h JOIN hadith_collections c ON c.id = h.collection_id,
+  websearch_to_tsquery('simple', $1) || websearch_to_tsquery('simple', $4) q
+WHERE (h.text_search @@ q OR h.translit_search @@ q) AND `+Published("h")+` AND `+TenantWhere("h", 3)+`
+ORDER BY rank DESC, h.id
+LIMIT $2`, query, limit, tenant.From(ctx), store.NormalizeTranslit(query))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	matches := []store.TextMatch{}
+	for rows.Next() {
+		var m store.TextMatch
+		h, err := ScanHadith(rows, &m.Rank)
+		if err != nil {
+			return nil, err
+		}
+		m.Hadith = h
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
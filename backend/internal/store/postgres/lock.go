@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Lock is a session-level advisory lock: one connection holds it across all
+// replicas until Release or until the connection is lost.
+type Lock struct {
+	name string
+	key  int64
+	conn *pgxpool.Conn
+}
+
+// LockKey maps a lock name to an advisory lock key.
+func LockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(appPrefix + name))
+	return int64(h.Sum64())
+}
+
+// TryLock takes lock name if it is free and returns nil if another session
+// holds it.
+func TryLock(ctx context.Context, pool *pgxpool.Pool, name string) (*Lock, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ok bool
+	key := LockKey(name)
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&ok); err != nil || !ok {
+		conn.Release()
+		return nil, err
+	}
+	return &Lock{name: name, key: key, conn: conn}, nil
+}
+
+// AcquireLock waits for lock name until ctx is done.
+func AcquireLock(ctx context.Context, pool *pgxpool.Pool, name string) (*Lock, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := LockKey(name)
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, key); err != nil {
+		// The wait may have been interrupted after the lock was granted.
+		conn.Conn().Close(context.Background())
+		conn.Release()
+		return nil, err
+	}
+	return &Lock{name: name, key: key, conn: conn}, nil
+}
+
+// Held reports whether the connection holding the lock is still alive.
+func (l *Lock) Held(ctx context.Context) bool {
+	return l.conn.Ping(ctx) == nil
+}
+
+func (l *Lock) Release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := l.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, l.key); err != nil {
+		// Closing the session drops the lock with it.
+		l.conn.Conn().Close(ctx)
+	}
+	l.conn.Release()
+}
+
+// LockHolders returns, for each of the named locks that is held, the app
+// its holder was opened as with OpenAs, or the holder's application_name.
+func LockHolders(ctx context.Context, pool *pgxpool.Pool, names []string) (map[string]string, error) {
+	byKey := make(map[int64]string, len(names))
+	for _, name := range names {
+		byKey[LockKey(name)] = name
+	}
+	rows, err := pool.Query(ctx, `
+SELECT (l.classid::bigint << 32) | l.objid::bigint, a.application_name
+FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid
+WHERE l.locktype = 'advisory' AND l.objsubid = 1 AND l.granted AND l.database = (SELECT oid FROM pg_database WHERE datname = current_database())`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	holders := map[string]string{}
+	for rows.Next() {
+		var key int64
+		var app string
+		if err := rows.Scan(&key, &app); err != nil {
+			return nil, err
+		}
+		if name, ok := byKey[key]; ok {
+			holders[name] = strings.TrimPrefix(app, appPrefix)
+		}
+	}
+	return holders, rows.Err()
+}
+
+// RunAsLeader runs fn on the one replica that holds lock name, checking
+// every interval whether it is free. The leader's context is cancelled
+// when its connection is lost, and another replica takes over. RunAsLeader
+// returns when ctx is done.
+func RunAsLeader(ctx context.Context, pool *pgxpool.Pool, name string, interval time.Duration, fn func(ctx context.Context)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		lock, err := TryLock(ctx, pool, name)
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("leader election failed", "lock", name, "error", err)
+		}
+		if lock != nil {
+			slog.Info("leading", "lock", name)
+			lead(ctx, lock, interval, fn)
+			lock.Release()
+			slog.Info("stopped leading", "lock", name)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// lead runs fn until it returns or the lock is lost.
+func lead(ctx context.Context, lock *Lock, interval time.Duration, fn func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(ctx)
+	}()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			if !lock.Held(ctx) && ctx.Err() == nil {
+				slog.Warn("lost leadership", "lock", lock.name)
+				cancel()
+				<-done
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolCollector reports pgxpool statistics at scrape time, labelled with the
+// pool's name.
+type PoolCollector struct {
+	pool *pgxpool.Pool
+
+	acquired, idle, total, constructing, max  *prometheus.Desc
+	acquires, emptyAcquires, canceledAcquires *prometheus.Desc
+	acquireWait, emptyAcquireWait             *prometheus.Desc
+	newConns, lifetimeDestroys, idleDestroys  *prometheus.Desc
+}
+
+func NewPoolCollector(pool *pgxpool.Pool, name string) *PoolCollector {
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc("pgxpool_"+metric, help, nil, prometheus.Labels{"pool": name})
+	}
+	return &PoolCollector{
+		pool:             pool,
+		acquired:         desc("acquired_conns", "Connections currently in use."),
+		idle:             desc("idle_conns", "Idle connections."),
+		total:            desc("total_conns", "Open connections."),
+		constructing:     desc("constructing_conns", "Connections being opened."),
+		max:              desc("max_conns", "Maximum pool size."),
+		acquires:         desc("acquires_total", "Successful connection acquisitions."),
+		emptyAcquires:    desc("empty_acquires_total", "Acquisitions that had to wait for a connection."),
+		canceledAcquires: desc("canceled_acquires_total", "Acquisitions given up because their context ended."),
+		acquireWait:      desc("acquire_duration_seconds_total", "Time spent acquiring connections."),
+		emptyAcquireWait: desc("empty_acquire_wait_seconds_total", "Time spent waiting for a connection when none was idle."),
+		newConns:         desc("new_conns_total", "Connections opened."),
+		lifetimeDestroys: desc("max_lifetime_destroys_total", "Connections closed for reaching their maximum lifetime."),
+		idleDestroys:     desc("max_idle_destroys_total", "Connections closed for staying idle too long."),
+	}
+}
+
+func (p *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(p, ch)
+}
+
+func (p *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := p.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(p.acquired, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(p.idle, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(p.total, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(p.constructing, prometheus.GaugeValue, float64(s.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(p.max, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(p.acquires, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(p.emptyAcquires, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(p.canceledAcquires, prometheus.CounterValue, float64(s.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(p.acquireWait, prometheus.CounterValue, s.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(p.emptyAcquireWait, prometheus.CounterValue, s.EmptyAcquireWaitTime().Seconds())
+	ch <- prometheus.MustNewConstMetric(p.newConns, prometheus.CounterValue, float64(s.NewConnsCount()))
+	ch <- prometheus.MustNewConstMetric(p.lifetimeDestroys, prometheus.CounterValue, float64(s.MaxLifetimeDestroyCount()))
+	ch <- prometheus.MustNewConstMetric(p.idleDestroys, prometheus.CounterValue, float64(s.MaxIdleDestroyCount()))
+}
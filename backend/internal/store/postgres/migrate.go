@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// NewMigrator returns a goose provider for the migrations embedded from
+// migrations/. Runs take a Postgres advisory lock, so replicas starting
+// together apply each migration once.
+func NewMigrator(pool *pgxpool.Pool) (*goose.Provider, error) {
+	migrations, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider(goose.DialectPostgres, stdlib.OpenDBFromPool(pool), migrations,
+		goose.WithSessionLocker(locker),
+		goose.WithDisableGlobalRegistry(true),
+	)
+}
+
+// EnsureSchema checks the schema version at startup. Pending migrations are
+// applied when migrate is set and are an error otherwise; a database newer
+// than the embedded migrations is always an error.
+func EnsureSchema(ctx context.Context, pool *pgxpool.Pool, migrate bool) error {
+	m, err := NewMigrator(pool)
+	if err != nil {
+		return err
+	}
+	current, latest, err := m.GetVersions(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case current > latest:
+		return fmt.Errorf("database schema is at version %d but this build only knows up to %d", current, latest)
+	case current == latest:
+		return nil
+	case !migrate:
+		return fmt.Errorf("database schema is at version %d, want %d: run \"server migrate up\"", current, latest)
+	}
+	results, err := m.Up(ctx)
+	for _, r := range results {
+		slog.Info("applied migration", "version", r.Source.Version, "duration", r.Duration)
+	}
+	return err
+}
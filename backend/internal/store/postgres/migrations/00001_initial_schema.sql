@@ -0,0 +1,111 @@
+-- The schema as created before versioned migrations. Every statement is
+-- idempotent so databases created by older builds adopt it as version 1.
+
+-- +goose Up
+CREATE TABLE IF NOT EXISTS hadith_collections (
+  id SERIAL PRIMARY KEY,
+  code TEXT UNIQUE NOT NULL,
+  title TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS hadiths (
+  id SERIAL PRIMARY KEY,
+  collection_id INT NOT NULL REFERENCES hadith_collections(id) ON DELETE CASCADE,
+  number TEXT NOT NULL,
+  text_ar TEXT,
+  text_ru TEXT,
+  text_en TEXT,
+  grade TEXT,
+  topics TEXT[]
+);
+ALTER TABLE hadiths ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE hadiths ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE hadith_collections ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+CREATE INDEX IF NOT EXISTS hadiths_collection_id_idx ON hadiths (collection_id);
+CREATE INDEX IF NOT EXISTS hadiths_created_at_idx ON hadiths (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS hadiths_collection_number_idx ON hadiths (collection_id, lower(regexp_replace(number, '\s', '', 'g')));
+CREATE TABLE IF NOT EXISTS users (
+  id SERIAL PRIMARY KEY,
+  email TEXT UNIQUE NOT NULL,
+  password_hash TEXT NOT NULL,
+  display_name TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'reader';
+ALTER TABLE users ALTER COLUMN password_hash DROP NOT NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_issuer TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_subject TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS users_oidc_idx ON users (oidc_issuer, oidc_subject);
+CREATE TABLE IF NOT EXISTS api_keys (
+  id SERIAL PRIMARY KEY,
+  name TEXT NOT NULL,
+  prefix TEXT NOT NULL,
+  key_hash TEXT UNIQUE NOT NULL,
+  scopes TEXT[] NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_used_at TIMESTAMPTZ,
+  revoked_at TIMESTAMPTZ
+);
+CREATE TABLE IF NOT EXISTS rate_limit_counters (
+  client TEXT NOT NULL,
+  route_group TEXT NOT NULL,
+  window_start TIMESTAMPTZ NOT NULL,
+  count INT NOT NULL,
+  PRIMARY KEY (client, route_group, window_start)
+);
+CREATE TABLE IF NOT EXISTS webhooks (
+  id SERIAL PRIMARY KEY,
+  url TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  events TEXT[] NOT NULL,
+  active BOOLEAN NOT NULL DEFAULT true,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_delivery_at TIMESTAMPTZ,
+  last_error TEXT
+);
+CREATE TABLE IF NOT EXISTS audit_log (
+  id BIGSERIAL PRIMARY KEY,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  actor_kind TEXT NOT NULL,
+  actor_id BIGINT,
+  actor_name TEXT NOT NULL,
+  action TEXT NOT NULL,
+  resources TEXT[] NOT NULL DEFAULT '{}',
+  summary JSONB NOT NULL DEFAULT '{}',
+  status INT NOT NULL,
+  request_id TEXT,
+  remote_ip TEXT
+);
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log (created_at);
+CREATE INDEX IF NOT EXISTS audit_log_resources_idx ON audit_log USING GIN (resources);
+CREATE TABLE IF NOT EXISTS api_key_usage (
+  api_key_id INT NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+  day DATE NOT NULL,
+  requests BIGINT NOT NULL DEFAULT 0,
+  searches BIGINT NOT NULL DEFAULT 0,
+  embedded_texts BIGINT NOT NULL DEFAULT 0,
+  embedded_chars BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (api_key_id, day)
+);
+CREATE TABLE IF NOT EXISTS exports (
+  id TEXT PRIMARY KEY,
+  status TEXT NOT NULL,
+  collection_code TEXT,
+  format TEXT NOT NULL,
+  hadiths INT,
+  size_bytes BIGINT,
+  error TEXT,
+  created_by TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  completed_at TIMESTAMPTZ
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS exports;
+DROP TABLE IF EXISTS api_key_usage;
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS webhooks;
+DROP TABLE IF EXISTS rate_limit_counters;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS hadiths;
+DROP TABLE IF EXISTS hadith_collections;
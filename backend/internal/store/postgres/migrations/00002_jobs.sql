@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE jobs (
+  id BIGSERIAL PRIMARY KEY,
+  kind TEXT NOT NULL,
+  args JSONB NOT NULL DEFAULT '{}',
+  state TEXT NOT NULL DEFAULT 'available',
+  attempt INT NOT NULL DEFAULT 0,
+  max_attempts INT NOT NULL,
+  run_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  started_at TIMESTAMPTZ,
+  finished_at TIMESTAMPTZ,
+  lease_until TIMESTAMPTZ,
+  last_error TEXT,
+  result JSONB
+);
+CREATE INDEX jobs_available_idx ON jobs (run_at, id) WHERE state = 'available';
+CREATE INDEX jobs_state_idx ON jobs (state, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS jobs;
@@ -0,0 +1,18 @@
+-- Index intents written in the same transaction as the hadiths they concern.
+-- A row means "make the hadith's points in Qdrant match Postgres"; it is
+-- deleted once that is done. available_at doubles as a lease while a worker
+-- or the uploading request applies it.
+
+-- +goose Up
+CREATE TABLE index_outbox (
+  id BIGSERIAL PRIMARY KEY,
+  hadith_id BIGINT NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  available_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_error TEXT
+);
+CREATE INDEX index_outbox_available_idx ON index_outbox (available_at, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS index_outbox;
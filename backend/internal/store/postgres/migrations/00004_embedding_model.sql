@@ -0,0 +1,14 @@
+-- The embedding model that produced the indexed vectors, one row at most.
+-- It lives in Postgres for both vector backends, so that points embedded by
+-- different models are never searched together.
+
+-- +goose Up
+CREATE TABLE embedding_model (
+  id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+  name TEXT NOT NULL,
+  dimensions INT NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS embedding_model;
@@ -0,0 +1,12 @@
+-- One embedding model row per vector index: the primary one and the
+-- shadow index used to evaluate another model.
+
+-- +goose Up
+ALTER TABLE embedding_model ADD COLUMN index_name TEXT NOT NULL DEFAULT 'primary';
+ALTER TABLE embedding_model DROP COLUMN id;
+ALTER TABLE embedding_model ADD PRIMARY KEY (index_name);
+
+-- +goose Down
+DELETE FROM embedding_model WHERE index_name <> 'primary';
+ALTER TABLE embedding_model DROP COLUMN index_name;
+ALTER TABLE embedding_model ADD COLUMN id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id);
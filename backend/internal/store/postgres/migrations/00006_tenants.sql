@@ -0,0 +1,65 @@
+-- Tenants partition the data of one deployment. Existing rows belong to the
+-- default tenant. api_key_usage, index_outbox and rate_limit_counters
+-- follow the tenant of their key, hadith or client; embedding_model is
+-- deployment-wide since every tenant shares the vector index.
+
+-- +goose Up
+CREATE TABLE tenants (
+  id SERIAL PRIMARY KEY,
+  code TEXT UNIQUE NOT NULL,
+  name TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+INSERT INTO tenants (id, code, name) VALUES (1, 'default', 'Default');
+SELECT setval(pg_get_serial_sequence('tenants', 'id'), 1);
+
+ALTER TABLE hadith_collections ADD COLUMN tenant_id INT NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE hadiths ADD COLUMN tenant_id INT NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE users ADD COLUMN tenant_id INT NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE api_keys ADD COLUMN tenant_id INT NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE webhooks ADD COLUMN tenant_id INT NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE audit_log ADD COLUMN tenant_id INT NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE exports ADD COLUMN tenant_id INT NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE jobs ADD COLUMN tenant_id INT NOT NULL DEFAULT 1 REFERENCES tenants(id);
+
+-- New rows must name their tenant.
+ALTER TABLE hadith_collections ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE hadiths ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE users ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE api_keys ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE webhooks ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE audit_log ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE exports ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE jobs ALTER COLUMN tenant_id DROP DEFAULT;
+
+-- Codes and emails are unique within a tenant.
+ALTER TABLE hadith_collections DROP CONSTRAINT hadith_collections_code_key;
+ALTER TABLE hadith_collections ADD CONSTRAINT hadith_collections_tenant_code_key UNIQUE (tenant_id, code);
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+ALTER TABLE users ADD CONSTRAINT users_tenant_email_key UNIQUE (tenant_id, email);
+DROP INDEX users_oidc_idx;
+CREATE UNIQUE INDEX users_oidc_idx ON users (tenant_id, oidc_issuer, oidc_subject);
+
+CREATE INDEX hadiths_tenant_id_idx ON hadiths (tenant_id, id);
+CREATE INDEX audit_log_tenant_created_at_idx ON audit_log (tenant_id, created_at);
+CREATE INDEX jobs_tenant_id_idx ON jobs (tenant_id, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS jobs_tenant_id_idx;
+DROP INDEX IF EXISTS audit_log_tenant_created_at_idx;
+DROP INDEX IF EXISTS hadiths_tenant_id_idx;
+DROP INDEX users_oidc_idx;
+CREATE UNIQUE INDEX users_oidc_idx ON users (oidc_issuer, oidc_subject);
+ALTER TABLE users DROP CONSTRAINT users_tenant_email_key;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+ALTER TABLE hadith_collections DROP CONSTRAINT hadith_collections_tenant_code_key;
+ALTER TABLE hadith_collections ADD CONSTRAINT hadith_collections_code_key UNIQUE (code);
+ALTER TABLE jobs DROP COLUMN tenant_id;
+ALTER TABLE exports DROP COLUMN tenant_id;
+ALTER TABLE audit_log DROP COLUMN tenant_id;
+ALTER TABLE webhooks DROP COLUMN tenant_id;
+ALTER TABLE api_keys DROP COLUMN tenant_id;
+ALTER TABLE users DROP COLUMN tenant_id;
+ALTER TABLE hadiths DROP COLUMN tenant_id;
+ALTER TABLE hadith_collections DROP COLUMN tenant_id;
+DROP TABLE IF EXISTS tenants;
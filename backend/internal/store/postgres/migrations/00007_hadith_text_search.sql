@@ -0,0 +1,13 @@
+-- Keyword search over the hadith texts, used when the vector store is
+-- unavailable. The simple configuration does not stem, so it treats the
+-- Arabic, Russian and English texts alike.
+
+-- +goose Up
+ALTER TABLE hadiths ADD COLUMN text_search tsvector GENERATED ALWAYS AS (
+  to_tsvector('simple', coalesce(text_ru, '') || ' ' || coalesce(text_en, '') || ' ' || coalesce(text_ar, ''))
+) STORED;
+CREATE INDEX hadiths_text_search_idx ON hadiths USING GIN (text_search);
+
+-- +goose Down
+DROP INDEX hadiths_text_search_idx;
+ALTER TABLE hadiths DROP COLUMN text_search;
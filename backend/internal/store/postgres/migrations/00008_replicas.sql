@@ -0,0 +1,18 @@
+-- Replicas of the server register here and heartbeat, for
+-- /v1/admin/cluster; jobs record the replica running their latest attempt
+-- and exports the replica writing them.
+
+-- +goose Up
+CREATE TABLE replicas (
+  id TEXT PRIMARY KEY,
+  hostname TEXT NOT NULL,
+  started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  seen_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+ALTER TABLE jobs ADD COLUMN worker TEXT;
+ALTER TABLE exports ADD COLUMN replica TEXT;
+
+-- +goose Down
+ALTER TABLE exports DROP COLUMN replica;
+ALTER TABLE jobs DROP COLUMN worker;
+DROP TABLE IF EXISTS replicas;
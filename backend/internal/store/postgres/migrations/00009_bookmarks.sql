@@ -0,0 +1,31 @@
+-- Users bookmark hadiths and ayahs, optionally filed into named folders.
+-- There is no Quran text here yet, so an ayah is referenced by surah and
+-- ayah number.
+
+-- +goose Up
+CREATE TABLE bookmark_folders (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (user_id, name)
+);
+
+CREATE TABLE bookmarks (
+  id BIGSERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  folder_id INT REFERENCES bookmark_folders(id) ON DELETE SET NULL,
+  hadith_id INT REFERENCES hadiths(id) ON DELETE CASCADE,
+  surah INT CHECK (surah BETWEEN 1 AND 114),
+  ayah INT CHECK (ayah BETWEEN 1 AND 286),
+  note TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CHECK ((hadith_id IS NOT NULL AND surah IS NULL AND ayah IS NULL) OR (hadith_id IS NULL AND surah IS NOT NULL AND ayah IS NOT NULL))
+);
+CREATE UNIQUE INDEX bookmarks_user_hadith_idx ON bookmarks (user_id, hadith_id) WHERE hadith_id IS NOT NULL;
+CREATE UNIQUE INDEX bookmarks_user_ayah_idx ON bookmarks (user_id, surah, ayah) WHERE surah IS NOT NULL;
+CREATE INDEX bookmarks_user_id_idx ON bookmarks (user_id, id DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS bookmarks;
+DROP TABLE IF EXISTS bookmark_folders;
@@ -0,0 +1,18 @@
+-- Private notes users keep on hadiths. Highlights are character ranges of
+-- the hadith's texts: [{"field": "text_en", "start": 0, "end": 12}].
+
+-- +goose Up
+CREATE TABLE hadith_notes (
+  id BIGSERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  hadith_id INT NOT NULL REFERENCES hadiths(id) ON DELETE CASCADE,
+  body TEXT NOT NULL DEFAULT '',
+  highlights JSONB NOT NULL DEFAULT '[]',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX hadith_notes_user_id_idx ON hadith_notes (user_id, id DESC);
+CREATE INDEX hadith_notes_user_hadith_idx ON hadith_notes (user_id, hadith_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS hadith_notes;
@@ -0,0 +1,29 @@
+-- Ordered lists of hadiths and ayahs users curate, readable by anyone with
+-- the share slug once shared.
+
+-- +goose Up
+CREATE TABLE reading_lists (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  title TEXT NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  share_slug TEXT UNIQUE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX reading_lists_user_id_idx ON reading_lists (user_id, id DESC);
+
+CREATE TABLE reading_list_items (
+  list_id INT NOT NULL REFERENCES reading_lists(id) ON DELETE CASCADE,
+  position INT NOT NULL,
+  hadith_id INT REFERENCES hadiths(id) ON DELETE CASCADE,
+  surah INT CHECK (surah BETWEEN 1 AND 114),
+  ayah INT CHECK (ayah BETWEEN 1 AND 286),
+  note TEXT NOT NULL DEFAULT '',
+  PRIMARY KEY (list_id, position),
+  CHECK ((hadith_id IS NOT NULL AND surah IS NULL AND ayah IS NULL) OR (hadith_id IS NULL AND surah IS NOT NULL AND ayah IS NOT NULL))
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS reading_list_items;
+DROP TABLE IF EXISTS reading_lists;
@@ -0,0 +1,19 @@
+-- Signed-in users' recent searches, one row per distinct query, for their
+-- history and suggestions. Users who turn search_history off keep none.
+
+-- +goose Up
+ALTER TABLE users ADD COLUMN search_history BOOLEAN NOT NULL DEFAULT true;
+
+CREATE TABLE search_history (
+  id BIGSERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  query TEXT NOT NULL,
+  count INT NOT NULL DEFAULT 1,
+  searched_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX search_history_user_query_idx ON search_history (user_id, lower(query));
+CREATE INDEX search_history_user_searched_at_idx ON search_history (user_id, searched_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS search_history;
+ALTER TABLE users DROP COLUMN search_history;
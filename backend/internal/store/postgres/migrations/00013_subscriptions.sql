@@ -0,0 +1,26 @@
+-- Daily hadith subscriptions: where each user gets the hadith of the day, in
+-- which language and at what local time. last_sent_on is the subscriber's
+-- local date the hadith was last scheduled for.
+
+-- +goose Up
+CREATE TABLE subscriptions (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  channel TEXT NOT NULL CHECK (channel IN ('email', 'push', 'webhook')),
+  target TEXT NOT NULL,
+  collection_code TEXT,
+  language TEXT NOT NULL DEFAULT 'en' CHECK (language IN ('ar', 'ru', 'en')),
+  send_at TIME NOT NULL DEFAULT '08:00',
+  timezone TEXT NOT NULL DEFAULT 'UTC',
+  secret TEXT,
+  unsubscribe_token TEXT UNIQUE NOT NULL,
+  active BOOLEAN NOT NULL DEFAULT true,
+  last_sent_on DATE,
+  last_error TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (user_id, channel, target)
+);
+CREATE INDEX subscriptions_active_idx ON subscriptions (send_at) WHERE active;
+
+-- +goose Down
+DROP TABLE IF EXISTS subscriptions;
@@ -0,0 +1,19 @@
+-- Short links to a hadith or a search, served at /s/{slug}. A search link
+-- keeps the hadiths the search found when it was shared, so previews need
+-- no embedding.
+
+-- +goose Up
+CREATE TABLE short_links (
+  slug TEXT PRIMARY KEY,
+  tenant_id INT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+  hadith_id INT REFERENCES hadiths(id) ON DELETE CASCADE,
+  query TEXT,
+  hadith_ids INT[] NOT NULL DEFAULT '{}',
+  hits BIGINT NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CHECK ((hadith_id IS NULL) <> (query IS NULL))
+);
+CREATE UNIQUE INDEX short_links_hadith_idx ON short_links (tenant_id, hadith_id) WHERE hadith_id IS NOT NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS short_links;
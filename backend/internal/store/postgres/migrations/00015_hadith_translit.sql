@@ -0,0 +1,16 @@
+-- Latin transliteration of the Arabic text. translit_norm is the same text
+-- after store.NormalizeTranslit, written by the application, so keyword
+-- search finds "solat" and "salah" alike.
+
+-- +goose Up
+ALTER TABLE hadiths
+  ADD COLUMN text_translit TEXT,
+  ADD COLUMN translit_norm TEXT;
+ALTER TABLE hadiths ADD COLUMN translit_search tsvector GENERATED ALWAYS AS (
+  to_tsvector('simple', coalesce(translit_norm, ''))
+) STORED;
+CREATE INDEX hadiths_translit_search_idx ON hadiths USING GIN (translit_search);
+
+-- +goose Down
+DROP INDEX hadiths_translit_search_idx;
+ALTER TABLE hadiths DROP COLUMN translit_search, DROP COLUMN translit_norm, DROP COLUMN text_translit;
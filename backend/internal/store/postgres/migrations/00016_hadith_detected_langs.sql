@@ -0,0 +1,13 @@
+-- The language detected in each text of a hadith at upload, keyed by field:
+-- {"text_ru": "en"} is English text given as Russian. lang_mismatch marks
+-- hadiths with any such text, for review. Hadiths uploaded before have none.
+
+-- +goose Up
+ALTER TABLE hadiths
+  ADD COLUMN detected_langs JSONB NOT NULL DEFAULT '{}',
+  ADD COLUMN lang_mismatch BOOLEAN NOT NULL DEFAULT false;
+CREATE INDEX hadiths_lang_mismatch_idx ON hadiths (tenant_id, id) WHERE lang_mismatch;
+
+-- +goose Down
+DROP INDEX hadiths_lang_mismatch_idx;
+ALTER TABLE hadiths DROP COLUMN lang_mismatch, DROP COLUMN detected_langs;
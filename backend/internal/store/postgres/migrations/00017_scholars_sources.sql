@@ -0,0 +1,66 @@
+-- Scholars (authors, compilers, graders) and the source books hadiths are
+-- cited from, down to the edition, volume and page, so citations can be
+-- rendered precisely. Both are per tenant and addressed by slug.
+
+-- +goose Up
+CREATE TABLE scholars (
+  id SERIAL PRIMARY KEY,
+  tenant_id INT NOT NULL REFERENCES tenants(id),
+  slug TEXT NOT NULL,
+  name TEXT NOT NULL,
+  name_ar TEXT NOT NULL DEFAULT '',
+  born_ah INT,
+  died_ah INT,
+  bio TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (tenant_id, slug)
+);
+
+CREATE TABLE source_books (
+  id SERIAL PRIMARY KEY,
+  tenant_id INT NOT NULL REFERENCES tenants(id),
+  slug TEXT NOT NULL,
+  title TEXT NOT NULL,
+  title_ar TEXT NOT NULL DEFAULT '',
+  author_id INT REFERENCES scholars(id) ON DELETE SET NULL,
+  edition TEXT NOT NULL DEFAULT '',
+  publisher TEXT NOT NULL DEFAULT '',
+  published_year INT,
+  volumes INT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (tenant_id, slug)
+);
+CREATE INDEX source_books_author_id_idx ON source_books (author_id);
+
+-- Where a hadith appears: its number in that book, and volume and page of
+-- the edition.
+CREATE TABLE hadith_sources (
+  hadith_id INT NOT NULL REFERENCES hadiths(id) ON DELETE CASCADE,
+  book_id INT NOT NULL REFERENCES source_books(id) ON DELETE CASCADE,
+  position INT NOT NULL,
+  volume INT,
+  page INT,
+  number TEXT NOT NULL DEFAULT '',
+  PRIMARY KEY (hadith_id, position)
+);
+CREATE INDEX hadith_sources_book_id_idx ON hadith_sources (book_id);
+
+-- The grade a scholar gave a hadith, and where they gave it.
+CREATE TABLE hadith_gradings (
+  hadith_id INT NOT NULL REFERENCES hadiths(id) ON DELETE CASCADE,
+  scholar_id INT NOT NULL REFERENCES scholars(id) ON DELETE CASCADE,
+  grade TEXT NOT NULL,
+  book_id INT REFERENCES source_books(id) ON DELETE SET NULL,
+  volume INT,
+  page INT,
+  PRIMARY KEY (hadith_id, scholar_id)
+);
+CREATE INDEX hadith_gradings_scholar_id_idx ON hadith_gradings (scholar_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS hadith_gradings;
+DROP TABLE IF EXISTS hadith_sources;
+DROP TABLE IF EXISTS source_books;
+DROP TABLE IF EXISTS scholars;
@@ -0,0 +1,32 @@
+-- Candidate duplicate narrations found by the duplicates.detect job: groups
+-- of hadiths whose vectors are closer than its threshold, within or across
+-- collections. Editors review each group and link its hadiths as parallel
+-- narrations, merge them under a canonical hadith, or dismiss it. A later
+-- run replaces the pending groups and leaves reviewed ones alone.
+
+-- +goose Up
+CREATE TABLE duplicate_groups (
+  id SERIAL PRIMARY KEY,
+  tenant_id INT NOT NULL REFERENCES tenants(id),
+  job_id BIGINT REFERENCES jobs(id) ON DELETE SET NULL,
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'linked', 'merged', 'dismissed')),
+  score REAL NOT NULL,
+  cross_collection BOOLEAN NOT NULL,
+  canonical_id INT REFERENCES hadiths(id) ON DELETE SET NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  resolved_at TIMESTAMPTZ
+);
+CREATE INDEX duplicate_groups_tenant_status_idx ON duplicate_groups (tenant_id, status, id);
+
+-- score is a member's highest similarity to another member of its group.
+CREATE TABLE duplicate_group_members (
+  group_id INT NOT NULL REFERENCES duplicate_groups(id) ON DELETE CASCADE,
+  hadith_id INT NOT NULL REFERENCES hadiths(id) ON DELETE CASCADE,
+  score REAL NOT NULL,
+  PRIMARY KEY (group_id, hadith_id)
+);
+CREATE INDEX duplicate_group_members_hadith_id_idx ON duplicate_group_members (hadith_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS duplicate_group_members;
+DROP TABLE IF EXISTS duplicate_groups;
@@ -0,0 +1,8 @@
+-- An optional title editors give a hadith, shown instead of its collection
+-- and number.
+
+-- +goose Up
+ALTER TABLE hadiths ADD COLUMN title TEXT;
+
+-- +goose Down
+ALTER TABLE hadiths DROP COLUMN title;
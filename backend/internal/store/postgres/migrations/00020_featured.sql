@@ -0,0 +1,24 @@
+-- Hadiths and collections editors feature on app home screens. An item is
+-- shown from starts_on to ends_on (inclusive, in DAILY_TIMEZONE) and, with
+-- hijri_month, only in that month of every Hijri year, e.g. 9 for Ramadan.
+
+-- +goose Up
+CREATE TABLE featured_items (
+  id SERIAL PRIMARY KEY,
+  tenant_id INT NOT NULL REFERENCES tenants(id),
+  hadith_id INT REFERENCES hadiths(id) ON DELETE CASCADE,
+  collection_id INT REFERENCES hadith_collections(id) ON DELETE CASCADE,
+  headline TEXT NOT NULL DEFAULT '',
+  position INT NOT NULL DEFAULT 0,
+  starts_on DATE,
+  ends_on DATE,
+  hijri_month INT CHECK (hijri_month BETWEEN 1 AND 12),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CHECK ((hadith_id IS NULL) <> (collection_id IS NULL)),
+  CHECK (ends_on >= starts_on)
+);
+CREATE INDEX featured_items_tenant_idx ON featured_items (tenant_id, position, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS featured_items;
@@ -0,0 +1,10 @@
+-- Hadiths uploaded with a future publish_at stay hidden from reads and
+-- search until the scheduler clears it and indexes them.
+
+-- +goose Up
+ALTER TABLE hadiths ADD COLUMN publish_at TIMESTAMPTZ;
+CREATE INDEX hadiths_publish_at_idx ON hadiths (publish_at) WHERE publish_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX hadiths_publish_at_idx;
+ALTER TABLE hadiths DROP COLUMN publish_at;
@@ -0,0 +1,43 @@
+-- Ranking experiments: searches by clients bucketed into a variant of the
+-- tenant's running experiment are recorded with their clicks and feedback,
+-- so that variants can be compared.
+
+-- +goose Up
+CREATE TABLE experiments (
+  id SERIAL PRIMARY KEY,
+  tenant_id INT NOT NULL REFERENCES tenants(id),
+  name TEXT NOT NULL,
+  variants JSONB NOT NULL,
+  active BOOLEAN NOT NULL DEFAULT false,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (tenant_id, name)
+);
+-- A tenant runs one experiment at a time.
+CREATE UNIQUE INDEX experiments_active_idx ON experiments (tenant_id) WHERE active;
+
+CREATE TABLE search_events (
+  id TEXT PRIMARY KEY,
+  experiment_id INT NOT NULL REFERENCES experiments(id) ON DELETE CASCADE,
+  variant TEXT NOT NULL,
+  client_id TEXT NOT NULL,
+  query TEXT NOT NULL,
+  hits INT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX search_events_experiment_idx ON search_events (experiment_id, variant);
+
+CREATE TABLE search_interactions (
+  id BIGSERIAL PRIMARY KEY,
+  search_id TEXT NOT NULL REFERENCES search_events(id) ON DELETE CASCADE,
+  kind TEXT NOT NULL CHECK (kind IN ('click', 'helpful', 'unhelpful')),
+  result_id TEXT,
+  position INT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX search_interactions_search_idx ON search_interactions (search_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS search_interactions;
+DROP TABLE IF EXISTS search_events;
+DROP TABLE IF EXISTS experiments;
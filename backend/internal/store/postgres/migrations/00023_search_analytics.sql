@@ -0,0 +1,19 @@
+-- Every search is recorded with the results it returned, not only those in
+-- experiments, so that clicks can be related to result positions.
+
+-- +goose Up
+ALTER TABLE search_events ADD COLUMN tenant_id INT REFERENCES tenants(id);
+UPDATE search_events s SET tenant_id = x.tenant_id FROM experiments x WHERE x.id = s.experiment_id;
+ALTER TABLE search_events ALTER COLUMN tenant_id SET NOT NULL;
+ALTER TABLE search_events ALTER COLUMN experiment_id DROP NOT NULL;
+ALTER TABLE search_events ALTER COLUMN variant DROP NOT NULL;
+ALTER TABLE search_events ADD COLUMN results TEXT[] NOT NULL DEFAULT '{}';
+CREATE INDEX search_events_created_idx ON search_events (created_at) WHERE experiment_id IS NULL;
+
+-- +goose Down
+DROP INDEX search_events_created_idx;
+DELETE FROM search_events WHERE experiment_id IS NULL;
+ALTER TABLE search_events DROP COLUMN results;
+ALTER TABLE search_events ALTER COLUMN variant SET NOT NULL;
+ALTER TABLE search_events ALTER COLUMN experiment_id SET NOT NULL;
+ALTER TABLE search_events DROP COLUMN tenant_id;
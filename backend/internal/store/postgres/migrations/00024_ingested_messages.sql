@@ -0,0 +1,12 @@
+-- Messages "server consume" has ingested, recorded in the transaction that
+-- stores their hadiths so that a redelivered message is not stored twice.
+
+-- +goose Up
+CREATE TABLE ingested_messages (
+  id TEXT PRIMARY KEY,
+  tenant_id INT NOT NULL REFERENCES tenants(id),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS ingested_messages;
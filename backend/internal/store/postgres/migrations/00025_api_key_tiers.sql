@@ -0,0 +1,7 @@
+-- The tier of an API key picks its search limits (SEARCH_TIER_LIMITS).
+
+-- +goose Up
+ALTER TABLE api_keys ADD COLUMN tier TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE api_keys DROP COLUMN IF EXISTS tier;
@@ -0,0 +1,20 @@
+-- Where a hadith sits in the printed edition of its collection: book and
+-- chapter numbers with their titles, and its position within the chapter.
+
+-- +goose Up
+ALTER TABLE hadiths
+  ADD COLUMN book INT,
+  ADD COLUMN book_title TEXT,
+  ADD COLUMN chapter INT,
+  ADD COLUMN chapter_title TEXT,
+  ADD COLUMN sequence INT;
+CREATE INDEX hadiths_structure_idx ON hadiths (collection_id, book, chapter, sequence) WHERE book IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS hadiths_structure_idx;
+ALTER TABLE hadiths
+  DROP COLUMN IF EXISTS book,
+  DROP COLUMN IF EXISTS book_title,
+  DROP COLUMN IF EXISTS chapter,
+  DROP COLUMN IF EXISTS chapter_title,
+  DROP COLUMN IF EXISTS sequence;
@@ -0,0 +1,16 @@
+-- Ayahs of the Quran a hadith cites or explains, entered by editors
+-- (manual) or found in its texts (detected).
+
+-- +goose Up
+CREATE TABLE hadith_ayah_refs (
+  hadith_id INT NOT NULL REFERENCES hadiths(id) ON DELETE CASCADE,
+  surah INT NOT NULL CHECK (surah BETWEEN 1 AND 114),
+  ayah INT NOT NULL CHECK (ayah BETWEEN 1 AND 286),
+  source TEXT NOT NULL CHECK (source IN ('manual', 'detected')),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (hadith_id, surah, ayah)
+);
+CREATE INDEX hadith_ayah_refs_ayah_idx ON hadith_ayah_refs (surah, ayah, hadith_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS hadith_ayah_refs;
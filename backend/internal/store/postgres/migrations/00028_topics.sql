@@ -0,0 +1,19 @@
+-- Topics are labels on hadiths; editors may describe them. Each topic's
+-- label and description are embedded, by the model in use, to suggest
+-- existing topics for new text.
+
+-- +goose Up
+CREATE TABLE topics (
+  tenant_id INT NOT NULL REFERENCES tenants(id),
+  name TEXT NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  -- embedded_text is what embedding was made from, by model.
+  embedded_text TEXT,
+  embedding REAL[],
+  model TEXT,
+  PRIMARY KEY (tenant_id, name)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS topics;
@@ -0,0 +1,40 @@
+-- Text users publish to others is moderated first: moderation_items queues
+-- what the pipeline flagged or refused, and moderation_decisions logs how
+-- each item was decided, by the pipeline or by a moderator. Lists shared
+-- before moderation existed stay approved.
+
+-- +goose Up
+ALTER TABLE reading_lists ADD COLUMN moderation TEXT NOT NULL DEFAULT 'approved'
+  CHECK (moderation IN ('pending', 'approved', 'rejected'));
+
+CREATE TABLE moderation_items (
+  id BIGSERIAL PRIMARY KEY,
+  tenant_id INT NOT NULL REFERENCES tenants(id),
+  kind TEXT NOT NULL,
+  ref_id BIGINT NOT NULL,
+  user_id INT REFERENCES users(id) ON DELETE SET NULL,
+  content TEXT NOT NULL,
+  status TEXT NOT NULL CHECK (status IN ('pending', 'approved', 'rejected')),
+  -- reasons are why the pipeline held the content, e.g. "review:word".
+  reasons TEXT[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  decided_at TIMESTAMPTZ
+);
+CREATE INDEX moderation_items_status_idx ON moderation_items (tenant_id, status, id);
+CREATE INDEX moderation_items_ref_idx ON moderation_items (kind, ref_id, id DESC);
+
+CREATE TABLE moderation_decisions (
+  id BIGSERIAL PRIMARY KEY,
+  item_id BIGINT NOT NULL REFERENCES moderation_items(id) ON DELETE CASCADE,
+  decision TEXT NOT NULL CHECK (decision IN ('approved', 'rejected')),
+  -- moderator is NULL for decisions of the pipeline.
+  moderator TEXT,
+  reason TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX moderation_decisions_item_idx ON moderation_decisions (item_id, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS moderation_decisions;
+DROP TABLE IF EXISTS moderation_items;
+ALTER TABLE reading_lists DROP COLUMN IF EXISTS moderation;
@@ -0,0 +1,17 @@
+-- IPs banned for a while from the public API after going far over the
+-- abuse limit of search. Rows stay once bans end, as their history.
+
+-- +goose Up
+CREATE TABLE ip_bans (
+  ip TEXT PRIMARY KEY,
+  reason TEXT NOT NULL,
+  -- bans counts how often the IP was banned.
+  bans INT NOT NULL DEFAULT 1,
+  banned_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  banned_until TIMESTAMPTZ NOT NULL,
+  lifted_by TEXT
+);
+CREATE INDEX ip_bans_banned_until_idx ON ip_bans (banned_until);
+
+-- +goose Down
+DROP TABLE IF EXISTS ip_bans;
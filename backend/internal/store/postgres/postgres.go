@@ -0,0 +1,87 @@
+// Package postgres opens the connection pool and owns the schema.
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/logging"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Open connects to dsn and pings the server. Queries slower than
+// logging.SlowThresholds.Postgres are logged.
+func Open(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
+	return OpenAs(ctx, dsn, "")
+}
+
+// appPrefix starts the application_name of the server's connections.
+const appPrefix = "islamapp:"
+
+// OpenAs is Open with the connections' application_name naming app, which
+// LockHolders reports, unless dsn sets one.
+func OpenAs(ctx context.Context, dsn, app string) (*pgxpool.Pool, error) {
+	return OpenPool(ctx, dsn, app, PoolConfig{})
+}
+
+// PoolConfig sizes a pool and ages its connections. Zero fields keep the
+// DSN's pool_* parameters or pgxpool's defaults.
+type PoolConfig struct {
+	MaxConns        int
+	MinConns        int
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
+}
+
+// OpenPool is OpenAs with the pool configured by pc.
+func OpenPool(ctx context.Context, dsn, app string, pc PoolConfig) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if pc.MaxConns > 0 {
+		cfg.MaxConns = int32(pc.MaxConns)
+	}
+	if pc.MinConns > 0 {
+		cfg.MinConns = int32(min(pc.MinConns, int(cfg.MaxConns)))
+	}
+	if pc.MaxConnLifetime > 0 {
+		cfg.MaxConnLifetime = pc.MaxConnLifetime
+	}
+	if pc.MaxConnIdleTime > 0 {
+		cfg.MaxConnIdleTime = pc.MaxConnIdleTime
+	}
+	if _, ok := cfg.ConnConfig.RuntimeParams["application_name"]; app != "" && !ok {
+		cfg.ConnConfig.RuntimeParams["application_name"] = appPrefix + app
+	}
+	if logging.SlowThresholds.Postgres > 0 {
+		cfg.ConnConfig.Tracer = slowQueryTracer{}
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	ctxPing, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := pool.Ping(ctxPing); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+// NullString stores an empty string as NULL.
+func NullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// TextArray stores an empty list as NULL.
+func TextArray(a []string) any {
+	if len(a) == 0 {
+		return nil
+	}
+	return a
+}
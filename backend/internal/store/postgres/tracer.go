@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/logging"
+	"github.com/buugaaga/test-cursor/backend/internal/metrics"
+	"github.com/jackc/pgx/v5"
+)
+
+// redactArgs describes query parameters by type and size so slow query logs
+// never contain user data.
+func redactArgs(args []any) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		switch v := a.(type) {
+		case nil:
+			out[i] = "null"
+		case string:
+			out[i] = fmt.Sprintf("string(%d)", len(v))
+		case []byte:
+			out[i] = fmt.Sprintf("bytes(%d)", len(v))
+		case []string:
+			out[i] = fmt.Sprintf("[]string(%d)", len(v))
+		default:
+			out[i] = fmt.Sprintf("%T", v)
+		}
+	}
+	return out
+}
+
+// compactSQL folds the whitespace of a multi-line statement onto one line.
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
+
+type slowQueryKey struct{}
+
+type slowQueryStart struct {
+	start time.Time
+	sql   string
+	args  []any
+}
+
+// slowQueryTracer is a pgx tracer that logs queries and COPYs exceeding
+// logging.SlowThresholds.Postgres.
+type slowQueryTracer struct{}
+
+func (slowQueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, slowQueryKey{}, slowQueryStart{start: time.Now(), sql: data.SQL, args: data.Args})
+}
+
+func (slowQueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	s, ok := ctx.Value(slowQueryKey{}).(slowQueryStart)
+	if !ok {
+		return
+	}
+	logging.Slow(ctx, "postgres query", logging.SlowThresholds.Postgres, time.Since(s.start),
+		"sql", compactSQL(s.sql), "args", redactArgs(s.args), "rows", data.CommandTag.RowsAffected(), "outcome", metrics.Outcome(data.Err))
+}
+
+func (slowQueryTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	return context.WithValue(ctx, slowQueryKey{}, slowQueryStart{start: time.Now(), sql: "COPY " + data.TableName.Sanitize()})
+}
+
+func (slowQueryTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	s, ok := ctx.Value(slowQueryKey{}).(slowQueryStart)
+	if !ok {
+		return
+	}
+	logging.Slow(ctx, "postgres query", logging.SlowThresholds.Postgres, time.Since(s.start),
+		"sql", s.sql, "rows", data.CommandTag.RowsAffected(), "outcome", metrics.Outcome(data.Err))
+}
@@ -0,0 +1,140 @@
+// Package store defines the hadith read model the handlers query.
+// postgres.Store is the production implementation; storetest.Store is an
+// in-memory fake for tests.
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/quran"
+)
+
+// ErrNotFound is returned when a single hadith or collection does not exist.
+var ErrNotFound = errors.New("not found")
+
+type Hadith struct {
+	ID             int64     `json:"id"`
+	CollectionCode string    `json:"collection_code"`
+	Number         string    `json:"number"`
+	Title          *string   `json:"title,omitempty"`
+	TextAr         *string   `json:"text_ar,omitempty"`
+	TextRu         *string   `json:"text_ru,omitempty"`
+	TextEn         *string   `json:"text_en,omitempty"`
+	TextTranslit   *string   `json:"text_translit,omitempty"`
+	Grade          *string   `json:"grade,omitempty"`
+	Topics         []string  `json:"topics,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	TenantID       int64     `json:"-"`
+	// Book, Chapter and Sequence place the hadith in the printed edition of
+	// its collection, when known.
+	Book         *int    `json:"book,omitempty"`
+	BookTitle    *string `json:"book_title,omitempty"`
+	Chapter      *int    `json:"chapter,omitempty"`
+	ChapterTitle *string `json:"chapter_title,omitempty"`
+	Sequence     *int    `json:"sequence,omitempty"`
+	// Ayahs are the ayahs of the Quran the hadith cites, in mushaf order.
+	Ayahs []quran.Ref `json:"ayahs,omitempty"`
+}
+
+type Collection struct {
+	ID          int64  `json:"id"`
+	Code        string `json:"code"`
+	Title       string `json:"title"`
+	HadithCount int64  `json:"hadith_count"`
+	TenantID    int64  `json:"-"`
+}
+
+// HadithCursor is the position after the last hadith of a page, in the
+// natural number order of a collection.
+type HadithCursor struct {
+	NumberKey int64  `json:"k"`
+	Number    string `json:"n"`
+	ID        int64  `json:"i"`
+}
+
+// Book is a book of the printed edition of a collection.
+type Book struct {
+	Number       int     `json:"book"`
+	Title        *string `json:"title,omitempty"`
+	ChapterCount int     `json:"chapter_count"`
+	HadithCount  int64   `json:"hadith_count"`
+	// Chapters are only listed for a single book.
+	Chapters []Chapter `json:"chapters,omitempty"`
+}
+
+// Chapter is a chapter of a book.
+type Chapter struct {
+	Number      int     `json:"chapter"`
+	Title       *string `json:"title,omitempty"`
+	HadithCount int64   `json:"hadith_count"`
+}
+
+// BookCursor is the position after the last hadith of a page, in the order
+// of the printed edition: by chapter, sequence, then number.
+type BookCursor struct {
+	Chapter  int `json:"c"`
+	Sequence int `json:"s"`
+	HadithCursor
+}
+
+// TextMatch is a hadith found by keyword search, with its relevance.
+type TextMatch struct {
+	Hadith
+	Rank float32
+}
+
+// HadithFilter restricts hadiths by collection code, grade and topic; empty
+// fields match everything.
+type HadithFilter struct {
+	Collection string
+	Grade      string
+	Topic      string
+}
+
+// Store reads the data of the tenant in the request context; an unscoped
+// context (tenant.All) sees every tenant's.
+type Store interface {
+	Hadith(ctx context.Context, id int64) (Hadith, error)
+	// Hadiths returns the given ids in request order, along with the ids
+	// that do not exist.
+	Hadiths(ctx context.Context, ids []int64) ([]Hadith, []int64, error)
+	// HadithByNumber matches number after NormalizeNumber.
+	HadithByNumber(ctx context.Context, code, number string) (Hadith, error)
+	// CompositeNumbers lists sub-numbered variants ("1234a", "1234b") of a
+	// plain number, for citations that omit the letter suffix.
+	CompositeNumbers(ctx context.Context, code, number string) ([]string, error)
+	// CollectionHadiths returns a page of up to limit hadiths after cursor
+	// and the cursor of the next page, nil on the last one.
+	CollectionHadiths(ctx context.Context, code string, limit int, desc bool, cursor *HadithCursor) ([]Hadith, *HadithCursor, error)
+	// Books lists the books of a collection in order; hadiths without a
+	// book are left out.
+	Books(ctx context.Context, code string) ([]Book, error)
+	// Chapters lists the chapters of a book in order.
+	Chapters(ctx context.Context, code string, book int) ([]Chapter, error)
+	// BookHadiths returns a page of the hadiths of a book, or of one of its
+	// chapters when chapter is not 0, like CollectionHadiths.
+	BookHadiths(ctx context.Context, code string, book, chapter, limit int, cursor *BookCursor) ([]Hadith, *BookCursor, error)
+	Collections(ctx context.Context) ([]Collection, error)
+	Collection(ctx context.Context, code string) (Collection, error)
+	CollectionExists(ctx context.Context, code string) (bool, error)
+	// CollectionsVersion summarizes everything a collection listing depends
+	// on, for ETags. code limits it to one collection; "" covers all.
+	CollectionsVersion(ctx context.Context, code string) (string, error)
+	CountHadiths(ctx context.Context, f HadithFilter) (int64, error)
+	// NthHadith returns the hadith at position n (0-based, ordered by id)
+	// among those matching f.
+	NthHadith(ctx context.Context, f HadithFilter, n int64) (Hadith, error)
+	// SearchText returns up to limit hadiths whose texts contain the words
+	// of query, most relevant first. Transliterations match after
+	// NormalizeTranslit.
+	SearchText(ctx context.Context, query string, limit int) ([]TextMatch, error)
+}
+
+// NormalizeNumber canonicalizes a cited number: "1234 A" and "1234a" refer
+// to the same hadith.
+func NormalizeNumber(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), ""))
+}
@@ -0,0 +1,429 @@
+// Package storetest provides an in-memory store.Store for handler tests
+// that run without Postgres.
+package storetest
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/buugaaga/test-cursor/backend/internal/store"
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+)
+
+var _ store.Store = (*Store)(nil)
+
+// Store holds collections and hadiths in memory with the ordering and
+// lookup rules of postgres.Store. It holds a single tenant: everything
+// belongs to tenant.Default. Err, when set, is returned by every call.
+type Store struct {
+	Err error
+
+	mu          sync.Mutex
+	collections []store.Collection
+	hadiths     []store.Hadith
+	// version counts changes, standing in for the updated_at columns.
+	version int
+}
+
+// AddCollection adds or replaces the collection with c's code.
+func (s *Store) AddCollection(c store.Collection) {
+	c.TenantID = tenant.Default
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.collections {
+		if existing.Code == c.Code {
+			s.collections[i] = c
+			s.version++
+			return
+		}
+	}
+	if c.ID == 0 {
+		c.ID = int64(len(s.collections) + 1)
+	}
+	s.collections = append(s.collections, c)
+	s.version++
+}
+
+// AddHadith adds h, creating its collection when missing. A zero ID is
+// assigned the next one.
+func (s *Store) AddHadith(h store.Hadith) store.Hadith {
+	s.mu.Lock()
+	found := false
+	for _, c := range s.collections {
+		found = found || c.Code == h.CollectionCode
+	}
+	s.mu.Unlock()
+	if !found {
+		s.AddCollection(store.Collection{Code: h.CollectionCode, Title: h.CollectionCode})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h.ID == 0 {
+		h.ID = int64(len(s.hadiths) + 1)
+	}
+	h.TenantID = tenant.Default
+	s.hadiths = append(s.hadiths, h)
+	s.version++
+	slices.SortFunc(s.hadiths, func(a, b store.Hadith) int { return int(a.ID - b.ID) })
+	return h
+}
+
+func (s *Store) Hadith(ctx context.Context, id int64) (store.Hadith, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return store.Hadith{}, s.Err
+	}
+	for _, h := range s.hadiths {
+		if h.ID == id {
+			return h, nil
+		}
+	}
+	return store.Hadith{}, store.ErrNotFound
+}
+
+func (s *Store) Hadiths(ctx context.Context, ids []int64) ([]store.Hadith, []int64, error) {
+	hadiths := []store.Hadith{}
+	missing := []int64{}
+	seen := map[int64]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		h, err := s.Hadith(ctx, id)
+		switch {
+		case err == store.ErrNotFound:
+			missing = append(missing, id)
+		case err != nil:
+			return nil, nil, err
+		default:
+			hadiths = append(hadiths, h)
+		}
+	}
+	return hadiths, missing, nil
+}
+
+func (s *Store) HadithByNumber(ctx context.Context, code, number string) (store.Hadith, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return store.Hadith{}, s.Err
+	}
+	for _, h := range s.hadiths {
+		if h.CollectionCode == code && store.NormalizeNumber(h.Number) == store.NormalizeNumber(number) {
+			return h, nil
+		}
+	}
+	return store.Hadith{}, store.ErrNotFound
+}
+
+func (s *Store) CompositeNumbers(ctx context.Context, code, number string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	re := regexp.MustCompile("^" + regexp.QuoteMeta(store.NormalizeNumber(number)) + "[a-z]+$")
+	candidates := []string{}
+	for _, h := range s.hadiths {
+		if h.CollectionCode == code && re.MatchString(store.NormalizeNumber(h.Number)) {
+			candidates = append(candidates, h.Number)
+		}
+	}
+	slices.Sort(candidates)
+	return candidates, nil
+}
+
+// numberKey is the leading integer of a hadith number, as in postgres.Store.
+func numberKey(number string) int64 {
+	end := strings.IndexFunc(number, func(r rune) bool { return r < '0' || r > '9' })
+	if end < 0 {
+		end = len(number)
+	}
+	n, _ := strconv.ParseInt(number[:end], 10, 64)
+	return n
+}
+
+func compareCursor(a, b store.HadithCursor) int {
+	if a.NumberKey != b.NumberKey {
+		if a.NumberKey < b.NumberKey {
+			return -1
+		}
+		return 1
+	}
+	if c := strings.Compare(a.Number, b.Number); c != 0 {
+		return c
+	}
+	return int(a.ID - b.ID)
+}
+
+func (s *Store) CollectionHadiths(ctx context.Context, code string, limit int, desc bool, cursor *store.HadithCursor) ([]store.Hadith, *store.HadithCursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, nil, s.Err
+	}
+	pos := func(h store.Hadith) store.HadithCursor {
+		return store.HadithCursor{NumberKey: numberKey(h.Number), Number: h.Number, ID: h.ID}
+	}
+	matched := []store.Hadith{}
+	for _, h := range s.hadiths {
+		if h.CollectionCode != code {
+			continue
+		}
+		if cursor != nil {
+			c := compareCursor(pos(h), *cursor)
+			if (!desc && c <= 0) || (desc && c >= 0) {
+				continue
+			}
+		}
+		matched = append(matched, h)
+	}
+	slices.SortFunc(matched, func(a, b store.Hadith) int {
+		c := compareCursor(pos(a), pos(b))
+		if desc {
+			return -c
+		}
+		return c
+	})
+	if len(matched) <= limit {
+		return matched, nil, nil
+	}
+	next := pos(matched[limit-1])
+	return matched[:limit], &next, nil
+}
+
+func orZero(n *int) int {
+	if n == nil {
+		return 0
+	}
+	return *n
+}
+
+func (s *Store) Books(ctx context.Context, code string) ([]store.Book, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	books := []store.Book{}
+	chapters := map[[2]int]bool{}
+	for _, h := range s.hadiths {
+		if h.CollectionCode != code || h.Book == nil {
+			continue
+		}
+		i := slices.IndexFunc(books, func(b store.Book) bool { return b.Number == *h.Book })
+		if i < 0 {
+			books = append(books, store.Book{Number: *h.Book})
+			i = len(books) - 1
+		}
+		b := &books[i]
+		b.HadithCount++
+		if b.Title == nil {
+			b.Title = h.BookTitle
+		}
+		if h.Chapter != nil && !chapters[[2]int{*h.Book, *h.Chapter}] {
+			chapters[[2]int{*h.Book, *h.Chapter}] = true
+			b.ChapterCount++
+		}
+	}
+	slices.SortFunc(books, func(a, b store.Book) int { return cmp.Compare(a.Number, b.Number) })
+	return books, nil
+}
+
+func (s *Store) Chapters(ctx context.Context, code string, book int) ([]store.Chapter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	chapters := []store.Chapter{}
+	for _, h := range s.hadiths {
+		if h.CollectionCode != code || orZero(h.Book) != book || h.Chapter == nil {
+			continue
+		}
+		i := slices.IndexFunc(chapters, func(ch store.Chapter) bool { return ch.Number == *h.Chapter })
+		if i < 0 {
+			chapters = append(chapters, store.Chapter{Number: *h.Chapter})
+			i = len(chapters) - 1
+		}
+		ch := &chapters[i]
+		ch.HadithCount++
+		if ch.Title == nil {
+			ch.Title = h.ChapterTitle
+		}
+	}
+	slices.SortFunc(chapters, func(a, b store.Chapter) int { return cmp.Compare(a.Number, b.Number) })
+	return chapters, nil
+}
+
+func (s *Store) BookHadiths(ctx context.Context, code string, book, chapter, limit int, cursor *store.BookCursor) ([]store.Hadith, *store.BookCursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, nil, s.Err
+	}
+	pos := func(h store.Hadith) store.BookCursor {
+		return store.BookCursor{
+			Chapter:      orZero(h.Chapter),
+			Sequence:     orZero(h.Sequence),
+			HadithCursor: store.HadithCursor{NumberKey: numberKey(h.Number), Number: h.Number, ID: h.ID},
+		}
+	}
+	compare := func(a, b store.BookCursor) int {
+		return cmp.Or(cmp.Compare(a.Chapter, b.Chapter), cmp.Compare(a.Sequence, b.Sequence), compareCursor(a.HadithCursor, b.HadithCursor))
+	}
+	matched := []store.Hadith{}
+	for _, h := range s.hadiths {
+		if h.CollectionCode != code || orZero(h.Book) != book || (chapter != 0 && orZero(h.Chapter) != chapter) {
+			continue
+		}
+		if cursor != nil && compare(pos(h), *cursor) <= 0 {
+			continue
+		}
+		matched = append(matched, h)
+	}
+	slices.SortFunc(matched, func(a, b store.Hadith) int { return compare(pos(a), pos(b)) })
+	if len(matched) <= limit {
+		return matched, nil, nil
+	}
+	next := pos(matched[limit-1])
+	return matched[:limit], &next, nil
+}
+
+func (s *Store) Collections(ctx context.Context) ([]store.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	collections := make([]store.Collection, 0, len(s.collections))
+	for _, c := range s.collections {
+		collections = append(collections, s.withCount(c))
+	}
+	slices.SortFunc(collections, func(a, b store.Collection) int { return strings.Compare(a.Code, b.Code) })
+	return collections, nil
+}
+
+func (s *Store) Collection(ctx context.Context, code string) (store.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return store.Collection{}, s.Err
+	}
+	for _, c := range s.collections {
+		if c.Code == code {
+			return s.withCount(c), nil
+		}
+	}
+	return store.Collection{}, store.ErrNotFound
+}
+
+func (s *Store) withCount(c store.Collection) store.Collection {
+	c.HadithCount = 0
+	for _, h := range s.hadiths {
+		if h.CollectionCode == c.Code {
+			c.HadithCount++
+		}
+	}
+	return c
+}
+
+func (s *Store) CollectionExists(ctx context.Context, code string) (bool, error) {
+	_, err := s.Collection(ctx, code)
+	if err == store.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Store) CollectionsVersion(ctx context.Context, code string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return "", s.Err
+	}
+	return fmt.Sprintf("%d.%d", len(s.filtered(store.HadithFilter{Collection: code})), s.version), nil
+}
+
+func (s *Store) filtered(f store.HadithFilter) []store.Hadith {
+	out := []store.Hadith{}
+	for _, h := range s.hadiths {
+		if f.Collection != "" && h.CollectionCode != f.Collection {
+			continue
+		}
+		if f.Grade != "" && (h.Grade == nil || *h.Grade != f.Grade) {
+			continue
+		}
+		if f.Topic != "" && !slices.Contains(h.Topics, f.Topic) {
+			continue
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+func (s *Store) CountHadiths(ctx context.Context, f store.HadithFilter) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return 0, s.Err
+	}
+	return int64(len(s.filtered(f))), nil
+}
+
+func (s *Store) NthHadith(ctx context.Context, f store.HadithFilter, n int64) (store.Hadith, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return store.Hadith{}, s.Err
+	}
+	hadiths := s.filtered(f)
+	if n < 0 || n >= int64(len(hadiths)) {
+		return store.Hadith{}, store.ErrNotFound
+	}
+	return hadiths[n], nil
+}
+
+// SearchText ranks hadiths by how many of the query's words their texts
+// contain, ignoring case.
+func (s *Store) SearchText(ctx context.Context, query string, limit int) ([]store.TextMatch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	words := strings.Fields(strings.ToLower(query))
+	normalized := strings.Fields(strings.ToLower(store.NormalizeTranslit(query)))
+	matches := []store.TextMatch{}
+	for _, h := range s.hadiths {
+		text := strings.ToLower(deref(h.TextRu) + " " + deref(h.TextEn) + " " + deref(h.TextAr))
+		translit := strings.ToLower(store.NormalizeTranslit(deref(h.TextTranslit)))
+		var n int
+		for i, w := range words {
+			if strings.Contains(text, w) || strings.Contains(translit, normalized[i]) {
+				n++
+			}
+		}
+		if n > 0 {
+			matches = append(matches, store.TextMatch{Hadith: h, Rank: float32(n) / float32(len(words))})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b store.TextMatch) int { return cmp.Compare(b.Rank, a.Rank) })
+	return matches[:min(limit, len(matches))], nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"regexp"
+	"strings"
+)
+
+// translitLetters drops the marks of scholarly transliteration: "ṣalāh" and
+// "qurʾān" are written "salah" and "quran" by most people.
+var translitLetters = strings.NewReplacer(
+	"ā", "a", "á", "a", "â", "a", "à", "a",
+	"ī", "i", "í", "i", "î", "i",
+	"ū", "u", "ú", "u", "û", "u",
+	"ē", "e", "ō", "o",
+	"ḥ", "h", "ḫ", "kh", "ṣ", "s", "ḍ", "d", "ṭ", "t", "ẓ", "z", "ḏ", "dh", "ṯ", "th", "ġ", "gh", "š", "sh",
+	"ʿ", "", "ʾ", "", "'", "", "’", "", "‘", "", "`", "",
+)
+
+// translitSpellings maps the common Latin spellings of Arabic terms to one of
+// them. Keys are lower case and already stripped by translitLetters.
+var translitSpellings = func() map[string]string {
+	groups := map[string][]string{
+		"salah":     {"salat", "solat", "salaat", "salaah", "solah", "swalah"},
+		"zakah":     {"zakat", "zakaat", "zakaah", "zakath"},
+		"sawm":      {"saum", "siyam", "siyaam"},
+		"hajj":      {"haj", "hadj", "hadjdj"},
+		"umrah":     {"umra", "omra", "umroh"},
+		"wudu":      {"wudhu", "wudoo", "wuduu", "wuzu"},
+		"ghusl":     {"gusl", "ghusal"},
+		"quran":     {"koran", "quraan", "kuran", "qoran"},
+		"hadith":    {"hadis", "hadeeth", "hadees", "hadiths", "ahadith"},
+		"sunnah":    {"sunna", "sunnat"},
+		"allah":     {"alloh", "allaah", "olloh"},
+		"muhammad":  {"mohammed", "muhammed", "mohammad", "mohamed", "mukhammad"},
+		"ramadan":   {"ramadhan", "ramazan", "ramzan"},
+		"jannah":    {"jannat", "janna"},
+		"jahannam":  {"jahanam", "jahannum"},
+		"dua":       {"duaa", "doa"},
+		"dhikr":     {"zikr", "zikir", "thikr", "zikar"},
+		"iman":      {"imaan", "eemaan", "eman"},
+		"ihsan":     {"ehsan"},
+		"tawhid":    {"tawheed", "tauhid", "tawhiid", "towheed"},
+		"shirk":     {"sherk"},
+		"taharah":   {"tahara", "taharat"},
+		"janazah":   {"janaza", "jenazah", "jenaza"},
+		"jumuah":    {"jumah", "jumma", "juma", "jumaa", "jumuaa"},
+		"adhan":     {"azan", "athan", "adhaan", "azaan"},
+		"iqamah":    {"iqama", "iqamat", "ikamah"},
+		"fajr":      {"fajar", "fadjr"},
+		"dhuhr":     {"zuhr", "zohr", "duhr", "zuhur"},
+		"asr":       {"asar"},
+		"maghrib":   {"magrib", "maghreb"},
+		"isha":      {"ishaa", "esha"},
+		"sadaqah":   {"sadaqa", "sadaka", "sadaqat"},
+		"nikah":     {"nikkah", "nikaah"},
+		"talaq":     {"talak", "talaaq"},
+		"riba":      {"ribaa"},
+		"fitnah":    {"fitna"},
+		"ummah":     {"umma", "ummat"},
+		"masjid":    {"masjed", "mesjid"},
+		"kabah":     {"kaaba", "kaba", "kaabah"},
+		"sahabah":   {"sahaba"},
+		"halal":     {"halaal"},
+		"haram":     {"haraam"},
+		"jihad":     {"jehad"},
+		"akhirah":   {"akhirat", "akhira", "ahirat"},
+		"qiyamah":   {"qiyamat", "qiyama", "kiyamat"},
+		"shaytan":   {"shaitan", "shetan", "shaytaan"},
+		"tasbih":    {"tasbeeh", "tasbeh"},
+		"istighfar": {"istigfar"},
+	}
+	out := map[string]string{}
+	for canonical, spellings := range groups {
+		out[canonical] = canonical
+		for _, s := range spellings {
+			out[s] = canonical
+		}
+	}
+	return out
+}()
+
+var latinWord = regexp.MustCompile(`[\p{Latin}'’‘ʿʾ` + "`" + `]+`)
+
+// NormalizeTranslit respells the transliterated Arabic terms of s the same
+// way, so that "solat", "salat" and "ṣalāh" all read "salah". Other words are
+// left as they are.
+func NormalizeTranslit(s string) string {
+	return latinWord.ReplaceAllStringFunc(s, func(w string) string {
+		if canonical, ok := translitSpellings[translitLetters.Replace(strings.ToLower(w))]; ok {
+			return canonical
+		}
+		return w
+	})
+}
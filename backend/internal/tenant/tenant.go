@@ -0,0 +1,35 @@
+// Package tenant carries the tenant a request acts for. Every tenant has its
+// own collections, hadiths, users, keys, webhooks, exports and jobs; one
+// deployment can host the corpora of several organizations.
+package tenant
+
+import "context"
+
+// Default is the tenant created by the migration that introduced tenants.
+// Data from before it, and requests that name no tenant, belong to it.
+const Default int64 = 1
+
+// All is the scope of background work that spans every tenant, such as the
+// index outbox and deployment-wide reindexes.
+const All int64 = 0
+
+type ctxKey struct{}
+
+// With returns a context acting for tenant id.
+func With(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// Unscoped returns a context that sees the data of every tenant.
+func Unscoped(ctx context.Context) context.Context {
+	return With(ctx, All)
+}
+
+// From returns the tenant of ctx: Default when none was set, All when ctx
+// is unscoped.
+func From(ctx context.Context) int64 {
+	if id, ok := ctx.Value(ctxKey{}).(int64); ok {
+		return id
+	}
+	return Default
+}
@@ -0,0 +1,145 @@
+package vector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/buugaaga/test-cursor/backend/internal/config"
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+var distances = map[string]qdrant.Distance{
+	"cosine":    qdrant.Distance_Cosine,
+	"dot":       qdrant.Distance_Dot,
+	"euclid":    qdrant.Distance_Euclid,
+	"manhattan": qdrant.Distance_Manhattan,
+}
+
+var compressionRatios = map[string]qdrant.CompressionRatio{
+	"x4":  qdrant.CompressionRatio_x4,
+	"x8":  qdrant.CompressionRatio_x8,
+	"x16": qdrant.CompressionRatio_x16,
+	"x32": qdrant.CompressionRatio_x32,
+	"x64": qdrant.CompressionRatio_x64,
+}
+
+// CollectionConfig is how the vector collection is created and indexed.
+// Nil HNSW fields leave Qdrant's defaults in place.
+type CollectionConfig struct {
+	Size          uint64
+	Distance      qdrant.Distance
+	HnswM         *uint64
+	EfConstruct   *uint64
+	OnDiskPayload bool
+	// Quantization is nil when vectors are stored unquantized.
+	Quantization *qdrant.QuantizationConfig
+}
+
+// NewCollectionConfig builds the collection settings from the qdrant
+// section of the configuration.
+func NewCollectionConfig(q config.Qdrant) (CollectionConfig, error) {
+	cfg := CollectionConfig{
+		Size:          q.VectorSize,
+		HnswM:         q.HnswM,
+		EfConstruct:   q.HnswEfConstruct,
+		OnDiskPayload: q.OnDiskPayload,
+	}
+	dist, ok := distances[strings.ToLower(q.Distance)]
+	if !ok {
+		return cfg, fmt.Errorf("invalid QDRANT_DISTANCE: want cosine, dot, euclid or manhattan")
+	}
+	cfg.Distance = dist
+
+	alwaysRAM := q.QuantizationInRAM
+	switch quant := strings.ToLower(q.Quantization); quant {
+	case "none":
+	case "scalar":
+		sq := &qdrant.ScalarQuantization{Type: qdrant.QuantizationType_Int8, AlwaysRam: &alwaysRAM}
+		if q.ScalarQuantile != nil {
+			sq.Quantile = qdrant.PtrOf(float32(*q.ScalarQuantile))
+		}
+		cfg.Quantization = qdrant.NewQuantizationScalar(sq)
+	case "product":
+		ratio, ok := compressionRatios[strings.ToLower(q.ProductCompression)]
+		if !ok {
+			return cfg, fmt.Errorf("invalid QDRANT_PRODUCT_COMPRESSION: want x4, x8, x16, x32 or x64")
+		}
+		cfg.Quantization = qdrant.NewQuantizationProduct(&qdrant.ProductQuantization{Compression: ratio, AlwaysRam: &alwaysRAM})
+	default:
+		return cfg, fmt.Errorf("invalid QDRANT_QUANTIZATION %q: want none, scalar or product", quant)
+	}
+	return cfg, nil
+}
+
+// EnsureCollection creates the collection from cfg. An existing collection
+// must match the vector size and distance, which Qdrant cannot change in
+// place; HNSW, payload storage and quantization are updated to match cfg.
+func EnsureCollection(ctx context.Context, q *qdrant.Client, name string, cfg CollectionConfig) error {
+	err := q.CreateCollection(ctx, &qdrant.CreateCollection{
+		CollectionName: name,
+		VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
+			Size:     cfg.Size,
+			Distance: cfg.Distance,
+		}),
+		HnswConfig:         &qdrant.HnswConfigDiff{M: cfg.HnswM, EfConstruct: cfg.EfConstruct},
+		OnDiskPayload:      &cfg.OnDiskPayload,
+		QuantizationConfig: cfg.Quantization,
+	})
+	if err == nil {
+		return nil
+	}
+	if st, ok := status.FromError(err); !ok || st.Code() != codes.AlreadyExists {
+		return err
+	}
+
+	info, err := q.GetCollectionInfo(ctx, name)
+	if err != nil {
+		return err
+	}
+	current := info.GetConfig()
+	vectors := current.GetParams().GetVectorsConfig().GetParams()
+	if vectors.GetSize() != cfg.Size || vectors.GetDistance() != cfg.Distance {
+		return fmt.Errorf("collection %s has %d-dimensional %s vectors, configured %d-dimensional %s; recreate it and reindex",
+			name, vectors.GetSize(), vectors.GetDistance(), cfg.Size, cfg.Distance)
+	}
+
+	update := &qdrant.UpdateCollection{CollectionName: name}
+	var changed []string
+	hnsw := current.GetHnswConfig()
+	if (cfg.HnswM != nil && *cfg.HnswM != hnsw.GetM()) || (cfg.EfConstruct != nil && *cfg.EfConstruct != hnsw.GetEfConstruct()) {
+		update.HnswConfig = &qdrant.HnswConfigDiff{M: cfg.HnswM, EfConstruct: cfg.EfConstruct}
+		changed = append(changed, "hnsw")
+	}
+	if current.GetParams().GetOnDiskPayload() != cfg.OnDiskPayload {
+		update.Params = &qdrant.CollectionParamsDiff{OnDiskPayload: &cfg.OnDiskPayload}
+		changed = append(changed, "on_disk_payload")
+	}
+	if !proto.Equal(current.GetQuantizationConfig(), cfg.Quantization) {
+		update.QuantizationConfig = quantizationDiff(cfg.Quantization)
+		changed = append(changed, "quantization")
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	if err := q.UpdateCollection(ctx, update); err != nil {
+		return fmt.Errorf("update collection %s: %w", name, err)
+	}
+	slog.Info("updated qdrant collection config", "collection", name, "changed", changed)
+	return nil
+}
+
+func quantizationDiff(c *qdrant.QuantizationConfig) *qdrant.QuantizationConfigDiff {
+	switch {
+	case c.GetScalar() != nil:
+		return qdrant.NewQuantizationDiffScalar(c.GetScalar())
+	case c.GetProduct() != nil:
+		return qdrant.NewQuantizationDiffProduct(c.GetProduct())
+	default:
+		return qdrant.NewQuantizationDiffDisabled()
+	}
+}
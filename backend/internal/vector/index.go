@@ -0,0 +1,214 @@
+package vector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/metrics"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Index is the vector store the search and ingest services use. QdrantIndex
+// is the production implementation; vectortest.Index is an in-memory fake for
+// tests.
+type Index interface {
+	// Search returns the points closest to vec that match filter, with
+	// payloads, best first.
+	Search(ctx context.Context, vec []float32, limit int, filter *qdrant.Filter) ([]*qdrant.ScoredPoint, error)
+	// HadithVector returns the indexed vector of hadith id, or nil when it
+	// has none.
+	HadithVector(ctx context.Context, id int64) ([]float32, error)
+	Upsert(ctx context.Context, points []*qdrant.PointStruct) error
+	// SetPayload sets the keys of payload and removes the keys in unset on
+	// the points that match filter, keeping their vectors.
+	SetPayload(ctx context.Context, filter *qdrant.Filter, payload map[string]*qdrant.Value, unset []string) error
+	// Delete removes the points that match filter.
+	Delete(ctx context.Context, filter *qdrant.Filter) error
+	// Scroll calls fn with pages of the points that match filter, with
+	// payloads and without vectors, until all are seen or fn fails.
+	Scroll(ctx context.Context, filter *qdrant.Filter, fn func([]*qdrant.RetrievedPoint) error) error
+	// Count returns the approximate number of points that match filter.
+	Count(ctx context.Context, filter *qdrant.Filter) (uint64, error)
+	Health(ctx context.Context) error
+}
+
+// QdrantIndex is the Index over one Qdrant collection. Its calls are
+// bounded and retried as opts say.
+type QdrantIndex struct {
+	client     *qdrant.Client
+	collection string
+	opts       QdrantOptions
+	health     *qdrantHealth
+}
+
+// NewIndex returns the Index over Collection.
+func NewIndex(client *qdrant.Client, opts QdrantOptions) *QdrantIndex {
+	return NewCollectionIndex(client, Collection, opts)
+}
+
+func NewCollectionIndex(client *qdrant.Client, collection string, opts QdrantOptions) *QdrantIndex {
+	return &QdrantIndex{client: client, collection: collection, opts: opts, health: newQdrantHealth(collection)}
+}
+
+func (x *QdrantIndex) Search(ctx context.Context, vec []float32, limit int, filter *qdrant.Filter) ([]*qdrant.ScoredPoint, error) {
+	start := time.Now()
+	var sp *qdrant.SearchResponse
+	err := x.call(ctx, "search", false, func(ctx context.Context) (err error) {
+		sp, err = x.client.GetPointsClient().Search(ctx, &qdrant.SearchPoints{
+			CollectionName: x.collection,
+			Vector:         vec,
+			Limit:          uint64(limit),
+			Filter:         filter,
+			WithPayload:    &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Enable{Enable: true}},
+		})
+		return err
+	})
+	metrics.ObserveQdrant(ctx, "search", start, err, "limit", limit, "filtered", filter != nil)
+	if err != nil {
+		return nil, err
+	}
+	return sp.Result, nil
+}
+
+func (x *QdrantIndex) HadithVector(ctx context.Context, id int64) ([]float32, error) {
+	start := time.Now()
+	var points []*qdrant.RetrievedPoint
+	err := x.call(ctx, "scroll", false, func(ctx context.Context) (err error) {
+		points, err = x.client.Scroll(ctx, &qdrant.ScrollPoints{
+			CollectionName: x.collection,
+			Filter: &qdrant.Filter{Must: []*qdrant.Condition{
+				qdrant.NewMatch("origin_type", "hadith"),
+				qdrant.NewMatchInt("origin_id", id),
+			}},
+			Limit:       qdrant.PtrOf(uint32(1)),
+			WithVectors: qdrant.NewWithVectors(true),
+		})
+		return err
+	})
+	metrics.ObserveQdrant(ctx, "scroll", start, err)
+	if err != nil || len(points) == 0 {
+		return nil, err
+	}
+	v := points[0].GetVectors().GetVector()
+	if vec := v.GetDense().GetData(); len(vec) > 0 {
+		return vec, nil
+	}
+	return v.GetData(), nil
+}
+
+func (x *QdrantIndex) Upsert(ctx context.Context, points []*qdrant.PointStruct) error {
+	start := time.Now()
+	err := x.call(ctx, "upsert", true, func(ctx context.Context) error {
+		_, err := x.client.Upsert(ctx, &qdrant.UpsertPoints{CollectionName: x.collection, Points: points})
+		return err
+	})
+	metrics.ObserveQdrant(ctx, "upsert", start, err, "points", len(points))
+	return err
+}
+
+func (x *QdrantIndex) SetPayload(ctx context.Context, filter *qdrant.Filter, payload map[string]*qdrant.Value, unset []string) error {
+	start := time.Now()
+	var err error
+	if len(payload) > 0 {
+		err = x.call(ctx, "set_payload", true, func(ctx context.Context) error {
+			_, err := x.client.SetPayload(ctx, &qdrant.SetPayloadPoints{
+				CollectionName: x.collection,
+				Wait:           qdrant.PtrOf(true),
+				Payload:        payload,
+				PointsSelector: qdrant.NewPointsSelectorFilter(filter),
+			})
+			return err
+		})
+	}
+	if err == nil && len(unset) > 0 {
+		err = x.call(ctx, "set_payload", true, func(ctx context.Context) error {
+			_, err := x.client.DeletePayload(ctx, &qdrant.DeletePayloadPoints{
+				CollectionName: x.collection,
+				Wait:           qdrant.PtrOf(true),
+				Keys:           unset,
+				PointsSelector: qdrant.NewPointsSelectorFilter(filter),
+			})
+			return err
+		})
+	}
+	metrics.ObserveQdrant(ctx, "set_payload", start, err)
+	return err
+}
+
+func (x *QdrantIndex) Delete(ctx context.Context, filter *qdrant.Filter) error {
+	start := time.Now()
+	err := x.call(ctx, "delete", true, func(ctx context.Context) error {
+		_, err := x.client.Delete(ctx, &qdrant.DeletePoints{
+			CollectionName: x.collection,
+			Wait:           qdrant.PtrOf(true),
+			Points:         qdrant.NewPointsSelectorFilter(filter),
+		})
+		return err
+	})
+	metrics.ObserveQdrant(ctx, "delete", start, err)
+	return err
+}
+
+func (x *QdrantIndex) Scroll(ctx context.Context, filter *qdrant.Filter, fn func([]*qdrant.RetrievedPoint) error) error {
+	var offset *qdrant.PointId
+	for {
+		start := time.Now()
+		var points []*qdrant.RetrievedPoint
+		var next *qdrant.PointId
+		err := x.call(ctx, "scroll", false, func(ctx context.Context) (err error) {
+			points, next, err = x.client.ScrollAndOffset(ctx, &qdrant.ScrollPoints{
+				CollectionName: x.collection,
+				Filter:         filter,
+				Offset:         offset,
+				Limit:          qdrant.PtrOf(uint32(1000)),
+				WithPayload:    qdrant.NewWithPayload(true),
+			})
+			return err
+		})
+		metrics.ObserveQdrant(ctx, "scroll", start, err)
+		if err != nil {
+			return err
+		}
+		if err := fn(points); err != nil {
+			return err
+		}
+		if next == nil {
+			return nil
+		}
+		offset = next
+	}
+}
+
+func (x *QdrantIndex) Count(ctx context.Context, filter *qdrant.Filter) (uint64, error) {
+	exact := false
+	start := time.Now()
+	var n uint64
+	err := x.call(ctx, "count", false, func(ctx context.Context) (err error) {
+		n, err = x.client.Count(ctx, &qdrant.CountPoints{CollectionName: x.collection, Filter: filter, Exact: &exact})
+		return err
+	})
+	metrics.ObserveQdrant(ctx, "count", start, err)
+	return n, err
+}
+
+// Health fails when the server does not answer a health check or when the
+// latest calls to the collection kept failing.
+func (x *QdrantIndex) Health(ctx context.Context) error {
+	if _, err := x.client.HealthCheck(ctx); err != nil {
+		return err
+	}
+	return x.health.err()
+}
+
+// PointID formats a point id as returned to clients.
+func PointID(id *qdrant.PointId) string {
+	switch p := id.GetPointIdOptions().(type) {
+	case *qdrant.PointId_Num:
+		return fmt.Sprintf("%d", p.Num)
+	case *qdrant.PointId_Uuid:
+		return p.Uuid
+	default:
+		return ""
+	}
+}
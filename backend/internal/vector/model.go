@@ -0,0 +1,67 @@
+package vector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// The embedding model is recorded per index: PrimaryIndex serves searches,
+// ShadowIndex is filled by the shadow embedder for comparison.
+const (
+	PrimaryIndex = "primary"
+	ShadowIndex  = "shadow"
+)
+
+// ModelMismatchError is returned by EnsureModel when the index holds
+// vectors of another embedding model than the configured one.
+type ModelMismatchError struct {
+	Indexed, Configured         string
+	IndexedDims, ConfiguredDims int
+}
+
+func (e *ModelMismatchError) Error() string {
+	return fmt.Sprintf("index holds %d-dimensional vectors of %s, embedder serves %d-dimensional %s",
+		e.IndexedDims, e.Indexed, e.ConfiguredDims, e.Configured)
+}
+
+// IndexedModel returns the embedding model recorded for index; ok is false
+// when none is.
+func IndexedModel(ctx context.Context, db *pgxpool.Pool, index string) (name string, dims int, ok bool, err error) {
+	err = db.QueryRow(ctx, `SELECT name, dimensions FROM embedding_model WHERE index_name = $1`, index).Scan(&name, &dims)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", 0, false, nil
+	}
+	return name, dims, err == nil, err
+}
+
+// EnsureModel records name as the embedding model of index when none is
+// recorded yet, and otherwise returns a *ModelMismatchError unless it is
+// the recorded one.
+func EnsureModel(ctx context.Context, db *pgxpool.Pool, index, name string, dims int) error {
+	if _, err := db.Exec(ctx, `
+INSERT INTO embedding_model (index_name, name, dimensions) VALUES ($1, $2, $3)
+ON CONFLICT (index_name) DO NOTHING`, index, name, dims); err != nil {
+		return err
+	}
+	indexed, indexedDims, _, err := IndexedModel(ctx, db, index)
+	if err != nil {
+		return err
+	}
+	if indexed != name || indexedDims != dims {
+		return &ModelMismatchError{Indexed: indexed, Configured: name, IndexedDims: indexedDims, ConfiguredDims: dims}
+	}
+	return nil
+}
+
+// SetModel records name as the embedding model of index, once its old
+// vectors are gone.
+func SetModel(ctx context.Context, db *pgxpool.Pool, index, name string, dims int) error {
+	_, err := db.Exec(ctx, `
+INSERT INTO embedding_model (index_name, name, dimensions) VALUES ($1, $2, $3)
+ON CONFLICT (index_name) DO UPDATE SET name = excluded.name, dimensions = excluded.dimensions, updated_at = now()`, index, name, dims)
+	return err
+}
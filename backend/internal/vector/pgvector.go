@@ -0,0 +1,461 @@
+package vector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// PGVectorTable holds the points when the pgvector backend is used, and
+// PGVectorShadowTable those of the shadow embedder.
+const (
+	PGVectorTable       = "vector_points"
+	PGVectorShadowTable = "vector_points_shadow"
+)
+
+type pgDistance struct {
+	// op orders by distance, nearest first; score turns the distance d into
+	// Qdrant's score for the same metric.
+	op, opclass, score string
+}
+
+var pgDistances = map[qdrant.Distance]pgDistance{
+	qdrant.Distance_Cosine:    {"<=>", "vector_cosine_ops", "1 - d"},
+	qdrant.Distance_Dot:       {"<#>", "vector_ip_ops", "-d"},
+	qdrant.Distance_Euclid:    {"<->", "vector_l2_ops", "d"},
+	qdrant.Distance_Manhattan: {"<+>", "vector_l1_ops", "d"},
+}
+
+// EnsurePGVector creates the pgvector extension, the points table and its
+// indexes from cfg. An existing table must have cfg's vector size.
+// Quantization and on-disk payload settings do not apply to pgvector.
+func EnsurePGVector(ctx context.Context, db *pgxpool.Pool, table string, cfg CollectionConfig) error {
+	dist, ok := pgDistances[cfg.Distance]
+	if !ok {
+		return fmt.Errorf("distance %s is not supported by pgvector", cfg.Distance)
+	}
+	if _, err := db.Exec(ctx, `CREATE EXTENSION IF NOT EXISTS vector`); err != nil {
+		return fmt.Errorf("create extension vector: %w", err)
+	}
+	_, err := db.Exec(ctx, fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s (
+  id UUID PRIMARY KEY,
+  embedding vector(%d) NOT NULL,
+  payload JSONB NOT NULL
+)`, table, cfg.Size))
+	if err != nil {
+		return err
+	}
+	var size int64
+	err = db.QueryRow(ctx, `
+SELECT atttypmod FROM pg_attribute
+WHERE attrelid = $1::regclass AND attname = 'embedding'`, table).Scan(&size)
+	if err != nil {
+		return err
+	}
+	if uint64(size) != cfg.Size {
+		return fmt.Errorf("%s has vectors of size %d, configured %d; drop the table and reindex to change it", table, size, cfg.Size)
+	}
+
+	var with []string
+	if cfg.HnswM != nil {
+		with = append(with, fmt.Sprintf("m = %d", *cfg.HnswM))
+	}
+	if cfg.EfConstruct != nil {
+		with = append(with, fmt.Sprintf("ef_construction = %d", *cfg.EfConstruct))
+	}
+	hnsw := fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_embedding_idx ON %[1]s USING hnsw (embedding %[2]s)`, table, dist.opclass)
+	if len(with) > 0 {
+		hnsw += " WITH (" + strings.Join(with, ", ") + ")"
+	}
+	for _, stmt := range []string{
+		hnsw,
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_origin_idx ON %[1]s ((payload->>'origin_type'), ((payload->>'origin_id')::bigint))`, table),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_collection_idx ON %[1]s ((payload->>'collection_code'))`, table),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_tenant_idx ON %[1]s (((payload->>'tenant_id')::bigint))`, table),
+	} {
+		if _, err := db.Exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DropPGVector drops a points table and its indexes.
+func DropPGVector(ctx context.Context, db *pgxpool.Pool, table string) error {
+	_, err := db.Exec(ctx, `DROP TABLE IF EXISTS `+table)
+	return err
+}
+
+// PGVectorIndex is the Index over a points table, for deployments that run on
+// Postgres alone. Point ids must be UUIDs and filters may only use the
+// keyword, integer and integer list matches the services build.
+type PGVectorIndex struct {
+	db    *pgxpool.Pool
+	table string
+	dist  pgDistance
+}
+
+func NewPGVectorIndex(db *pgxpool.Pool, table string, distance qdrant.Distance) (*PGVectorIndex, error) {
+	dist, ok := pgDistances[distance]
+	if !ok {
+		return nil, fmt.Errorf("distance %s is not supported by pgvector", distance)
+	}
+	return &PGVectorIndex{db: db, table: table, dist: dist}, nil
+}
+
+func (x *PGVectorIndex) Search(ctx context.Context, vec []float32, limit int, filter *qdrant.Filter) ([]*qdrant.ScoredPoint, error) {
+	args := []any{formatVector(vec), limit}
+	where, err := pgFilter(filter, &args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := x.db.Query(ctx, fmt.Sprintf(`
+SELECT id::text, payload, %s FROM (
+  SELECT id, payload, embedding %s $1::vector AS d FROM %s
+  WHERE %s
+  ORDER BY d
+  LIMIT $2
+) nearest ORDER BY d`, x.dist.score, x.dist.op, x.table, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	points := []*qdrant.ScoredPoint{}
+	var id string
+	var payload []byte
+	var score float64
+	_, err = pgx.ForEachRow(rows, []any{&id, &payload, &score}, func() error {
+		p, err := decodePayload(payload)
+		if err != nil {
+			return err
+		}
+		points = append(points, &qdrant.ScoredPoint{Id: uuidPoint(id), Payload: p, Score: float32(score)})
+		return nil
+	})
+	return points, err
+}
+
+func (x *PGVectorIndex) HadithVector(ctx context.Context, id int64) ([]float32, error) {
+	var text string
+	err := x.db.QueryRow(ctx, `
+SELECT embedding::text FROM `+x.table+`
+WHERE payload->>'origin_type' = 'hadith' AND (payload->>'origin_id')::bigint = $1
+LIMIT 1`, id).Scan(&text)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseVector(text)
+}
+
+func (x *PGVectorIndex) Upsert(ctx context.Context, points []*qdrant.PointStruct) error {
+	if len(points) == 0 {
+		return nil
+	}
+	batch := &pgx.Batch{}
+	for _, p := range points {
+		id := p.GetId().GetUuid()
+		if id == "" {
+			return fmt.Errorf("pgvector points need uuid ids, got %v", p.GetId())
+		}
+		v := p.GetVectors().GetVector()
+		vec := v.GetDense().GetData()
+		if len(vec) == 0 {
+			vec = v.GetData()
+		}
+		payload, err := encodePayload(p.GetPayload())
+		if err != nil {
+			return err
+		}
+		batch.Queue(`
+INSERT INTO `+x.table+` (id, embedding, payload) VALUES ($1, $2::vector, $3)
+ON CONFLICT (id) DO UPDATE SET embedding = EXCLUDED.embedding, payload = EXCLUDED.payload`,
+			id, formatVector(vec), payload)
+	}
+	return x.db.SendBatch(ctx, batch).Close()
+}
+
+func (x *PGVectorIndex) SetPayload(ctx context.Context, filter *qdrant.Filter, payload map[string]*qdrant.Value, unset []string) error {
+	set, err := encodePayload(payload)
+	if err != nil {
+		return err
+	}
+	args := []any{set, unset}
+	where, err := pgFilter(filter, &args)
+	if err != nil {
+		return err
+	}
+	_, err = x.db.Exec(ctx, `UPDATE `+x.table+` SET payload = (payload || $1::jsonb) - $2::text[] WHERE `+where, args...)
+	return err
+}
+
+func (x *PGVectorIndex) Delete(ctx context.Context, filter *qdrant.Filter) error {
+	args := []any{}
+	where, err := pgFilter(filter, &args)
+	if err != nil {
+		return err
+	}
+	_, err = x.db.Exec(ctx, `DELETE FROM `+x.table+` WHERE `+where, args...)
+	return err
+}
+
+func (x *PGVectorIndex) Scroll(ctx context.Context, filter *qdrant.Filter, fn func([]*qdrant.RetrievedPoint) error) error {
+	const pageSize = 1000
+	after := "00000000-0000-0000-0000-000000000000"
+	for {
+		args := []any{after, pageSize}
+		where, err := pgFilter(filter, &args)
+		if err != nil {
+			return err
+		}
+		rows, err := x.db.Query(ctx, `
+SELECT id::text, payload FROM `+x.table+`
+WHERE id > $1 AND `+where+`
+ORDER BY id
+LIMIT $2`, args...)
+		if err != nil {
+			return err
+		}
+		var page []*qdrant.RetrievedPoint
+		var id string
+		var payload []byte
+		_, err = pgx.ForEachRow(rows, []any{&id, &payload}, func() error {
+			p, err := decodePayload(payload)
+			if err != nil {
+				return err
+			}
+			page = append(page, &qdrant.RetrievedPoint{Id: uuidPoint(id), Payload: p})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if len(page) > 0 {
+			if err := fn(page); err != nil {
+				return err
+			}
+		}
+		if len(page) < pageSize {
+			return nil
+		}
+		after = id
+	}
+}
+
+func (x *PGVectorIndex) Count(ctx context.Context, filter *qdrant.Filter) (uint64, error) {
+	var args []any
+	where, err := pgFilter(filter, &args)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = x.db.QueryRow(ctx, `SELECT count(*) FROM `+x.table+` WHERE `+where, args...).Scan(&n)
+	return uint64(n), err
+}
+
+func (x *PGVectorIndex) Health(ctx context.Context) error {
+	return x.db.Ping(ctx)
+}
+
+func uuidPoint(id string) *qdrant.PointId {
+	return &qdrant.PointId{PointIdOptions: &qdrant.PointId_Uuid{Uuid: id}}
+}
+
+// pgFilter renders filter as a WHERE condition, appending its parameters
+// to args.
+func pgFilter(filter *qdrant.Filter, args *[]any) (string, error) {
+	conds := []string{"true"}
+	for _, c := range filter.GetMust() {
+		cond, err := pgCondition(c, args)
+		if err != nil {
+			return "", err
+		}
+		conds = append(conds, cond)
+	}
+	for _, c := range filter.GetMustNot() {
+		cond, err := pgCondition(c, args)
+		if err != nil {
+			return "", err
+		}
+		// A point without the field does not match the condition, so it is
+		// kept, as in Qdrant.
+		conds = append(conds, "("+cond+") IS NOT TRUE")
+	}
+	if len(filter.GetShould()) > 0 {
+		var should []string
+		for _, c := range filter.GetShould() {
+			cond, err := pgCondition(c, args)
+			if err != nil {
+				return "", err
+			}
+			should = append(should, "("+cond+") IS TRUE")
+		}
+		conds = append(conds, "("+strings.Join(should, " OR ")+")")
+	}
+	return strings.Join(conds, " AND "), nil
+}
+
+var payloadKey = regexp.MustCompile(`^[a-z_]+$`)
+
+func pgCondition(c *qdrant.Condition, args *[]any) (string, error) {
+	if f := c.GetFilter(); f != nil {
+		cond, err := pgFilter(f, args)
+		return "(" + cond + ")", err
+	}
+	if e := c.GetIsEmpty(); e != nil {
+		if !payloadKey.MatchString(e.GetKey()) {
+			return "", fmt.Errorf("invalid payload key %q", e.GetKey())
+		}
+		return fmt.Sprintf("COALESCE(payload->'%[1]s', 'null') IN ('null', '[]')", e.GetKey()), nil
+	}
+	field := c.GetField()
+	if field == nil {
+		return "", fmt.Errorf("unsupported pgvector filter condition %v", c)
+	}
+	param := func(v any) string {
+		*args = append(*args, v)
+		return "$" + strconv.Itoa(len(*args))
+	}
+	// The key is inlined so that the expression indexes match.
+	if !payloadKey.MatchString(field.GetKey()) {
+		return "", fmt.Errorf("invalid payload key %q", field.GetKey())
+	}
+	key := "'" + field.GetKey() + "'"
+	switch m := field.GetMatch().GetMatchValue().(type) {
+	case *qdrant.Match_Keyword:
+		return fmt.Sprintf("payload->>%s = %s", key, param(m.Keyword)), nil
+	case *qdrant.Match_Integer:
+		return fmt.Sprintf("(payload->>%s)::bigint = %s", key, param(m.Integer)), nil
+	case *qdrant.Match_Integers:
+		return fmt.Sprintf("(payload->>%s)::bigint = ANY(%s)", key, param(m.Integers.GetIntegers())), nil
+	default:
+		return "", fmt.Errorf("unsupported pgvector match on %s", field.GetKey())
+	}
+}
+
+// formatVector renders vec in pgvector's text format, e.g. [1,0.5,-2].
+func formatVector(vec []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, f := range vec {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(f), 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+func parseVector(s string) ([]float32, error) {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	vec := make([]float32, len(parts))
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(p, 32)
+		if err != nil {
+			return nil, err
+		}
+		vec[i] = float32(f)
+	}
+	return vec, nil
+}
+
+// encodePayload stores a Qdrant payload as JSON; decodePayload restores it
+// with integers and doubles told apart, as Qdrant returns them.
+func encodePayload(payload map[string]*qdrant.Value) ([]byte, error) {
+	m := make(map[string]any, len(payload))
+	for k, v := range payload {
+		m[k] = valueToAny(v)
+	}
+	return json.Marshal(m)
+}
+
+func valueToAny(v *qdrant.Value) any {
+	switch k := v.GetKind().(type) {
+	case *qdrant.Value_StringValue:
+		return k.StringValue
+	case *qdrant.Value_IntegerValue:
+		return k.IntegerValue
+	case *qdrant.Value_DoubleValue:
+		// A decimal point keeps whole doubles from decoding as integers.
+		if d := k.DoubleValue; d == math.Trunc(d) && !math.IsInf(d, 0) {
+			return json.Number(strconv.FormatFloat(d, 'f', 1, 64))
+		}
+		return k.DoubleValue
+	case *qdrant.Value_BoolValue:
+		return k.BoolValue
+	case *qdrant.Value_ListValue:
+		list := make([]any, 0, len(k.ListValue.GetValues()))
+		for _, item := range k.ListValue.GetValues() {
+			list = append(list, valueToAny(item))
+		}
+		return list
+	case *qdrant.Value_StructValue:
+		m := make(map[string]any, len(k.StructValue.GetFields()))
+		for key, item := range k.StructValue.GetFields() {
+			m[key] = valueToAny(item)
+		}
+		return m
+	default:
+		return nil
+	}
+}
+
+func decodePayload(b []byte) (map[string]*qdrant.Value, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var m map[string]any
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	payload := make(map[string]*qdrant.Value, len(m))
+	for k, v := range m {
+		payload[k] = anyToValue(v)
+	}
+	return payload, nil
+}
+
+func anyToValue(v any) *qdrant.Value {
+	switch v := v.(type) {
+	case string:
+		return qdrant.NewValueString(v)
+	case bool:
+		return qdrant.NewValueBool(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return qdrant.NewValueInt(i)
+		}
+		f, _ := v.Float64()
+		return qdrant.NewValueDouble(f)
+	case []any:
+		values := make([]*qdrant.Value, 0, len(v))
+		for _, item := range v {
+			values = append(values, anyToValue(item))
+		}
+		return qdrant.NewValueList(&qdrant.ListValue{Values: values})
+	case map[string]any:
+		fields := make(map[string]*qdrant.Value, len(v))
+		for k, item := range v {
+			fields[k] = anyToValue(item)
+		}
+		return qdrant.NewValueStruct(&qdrant.Struct{Fields: fields})
+	default:
+		return qdrant.NewValueNull()
+	}
+}
@@ -0,0 +1,126 @@
+package vector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/buugaaga/test-cursor/backend/internal/config"
+	"github.com/buugaaga/test-cursor/backend/internal/metrics"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// unhealthyAfter consecutive transient failures, the latest no older
+	// than unhealthyWindow, make Health fail.
+	unhealthyAfter  = 3
+	unhealthyWindow = time.Minute
+)
+
+// QdrantOptions bounds and retries the calls of a QdrantIndex.
+type QdrantOptions struct {
+	// Timeout bounds each attempt of a search or read, WriteTimeout each
+	// attempt of a write; zero means only the caller's deadline applies.
+	Timeout      time.Duration
+	WriteTimeout time.Duration
+	MaxRetries   int
+	RetryBackoff time.Duration
+}
+
+func NewQdrantOptions(q config.Qdrant) QdrantOptions {
+	return QdrantOptions{Timeout: q.Timeout, WriteTimeout: q.WriteTimeout, MaxRetries: q.MaxRetries, RetryBackoff: q.RetryBackoff}
+}
+
+// transient reports whether err may go away when the call is tried again.
+// Every write is idempotent: points are upserted under fixed ids and
+// payload changes and deletes select them by filter.
+func transient(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// call runs fn with the operation's timeout, retrying transient failures
+// with exponential backoff while ctx is live.
+func (x *QdrantIndex) call(ctx context.Context, op string, write bool, fn func(ctx context.Context) error) error {
+	timeout := x.opts.Timeout
+	if write {
+		timeout = x.opts.WriteTimeout
+	}
+	for attempt := 0; ; attempt++ {
+		actx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			actx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		err := fn(actx)
+		cancel()
+		retry := err != nil && ctx.Err() == nil && transient(err)
+		if ctx.Err() == nil {
+			x.health.record(err, retry)
+		}
+		if !retry || attempt >= x.opts.MaxRetries {
+			return err
+		}
+		metrics.QdrantRetries.WithLabelValues(op).Inc()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(x.opts.RetryBackoff << attempt):
+		}
+	}
+}
+
+// qdrantHealth tracks whether calls to a collection keep failing, which a
+// health check of the server alone does not show.
+type qdrantHealth struct {
+	collection string
+
+	mu       sync.Mutex
+	failures int
+	lastErr  error
+	lastAt   time.Time
+}
+
+func newQdrantHealth(collection string) *qdrantHealth {
+	metrics.QdrantUp.WithLabelValues(collection).Set(1)
+	return &qdrantHealth{collection: collection}
+}
+
+// record notes the outcome of an attempt; failures that retrying cannot
+// fix, such as a bad request, say nothing about availability.
+func (h *qdrantHealth) record(err error, isTransient bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !isTransient {
+		h.failures = 0
+		metrics.QdrantUp.WithLabelValues(h.collection).Set(1)
+		return
+	}
+	h.failures++
+	h.lastErr, h.lastAt = err, time.Now()
+	if h.failures >= unhealthyAfter {
+		metrics.QdrantUp.WithLabelValues(h.collection).Set(0)
+	}
+}
+
+func (h *qdrantHealth) err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.failures < unhealthyAfter {
+		return nil
+	}
+	if time.Since(h.lastAt) > unhealthyWindow {
+		h.failures = 0
+		metrics.QdrantUp.WithLabelValues(h.collection).Set(1)
+		return nil
+	}
+	return fmt.Errorf("%d calls in a row failed: %w", h.failures, h.lastErr)
+}
@@ -0,0 +1,36 @@
+package vector
+
+import (
+	"context"
+
+	"github.com/buugaaga/test-cursor/backend/internal/tenant"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// TenantCondition matches the points of tenant id. Points indexed before
+// tenants existed have no tenant_id and belong to the default tenant.
+func TenantCondition(id int64) *qdrant.Condition {
+	match := qdrant.NewMatchInt("tenant_id", id)
+	if id != tenant.Default {
+		return match
+	}
+	return qdrant.NewFilterAsCondition(&qdrant.Filter{
+		Should: []*qdrant.Condition{match, qdrant.NewIsEmpty("tenant_id")},
+	})
+}
+
+// ForTenant restricts filter, which may be nil, to the points of the tenant
+// of ctx. An unscoped ctx leaves it unchanged.
+func ForTenant(ctx context.Context, filter *qdrant.Filter) *qdrant.Filter {
+	id := tenant.From(ctx)
+	if id == tenant.All {
+		return filter
+	}
+	scoped := &qdrant.Filter{Must: []*qdrant.Condition{TenantCondition(id)}}
+	if filter != nil {
+		scoped.Must = append(scoped.Must, filter.GetMust()...)
+		scoped.Should = filter.GetShould()
+		scoped.MustNot = filter.GetMustNot()
+	}
+	return scoped
+}
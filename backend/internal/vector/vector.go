@@ -0,0 +1,75 @@
+// Package vector manages the Qdrant collection that holds the embeddings.
+package vector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Collection is the Qdrant collection holding hadith embeddings;
+// ShadowCollection holds those of the shadow embedder, when configured.
+const (
+	Collection       = "documents"
+	ShadowCollection = "documents_shadow"
+)
+
+// Open returns a client of Qdrant's gRPC API.
+func Open(host string, grpcPort int, useTLS bool, apiKey string) (*qdrant.Client, error) {
+	qClient, err := qdrant.NewClient(&qdrant.Config{
+		Host:   host,
+		Port:   grpcPort,
+		UseTLS: useTLS,
+		APIKey: apiKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return qClient, nil
+}
+
+// payloadIndexes are the payload fields search filters on.
+var payloadIndexes = []struct {
+	field     string
+	fieldType qdrant.FieldType
+	schema    qdrant.PayloadSchemaType
+}{
+	{"origin_type", qdrant.FieldType_FieldTypeKeyword, qdrant.PayloadSchemaType_Keyword},
+	{"origin_id", qdrant.FieldType_FieldTypeInteger, qdrant.PayloadSchemaType_Integer},
+	{"collection_code", qdrant.FieldType_FieldTypeKeyword, qdrant.PayloadSchemaType_Keyword},
+	{"lang", qdrant.FieldType_FieldTypeKeyword, qdrant.PayloadSchemaType_Keyword},
+	{"grade", qdrant.FieldType_FieldTypeKeyword, qdrant.PayloadSchemaType_Keyword},
+	{"tenant_id", qdrant.FieldType_FieldTypeInteger, qdrant.PayloadSchemaType_Integer},
+}
+
+// EnsurePayloadIndexes creates the missing payload indexes. An index that
+// exists with another type is left alone and reported, since changing it
+// means dropping it first.
+func EnsurePayloadIndexes(ctx context.Context, q *qdrant.Client, name string) error {
+	info, err := q.GetCollectionInfo(ctx, name)
+	if err != nil {
+		return err
+	}
+	existing := info.GetPayloadSchema()
+	for _, idx := range payloadIndexes {
+		if s, ok := existing[idx.field]; ok {
+			if s.GetDataType() != idx.schema {
+				slog.Warn("qdrant payload index has unexpected type", "field", idx.field, "type", s.GetDataType().String(), "want", idx.schema.String())
+			}
+			continue
+		}
+		_, err := q.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+			CollectionName: name,
+			FieldName:      idx.field,
+			FieldType:      idx.fieldType.Enum(),
+			Wait:           qdrant.PtrOf(true),
+		})
+		if err != nil {
+			return fmt.Errorf("index %s: %w", idx.field, err)
+		}
+		slog.Info("created qdrant payload index", "field", idx.field)
+	}
+	return nil
+}
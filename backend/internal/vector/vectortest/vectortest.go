@@ -0,0 +1,231 @@
+// Package vectortest provides an in-memory vector.Index for tests that run
+// without Qdrant.
+package vectortest
+
+import (
+	"context"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+
+	"github.com/buugaaga/test-cursor/backend/internal/vector"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+var _ vector.Index = (*Index)(nil)
+
+// Index keeps upserted points in memory and ranks them by cosine
+// similarity. Filters support the keyword, integer and integer list match,
+// is-empty and nested filter conditions the services build; any other
+// condition never matches. Err, when set, is
+// returned by every call.
+type Index struct {
+	Err error
+
+	mu     sync.Mutex
+	points map[string]*qdrant.PointStruct
+}
+
+func (x *Index) Search(ctx context.Context, vec []float32, limit int, filter *qdrant.Filter) ([]*qdrant.ScoredPoint, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.Err != nil {
+		return nil, x.Err
+	}
+	scored := []*qdrant.ScoredPoint{}
+	for _, p := range x.points {
+		if !matches(p.Payload, filter) {
+			continue
+		}
+		scored = append(scored, &qdrant.ScoredPoint{Id: p.Id, Payload: p.Payload, Score: cosine(vec, pointVector(p))})
+	}
+	slices.SortFunc(scored, func(a, b *qdrant.ScoredPoint) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		default:
+			return 0
+		}
+	})
+	return scored[:min(limit, len(scored))], nil
+}
+
+func (x *Index) HadithVector(ctx context.Context, id int64) ([]float32, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.Err != nil {
+		return nil, x.Err
+	}
+	filter := &qdrant.Filter{Must: []*qdrant.Condition{
+		qdrant.NewMatch("origin_type", "hadith"),
+		qdrant.NewMatchInt("origin_id", id),
+	}}
+	for _, p := range x.points {
+		if matches(p.Payload, filter) {
+			return pointVector(p), nil
+		}
+	}
+	return nil, nil
+}
+
+func (x *Index) Upsert(ctx context.Context, points []*qdrant.PointStruct) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.Err != nil {
+		return x.Err
+	}
+	if x.points == nil {
+		x.points = map[string]*qdrant.PointStruct{}
+	}
+	for _, p := range points {
+		x.points[vector.PointID(p.Id)] = p
+	}
+	return nil
+}
+
+func (x *Index) SetPayload(ctx context.Context, filter *qdrant.Filter, payload map[string]*qdrant.Value, unset []string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.Err != nil {
+		return x.Err
+	}
+	for id, p := range x.points {
+		if !matches(p.Payload, filter) {
+			continue
+		}
+		// Points are replaced rather than changed, since Points hands them out.
+		patched := maps.Clone(p.Payload)
+		maps.Copy(patched, payload)
+		for _, k := range unset {
+			delete(patched, k)
+		}
+		x.points[id] = &qdrant.PointStruct{Id: p.Id, Vectors: p.Vectors, Payload: patched}
+	}
+	return nil
+}
+
+func (x *Index) Delete(ctx context.Context, filter *qdrant.Filter) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.Err != nil {
+		return x.Err
+	}
+	for id, p := range x.points {
+		if matches(p.Payload, filter) {
+			delete(x.points, id)
+		}
+	}
+	return nil
+}
+
+// Scroll returns every matching point in one page.
+func (x *Index) Scroll(ctx context.Context, filter *qdrant.Filter, fn func([]*qdrant.RetrievedPoint) error) error {
+	x.mu.Lock()
+	if x.Err != nil {
+		x.mu.Unlock()
+		return x.Err
+	}
+	var page []*qdrant.RetrievedPoint
+	for _, p := range x.points {
+		if matches(p.Payload, filter) {
+			page = append(page, &qdrant.RetrievedPoint{Id: p.Id, Payload: p.Payload})
+		}
+	}
+	x.mu.Unlock()
+	return fn(page)
+}
+
+func (x *Index) Count(ctx context.Context, filter *qdrant.Filter) (uint64, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	var n uint64
+	for _, p := range x.points {
+		if matches(p.Payload, filter) {
+			n++
+		}
+	}
+	return n, x.Err
+}
+
+func (x *Index) Health(ctx context.Context) error {
+	return x.Err
+}
+
+// Points returns a copy of the stored points.
+func (x *Index) Points() []*qdrant.PointStruct {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	out := make([]*qdrant.PointStruct, 0, len(x.points))
+	for _, p := range x.points {
+		out = append(out, p)
+	}
+	return out
+}
+
+func pointVector(p *qdrant.PointStruct) []float32 {
+	v := p.GetVectors().GetVector()
+	if vec := v.GetDense().GetData(); len(vec) > 0 {
+		return vec
+	}
+	return v.GetData()
+}
+
+func matches(payload map[string]*qdrant.Value, filter *qdrant.Filter) bool {
+	for _, c := range filter.GetMust() {
+		if !matchCondition(payload, c) {
+			return false
+		}
+	}
+	for _, c := range filter.GetMustNot() {
+		if matchCondition(payload, c) {
+			return false
+		}
+	}
+	if len(filter.GetShould()) > 0 {
+		return slices.ContainsFunc(filter.GetShould(), func(c *qdrant.Condition) bool {
+			return matchCondition(payload, c)
+		})
+	}
+	return true
+}
+
+func matchCondition(payload map[string]*qdrant.Value, c *qdrant.Condition) bool {
+	if f := c.GetFilter(); f != nil {
+		return matches(payload, f)
+	}
+	if e := c.GetIsEmpty(); e != nil {
+		_, ok := payload[e.GetKey()]
+		return !ok
+	}
+	field := c.GetField()
+	v, ok := payload[field.GetKey()]
+	if field == nil || !ok {
+		return false
+	}
+	switch m := field.GetMatch().GetMatchValue().(type) {
+	case *qdrant.Match_Keyword:
+		return v.GetStringValue() == m.Keyword
+	case *qdrant.Match_Integer:
+		return v.GetIntegerValue() == m.Integer
+	case *qdrant.Match_Integers:
+		return slices.Contains(m.Integers.GetIntegers(), v.GetIntegerValue())
+	default:
+		return false
+	}
+}
+
+func cosine(a, b []float32) float32 {
+	var dot, na, nb float64
+	for i := range min(len(a), len(b)) {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return float32(dot / math.Sqrt(na*nb))
+}
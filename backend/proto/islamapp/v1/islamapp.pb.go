@@ -0,0 +1,1457 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: islamapp/v1/islamapp.proto
+
+package islamappv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SearchRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Query string `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	// 1-50, defaults to 10.
+	Limit int32 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+}
+
+func (x *SearchRequest) Reset() {
+	*x = SearchRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_islamapp_v1_islamapp_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchRequest) ProtoMessage() {}
+
+func (x *SearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_islamapp_v1_islamapp_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return file_islamapp_v1_islamapp_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SearchRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type SearchResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id      string           `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Score   float32          `protobuf:"fixed32,2,opt,name=score,proto3" json:"score,omitempty"`
+	Payload *structpb.Struct `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
+}
+
+func (x *SearchResult) Reset() {
+	*x = SearchResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_islamapp_v1_islamapp_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SearchResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchResult) ProtoMessage() {}
+
+func (x *SearchResult) ProtoReflect() protoreflect.Message {
+	mi := &file_islamapp_v1_islamapp_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchResult.ProtoReflect.Descriptor instead.
+func (*SearchResult) Descriptor() ([]byte, []int) {
+	return file_islamapp_v1_islamapp_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SearchResult) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *SearchResult) GetScore() float32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *SearchResult) GetPayload() *structpb.Struct {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+type SearchResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Results []*SearchResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+}
+
+func (x *SearchResponse) Reset() {
+	*x = SearchResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_islamapp_v1_islamapp_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SearchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchResponse) ProtoMessage() {}
+
+func (x *SearchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_islamapp_v1_islamapp_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchResponse.ProtoReflect.Descriptor instead.
+func (*SearchResponse) Descriptor() ([]byte, []int) {
+	return file_islamapp_v1_islamapp_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SearchResponse) GetResults() []*SearchResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type HadithCollection struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Code  string `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Title string `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+}
+
+func (x *HadithCollection) Reset() {
+	*x = HadithCollection{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_islamapp_v1_islamapp_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HadithCollection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HadithCollection) ProtoMessage() {}
+
+func (x *HadithCollection) ProtoReflect() protoreflect.Message {
+	mi := &file_islamapp_v1_islamapp_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HadithCollection.ProtoReflect.Descriptor instead.
+func (*HadithCollection) Descriptor() ([]byte, []int) {
+	return file_islamapp_v1_islamapp_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *HadithCollection) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *HadithCollection) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+type HadithInput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Number string   `protobuf:"bytes,1,opt,name=number,proto3" json:"number,omitempty"`
+	TextAr string   `protobuf:"bytes,2,opt,name=text_ar,json=textAr,proto3" json:"text_ar,omitempty"`
+	TextRu string   `protobuf:"bytes,3,opt,name=text_ru,json=textRu,proto3" json:"text_ru,omitempty"`
+	TextEn string   `protobuf:"bytes,4,opt,name=text_en,json=textEn,proto3" json:"text_en,omitempty"`
+	Grade  string   `protobuf:"bytes,5,opt,name=grade,proto3" json:"grade,omitempty"`
+	Topics []string `protobuf:"bytes,6,rep,name=topics,proto3" json:"topics,omitempty"`
+}
+
+func (x *HadithInput) Reset() {
+	*x = HadithInput{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_islamapp_v1_islamapp_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HadithInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HadithInput) ProtoMessage() {}
+
+func (x *HadithInput) ProtoReflect() protoreflect.Message {
+	mi := &file_islamapp_v1_islamapp_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HadithInput.ProtoReflect.Descriptor instead.
+func (*HadithInput) Descriptor() ([]byte, []int) {
+	return file_islamapp_v1_islamapp_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *HadithInput) GetNumber() string {
+	if x != nil {
+		return x.Number
+	}
+	return ""
+}
+
+func (x *HadithInput) GetTextAr() string {
+	if x != nil {
+		return x.TextAr
+	}
+	return ""
+}
+
+func (x *HadithInput) GetTextRu() string {
+	if x != nil {
+		return x.TextRu
+	}
+	return ""
+}
+
+func (x *HadithInput) GetTextEn() string {
+	if x != nil {
+		return x.TextEn
+	}
+	return ""
+}
+
+func (x *HadithInput) GetGrade() string {
+	if x != nil {
+		return x.Grade
+	}
+	return ""
+}
+
+func (x *HadithInput) GetTopics() []string {
+	if x != nil {
+		return x.Topics
+	}
+	return nil
+}
+
+type UploadHadithsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Collection *HadithCollection `protobuf:"bytes,1,opt,name=collection,proto3" json:"collection,omitempty"`
+	Hadiths    []*HadithInput    `protobuf:"bytes,2,rep,name=hadiths,proto3" json:"hadiths,omitempty"`
+}
+
+func (x *UploadHadithsRequest) Reset() {
+	*x = UploadHadithsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_islamapp_v1_islamapp_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UploadHadithsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadHadithsRequest) ProtoMessage() {}
+
+func (x *UploadHadithsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_islamapp_v1_islamapp_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadHadithsRequest.ProtoReflect.Descriptor instead.
+func (*UploadHadithsRequest) Descriptor() ([]byte, []int) {
+	return file_islamapp_v1_islamapp_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UploadHadithsRequest) GetCollection() *HadithCollection {
+	if x != nil {
+		return x.Collection
+	}
+	return nil
+}
+
+func (x *UploadHadithsRequest) GetHadiths() []*HadithInput {
+	if x != nil {
+		return x.Hadiths
+	}
+	return nil
+}
+
+type UploadHadithsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Inserted int32 `protobuf:"varint,1,opt,name=inserted,proto3" json:"inserted,omitempty"`
+	Embedded int32 `protobuf:"varint,2,opt,name=embedded,proto3" json:"embedded,omitempty"`
+}
+
+func (x *UploadHadithsResponse) Reset() {
+	*x = UploadHadithsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_islamapp_v1_islamapp_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UploadHadithsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadHadithsResponse) ProtoMessage() {}
+
+func (x *UploadHadithsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_islamapp_v1_islamapp_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadHadithsResponse.ProtoReflect.Descriptor instead.
+func (*UploadHadithsResponse) Descriptor() ([]byte, []int) {
+	return file_islamapp_v1_islamapp_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *UploadHadithsResponse) GetInserted() int32 {
+	if x != nil {
+		return x.Inserted
+	}
+	return 0
+}
+
+func (x *UploadHadithsResponse) GetEmbedded() int32 {
+	if x != nil {
+		return x.Embedded
+	}
+	return 0
+}
+
+type Hadith struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id             int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	CollectionCode string                 `protobuf:"bytes,2,opt,name=collection_code,json=collectionCode,proto3" json:"collection_code,omitempty"`
+	Number         string                 `protobuf:"bytes,3,opt,name=number,proto3" json:"number,omitempty"`
+	TextAr         *string                `protobuf:"bytes,4,opt,name=text_ar,json=textAr,proto3,oneof" json:"text_ar,omitempty"`
+	TextRu         *string                `protobuf:"bytes,5,opt,name=text_ru,json=textRu,proto3,oneof" json:"text_ru,omitempty"`
+	TextEn         *string                `protobuf:"bytes,6,opt,name=text_en,json=textEn,proto3,oneof" json:"text_en,omitempty"`
+	Grade          *string                `protobuf:"bytes,7,opt,name=grade,proto3,oneof" json:"grade,omitempty"`
+	Topics         []string               `protobuf:"bytes,8,rep,name=topics,proto3" json:"topics,omitempty"`
+	UpdatedAt      *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+}
+
+func (x *Hadith) Reset() {
+	*x = Hadith{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_islamapp_v1_islamapp_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Hadith) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Hadith) ProtoMessage() {}
+
+func (x *Hadith) ProtoReflect() protoreflect.Message {
+	mi := &file_islamapp_v1_islamapp_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Hadith.ProtoReflect.Descriptor instead.
+func (*Hadith) Descriptor() ([]byte, []int) {
+	return file_islamapp_v1_islamapp_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Hadith) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Hadith) GetCollectionCode() string {
+	if x != nil {
+		return x.CollectionCode
+	}
+	return ""
+}
+
+func (x *Hadith) GetNumber() string {
+	if x != nil {
+		return x.Number
+	}
+	return ""
+}
+
+func (x *Hadith) GetTextAr() string {
+	if x != nil && x.TextAr != nil {
+		return *x.TextAr
+	}
+	return ""
+}
+
+func (x *Hadith) GetTextRu() string {
+	if x != nil && x.TextRu != nil {
+		return *x.TextRu
+	}
+	return ""
+}
+
+func (x *Hadith) GetTextEn() string {
+	if x != nil && x.TextEn != nil {
+		return *x.TextEn
+	}
+	return ""
+}
+
+func (x *Hadith) GetGrade() string {
+	if x != nil && x.Grade != nil {
+		return *x.Grade
+	}
+	return ""
+}
+
+func (x *Hadith) GetTopics() []string {
+	if x != nil {
+		return x.Topics
+	}
+	return nil
+}
+
+func (x *Hadith) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type Collection struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id          int64  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Code        string `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	Title       string `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	HadithCount int64  `protobuf:"varint,4,opt,name=hadith_count,json=hadithCount,proto3" json:"hadith_count,omitempty"`
+}
+
+func (x *Collection) Reset() {
+	*x = Collection{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_islamapp_v1_islamapp_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Collection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Collection) ProtoMessage() {}
+
+func (x *Collection) ProtoReflect() protoreflect.Message {
+	mi := &file_islamapp_v1_islamapp_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Collection.ProtoReflect.Descriptor instead.
+func (*Collection) Descriptor() ([]byte, []int) {
+	return file_islamapp_v1_islamapp_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Collection) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Collection) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Collection) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Collection) GetHadithCount() int64 {
+	if x != nil {
+		return x.HadithCount
+	}
+	return 0
+}
+
+type CollectionList struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Collections []*Collection `protobuf:"bytes,1,rep,name=collections,proto3" json:"collections,omitempty"`
+}
+
+func (x *CollectionList) Reset() {
+	*x = CollectionList{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_islamapp_v1_islamapp_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CollectionList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CollectionList) ProtoMessage() {}
+
+func (x *CollectionList) ProtoReflect() protoreflect.Message {
+	mi := &file_islamapp_v1_islamapp_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CollectionList.ProtoReflect.Descriptor instead.
+func (*CollectionList) Descriptor() ([]byte, []int) {
+	return file_islamapp_v1_islamapp_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *CollectionList) GetCollections() []*Collection {
+	if x != nil {
+		return x.Collections
+	}
+	return nil
+}
+
+type HadithList struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Hadiths    []*Hadith `protobuf:"bytes,1,rep,name=hadiths,proto3" json:"hadiths,omitempty"`
+	NextCursor *string   `protobuf:"bytes,2,opt,name=next_cursor,json=nextCursor,proto3,oneof" json:"next_cursor,omitempty"`
+}
+
+func (x *HadithList) Reset() {
+	*x = HadithList{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_islamapp_v1_islamapp_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HadithList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HadithList) ProtoMessage() {}
+
+func (x *HadithList) ProtoReflect() protoreflect.Message {
+	mi := &file_islamapp_v1_islamapp_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HadithList.ProtoReflect.Descriptor instead.
+func (*HadithList) Descriptor() ([]byte, []int) {
+	return file_islamapp_v1_islamapp_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *HadithList) GetHadiths() []*Hadith {
+	if x != nil {
+		return x.Hadiths
+	}
+	return nil
+}
+
+func (x *HadithList) GetNextCursor() string {
+	if x != nil && x.NextCursor != nil {
+		return *x.NextCursor
+	}
+	return ""
+}
+
+type BatchGetHadithsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Hadiths []*Hadith `protobuf:"bytes,1,rep,name=hadiths,proto3" json:"hadiths,omitempty"`
+	Missing []int64   `protobuf:"varint,2,rep,packed,name=missing,proto3" json:"missing,omitempty"`
+}
+
+func (x *BatchGetHadithsResponse) Reset() {
+	*x = BatchGetHadithsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_islamapp_v1_islamapp_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BatchGetHadithsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetHadithsResponse) ProtoMessage() {}
+
+func (x *BatchGetHadithsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_islamapp_v1_islamapp_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetHadithsResponse.ProtoReflect.Descriptor instead.
+func (*BatchGetHadithsResponse) Descriptor() ([]byte, []int) {
+	return file_islamapp_v1_islamapp_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *BatchGetHadithsResponse) GetHadiths() []*Hadith {
+	if x != nil {
+		return x.Hadiths
+	}
+	return nil
+}
+
+func (x *BatchGetHadithsResponse) GetMissing() []int64 {
+	if x != nil {
+		return x.Missing
+	}
+	return nil
+}
+
+type DailyHadith struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Date      string  `protobuf:"bytes,1,opt,name=date,proto3" json:"date,omitempty"`
+	HijriDate string  `protobuf:"bytes,2,opt,name=hijri_date,json=hijriDate,proto3" json:"hijri_date,omitempty"`
+	Calendar  string  `protobuf:"bytes,3,opt,name=calendar,proto3" json:"calendar,omitempty"`
+	Hadith    *Hadith `protobuf:"bytes,4,opt,name=hadith,proto3" json:"hadith,omitempty"`
+}
+
+func (x *DailyHadith) Reset() {
+	*x = DailyHadith{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_islamapp_v1_islamapp_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DailyHadith) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DailyHadith) ProtoMessage() {}
+
+func (x *DailyHadith) ProtoReflect() protoreflect.Message {
+	mi := &file_islamapp_v1_islamapp_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DailyHadith.ProtoReflect.Descriptor instead.
+func (*DailyHadith) Descriptor() ([]byte, []int) {
+	return file_islamapp_v1_islamapp_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *DailyHadith) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *DailyHadith) GetHijriDate() string {
+	if x != nil {
+		return x.HijriDate
+	}
+	return ""
+}
+
+func (x *DailyHadith) GetCalendar() string {
+	if x != nil {
+		return x.Calendar
+	}
+	return ""
+}
+
+func (x *DailyHadith) GetHadith() *Hadith {
+	if x != nil {
+		return x.Hadith
+	}
+	return nil
+}
+
+type CollectionStats struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Code    string `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Title   string `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Hadiths int64  `protobuf:"varint,3,opt,name=hadiths,proto3" json:"hadiths,omitempty"`
+	TextAr  int64  `protobuf:"varint,4,opt,name=text_ar,json=textAr,proto3" json:"text_ar,omitempty"`
+	TextRu  int64  `protobuf:"varint,5,opt,name=text_ru,json=textRu,proto3" json:"text_ru,omitempty"`
+	TextEn  int64  `protobuf:"varint,6,opt,name=text_en,json=textEn,proto3" json:"text_en,omitempty"`
+}
+
+func (x *CollectionStats) Reset() {
+	*x = CollectionStats{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_islamapp_v1_islamapp_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CollectionStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CollectionStats) ProtoMessage() {}
+
+func (x *CollectionStats) ProtoReflect() protoreflect.Message {
+	mi := &file_islamapp_v1_islamapp_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CollectionStats.ProtoReflect.Descriptor instead.
+func (*CollectionStats) Descriptor() ([]byte, []int) {
+	return file_islamapp_v1_islamapp_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *CollectionStats) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *CollectionStats) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *CollectionStats) GetHadiths() int64 {
+	if x != nil {
+		return x.Hadiths
+	}
+	return 0
+}
+
+func (x *CollectionStats) GetTextAr() int64 {
+	if x != nil {
+		return x.TextAr
+	}
+	return 0
+}
+
+func (x *CollectionStats) GetTextRu() int64 {
+	if x != nil {
+		return x.TextRu
+	}
+	return 0
+}
+
+func (x *CollectionStats) GetTextEn() int64 {
+	if x != nil {
+		return x.TextEn
+	}
+	return 0
+}
+
+type CorpusStats struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Collections    []*CollectionStats     `protobuf:"bytes,1,rep,name=collections,proto3" json:"collections,omitempty"`
+	Hadiths        int64                  `protobuf:"varint,2,opt,name=hadiths,proto3" json:"hadiths,omitempty"`
+	Languages      map[string]int64       `protobuf:"bytes,3,rep,name=languages,proto3" json:"languages,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	Grades         map[string]int64       `protobuf:"bytes,4,rep,name=grades,proto3" json:"grades,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	IndexedVectors uint64                 `protobuf:"varint,5,opt,name=indexed_vectors,json=indexedVectors,proto3" json:"indexed_vectors,omitempty"`
+	ComputedAt     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=computed_at,json=computedAt,proto3" json:"computed_at,omitempty"`
+}
+
+func (x *CorpusStats) Reset() {
+	*x = CorpusStats{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_islamapp_v1_islamapp_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CorpusStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CorpusStats) ProtoMessage() {}
+
+func (x *CorpusStats) ProtoReflect() protoreflect.Message {
+	mi := &file_islamapp_v1_islamapp_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CorpusStats.ProtoReflect.Descriptor instead.
+func (*CorpusStats) Descriptor() ([]byte, []int) {
+	return file_islamapp_v1_islamapp_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *CorpusStats) GetCollections() []*CollectionStats {
+	if x != nil {
+		return x.Collections
+	}
+	return nil
+}
+
+func (x *CorpusStats) GetHadiths() int64 {
+	if x != nil {
+		return x.Hadiths
+	}
+	return 0
+}
+
+func (x *CorpusStats) GetLanguages() map[string]int64 {
+	if x != nil {
+		return x.Languages
+	}
+	return nil
+}
+
+func (x *CorpusStats) GetGrades() map[string]int64 {
+	if x != nil {
+		return x.Grades
+	}
+	return nil
+}
+
+func (x *CorpusStats) GetIndexedVectors() uint64 {
+	if x != nil {
+		return x.IndexedVectors
+	}
+	return 0
+}
+
+func (x *CorpusStats) GetComputedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ComputedAt
+	}
+	return nil
+}
+
+var File_islamapp_v1_islamapp_proto protoreflect.FileDescriptor
+
+var file_islamapp_v1_islamapp_proto_rawDesc = []byte{
+	0x0a, 0x1a, 0x69, 0x73, 0x6c, 0x61, 0x6d, 0x61, 0x70, 0x70, 0x2f, 0x76, 0x31, 0x2f, 0x69, 0x73,
+	0x6c, 0x61, 0x6d, 0x61, 0x70, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0b, 0x69, 0x73,
+	0x6c, 0x61, 0x6d, 0x61, 0x70, 0x70, 0x2e, 0x76, 0x31, 0x1a, 0x1c, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x73, 0x74, 0x72, 0x75, 0x63,
+	0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x3b, 0x0a, 0x0d, 0x53, 0x65, 0x61, 0x72,
+	0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x71, 0x75, 0x65,
+	0x72, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x12,
+	0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05,
+	0x6c, 0x69, 0x6d, 0x69, 0x74, 0x22, 0x67, 0x0a, 0x0c, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x52,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x02, 0x52, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x12, 0x31, 0x0a, 0x07, 0x70,
+	0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53,
+	0x74, 0x72, 0x75, 0x63, 0x74, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x22, 0x45,
+	0x0a, 0x0e, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x33, 0x0a, 0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x19, 0x2e, 0x69, 0x73, 0x6c, 0x61, 0x6d, 0x61, 0x70, 0x70, 0x2e, 0x76, 0x31, 0x2e,
+	0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x07, 0x72, 0x65,
+	0x73, 0x75, 0x6c, 0x74, 0x73, 0x22, 0x3c, 0x0a, 0x10, 0x48, 0x61, 0x64, 0x69, 0x74, 0x68, 0x43,
+	0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x14, 0x0a,
+	0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x69,
+	0x74, 0x6c, 0x65, 0x22, 0x9e, 0x01, 0x0a, 0x0b, 0x48, 0x61, 0x64, 0x69, 0x74, 0x68, 0x49, 0x6e,
+	0x70, 0x75, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x17, 0x0a, 0x07, 0x74,
+	0x65, 0x78, 0x74, 0x5f, 0x61, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x74, 0x65,
+	0x78, 0x74, 0x41, 0x72, 0x12, 0x17, 0x0a, 0x07, 0x74, 0x65, 0x78, 0x74, 0x5f, 0x72, 0x75, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x74, 0x65, 0x78, 0x74, 0x52, 0x75, 0x12, 0x17, 0x0a,
+	0x07, 0x74, 0x65, 0x78, 0x74, 0x5f, 0x65, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x74, 0x65, 0x78, 0x74, 0x45, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x67, 0x72, 0x61, 0x64, 0x65, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x67, 0x72, 0x61, 0x64, 0x65, 0x12, 0x16, 0x0a, 0x06,
+	0x74, 0x6f, 0x70, 0x69, 0x63, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x09, 0x52, 0x06, 0x74, 0x6f,
+	0x70, 0x69, 0x63, 0x73, 0x22, 0x89, 0x01, 0x0a, 0x14, 0x55, 0x70, 0x6c, 0x6f, 0x61, 0x64, 0x48,
+	0x61, 0x64, 0x69, 0x74, 0x68, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3d, 0x0a,
+	0x0a, 0x63, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1d, 0x2e, 0x69, 0x73, 0x6c, 0x61, 0x6d, 0x61, 0x70, 0x70, 0x2e, 0x76, 0x31, 0x2e,
+	0x48, 0x61, 0x64, 0x69, 0x74, 0x68, 0x43, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x52, 0x0a, 0x63, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x32, 0x0a, 0x07,
+	0x68, 0x61, 0x64, 0x69, 0x74, 0x68, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e,
+	0x69, 0x73, 0x6c, 0x61, 0x6d, 0x61, 0x70, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x48, 0x61, 0x64, 0x69,
+	0x74, 0x68, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x52, 0x07, 0x68, 0x61, 0x64, 0x69, 0x74, 0x68, 0x73,
+	0x22, 0x4f, 0x0a, 0x15, 0x55, 0x70, 0x6c, 0x6f, 0x61, 0x64, 0x48, 0x61, 0x64, 0x69, 0x74, 0x68,
+	0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x69, 0x6e, 0x73,
+	0x65, 0x72, 0x74, 0x65, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x69, 0x6e, 0x73,
+	0x65, 0x72, 0x74, 0x65, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x6d, 0x62, 0x65, 0x64, 0x64, 0x65,
+	0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x65, 0x6d, 0x62, 0x65, 0x64, 0x64, 0x65,
+	0x64, 0x22, 0xcf, 0x02, 0x0a, 0x06, 0x48, 0x61, 0x64, 0x69, 0x74, 0x68, 0x12, 0x0e, 0x0a, 0x02,
+	0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x02, 0x69, 0x64, 0x12, 0x27, 0x0a, 0x0f,
+	0x63, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x63, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x1c, 0x0a,
+	0x07, 0x74, 0x65, 0x78, 0x74, 0x5f, 0x61, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00,
+	0x52, 0x06, 0x74, 0x65, 0x78, 0x74, 0x41, 0x72, 0x88, 0x01, 0x01, 0x12, 0x1c, 0x0a, 0x07, 0x74,
+	0x65, 0x78, 0x74, 0x5f, 0x72, 0x75, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x48, 0x01, 0x52, 0x06,
+	0x74, 0x65, 0x78, 0x74, 0x52, 0x75, 0x88, 0x01, 0x01, 0x12, 0x1c, 0x0a, 0x07, 0x74, 0x65, 0x78,
+	0x74, 0x5f, 0x65, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x48, 0x02, 0x52, 0x06, 0x74, 0x65,
+	0x78, 0x74, 0x45, 0x6e, 0x88, 0x01, 0x01, 0x12, 0x19, 0x0a, 0x05, 0x67, 0x72, 0x61, 0x64, 0x65,
+	0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x48, 0x03, 0x52, 0x05, 0x67, 0x72, 0x61, 0x64, 0x65, 0x88,
+	0x01, 0x01, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x73, 0x18, 0x08, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x06, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x73, 0x12, 0x39, 0x0a, 0x0a, 0x75, 0x70,
+	0x64, 0x61, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x75, 0x70, 0x64, 0x61,
+	0x74, 0x65, 0x64, 0x41, 0x74, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x74, 0x65, 0x78, 0x74, 0x5f, 0x61,
+	0x72, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x74, 0x65, 0x78, 0x74, 0x5f, 0x72, 0x75, 0x42, 0x0a, 0x0a,
+	0x08, 0x5f, 0x74, 0x65, 0x78, 0x74, 0x5f, 0x65, 0x6e, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x67, 0x72,
+	0x61, 0x64, 0x65, 0x22, 0x69, 0x0a, 0x0a, 0x43, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x02, 0x69,
+	0x64, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x68,
+	0x61, 0x64, 0x69, 0x74, 0x68, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x0b, 0x68, 0x61, 0x64, 0x69, 0x74, 0x68, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x22, 0x4b,
+	0x0a, 0x0e, 0x43, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x4c, 0x69, 0x73, 0x74,
+	0x12, 0x39, 0x0a, 0x0b, 0x63, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18,
+	0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x69, 0x73, 0x6c, 0x61, 0x6d, 0x61, 0x70, 0x70,
+	0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0b,
+	0x63, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x22, 0x71, 0x0a, 0x0a, 0x48,
+	0x61, 0x64, 0x69, 0x74, 0x68, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x2d, 0x0a, 0x07, 0x68, 0x61, 0x64,
+	0x69, 0x74, 0x68, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x69, 0x73, 0x6c,
+	0x61, 0x6d, 0x61, 0x70, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x48, 0x61, 0x64, 0x69, 0x74, 0x68, 0x52,
+	0x07, 0x68, 0x61, 0x64, 0x69, 0x74, 0x68, 0x73, 0x12, 0x24, 0x0a, 0x0b, 0x6e, 0x65, 0x78, 0x74,
+	0x5f, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52,
+	0x0a, 0x6e, 0x65, 0x78, 0x74, 0x43, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x88, 0x01, 0x01, 0x42, 0x0e,
+	0x0a, 0x0c, 0x5f, 0x6e, 0x65, 0x78, 0x74, 0x5f, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x22, 0x62,
+	0x0a, 0x17, 0x42, 0x61, 0x74, 0x63, 0x68, 0x47, 0x65, 0x74, 0x48, 0x61, 0x64, 0x69, 0x74, 0x68,
+	0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2d, 0x0a, 0x07, 0x68, 0x61, 0x64,
+	0x69, 0x74, 0x68, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x69, 0x73, 0x6c,
+	0x61, 0x6d, 0x61, 0x70, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x48, 0x61, 0x64, 0x69, 0x74, 0x68, 0x52,
+	0x07, 0x68, 0x61, 0x64, 0x69, 0x74, 0x68, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x69, 0x73, 0x73,
+	0x69, 0x6e, 0x67, 0x18, 0x02, 0x20, 0x03, 0x28, 0x03, 0x52, 0x07, 0x6d, 0x69, 0x73, 0x73, 0x69,
+	0x6e, 0x67, 0x22, 0x89, 0x01, 0x0a, 0x0b, 0x44, 0x61, 0x69, 0x6c, 0x79, 0x48, 0x61, 0x64, 0x69,
+	0x74, 0x68, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x64, 0x61, 0x74, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x68, 0x69, 0x6a, 0x72, 0x69, 0x5f,
+	0x64, 0x61, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x68, 0x69, 0x6a, 0x72,
+	0x69, 0x44, 0x61, 0x74, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x61, 0x6c, 0x65, 0x6e, 0x64, 0x61,
+	0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x63, 0x61, 0x6c, 0x65, 0x6e, 0x64, 0x61,
+	0x72, 0x12, 0x2b, 0x0a, 0x06, 0x68, 0x61, 0x64, 0x69, 0x74, 0x68, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x13, 0x2e, 0x69, 0x73, 0x6c, 0x61, 0x6d, 0x61, 0x70, 0x70, 0x2e, 0x76, 0x31, 0x2e,
+	0x48, 0x61, 0x64, 0x69, 0x74, 0x68, 0x52, 0x06, 0x68, 0x61, 0x64, 0x69, 0x74, 0x68, 0x22, 0xa0,
+	0x01, 0x0a, 0x0f, 0x43, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61,
+	0x74, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x12, 0x18, 0x0a, 0x07,
+	0x68, 0x61, 0x64, 0x69, 0x74, 0x68, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x68,
+	0x61, 0x64, 0x69, 0x74, 0x68, 0x73, 0x12, 0x17, 0x0a, 0x07, 0x74, 0x65, 0x78, 0x74, 0x5f, 0x61,
+	0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x74, 0x65, 0x78, 0x74, 0x41, 0x72, 0x12,
+	0x17, 0x0a, 0x07, 0x74, 0x65, 0x78, 0x74, 0x5f, 0x72, 0x75, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x06, 0x74, 0x65, 0x78, 0x74, 0x52, 0x75, 0x12, 0x17, 0x0a, 0x07, 0x74, 0x65, 0x78, 0x74,
+	0x5f, 0x65, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x74, 0x65, 0x78, 0x74, 0x45,
+	0x6e, 0x22, 0xcb, 0x03, 0x0a, 0x0b, 0x43, 0x6f, 0x72, 0x70, 0x75, 0x73, 0x53, 0x74, 0x61, 0x74,
+	0x73, 0x12, 0x3e, 0x0a, 0x0b, 0x63, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x69, 0x73, 0x6c, 0x61, 0x6d, 0x61, 0x70,
+	0x70, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x53,
+	0x74, 0x61, 0x74, 0x73, 0x52, 0x0b, 0x63, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x73, 0x12, 0x18, 0x0a, 0x07, 0x68, 0x61, 0x64, 0x69, 0x74, 0x68, 0x73, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x07, 0x68, 0x61, 0x64, 0x69, 0x74, 0x68, 0x73, 0x12, 0x45, 0x0a, 0x09, 0x6c,
+	0x61, 0x6e, 0x67, 0x75, 0x61, 0x67, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x27,
+	0x2e, 0x69, 0x73, 0x6c, 0x61, 0x6d, 0x61, 0x70, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x72,
+	0x70, 0x75, 0x73, 0x53, 0x74, 0x61, 0x74, 0x73, 0x2e, 0x4c, 0x61, 0x6e, 0x67, 0x75, 0x61, 0x67,
+	0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x09, 0x6c, 0x61, 0x6e, 0x67, 0x75, 0x61, 0x67,
+	0x65, 0x73, 0x12, 0x3c, 0x0a, 0x06, 0x67, 0x72, 0x61, 0x64, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x24, 0x2e, 0x69, 0x73, 0x6c, 0x61, 0x6d, 0x61, 0x70, 0x70, 0x2e, 0x76, 0x31,
+	0x2e, 0x43, 0x6f, 0x72, 0x70, 0x75, 0x73, 0x53, 0x74, 0x61, 0x74, 0x73, 0x2e, 0x47, 0x72, 0x61,
+	0x64, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06, 0x67, 0x72, 0x61, 0x64, 0x65, 0x73,
+	0x12, 0x27, 0x0a, 0x0f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x65, 0x64, 0x5f, 0x76, 0x65, 0x63, 0x74,
+	0x6f, 0x72, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x69, 0x6e, 0x64, 0x65, 0x78,
+	0x65, 0x64, 0x56, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x73, 0x12, 0x3b, 0x0a, 0x0b, 0x63, 0x6f, 0x6d,
+	0x70, 0x75, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a, 0x63, 0x6f, 0x6d, 0x70,
+	0x75, 0x74, 0x65, 0x64, 0x41, 0x74, 0x1a, 0x3c, 0x0a, 0x0e, 0x4c, 0x61, 0x6e, 0x67, 0x75, 0x61,
+	0x67, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x3a, 0x02, 0x38, 0x01, 0x1a, 0x39, 0x0a, 0x0b, 0x47, 0x72, 0x61, 0x64, 0x65, 0x73, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x32,
+	0xac, 0x01, 0x0a, 0x0f, 0x49, 0x73, 0x6c, 0x61, 0x6d, 0x41, 0x70, 0x70, 0x53, 0x65, 0x72, 0x76,
+	0x69, 0x63, 0x65, 0x12, 0x41, 0x0a, 0x06, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x12, 0x1a, 0x2e,
+	0x69, 0x73, 0x6c, 0x61, 0x6d, 0x61, 0x70, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x61, 0x72,
+	0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x69, 0x73, 0x6c, 0x61,
+	0x6d, 0x61, 0x70, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x56, 0x0a, 0x0d, 0x55, 0x70, 0x6c, 0x6f, 0x61, 0x64,
+	0x48, 0x61, 0x64, 0x69, 0x74, 0x68, 0x73, 0x12, 0x21, 0x2e, 0x69, 0x73, 0x6c, 0x61, 0x6d, 0x61,
+	0x70, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x55, 0x70, 0x6c, 0x6f, 0x61, 0x64, 0x48, 0x61, 0x64, 0x69,
+	0x74, 0x68, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x22, 0x2e, 0x69, 0x73, 0x6c,
+	0x61, 0x6d, 0x61, 0x70, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x55, 0x70, 0x6c, 0x6f, 0x61, 0x64, 0x48,
+	0x61, 0x64, 0x69, 0x74, 0x68, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x46,
+	0x5a, 0x44, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x62, 0x75, 0x75,
+	0x67, 0x61, 0x61, 0x67, 0x61, 0x2f, 0x74, 0x65, 0x73, 0x74, 0x2d, 0x63, 0x75, 0x72, 0x73, 0x6f,
+	0x72, 0x2f, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f,
+	0x69, 0x73, 0x6c, 0x61, 0x6d, 0x61, 0x70, 0x70, 0x2f, 0x76, 0x31, 0x3b, 0x69, 0x73, 0x6c, 0x61,
+	0x6d, 0x61, 0x70, 0x70, 0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_islamapp_v1_islamapp_proto_rawDescOnce sync.Once
+	file_islamapp_v1_islamapp_proto_rawDescData = file_islamapp_v1_islamapp_proto_rawDesc
+)
+
+func file_islamapp_v1_islamapp_proto_rawDescGZIP() []byte {
+	file_islamapp_v1_islamapp_proto_rawDescOnce.Do(func() {
+		file_islamapp_v1_islamapp_proto_rawDescData = protoimpl.X.CompressGZIP(file_islamapp_v1_islamapp_proto_rawDescData)
+	})
+	return file_islamapp_v1_islamapp_proto_rawDescData
+}
+
+var file_islamapp_v1_islamapp_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
+var file_islamapp_v1_islamapp_proto_goTypes = []any{
+	(*SearchRequest)(nil),           // 0: islamapp.v1.SearchRequest
+	(*SearchResult)(nil),            // 1: islamapp.v1.SearchResult
+	(*SearchResponse)(nil),          // 2: islamapp.v1.SearchResponse
+	(*HadithCollection)(nil),        // 3: islamapp.v1.HadithCollection
+	(*HadithInput)(nil),             // 4: islamapp.v1.HadithInput
+	(*UploadHadithsRequest)(nil),    // 5: islamapp.v1.UploadHadithsRequest
+	(*UploadHadithsResponse)(nil),   // 6: islamapp.v1.UploadHadithsResponse
+	(*Hadith)(nil),                  // 7: islamapp.v1.Hadith
+	(*Collection)(nil),              // 8: islamapp.v1.Collection
+	(*CollectionList)(nil),          // 9: islamapp.v1.CollectionList
+	(*HadithList)(nil),              // 10: islamapp.v1.HadithList
+	(*BatchGetHadithsResponse)(nil), // 11: islamapp.v1.BatchGetHadithsResponse
+	(*DailyHadith)(nil),             // 12: islamapp.v1.DailyHadith
+	(*CollectionStats)(nil),         // 13: islamapp.v1.CollectionStats
+	(*CorpusStats)(nil),             // 14: islamapp.v1.CorpusStats
+	nil,                             // 15: islamapp.v1.CorpusStats.LanguagesEntry
+	nil,                             // 16: islamapp.v1.CorpusStats.GradesEntry
+	(*structpb.Struct)(nil),         // 17: google.protobuf.Struct
+	(*timestamppb.Timestamp)(nil),   // 18: google.protobuf.Timestamp
+}
+var file_islamapp_v1_islamapp_proto_depIdxs = []int32{
+	17, // 0: islamapp.v1.SearchResult.payload:type_name -> google.protobuf.Struct
+	1,  // 1: islamapp.v1.SearchResponse.results:type_name -> islamapp.v1.SearchResult
+	3,  // 2: islamapp.v1.UploadHadithsRequest.collection:type_name -> islamapp.v1.HadithCollection
+	4,  // 3: islamapp.v1.UploadHadithsRequest.hadiths:type_name -> islamapp.v1.HadithInput
+	18, // 4: islamapp.v1.Hadith.updated_at:type_name -> google.protobuf.Timestamp
+	8,  // 5: islamapp.v1.CollectionList.collections:type_name -> islamapp.v1.Collection
+	7,  // 6: islamapp.v1.HadithList.hadiths:type_name -> islamapp.v1.Hadith
+	7,  // 7: islamapp.v1.BatchGetHadithsResponse.hadiths:type_name -> islamapp.v1.Hadith
+	7,  // 8: islamapp.v1.DailyHadith.hadith:type_name -> islamapp.v1.Hadith
+	13, // 9: islamapp.v1.CorpusStats.collections:type_name -> islamapp.v1.CollectionStats
+	15, // 10: islamapp.v1.CorpusStats.languages:type_name -> islamapp.v1.CorpusStats.LanguagesEntry
+	16, // 11: islamapp.v1.CorpusStats.grades:type_name -> islamapp.v1.CorpusStats.GradesEntry
+	18, // 12: islamapp.v1.CorpusStats.computed_at:type_name -> google.protobuf.Timestamp
+	0,  // 13: islamapp.v1.IslamAppService.Search:input_type -> islamapp.v1.SearchRequest
+	5,  // 14: islamapp.v1.IslamAppService.UploadHadiths:input_type -> islamapp.v1.UploadHadithsRequest
+	2,  // 15: islamapp.v1.IslamAppService.Search:output_type -> islamapp.v1.SearchResponse
+	6,  // 16: islamapp.v1.IslamAppService.UploadHadiths:output_type -> islamapp.v1.UploadHadithsResponse
+	15, // [15:17] is the sub-list for method output_type
+	13, // [13:15] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
+}
+
+func init() { file_islamapp_v1_islamapp_proto_init() }
+func file_islamapp_v1_islamapp_proto_init() {
+	if File_islamapp_v1_islamapp_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_islamapp_v1_islamapp_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*SearchRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_islamapp_v1_islamapp_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*SearchResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_islamapp_v1_islamapp_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*SearchResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_islamapp_v1_islamapp_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*HadithCollection); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_islamapp_v1_islamapp_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*HadithInput); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_islamapp_v1_islamapp_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*UploadHadithsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_islamapp_v1_islamapp_proto_msgTypes[6].Exporter = func(v any, i int) any {
+			switch v := v.(*UploadHadithsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_islamapp_v1_islamapp_proto_msgTypes[7].Exporter = func(v any, i int) any {
+			switch v := v.(*Hadith); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_islamapp_v1_islamapp_proto_msgTypes[8].Exporter = func(v any, i int) any {
+			switch v := v.(*Collection); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_islamapp_v1_islamapp_proto_msgTypes[9].Exporter = func(v any, i int) any {
+			switch v := v.(*CollectionList); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_islamapp_v1_islamapp_proto_msgTypes[10].Exporter = func(v any, i int) any {
+			switch v := v.(*HadithList); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_islamapp_v1_islamapp_proto_msgTypes[11].Exporter = func(v any, i int) any {
+			switch v := v.(*BatchGetHadithsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_islamapp_v1_islamapp_proto_msgTypes[12].Exporter = func(v any, i int) any {
+			switch v := v.(*DailyHadith); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_islamapp_v1_islamapp_proto_msgTypes[13].Exporter = func(v any, i int) any {
+			switch v := v.(*CollectionStats); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_islamapp_v1_islamapp_proto_msgTypes[14].Exporter = func(v any, i int) any {
+			switch v := v.(*CorpusStats); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_islamapp_v1_islamapp_proto_msgTypes[7].OneofWrappers = []any{}
+	file_islamapp_v1_islamapp_proto_msgTypes[10].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_islamapp_v1_islamapp_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   17,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_islamapp_v1_islamapp_proto_goTypes,
+		DependencyIndexes: file_islamapp_v1_islamapp_proto_depIdxs,
+		MessageInfos:      file_islamapp_v1_islamapp_proto_msgTypes,
+	}.Build()
+	File_islamapp_v1_islamapp_proto = out.File
+	file_islamapp_v1_islamapp_proto_rawDesc = nil
+	file_islamapp_v1_islamapp_proto_goTypes = nil
+	file_islamapp_v1_islamapp_proto_depIdxs = nil
+}
@@ -0,0 +1,165 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: islamapp/v1/islamapp.proto
+
+package islamappv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	IslamAppService_Search_FullMethodName        = "/islamapp.v1.IslamAppService/Search"
+	IslamAppService_UploadHadiths_FullMethodName = "/islamapp.v1.IslamAppService/UploadHadiths"
+)
+
+// IslamAppServiceClient is the client API for IslamAppService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// IslamAppService exposes the same search and ingestion operations as the
+// HTTP API (POST /v1/search, POST /v1/admin/hadiths/upload).
+type IslamAppServiceClient interface {
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	UploadHadiths(ctx context.Context, in *UploadHadithsRequest, opts ...grpc.CallOption) (*UploadHadithsResponse, error)
+}
+
+type islamAppServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewIslamAppServiceClient(cc grpc.ClientConnInterface) IslamAppServiceClient {
+	return &islamAppServiceClient{cc}
+}
+
+func (c *islamAppServiceClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, IslamAppService_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *islamAppServiceClient) UploadHadiths(ctx context.Context, in *UploadHadithsRequest, opts ...grpc.CallOption) (*UploadHadithsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UploadHadithsResponse)
+	err := c.cc.Invoke(ctx, IslamAppService_UploadHadiths_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// IslamAppServiceServer is the server API for IslamAppService service.
+// All implementations must embed UnimplementedIslamAppServiceServer
+// for forward compatibility.
+//
+// IslamAppService exposes the same search and ingestion operations as the
+// HTTP API (POST /v1/search, POST /v1/admin/hadiths/upload).
+type IslamAppServiceServer interface {
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	UploadHadiths(context.Context, *UploadHadithsRequest) (*UploadHadithsResponse, error)
+	mustEmbedUnimplementedIslamAppServiceServer()
+}
+
+// UnimplementedIslamAppServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedIslamAppServiceServer struct{}
+
+func (UnimplementedIslamAppServiceServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedIslamAppServiceServer) UploadHadiths(context.Context, *UploadHadithsRequest) (*UploadHadithsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UploadHadiths not implemented")
+}
+func (UnimplementedIslamAppServiceServer) mustEmbedUnimplementedIslamAppServiceServer() {}
+func (UnimplementedIslamAppServiceServer) testEmbeddedByValue()                         {}
+
+// UnsafeIslamAppServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to IslamAppServiceServer will
+// result in compilation errors.
+type UnsafeIslamAppServiceServer interface {
+	mustEmbedUnimplementedIslamAppServiceServer()
+}
+
+func RegisterIslamAppServiceServer(s grpc.ServiceRegistrar, srv IslamAppServiceServer) {
+	// If the following call pancis, it indicates UnimplementedIslamAppServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&IslamAppService_ServiceDesc, srv)
+}
+
+func _IslamAppService_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IslamAppServiceServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IslamAppService_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IslamAppServiceServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IslamAppService_UploadHadiths_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadHadithsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IslamAppServiceServer).UploadHadiths(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IslamAppService_UploadHadiths_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IslamAppServiceServer).UploadHadiths(ctx, req.(*UploadHadithsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// IslamAppService_ServiceDesc is the grpc.ServiceDesc for IslamAppService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var IslamAppService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "islamapp.v1.IslamAppService",
+	HandlerType: (*IslamAppServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Search",
+			Handler:    _IslamAppService_Search_Handler,
+		},
+		{
+			MethodName: "UploadHadiths",
+			Handler:    _IslamAppService_UploadHadiths_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "islamapp/v1/islamapp.proto",
+}
@@ -1,13 +1,15 @@
-from fastapi import FastAPI
+from fastapi import FastAPI, Header, HTTPException
 from pydantic import BaseModel
 from sentence_transformers import SentenceTransformer
 import os
 
 MODEL_NAME = os.getenv("MODEL_NAME", "intfloat/multilingual-e5-base")
+API_KEY = os.getenv("API_KEY", "")
 
 embedder_app = FastAPI(title="Embedding Service", version="0.1.0")
 
 model = SentenceTransformer(MODEL_NAME)
+DIMENSIONS = model.get_sentence_embedding_dimension()
 
 class EmbedRequest(BaseModel):
     texts: list[str]
@@ -17,10 +19,12 @@ class EmbedResponse(BaseModel):
 
 @embedder_app.get("/healthz")
 def healthz():
-    return {"status": "ok", "model": MODEL_NAME}
+    return {"status": "ok", "model": MODEL_NAME, "dimensions": DIMENSIONS}
 
 @embedder_app.post("/embed", response_model=EmbedResponse)
-def embed(req: EmbedRequest):
+def embed(req: EmbedRequest, authorization: str | None = Header(default=None)):
+    if API_KEY and authorization != f"Bearer {API_KEY}":
+        raise HTTPException(status_code=401, detail="invalid api key")
     if not req.texts:
         return {"embeddings": []}
     vectors = model.encode(req.texts, normalize_embeddings=True, convert_to_numpy=True)